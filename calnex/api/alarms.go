@@ -0,0 +1,188 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const getAlarmsURL = "https://%s/api/getalarms"
+
+// DefaultAlarmInterval is how often device alarms are polled
+const DefaultAlarmInterval = 10 * time.Second
+
+// AlarmType is a kind of instrument alarm
+type AlarmType string
+
+// Alarm types reported by the device
+const (
+	AlarmReferenceLost   AlarmType = "reference_lost"
+	AlarmModulesNotReady AlarmType = "modules_not_ready"
+	AlarmChannelError    AlarmType = "channel_error"
+	AlarmStorageFull     AlarmType = "storage_full"
+)
+
+// Alarm is a struct representing active Calnex instrument alarm
+type Alarm struct {
+	Type AlarmType
+	// Channel is set for channel alarms
+	Channel *Channel
+	Message string
+}
+
+// key identifies the alarm between polls
+func (a *Alarm) key() string {
+	if a.Channel == nil {
+		return string(a.Type)
+	}
+	return fmt.Sprintf("%s/%s", a.Type, a.Channel)
+}
+
+// alarms is a struct representing Calnex alarms JSON response
+type alarms struct {
+	Alarms []*Alarm
+}
+
+// FetchAlarms returns active alarms of the device.
+// Firmware without alarms API gets reference and modules alarms derived from the status
+func (a *API) FetchAlarms() ([]*Alarm, error) {
+	url := fmt.Sprintf(getAlarmsURL, a.source)
+	resp, err := a.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return a.statusAlarms()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	al := &alarms{}
+	if err = json.NewDecoder(resp.Body).Decode(al); err != nil {
+		return nil, err
+	}
+	return al.Alarms, nil
+}
+
+func (a *API) statusAlarms() ([]*Alarm, error) {
+	s, err := a.FetchStatus()
+	if err != nil {
+		return nil, err
+	}
+	al := []*Alarm{}
+	if !s.ReferenceReady {
+		al = append(al, &Alarm{Type: AlarmReferenceLost, Message: "reference is not ready"})
+	}
+	if !s.ModulesReady {
+		al = append(al, &Alarm{Type: AlarmModulesNotReady, Message: "modules are not ready"})
+	}
+	return al, nil
+}
+
+// AlarmEvent is a change of the alarm state
+type AlarmEvent struct {
+	Source string
+	Time   time.Time
+	Alarm  *Alarm
+	// Raised is true when alarm becomes active and false when it's cleared
+	Raised bool
+}
+
+// AlarmHandler is notified about every alarm event
+type AlarmHandler func(e *AlarmEvent)
+
+// AlarmPoller polls device alarms and notifies handler when they are raised or cleared
+type AlarmPoller struct {
+	Interval time.Duration
+	Handler  AlarmHandler
+
+	api    *API
+	active map[string]*Alarm
+}
+
+// NewAlarmPoller returns AlarmPoller of the device calling handler on alarm events
+func (a *API) NewAlarmPoller(handler AlarmHandler) *AlarmPoller {
+	return &AlarmPoller{
+		Interval: DefaultAlarmInterval,
+		Handler:  handler,
+		api:      a,
+		active:   map[string]*Alarm{},
+	}
+}
+
+// Active returns currently active alarms
+func (p *AlarmPoller) Active() []*Alarm {
+	keys := make([]string, 0, len(p.active))
+	for k := range p.active {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	al := make([]*Alarm, 0, len(keys))
+	for _, k := range keys {
+		al = append(al, p.active[k])
+	}
+	return al
+}
+
+// Poll fetches alarms once, notifying handler about the ones raised and cleared since the previous poll
+func (p *AlarmPoller) Poll() error {
+	al, err := p.api.FetchAlarms()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	current := map[string]*Alarm{}
+	for _, a := range al {
+		current[a.key()] = a
+	}
+	for _, a := range al {
+		if _, ok := p.active[a.key()]; !ok {
+			p.Handler(&AlarmEvent{Source: p.api.source, Time: now, Alarm: a, Raised: true})
+		}
+	}
+	for _, a := range p.Active() {
+		if _, ok := current[a.key()]; !ok {
+			p.Handler(&AlarmEvent{Source: p.api.source, Time: now, Alarm: a, Raised: false})
+		}
+	}
+	p.active = current
+	return nil
+}
+
+// Run polls alarms every Interval until ctx is done. Poll failures are logged and retried
+func (p *AlarmPoller) Run(ctx context.Context) error {
+	for {
+		if err := p.Poll(); err != nil {
+			log.Warningf("%s: failed to poll alarms: %v", p.api.source, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.Interval):
+		}
+	}
+}
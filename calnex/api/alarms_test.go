@@ -0,0 +1,136 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// alarmDevice serves alarms. Old firmware without alarms API only serves status
+type alarmDevice struct {
+	alarms         string
+	referenceReady bool
+}
+
+func (d *alarmDevice) start() (*API, func()) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "getalarms"):
+			if d.alarms == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, d.alarms)
+		case strings.Contains(r.URL.Path, "getstatus"):
+			fmt.Fprintf(w, `{"referenceReady": %t, "modulesReady": true, "measurementActive": true}`, d.referenceReady)
+		}
+	}))
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	return calnexAPI, ts.Close
+}
+
+func TestFetchAlarms(t *testing.T) {
+	d := &alarmDevice{alarms: `{"alarms": [{"type": "channel_error", "channel": "1", "message": "no response"}, {"type": "storage_full"}]}`}
+	calnexAPI, stop := d.start()
+	defer stop()
+
+	al, err := calnexAPI.FetchAlarms()
+	require.NoError(t, err)
+	require.Len(t, al, 2)
+	require.Equal(t, AlarmChannelError, al[0].Type)
+	require.Equal(t, ChannelONE, *al[0].Channel)
+	require.Equal(t, "no response", al[0].Message)
+	require.Equal(t, AlarmStorageFull, al[1].Type)
+	require.Nil(t, al[1].Channel)
+}
+
+func TestFetchAlarmsFromStatus(t *testing.T) {
+	d := &alarmDevice{}
+	calnexAPI, stop := d.start()
+	defer stop()
+
+	al, err := calnexAPI.FetchAlarms()
+	require.NoError(t, err)
+	require.Equal(t, []*Alarm{{Type: AlarmReferenceLost, Message: "reference is not ready"}}, al)
+
+	d.referenceReady = true
+	al, err = calnexAPI.FetchAlarms()
+	require.NoError(t, err)
+	require.Empty(t, al)
+}
+
+func TestAlarmPoller(t *testing.T) {
+	d := &alarmDevice{alarms: `{"alarms": []}`}
+	calnexAPI, stop := d.start()
+	defer stop()
+
+	var events []*AlarmEvent
+	p := calnexAPI.NewAlarmPoller(func(e *AlarmEvent) { events = append(events, e) })
+	require.NoError(t, p.Poll())
+	require.Empty(t, events)
+
+	d.alarms = `{"alarms": [{"type": "channel_error", "channel": "1"}, {"type": "channel_error", "channel": "2"}]}`
+	require.NoError(t, p.Poll())
+	require.Len(t, events, 2)
+	require.True(t, events[0].Raised)
+	require.Equal(t, calnexAPI.source, events[0].Source)
+	require.Equal(t, ChannelTWO, *events[1].Alarm.Channel)
+
+	// same alarms are not reported again
+	require.NoError(t, p.Poll())
+	require.Len(t, events, 2)
+
+	d.alarms = `{"alarms": [{"type": "channel_error", "channel": "2"}, {"type": "reference_lost"}]}`
+	require.NoError(t, p.Poll())
+	require.Len(t, events, 4)
+	require.True(t, events[2].Raised)
+	require.Equal(t, AlarmReferenceLost, events[2].Alarm.Type)
+	require.False(t, events[3].Raised)
+	require.Equal(t, ChannelONE, *events[3].Alarm.Channel)
+	require.Len(t, p.Active(), 2)
+
+	d.alarms = "{"
+	require.Error(t, p.Poll())
+	require.Len(t, events, 4)
+}
+
+func TestAlarmPollerRun(t *testing.T) {
+	d := &alarmDevice{alarms: `{"alarms": [{"type": "storage_full"}]}`}
+	calnexAPI, stop := d.start()
+	defer stop()
+
+	raised := make(chan *AlarmEvent, 1)
+	p := calnexAPI.NewAlarmPoller(func(e *AlarmEvent) { raised <- e })
+	p.Interval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-raised
+		cancel()
+	}()
+	require.ErrorIs(t, p.Run(ctx), context.Canceled)
+}
@@ -18,7 +18,7 @@ package api
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
@@ -32,13 +32,23 @@ import (
 	"strings"
 	"time"
 
+	"github.com/facebook/time/dialer"
 	"github.com/go-ini/ini"
 )
 
 // API is struct for accessing calnex API
 type API struct {
 	Client *http.Client
-	source string
+	// PollInterval is how often asynchronous operations are checked
+	PollInterval time.Duration
+	// WriteInterval is the minimum time between writes to the device, so it doesn't lock up
+	WriteInterval time.Duration
+	// BusyCheck makes writes fail with ErrDeviceBusy unless the device is ready
+	BusyCheck bool
+	// CloseFirmwareConns closes connections of firmware upload and version requests
+	CloseFirmwareConns bool
+	source             string
+	settings           *settingsCache
 }
 
 // Status is a struct representing Calnex status JSON response
@@ -232,6 +242,8 @@ const (
 	// measureURL is a base URL for to the measurement API
 	measureURL = "https://%s/api/get/measure/%s/ptp_synce/%s/%s"
 	dataURL    = "https://%s/api/getdata?channel=%s&datatype=%s&reset=true"
+	// dataRangeURL returns samples within [start, end) of the device clock in unix seconds without resetting the read pointer
+	dataRangeURL = "https://%s/api/getdata?channel=%s&datatype=%s&reset=false&start=%d&end=%d"
 
 	startMeasure = "https://%s/api/startmeasurement"
 	stopMeasure  = "https://%s/api/stopmeasurement"
@@ -273,14 +285,26 @@ func parseResponse(response string) (string, error) {
 
 // NewAPI returns an pointer of API struct with default values.
 func NewAPI(source string, insecureTLS bool) *API {
-	return &API{
+	a := &API{
 		Client: &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureTLS},
-			},
-			Timeout: 2 * time.Minute,
+			Transport: newTransport(insecureTLS),
+			Timeout:   2 * time.Minute,
 		},
-		source: source,
+		PollInterval:  DefaultPollInterval,
+		WriteInterval: DefaultWriteInterval,
+		source:        source,
+		settings:      &settingsCache{},
+	}
+	a.SetConnOptions(DefaultConnOptions)
+	return a
+}
+
+// SetDialer makes API reach the device via the dialer, for example SOCKS5 proxy
+func (a *API) SetDialer(d dialer.Dialer) {
+	if t := a.transport(); t != nil {
+		t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			return d.Dial(network, address)
+		}
 	}
 }
 
@@ -288,14 +312,35 @@ func NewAPI(source string, insecureTLS bool) *API {
 // it returns list of CSV lines which is []string
 func (a *API) FetchCsv(channel Channel) ([][]string, error) {
 	url := fmt.Sprintf(dataURL, a.source, channel, channelDatatypeMap[channel])
+	return a.fetchCsv(url, channel)
+}
+
+// FetchCsvRange returns CSV lines of the channel with samples taken within [start, end) of the device clock.
+// Unlike FetchCsv it doesn't reset the read pointer, so the same range can be fetched again
+func (a *API) FetchCsvRange(channel Channel, start, end time.Time) ([][]string, error) {
+	url := fmt.Sprintf(dataRangeURL, a.source, channel, channelDatatypeMap[channel], start.Unix(), end.Unix())
+	return a.fetchCsv(url, channel)
+}
+
+func (a *API) fetchCsv(url string, channel Channel) ([][]string, error) {
+	var res [][]string
+	err := a.exclusive(func() error {
+		var err error
+		res, err = a.readCsv(url, channel)
+		return err
+	})
+	return res, err
+}
+
+func (a *API) readCsv(url string, channel Channel) ([][]string, error) {
 	resp, err := a.Client.Get(url)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(http.StatusText(resp.StatusCode))
+		return nil, statusError(resp)
 	}
 
 	var res [][]string
@@ -315,6 +360,30 @@ func (a *API) FetchCsv(channel Channel) ([][]string, error) {
 	return res, nil
 }
 
+// FetchClockOffset returns offset of the device clock from the local one, estimated from the HTTP Date header.
+// Precision is about a second, which is enough to align export windows
+func (a *API) FetchClockOffset() (time.Duration, error) {
+	url := fmt.Sprintf(versionURL, a.source)
+	sent := time.Now()
+	resp, err := a.Client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, statusError(resp)
+	}
+	device, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse device time: %w", err)
+	}
+	// Date header has a second resolution, so compare against the middle of the second
+	local := sent.Add(received.Sub(sent) / 2)
+	return device.Add(500 * time.Millisecond).Sub(local), nil
+}
+
 // FetchChannelProbe returns monitored protocol of the channel
 func (a *API) FetchChannelProbe(channel Channel) (*Probe, error) {
 	url := fmt.Sprintf(measureURL, a.source, channel.CalnexAPI(), "mode", "probe_type")
@@ -322,10 +391,10 @@ func (a *API) FetchChannelProbe(channel Channel) (*Probe, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(http.StatusText(resp.StatusCode))
+		return nil, statusError(resp)
 	}
 
 	b, err := ioutil.ReadAll(resp.Body)
@@ -348,10 +417,10 @@ func (a *API) FetchChannelTargetIP(channel Channel, probe Probe) (string, error)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return "", errors.New(http.StatusText(resp.StatusCode))
+		return "", statusError(resp)
 	}
 
 	b, err := ioutil.ReadAll(resp.Body)
@@ -365,14 +434,13 @@ func (a *API) FetchChannelTargetIP(channel Channel, probe Probe) (string, error)
 // FetchUsedChannels returns list of channels in use
 func (a *API) FetchUsedChannels() ([]Channel, error) {
 	channels := []Channel{}
-	f, err := a.FetchSettings()
+	d, err := a.FetchDeviceSettings()
 	if err != nil {
 		return channels, err
 	}
 
-	for ch := range ChannelCalnexToString {
-		chStatus := f.Section("measure").Key(fmt.Sprintf("%s\\used", ch.CalnexAPI())).String()
-		if chStatus == "Yes" {
+	for ch, c := range d.Channels {
+		if c.Used {
 			channels = append(channels, ch)
 		}
 	}
@@ -394,20 +462,11 @@ func (a *API) FetchChannelTargetName(channel Channel, probe Probe) (string, erro
 	return hostnames[0], nil
 }
 
-// FetchSettings returns the calnex settings
+// FetchSettings returns the calnex settings.
+// Settings are downloaded again only if they changed on the device, see FetchSettingsIfChanged
 func (a *API) FetchSettings() (*ini.File, error) {
-	url := fmt.Sprintf(getSettingsURL, a.source)
-	resp, err := a.Client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(http.StatusText(resp.StatusCode))
-	}
-
-	return ini.Load(resp.Body)
+	f, _, err := a.FetchSettingsIfChanged()
+	return f, err
 }
 
 // FetchStatus returns the calnex status
@@ -417,10 +476,10 @@ func (a *API) FetchStatus() (*Status, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(http.StatusText(resp.StatusCode))
+		return nil, statusError(resp)
 	}
 
 	s := &Status{}
@@ -438,10 +497,10 @@ func (a *API) FetchProblemReport(dir string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return "", errors.New(http.StatusText(resp.StatusCode))
+		return "", statusError(resp)
 	}
 
 	// calnex_problem_report_2021-12-07_10-42-26.tar
@@ -463,14 +522,20 @@ func (a *API) FetchProblemReport(dir string) (string, error) {
 // FetchVersion returns current Firmware Version
 func (a *API) FetchVersion() (*Version, error) {
 	url := fmt.Sprintf(versionURL, a.source)
-	resp, err := a.Client.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	// version is polled while the device reboots into the new firmware
+	req.Close = a.CloseFirmwareConns
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(http.StatusText(resp.StatusCode))
+		return nil, statusError(resp)
 	}
 
 	v := &Version{}
@@ -497,7 +562,7 @@ func (a *API) PushVersion(path string) (*Result, error) {
 		return nil, err
 	}
 
-	r, err := a.post(url, buf)
+	r, err := a.post(url, buf, a.CloseFirmwareConns)
 	return r, err
 }
 
@@ -509,45 +574,69 @@ func (a *API) PushSettings(f *ini.File) error {
 	}
 	url := fmt.Sprintf(setSettingsURL, a.source)
 
-	_, err = a.post(url, buf)
+	defer a.settings.reset()
+	_, err = a.post(url, buf, false)
 	return err
 }
 
-func (a *API) post(url string, content *bytes.Buffer) (*Result, error) {
+// post sends content to the url. Connection is closed after the request if close is set
+func (a *API) post(url string, content *bytes.Buffer, close bool) (*Result, error) {
+	var r *Result
+	err := a.write(func() error {
+		var err error
+		r, err = a.doPost(url, content, close)
+		return err
+	})
+	return r, err
+}
+
+func (a *API) doPost(url string, content *bytes.Buffer, close bool) (*Result, error) {
 	// content must be a bytes.Buffer or anything which supports .Len()
 	// Otherwise Content-Length will not be set.
-	resp, err := a.Client.Post(url, "application/x-www-form-urlencoded", content)
+	req, err := http.NewRequest(http.MethodPost, url, content)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	r := &Result{}
-	if err = json.NewDecoder(resp.Body).Decode(r); err != nil {
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Close = close
+	resp, err := a.Client.Do(req)
+	if err != nil {
 		return nil, err
 	}
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return r, errors.New(http.StatusText(resp.StatusCode))
+		return nil, statusError(resp)
+	}
+
+	r := &Result{}
+	if err = json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return nil, err
 	}
 
 	if !r.Result {
-		return nil, errors.New(r.Message)
+		return nil, resultError(resp.StatusCode, r)
 	}
 
 	return r, nil
 }
 
 func (a *API) get(path string) error {
+	return a.write(func() error {
+		return a.doGet(path)
+	})
+}
+
+func (a *API) doGet(path string) error {
 	url := fmt.Sprintf(path, a.source)
 	resp, err := a.Client.Get(url)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.New(http.StatusText(resp.StatusCode))
+		return statusError(resp)
 	}
 
 	r := &Result{}
@@ -556,7 +645,7 @@ func (a *API) get(path string) error {
 	}
 
 	if !r.Result {
-		return errors.New(r.Message)
+		return resultError(resp.StatusCode, r)
 	}
 
 	return nil
@@ -22,12 +22,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-ini/ini"
 	"github.com/stretchr/testify/require"
@@ -118,17 +120,48 @@ func TestTLSSetting(t *testing.T) {
 	calnexAPI := NewAPI("localhost", false)
 	// Never ever ever allow insucure over https
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: false},
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     DefaultIdleConnTimeout,
 	}
 	require.Equal(t, transport, calnexAPI.Client.Transport)
 
 	calnexAPI = NewAPI("localhost", true)
 	transport = &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     DefaultIdleConnTimeout,
 	}
 	require.Equal(t, transport, calnexAPI.Client.Transport)
 }
 
+type countingDialer struct {
+	dials int
+}
+
+func (d *countingDialer) Dial(network, address string) (net.Conn, error) {
+	d.dials++
+	return net.Dial(network, address)
+}
+
+func TestSetDialer(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		fmt.Fprintln(w, "1607961193.773740,-000.000000250501")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	d := &countingDialer{}
+	calnexAPI.SetDialer(d)
+	_, err := calnexAPI.FetchCsv(ChannelONE)
+	require.NoError(t, err)
+	require.Equal(t, 1, d.dials)
+}
+
 func TestFetchCsv(t *testing.T) {
 	sampleResp := "1607961193.773740,-000.000000250501"
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
@@ -150,6 +183,44 @@ func TestFetchCsv(t *testing.T) {
 	}
 }
 
+func TestFetchCsvRange(t *testing.T) {
+	sampleResp := "1607961193.773740,-000.000000250501"
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		require.Equal(t, "1", r.URL.Query().Get("channel"))
+		require.Equal(t, "false", r.URL.Query().Get("reset"))
+		require.Equal(t, "1607961000", r.URL.Query().Get("start"))
+		require.Equal(t, "1607964600", r.URL.Query().Get("end"))
+		fmt.Fprintln(w, sampleResp)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	start := time.Unix(1607961000, 0)
+	lines, err := calnexAPI.FetchCsvRange(ChannelONE, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(lines))
+	require.Equal(t, sampleResp, strings.Join(lines[0], ","))
+}
+
+func TestFetchClockOffset(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		fmt.Fprintln(w, "{\"firmware\":\"2.13.1.0.5583D-20210924\"}")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	offset, err := calnexAPI.FetchClockOffset()
+	require.NoError(t, err)
+	require.InDelta(t, time.Hour, offset, float64(2*time.Second))
+}
+
 func TestFetchChannelProtocol_NTP(t *testing.T) {
 	sampleResp := "measure/ch6/ptp_synce/mode/probe_type=2"
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
@@ -404,7 +475,7 @@ func TestPost(t *testing.T) {
 	calnexAPI.Client = ts.Client()
 
 	buf := bytes.NewBuffer(postData)
-	r, err := calnexAPI.post(parsed.String(), buf)
+	r, err := calnexAPI.post(parsed.String(), buf, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, r)
 	require.Equal(t, postData, serverReceived.Bytes())
@@ -420,6 +491,7 @@ func TestGet(t *testing.T) {
 
 	parsed, _ := url.Parse(ts.URL)
 	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.WriteInterval = 0
 	calnexAPI.Client = ts.Client()
 
 	err := calnexAPI.StartMeasure()
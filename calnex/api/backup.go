@@ -0,0 +1,209 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/go-ini/ini"
+)
+
+const (
+	getInstrumentURL = "https://%s/api/instrument/information"
+
+	backupSettingsFile = "settings.ini"
+	backupStateFile    = "backup.json"
+)
+
+var errNoBackupSettings = errors.New("backup has no settings")
+
+// Backup is a snapshot of the device state
+type Backup struct {
+	Source   string    `json:"source"`
+	Time     time.Time `json:"time"`
+	Settings *ini.File `json:"-"`
+	Version  *Version  `json:"version"`
+	Status   *Status   `json:"status"`
+	// GNSS is nil for devices without GNSS receiver
+	GNSS *GNSS `json:"gnss,omitempty"`
+	// Instrument is model, serial number and installed modules as reported by the device, nil if not supported
+	Instrument json.RawMessage `json:"instrument,omitempty"`
+}
+
+// FetchInstrument returns raw instrument information JSON
+func (a *API) FetchInstrument() (json.RawMessage, error) {
+	url := fmt.Sprintf(getInstrumentURL, a.source)
+	resp, err := a.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var i json.RawMessage
+	if err = json.NewDecoder(resp.Body).Decode(&i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// FetchBackup captures settings, version, status, reference and instrument information of the device
+func (a *API) FetchBackup() (*Backup, error) {
+	b := &Backup{Source: a.source, Time: time.Now()}
+	var err error
+	if b.Settings, err = a.FetchSettings(); err != nil {
+		return nil, fmt.Errorf("fetching settings: %w", err)
+	}
+	if b.Version, err = a.FetchVersion(); err != nil {
+		return nil, fmt.Errorf("fetching version: %w", err)
+	}
+	if b.Status, err = a.FetchStatus(); err != nil {
+		return nil, fmt.Errorf("fetching status: %w", err)
+	}
+	if b.GNSS, err = a.FetchGNSS(); err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("fetching gnss status: %w", err)
+	}
+	if b.Instrument, err = a.FetchInstrument(); err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("fetching instrument information: %w", err)
+	}
+	return b, nil
+}
+
+// SaveBackup captures the device state into timestamped archive in the dir
+func (a *API) SaveBackup(dir string) (string, error) {
+	b, err := a.FetchBackup()
+	if err != nil {
+		return "", err
+	}
+
+	// calnex_backup_calnex01.example.com_2021-12-07_10-42-26.tar.gz
+	backupFileName := path.Join(dir, fmt.Sprintf("calnex_backup_%s_%s.tar.gz", a.source, b.Time.Format("2006-01-02_15-04-05")))
+	backupF, err := os.Create(backupFileName)
+	if err != nil {
+		return "", err
+	}
+	defer backupF.Close()
+
+	if err := b.Write(backupF); err != nil {
+		return "", err
+	}
+	return backupFileName, backupF.Close()
+}
+
+// Restore pushes settings from the backup to the device
+func (a *API) Restore(b *Backup) error {
+	if b.Settings == nil {
+		return errNoBackupSettings
+	}
+	return a.PushSettings(b.Settings)
+}
+
+// Write backup as gzip compressed tar archive
+func (b *Backup) Write(w io.Writer) error {
+	settings, err := ToBuffer(b.Settings)
+	if err != nil {
+		return err
+	}
+	state, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{name: backupStateFile, data: state},
+		{name: backupSettingsFile, data: settings.Bytes()},
+	} {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), ModTime: b.Time}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadBackup reads backup archive created by Write
+func ReadBackup(r io.Reader) (*Backup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	b := &Backup{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Name {
+		case backupStateFile:
+			if err := json.Unmarshal(data, b); err != nil {
+				return nil, err
+			}
+		case backupSettingsFile:
+			if b.Settings, err = ini.Load(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if b.Settings == nil {
+		return nil, errNoBackupSettings
+	}
+	return b, nil
+}
+
+// ReadBackupFile reads backup archive from the path
+func ReadBackupFile(path string) (*Backup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBackup(f)
+}
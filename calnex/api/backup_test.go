@@ -0,0 +1,137 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const backupSettings = "[measure]\nch0\\used=Yes\n"
+
+// backupDevice serves device state. Settings pushed to it are recorded
+type backupDevice struct {
+	gnss       bool
+	instrument bool
+	pushed     string
+}
+
+func (d *backupDevice) start(t *testing.T) (*API, func()) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "getsettings"):
+			fmt.Fprint(w, backupSettings)
+		case strings.Contains(r.URL.Path, "setsettings"):
+			b, _ := ioutil.ReadAll(r.Body)
+			d.pushed = string(b)
+			fmt.Fprintln(w, `{"result": true}`)
+		case strings.Contains(r.URL.Path, "version"):
+			fmt.Fprintln(w, `{"firmware": "2.13.1.0.5583D-20210924"}`)
+		case strings.Contains(r.URL.Path, "getstatus"):
+			fmt.Fprintln(w, `{"referenceReady": true, "modulesReady": true, "measurementActive": true}`)
+		case strings.Contains(r.URL.Path, "getgnssstatus"):
+			if !d.gnss {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprintln(w, `{"locked": true, "satellites": 9}`)
+		case strings.Contains(r.URL.Path, "instrument"):
+			if !d.instrument {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprintln(w, `{"model": "Sentinel", "serial": "42"}`)
+		}
+	}))
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	return calnexAPI, ts.Close
+}
+
+func TestFetchBackup(t *testing.T) {
+	d := &backupDevice{gnss: true, instrument: true}
+	calnexAPI, stop := d.start(t)
+	defer stop()
+
+	b, err := calnexAPI.FetchBackup()
+	require.NoError(t, err)
+	require.Equal(t, "2.13.1.0.5583D-20210924", b.Version.Firmware)
+	require.Equal(t, &Status{ReferenceReady: true, ModulesReady: true, MeasurementActive: true}, b.Status)
+	require.Equal(t, &GNSS{Locked: true, Satellites: 9}, b.GNSS)
+	require.JSONEq(t, `{"model": "Sentinel", "serial": "42"}`, string(b.Instrument))
+	require.Equal(t, "Yes", b.Settings.Section("measure").Key("ch0\\used").String())
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, b.Write(buf))
+	restored, err := ReadBackup(buf)
+	require.NoError(t, err)
+	require.Equal(t, b.Source, restored.Source)
+	require.True(t, b.Time.Equal(restored.Time))
+	require.Equal(t, b.Version, restored.Version)
+	require.Equal(t, b.GNSS, restored.GNSS)
+	require.JSONEq(t, string(b.Instrument), string(restored.Instrument))
+
+	require.NoError(t, calnexAPI.Restore(restored))
+	require.Equal(t, backupSettings, d.pushed)
+}
+
+func TestFetchBackupOptional(t *testing.T) {
+	d := &backupDevice{}
+	calnexAPI, stop := d.start(t)
+	defer stop()
+
+	b, err := calnexAPI.FetchBackup()
+	require.NoError(t, err)
+	require.Nil(t, b.GNSS)
+	require.Nil(t, b.Instrument)
+}
+
+func TestSaveBackup(t *testing.T) {
+	d := &backupDevice{}
+	calnexAPI, stop := d.start(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "calnex")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	name, err := calnexAPI.SaveBackup(dir)
+	require.NoError(t, err)
+	require.Contains(t, name, "calnex_backup_"+calnexAPI.source)
+
+	b, err := ReadBackupFile(name)
+	require.NoError(t, err)
+	require.Equal(t, "2.13.1.0.5583D-20210924", b.Version.Firmware)
+}
+
+func TestRestoreNoSettings(t *testing.T) {
+	require.ErrorIs(t, NewAPI("localhost", true).Restore(&Backup{}), errNoBackupSettings)
+
+	buf := &bytes.Buffer{}
+	_, err := ReadBackup(buf)
+	require.Error(t, err)
+}
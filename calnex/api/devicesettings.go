@@ -0,0 +1,381 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-ini/ini"
+)
+
+// MeasureSection is the settings section holding measurement and channel config
+const MeasureSection = "measure"
+
+// Channel setting keys, formatted with channel like "ch6"
+const (
+	usedKey            = "%s\\used"
+	protocolEnabledKey = "%s\\protocol_enabled"
+	synceEnabledKey    = "%s\\synce_enabled"
+	probeTypeKeyFormat = "%s\\ptp_synce\\mode\\probe_type"
+
+	dhcpKey          = "%s\\ptp_synce\\ethernet\\dhcp"
+	ipAddressKey     = "%s\\ptp_synce\\ethernet\\ip_address"
+	ipAddressIPv6Key = "%s\\ptp_synce\\ethernet\\ip_address_ipv6"
+	gatewayKey       = "%s\\ptp_synce\\ethernet\\gateway"
+	gatewayIPv6Key   = "%s\\ptp_synce\\ethernet\\gateway_ipv6"
+	maskKey          = "%s\\ptp_synce\\ethernet\\mask"
+
+	ntpServerIPKey        = "%s\\ptp_synce\\ntp\\server_ip"
+	ntpServerIPv6Key      = "%s\\ptp_synce\\ntp\\server_ip_ipv6"
+	ntpProtocolLevelKey   = "%s\\ptp_synce\\ntp\\protocol_level"
+	ntpPollLogIntervalKey = "%s\\ptp_synce\\ntp\\poll_log_interval"
+
+	ptpMasterIPKey       = "%s\\ptp_synce\\ptp\\master_ip"
+	ptpMasterIPv6Key     = "%s\\ptp_synce\\ptp\\master_ip_ipv6"
+	ptpProtocolLevelKey  = "%s\\ptp_synce\\ptp\\protocol_level"
+	ptpStackModeKey      = "%s\\ptp_synce\\ptp\\stack_mode"
+	ptpLogAnnounceIntKey = "%s\\ptp_synce\\ptp\\log_announce_int"
+	ptpLogDelayReqIntKey = "%s\\ptp_synce\\ptp\\log_delay_req_int"
+	ptpLogSyncIntKey     = "%s\\ptp_synce\\ptp\\log_sync_int"
+	ptpDomainKey         = "%s\\ptp_synce\\ptp\\domain"
+	ptpDSCPKey           = "%s\\ptp_synce\\ptp\\dscp"
+)
+
+// TIEModeKey is a setting controlling what is measured on physical channels
+const TIEModeKey = "tie_mode"
+
+// EthernetSettings are network settings of the channel port
+type EthernetSettings struct {
+	DHCP        bool
+	IP          string
+	IPv6        string
+	Gateway     string
+	GatewayIPv6 string
+	Mask        string
+}
+
+// NTPClientSettings are settings of the channel probing NTP server
+type NTPClientSettings struct {
+	ServerIP        string
+	ServerIPv6      string
+	ProtocolLevel   string
+	PollLogInterval string
+	Metric          NTPMetric
+}
+
+// PTPClientSettings are settings of the channel probing PTP master
+type PTPClientSettings struct {
+	MasterIP       string
+	MasterIPv6     string
+	ProtocolLevel  string
+	StackMode      string
+	LogAnnounceInt string
+	LogDelayReqInt string
+	LogSyncInt     string
+	Domain         int
+	DSCP           int
+}
+
+// ChannelSettings are settings of a single channel
+type ChannelSettings struct {
+	Used            bool
+	ProtocolEnabled bool
+	SynceEnabled    bool
+	// ProbeType is Calnex name of the channel mode, like "NTP client" or "PTP master"
+	ProbeType string
+	Ethernet  EthernetSettings
+	NTP       NTPClientSettings
+	PTP       PTPClientSettings
+}
+
+// DeviceSettings is a typed representation of the device settings tree.
+// Only changed values and assigned keys missing on the device are written back, keys unknown to the model are left intact
+// and can be accessed via Raw
+type DeviceSettings struct {
+	Continuous    bool
+	MeasureTime   MeasureDuration
+	TIEMode       string
+	Channels      map[Channel]*ChannelSettings
+	Notifications *Notifications
+
+	raw *ini.File
+	// device holds values of the device per section, to tell what was changed
+	device map[string]map[string]string
+	// present holds keys existing on the device per section
+	present map[string]map[string]bool
+	// assigned holds keys which are written even if missing on the device with zero values
+	assigned map[string]bool
+}
+
+// field binds a setting key to the value of the typed model
+type field struct {
+	key string
+	ptr interface{}
+	get func() string
+	set func(value string) error
+}
+
+func stringField(key string, v *string) field {
+	return field{
+		key: key,
+		ptr: v,
+		get: func() string { return *v },
+		set: func(value string) error { *v = value; return nil },
+	}
+}
+
+func boolField(key, on, off string, v *bool) field {
+	return field{
+		key: key,
+		ptr: v,
+		get: func() string {
+			if *v {
+				return on
+			}
+			return off
+		},
+		set: func(value string) error { *v = value == on; return nil },
+	}
+}
+
+func intField(key string, v *int) field {
+	return field{
+		key: key,
+		ptr: v,
+		get: func() string { return strconv.Itoa(*v) },
+		set: func(value string) (err error) {
+			*v, err = strconv.Atoi(value)
+			return err
+		},
+	}
+}
+
+func (c *ChannelSettings) fields(ch Channel) []field {
+	k := func(format string) string {
+		return fmt.Sprintf(format, ch.CalnexAPI())
+	}
+	return []field{
+		boolField(k(usedKey), YES, NO, &c.Used),
+		boolField(k(protocolEnabledKey), ON, OFF, &c.ProtocolEnabled),
+		boolField(k(synceEnabledKey), ON, OFF, &c.SynceEnabled),
+		stringField(k(probeTypeKeyFormat), &c.ProbeType),
+
+		boolField(k(dhcpKey), ON, OFF, &c.Ethernet.DHCP),
+		stringField(k(ipAddressKey), &c.Ethernet.IP),
+		stringField(k(ipAddressIPv6Key), &c.Ethernet.IPv6),
+		stringField(k(gatewayKey), &c.Ethernet.Gateway),
+		stringField(k(gatewayIPv6Key), &c.Ethernet.GatewayIPv6),
+		stringField(k(maskKey), &c.Ethernet.Mask),
+
+		stringField(k(ntpServerIPKey), &c.NTP.ServerIP),
+		stringField(k(ntpServerIPv6Key), &c.NTP.ServerIPv6),
+		stringField(k(ntpProtocolLevelKey), &c.NTP.ProtocolLevel),
+		stringField(k(ntpPollLogIntervalKey), &c.NTP.PollLogInterval),
+		{
+			key: k(NTPMetricKey),
+			ptr: &c.NTP.Metric,
+			get: func() string { return c.NTP.Metric.Value() },
+			set: func(value string) error {
+				c.NTP.Metric = NTPMetricOffset
+				if value == ON {
+					c.NTP.Metric = NTPMetricTIE
+				}
+				return nil
+			},
+		},
+
+		stringField(k(ptpMasterIPKey), &c.PTP.MasterIP),
+		stringField(k(ptpMasterIPv6Key), &c.PTP.MasterIPv6),
+		stringField(k(ptpProtocolLevelKey), &c.PTP.ProtocolLevel),
+		stringField(k(ptpStackModeKey), &c.PTP.StackMode),
+		stringField(k(ptpLogAnnounceIntKey), &c.PTP.LogAnnounceInt),
+		stringField(k(ptpLogDelayReqIntKey), &c.PTP.LogDelayReqInt),
+		stringField(k(ptpLogSyncIntKey), &c.PTP.LogSyncInt),
+		intField(k(ptpDomainKey), &c.PTP.Domain),
+		intField(k(ptpDSCPKey), &c.PTP.DSCP),
+	}
+}
+
+// channels returns configured channels in a stable order
+func (d *DeviceSettings) channels() []Channel {
+	channels := make([]Channel, 0, len(d.Channels))
+	for ch := range d.Channels {
+		channels = append(channels, ch)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+	return channels
+}
+
+func (d *DeviceSettings) measureFields() []field {
+	fields := []field{
+		boolField(ContinuousKey, ON, OFF, &d.Continuous),
+		{
+			key: MeasureTimeKey,
+			ptr: &d.MeasureTime,
+			get: func() string {
+				if d.MeasureTime == 0 {
+					return ""
+				}
+				return d.MeasureTime.String()
+			},
+			set: func(value string) (err error) {
+				if value == "" {
+					d.MeasureTime = 0
+					return nil
+				}
+				d.MeasureTime, err = ParseMeasureDuration(value)
+				return err
+			},
+		},
+		stringField(TIEModeKey, &d.TIEMode),
+	}
+	for _, ch := range d.channels() {
+		if c := d.Channels[ch]; c != nil {
+			fields = append(fields, c.fields(ch)...)
+		}
+	}
+	return fields
+}
+
+// Settings returns all settings of the section known to the model
+func (d *DeviceSettings) Settings(section string) []Setting {
+	switch section {
+	case MeasureSection:
+		fields := d.measureFields()
+		res := make([]Setting, 0, len(fields))
+		for _, f := range fields {
+			res = append(res, Setting{Key: f.key, Value: f.get()})
+		}
+		return res
+	case NotificationsSection:
+		if d.Notifications == nil {
+			return nil
+		}
+		return d.Notifications.Settings()
+	}
+	return nil
+}
+
+// sections returns sections of the typed model
+func sections() []string {
+	return []string{MeasureSection, NotificationsSection}
+}
+
+// snapshot remembers current values and keys of the underlying settings as the device ones
+func (d *DeviceSettings) snapshot() {
+	d.device = map[string]map[string]string{}
+	d.present = map[string]map[string]bool{}
+	d.assigned = map[string]bool{}
+	for _, section := range sections() {
+		values := map[string]string{}
+		present := map[string]bool{}
+		raw, err := d.raw.GetSection(section)
+		for _, s := range d.Settings(section) {
+			values[s.Key] = s.Value
+			present[s.Key] = err == nil && raw.HasKey(s.Key)
+		}
+		d.device[section] = values
+		d.present[section] = present
+	}
+}
+
+// Assign marks model fields, like &d.Channels[ChannelONE].SynceEnabled, as set by the caller.
+// Assigned fields are written even if the key is missing on the device and the value is the zero one
+func (d *DeviceSettings) Assign(values ...interface{}) {
+	for _, f := range d.measureFields() {
+		for _, v := range values {
+			if f.ptr == v {
+				d.assigned[f.key] = true
+			}
+		}
+	}
+}
+
+// Changes returns settings of the section which differ from the device ones or are assigned and missing on the device
+func (d *DeviceSettings) Changes(section string) []Setting {
+	res := []Setting{}
+	for _, s := range d.Settings(section) {
+		if d.device[section][s.Key] == s.Value && (d.present[section][s.Key] || !d.assigned[s.Key]) {
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// Raw returns underlying settings for keys the typed model doesn't cover.
+// Typed changes are only written there by File
+func (d *DeviceSettings) Raw() *ini.File {
+	return d.raw
+}
+
+// File writes typed changes to the underlying settings and returns them, ready to be pushed to the device
+func (d *DeviceSettings) File() *ini.File {
+	for _, section := range sections() {
+		s := d.raw.Section(section)
+		for _, setting := range d.Changes(section) {
+			s.Key(setting.Key).SetValue(setting.Value)
+		}
+	}
+	d.snapshot()
+	return d.raw
+}
+
+// ParseDeviceSettings returns typed representation of the device settings.
+// Missing keys are left at zero values and are only written if changed or assigned
+func ParseDeviceSettings(f *ini.File) (*DeviceSettings, error) {
+	d := &DeviceSettings{
+		Channels:      map[Channel]*ChannelSettings{},
+		Notifications: &Notifications{},
+		raw:           f,
+	}
+	for ch := range ChannelCalnexToString {
+		d.Channels[ch] = &ChannelSettings{}
+	}
+	if s, err := f.GetSection(MeasureSection); err == nil {
+		for _, field := range d.measureFields() {
+			k, err := s.GetKey(field.key)
+			if err != nil {
+				continue
+			}
+			if err := field.set(k.Value()); err != nil {
+				return nil, fmt.Errorf("parsing %s: %w", field.key, err)
+			}
+		}
+	}
+	if s, err := f.GetSection(NotificationsSection); err == nil {
+		d.Notifications = NotificationsFromSettings(s)
+	}
+	d.snapshot()
+	return d, nil
+}
+
+// FetchDeviceSettings returns typed representation of the device settings
+func (a *API) FetchDeviceSettings() (*DeviceSettings, error) {
+	f, err := a.FetchSettings()
+	if err != nil {
+		return nil, err
+	}
+	return ParseDeviceSettings(f)
+}
+
+// PushDeviceSettings pushes the settings with typed changes to the device
+func (a *API) PushDeviceSettings(d *DeviceSettings) error {
+	return a.PushSettings(d.File())
+}
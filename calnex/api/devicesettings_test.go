@@ -0,0 +1,199 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/stretchr/testify/require"
+)
+
+const deviceSettings = `[measure]
+continuous=On
+meas_time=1 days 1 hours
+tie_mode=TIE
+ch6\used=Yes
+ch6\protocol_enabled=On
+ch6\ptp_synce\mode\probe_type=NTP client
+ch6\ptp_synce\ntp\server_ip=fd00::d
+ch6\ptp_synce\ntp\normalize_delays=On
+ch6\ptp_synce\ptp\domain=24
+ch6\ptp_synce\unknown=42
+ch7\used=No
+[notifications]
+snmp\enabled=On
+snmp\community=lab
+snmp\trap_target_1=192.0.2.1
+smtp\enabled=Off
+[gnss]
+antenna_delay=30
+`
+
+func TestParseDeviceSettings(t *testing.T) {
+	f, err := ini.Load([]byte(deviceSettings))
+	require.NoError(t, err)
+	d, err := ParseDeviceSettings(f)
+	require.NoError(t, err)
+
+	require.True(t, d.Continuous)
+	require.Equal(t, MeasureDuration(25*time.Hour), d.MeasureTime)
+	require.Equal(t, "TIE", d.TIEMode)
+	require.Len(t, d.Channels, len(ChannelCalnexToString))
+	ch := d.Channels[ChannelONE]
+	require.True(t, ch.Used)
+	require.True(t, ch.ProtocolEnabled)
+	require.Equal(t, "NTP client", ch.ProbeType)
+	require.Equal(t, "fd00::d", ch.NTP.ServerIP)
+	require.Equal(t, NTPMetricTIE, ch.NTP.Metric)
+	require.Equal(t, 24, ch.PTP.Domain)
+	require.False(t, d.Channels[ChannelTWO].Used)
+	require.Equal(t, &Notifications{SNMP: &SNMPNotifications{Community: "lab", Targets: []string{"192.0.2.1"}}}, d.Notifications)
+
+	// nothing is changed by parsing
+	require.Empty(t, d.Changes(MeasureSection))
+	require.Empty(t, d.Changes(NotificationsSection))
+	buf, err := ToBuffer(d.File())
+	require.NoError(t, err)
+	require.Equal(t, deviceSettings, buf.String())
+}
+
+func TestParseDeviceSettingsError(t *testing.T) {
+	for _, c := range []string{
+		"[measure]\nmeas_time=forever\n",
+		"[measure]\nch6\\ptp_synce\\ptp\\domain=zero\n",
+	} {
+		f, err := ini.Load([]byte(c))
+		require.NoError(t, err)
+		_, err = ParseDeviceSettings(f)
+		require.Error(t, err)
+	}
+}
+
+func TestDeviceSettingsChanges(t *testing.T) {
+	f, err := ini.Load([]byte(deviceSettings))
+	require.NoError(t, err)
+	d, err := ParseDeviceSettings(f)
+	require.NoError(t, err)
+
+	d.MeasureTime = MeasureDuration(time.Hour)
+	d.Channels[ChannelONE].NTP.Metric = NTPMetricOffset
+	d.Channels[ChannelONE].PTP.Domain = 0
+	d.Channels[ChannelTWO].Used = true
+	d.Channels[ChannelTWO].Ethernet.IPv6 = "fd00::2"
+	// set back to the device value
+	d.TIEMode = "TIE + 1 PPS TE"
+	d.TIEMode = "TIE"
+	d.Notifications.SNMP = nil
+	// escape hatch for keys unknown to the model
+	d.Raw().Section("gnss").Key("antenna_delay").SetValue("35")
+
+	require.Equal(t, []Setting{
+		{Key: MeasureTimeKey, Value: "1 hours"},
+		{Key: "ch6\\ptp_synce\\ntp\\normalize_delays", Value: OFF},
+		{Key: "ch6\\ptp_synce\\ptp\\domain", Value: "0"},
+		{Key: "ch7\\used", Value: YES},
+		{Key: "ch7\\ptp_synce\\ethernet\\ip_address_ipv6", Value: "fd00::2"},
+	}, d.Changes(MeasureSection))
+	require.Equal(t, []Setting{
+		{Key: "snmp\\enabled", Value: OFF},
+		{Key: "snmp\\trap_target_1", Value: ""},
+	}, d.Changes(NotificationsSection))
+
+	buf, err := ToBuffer(d.File())
+	require.NoError(t, err)
+	require.Equal(t, `[measure]
+continuous=On
+meas_time=1 hours
+tie_mode=TIE
+ch6\used=Yes
+ch6\protocol_enabled=On
+ch6\ptp_synce\mode\probe_type=NTP client
+ch6\ptp_synce\ntp\server_ip=fd00::d
+ch6\ptp_synce\ntp\normalize_delays=Off
+ch6\ptp_synce\ptp\domain=0
+ch6\ptp_synce\unknown=42
+ch7\used=Yes
+ch7\ptp_synce\ethernet\ip_address_ipv6=fd00::2
+[notifications]
+snmp\enabled=Off
+snmp\community=lab
+snmp\trap_target_1=
+smtp\enabled=Off
+[gnss]
+antenna_delay=35
+`, buf.String())
+
+	// written changes become the device values
+	require.Empty(t, d.Changes(MeasureSection))
+}
+
+func TestDeviceSettingsAssign(t *testing.T) {
+	f, err := ini.Load([]byte(deviceSettings))
+	require.NoError(t, err)
+	d, err := ParseDeviceSettings(f)
+	require.NoError(t, err)
+
+	// zero values missing on the device are only written when assigned
+	ch := d.Channels[ChannelTWO]
+	ch.SynceEnabled = false
+	ch.PTP.DSCP = 0
+	require.Empty(t, d.Changes(MeasureSection))
+
+	d.Assign(&ch.SynceEnabled, &ch.PTP.DSCP, &ch.Used)
+	require.Equal(t, []Setting{
+		{Key: "ch7\\synce_enabled", Value: OFF},
+		{Key: "ch7\\ptp_synce\\ptp\\dscp", Value: "0"},
+	}, d.Changes(MeasureSection))
+
+	d.File()
+	require.Empty(t, d.Changes(MeasureSection))
+}
+
+func TestPushDeviceSettings(t *testing.T) {
+	var pushed string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		switch r.URL.Path {
+		case "/api/getsettings":
+			fmt.Fprintln(w, "[measure]\nch6\\used=No\nch7\\used=Yes")
+		case "/api/setsettings":
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			pushed = string(b)
+			fmt.Fprintln(w, "{\n\"result\": true\n}")
+		}
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	d, err := calnexAPI.FetchDeviceSettings()
+	require.NoError(t, err)
+	require.False(t, d.Channels[ChannelONE].Used)
+	d.Channels[ChannelONE].Used = true
+	require.NoError(t, calnexAPI.PushDeviceSettings(d))
+	require.Contains(t, pushed, "ch6\\used=Yes")
+}
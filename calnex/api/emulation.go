@@ -0,0 +1,175 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/go-ini/ini"
+)
+
+// Calnex names of channel modes where the device acts as a time source
+const (
+	NTPServerName = "NTP server"
+	PTPMasterName = "PTP master"
+)
+
+var errBadEmulation = errors.New("emulation config doesn't match the protocol")
+
+// NTPServer is a config of the device acting as NTP server on a channel
+type NTPServer struct {
+	Stratum int    `json:"stratum"`
+	RefID   string `json:"refid"`
+	// Leap is the leap indicator advertised to clients
+	Leap int `json:"leap"`
+}
+
+// PTPMaster is a config of the device acting as PTP master on a channel
+type PTPMaster struct {
+	Priority1     int `json:"priority1"`
+	Priority2     int `json:"priority2"`
+	ClockClass    int `json:"clock_class"`
+	ClockAccuracy int `json:"clock_accuracy"`
+	UTCOffset     int `json:"utc_offset"`
+}
+
+// Emulation is a config of the channel where the device acts as NTP server or PTP master
+// for impairment testing instead of probing a target
+type Emulation struct {
+	Probe Probe      `json:"probe"`
+	NTP   *NTPServer `json:"ntp,omitempty"`
+	PTP   *PTPMaster `json:"ptp,omitempty"`
+}
+
+// Setting is a single Calnex setting
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// CalnexName returns Calnex name of the channel mode like "NTP server" or "PTP master"
+func (e *Emulation) CalnexName() string {
+	if e.Probe == ProbePTP {
+		return PTPMasterName
+	}
+	return NTPServerName
+}
+
+// Validate checks config is present for the protocol
+func (e *Emulation) Validate() error {
+	switch e.Probe {
+	case ProbeNTP:
+		if e.NTP == nil || e.PTP != nil {
+			return errBadEmulation
+		}
+	case ProbePTP:
+		if e.PTP == nil || e.NTP != nil {
+			return errBadEmulation
+		}
+	default:
+		return errBadProbe
+	}
+	return nil
+}
+
+func ntpServerKey(ch Channel, name string) string {
+	return fmt.Sprintf("%s\\ptp_synce\\ntp\\server_%s", ch.CalnexAPI(), name)
+}
+
+func ptpMasterKey(ch Channel, name string) string {
+	return fmt.Sprintf("%s\\ptp_synce\\ptp\\master_%s", ch.CalnexAPI(), name)
+}
+
+func probeTypeKey(ch Channel) string {
+	return fmt.Sprintf(probeTypeKeyFormat, ch.CalnexAPI())
+}
+
+// Settings returns Calnex settings of the channel in emulation mode
+func (e *Emulation) Settings(ch Channel) []Setting {
+	res := []Setting{{Key: probeTypeKey(ch), Value: e.CalnexName()}}
+	if e.NTP != nil {
+		res = append(res,
+			Setting{Key: ntpServerKey(ch, "stratum"), Value: strconv.Itoa(e.NTP.Stratum)},
+			Setting{Key: ntpServerKey(ch, "reference_id"), Value: e.NTP.RefID},
+			Setting{Key: ntpServerKey(ch, "leap_indicator"), Value: strconv.Itoa(e.NTP.Leap)},
+		)
+	}
+	if e.PTP != nil {
+		res = append(res,
+			Setting{Key: ptpMasterKey(ch, "priority1"), Value: strconv.Itoa(e.PTP.Priority1)},
+			Setting{Key: ptpMasterKey(ch, "priority2"), Value: strconv.Itoa(e.PTP.Priority2)},
+			Setting{Key: ptpMasterKey(ch, "clock_class"), Value: strconv.Itoa(e.PTP.ClockClass)},
+			Setting{Key: ptpMasterKey(ch, "clock_accuracy"), Value: strconv.Itoa(e.PTP.ClockAccuracy)},
+			Setting{Key: ptpMasterKey(ch, "utc_offset"), Value: strconv.Itoa(e.PTP.UTCOffset)},
+		)
+	}
+	return res
+}
+
+func intKey(s *ini.Section, key string) (int, error) {
+	v := keyValue(s, key)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", key, err)
+	}
+	return i, nil
+}
+
+// EmulationFromSettings returns emulation config of the channel. Nil if the channel probes a target
+func EmulationFromSettings(s *ini.Section, ch Channel) (*Emulation, error) {
+	var err error
+	switch keyValue(s, probeTypeKey(ch)) {
+	case NTPServerName:
+		n := &NTPServer{RefID: keyValue(s, ntpServerKey(ch, "reference_id"))}
+		if n.Stratum, err = intKey(s, ntpServerKey(ch, "stratum")); err != nil {
+			return nil, err
+		}
+		if n.Leap, err = intKey(s, ntpServerKey(ch, "leap_indicator")); err != nil {
+			return nil, err
+		}
+		return &Emulation{Probe: ProbeNTP, NTP: n}, nil
+	case PTPMasterName:
+		p := &PTPMaster{}
+		for key, v := range map[string]*int{
+			"priority1":      &p.Priority1,
+			"priority2":      &p.Priority2,
+			"clock_class":    &p.ClockClass,
+			"clock_accuracy": &p.ClockAccuracy,
+			"utc_offset":     &p.UTCOffset,
+		} {
+			if *v, err = intKey(s, ptpMasterKey(ch, key)); err != nil {
+				return nil, err
+			}
+		}
+		return &Emulation{Probe: ProbePTP, PTP: p}, nil
+	}
+	return nil, nil
+}
+
+// FetchEmulation returns emulation config of the channel. Nil if the channel probes a target
+func (a *API) FetchEmulation(ch Channel) (*Emulation, error) {
+	f, err := a.FetchSettings()
+	if err != nil {
+		return nil, err
+	}
+	return EmulationFromSettings(f.Section("measure"), ch)
+}
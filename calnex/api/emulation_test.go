@@ -0,0 +1,90 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-ini/ini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmulationSettings(t *testing.T) {
+	e := &Emulation{Probe: ProbeNTP, NTP: &NTPServer{Stratum: 1, RefID: "GPS", Leap: 0}}
+	require.NoError(t, e.Validate())
+	require.Equal(t, []Setting{
+		{Key: "ch6\\ptp_synce\\mode\\probe_type", Value: "NTP server"},
+		{Key: "ch6\\ptp_synce\\ntp\\server_stratum", Value: "1"},
+		{Key: "ch6\\ptp_synce\\ntp\\server_reference_id", Value: "GPS"},
+		{Key: "ch6\\ptp_synce\\ntp\\server_leap_indicator", Value: "0"},
+	}, e.Settings(ChannelONE))
+
+	e = &Emulation{Probe: ProbePTP, PTP: &PTPMaster{Priority1: 128, Priority2: 128, ClockClass: 6, ClockAccuracy: 0x21, UTCOffset: 37}}
+	require.NoError(t, e.Validate())
+	require.Equal(t, PTPMasterName, e.CalnexName())
+	require.Len(t, e.Settings(ChannelTWO), 6)
+
+	require.ErrorIs(t, (&Emulation{Probe: ProbePTP, NTP: &NTPServer{}}).Validate(), errBadEmulation)
+	require.ErrorIs(t, (&Emulation{Probe: ProbeNTP}).Validate(), errBadEmulation)
+	require.ErrorIs(t, (&Emulation{Probe: Probe(42)}).Validate(), errBadProbe)
+}
+
+func TestEmulationFromSettings(t *testing.T) {
+	for _, e := range []*Emulation{
+		{Probe: ProbeNTP, NTP: &NTPServer{Stratum: 2, RefID: "CALN", Leap: 1}},
+		{Probe: ProbePTP, PTP: &PTPMaster{Priority1: 1, Priority2: 2, ClockClass: 6, ClockAccuracy: 33, UTCOffset: 37}},
+	} {
+		f := ini.Empty()
+		s := f.Section("measure")
+		for _, setting := range e.Settings(ChannelONE) {
+			s.Key(setting.Key).SetValue(setting.Value)
+		}
+		got, err := EmulationFromSettings(s, ChannelONE)
+		require.NoError(t, err)
+		require.Equal(t, e, got)
+	}
+
+	f, err := ini.Load([]byte("[measure]\nch6\\ptp_synce\\mode\\probe_type=NTP client\n"))
+	require.NoError(t, err)
+	got, err := EmulationFromSettings(f.Section("measure"), ChannelONE)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	f, err = ini.Load([]byte("[measure]\nch6\\ptp_synce\\mode\\probe_type=NTP server\nch6\\ptp_synce\\ntp\\server_stratum=one\n"))
+	require.NoError(t, err)
+	_, err = EmulationFromSettings(f.Section("measure"), ChannelONE)
+	require.Error(t, err)
+}
+
+func TestFetchEmulation(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "[measure]\nch7\\ptp_synce\\mode\\probe_type=NTP server\nch7\\ptp_synce\\ntp\\server_stratum=1\nch7\\ptp_synce\\ntp\\server_reference_id=GPS")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	e, err := calnexAPI.FetchEmulation(ChannelTWO)
+	require.NoError(t, err)
+	require.Equal(t, &Emulation{Probe: ProbeNTP, NTP: &NTPServer{Stratum: 1, RefID: "GPS"}}, e)
+}
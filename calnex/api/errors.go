@@ -0,0 +1,126 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Kinds of errors reported by the device. Use errors.Is to check the kind of returned error
+var (
+	// ErrMeasurementActive means operation is not allowed while measurement is running
+	ErrMeasurementActive = errors.New("measurement is active")
+	// ErrDeviceBusy means device is busy with another operation and the request can be retried later
+	ErrDeviceBusy = errors.New("device is busy")
+	// ErrBadRequest means device rejected the request, see DeviceError message for details
+	ErrBadRequest = errors.New("bad request")
+)
+
+// DeviceError is an error reported by the device via HTTP status or result message
+type DeviceError struct {
+	StatusCode int
+	Message    string
+	kind       error
+}
+
+// Error returns device message if there is one, HTTP status text otherwise
+func (e *DeviceError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+// Unwrap returns the kind of the error, such as ErrDeviceBusy. Nil if it's not recognized
+func (e *DeviceError) Unwrap() error {
+	return e.kind
+}
+
+// measurementActivePhrases are parts of device messages rejecting requests during measurement
+var measurementActivePhrases = []string{
+	"measurement is running",
+	"measurement in progress",
+	"measurement is active",
+	"stop measurement",
+	"while measuring",
+}
+
+// busyPhrases are parts of device messages rejecting requests during other operations
+var busyPhrases = []string{
+	"busy",
+	"in use",
+	"try again",
+	"please wait",
+}
+
+func containsAny(s string, phrases []string) bool {
+	for _, p := range phrases {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorKind maps HTTP status code and device message to one of the error kinds
+func errorKind(statusCode int, message string) error {
+	m := strings.ToLower(message)
+	switch {
+	case containsAny(m, measurementActivePhrases):
+		return ErrMeasurementActive
+	case containsAny(m, busyPhrases),
+		statusCode == http.StatusServiceUnavailable,
+		statusCode == http.StatusTooManyRequests,
+		statusCode == http.StatusConflict:
+		return ErrDeviceBusy
+	case statusCode == http.StatusBadRequest,
+		statusCode == http.StatusOK,
+		statusCode == http.StatusUnprocessableEntity:
+		// a failed result with OK status is a rejected request
+		return ErrBadRequest
+	}
+	return nil
+}
+
+func newDeviceError(statusCode int, message string) *DeviceError {
+	return &DeviceError{
+		StatusCode: statusCode,
+		Message:    message,
+		kind:       errorKind(statusCode, message),
+	}
+}
+
+// resultError returns DeviceError for a failed Result
+func resultError(statusCode int, r *Result) error {
+	return newDeviceError(statusCode, r.Message)
+}
+
+// statusError returns DeviceError for response with non-OK status, using message from the payload if any
+func statusError(resp *http.Response) error {
+	var message string
+	if body, err := ioutil.ReadAll(resp.Body); err == nil {
+		r := &Result{}
+		if json.Unmarshal(body, r) == nil {
+			message = r.Message
+		}
+	}
+	return newDeviceError(resp.StatusCode, message)
+}
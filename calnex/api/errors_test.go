@@ -0,0 +1,84 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorKind(t *testing.T) {
+	require.Equal(t, ErrMeasurementActive, errorKind(http.StatusOK, "Measurement is running, stop it first"))
+	require.Equal(t, ErrDeviceBusy, errorKind(http.StatusOK, "Device busy"))
+	require.Equal(t, ErrDeviceBusy, errorKind(http.StatusServiceUnavailable, ""))
+	require.Equal(t, ErrBadRequest, errorKind(http.StatusOK, "Invalid channel"))
+	require.Equal(t, ErrBadRequest, errorKind(http.StatusBadRequest, ""))
+	require.Nil(t, errorKind(http.StatusNotFound, ""))
+}
+
+func TestDeviceError(t *testing.T) {
+	err := error(newDeviceError(http.StatusBadRequest, "Invalid channel"))
+	require.EqualError(t, err, "Invalid channel")
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.False(t, errors.Is(err, ErrDeviceBusy))
+
+	var de *DeviceError
+	require.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &de))
+	require.Equal(t, http.StatusBadRequest, de.StatusCode)
+	require.Equal(t, "Invalid channel", de.Message)
+
+	require.EqualError(t, newDeviceError(http.StatusNotFound, ""), "Not Found")
+}
+
+func TestDeviceErrorFromResponses(t *testing.T) {
+	status := http.StatusOK
+	body := ""
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprintln(w, body)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.WriteInterval = 0
+	calnexAPI.Client = ts.Client()
+
+	body = "{\"result\": false, \"message\": \"Measurement is running\"}"
+	err := calnexAPI.ClearDevice()
+	require.ErrorIs(t, err, ErrMeasurementActive)
+	require.EqualError(t, err, "Measurement is running")
+
+	status = http.StatusServiceUnavailable
+	body = "<html>unavailable</html>"
+	err = calnexAPI.StartMeasure()
+	require.ErrorIs(t, err, ErrDeviceBusy)
+	require.EqualError(t, err, "Service Unavailable")
+
+	status = http.StatusBadRequest
+	body = "{\"result\": false, \"message\": \"Bad settings\"}"
+	_, err = calnexAPI.post(ts.URL, &bytes.Buffer{}, false)
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.EqualError(t, err, "Bad settings")
+}
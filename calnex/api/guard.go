@@ -0,0 +1,79 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// deviceGuard serializes operations on a single device, shared by all API clients of the device in the process
+type deviceGuard struct {
+	sync.Mutex
+	lastWrite time.Time
+}
+
+var (
+	guardsLock sync.Mutex
+	guards     = map[string]*deviceGuard{}
+)
+
+func guardFor(source string) *deviceGuard {
+	guardsLock.Lock()
+	defer guardsLock.Unlock()
+	g, ok := guards[source]
+	if !ok {
+		g = &deviceGuard{}
+		guards[source] = g
+	}
+	return g
+}
+
+// exclusive runs f while no other operation is running on the device
+func (a *API) exclusive(f func() error) error {
+	g := guardFor(a.source)
+	g.Lock()
+	defer g.Unlock()
+	return f()
+}
+
+// write runs f exclusively, at least WriteInterval after the previous write to the device.
+// With BusyCheck the device must be ready, otherwise ErrDeviceBusy is returned without calling f
+func (a *API) write(f func() error) error {
+	g := guardFor(a.source)
+	g.Lock()
+	defer g.Unlock()
+
+	if !g.lastWrite.IsZero() {
+		if wait := a.WriteInterval - time.Since(g.lastWrite); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	defer func() { g.lastWrite = time.Now() }()
+
+	if a.BusyCheck {
+		s, err := a.FetchStatus()
+		if err != nil {
+			return err
+		}
+		if !s.ReferenceReady || !s.ModulesReady {
+			return fmt.Errorf("%w: reference ready: %t, modules ready: %t", ErrDeviceBusy, s.ReferenceReady, s.ModulesReady)
+		}
+	}
+	return f()
+}
@@ -0,0 +1,117 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteInterval(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "{\"result\": true}")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	calnexAPI.WriteInterval = 100 * time.Millisecond
+
+	start := time.Now()
+	require.NoError(t, calnexAPI.StopMeasure())
+	// interval is shared by all clients of the device
+	other := NewAPI(parsed.Host, true)
+	other.Client = ts.Client()
+	other.WriteInterval = calnexAPI.WriteInterval
+	require.NoError(t, other.StartMeasure())
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(calnexAPI.WriteInterval))
+}
+
+func TestExclusive(t *testing.T) {
+	var running, overlaps int32
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if strings.Contains(r.URL.Path, "getdata") {
+			fmt.Fprintln(w, "1607961193,-000.000000250501")
+			return
+		}
+		fmt.Fprintln(w, "{\"result\": true}")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		calnexAPI := NewAPI(parsed.Host, true)
+		calnexAPI.Client = ts.Client()
+		calnexAPI.WriteInterval = 0
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := calnexAPI.FetchCsv(ChannelA)
+			require.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			require.NoError(t, calnexAPI.StartMeasure())
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(0), overlaps)
+}
+
+func TestBusyCheck(t *testing.T) {
+	ready := false
+	pushed := false
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "getstatus") {
+			fmt.Fprintf(w, "{\"referenceReady\": %t, \"modulesReady\": true, \"measurementActive\": false}\n", ready)
+			return
+		}
+		pushed = true
+		fmt.Fprintln(w, "{\"result\": true}")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	calnexAPI.WriteInterval = 0
+	calnexAPI.BusyCheck = true
+
+	err := calnexAPI.StartMeasure()
+	require.ErrorIs(t, err, ErrDeviceBusy)
+	require.False(t, pushed)
+
+	ready = true
+	require.NoError(t, calnexAPI.StartMeasure())
+	require.True(t, pushed)
+}
@@ -30,3 +30,13 @@ func ToBuffer(f *ini.File) (*bytes.Buffer, error) {
 	_, err := f.WriteTo(buf)
 	return buf, err
 }
+
+// keyValue returns value of the key or empty string if there is no such key.
+// Unlike Section.Key it doesn't add missing keys to the settings
+func keyValue(s *ini.Section, name string) string {
+	k, err := s.GetKey(name)
+	if err != nil {
+		return ""
+	}
+	return k.Value()
+}
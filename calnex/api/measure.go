@@ -0,0 +1,167 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calnex measurement settings keys
+const (
+	MeasureTimeKey = "meas_time"
+	ContinuousKey  = "continuous"
+)
+
+var (
+	errMeasureTimeFormat = errors.New("invalid measurement time format")
+	errMeasureTimeShort  = errors.New("measurement time must be at least 1 minute")
+)
+
+var measureTimeUnits = []struct {
+	name string
+	d    time.Duration
+}{
+	{"days", 24 * time.Hour},
+	{"hours", time.Hour},
+	{"minutes", time.Minute},
+}
+
+// MeasureDuration is a measurement duration with minute resolution.
+// It's represented as "1 days 1 hours" by Calnex
+type MeasureDuration time.Duration
+
+// ParseMeasureDuration parses Calnex representation of the measurement duration
+func ParseMeasureDuration(value string) (MeasureDuration, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return 0, fmt.Errorf("%w: %q", errMeasureTimeFormat, value)
+	}
+	var d time.Duration
+	for i := 0; i < len(fields); i += 2 {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%w: %q", errMeasureTimeFormat, value)
+		}
+		unit := strings.TrimSuffix(fields[i+1], "s") + "s"
+		found := false
+		for _, u := range measureTimeUnits {
+			if u.name == unit {
+				d += time.Duration(n) * u.d
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("%w: %q", errMeasureTimeFormat, value)
+		}
+	}
+	return MeasureDuration(d), nil
+}
+
+// MeasureDurationUntil returns the duration of a measurement started now which ends no later than end
+func MeasureDurationUntil(now, end time.Time) MeasureDuration {
+	return MeasureDuration(end.Sub(now).Truncate(time.Minute))
+}
+
+// Duration returns the measurement duration as time.Duration
+func (d MeasureDuration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String returns Calnex representation of the measurement duration rounded up to a minute
+func (d MeasureDuration) String() string {
+	rest := time.Duration(d)
+	if r := rest % time.Minute; r != 0 {
+		rest += time.Minute - r
+	}
+	parts := []string{}
+	for _, u := range measureTimeUnits {
+		if n := rest / u.d; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, u.name))
+			rest -= n * u.d
+		}
+	}
+	if len(parts) == 0 {
+		return "0 minutes"
+	}
+	return strings.Join(parts, " ")
+}
+
+// MarshalJSON marshals the measurement duration as Go duration string
+func (d MeasureDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON unmarshals the measurement duration from Go duration string such as "25h"
+func (d *MeasureDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = MeasureDuration(v)
+	return nil
+}
+
+// MeasureSettings represents measurement duration and rollover behaviour.
+// With Continuous enabled the device never stops recording: once Duration is reached
+// the oldest data is rolled over. Otherwise measurement stops after Duration
+// Reference guard, if set, validates the reference before measurement is started
+type MeasureSettings struct {
+	Duration   MeasureDuration `json:"duration"`
+	Continuous bool            `json:"continuous"`
+	Reference  *ReferenceGuard `json:"reference,omitempty"`
+}
+
+// DefaultMeasureSettings keeps 25 hours of data recording continuously
+var DefaultMeasureSettings = MeasureSettings{
+	Duration:   MeasureDuration(25 * time.Hour),
+	Continuous: true,
+}
+
+// Validate checks the measurement settings are acceptable by Calnex
+func (m *MeasureSettings) Validate() error {
+	if m.Duration.Duration() < time.Minute {
+		return errMeasureTimeShort
+	}
+	return nil
+}
+
+// FetchMeasureSettings returns measurement duration and rollover settings of the device
+func (a *API) FetchMeasureSettings() (*MeasureSettings, error) {
+	f, err := a.FetchSettings()
+	if err != nil {
+		return nil, err
+	}
+	s := f.Section("measure")
+	d, err := ParseMeasureDuration(s.Key(MeasureTimeKey).Value())
+	if err != nil {
+		return nil, err
+	}
+	return &MeasureSettings{
+		Duration:   d,
+		Continuous: s.Key(ContinuousKey).Value() == ON,
+	}, nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureDurationString(t *testing.T) {
+	require.Equal(t, "1 days 1 hours", MeasureDuration(25*time.Hour).String())
+	require.Equal(t, "10 minutes", MeasureDuration(10*time.Minute).String())
+	require.Equal(t, "2 days 3 hours 4 minutes", MeasureDuration(51*time.Hour+4*time.Minute).String())
+	// rounded up to a minute
+	require.Equal(t, "1 hours 1 minutes", MeasureDuration(time.Hour+time.Second).String())
+	require.Equal(t, "0 minutes", MeasureDuration(0).String())
+}
+
+func TestParseMeasureDuration(t *testing.T) {
+	d, err := ParseMeasureDuration("1 days 1 hours")
+	require.NoError(t, err)
+	require.Equal(t, 25*time.Hour, d.Duration())
+
+	d, err = ParseMeasureDuration("1 day 30 minute")
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour+30*time.Minute, d.Duration())
+
+	for _, v := range []string{"", "1", "1 weeks", "x hours", "-1 hours"} {
+		_, err = ParseMeasureDuration(v)
+		require.ErrorIs(t, err, errMeasureTimeFormat, v)
+	}
+}
+
+func TestMeasureDurationUntil(t *testing.T) {
+	now := time.Date(2021, 12, 1, 10, 0, 30, 0, time.UTC)
+	end := time.Date(2021, 12, 1, 14, 0, 0, 0, time.UTC)
+	require.Equal(t, "3 hours 59 minutes", MeasureDurationUntil(now, end).String())
+}
+
+func TestMeasureSettingsJSON(t *testing.T) {
+	var m MeasureSettings
+	err := json.Unmarshal([]byte(`{"duration": "25h", "continuous": true}`), &m)
+	require.NoError(t, err)
+	require.Equal(t, DefaultMeasureSettings, m)
+
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+	require.Equal(t, `{"duration":"25h0m0s","continuous":true}`, string(b))
+
+	err = json.Unmarshal([]byte(`{"duration": "forever"}`), &m)
+	require.Error(t, err)
+}
+
+func TestMeasureSettingsValidate(t *testing.T) {
+	require.NoError(t, DefaultMeasureSettings.Validate())
+	m := MeasureSettings{Duration: MeasureDuration(30 * time.Second)}
+	require.ErrorIs(t, m.Validate(), errMeasureTimeShort)
+}
+
+func TestFetchMeasureSettings(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.Contains(r.URL.Path, "getsettings"))
+		fmt.Fprintln(w, "[measure]\ncontinuous=Off\nmeas_time=2 hours 30 minutes")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	m, err := calnexAPI.FetchMeasureSettings()
+	require.NoError(t, err)
+	require.Equal(t, &MeasureSettings{Duration: MeasureDuration(150 * time.Minute), Continuous: false}, m)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NTPMetricKey is a setting controlling what NTP client channel records, formatted with channel like "ch6"
+const NTPMetricKey = "%s\\ptp_synce\\ntp\\normalize_delays"
+
+var errBadNTPMetric = errors.New("ntp metric is not recognized")
+
+// NTPMetric is what NTP client channel records
+type NTPMetric int
+
+// Supported NTP metrics
+const (
+	// NTPMetricOffset is raw offset of the server
+	NTPMetricOffset NTPMetric = iota
+	// NTPMetricTIE is time interval error: offset relative to the first sample of the measurement
+	NTPMetricTIE
+)
+
+var ntpMetricToString = map[NTPMetric]string{
+	NTPMetricOffset: "offset",
+	NTPMetricTIE:    "tie",
+}
+
+// NTPMetricFromString returns metric from its name like "offset" or "tie"
+func NTPMetricFromString(value string) (NTPMetric, error) {
+	for m, s := range ntpMetricToString {
+		if s == value {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", errBadNTPMetric, value)
+}
+
+// String returns metric name like "offset" or "tie"
+func (m NTPMetric) String() string {
+	return ntpMetricToString[m]
+}
+
+// MarshalText metric into its name
+func (m NTPMetric) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText metric from its name
+func (m *NTPMetric) UnmarshalText(value []byte) error {
+	mr, err := NTPMetricFromString(string(value))
+	if err != nil {
+		return err
+	}
+	*m = mr
+	return nil
+}
+
+// Value returns Calnex setting value of the metric
+func (m NTPMetric) Value() string {
+	if m == NTPMetricTIE {
+		return ON
+	}
+	return OFF
+}
+
+// FetchNTPMetric returns what NTP client channel records
+func (a *API) FetchNTPMetric(channel Channel) (NTPMetric, error) {
+	d, err := a.FetchDeviceSettings()
+	if err != nil {
+		return 0, err
+	}
+	c, ok := d.Channels[channel]
+	if !ok {
+		return 0, errBadChannel
+	}
+	return c.NTP.Metric, nil
+}
+
+// OffsetToTIE converts offsets into TIE relative to the first offset
+func OffsetToTIE(offsets []float64) []float64 {
+	tie := make([]float64, len(offsets))
+	for i, o := range offsets {
+		tie[i] = o - offsets[0]
+	}
+	return tie
+}
+
+// TIEToOffset converts TIE into offsets given the offset of the first sample,
+// which can be measured independently, for example by a channel recording offsets at the same time
+func TIEToOffset(tie []float64, initial float64) []float64 {
+	offsets := make([]float64, len(tie))
+	for i, t := range tie {
+		offsets[i] = t + initial
+	}
+	return offsets
+}
+
+// ConvertNTPMetric converts samples recorded as metric from into metric to.
+// initial is the offset of the first sample, used only to convert TIE into offsets
+func ConvertNTPMetric(samples []float64, from, to NTPMetric, initial float64) []float64 {
+	switch {
+	case from == to:
+		return append([]float64{}, samples...)
+	case to == NTPMetricTIE:
+		return OffsetToTIE(samples)
+	default:
+		return TIEToOffset(samples, initial)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNTPMetricText(t *testing.T) {
+	m, err := NTPMetricFromString("tie")
+	require.NoError(t, err)
+	require.Equal(t, NTPMetricTIE, m)
+	require.Equal(t, ON, m.Value())
+	require.Equal(t, OFF, NTPMetricOffset.Value())
+
+	_, err = NTPMetricFromString("mtie")
+	require.ErrorIs(t, err, errBadNTPMetric)
+
+	var c struct {
+		Metric NTPMetric `json:"metric"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"metric": "tie"}`), &c))
+	require.Equal(t, NTPMetricTIE, c.Metric)
+	b, err := json.Marshal(c)
+	require.NoError(t, err)
+	require.Equal(t, `{"metric":"tie"}`, string(b))
+	require.Error(t, json.Unmarshal([]byte(`{"metric": "mtie"}`), &c))
+}
+
+func TestFetchNTPMetric(t *testing.T) {
+	sampleResp := "[measure]\nch6\\ptp_synce\\ntp\\normalize_delays=On\nch7\\ptp_synce\\ntp\\normalize_delays=Off\n"
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		fmt.Fprintln(w, sampleResp)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	m, err := calnexAPI.FetchNTPMetric(ChannelONE)
+	require.NoError(t, err)
+	require.Equal(t, NTPMetricTIE, m)
+	m, err = calnexAPI.FetchNTPMetric(ChannelTWO)
+	require.NoError(t, err)
+	require.Equal(t, NTPMetricOffset, m)
+}
+
+func TestConvertNTPMetric(t *testing.T) {
+	offsets := []float64{1e-6, 3e-6, -2e-6}
+	tie := ConvertNTPMetric(offsets, NTPMetricOffset, NTPMetricTIE, 0)
+	require.InDeltaSlice(t, []float64{0, 2e-6, -3e-6}, tie, 1e-12)
+	require.InDeltaSlice(t, offsets, ConvertNTPMetric(tie, NTPMetricTIE, NTPMetricOffset, 1e-6), 1e-12)
+
+	same := ConvertNTPMetric(offsets, NTPMetricOffset, NTPMetricOffset, 0)
+	require.Equal(t, offsets, same)
+	same[0] = 0
+	require.Equal(t, 1e-6, offsets[0])
+
+	require.Empty(t, OffsetToTIE(nil))
+}
@@ -0,0 +1,69 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/facebook/time/metrics"
+)
+
+// instrumentedTransport records requests made to the device
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	source   string
+	registry metrics.Registry
+}
+
+// RoundTrip performs the request and records its result and duration
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	labels := metrics.Labels{"source": t.source}
+	t.registry.Histogram("calnex_api_request_duration_seconds", "Duration of Calnex API requests", metrics.DefaultBuckets, labels).Observe(time.Since(start).Seconds())
+	t.registry.Counter("calnex_api_requests_total", "Calnex API requests by status code", metrics.Labels{"source": t.source, "code": code}).Inc()
+	return resp, err
+}
+
+// SetMetrics makes API record requests to the device in the registry
+func (a *API) SetMetrics(r metrics.Registry) {
+	if t, ok := a.Client.Transport.(*instrumentedTransport); ok {
+		t.registry = metrics.Default(r)
+		return
+	}
+	next := a.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	a.Client.Transport = &instrumentedTransport{next: next, source: a.source, registry: metrics.Default(r)}
+}
+
+// transport returns the underlying http.Transport of the client, if any
+func (a *API) transport() *http.Transport {
+	rt := a.Client.Transport
+	if t, ok := rt.(*instrumentedTransport); ok {
+		rt = t.next
+	}
+	t, _ := rt.(*http.Transport)
+	return t
+}
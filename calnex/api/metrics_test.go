@@ -0,0 +1,64 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/facebook/time/metrics"
+)
+
+func TestSetMetrics(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if r.URL.Path == "/api/getstatus" {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "1607961193.773740,-000.000000250501")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	r := metrics.NewPrometheus()
+	calnexAPI.SetMetrics(r)
+	// dialer is still applied to the wrapped transport
+	d := &countingDialer{}
+	calnexAPI.SetDialer(d)
+	calnexAPI.SetMetrics(r)
+
+	_, err := calnexAPI.FetchCsv(ChannelONE)
+	require.NoError(t, err)
+	_, err = calnexAPI.FetchStatus()
+	require.Error(t, err)
+	require.Equal(t, 1, d.dials)
+
+	var b bytes.Buffer
+	require.NoError(t, r.WritePrometheus(&b))
+	out := b.String()
+	require.Contains(t, out, fmt.Sprintf("calnex_api_requests_total{code=\"200\",source=%q} 1", parsed.Host))
+	require.Contains(t, out, fmt.Sprintf("calnex_api_requests_total{code=\"503\",source=%q} 1", parsed.Host))
+	require.Contains(t, out, fmt.Sprintf("calnex_api_request_duration_seconds_count{source=%q} 2", parsed.Host))
+}
@@ -0,0 +1,159 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	getNetworkURL     = "https://%s/api/getnetwork"
+	setNetworkURL     = "https://%s/api/setnetwork"
+	confirmNetworkURL = "https://%s/api/confirmnetwork"
+)
+
+// Network address modes
+const (
+	NetworkStatic = "static"
+	NetworkDHCP   = "dhcp"
+)
+
+var (
+	errBadNetworkMode = errors.New("network mode is not recognized")
+	errBadAddress     = errors.New("invalid address")
+	errGatewaySubnet  = errors.New("gateway is not in the subnet")
+	errNoRollback     = errors.New("rollback timeout must be positive")
+	// ErrNetworkRolledBack means device was not reachable on the new address and reverted the change
+	ErrNetworkRolledBack = errors.New("network change was not confirmed and is rolled back")
+)
+
+// Network is the management network configuration of the device
+type Network struct {
+	Mode    string   `json:"mode"`
+	Address string   `json:"address,omitempty"`
+	Netmask string   `json:"netmask,omitempty"`
+	Gateway string   `json:"gateway,omitempty"`
+	DNS     []string `json:"dns,omitempty"`
+}
+
+// networkRequest is a body of the network change request.
+// Device reverts the change unless it's confirmed within the rollback timeout
+type networkRequest struct {
+	Network
+	RollbackTimeout int `json:"rollback_timeout,omitempty"`
+}
+
+// Validate checks the network configuration
+func (n *Network) Validate() error {
+	for _, dns := range n.DNS {
+		if net.ParseIP(dns) == nil {
+			return fmt.Errorf("%w: dns %q", errBadAddress, dns)
+		}
+	}
+	switch n.Mode {
+	case NetworkDHCP:
+		return nil
+	case NetworkStatic:
+	default:
+		return fmt.Errorf("%w: %q", errBadNetworkMode, n.Mode)
+	}
+	ip := net.ParseIP(n.Address).To4()
+	if ip == nil {
+		return fmt.Errorf("%w: address %q", errBadAddress, n.Address)
+	}
+	mask := net.ParseIP(n.Netmask).To4()
+	if mask == nil {
+		return fmt.Errorf("%w: netmask %q", errBadAddress, n.Netmask)
+	}
+	if ones, bits := net.IPMask(mask).Size(); ones == 0 && bits == 0 {
+		return fmt.Errorf("%w: netmask %q", errBadAddress, n.Netmask)
+	}
+	if n.Gateway == "" {
+		return nil
+	}
+	gw := net.ParseIP(n.Gateway).To4()
+	if gw == nil {
+		return fmt.Errorf("%w: gateway %q", errBadAddress, n.Gateway)
+	}
+	subnet := &net.IPNet{IP: ip.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)}
+	if !subnet.Contains(gw) {
+		return fmt.Errorf("%w: %s is not in %s", errGatewaySubnet, gw, subnet)
+	}
+	return nil
+}
+
+// FetchNetwork returns the management network configuration
+func (a *API) FetchNetwork() (*Network, error) {
+	url := fmt.Sprintf(getNetworkURL, a.source)
+	resp, err := a.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	n := &Network{}
+	if err = json.NewDecoder(resp.Body).Decode(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// PushNetwork applies the management network configuration.
+// With non-zero rollback device reverts the change unless ConfirmNetwork is called within it
+func (a *API) PushNetwork(n *Network, rollback time.Duration) error {
+	if err := n.Validate(); err != nil {
+		return err
+	}
+	return a.postJSON(setNetworkURL, &networkRequest{Network: *n, RollbackTimeout: int(rollback.Seconds())})
+}
+
+// ConfirmNetwork makes the pending network change permanent
+func (a *API) ConfirmNetwork() error {
+	return a.postJSON(confirmNetworkURL, struct{}{})
+}
+
+// MoveNetwork applies the network configuration and confirms it via newSource, the device address after the change.
+// If device isn't reachable there within rollback it reverts the change and ErrNetworkRolledBack is returned.
+// Returns API of the device on the new address
+func (a *API) MoveNetwork(n *Network, newSource string, rollback time.Duration) (*API, error) {
+	if rollback <= 0 {
+		return nil, errNoRollback
+	}
+	if err := a.PushNetwork(n, rollback); err != nil {
+		return nil, err
+	}
+	moved := *a
+	moved.source = newSource
+	deadline := time.Now().Add(rollback)
+	var err error
+	for time.Now().Before(deadline) {
+		if err = moved.ConfirmNetwork(); err == nil {
+			return &moved, nil
+		}
+		time.Sleep(a.PollInterval)
+	}
+	return nil, fmt.Errorf("%w: %s is unreachable: %v", ErrNetworkRolledBack, newSource, err)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkValidate(t *testing.T) {
+	require.NoError(t, (&Network{Mode: NetworkDHCP}).Validate())
+	require.NoError(t, (&Network{Mode: NetworkStatic, Address: "10.0.0.5", Netmask: "255.255.255.0", Gateway: "10.0.0.1", DNS: []string{"10.0.0.53", "2001:db8::53"}}).Validate())
+	require.NoError(t, (&Network{Mode: NetworkStatic, Address: "10.0.0.5", Netmask: "255.255.0.0"}).Validate())
+
+	require.ErrorIs(t, (&Network{Mode: "bootp"}).Validate(), errBadNetworkMode)
+	require.ErrorIs(t, (&Network{Mode: NetworkStatic, Address: "10.0.0", Netmask: "255.255.255.0"}).Validate(), errBadAddress)
+	require.ErrorIs(t, (&Network{Mode: NetworkStatic, Address: "10.0.0.5", Netmask: "255.0.255.0"}).Validate(), errBadAddress)
+	require.ErrorIs(t, (&Network{Mode: NetworkStatic, Address: "10.0.0.5", Netmask: "255.255.255.0", Gateway: "10.0.1.1"}).Validate(), errGatewaySubnet)
+	require.ErrorIs(t, (&Network{Mode: NetworkDHCP, DNS: []string{"dns.example.com"}}).Validate(), errBadAddress)
+}
+
+func TestFetchNetwork(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/getnetwork", r.URL.Path)
+		fmt.Fprintln(w, `{"mode": "static", "address": "10.0.0.5", "netmask": "255.255.255.0", "gateway": "10.0.0.1", "dns": ["10.0.0.53"]}`)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	n, err := calnexAPI.FetchNetwork()
+	require.NoError(t, err)
+	require.Equal(t, &Network{Mode: NetworkStatic, Address: "10.0.0.5", Netmask: "255.255.255.0", Gateway: "10.0.0.1", DNS: []string{"10.0.0.53"}}, n)
+}
+
+func TestMoveNetwork(t *testing.T) {
+	var pushed networkRequest
+	confirmed := 0
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/setnetwork":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&pushed))
+		case "/api/confirmnetwork":
+			confirmed++
+		}
+		fmt.Fprintln(w, `{"result": true}`)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.WriteInterval = 0
+	calnexAPI.Client = ts.Client()
+
+	n := &Network{Mode: NetworkDHCP}
+	moved, err := calnexAPI.MoveNetwork(n, parsed.Host, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, parsed.Host, moved.source)
+	require.Equal(t, networkRequest{Network: *n, RollbackTimeout: 60}, pushed)
+	require.Equal(t, 1, confirmed)
+
+	_, err = calnexAPI.MoveNetwork(n, parsed.Host, 0)
+	require.ErrorIs(t, err, errNoRollback)
+}
+
+func TestMoveNetworkRolledBack(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"result": true}`)
+	}))
+	defer ts.Close()
+	// nothing listens on the new address
+	gone := httptest.NewServer(http.NotFoundHandler())
+	goneURL, _ := url.Parse(gone.URL)
+	gone.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.WriteInterval = 0
+	calnexAPI.Client = ts.Client()
+	calnexAPI.PollInterval = 10 * time.Millisecond
+
+	_, err := calnexAPI.MoveNetwork(&Network{Mode: NetworkStatic, Address: "10.0.0.5", Netmask: "255.255.255.0"}, goneURL.Host, 50*time.Millisecond)
+	require.ErrorIs(t, err, ErrNetworkRolledBack)
+}
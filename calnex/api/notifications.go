@@ -0,0 +1,181 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-ini/ini"
+)
+
+// NotificationsSection is the settings section holding notification config
+const NotificationsSection = "notifications"
+
+// MaxTrapTargets is how many SNMP trap targets the device supports
+const MaxTrapTargets = 4
+
+// Notification setting keys
+const (
+	snmpEnabledKey   = "snmp\\enabled"
+	snmpCommunityKey = "snmp\\community"
+	snmpTargetKey    = "snmp\\trap_target_%d"
+
+	smtpEnabledKey       = "smtp\\enabled"
+	smtpServerKey        = "smtp\\server"
+	smtpFromKey          = "smtp\\from"
+	smtpToKey            = "smtp\\to"
+	smtpReferenceLossKey = "smtp\\alert_reference_loss"
+)
+
+var (
+	errTooManyTrapTargets = fmt.Errorf("at most %d SNMP trap targets are supported", MaxTrapTargets)
+	errNoSMTPServer       = errors.New("SMTP server is required")
+	errNoRecipients       = errors.New("at least one email recipient is required")
+)
+
+// SNMPNotifications is a config of SNMP traps
+type SNMPNotifications struct {
+	Community string `json:"community"`
+	// Targets are trap receivers as host or host:port
+	Targets []string `json:"targets"`
+}
+
+// EmailNotifications is a config of email alerts
+type EmailNotifications struct {
+	// SMTPServer as host or host:port
+	SMTPServer string   `json:"smtp_server"`
+	From       string   `json:"from"`
+	To         []string `json:"to"`
+	// ReferenceLoss sends an alert when the device loses its reference
+	ReferenceLoss bool `json:"reference_loss"`
+}
+
+// Notifications is a config of the device notifications. Nil SNMP or Email disables it
+type Notifications struct {
+	SNMP  *SNMPNotifications  `json:"snmp,omitempty"`
+	Email *EmailNotifications `json:"email,omitempty"`
+}
+
+func validHostPort(addr string) bool {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return true
+	}
+	return addr != "" && !strings.ContainsAny(addr, " ,")
+}
+
+// Validate checks the notifications config
+func (n *Notifications) Validate() error {
+	if n.SNMP != nil {
+		if len(n.SNMP.Targets) > MaxTrapTargets {
+			return errTooManyTrapTargets
+		}
+		for _, t := range n.SNMP.Targets {
+			if !validHostPort(t) {
+				return fmt.Errorf("invalid SNMP trap target %q", t)
+			}
+		}
+	}
+	if n.Email != nil {
+		if n.Email.SMTPServer == "" {
+			return errNoSMTPServer
+		}
+		if !validHostPort(n.Email.SMTPServer) {
+			return fmt.Errorf("invalid SMTP server %q", n.Email.SMTPServer)
+		}
+		if len(n.Email.To) == 0 {
+			return errNoRecipients
+		}
+		for _, addr := range append([]string{n.Email.From}, n.Email.To...) {
+			if addr != "" && !strings.Contains(addr, "@") {
+				return fmt.Errorf("invalid email address %q", addr)
+			}
+		}
+	}
+	return nil
+}
+
+func onOff(b bool) string {
+	if b {
+		return ON
+	}
+	return OFF
+}
+
+// Settings returns Calnex settings of the notifications section.
+// Unused trap targets are cleared, so nothing stale is left on the device
+func (n *Notifications) Settings() []Setting {
+	res := []Setting{{Key: snmpEnabledKey, Value: onOff(n.SNMP != nil)}}
+	if n.SNMP != nil {
+		res = append(res, Setting{Key: snmpCommunityKey, Value: n.SNMP.Community})
+	}
+	for i := 0; i < MaxTrapTargets; i++ {
+		target := ""
+		if n.SNMP != nil && i < len(n.SNMP.Targets) {
+			target = n.SNMP.Targets[i]
+		}
+		res = append(res, Setting{Key: fmt.Sprintf(snmpTargetKey, i+1), Value: target})
+	}
+	res = append(res, Setting{Key: smtpEnabledKey, Value: onOff(n.Email != nil)})
+	if n.Email != nil {
+		res = append(res,
+			Setting{Key: smtpServerKey, Value: n.Email.SMTPServer},
+			Setting{Key: smtpFromKey, Value: n.Email.From},
+			Setting{Key: smtpToKey, Value: strings.Join(n.Email.To, ",")},
+			Setting{Key: smtpReferenceLossKey, Value: onOff(n.Email.ReferenceLoss)},
+		)
+	}
+	return res
+}
+
+// NotificationsFromSettings returns notifications config from the notifications section
+func NotificationsFromSettings(s *ini.Section) *Notifications {
+	n := &Notifications{}
+	if keyValue(s, snmpEnabledKey) == ON {
+		n.SNMP = &SNMPNotifications{Community: keyValue(s, snmpCommunityKey), Targets: []string{}}
+		for i := 1; i <= MaxTrapTargets; i++ {
+			if t := keyValue(s, fmt.Sprintf(snmpTargetKey, i)); t != "" {
+				n.SNMP.Targets = append(n.SNMP.Targets, t)
+			}
+		}
+	}
+	if keyValue(s, smtpEnabledKey) == ON {
+		n.Email = &EmailNotifications{
+			SMTPServer:    keyValue(s, smtpServerKey),
+			From:          keyValue(s, smtpFromKey),
+			To:            []string{},
+			ReferenceLoss: keyValue(s, smtpReferenceLossKey) == ON,
+		}
+		for _, to := range strings.Split(keyValue(s, smtpToKey), ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				n.Email.To = append(n.Email.To, to)
+			}
+		}
+	}
+	return n
+}
+
+// FetchNotifications returns notifications config of the device
+func (a *API) FetchNotifications() (*Notifications, error) {
+	f, err := a.FetchSettings()
+	if err != nil {
+		return nil, err
+	}
+	return NotificationsFromSettings(f.Section(NotificationsSection)), nil
+}
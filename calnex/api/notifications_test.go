@@ -0,0 +1,98 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-ini/ini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationsSettings(t *testing.T) {
+	n := &Notifications{
+		SNMP:  &SNMPNotifications{Community: "public", Targets: []string{"192.0.2.1", "monitoring.example.com:1162"}},
+		Email: &EmailNotifications{SMTPServer: "smtp.example.com:25", From: "calnex@example.com", To: []string{"oncall@example.com", "lab@example.com"}, ReferenceLoss: true},
+	}
+	require.NoError(t, n.Validate())
+	require.Equal(t, []Setting{
+		{Key: "snmp\\enabled", Value: "On"},
+		{Key: "snmp\\community", Value: "public"},
+		{Key: "snmp\\trap_target_1", Value: "192.0.2.1"},
+		{Key: "snmp\\trap_target_2", Value: "monitoring.example.com:1162"},
+		{Key: "snmp\\trap_target_3", Value: ""},
+		{Key: "snmp\\trap_target_4", Value: ""},
+		{Key: "smtp\\enabled", Value: "On"},
+		{Key: "smtp\\server", Value: "smtp.example.com:25"},
+		{Key: "smtp\\from", Value: "calnex@example.com"},
+		{Key: "smtp\\to", Value: "oncall@example.com,lab@example.com"},
+		{Key: "smtp\\alert_reference_loss", Value: "On"},
+	}, n.Settings())
+
+	// everything disabled
+	require.Equal(t, []Setting{
+		{Key: "snmp\\enabled", Value: "Off"},
+		{Key: "snmp\\trap_target_1", Value: ""},
+		{Key: "snmp\\trap_target_2", Value: ""},
+		{Key: "snmp\\trap_target_3", Value: ""},
+		{Key: "snmp\\trap_target_4", Value: ""},
+		{Key: "smtp\\enabled", Value: "Off"},
+	}, (&Notifications{}).Settings())
+}
+
+func TestNotificationsValidate(t *testing.T) {
+	require.ErrorIs(t, (&Notifications{SNMP: &SNMPNotifications{Targets: []string{"a", "b", "c", "d", "e"}}}).Validate(), errTooManyTrapTargets)
+	require.Error(t, (&Notifications{SNMP: &SNMPNotifications{Targets: []string{"a b"}}}).Validate())
+	require.ErrorIs(t, (&Notifications{Email: &EmailNotifications{To: []string{"a@example.com"}}}).Validate(), errNoSMTPServer)
+	require.ErrorIs(t, (&Notifications{Email: &EmailNotifications{SMTPServer: "smtp"}}).Validate(), errNoRecipients)
+	require.EqualError(t, (&Notifications{Email: &EmailNotifications{SMTPServer: "smtp", To: []string{"oncall"}}}).Validate(), "invalid email address \"oncall\"")
+}
+
+func TestNotificationsFromSettings(t *testing.T) {
+	for _, n := range []*Notifications{
+		{},
+		{SNMP: &SNMPNotifications{Community: "public", Targets: []string{"192.0.2.1"}}},
+		{Email: &EmailNotifications{SMTPServer: "smtp", From: "", To: []string{"a@example.com", "b@example.com"}}},
+	} {
+		f := ini.Empty()
+		s := f.Section(NotificationsSection)
+		for _, setting := range n.Settings() {
+			s.Key(setting.Key).SetValue(setting.Value)
+		}
+		require.Equal(t, n, NotificationsFromSettings(s))
+	}
+}
+
+func TestFetchNotifications(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		fmt.Fprintln(w, "[notifications]\nsnmp\\enabled=On\nsnmp\\community=lab\nsnmp\\trap_target_2=192.0.2.2\nsmtp\\enabled=Off")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	n, err := calnexAPI.FetchNotifications()
+	require.NoError(t, err)
+	require.Equal(t, &Notifications{SNMP: &SNMPNotifications{Community: "lab", Targets: []string{"192.0.2.2"}}}, n)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPollInterval is how often the device is polled for asynchronous operation status
+const DefaultPollInterval = 5 * time.Second
+
+// DefaultWriteInterval is the minimum time between writes to the device
+const DefaultWriteInterval = time.Second
+
+// OperationCheck reports if asynchronous operation is complete.
+// Errors are considered transient, for example when the device is rebooting
+type OperationCheck func() (done bool, err error)
+
+// WaitForOperation calls check immediately and then every interval until operation is complete or ctx is done
+func WaitForOperation(ctx context.Context, interval time.Duration, check OperationCheck) error {
+	var lastErr error
+	for {
+		done, err := check()
+		if err != nil {
+			log.Debugf("operation is not complete: %v", err)
+			lastErr = err
+		} else if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w, last error: %v", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WaitForReady waits until both reference and modules are ready
+func (a *API) WaitForReady(ctx context.Context) error {
+	return WaitForOperation(ctx, a.PollInterval, func() (bool, error) {
+		s, err := a.FetchStatus()
+		if err != nil {
+			return false, err
+		}
+		return s.ReferenceReady && s.ModulesReady, nil
+	})
+}
+
+// WaitForReboot waits for the device to go down and come back ready
+func (a *API) WaitForReboot(ctx context.Context) error {
+	err := WaitForOperation(ctx, a.PollInterval, func() (bool, error) {
+		_, err := a.FetchStatus()
+		return err != nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("device didn't go down: %w", err)
+	}
+	return a.WaitForReady(ctx)
+}
+
+// ClearDeviceAndWait clears device data and waits for the device to reboot
+func (a *API) ClearDeviceAndWait(ctx context.Context) error {
+	if err := a.ClearDevice(); err != nil {
+		return err
+	}
+	return a.WaitForReboot(ctx)
+}
+
+// FetchProblemReportAndWait saves a problem report retrying while the device generates it
+func (a *API) FetchProblemReportAndWait(ctx context.Context, dir string) (string, error) {
+	var reportFileName string
+	err := WaitForOperation(ctx, a.PollInterval, func() (bool, error) {
+		name, err := a.FetchProblemReport(dir)
+		if err != nil {
+			return false, err
+		}
+		reportFileName = name
+		return true, nil
+	})
+	return reportFileName, err
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForOperation(t *testing.T) {
+	calls := 0
+	err := WaitForOperation(context.Background(), time.Millisecond, func() (bool, error) {
+		calls++
+		if calls == 1 {
+			return false, errors.New("rebooting")
+		}
+		return calls == 3, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+}
+
+func TestWaitForOperationTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := WaitForOperation(ctx, time.Millisecond, func() (bool, error) {
+		return false, errors.New("rebooting")
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, err.Error(), "rebooting")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = WaitForOperation(ctx, time.Millisecond, func() (bool, error) {
+		return false, nil
+	})
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestClearDeviceAndWait(t *testing.T) {
+	statusCalls := 0
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if strings.Contains(r.URL.Path, "cleardevice") {
+			fmt.Fprintln(w, "{\n\"result\": true\n}")
+			return
+		}
+		statusCalls++
+		switch statusCalls {
+		case 1:
+			// still up
+			fmt.Fprintln(w, "{\n\"referenceReady\": true,\n\"modulesReady\": true,\n\"measurementActive\": false\n}")
+		case 2:
+			// rebooting
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 3:
+			// booting
+			fmt.Fprintln(w, "{\n\"referenceReady\": false,\n\"modulesReady\": true,\n\"measurementActive\": false\n}")
+		default:
+			fmt.Fprintln(w, "{\n\"referenceReady\": true,\n\"modulesReady\": true,\n\"measurementActive\": false\n}")
+		}
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	calnexAPI.PollInterval = time.Millisecond
+
+	err := calnexAPI.ClearDeviceAndWait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 4, statusCalls)
+}
+
+func TestFetchProblemReportAndWait(t *testing.T) {
+	calls := 0
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "I am a problem report")
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	calnexAPI.PollInterval = time.Millisecond
+
+	dir, err := ioutil.TempDir("/tmp", "calnex")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	reportFilePath, err := calnexAPI.FetchProblemReportAndWait(context.Background(), dir)
+	require.NoError(t, err)
+	require.FileExists(t, reportFilePath)
+	require.Equal(t, 3, calls)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dataPageURL returns up to count samples starting from the index without resetting the read pointer
+const dataPageURL = "https://%s/api/getdata?channel=%s&datatype=%s&reset=false&index=%d&count=%d"
+
+// DefaultPageSize is how many CSV lines are fetched in one request by CsvIterator
+const DefaultPageSize = 100000
+
+// DefaultPageRetries is how many times a page is fetched again after a transient error
+const DefaultPageRetries = 3
+
+var errBadToken = errors.New("malformed continuation token")
+
+// CsvIterator fetches CSV lines of the channel page by page, so huge datasets
+// don't have to be transferred in a single request.
+// A page failed with a transient error is fetched again, and the transfer can be
+// resumed later from the Token in case all retries failed
+type CsvIterator struct {
+	// PageSize is how many lines are requested at once
+	PageSize int
+	// Retries is how many times a page is fetched again after a network error or busy device
+	Retries int
+	// RetryDelay is the delay before the first retry, doubled after every attempt
+	RetryDelay time.Duration
+
+	api     *API
+	channel Channel
+	index   int
+	done    bool
+}
+
+// NewCsvIterator returns iterator over CSV lines of the channel starting from the first one
+func (a *API) NewCsvIterator(channel Channel) *CsvIterator {
+	return a.newCsvIterator(channel, 0)
+}
+
+// ResumeCsvIterator returns iterator continuing from the token returned by CsvIterator.Token
+func (a *API) ResumeCsvIterator(token string) (*CsvIterator, error) {
+	s := strings.Split(token, ":")
+	if len(s) != 2 {
+		return nil, errBadToken
+	}
+	channel, err := ChannelFromString(s[0])
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.Atoi(s[1])
+	if err != nil || index < 0 {
+		return nil, errBadToken
+	}
+	return a.newCsvIterator(*channel, index), nil
+}
+
+func (a *API) newCsvIterator(channel Channel, index int) *CsvIterator {
+	return &CsvIterator{
+		PageSize:   DefaultPageSize,
+		Retries:    DefaultPageRetries,
+		RetryDelay: a.PollInterval,
+		api:        a,
+		channel:    channel,
+		index:      index,
+	}
+}
+
+// Token returns continuation token pointing to the next line to fetch
+func (it *CsvIterator) Token() string {
+	return fmt.Sprintf("%s:%d", it.channel, it.index)
+}
+
+// Index returns the index of the next line to fetch
+func (it *CsvIterator) Index() int {
+	return it.index
+}
+
+// Next returns the next page of CSV lines. io.EOF is returned after the last page
+func (it *CsvIterator) Next() ([][]string, error) {
+	if it.done {
+		return nil, io.EOF
+	}
+	url := fmt.Sprintf(dataPageURL, it.api.source, it.channel, channelDatatypeMap[it.channel], it.index, it.PageSize)
+	delay := it.RetryDelay
+	for attempt := 0; ; attempt++ {
+		lines, err := it.api.fetchCsv(url, it.channel)
+		if err == nil {
+			it.index += len(lines)
+			if len(lines) < it.PageSize {
+				it.done = true
+			}
+			if len(lines) == 0 {
+				return nil, io.EOF
+			}
+			return lines, nil
+		}
+		if !transient(err) || attempt >= it.Retries {
+			return nil, fmt.Errorf("failed to fetch page of channel %s, resume from %s: %w", it.channel, it.Token(), err)
+		}
+		log.Warningf("Fetching page of channel %s from %d failed, retrying in %v: %v", it.channel, it.index, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// All returns all remaining CSV lines
+func (it *CsvIterator) All() ([][]string, error) {
+	var res [][]string
+	for {
+		lines, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, lines...)
+	}
+}
+
+// transient returns true if fetching the data may succeed later
+func transient(err error) bool {
+	var de *DeviceError
+	if !errors.As(err, &de) {
+		// network errors
+		return true
+	}
+	return errors.Is(err, ErrDeviceBusy) || de.StatusCode >= 500
+}
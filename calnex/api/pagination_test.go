@@ -0,0 +1,137 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pagedServer serves total lines, failing the first request for every index in fail
+func pagedServer(t *testing.T, total int, fail map[int]int) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "false", r.URL.Query().Get("reset"))
+		require.Equal(t, "1", r.URL.Query().Get("channel"))
+		index, err := strconv.Atoi(r.URL.Query().Get("index"))
+		require.NoError(t, err)
+		count, err := strconv.Atoi(r.URL.Query().Get("count"))
+		require.NoError(t, err)
+		if code, ok := fail[index]; ok {
+			delete(fail, index)
+			w.WriteHeader(code)
+			return
+		}
+		for i := index; i < total && i < index+count; i++ {
+			fmt.Fprintf(w, "1607961193.%06d,-000.000000250501\n", i)
+		}
+	}))
+}
+
+func newPagedAPI(ts *httptest.Server) *API {
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	calnexAPI.PollInterval = 0
+	return calnexAPI
+}
+
+func TestCsvIterator(t *testing.T) {
+	ts := pagedServer(t, 7, map[int]int{3: http.StatusServiceUnavailable})
+	defer ts.Close()
+
+	it := newPagedAPI(ts).NewCsvIterator(ChannelONE)
+	it.PageSize = 3
+	lines, err := it.Next()
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	require.Equal(t, "1:3", it.Token())
+
+	// the second page is retried after 503
+	lines, err = it.Next()
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	require.Equal(t, "1607961193.000003", lines[0][0])
+
+	lines, err = it.Next()
+	require.NoError(t, err)
+	require.Len(t, lines, 1)
+	require.Equal(t, 7, it.Index())
+
+	_, err = it.Next()
+	require.True(t, errors.Is(err, io.EOF))
+}
+
+func TestCsvIteratorExactPages(t *testing.T) {
+	ts := pagedServer(t, 4, nil)
+	defer ts.Close()
+
+	it := newPagedAPI(ts).NewCsvIterator(ChannelONE)
+	it.PageSize = 2
+	lines, err := it.All()
+	require.NoError(t, err)
+	require.Len(t, lines, 4)
+	require.Equal(t, "1607961193.000003", lines[3][0])
+}
+
+func TestCsvIteratorResume(t *testing.T) {
+	ts := pagedServer(t, 5, map[int]int{2: http.StatusBadRequest})
+	defer ts.Close()
+	calnexAPI := newPagedAPI(ts)
+
+	it := calnexAPI.NewCsvIterator(ChannelONE)
+	it.PageSize = 2
+	_, err := it.All()
+	// bad request is not retried
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrBadRequest))
+	require.Equal(t, "1:2", it.Token())
+
+	it, err = calnexAPI.ResumeCsvIterator(it.Token())
+	require.NoError(t, err)
+	it.PageSize = 2
+	lines, err := it.All()
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	require.Equal(t, "1607961193.000002", lines[0][0])
+}
+
+func TestCsvIteratorRetriesExhausted(t *testing.T) {
+	ts := pagedServer(t, 5, map[int]int{0: http.StatusInternalServerError})
+	defer ts.Close()
+
+	it := newPagedAPI(ts).NewCsvIterator(ChannelONE)
+	it.Retries = 0
+	_, err := it.Next()
+	require.Error(t, err)
+	require.Equal(t, 0, it.Index())
+}
+
+func TestResumeCsvIteratorBadToken(t *testing.T) {
+	calnexAPI := NewAPI("localhost", true)
+	for _, token := range []string{"", "1", "x:1", "1:x", "1:-1"} {
+		_, err := calnexAPI.ResumeCsvIterator(token)
+		require.Error(t, err, token)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const getPortsURL = "https://%s/api/getportstatus"
+
+// MinRxPower is the lowest SFP receive power in dBm considered a usable signal
+const MinRxPower = -20.0
+
+// ErrCabling means channel is affected by a physical port problem rather than probe configuration
+var ErrCabling = errors.New("cabling problem")
+
+// ErrNoPort means channel is not mapped to any physical port of the device
+var ErrNoPort = errors.New("channel is not mapped to a port")
+
+// LinkState is a state of the ethernet link
+type LinkState string
+
+// Link states reported by the device
+const (
+	LinkUp   LinkState = "up"
+	LinkDown LinkState = "down"
+)
+
+// SFP is a struct representing status of the pluggable transceiver
+type SFP struct {
+	Present    bool
+	Vendor     string
+	PartNumber string
+	// RxPower and TxPower in dBm
+	RxPower float64
+	TxPower float64
+}
+
+// Port is a struct representing Calnex physical port status JSON response
+type Port struct {
+	Name string
+	// Channels measured on the port
+	Channels []Channel
+	// Link is empty for ports without ethernet link, such as 1PPS or 10MHz inputs
+	Link LinkState
+	// SFP is nil for ports without SFP cage
+	SFP *SFP
+	// SignalPresent is true when input signal is detected
+	SignalPresent bool
+}
+
+// ports is a struct representing Calnex port status JSON response
+type ports struct {
+	Ports []*Port
+}
+
+// Problems returns physical problems of the port, such as missing SFP, link down or low signal
+func (p *Port) Problems() []string {
+	var problems []string
+	if p.SFP != nil && !p.SFP.Present {
+		return append(problems, "SFP is not present")
+	}
+	if p.Link == LinkDown {
+		problems = append(problems, "link is down")
+	}
+	if !p.SignalPresent {
+		problems = append(problems, "no signal")
+	}
+	if p.SFP != nil && p.SFP.RxPower < MinRxPower {
+		problems = append(problems, fmt.Sprintf("SFP rx power %.1f dBm is below %.1f dBm", p.SFP.RxPower, MinRxPower))
+	}
+	return problems
+}
+
+// FetchPorts returns status of the physical ports with channels mapped to them
+func (a *API) FetchPorts() ([]*Port, error) {
+	url := fmt.Sprintf(getPortsURL, a.source)
+	resp, err := a.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	p := &ports{}
+	if err = json.NewDecoder(resp.Body).Decode(p); err != nil {
+		return nil, err
+	}
+	return p.Ports, nil
+}
+
+// FetchChannelPort returns the physical port channel is measured on
+func (a *API) FetchChannelPort(channel Channel) (*Port, error) {
+	ports, err := a.FetchPorts()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ports {
+		for _, c := range p.Channels {
+			if c == channel {
+				return p, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoPort, channel)
+}
+
+// DiagnoseChannel returns ErrCabling with the reasons if port of the channel has physical problems.
+// No error means the port is fine and channel problems are caused by the probe configuration
+func (a *API) DiagnoseChannel(channel Channel) error {
+	p, err := a.FetchChannelPort(channel)
+	if err != nil {
+		return err
+	}
+	if problems := p.Problems(); len(problems) > 0 {
+		return fmt.Errorf("%w: port %s of channel %s: %s", ErrCabling, p.Name, channel, strings.Join(problems, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const portsJSON = `{"ports": [
+	{"name": "eth1", "channels": ["1"], "link": "up", "signalPresent": true,
+		"sfp": {"present": true, "vendor": "FINISAR", "partNumber": "FTLF8519P3BNL", "rxPower": -5.2, "txPower": -4.9}},
+	{"name": "eth2", "channels": ["2"], "link": "down", "signalPresent": false,
+		"sfp": {"present": false}},
+	{"name": "pps", "channels": ["a", "b"], "signalPresent": true}
+]}`
+
+func startPortsDevice(t *testing.T, response string) (*API, func()) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "getportstatus") || response == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	return calnexAPI, ts.Close
+}
+
+func TestFetchPorts(t *testing.T) {
+	calnexAPI, stop := startPortsDevice(t, portsJSON)
+	defer stop()
+
+	ports, err := calnexAPI.FetchPorts()
+	require.NoError(t, err)
+	require.Len(t, ports, 3)
+	require.Equal(t, &Port{
+		Name:          "eth1",
+		Channels:      []Channel{ChannelONE},
+		Link:          LinkUp,
+		SignalPresent: true,
+		SFP:           &SFP{Present: true, Vendor: "FINISAR", PartNumber: "FTLF8519P3BNL", RxPower: -5.2, TxPower: -4.9},
+	}, ports[0])
+	require.Equal(t, []Channel{ChannelA, ChannelB}, ports[2].Channels)
+	require.Nil(t, ports[2].SFP)
+
+	p, err := calnexAPI.FetchChannelPort(ChannelB)
+	require.NoError(t, err)
+	require.Equal(t, "pps", p.Name)
+
+	_, err = calnexAPI.FetchChannelPort(ChannelF)
+	require.ErrorIs(t, err, ErrNoPort)
+}
+
+func TestFetchPortsNotFound(t *testing.T) {
+	calnexAPI, stop := startPortsDevice(t, "")
+	defer stop()
+
+	_, err := calnexAPI.FetchPorts()
+	require.True(t, isNotFound(err))
+}
+
+func TestPortProblems(t *testing.T) {
+	p := &Port{Link: LinkUp, SignalPresent: true, SFP: &SFP{Present: true, RxPower: -3}}
+	require.Empty(t, p.Problems())
+
+	p.SFP.RxPower = -25
+	require.Equal(t, []string{"SFP rx power -25.0 dBm is below -20.0 dBm"}, p.Problems())
+
+	p.Link = LinkDown
+	p.SignalPresent = false
+	require.Equal(t, []string{"link is down", "no signal", "SFP rx power -25.0 dBm is below -20.0 dBm"}, p.Problems())
+
+	p.SFP.Present = false
+	require.Equal(t, []string{"SFP is not present"}, p.Problems())
+
+	// signal inputs have neither link nor SFP
+	require.Empty(t, (&Port{SignalPresent: true}).Problems())
+}
+
+func TestDiagnoseChannel(t *testing.T) {
+	calnexAPI, stop := startPortsDevice(t, portsJSON)
+	defer stop()
+
+	require.NoError(t, calnexAPI.DiagnoseChannel(ChannelONE))
+	require.NoError(t, calnexAPI.DiagnoseChannel(ChannelA))
+
+	err := calnexAPI.DiagnoseChannel(ChannelTWO)
+	require.ErrorIs(t, err, ErrCabling)
+	require.EqualError(t, err, "cabling problem: port eth2 of channel 2: SFP is not present")
+
+	require.ErrorIs(t, calnexAPI.DiagnoseChannel(ChannelF), ErrNoPort)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const getGNSSURL = "https://%s/api/getgnssstatus"
+
+// ErrReferenceNotReady means measurement would run against unlocked reference
+var ErrReferenceNotReady = errors.New("reference is not ready")
+
+// GNSS is a struct representing Calnex GNSS receiver status JSON response
+type GNSS struct {
+	Locked        bool
+	Satellites    int
+	AntennaStatus string
+}
+
+// FetchGNSS returns GNSS receiver status. Devices without GNSS receiver respond with Not Found DeviceError
+func (a *API) FetchGNSS() (*GNSS, error) {
+	url := fmt.Sprintf(getGNSSURL, a.source)
+	resp, err := a.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	g := &GNSS{}
+	if err = json.NewDecoder(resp.Body).Decode(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func isNotFound(err error) bool {
+	var de *DeviceError
+	return errors.As(err, &de) && de.StatusCode == http.StatusNotFound
+}
+
+// ReferenceGuard validates the reference before measurement starts
+type ReferenceGuard struct {
+	// Wait for the reference to become ready up to this long. Checked once if 0
+	Wait MeasureDuration `json:"wait,omitempty"`
+	// GNSS requires GNSS receiver lock, if device has one
+	GNSS bool `json:"gnss"`
+	// MinSatellites required in view with GNSS check
+	MinSatellites int `json:"min_satellites,omitempty"`
+	// WarnOnly starts measurement anyway, only logging the problem
+	WarnOnly bool `json:"warn_only"`
+}
+
+// CheckReference returns ErrReferenceNotReady with the reasons if reference is not locked
+func (a *API) CheckReference(g *ReferenceGuard) error {
+	s, err := a.FetchStatus()
+	if err != nil {
+		return err
+	}
+	var problems []string
+	if !s.ReferenceReady {
+		problems = append(problems, "reference is not ready")
+	}
+	if !s.ModulesReady {
+		problems = append(problems, "modules are not ready")
+	}
+	if g.GNSS {
+		gnss, err := a.FetchGNSS()
+		switch {
+		case isNotFound(err):
+			log.Debugf("%s has no GNSS receiver", a.source)
+		case err != nil:
+			return err
+		case !gnss.Locked:
+			problems = append(problems, "GNSS is not locked")
+		case gnss.Satellites < g.MinSatellites:
+			problems = append(problems, fmt.Sprintf("%d GNSS satellites, %d required", gnss.Satellites, g.MinSatellites))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrReferenceNotReady, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// WaitForReference waits up to g.Wait until reference is ready
+func (a *API) WaitForReference(ctx context.Context, g *ReferenceGuard) error {
+	ctx, cancel := context.WithTimeout(ctx, g.Wait.Duration())
+	defer cancel()
+	var lastErr error
+	err := WaitForOperation(ctx, a.PollInterval, func() (bool, error) {
+		lastErr = a.CheckReference(g)
+		if errors.Is(lastErr, ErrReferenceNotReady) {
+			return false, nil
+		}
+		return lastErr == nil, lastErr
+	})
+	if errors.Is(lastErr, ErrReferenceNotReady) {
+		return lastErr
+	}
+	return err
+}
+
+// StartMeasureGuarded starts measurement once the reference is ready.
+// Without the guard measurement is started right away
+func (a *API) StartMeasureGuarded(ctx context.Context, g *ReferenceGuard) error {
+	if g != nil {
+		if err := a.WaitForReference(ctx, g); err != nil {
+			if !g.WarnOnly || !errors.Is(err, ErrReferenceNotReady) {
+				return err
+			}
+			log.Warningf("%s: starting measurement anyway: %v", a.source, err)
+		}
+	}
+	return a.StartMeasure()
+}
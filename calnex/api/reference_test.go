@@ -0,0 +1,119 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// referenceDevice becomes ready after readyAfter status requests
+type referenceDevice struct {
+	readyAfter int
+	statuses   int
+	gnss       string
+	started    bool
+}
+
+func (d *referenceDevice) start(t *testing.T) (*API, func()) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "getstatus"):
+			d.statuses++
+			ready := d.statuses > d.readyAfter
+			fmt.Fprintf(w, `{"referenceReady": %t, "modulesReady": true, "measurementActive": false}`, ready)
+		case strings.Contains(r.URL.Path, "getgnssstatus"):
+			if d.gnss == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, d.gnss)
+		case strings.Contains(r.URL.Path, "startmeasurement"):
+			d.started = true
+			fmt.Fprintln(w, `{"result": true}`)
+		}
+	}))
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+	calnexAPI.PollInterval = time.Millisecond
+	return calnexAPI, ts.Close
+}
+
+func TestCheckReference(t *testing.T) {
+	d := &referenceDevice{readyAfter: 1, gnss: `{"locked": true, "satellites": 3}`}
+	calnexAPI, stop := d.start(t)
+	defer stop()
+
+	err := calnexAPI.CheckReference(&ReferenceGuard{})
+	require.ErrorIs(t, err, ErrReferenceNotReady)
+	require.EqualError(t, err, "reference is not ready: reference is not ready")
+	require.NoError(t, calnexAPI.CheckReference(&ReferenceGuard{GNSS: true, MinSatellites: 3}))
+
+	err = calnexAPI.CheckReference(&ReferenceGuard{GNSS: true, MinSatellites: 4})
+	require.ErrorIs(t, err, ErrReferenceNotReady)
+	require.Contains(t, err.Error(), "3 GNSS satellites, 4 required")
+
+	d.gnss = `{"locked": false}`
+	err = calnexAPI.CheckReference(&ReferenceGuard{GNSS: true})
+	require.Contains(t, err.Error(), "GNSS is not locked")
+
+	// device without GNSS receiver
+	d.gnss = ""
+	require.NoError(t, calnexAPI.CheckReference(&ReferenceGuard{GNSS: true, MinSatellites: 4}))
+}
+
+func TestStartMeasureGuarded(t *testing.T) {
+	d := &referenceDevice{readyAfter: 3}
+	calnexAPI, stop := d.start(t)
+	defer stop()
+
+	require.NoError(t, calnexAPI.StartMeasureGuarded(context.Background(), &ReferenceGuard{Wait: MeasureDuration(time.Second)}))
+	require.True(t, d.started)
+	require.Equal(t, 4, d.statuses)
+}
+
+func TestStartMeasureGuardedNotReady(t *testing.T) {
+	d := &referenceDevice{readyAfter: 1000}
+	calnexAPI, stop := d.start(t)
+	defer stop()
+
+	err := calnexAPI.StartMeasureGuarded(context.Background(), &ReferenceGuard{Wait: MeasureDuration(20 * time.Millisecond)})
+	require.ErrorIs(t, err, ErrReferenceNotReady)
+	require.False(t, d.started)
+
+	require.NoError(t, calnexAPI.StartMeasureGuarded(context.Background(), &ReferenceGuard{WarnOnly: true}))
+	require.True(t, d.started)
+}
+
+func TestStartMeasureUnguarded(t *testing.T) {
+	d := &referenceDevice{readyAfter: 1000}
+	calnexAPI, stop := d.start(t)
+	defer stop()
+
+	require.NoError(t, calnexAPI.StartMeasureGuarded(context.Background(), nil))
+	require.True(t, d.started)
+	require.Zero(t, d.statuses)
+}
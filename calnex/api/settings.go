@@ -0,0 +1,114 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/go-ini/ini"
+)
+
+// settingsCache keeps the last downloaded settings, so unchanged settings are not downloaded again
+// if device supports conditional requests
+type settingsCache struct {
+	sync.Mutex
+	etag         string
+	lastModified string
+	body         []byte
+	sum          [sha256.Size]byte
+}
+
+// reset forgets cached settings, for example after they are changed
+func (c *settingsCache) reset() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.etag = ""
+	c.lastModified = ""
+	c.body = nil
+	c.sum = [sha256.Size]byte{}
+}
+
+// FetchSettingsIfChanged returns the calnex settings and whether they changed since the previous fetch.
+// Device is asked with If-None-Match/If-Modified-Since to skip the download of unchanged settings.
+// If device doesn't support conditional requests the settings are compared by hash.
+// Returned settings are parsed every time, so they can be modified by the caller
+func (a *API) FetchSettingsIfChanged() (*ini.File, bool, error) {
+	body, changed, err := a.fetchSettings()
+	if err != nil {
+		return nil, false, err
+	}
+	f, err := ini.Load(body)
+	if err != nil {
+		return nil, false, err
+	}
+	return f, changed, nil
+}
+
+func (a *API) fetchSettings() ([]byte, bool, error) {
+	url := fmt.Sprintf(getSettingsURL, a.source)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	c := a.settings
+	if c == nil {
+		c = &settingsCache{}
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.body != nil {
+		if c.etag != "" {
+			req.Header.Set("If-None-Match", c.etag)
+		}
+		if c.lastModified != "" {
+			req.Header.Set("If-Modified-Since", c.lastModified)
+		}
+	}
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode == http.StatusNotModified && c.body != nil {
+		return c.body, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, statusError(resp)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	sum := sha256.Sum256(body)
+	changed := c.body == nil || !bytes.Equal(sum[:], c.sum[:])
+	c.etag = resp.Header.Get("ETag")
+	c.lastModified = resp.Header.Get("Last-Modified")
+	c.body = body
+	c.sum = sum
+	return body, changed, nil
+}
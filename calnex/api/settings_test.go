@@ -0,0 +1,98 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSettings = "[measure]\nch0\\used=Yes\n"
+
+func TestFetchSettingsETag(t *testing.T) {
+	downloads := 0
+	etag := `"v1"`
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			etag = `"v2"`
+			fmt.Fprintln(w, `{"result": true}`)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, testSettings)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	f, changed, err := calnexAPI.FetchSettingsIfChanged()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "Yes", f.Section("measure").Key("ch0\\used").String())
+	// caller may modify returned settings
+	f.Section("measure").Key("ch0\\used").SetValue("No")
+
+	f, changed, err = calnexAPI.FetchSettingsIfChanged()
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Equal(t, "Yes", f.Section("measure").Key("ch0\\used").String())
+	require.Equal(t, 1, downloads)
+
+	// pushing settings invalidates the cache
+	require.NoError(t, calnexAPI.PushSettings(f))
+	_, err = calnexAPI.FetchSettings()
+	require.NoError(t, err)
+	require.Equal(t, 2, downloads)
+}
+
+func TestFetchSettingsHash(t *testing.T) {
+	settings := testSettings
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("If-None-Match"))
+		fmt.Fprint(w, settings)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	_, changed, err := calnexAPI.FetchSettingsIfChanged()
+	require.NoError(t, err)
+	require.True(t, changed)
+	_, changed, err = calnexAPI.FetchSettingsIfChanged()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	settings = "[measure]\nch0\\used=No\n"
+	f, changed, err := calnexAPI.FetchSettingsIfChanged()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "No", f.Section("measure").Key("ch0\\used").String())
+}
@@ -0,0 +1,100 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Connection reuse defaults of the API transport
+const (
+	DefaultMaxIdleConnsPerHost = 4
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// ConnOptions tune connections of the API to the device
+type ConnOptions struct {
+	// KeepAlive enables reuse of connections between requests
+	KeepAlive bool
+	// HTTP2 negotiates HTTP/2 with devices supporting it
+	HTTP2 bool
+	// CloseFirmwareConns closes connections of firmware upload and version requests even with keep-alives enabled,
+	// for firmware versions misbehaving with keep-alives on these endpoints
+	CloseFirmwareConns bool
+}
+
+// DefaultConnOptions are applied to every API created by NewAPI, so command line tools can set them from flags
+var DefaultConnOptions = ConnOptions{KeepAlive: true, CloseFirmwareConns: true}
+
+// maxDrainBytes limits unread response body discarded on close to keep the connection reusable,
+// same as newer net/http versions drain on their own
+const maxDrainBytes = 256 << 10
+
+// newTransport returns transport reusing connections to the device between requests
+func newTransport(insecureTLS bool) *http.Transport {
+	return &http.Transport{
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: insecureTLS},
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     DefaultIdleConnTimeout,
+	}
+}
+
+// closeBody discards the rest of the response body and closes it.
+// Connection is only returned to the idle pool if the body is read to the end
+func closeBody(body io.ReadCloser) error {
+	_, _ = io.CopyN(ioutil.Discard, body, maxDrainBytes)
+	return body.Close()
+}
+
+// SetConnOptions applies connection options to the API. Must be called before the first request
+func (a *API) SetConnOptions(o ConnOptions) {
+	a.SetKeepAlive(o.KeepAlive)
+	a.SetHTTP2(o.HTTP2)
+	a.CloseFirmwareConns = o.CloseFirmwareConns
+}
+
+// SetIdleConns sets how many idle connections to the device are kept and for how long
+func (a *API) SetIdleConns(perHost int, timeout time.Duration) {
+	if t := a.transport(); t != nil {
+		t.MaxIdleConnsPerHost = perHost
+		t.IdleConnTimeout = timeout
+	}
+}
+
+// SetKeepAlive enables or disables reuse of connections between requests.
+// Disable it for firmware versions misbehaving with keep-alives, so every request uses a new connection
+func (a *API) SetKeepAlive(enabled bool) {
+	if t := a.transport(); t != nil {
+		t.DisableKeepAlives = !enabled
+		if !enabled {
+			t.CloseIdleConnections()
+		}
+	}
+}
+
+// SetHTTP2 makes API negotiate HTTP/2 with devices supporting it,
+// multiplexing requests over a single connection. Must be called before the first request
+func (a *API) SetHTTP2(enabled bool) {
+	if t := a.transport(); t != nil {
+		t.ForceAttemptHTTP2 = enabled
+	}
+}
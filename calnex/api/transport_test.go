@@ -0,0 +1,142 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newConnCountingServer returns TLS server replying with status JSON and counting new connections
+func newConnCountingServer(http2 bool) (*httptest.Server, func() int, func() int) {
+	var mux sync.Mutex
+	conns, proto := 0, 0
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		proto = r.ProtoMajor
+		mux.Unlock()
+		// trailing whitespace is left unread by JSON decoder
+		fmt.Fprintln(w, `{"referenceReady": true, "modulesReady": true, "measurementActive": true}`+strings.Repeat(" ", 8192))
+	}))
+	ts.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			mux.Lock()
+			conns++
+			mux.Unlock()
+		}
+	}
+	ts.EnableHTTP2 = http2
+	ts.StartTLS()
+	get := func(v *int) func() int {
+		return func() int {
+			mux.Lock()
+			defer mux.Unlock()
+			return *v
+		}
+	}
+	return ts, get(&conns), get(&proto)
+}
+
+func TestConnectionReuse(t *testing.T) {
+	ts, conns, _ := newConnCountingServer(false)
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	for i := 0; i < 3; i++ {
+		_, err := calnexAPI.FetchStatus()
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, conns())
+}
+
+func TestSetKeepAlive(t *testing.T) {
+	ts, conns, _ := newConnCountingServer(false)
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.SetMetrics(nil)
+	calnexAPI.SetKeepAlive(false)
+	for i := 0; i < 3; i++ {
+		_, err := calnexAPI.FetchStatus()
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, conns())
+}
+
+func TestSetHTTP2(t *testing.T) {
+	ts, _, proto := newConnCountingServer(true)
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	_, err := calnexAPI.FetchStatus()
+	require.NoError(t, err)
+	require.Equal(t, 1, proto())
+
+	calnexAPI = NewAPI(parsed.Host, true)
+	calnexAPI.SetHTTP2(true)
+	_, err = calnexAPI.FetchStatus()
+	require.NoError(t, err)
+	require.Equal(t, 2, proto())
+}
+
+func TestSetIdleConns(t *testing.T) {
+	calnexAPI := NewAPI("localhost", true)
+	calnexAPI.SetIdleConns(1, time.Second)
+	require.Equal(t, 1, calnexAPI.transport().MaxIdleConnsPerHost)
+	require.Equal(t, time.Second, calnexAPI.transport().IdleConnTimeout)
+}
+
+func TestCloseFirmwareConns(t *testing.T) {
+	for _, close := range []bool{true, false} {
+		ts, conns, _ := newConnCountingServer(false)
+		parsed, _ := url.Parse(ts.URL)
+		calnexAPI := NewAPI(parsed.Host, true)
+		require.True(t, calnexAPI.CloseFirmwareConns)
+		calnexAPI.CloseFirmwareConns = close
+		for i := 0; i < 3; i++ {
+			_, err := calnexAPI.FetchVersion()
+			require.NoError(t, err)
+		}
+		expected := 1
+		if close {
+			expected = 3
+		}
+		require.Equal(t, expected, conns(), close)
+		ts.Close()
+	}
+}
+
+func TestDefaultConnOptions(t *testing.T) {
+	defer func(o ConnOptions) { DefaultConnOptions = o }(DefaultConnOptions)
+	DefaultConnOptions = ConnOptions{HTTP2: true}
+	calnexAPI := NewAPI("localhost", true)
+	require.True(t, calnexAPI.transport().DisableKeepAlives)
+	require.True(t, calnexAPI.transport().ForceAttemptHTTP2)
+	require.False(t, calnexAPI.CloseFirmwareConns)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	getUsersURL       = "https://%s/api/getusers"
+	addUserURL        = "https://%s/api/adduser"
+	deleteUserURL     = "https://%s/api/deleteuser"
+	changePasswordURL = "https://%s/api/changepassword"
+)
+
+// Role is a Calnex user role
+type Role string
+
+// Calnex user roles
+const (
+	RoleAdmin    Role = "admin"
+	RoleOperator Role = "operator"
+	RoleViewer   Role = "viewer"
+)
+
+var (
+	errEmptyUsername = errors.New("username must not be empty")
+	errEmptyPassword = errors.New("password must not be empty")
+	errBadRole       = errors.New("role is not recognized")
+)
+
+// User is a Calnex device user
+type User struct {
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+}
+
+// users is a struct representing Calnex users JSON response
+type users struct {
+	Users []User `json:"users"`
+}
+
+// userRequest is a body of user management requests
+type userRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password,omitempty"`
+	OldPassword string `json:"old_password,omitempty"`
+	Role        Role   `json:"role,omitempty"`
+}
+
+// RoleFromString returns Role from its name
+func RoleFromString(value string) (Role, error) {
+	switch r := Role(value); r {
+	case RoleAdmin, RoleOperator, RoleViewer:
+		return r, nil
+	}
+	return "", fmt.Errorf("%w: %q", errBadRole, value)
+}
+
+func (a *API) postJSON(path string, v interface{}) error {
+	return a.write(func() error {
+		return a.doPostJSON(path, v)
+	})
+}
+
+func (a *API) doPostJSON(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := a.Client.Post(fmt.Sprintf(path, a.source), "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+
+	r := &Result{}
+	if err = json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return err
+	}
+
+	if !r.Result {
+		return resultError(resp.StatusCode, r)
+	}
+	return nil
+}
+
+// FetchUsers returns users of the device
+func (a *API) FetchUsers() ([]User, error) {
+	url := fmt.Sprintf(getUsersURL, a.source)
+	resp, err := a.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	u := &users{}
+	if err = json.NewDecoder(resp.Body).Decode(u); err != nil {
+		return nil, err
+	}
+	return u.Users, nil
+}
+
+// AddUser creates a new user on the device
+func (a *API) AddUser(username, password string, role Role) error {
+	if username == "" {
+		return errEmptyUsername
+	}
+	if password == "" {
+		return errEmptyPassword
+	}
+	if _, err := RoleFromString(string(role)); err != nil {
+		return err
+	}
+	return a.postJSON(addUserURL, &userRequest{Username: username, Password: password, Role: role})
+}
+
+// DeleteUser removes the user from the device
+func (a *API) DeleteUser(username string) error {
+	if username == "" {
+		return errEmptyUsername
+	}
+	return a.postJSON(deleteUserURL, &userRequest{Username: username})
+}
+
+// ChangePassword changes password of the user
+func (a *API) ChangePassword(username, oldPassword, newPassword string) error {
+	if username == "" {
+		return errEmptyUsername
+	}
+	if newPassword == "" {
+		return errEmptyPassword
+	}
+	return a.postJSON(changePasswordURL, &userRequest{Username: username, OldPassword: oldPassword, Password: newPassword})
+}
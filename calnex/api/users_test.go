@@ -0,0 +1,84 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchUsers(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.Contains(r.URL.Path, "getusers"))
+		fmt.Fprintln(w, `{"users": [{"username": "admin", "role": "admin"}, {"username": "monitor", "role": "viewer"}]}`)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.Client = ts.Client()
+
+	u, err := calnexAPI.FetchUsers()
+	require.NoError(t, err)
+	require.Equal(t, []User{{Username: "admin", Role: RoleAdmin}, {Username: "monitor", Role: RoleViewer}}, u)
+}
+
+func TestUserManagement(t *testing.T) {
+	requests := map[string]userRequest{}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req userRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests[r.URL.Path] = req
+		if req.Username == "missing" {
+			fmt.Fprintln(w, `{"result": false, "message": "no such user"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"result": true}`)
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	calnexAPI := NewAPI(parsed.Host, true)
+	calnexAPI.WriteInterval = 0
+	calnexAPI.Client = ts.Client()
+
+	require.NoError(t, calnexAPI.AddUser("ops", "secret", RoleOperator))
+	require.NoError(t, calnexAPI.ChangePassword("admin", "calnex", "n3w"))
+	require.NoError(t, calnexAPI.DeleteUser("ops"))
+	require.Equal(t, map[string]userRequest{
+		"/api/adduser":        {Username: "ops", Password: "secret", Role: RoleOperator},
+		"/api/changepassword": {Username: "admin", OldPassword: "calnex", Password: "n3w"},
+		"/api/deleteuser":     {Username: "ops"},
+	}, requests)
+
+	require.EqualError(t, calnexAPI.DeleteUser("missing"), "no such user")
+
+	require.ErrorIs(t, calnexAPI.AddUser("", "secret", RoleAdmin), errEmptyUsername)
+	require.ErrorIs(t, calnexAPI.AddUser("ops", "", RoleAdmin), errEmptyPassword)
+	require.ErrorIs(t, calnexAPI.AddUser("ops", "secret", "root"), errBadRole)
+	require.ErrorIs(t, calnexAPI.DeleteUser(""), errEmptyUsername)
+	require.ErrorIs(t, calnexAPI.ChangePassword("admin", "calnex", ""), errEmptyPassword)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package campaign
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/facebook/time/calnex/api"
+	"github.com/facebook/time/calnex/compare"
+	"github.com/facebook/time/calnex/export"
+	log "github.com/sirupsen/logrus"
+)
+
+// bundleWriter writes entries of a device as JSON lines and keeps them for the report
+type bundleWriter struct {
+	json    *export.JSONWriter
+	entries []*export.Entry
+}
+
+func (b *bundleWriter) Write(entry *export.Entry) error {
+	b.entries = append(b.entries, entry)
+	return b.json.Write(entry)
+}
+
+// exportDevice exports the window of a single device into its bundle file
+func (r *Runner) exportDevice(target string, channels []api.Channel, w export.Window, res *DeviceResult) ([]*export.Entry, error) {
+	res.File = target + ".json"
+	f, err := os.Create(filepath.Join(r.Dir, res.File))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b := &bundleWriter{json: &export.JSONWriter{Output: f}}
+	err = r.Controller.Export(target, channels, w, b)
+	res.Samples = len(b.entries)
+	return b.entries, err
+}
+
+// export exports all devices one by one and compares them. Failed devices are recorded in results
+func (r *Runner) export(c *Campaign, targets []string, w export.Window, results map[string]*DeviceResult) (*compare.Report, error) {
+	var entries []*export.Entry
+	for _, t := range targets {
+		e, err := r.exportDevice(t, c.Channels, w, results[t])
+		if err != nil {
+			log.Errorf("failed to export %s: %v", t, err)
+			results[t].Error = err.Error()
+			continue
+		}
+		entries = append(entries, e...)
+	}
+	if len(entries) == 0 {
+		return nil, errNothingExport
+	}
+	return compare.Compare(entries, c.Align)
+}
+
+// writeReport writes the report as JSON and HTML
+func (r *Runner) writeReport(report *compare.Report) error {
+	if err := writeJSON(filepath.Join(r.Dir, ReportJSONFile), report); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(r.Dir, ReportHTMLFile))
+	if err != nil {
+		return err
+	}
+	if err := report.WriteHTML(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
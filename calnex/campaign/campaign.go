@@ -0,0 +1,322 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package campaign implements coordinated measurement campaigns across multiple
+Calnex devices. A campaign configures all devices, starts measurements at the
+same instant, runs for a duration, stops, exports the measured window and
+produces a comparison report. Everything lands in a single bundle directory.
+*/
+package campaign
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/facebook/time/calnex/api"
+	"github.com/facebook/time/calnex/config"
+	"github.com/facebook/time/calnex/export"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultLead is the time between the campaign launch and the measurement start
+// unless the start is set explicitly. It must be enough to configure all devices
+const DefaultLead = time.Minute
+
+// measureMargin keeps devices measuring a bit longer than the campaign so they don't stop on their own first
+const measureMargin = time.Minute
+
+// Bundle file names
+const (
+	ManifestFile   = "campaign.json"
+	ReportJSONFile = "report.json"
+	ReportHTMLFile = "report.html"
+)
+
+var (
+	errNoDevices     = errors.New("no devices in the campaign")
+	errNoDuration    = errors.New("campaign duration must be positive")
+	errBadAlign      = errors.New("align must be positive")
+	errStartPassed   = errors.New("campaign start has passed before devices were ready")
+	errNothingExport = errors.New("no device exported any data")
+)
+
+// Device is the configuration of a single Calnex taking part in the campaign.
+// Measurement settings are controlled by the campaign
+type Device struct {
+	Calnex        config.CalnexConfig   `json:"calnex"`
+	Network       *config.NetworkConfig `json:"network,omitempty"`
+	Notifications *api.Notifications    `json:"notifications,omitempty"`
+}
+
+// Campaign is a coordinated measurement on multiple devices
+type Campaign struct {
+	Name string `json:"name"`
+	// Start of the measurement. Zero means DefaultLead after the launch
+	Start    time.Time           `json:"start"`
+	Duration api.MeasureDuration `json:"duration"`
+	// Channels to export. Empty means all used channels
+	Channels []api.Channel `json:"channels,omitempty"`
+	// Align samples into slots of this many seconds when comparing devices
+	Align   int               `json:"align"`
+	Devices map[string]Device `json:"devices"`
+	// Reference is validated on every device when it's configured, before the coordinated start
+	Reference *api.ReferenceGuard `json:"reference,omitempty"`
+}
+
+// Read reads campaign from the JSON file
+func Read(path string) (*Campaign, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Campaign{Align: 1}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the campaign
+func (c *Campaign) Validate() error {
+	if len(c.Devices) == 0 {
+		return errNoDevices
+	}
+	if c.Duration <= 0 {
+		return errNoDuration
+	}
+	if c.Align <= 0 {
+		return errBadAlign
+	}
+	return nil
+}
+
+// Targets returns sorted device names
+func (c *Campaign) Targets() []string {
+	targets := make([]string, 0, len(c.Devices))
+	for t := range c.Devices {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// measureSettings returns measurement settings pushed to every device
+func (c *Campaign) measureSettings() *api.MeasureSettings {
+	return &api.MeasureSettings{Duration: c.Duration + api.MeasureDuration(measureMargin), Continuous: false, Reference: c.Reference}
+}
+
+// Controller performs campaign steps on a device
+type Controller interface {
+	Configure(target string, d Device, m *api.MeasureSettings) error
+	Start(target string) error
+	Stop(target string) error
+	Export(target string, channels []api.Channel, w export.Window, output export.EntryWriter) error
+}
+
+// CalnexController controls devices via Calnex API
+type CalnexController struct {
+	InsecureTLS bool
+	// MaxClockOffset of the device clock when exporting. 0 disables the check
+	MaxClockOffset time.Duration
+}
+
+// Configure applies device config. Calnex starts measuring once configured
+func (c *CalnexController) Configure(target string, d Device, m *api.MeasureSettings) error {
+	return config.Config(target, c.InsecureTLS, d.Network, d.Calnex, m, d.Notifications, true)
+}
+
+// Start starts measurement
+func (c *CalnexController) Start(target string) error {
+	return api.NewAPI(target, c.InsecureTLS).StartMeasure()
+}
+
+// Stop stops measurement
+func (c *CalnexController) Stop(target string) error {
+	return api.NewAPI(target, c.InsecureTLS).StopMeasure()
+}
+
+// Export exports samples of the window
+func (c *CalnexController) Export(target string, channels []api.Channel, w export.Window, output export.EntryWriter) error {
+	return export.ExportWindow(target, c.InsecureTLS, channels, w, c.MaxClockOffset, output)
+}
+
+// DeviceResult is the outcome of the campaign on a single device
+type DeviceResult struct {
+	Target string `json:"target"`
+	// Started is when the start request completed
+	Started time.Time `json:"started"`
+	File    string    `json:"file,omitempty"`
+	Samples int       `json:"samples"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Manifest describes the bundle
+type Manifest struct {
+	Campaign *Campaign      `json:"campaign"`
+	Window   export.Window  `json:"window"`
+	Devices  []DeviceResult `json:"devices"`
+}
+
+// Runner runs the campaign
+type Runner struct {
+	Controller Controller
+	// Dir is the bundle directory
+	Dir string
+	// now and sleep are replaced in tests
+	now   func() time.Time
+	sleep func(ctx context.Context, until time.Time) error
+}
+
+// NewRunner returns a runner writing the bundle to dir
+func NewRunner(controller Controller, dir string) *Runner {
+	return &Runner{Controller: controller, Dir: dir, now: time.Now, sleep: sleepUntil}
+}
+
+func sleepUntil(ctx context.Context, until time.Time) error {
+	t := time.NewTimer(time.Until(until))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// parallel runs f for every target concurrently and returns errors by target
+func parallel(targets []string, f func(target string) error) map[string]error {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	errs := map[string]error{}
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t string) {
+			defer wg.Done()
+			if err := f(t); err != nil {
+				lock.Lock()
+				errs[t] = err
+				lock.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+	return errs
+}
+
+// joinErrors returns a single error listing failed targets
+func joinErrors(step string, errs map[string]error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(errs))
+	for t, err := range errs {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", t, err))
+	}
+	sort.Strings(msgs)
+	return fmt.Errorf("failed to %s: %s", step, strings.Join(msgs, "; "))
+}
+
+// stop stops measurement on targets ignoring failures
+func (r *Runner) stop(targets []string) {
+	for t, err := range parallel(targets, r.Controller.Stop) {
+		log.Errorf("failed to stop %s: %v", t, err)
+	}
+}
+
+// Run runs the campaign and writes the bundle. It returns the manifest written to the bundle
+func (r *Runner) Run(ctx context.Context, c *Campaign) (*Manifest, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return nil, err
+	}
+	targets := c.Targets()
+	start := c.Start
+	if start.IsZero() {
+		start = r.now().Add(DefaultLead).Truncate(time.Second)
+	}
+	w := export.Window{Start: start, End: start.Add(c.Duration.Duration())}
+	log.Infof("campaign %q: %d devices, window %s", c.Name, len(targets), w)
+
+	m := c.measureSettings()
+	err := joinErrors("configure", parallel(targets, func(t string) error {
+		return r.Controller.Configure(t, c.Devices[t], m)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	// configuration starts measurements at random times, restart them together
+	if err := joinErrors("stop", parallel(targets, r.Controller.Stop)); err != nil {
+		return nil, err
+	}
+	if !r.now().Before(start) {
+		return nil, fmt.Errorf("%w: %s", errStartPassed, start.UTC().Format(time.RFC3339))
+	}
+	if err := r.sleep(ctx, start); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*DeviceResult, len(targets))
+	for _, t := range targets {
+		results[t] = &DeviceResult{Target: t}
+	}
+	var lock sync.Mutex
+	errs := parallel(targets, func(t string) error {
+		if err := r.Controller.Start(t); err != nil {
+			return err
+		}
+		lock.Lock()
+		results[t].Started = r.now()
+		lock.Unlock()
+		return nil
+	})
+	if err := joinErrors("start", errs); err != nil {
+		r.stop(targets)
+		return nil, err
+	}
+	log.Infof("measuring until %s", w.End.UTC().Format(time.RFC3339))
+
+	// stop even if cancelled, devices aren't left measuring after the campaign
+	err = r.sleep(ctx, w.End)
+	r.stop(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Campaign: c, Window: w}
+	report, err := r.export(c, targets, w, results)
+	for _, t := range targets {
+		manifest.Devices = append(manifest.Devices, *results[t])
+	}
+	if werr := writeJSON(filepath.Join(r.Dir, ManifestFile), manifest); werr != nil {
+		return nil, werr
+	}
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, r.writeReport(report)
+}
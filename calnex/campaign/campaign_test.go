@@ -0,0 +1,238 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package campaign
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebook/time/calnex/api"
+	"github.com/facebook/time/calnex/export"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeController struct {
+	sync.Mutex
+	steps     []string
+	measure   *api.MeasureSettings
+	failStart string
+	failExp   string
+}
+
+func (f *fakeController) record(step, target string) {
+	f.Lock()
+	defer f.Unlock()
+	f.steps = append(f.steps, step+" "+target)
+}
+
+func (f *fakeController) Configure(target string, d Device, m *api.MeasureSettings) error {
+	f.record("configure", target)
+	f.measure = m
+	return nil
+}
+
+func (f *fakeController) Start(target string) error {
+	f.record("start", target)
+	if target == f.failStart {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func (f *fakeController) Stop(target string) error {
+	f.record("stop", target)
+	return nil
+}
+
+func (f *fakeController) Export(target string, channels []api.Channel, w export.Window, output export.EntryWriter) error {
+	f.record("export", target)
+	if target == f.failExp {
+		return errors.New("boom")
+	}
+	for i := int(w.Start.Unix()); i < int(w.End.Unix()); i++ {
+		e := &export.Entry{
+			Float:  &export.FloatData{Value: 0.000001},
+			Int:    &export.IntData{Time: i},
+			Normal: &export.NormalData{Channel: "1", Target: "ntp01", Protocol: "ntp", Source: target},
+		}
+		if err := output.Write(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// phases returns steps grouped by two and sorted within each phase, as devices are handled concurrently
+func (f *fakeController) phases() [][]string {
+	res := [][]string{}
+	for i := 0; i < len(f.steps); i += 2 {
+		p := []string{f.steps[i], f.steps[i+1]}
+		sort.Strings(p)
+		res = append(res, p)
+	}
+	return res
+}
+
+func testRunner(t *testing.T, f *fakeController) (*Runner, *[]time.Time) {
+	dir, err := ioutil.TempDir("", "campaign")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	r := NewRunner(f, dir)
+	now := time.Unix(1607961000, 0)
+	r.now = func() time.Time { return now }
+	slept := []time.Time{}
+	r.sleep = func(ctx context.Context, until time.Time) error {
+		slept = append(slept, until)
+		now = until
+		return ctx.Err()
+	}
+	return r, &slept
+}
+
+func testCampaign() *Campaign {
+	return &Campaign{
+		Name:     "lab",
+		Duration: api.MeasureDuration(time.Minute),
+		Align:    1,
+		Devices:  map[string]Device{"calnex02": {}, "calnex01": {}},
+	}
+}
+
+func TestRead(t *testing.T) {
+	f, err := ioutil.TempFile("", "campaign")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"name": "lab", "duration": "1h", "start": "2020-12-14T16:00:00Z", "channels": ["1"], "devices": {"calnex01": {"calnex": {"1": {"target": "fd00::d", "probe": "ntp"}}}}}`)
+	require.NoError(t, err)
+	f.Close()
+
+	c, err := Read(f.Name())
+	require.NoError(t, err)
+	require.NoError(t, c.Validate())
+	require.Equal(t, api.MeasureDuration(time.Hour), c.Duration)
+	require.Equal(t, 1, c.Align)
+	require.Equal(t, []api.Channel{api.ChannelONE}, c.Channels)
+	require.Equal(t, time.Date(2020, 12, 14, 16, 0, 0, 0, time.UTC), c.Start)
+	require.Equal(t, []string{"calnex01"}, c.Targets())
+}
+
+func TestValidate(t *testing.T) {
+	c := testCampaign()
+	require.NoError(t, c.Validate())
+
+	c.Align = 0
+	require.ErrorIs(t, c.Validate(), errBadAlign)
+	c.Duration = 0
+	require.ErrorIs(t, c.Validate(), errNoDuration)
+	c.Devices = nil
+	require.ErrorIs(t, c.Validate(), errNoDevices)
+}
+
+func TestRunReference(t *testing.T) {
+	f := &fakeController{}
+	r, _ := testRunner(t, f)
+	c := testCampaign()
+	c.Reference = &api.ReferenceGuard{Wait: api.MeasureDuration(time.Minute), GNSS: true}
+
+	_, err := r.Run(context.Background(), c)
+	require.NoError(t, err)
+	require.Equal(t, c.Reference, f.measure.Reference)
+}
+
+func TestRun(t *testing.T) {
+	f := &fakeController{}
+	r, slept := testRunner(t, f)
+	c := testCampaign()
+
+	m, err := r.Run(context.Background(), c)
+	require.NoError(t, err)
+
+	start := time.Unix(1607961000, 0).Add(DefaultLead)
+	require.Equal(t, []time.Time{start, start.Add(time.Minute)}, *slept)
+	require.Equal(t, export.Window{Start: start, End: start.Add(time.Minute)}, m.Window)
+	require.Equal(t, &api.MeasureSettings{Duration: api.MeasureDuration(2 * time.Minute)}, f.measure)
+	require.Equal(t, [][]string{
+		{"configure calnex01", "configure calnex02"},
+		{"stop calnex01", "stop calnex02"},
+		{"start calnex01", "start calnex02"},
+		{"stop calnex01", "stop calnex02"},
+		{"export calnex01", "export calnex02"},
+	}, f.phases())
+	require.Equal(t, []DeviceResult{
+		{Target: "calnex01", Started: start, File: "calnex01.json", Samples: 60},
+		{Target: "calnex02", Started: start, File: "calnex02.json", Samples: 60},
+	}, m.Devices)
+
+	for _, name := range []string{ManifestFile, ReportJSONFile, ReportHTMLFile, "calnex01.json", "calnex02.json"} {
+		_, err := os.Stat(filepath.Join(r.Dir, name))
+		require.NoError(t, err, name)
+	}
+}
+
+func TestRunStartPassed(t *testing.T) {
+	f := &fakeController{}
+	r, _ := testRunner(t, f)
+	c := testCampaign()
+	c.Start = r.now()
+
+	_, err := r.Run(context.Background(), c)
+	require.ErrorIs(t, err, errStartPassed)
+}
+
+func TestRunStartFailure(t *testing.T) {
+	f := &fakeController{failStart: "calnex02"}
+	r, _ := testRunner(t, f)
+
+	_, err := r.Run(context.Background(), testCampaign())
+	require.Error(t, err)
+	// devices which did start are stopped
+	require.Equal(t, []string{"stop calnex01", "stop calnex02"}, f.phases()[3])
+}
+
+func TestRunExportFailure(t *testing.T) {
+	f := &fakeController{failExp: "calnex02"}
+	r, _ := testRunner(t, f)
+
+	m, err := r.Run(context.Background(), testCampaign())
+	require.NoError(t, err)
+	require.Equal(t, 60, m.Devices[0].Samples)
+	require.Equal(t, "boom", m.Devices[1].Error)
+
+	f = &fakeController{failExp: "calnex01"}
+	r, _ = testRunner(t, f)
+	c := testCampaign()
+	delete(c.Devices, "calnex02")
+	_, err = r.Run(context.Background(), c)
+	require.ErrorIs(t, err, errNothingExport)
+}
+
+func TestRunCancelled(t *testing.T) {
+	f := &fakeController{}
+	r, _ := testRunner(t, f)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := r.Run(ctx, testCampaign())
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,109 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/facebook/time/calnex/api"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(backupCmd)
+	backupCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
+	backupCmd.Flags().StringVar(&target, "target", "", "device to back up")
+	backupCmd.Flags().StringVar(&dir, "dir", "/tmp", "dir to save backup")
+	if err := backupCmd.MarkFlagRequired("target"); err != nil {
+		log.Fatal(err)
+	}
+
+	RootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
+	restoreCmd.Flags().StringVar(&target, "target", "", "device to restore")
+	restoreCmd.Flags().StringVar(&source, "file", "", "backup file path")
+	restoreCmd.Flags().BoolVar(&apply, "apply", false, "push the settings. Dry run if false")
+	if err := restoreCmd.MarkFlagRequired("target"); err != nil {
+		log.Fatal(err)
+	}
+	if err := restoreCmd.MarkFlagRequired("file"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func backup() error {
+	api := api.NewAPI(target, insecureTLS)
+
+	backupFileName, err := api.SaveBackup(dir)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Backup is captured in: %s", backupFileName)
+
+	return nil
+}
+
+func restore() error {
+	b, err := api.ReadBackupFile(source)
+	if err != nil {
+		return err
+	}
+	api := api.NewAPI(target, insecureTLS)
+
+	if b.Source != target {
+		log.Warningf("Backup was taken from %s", b.Source)
+	}
+	v, err := api.FetchVersion()
+	if err != nil {
+		return err
+	}
+	if b.Version != nil && b.Version.Firmware != v.Firmware {
+		log.Warningf("Backup was taken on firmware %s, %s is running %s", b.Version.Firmware, target, v.Firmware)
+	}
+
+	if !apply {
+		log.Infof("dry run. Exiting")
+		return nil
+	}
+	if err := api.Restore(b); err != nil {
+		return err
+	}
+
+	log.Infof("Settings from %s taken at %s are restored", source, b.Time)
+
+	return nil
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "save settings, version, reference status and instrument information of the device",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := backup(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "push settings from the backup to the device",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := restore(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -0,0 +1,65 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/facebook/time/calnex/campaign"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	campaignFile           string
+	campaignDir            string
+	campaignMaxClockOffset time.Duration
+)
+
+func init() {
+	RootCmd.AddCommand(campaignCmd)
+	campaignCmd.Flags().StringVar(&campaignFile, "file", "", "campaign file")
+	campaignCmd.Flags().StringVar(&campaignDir, "dir", ".", "bundle directory to write exported data and reports to")
+	campaignCmd.Flags().DurationVar(&campaignMaxClockOffset, "max-clock-offset", 5*time.Second, "max offset of the device clock when exporting. 0 to skip the check")
+	campaignCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
+	if err := campaignCmd.MarkFlagRequired("file"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+var campaignCmd = &cobra.Command{
+	Use:   "campaign",
+	Short: "configure, measure, export and compare multiple devices in one go",
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := campaign.Read(campaignFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		controller := &campaign.CalnexController{InsecureTLS: insecureTLS, MaxClockOffset: campaignMaxClockOffset}
+		if _, err := campaign.NewRunner(controller, campaignDir).Run(ctx, c); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("bundle is written to %s", campaignDir)
+	},
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"time"
+
 	"github.com/facebook/time/calnex/api"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -26,6 +29,8 @@ func init() {
 	RootCmd.AddCommand(clearCmd)
 	clearCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
 	clearCmd.Flags().StringVar(&target, "target", "", "device to configure")
+	clearCmd.Flags().BoolVar(&wait, "wait", false, "wait for the device to reboot")
+	clearCmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "how long to wait for the device to reboot")
 	if err := clearCmd.MarkFlagRequired("target"); err != nil {
 		log.Fatal(err)
 	}
@@ -34,11 +39,20 @@ func init() {
 func clear() error {
 	api := api.NewAPI(target, insecureTLS)
 
-	if err := api.ClearDevice(); err != nil {
-		return err
+	if !wait {
+		if err := api.ClearDevice(); err != nil {
+			return err
+		}
+		log.Infof("Device data cleared. The device will now reboot.")
+		return nil
 	}
 
-	log.Infof("Device data cleared. The device will now reboot.")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := api.ClearDeviceAndWait(ctx); err != nil {
+		return err
+	}
+	log.Infof("Device data cleared. The device is ready.")
 
 	return nil
 }
@@ -17,6 +17,10 @@ limitations under the License.
 package cmd
 
 import (
+	"time"
+
+	"github.com/facebook/time/calnex/api"
+	"github.com/facebook/time/cliconfig"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -24,6 +28,19 @@ import (
 var RootCmd = &cobra.Command{
 	Use:   "calnex",
 	Short: "collection of calnex utilities",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return flagConfig.Apply(cliconfig.PFlags(cmd.Flags()))
+	},
+}
+
+// flagConfig reads flags not set on the command line from CALNEX_<FLAG> environment variables and the flag file
+var flagConfig = cliconfig.Config{EnvPrefix: "CALNEX"}
+
+func init() {
+	RootCmd.PersistentFlags().StringVar(&flagConfig.File, cliconfig.FlagFile, "", "Yaml file with flag values. Flags are also read from CALNEX_<FLAG> environment variables")
+	RootCmd.PersistentFlags().BoolVar(&api.DefaultConnOptions.KeepAlive, "keepalive", api.DefaultConnOptions.KeepAlive, "Reuse connections to the device between requests")
+	RootCmd.PersistentFlags().BoolVar(&api.DefaultConnOptions.HTTP2, "http2", api.DefaultConnOptions.HTTP2, "Negotiate HTTP/2 with devices supporting it")
+	RootCmd.PersistentFlags().BoolVar(&api.DefaultConnOptions.CloseFirmwareConns, "closefirmwareconns", api.DefaultConnOptions.CloseFirmwareConns, "Close connections after firmware upload and version requests, for firmware misbehaving with keep-alives")
 }
 
 var (
@@ -33,6 +50,8 @@ var (
 	dir         string
 	source      string
 	target      string
+	timeout     time.Duration
+	wait        bool
 )
 
 // Execute is the main entry point for CLI interface
@@ -0,0 +1,82 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/facebook/time/calnex/compare"
+	"github.com/facebook/time/calnex/export"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareFiles  []string
+	compareAlign  int
+	compareFormat string
+)
+
+func init() {
+	RootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().StringArrayVar(&compareFiles, "file", []string{}, "file produced by export. Repeat for multiple devices")
+	compareCmd.Flags().IntVar(&compareAlign, "align", 1, "align samples into slots of this many seconds")
+	compareCmd.Flags().StringVar(&compareFormat, "format", "json", "output format: json or html")
+	if err := compareCmd.MarkFlagRequired("file"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func compareDevices() error {
+	var entries []*export.Entry
+	for _, name := range compareFiles {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		e, err := compare.ReadEntries(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		entries = append(entries, e...)
+	}
+
+	r, err := compare.Compare(entries, compareAlign)
+	if err != nil {
+		return err
+	}
+
+	switch compareFormat {
+	case "json":
+		return r.WriteJSON(os.Stdout)
+	case "html":
+		return r.WriteHTML(os.Stdout)
+	}
+	return fmt.Errorf("unsupported format %q", compareFormat)
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "compare measurements of the same targets exported from multiple devices",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := compareDevices(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -0,0 +1,80 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/facebook/time/calnex/firmware"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	complianceTargets []string
+	compliancePolicy  string
+	complianceStage   int
+)
+
+func init() {
+	RootCmd.AddCommand(complianceCmd)
+	complianceCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
+	complianceCmd.Flags().StringArrayVar(&complianceTargets, "target", []string{}, "device to check. Repeat for multiple devices")
+	complianceCmd.Flags().StringVar(&compliancePolicy, "policy", "", "yaml file with allowed firmware versions")
+	complianceCmd.Flags().StringVar(&source, "file", "", "firmware file path to upgrade non-compliant devices with. Report only if empty")
+	complianceCmd.Flags().IntVar(&complianceStage, "stage-size", 1, "how many devices to upgrade at once. Next stage starts after the previous one succeeded")
+	complianceCmd.Flags().BoolVar(&apply, "apply", false, "apply the firmware upgrade")
+	addVerifyFlags(complianceCmd)
+	if err := complianceCmd.MarkFlagRequired("target"); err != nil {
+		log.Fatal(err)
+	}
+	if err := complianceCmd.MarkFlagRequired("policy"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func compliance() error {
+	p, err := firmware.ReadPolicy(compliancePolicy)
+	if err != nil {
+		return err
+	}
+	r := firmware.Compliance(complianceTargets, insecureTLS, p)
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	if source == "" {
+		return nil
+	}
+	fw, err := firmwareFile(source)
+	if err != nil {
+		return err
+	}
+	return firmware.Remediate(r, insecureTLS, p, fw, complianceStage, apply)
+}
+
+var complianceCmd = &cobra.Command{
+	Use:   "compliance",
+	Short: "check fleet firmware versions against the policy and upgrade non-compliant devices in stages",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := compliance(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
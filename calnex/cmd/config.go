@@ -18,9 +18,11 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 
+	"github.com/facebook/time/calnex/api"
 	"github.com/facebook/time/calnex/config"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -41,38 +43,49 @@ func init() {
 }
 
 type deviceConfig struct {
-	Calnex  config.CalnexConfig
-	Network *config.NetworkConfig
+	Calnex        config.CalnexConfig
+	Network       *config.NetworkConfig
+	Measure       *api.MeasureSettings
+	Notifications *api.Notifications
 }
 
 type devices map[string]deviceConfig
 
+// readDeviceConfig reads config of the target from the config file
+func readDeviceConfig(source, target string) (*deviceConfig, error) {
+	configFile, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer configFile.Close()
+	b, err := ioutil.ReadAll(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var d devices
+	err = json.Unmarshal(b, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, ok := d[target]
+	if !ok {
+		return nil, fmt.Errorf("failed to find config for %s in %s", target, source)
+	}
+	return &dc, nil
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "configure a calnex appliance",
 	Run: func(cmd *cobra.Command, args []string) {
-		configFile, err := os.Open(source)
+		dc, err := readDeviceConfig(source, target)
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer configFile.Close()
-		b, err := ioutil.ReadAll(configFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		var d devices
-		err = json.Unmarshal(b, &d)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		dc, ok := d[target]
-		if !ok {
-			log.Fatalf("Failed to find config for %s in %s", target, source)
-		}
 
-		if err := config.Config(target, insecureTLS, dc.Network, dc.Calnex, apply); err != nil {
+		if err := config.Config(target, insecureTLS, dc.Network, dc.Calnex, dc.Measure, dc.Notifications, apply); err != nil {
 			log.Fatal(err)
 		}
 	},
@@ -18,8 +18,11 @@ package cmd
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/facebook/time/calnex/api"
 	"github.com/facebook/time/calnex/config"
@@ -75,3 +78,60 @@ func TestUnmarshalConfig(t *testing.T) {
 	}
 	require.Equal(t, expected, d)
 }
+
+func TestReadDeviceConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "calnex")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"calnex01.example.com": {"calnex": {"1": {"target": "fd00::d", "probe": "ntp"}}}}`)
+	require.NoError(t, err)
+	f.Close()
+
+	dc, err := readDeviceConfig(f.Name(), "calnex01.example.com")
+	require.NoError(t, err)
+	require.Equal(t, config.MeasureConfig{Target: "fd00::d", Probe: api.ProbeNTP}, dc.Calnex[api.ChannelONE])
+	require.Nil(t, dc.Measure)
+	require.Nil(t, dc.Calnex[api.ChannelONE].Emulation)
+
+	_, err = readDeviceConfig(f.Name(), "calnex02.example.com")
+	require.Error(t, err)
+}
+
+func TestReadDeviceConfigEmulation(t *testing.T) {
+	f, err := ioutil.TempFile("", "calnex")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"calnex01.example.com": {"calnex": {"2": {"emulation": {"probe": "ptp", "ptp": {"priority1": 128, "clock_class": 6}}}}}}`)
+	require.NoError(t, err)
+	f.Close()
+
+	dc, err := readDeviceConfig(f.Name(), "calnex01.example.com")
+	require.NoError(t, err)
+	require.Equal(t, &api.Emulation{Probe: api.ProbePTP, PTP: &api.PTPMaster{Priority1: 128, ClockClass: 6}}, dc.Calnex[api.ChannelTWO].Emulation)
+}
+
+func TestReadDeviceConfigMeasure(t *testing.T) {
+	f, err := ioutil.TempFile("", "calnex")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"calnex01.example.com": {"measure": {"duration": "6h", "continuous": false}}}`)
+	require.NoError(t, err)
+	f.Close()
+
+	dc, err := readDeviceConfig(f.Name(), "calnex01.example.com")
+	require.NoError(t, err)
+	require.Equal(t, &api.MeasureSettings{Duration: api.MeasureDuration(6 * time.Hour), Continuous: false}, dc.Measure)
+}
+
+func TestReadDeviceConfigNotifications(t *testing.T) {
+	f, err := ioutil.TempFile("", "calnex")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"calnex01.example.com": {"notifications": {"snmp": {"community": "public", "targets": ["fd00::1"]}}}}`)
+	require.NoError(t, err)
+	f.Close()
+
+	dc, err := readDeviceConfig(f.Name(), "calnex01.example.com")
+	require.NoError(t, err)
+	require.Equal(t, &api.Notifications{SNMP: &api.SNMPNotifications{Community: "public", Targets: []string{"fd00::1"}}}, dc.Notifications)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/facebook/time/calnex/config"
+	"github.com/fatih/color"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var jsonOutput bool
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
+	diffCmd.Flags().BoolVar(&jsonOutput, "json", false, "print the diff as json")
+	diffCmd.Flags().StringVar(&target, "target", "", "device to compare")
+	diffCmd.Flags().StringVar(&source, "file", "", "configuration file")
+	if err := diffCmd.MarkFlagRequired("target"); err != nil {
+		log.Fatal(err)
+	}
+	if err := diffCmd.MarkFlagRequired("file"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// printDiff prints changes in a unified-diff like format
+func printDiff(w io.Writer, changes []config.Change) {
+	for _, c := range changes {
+		fmt.Fprintln(w, color.RedString("- %s=%s", c.Key, c.Old))
+		fmt.Fprintln(w, color.GreenString("+ %s=%s", c.Key, c.New))
+	}
+	fmt.Fprintf(w, "%d setting(s) differ\n", len(changes))
+}
+
+func diff() error {
+	dc, err := readDeviceConfig(source, target)
+	if err != nil {
+		return err
+	}
+
+	changes, err := config.Diff(target, insecureTLS, dc.Network, dc.Calnex, dc.Measure, dc.Notifications)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(changes)
+	}
+	printDiff(os.Stdout, changes)
+	return nil
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "show difference between device settings and the configuration file without applying it",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := diff(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
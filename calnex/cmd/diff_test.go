@@ -0,0 +1,33 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/facebook/time/calnex/config"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintDiff(t *testing.T) {
+	color.NoColor = true
+	var b bytes.Buffer
+	printDiff(&b, []config.Change{{Key: "ch6\\used", Old: "No", New: "Yes"}})
+	require.Equal(t, "- ch6\\used=No\n+ ch6\\used=Yes\n1 setting(s) differ\n", b.String())
+}
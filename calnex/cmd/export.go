@@ -17,7 +17,10 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/facebook/time/calnex/api"
 	"github.com/facebook/time/calnex/export"
@@ -25,8 +28,37 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	exportFormat         string
+	exportDir            string
+	exportStart          string
+	exportEnd            string
+	exportWindow         time.Duration
+	exportMaxClockOffset time.Duration
+	exportURL            string
+	exportBatchSize      int
+	exportCompression    string
+	exportRetries        int
+	exportTransform      string
+	exportMaxAbs         float64
+	exportMaintenance    string
+)
+
 func init() {
 	RootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportFormat, "format", "json", "Output format: json, parquet, cat or http")
+	exportCmd.Flags().StringVar(&exportDir, "dir", ".", "Directory to write parquet files partitioned by source/channel/date or CAT csv files per source/channel to")
+	exportCmd.Flags().StringVar(&exportURL, "url", "", "URL to upload batches of JSON lines to with http format")
+	exportCmd.Flags().IntVar(&exportBatchSize, "batch-size", export.DefaultBatchSize, "Entries per upload with http format")
+	exportCmd.Flags().StringVar(&exportCompression, "compression", export.CompressionGzip, "Compression of uploads with http format: gzip or none")
+	exportCmd.Flags().IntVar(&exportRetries, "retries", 3, "Retries of a failed upload with http format")
+	exportCmd.Flags().StringVar(&exportTransform, "transform", "", "Yaml file with per channel or protocol transforms (unit conversion, scale, sign flip, clamping) applied before writing. Disabled if empty")
+	exportCmd.Flags().Float64Var(&exportMaxAbs, "max-abs", 0, "Drop samples with absolute value above this as bogus, in units of transformed values. Disabled if 0")
+	exportCmd.Flags().StringVar(&exportMaintenance, "maintenance", "", "Yaml calendar of maintenance windows per device and channel to drop samples from. Disabled if empty")
+	exportCmd.Flags().StringVar(&exportStart, "start", "", "Export samples taken since this device time in RFC3339 format. Requires --end")
+	exportCmd.Flags().StringVar(&exportEnd, "end", "", "Export samples taken before this device time in RFC3339 format. Requires --start")
+	exportCmd.Flags().DurationVar(&exportWindow, "window", 0, "Export the latest complete window of this duration aligned to it, such as the previous hour for 1h. Overrides --start and --end")
+	exportCmd.Flags().DurationVar(&exportMaxClockOffset, "max-clock-offset", 5*time.Second, "Max offset of the device clock when exporting a time range. 0 to skip the check")
 	exportCmd.Flags().StringArrayVar(&channels, "channel", []string{}, "Channel name. Ex: 1, 2, c ,d. Repeat for multiple. Skip for auto-detection")
 	exportCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
 	exportCmd.Flags().StringVar(&source, "source", "localhost", "Source of the data. Ex: calnex01.example.com")
@@ -47,8 +79,102 @@ var exportCmd = &cobra.Command{
 			}
 			chs = append(chs, *c)
 		}
-		if err := export.Export(source, insecureTLS, chs, os.Stdout); err != nil {
+		var w export.EntryWriter
+		var hw *export.HTTPWriter
+		switch exportFormat {
+		case "json":
+			w = &export.JSONWriter{Output: os.Stdout}
+		case "parquet":
+			w = export.NewParquetWriter(exportDir)
+		case "cat":
+			w = export.NewCATWriter(exportDir)
+		case "http":
+			if exportURL == "" {
+				log.Fatal("--url is required with http format")
+			}
+			hw = export.NewHTTPWriter(exportURL)
+			hw.BatchSize = exportBatchSize
+			hw.Compression = exportCompression
+			hw.Retries = exportRetries
+			w = hw
+		default:
+			log.Fatal(fmt.Errorf("unsupported format %q", exportFormat))
+		}
+		var fw *export.FilterWriter
+		if exportMaxAbs != 0 || exportMaintenance != "" {
+			fw = &export.FilterWriter{Output: w, MaxAbs: exportMaxAbs}
+			if err := fw.Validate(); err != nil {
+				log.Fatal(err)
+			}
+			if exportMaintenance != "" {
+				c, err := export.ReadCalendarFile(exportMaintenance)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fw.Calendar = c
+			}
+			w = fw
+		}
+		var tw *export.TransformWriter
+		if exportTransform != "" {
+			ts, err := export.ReadTransformsFile(exportTransform)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tw = &export.TransformWriter{Output: w, Transforms: ts}
+			w = tw
+		}
+
+		window, err := exportTimeWindow(time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if window != nil {
+			err = export.ExportWindow(source, insecureTLS, chs, *window, exportMaxClockOffset, w)
+		} else {
+			err = export.ExportEntries(source, insecureTLS, chs, w)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if c, ok := w.(io.Closer); ok {
+			err = c.Close()
+		}
+		if tw != nil && tw.Clamped > 0 {
+			log.Warningf("Clamped %d outliers", tw.Clamped)
+		}
+		if fw != nil && fw.Bogus+fw.Maintenance > 0 {
+			log.Warningf("Dropped %d bogus samples and %d samples taken during maintenance", fw.Bogus, fw.Maintenance)
+		}
+		if hw != nil {
+			r := hw.Report()
+			log.Infof("Uploaded %d entries in %d batches", r.Entries-r.FailedEntries, r.Batches-r.FailedBatches)
+		}
+		if err != nil {
 			log.Fatal(err)
 		}
 	},
 }
+
+// exportTimeWindow returns time range to export from flags. Nil means everything since the last export
+func exportTimeWindow(now time.Time) (*export.Window, error) {
+	if exportWindow > 0 {
+		w := export.LastWindow(now, exportWindow)
+		return &w, nil
+	}
+	if exportStart == "" && exportEnd == "" {
+		return nil, nil
+	}
+	if exportStart == "" || exportEnd == "" {
+		return nil, fmt.Errorf("both --start and --end are required")
+	}
+	start, err := time.Parse(time.RFC3339, exportStart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, exportEnd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+	return &export.Window{Start: start, End: end}, nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/facebook/time/calnex/export"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportTimeWindow(t *testing.T) {
+	defer func() {
+		exportStart, exportEnd, exportWindow = "", "", 0
+	}()
+	now := time.Date(2021, 12, 14, 15, 53, 13, 0, time.UTC)
+
+	w, err := exportTimeWindow(now)
+	require.NoError(t, err)
+	require.Nil(t, w)
+
+	exportStart = "2021-12-14T14:00:00Z"
+	_, err = exportTimeWindow(now)
+	require.Error(t, err)
+
+	exportEnd = "2021-12-14T15:00:00Z"
+	w, err = exportTimeWindow(now)
+	require.NoError(t, err)
+	expected := &export.Window{Start: time.Date(2021, 12, 14, 14, 0, 0, 0, time.UTC), End: time.Date(2021, 12, 14, 15, 0, 0, 0, time.UTC)}
+	require.Equal(t, expected, w)
+
+	exportStart, exportEnd = "", ""
+	exportWindow = time.Hour
+	w, err = exportTimeWindow(now)
+	require.NoError(t, err)
+	require.Equal(t, expected.Start.Unix(), w.Start.Unix())
+	require.Equal(t, expected.End.Unix(), w.End.Unix())
+
+	exportWindow = 0
+	exportStart, exportEnd = "yesterday", "2021-12-14T15:00:00Z"
+	_, err = exportTimeWindow(now)
+	require.Error(t, err)
+}
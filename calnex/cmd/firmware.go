@@ -17,17 +17,56 @@ limitations under the License.
 package cmd
 
 import (
+	"io/ioutil"
+
 	"github.com/facebook/time/calnex/firmware"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var (
+	firmwareManifest  string
+	firmwarePublicKey string
+	firmwareSignature string
+)
+
+// addVerifyFlags adds flags of firmware image verification to the command
+func addVerifyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&firmwareManifest, "manifest", "", "sha256sum manifest to check firmware file against before upload. Not checked if empty")
+	cmd.Flags().StringVar(&firmwarePublicKey, "public-key", "", "file with base64 ed25519 public key to verify firmware signature with before upload. Not verified if empty")
+	cmd.Flags().StringVar(&firmwareSignature, "signature", "", "file with base64 detached signature of firmware SHA-256. Firmware file path with .sig suffix if empty")
+}
+
+// firmwareFile returns firmware file checked by verifiers set by flags
+func firmwareFile(path string) (*firmware.OSSFW, error) {
+	fw := &firmware.OSSFW{Filepath: path}
+	if firmwareManifest != "" {
+		fw.Verifiers = append(fw.Verifiers, &firmware.ChecksumVerifier{Manifest: firmwareManifest})
+	}
+	if firmwarePublicKey != "" {
+		b, err := ioutil.ReadFile(firmwarePublicKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := firmware.ParsePublicKey(string(b))
+		if err != nil {
+			return nil, err
+		}
+		fw.Verifiers = append(fw.Verifiers, &firmware.SignatureVerifier{PublicKey: key, Signature: firmwareSignature})
+	}
+	if len(fw.Verifiers) == 0 {
+		log.Warningf("firmware %s will be uploaded without verification", path)
+	}
+	return fw, nil
+}
+
 func init() {
 	RootCmd.AddCommand(firmwareCmd)
 	firmwareCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
 	firmwareCmd.Flags().BoolVar(&apply, "apply", false, "apply the firmware upgrade")
 	firmwareCmd.Flags().StringVar(&target, "target", "", "device to configure")
 	firmwareCmd.Flags().StringVar(&source, "file", "", "firmware file path")
+	addVerifyFlags(firmwareCmd)
 	if err := firmwareCmd.MarkFlagRequired("target"); err != nil {
 		log.Fatal(err)
 	}
@@ -40,8 +79,9 @@ var firmwareCmd = &cobra.Command{
 	Use:   "firmware",
 	Short: "update the device firmware",
 	Run: func(cmd *cobra.Command, args []string) {
-		fw := &firmware.OSSFW{
-			Filepath: source,
+		fw, err := firmwareFile(source)
+		if err != nil {
+			log.Fatal(err)
 		}
 		if err := firmware.Firmware(target, insecureTLS, fw, apply); err != nil {
 			log.Fatal(err)
@@ -0,0 +1,94 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/facebook/time/calnex/api"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	network   api.Network
+	newTarget string
+	rollback  time.Duration
+)
+
+func init() {
+	RootCmd.AddCommand(networkCmd)
+	networkCmd.PersistentFlags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
+	networkCmd.PersistentFlags().StringVar(&target, "target", "", "device to manage network of")
+	if err := networkCmd.MarkPersistentFlagRequired("target"); err != nil {
+		log.Fatal(err)
+	}
+
+	networkCmd.AddCommand(networkShowCmd)
+
+	networkCmd.AddCommand(networkSetCmd)
+	networkSetCmd.Flags().StringVar(&network.Mode, "mode", api.NetworkStatic, "address mode: static or dhcp")
+	networkSetCmd.Flags().StringVar(&network.Address, "address", "", "management IP address")
+	networkSetCmd.Flags().StringVar(&network.Netmask, "netmask", "", "management network mask")
+	networkSetCmd.Flags().StringVar(&network.Gateway, "gateway", "", "default gateway")
+	networkSetCmd.Flags().StringSliceVar(&network.DNS, "dns", nil, "DNS servers. Repeat for multiple")
+	networkSetCmd.Flags().StringVar(&newTarget, "new-target", "", "device name after the change. Defaults to the new address, or target with dhcp")
+	networkSetCmd.Flags().DurationVar(&rollback, "rollback", 2*time.Minute, "device reverts the change unless it's reachable on the new address within this time")
+}
+
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "manage device management network",
+}
+
+var networkShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "show device management network",
+	Run: func(cmd *cobra.Command, args []string) {
+		n, err := api.NewAPI(target, insecureTLS).FetchNetwork()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("mode:\t%s\n", n.Mode)
+		fmt.Printf("address:\t%s\n", n.Address)
+		fmt.Printf("netmask:\t%s\n", n.Netmask)
+		fmt.Printf("gateway:\t%s\n", n.Gateway)
+		fmt.Printf("dns:\t%s\n", strings.Join(n.DNS, ","))
+	},
+}
+
+var networkSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "re-address the device, confirming it's reachable afterwards",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := network.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		if newTarget == "" {
+			newTarget = target
+			if network.Mode == api.NetworkStatic {
+				newTarget = network.Address
+			}
+		}
+		if _, err := api.NewAPI(target, insecureTLS).MoveNetwork(&network, newTarget, rollback); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("%s is reachable as %s", target, newTarget)
+	},
+}
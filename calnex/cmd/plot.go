@@ -0,0 +1,110 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/facebook/time/calnex/compare"
+	"github.com/facebook/time/calnex/export"
+	"github.com/facebook/time/calnex/plot"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	plotFiles  []string
+	plotTitle  string
+	plotSVGDir string
+)
+
+func init() {
+	RootCmd.AddCommand(plotCmd)
+	plotCmd.Flags().StringArrayVar(&plotFiles, "file", []string{}, "file produced by export. Repeat for multiple devices")
+	plotCmd.Flags().StringVar(&plotTitle, "title", "Calnex offset report", "report title")
+	plotCmd.Flags().StringVar(&plotSVGDir, "svg-dir", "", "also write plot and heatmap of every series as SVG files to this directory")
+	if err := plotCmd.MarkFlagRequired("file"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// svgName returns file name of the series plot
+func svgName(s *plot.Series, suffix string) string {
+	name := strings.NewReplacer("/", "_", " ", "_", ":", "_").Replace(s.Name())
+	return name + suffix + ".svg"
+}
+
+func writeSVG(path string, s *plot.Series, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func plotReport() error {
+	var entries []*export.Entry
+	for _, name := range plotFiles {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		e, err := compare.ReadEntries(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		entries = append(entries, e...)
+	}
+	series := plot.SeriesFromEntries(entries)
+
+	if plotSVGDir != "" {
+		for _, s := range series {
+			s := s
+			err := writeSVG(filepath.Join(plotSVGDir, svgName(s, "")), s, func(f *os.File) error {
+				return plot.WriteSVG(f, s, plot.Width, plot.Height)
+			})
+			if err != nil {
+				return err
+			}
+			err = writeSVG(filepath.Join(plotSVGDir, svgName(s, "_heatmap")), s, func(f *os.File) error {
+				return plot.WriteHeatmapSVG(f, s, plot.Width, plot.Height)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return plot.WriteHTML(os.Stdout, plotTitle, series)
+}
+
+var plotCmd = &cobra.Command{
+	Use:   "plot",
+	Short: "render offset plots, heatmaps and percentiles of exported measurements as HTML report",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := plotReport(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"time"
+
 	"github.com/facebook/time/calnex/api"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -27,6 +30,7 @@ func init() {
 	reportCmd.Flags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
 	reportCmd.Flags().StringVar(&target, "target", "", "device to configure")
 	reportCmd.Flags().StringVar(&dir, "dir", "/tmp", "dir to save report")
+	reportCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "how long to wait for the report to be generated")
 	if err := reportCmd.MarkFlagRequired("target"); err != nil {
 		log.Fatal(err)
 	}
@@ -35,7 +39,9 @@ func init() {
 func report() error {
 	api := api.NewAPI(target, insecureTLS)
 
-	reportFileName, err := api.FetchProblemReport(dir)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	reportFileName, err := api.FetchProblemReportAndWait(ctx, dir)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,136 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/facebook/time/calnex/api"
+	"github.com/facebook/time/cliconfig"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	username        string
+	role            string
+	passwordFile    string
+	oldPasswordFile string
+)
+
+func init() {
+	RootCmd.AddCommand(userCmd)
+	userCmd.PersistentFlags().BoolVar(&insecureTLS, "insecureTLS", false, "Ignore TLS certificate errors")
+	userCmd.PersistentFlags().StringVar(&target, "target", "", "device to manage users on")
+	if err := userCmd.MarkPersistentFlagRequired("target"); err != nil {
+		log.Fatal(err)
+	}
+
+	userCmd.AddCommand(userListCmd)
+
+	userCmd.AddCommand(userAddCmd)
+	userAddCmd.Flags().StringVar(&username, "user", "", "user name")
+	userAddCmd.Flags().StringVar(&role, "role", string(api.RoleViewer), "user role: admin, operator or viewer")
+	userAddCmd.Flags().StringVar(&passwordFile, "password-file", "", "file with the password")
+
+	userCmd.AddCommand(userDeleteCmd)
+	userDeleteCmd.Flags().StringVar(&username, "user", "", "user name")
+
+	userCmd.AddCommand(userPasswordCmd)
+	userPasswordCmd.Flags().StringVar(&username, "user", "", "user name")
+	userPasswordCmd.Flags().StringVar(&oldPasswordFile, "old-password-file", "", "file with the current password")
+	userPasswordCmd.Flags().StringVar(&passwordFile, "password-file", "", "file with the new password")
+
+	for _, c := range []*cobra.Command{userAddCmd, userDeleteCmd, userPasswordCmd} {
+		if err := c.MarkFlagRequired("user"); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, c := range []*cobra.Command{userAddCmd, userPasswordCmd} {
+		if err := c.MarkFlagRequired("password-file"); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "manage device users",
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list device users",
+	Run: func(cmd *cobra.Command, args []string) {
+		users, err := api.NewAPI(target, insecureTLS).FetchUsers()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, u := range users {
+			fmt.Printf("%s\t%s\n", u.Username, u.Role)
+		}
+	},
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "create a device user",
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := api.RoleFromString(role)
+		if err != nil {
+			log.Fatal(err)
+		}
+		password, err := cliconfig.ReadSecret(passwordFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := api.NewAPI(target, insecureTLS).AddUser(username, password, r); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("user %s is created", username)
+	},
+}
+
+var userDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "delete a device user",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := api.NewAPI(target, insecureTLS).DeleteUser(username); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("user %s is deleted", username)
+	},
+}
+
+var userPasswordCmd = &cobra.Command{
+	Use:   "passwd",
+	Short: "change password of a device user",
+	Run: func(cmd *cobra.Command, args []string) {
+		oldPassword, err := cliconfig.ReadSecret(oldPasswordFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		password, err := cliconfig.ReadSecret(passwordFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := api.NewAPI(target, insecureTLS).ChangePassword(username, oldPassword, password); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("password of %s is changed", username)
+	},
+}
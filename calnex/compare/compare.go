@@ -0,0 +1,188 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package compare implements comparison of measurements exported from multiple
+Calnex devices measuring the same targets. Samples are aligned by time and
+for every target it reports percentiles per device and disagreement between devices.
+*/
+package compare
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/facebook/time/calnex/export"
+)
+
+var errNoSamples = errors.New("no samples")
+
+// Percentiles of absolute values
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// DeviceStats is a summary of measurements of the target by a single device
+type DeviceStats struct {
+	Source  string      `json:"source"`
+	Samples int         `json:"samples"`
+	Offset  Percentiles `json:"offset"`
+}
+
+// TargetReport is a summary of measurements of a single target by all devices
+type TargetReport struct {
+	Target  string         `json:"target"`
+	Devices []*DeviceStats `json:"devices"`
+	// Aligned is number of time slots measured by at least 2 devices
+	Aligned int `json:"aligned"`
+	// Disagreement is the spread (max - min) of offsets reported by devices in the same time slot
+	Disagreement Percentiles `json:"disagreement"`
+}
+
+// Report is a comparison of all targets
+type Report struct {
+	Sources []string        `json:"sources"`
+	Align   int             `json:"align"`
+	Targets []*TargetReport `json:"targets"`
+}
+
+// ReadEntries reads entries produced by calnex export
+func ReadEntries(r io.Reader) ([]*export.Entry, error) {
+	entries := []*export.Entry{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := &export.Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if e.Float == nil || e.Int == nil || e.Normal == nil {
+			return nil, fmt.Errorf("line %d: incomplete entry", line)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// percentile returns nearest rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// AbsPercentiles returns percentiles of absolute values
+func AbsPercentiles(values []float64) Percentiles {
+	abs := make([]float64, len(values))
+	for i, v := range values {
+		abs[i] = math.Abs(v)
+	}
+	sort.Float64s(abs)
+	return Percentiles{
+		P50: percentile(abs, 50),
+		P90: percentile(abs, 90),
+		P99: percentile(abs, 99),
+		Max: percentile(abs, 100),
+	}
+}
+
+// Compare entries from multiple devices. Samples are aligned into slots of align seconds
+func Compare(entries []*export.Entry, align int) (*Report, error) {
+	if len(entries) == 0 {
+		return nil, errNoSamples
+	}
+	if align < 1 {
+		align = 1
+	}
+
+	// target -> source -> values
+	values := map[string]map[string][]float64{}
+	// target -> slot -> source -> values
+	slots := map[string]map[int]map[string][]float64{}
+	sources := map[string]bool{}
+	for _, e := range entries {
+		target, source := e.Normal.Target, e.Normal.Source
+		sources[source] = true
+		if values[target] == nil {
+			values[target] = map[string][]float64{}
+			slots[target] = map[int]map[string][]float64{}
+		}
+		values[target][source] = append(values[target][source], e.Float.Value)
+		slot := e.Int.Time - e.Int.Time%align
+		if slots[target][slot] == nil {
+			slots[target][slot] = map[string][]float64{}
+		}
+		slots[target][slot][source] = append(slots[target][slot][source], e.Float.Value)
+	}
+
+	r := &Report{Align: align}
+	for s := range sources {
+		r.Sources = append(r.Sources, s)
+	}
+	sort.Strings(r.Sources)
+
+	for target, bySource := range values {
+		tr := &TargetReport{Target: target}
+		for source, v := range bySource {
+			tr.Devices = append(tr.Devices, &DeviceStats{Source: source, Samples: len(v), Offset: AbsPercentiles(v)})
+		}
+		sort.Slice(tr.Devices, func(i, j int) bool { return tr.Devices[i].Source < tr.Devices[j].Source })
+
+		spreads := []float64{}
+		for _, bySlot := range slots[target] {
+			if len(bySlot) < 2 {
+				continue
+			}
+			min, max := math.Inf(1), math.Inf(-1)
+			for _, v := range bySlot {
+				m := mean(v)
+				min = math.Min(min, m)
+				max = math.Max(max, m)
+			}
+			spreads = append(spreads, max-min)
+		}
+		tr.Aligned = len(spreads)
+		tr.Disagreement = AbsPercentiles(spreads)
+		r.Targets = append(r.Targets, tr)
+	}
+	sort.Slice(r.Targets, func(i, j int) bool { return r.Targets[i].Target < r.Targets[j].Target })
+	return r, nil
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/facebook/time/calnex/export"
+	"github.com/stretchr/testify/require"
+)
+
+func entry(source, target string, t int, v float64) *export.Entry {
+	return &export.Entry{
+		Float:  &export.FloatData{Value: v},
+		Int:    &export.IntData{Time: t},
+		Normal: &export.NormalData{Channel: "1", Target: target, Protocol: "ntp", Source: source},
+	}
+}
+
+func TestReadEntries(t *testing.T) {
+	data := `{"float":{"value":-0.00000025},"int":{"time":1607961193},"normal":{"channel":"1","target":"ntp01","protocol":"ntp","source":"calnex01"}}
+
+{"float":{"value":0.00000025},"int":{"time":1607961194},"normal":{"channel":"1","target":"ntp01","protocol":"ntp","source":"calnex01"}}
+`
+	entries, err := ReadEntries(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	require.Equal(t, entry("calnex01", "ntp01", 1607961193, -0.00000025), entries[0])
+
+	_, err = ReadEntries(strings.NewReader("{\"float\":{\"value\":1}}\n"))
+	require.Error(t, err)
+	_, err = ReadEntries(strings.NewReader("nope\n"))
+	require.Error(t, err)
+}
+
+func TestPercentiles(t *testing.T) {
+	v := []float64{}
+	for i := 100; i > 0; i-- {
+		v = append(v, -float64(i))
+	}
+	require.Equal(t, Percentiles{P50: 50, P90: 90, P99: 99, Max: 100}, AbsPercentiles(v))
+	require.Equal(t, Percentiles{}, AbsPercentiles(nil))
+}
+
+func TestCompare(t *testing.T) {
+	_, err := Compare(nil, 1)
+	require.ErrorIs(t, err, errNoSamples)
+
+	entries := []*export.Entry{
+		entry("calnex02", "ntp01", 10, 3),
+		entry("calnex01", "ntp01", 10, 1),
+		entry("calnex01", "ntp01", 11, -2),
+		entry("calnex02", "ntp01", 12, -3),
+		entry("calnex01", "ntp02", 10, 1),
+	}
+	r, err := Compare(entries, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"calnex01", "calnex02"}, r.Sources)
+	require.Equal(t, 2, len(r.Targets))
+
+	ntp01 := r.Targets[0]
+	require.Equal(t, "ntp01", ntp01.Target)
+	require.Equal(t, []*DeviceStats{
+		{Source: "calnex01", Samples: 2, Offset: Percentiles{P50: 1, P90: 2, P99: 2, Max: 2}},
+		{Source: "calnex02", Samples: 2, Offset: Percentiles{P50: 3, P90: 3, P99: 3, Max: 3}},
+	}, ntp01.Devices)
+	// only second 10 is measured by both
+	require.Equal(t, 1, ntp01.Aligned)
+	require.Equal(t, Percentiles{P50: 2, P90: 2, P99: 2, Max: 2}, ntp01.Disagreement)
+
+	ntp02 := r.Targets[1]
+	require.Equal(t, 0, ntp02.Aligned)
+
+	// seconds 10 and 11 end up in the same slot, 12 in the next one
+	r, err = Compare(entries, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, r.Targets[0].Aligned)
+	// slot 10: mean(1, -2) vs 3, slot 12: -3 vs nothing from calnex01
+	require.Equal(t, 3.5, r.Targets[0].Disagreement.Max)
+}
+
+func TestWrite(t *testing.T) {
+	r, err := Compare([]*export.Entry{entry("calnex01", "ntp<01>", 10, 1)}, 1)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, r.WriteJSON(&b))
+	require.Contains(t, b.String(), `"source": "calnex01"`)
+
+	b.Reset()
+	require.NoError(t, r.WriteHTML(&b))
+	require.Contains(t, b.String(), "<h2>ntp&lt;01&gt;</h2>")
+	require.Contains(t, b.String(), "<td>calnex01</td><td>1</td>")
+}
@@ -0,0 +1,53 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compare
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+)
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Calnex comparison report</title></head>
+<body>
+<h1>Calnex comparison report</h1>
+<p>Sources: {{range $i, $s := .Sources}}{{if $i}}, {{end}}{{$s}}{{end}}. Alignment: {{.Align}}s. Values are absolute offsets in seconds.</p>
+{{range .Targets}}
+<h2>{{.Target}}</h2>
+<table border="1">
+<tr><th>Source</th><th>Samples</th><th>p50</th><th>p90</th><th>p99</th><th>max</th></tr>
+{{range .Devices}}<tr><td>{{.Source}}</td><td>{{.Samples}}</td><td>{{.Offset.P50}}</td><td>{{.Offset.P90}}</td><td>{{.Offset.P99}}</td><td>{{.Offset.Max}}</td></tr>
+{{end}}<tr><td>disagreement</td><td>{{.Aligned}}</td><td>{{.Disagreement.P50}}</td><td>{{.Disagreement.P90}}</td><td>{{.Disagreement.P99}}</td><td>{{.Disagreement.Max}}</td></tr>
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteJSON writes report as JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+// WriteHTML writes report as HTML page
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package config
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"sort"
 
 	"github.com/facebook/time/calnex/api"
 	"github.com/go-ini/ini"
@@ -28,10 +30,14 @@ import (
 // CalnexConfig is a wrapper around map[channel]MeasureConfig
 type CalnexConfig map[api.Channel]MeasureConfig
 
-// MeasureConfig is a Calnex channel config
+// MeasureConfig is a Calnex channel config.
+// With Emulation set the device acts as NTP server or PTP master on the channel instead of probing the Target.
+// Metric selects whether NTP channel records raw offsets (default) or TIE
 type MeasureConfig struct {
-	Target string
-	Probe  api.Probe
+	Target    string
+	Probe     api.Probe
+	Metric    api.NTPMetric
+	Emulation *api.Emulation
 }
 
 // NetworkConfig represents network config of a Calnex device
@@ -42,141 +48,261 @@ type NetworkConfig struct {
 	Gw2  net.IP
 }
 
+// Change is a single setting which differs between the device and the desired config
+type Change struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
 type config struct {
 	changed bool
+	changes []Change
 }
 
-// chSet modifies a config on several channels
-func (c *config) chSet(s *ini.Section, start, end api.Channel, keyf, value string) {
-	for i := start; i <= end; i++ {
-		name := fmt.Sprintf(keyf, i.CalnexAPI())
-		c.set(s, name, value)
+// set modifies a single config value. Later values override earlier ones
+func (c *config) set(s *ini.Section, name, value string) {
+	k := s.Key(name)
+	old := k.Value()
+	if old == value {
+		return
 	}
+	k.SetValue(value)
+	for i, change := range c.changes {
+		if change.Key != name {
+			continue
+		}
+		if change.Old == value {
+			c.changes = append(c.changes[:i], c.changes[i+1:]...)
+		} else {
+			c.changes[i].New = value
+		}
+		c.changed = len(c.changes) > 0
+		return
+	}
+	c.changes = append(c.changes, Change{Key: name, Old: old, New: value})
+	c.changed = true
 }
 
-// set modifies a single config value
-func (c *config) set(s *ini.Section, name, value string) {
-	k := s.Key(name)
-	if k.Value() != value {
-		k.SetValue(value)
-		log.Infof("setting %s to %s", name, value)
-		c.changed = true
+// apply writes typed changes of the measure section to the settings
+func (c *config) apply(d *api.DeviceSettings) {
+	s := d.Raw().Section(api.MeasureSection)
+	for _, setting := range d.Changes(api.MeasureSection) {
+		c.set(s, setting.Key, setting.Value)
 	}
 }
 
-func (c *config) measureConfig(s *ini.Section, cc CalnexConfig) {
+func (c *config) measureConfig(d *api.DeviceSettings, cc CalnexConfig) {
 	channelEnabled := make(map[api.Channel]bool)
 
 	for ch, m := range cc {
 		channelEnabled[ch] = true
 
-		probe := fmt.Sprintf("%s\\ptp_synce\\mode\\probe_type", ch.CalnexAPI())
-		c.set(s, probe, m.Probe.CalnexName())
+		if m.Emulation != nil {
+			// emulation settings are not part of the typed settings
+			s := d.Raw().Section(api.MeasureSection)
+			for _, setting := range m.Emulation.Settings(ch) {
+				c.set(s, setting.Key, setting.Value)
+			}
+			continue
+		}
+
+		chs := d.Channels[ch]
+		chs.ProbeType = m.Probe.CalnexName()
 
 		switch m.Probe {
 		case api.ProbeNTP:
-			server := fmt.Sprintf("%s\\ptp_synce\\ntp\\server_ip", ch.CalnexAPI())
-			c.set(s, server, m.Target)
+			chs.NTP.ServerIP = m.Target
+			chs.NTP.ServerIPv6 = m.Target
 
-			serverv6 := fmt.Sprintf("%s\\ptp_synce\\ntp\\server_ip_ipv6", ch.CalnexAPI())
-			c.set(s, serverv6, m.Target)
+			// raw offsets are set by the base config
+			if m.Metric == api.NTPMetricTIE {
+				chs.NTP.Metric = m.Metric
+			}
 		case api.ProbePTP:
-			server := fmt.Sprintf("%s\\ptp_synce\\ptp\\master_ip", ch.CalnexAPI())
-			c.set(s, server, m.Target)
-
-			serverv6 := fmt.Sprintf("%s\\ptp_synce\\ptp\\master_ip_ipv6", ch.CalnexAPI())
-			c.set(s, serverv6, m.Target)
+			chs.PTP.MasterIP = m.Target
+			chs.PTP.MasterIPv6 = m.Target
 		}
 	}
 
 	// Disable unused channels and enable used
-	for ch := range api.ChannelCalnexToString {
-		used := api.NO
-		enabled := api.OFF
-		if channelEnabled[ch] {
-			// enable PTP/NTP channels
-			used = api.YES
-			enabled = api.ON
-		}
-		c.set(s, fmt.Sprintf("%s\\used", ch.CalnexAPI()), used)
-		c.set(s, fmt.Sprintf("%s\\protocol_enabled", ch.CalnexAPI()), enabled)
+	for ch, chs := range d.Channels {
+		// enable PTP/NTP channels
+		chs.Used = channelEnabled[ch]
+		chs.ProtocolEnabled = channelEnabled[ch]
+		d.Assign(&chs.Used, &chs.ProtocolEnabled)
 	}
 }
 
-func (c *config) nicConfig(s *ini.Section, n *NetworkConfig) {
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\gateway", api.ChannelONE.CalnexAPI()), n.Gw1.String())
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\gateway_ipv6", api.ChannelONE.CalnexAPI()), n.Gw1.String())
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\ip_address", api.ChannelONE.CalnexAPI()), n.Eth1.String())
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\ip_address_ipv6", api.ChannelONE.CalnexAPI()), n.Eth1.String())
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\mask", api.ChannelONE.CalnexAPI()), "64")
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\gateway", api.ChannelTWO.CalnexAPI()), n.Gw2.String())
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\gateway_ipv6", api.ChannelTWO.CalnexAPI()), n.Gw2.String())
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\ip_address", api.ChannelTWO.CalnexAPI()), n.Eth2.String())
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\ip_address_ipv6", api.ChannelTWO.CalnexAPI()), n.Eth2.String())
-	c.set(s, fmt.Sprintf("%s\\ptp_synce\\ethernet\\mask", api.ChannelTWO.CalnexAPI()), "64")
+func (c *config) nicConfig(d *api.DeviceSettings, n *NetworkConfig) {
+	one := &d.Channels[api.ChannelONE].Ethernet
+	one.Gateway = n.Gw1.String()
+	one.GatewayIPv6 = n.Gw1.String()
+	one.IP = n.Eth1.String()
+	one.IPv6 = n.Eth1.String()
+	one.Mask = "64"
+	two := &d.Channels[api.ChannelTWO].Ethernet
+	two.Gateway = n.Gw2.String()
+	two.GatewayIPv6 = n.Gw2.String()
+	two.IP = n.Eth2.String()
+	two.IPv6 = n.Eth2.String()
+	two.Mask = "64"
 }
 
-func (c *config) baseConfig(s *ini.Section) {
-	// disable synce
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\synce_enabled", api.OFF)
+func (c *config) notificationsConfig(s *ini.Section, nt *api.Notifications) {
+	for _, setting := range nt.Settings() {
+		c.set(s, setting.Key, setting.Value)
+	}
+}
 
-	// DHCP off (not working properly anyway)
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ethernet\\dhcp", api.OFF)
+func (c *config) measureSettings(d *api.DeviceSettings, m *api.MeasureSettings) {
+	d.Continuous = m.Continuous
+	d.MeasureTime = m.Duration
+}
 
-	// show raw metrics
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ntp\\normalize_delays", api.OFF)
+func (c *config) baseConfig(d *api.DeviceSettings) {
+	for _, ch := range []api.Channel{api.ChannelONE, api.ChannelTWO} {
+		chs := d.Channels[ch]
 
-	// use ipv6
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ntp\\protocol_level", "UDP/IPv6")
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ptp\\protocol_level", "UDP/IPv6")
+		// disable synce
+		chs.SynceEnabled = false
 
-	// ntp 1 packet per 64 second
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ntp\\poll_log_interval", "1 packet/64 s")
+		// DHCP off (not working properly anyway)
+		chs.Ethernet.DHCP = false
 
-	// ptp 1 packet per 1 second
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ptp\\log_announce_int", "1 packet/s")
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ptp\\log_delay_req_int", "1 packet/s")
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ptp\\log_sync_int", "1 packet/s")
+		// show raw metrics
+		chs.NTP.Metric = api.NTPMetricOffset
 
-	// ptp unicast mode
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ptp\\stack_mode", "Unicast")
+		// use ipv6
+		chs.NTP.ProtocolLevel = "UDP/IPv6"
+		chs.PTP.ProtocolLevel = "UDP/IPv6"
 
-	// ptp domain
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ptp\\domain", "0")
+		// ntp 1 packet per 64 second
+		chs.NTP.PollLogInterval = "1 packet/64 s"
 
-	// ptp dscp
-	c.chSet(s, api.ChannelONE, api.ChannelTWO, "%s\\ptp_synce\\ptp\\dscp", "0")
+		// ptp 1 packet per 1 second
+		chs.PTP.LogAnnounceInt = "1 packet/s"
+		chs.PTP.LogDelayReqInt = "1 packet/s"
+		chs.PTP.LogSyncInt = "1 packet/s"
 
-	// continuous measurement
-	c.set(s, "continuous", api.ON)
+		// ptp unicast mode
+		chs.PTP.StackMode = "Unicast"
 
-	// 25h measurement
-	c.set(s, "meas_time", "1 days 1 hours")
+		// ptp domain
+		chs.PTP.Domain = 0
+
+		// ptp dscp
+		chs.PTP.DSCP = 0
+
+		// zero values are written even if missing on the device
+		d.Assign(&chs.SynceEnabled, &chs.Ethernet.DHCP, &chs.NTP.Metric, &chs.PTP.Domain, &chs.PTP.DSCP)
+	}
 
 	// tie_mode=TIE + 1 PPS TE
-	c.set(s, "tie_mode", "TIE + 1 PPS TE")
+	d.TIEMode = "TIE + 1 PPS TE"
 }
 
-// Config configures target Calnex via protocol with Network/Calnex configs if apply is specified
-func Config(target string, insecureTLS bool, n *NetworkConfig, cc CalnexConfig, apply bool) error {
+// desiredConfig applies desired Network/Calnex/Measure/Notifications configs on top of the device settings
+func (c *config) desiredConfig(f *ini.File, n *NetworkConfig, cc CalnexConfig, m *api.MeasureSettings, nt *api.Notifications) error {
+	d, err := api.ParseDeviceSettings(f)
+	if err != nil {
+		return err
+	}
+
+	// set static config
+	c.baseConfig(d)
+
+	// set measurement duration and rollover, 25h continuous by default
+	if m == nil {
+		m = &api.DefaultMeasureSettings
+	}
+	c.measureSettings(d, m)
+
+	// set IP/Gateway/Mask
+	c.nicConfig(d, n)
+
+	// set measure config
+	c.measureConfig(d, cc)
+	c.apply(d)
+
+	// measure config is a map, make the order stable
+	sort.SliceStable(c.changes, func(i, j int) bool {
+		return c.changes[i].Key < c.changes[j].Key
+	})
+
+	// notifications are left as is unless configured
+	if nt != nil {
+		c.notificationsConfig(f.Section(api.NotificationsSection), nt)
+	}
+	return nil
+}
+
+// validate checks configs before anything is fetched from the device
+func validate(cc CalnexConfig, m *api.MeasureSettings, nt *api.Notifications) error {
+	for ch, mc := range cc {
+		if mc.Emulation != nil {
+			if err := mc.Emulation.Validate(); err != nil {
+				return fmt.Errorf("channel %s: %w", ch, err)
+			}
+		}
+	}
+	if nt != nil {
+		if err := nt.Validate(); err != nil {
+			return err
+		}
+	}
+	if m != nil {
+		return m.Validate()
+	}
+	return nil
+}
+
+// Diff returns settings of the target Calnex which differ from Network/Calnex/Measure/Notifications configs. Nothing is applied
+func Diff(target string, insecureTLS bool, n *NetworkConfig, cc CalnexConfig, m *api.MeasureSettings, nt *api.Notifications) ([]Change, error) {
 	var c config
+	if err := validate(cc, m, nt); err != nil {
+		return nil, err
+	}
 	api := api.NewAPI(target, insecureTLS)
 
 	f, err := api.FetchSettings()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := c.desiredConfig(f, n, cc, m, nt); err != nil {
+		return nil, err
 	}
+	return c.changes, nil
+}
 
-	s := f.Section("measure")
+// startMeasure starts measurement, waiting for the reference if measurement settings have a guard
+func startMeasure(a *api.API, m *api.MeasureSettings) error {
+	if m == nil {
+		return a.StartMeasure()
+	}
+	return a.StartMeasureGuarded(context.Background(), m.Reference)
+}
 
-	// set static config
-	c.baseConfig(s)
+// Config configures target Calnex via protocol with Network/Calnex/Measure/Notifications configs if apply is specified
+func Config(target string, insecureTLS bool, n *NetworkConfig, cc CalnexConfig, m *api.MeasureSettings, nt *api.Notifications, apply bool) error {
+	var c config
+	if err := validate(cc, m, nt); err != nil {
+		return err
+	}
+	api := api.NewAPI(target, insecureTLS)
 
-	// set IP/Gateway/Mask
-	c.nicConfig(s, n)
+	f, err := api.FetchSettings()
+	if err != nil {
+		return err
+	}
 
-	// set measure config
-	c.measureConfig(s, cc)
+	if err := c.desiredConfig(f, n, cc, m, nt); err != nil {
+		return err
+	}
+	for _, change := range c.changes {
+		log.Infof("setting %s to %s", change.Key, change.New)
+	}
 
 	if !apply {
 		log.Infof("dry run. Exiting")
@@ -210,7 +336,7 @@ func Config(target string, insecureTLS bool, n *NetworkConfig, cc CalnexConfig,
 	if c.changed || !status.MeasurementActive {
 		log.Infof("starting measurement")
 		// start measurement
-		if err = api.StartMeasure(); err != nil {
+		if err = startMeasure(api, m); err != nil {
 			return err
 		}
 	}
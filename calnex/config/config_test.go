@@ -25,13 +25,22 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/facebook/time/calnex/api"
 	"github.com/go-ini/ini"
 	"github.com/stretchr/testify/require"
 )
 
-func TestChSet(t *testing.T) {
+func deviceSettings(t *testing.T, config string) (*ini.File, *api.DeviceSettings) {
+	f, err := ini.Load([]byte(config))
+	require.NoError(t, err)
+	d, err := api.ParseDeviceSettings(f)
+	require.NoError(t, err)
+	return f, d
+}
+
+func TestApply(t *testing.T) {
 	testConfig := `[measure]
 ch0\used=Yes
 ch1\used=Yes
@@ -51,12 +60,13 @@ ch5\used=No
 `
 	c := config{}
 
-	f, err := ini.Load([]byte(testConfig))
-	require.NoError(t, err)
-
-	s := f.Section("measure")
-	c.chSet(s, api.ChannelA, api.ChannelF, "%s\\used", api.NO)
+	f, d := deviceSettings(t, testConfig)
+	for ch := api.ChannelA; ch <= api.ChannelF; ch++ {
+		d.Channels[ch].Used = false
+	}
+	c.apply(d)
 	require.True(t, c.changed)
+	require.Len(t, c.changes, 6)
 
 	buf, err := api.ToBuffer(f)
 	require.NoError(t, err)
@@ -158,13 +168,37 @@ tie_mode=TIE + 1 PPS TE
 
 	c := config{}
 
-	f, err := ini.Load([]byte(testConfig))
+	f, d := deviceSettings(t, testConfig)
+	c.baseConfig(d)
+	c.measureSettings(d, &api.DefaultMeasureSettings)
+	c.apply(d)
+	require.True(t, c.changed)
+
+	buf, err := api.ToBuffer(f)
 	require.NoError(t, err)
+	require.Equal(t, expectedConfig, buf.String())
+}
 
-	s := f.Section("measure")
+func TestMeasureSettings(t *testing.T) {
+	testConfig := `[measure]
+continuous=On
+meas_time=1 days 1 hours
+`
 
-	c.baseConfig(s)
+	expectedConfig := `[measure]
+continuous=Off
+meas_time=2 hours 30 minutes
+`
+	c := config{}
+
+	f, d := deviceSettings(t, testConfig)
+	c.measureSettings(d, &api.MeasureSettings{Duration: api.MeasureDuration(150 * time.Minute), Continuous: false})
+	c.apply(d)
 	require.True(t, c.changed)
+	require.Equal(t, []Change{
+		{Key: "continuous", Old: "On", New: "Off"},
+		{Key: "meas_time", Old: "1 days 1 hours", New: "2 hours 30 minutes"},
+	}, c.changes)
 
 	buf, err := api.ToBuffer(f)
 	require.NoError(t, err)
@@ -200,10 +234,7 @@ ch7\ptp_synce\ethernet\mask=64
 
 	c := config{}
 
-	f, err := ini.Load([]byte(testConfig))
-	require.NoError(t, err)
-
-	s := f.Section("measure")
+	f, d := deviceSettings(t, testConfig)
 
 	n := &NetworkConfig{
 		Eth1: net.ParseIP("fd00:3226:310a::1"),
@@ -212,7 +243,8 @@ ch7\ptp_synce\ethernet\mask=64
 		Gw2:  net.ParseIP("fd00:3226:310a::a"),
 	}
 
-	c.nicConfig(s, n)
+	c.nicConfig(d, n)
+	c.apply(d)
 	require.True(t, c.changed)
 
 	buf, err := api.ToBuffer(f)
@@ -274,10 +306,7 @@ ch7\ptp_synce\ptp\master_ip_ipv6=fd00:3016:3109:face:0:1:0
 
 	c := config{}
 
-	f, err := ini.Load([]byte(testConfig))
-	require.NoError(t, err)
-
-	s := f.Section("measure")
+	f, d := deviceSettings(t, testConfig)
 
 	mc := map[api.Channel]MeasureConfig{
 		api.ChannelONE: {
@@ -290,12 +319,119 @@ ch7\ptp_synce\ptp\master_ip_ipv6=fd00:3016:3109:face:0:1:0
 		},
 	}
 
-	c.measureConfig(s, CalnexConfig(mc))
+	c.measureConfig(d, CalnexConfig(mc))
+	c.apply(d)
+	require.True(t, c.changed)
+
+	buf, err := api.ToBuffer(f)
+	require.NoError(t, err)
+	require.Equal(t, expectedConfig, buf.String())
+}
+
+func TestSetOverride(t *testing.T) {
+	f, err := ini.Load([]byte("[measure]\nch6\\used=Yes\n"))
+	require.NoError(t, err)
+	s := f.Section("measure")
+
+	c := config{}
+	c.set(s, "ch6\\used", api.NO)
+	c.set(s, "ch6\\used", api.OFF)
+	require.True(t, c.changed)
+	require.Equal(t, []Change{{Key: "ch6\\used", Old: api.YES, New: api.OFF}}, c.changes)
+
+	// back to the device value
+	c.set(s, "ch6\\used", api.YES)
+	require.False(t, c.changed)
+	require.Empty(t, c.changes)
+}
+
+func TestMeasureConfigTIE(t *testing.T) {
+	f, d := deviceSettings(t, "[measure]\nch6\\ptp_synce\\ntp\\normalize_delays=On\nch7\\ptp_synce\\ntp\\normalize_delays=On\n")
+	s := f.Section("measure")
+
+	c := config{}
+	c.baseConfig(d)
+	c.measureConfig(d, CalnexConfig{
+		api.ChannelONE: {Target: "fd00:3226:301b::3f", Probe: api.ProbeNTP, Metric: api.NTPMetricTIE},
+		api.ChannelTWO: {Target: "fd00:3226:301b::3f", Probe: api.ProbeNTP},
+	})
+	c.apply(d)
+	require.Equal(t, api.ON, s.Key("ch6\\ptp_synce\\ntp\\normalize_delays").String())
+	require.Equal(t, api.OFF, s.Key("ch7\\ptp_synce\\ntp\\normalize_delays").String())
+	for _, change := range c.changes {
+		require.NotEqual(t, "ch6\\ptp_synce\\ntp\\normalize_delays", change.Key)
+	}
+	require.Contains(t, c.changes, Change{Key: "ch7\\ptp_synce\\ntp\\normalize_delays", Old: api.ON, New: api.OFF})
+}
+
+func TestMeasureConfigEmulation(t *testing.T) {
+	testConfig := `[measure]
+ch6\used=No
+ch6\protocol_enabled=Off
+ch6\ptp_synce\mode\probe_type=NTP client
+`
+
+	expectedConfig := `[measure]
+ch6\used=Yes
+ch6\protocol_enabled=On
+ch6\ptp_synce\mode\probe_type=NTP server
+ch6\ptp_synce\ntp\server_stratum=1
+ch6\ptp_synce\ntp\server_reference_id=GPS
+ch6\ptp_synce\ntp\server_leap_indicator=0
+`
+	c := config{}
+
+	f, d := deviceSettings(t, testConfig)
+	mc := CalnexConfig{
+		api.ChannelONE: {
+			Emulation: &api.Emulation{Probe: api.ProbeNTP, NTP: &api.NTPServer{Stratum: 1, RefID: "GPS"}},
+		},
+	}
+	c.measureConfig(d, mc)
+	c.apply(d)
+	require.True(t, c.changed)
+
+	buf, err := api.ToBuffer(f)
+	require.NoError(t, err)
+	// other channels are disabled
+	require.Contains(t, buf.String(), "ch7\\used=No\n")
+	require.True(t, strings.HasPrefix(buf.String(), expectedConfig))
+
+	err = validate(CalnexConfig{api.ChannelONE: {Emulation: &api.Emulation{Probe: api.ProbePTP}}}, nil, nil)
+	require.Error(t, err)
+}
+
+func TestNotificationsConfig(t *testing.T) {
+	testConfig := `[notifications]
+snmp\enabled=Off
+snmp\trap_target_3=stale.example.com
+smtp\enabled=On
+`
+
+	expectedConfig := `[notifications]
+snmp\enabled=On
+snmp\trap_target_3=
+smtp\enabled=Off
+snmp\community=public
+snmp\trap_target_1=fd00::1
+snmp\trap_target_2=
+snmp\trap_target_4=
+`
+	c := config{}
+
+	f, err := ini.Load([]byte(testConfig))
+	require.NoError(t, err)
+
+	nt := &api.Notifications{SNMP: &api.SNMPNotifications{Community: "public", Targets: []string{"fd00::1"}}}
+	c.notificationsConfig(f.Section(api.NotificationsSection), nt)
 	require.True(t, c.changed)
 
 	buf, err := api.ToBuffer(f)
 	require.NoError(t, err)
 	require.Equal(t, expectedConfig, buf.String())
+
+	err = validate(CalnexConfig{}, nil, &api.Notifications{Email: &api.EmailNotifications{}})
+	require.Error(t, err)
 }
 
 func TestConfig(t *testing.T) {
@@ -407,7 +543,7 @@ ch7\ptp_synce\ptp\master_ip_ipv6=fd00:3016:3109:face:0:1:0
 		},
 	}
 
-	err := Config(parsed.Host, true, n, CalnexConfig(mc), true)
+	err := Config(parsed.Host, true, n, CalnexConfig(mc), nil, nil, true)
 	require.NoError(t, err)
 }
 
@@ -415,6 +551,57 @@ func TestConfigFail(t *testing.T) {
 	n := &NetworkConfig{}
 	mc := map[api.Channel]MeasureConfig{}
 
-	err := Config("localhost", true, n, CalnexConfig(mc), true)
+	err := Config("localhost", true, n, CalnexConfig(mc), nil, nil, true)
+	require.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if strings.Contains(r.URL.Path, "getsettings") {
+			// FetchSettings
+			fmt.Fprintln(w, "[measure]\nch6\\used=No\nch6\\ptp_synce\\ntp\\server_ip=fd00:3226:301b::3f")
+		} else {
+			require.Fail(t, "diff must not change anything", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+
+	n := &NetworkConfig{
+		Eth1: net.ParseIP("fd00:3226:310a::1"),
+		Gw1:  net.ParseIP("fd00:3226:310a::a"),
+		Eth2: net.ParseIP("fd00:3226:310a::2"),
+		Gw2:  net.ParseIP("fd00:3226:310a::a"),
+	}
+
+	mc := map[api.Channel]MeasureConfig{
+		api.ChannelONE: {
+			Target: "fd00:3226:301b::3f",
+			Probe:  api.ProbeNTP,
+		},
+	}
+
+	changes, err := Diff(parsed.Host, true, n, CalnexConfig(mc), nil, nil)
+	require.NoError(t, err)
+	require.Contains(t, changes, Change{Key: "ch6\\used", Old: "No", New: "Yes"})
+	for i, c := range changes {
+		// unchanged value is not reported
+		require.NotEqual(t, "ch6\\ptp_synce\\ntp\\server_ip", c.Key)
+		if i > 0 {
+			require.LessOrEqual(t, changes[i-1].Key, c.Key)
+		}
+	}
+}
+
+func TestDiffFail(t *testing.T) {
+	n := &NetworkConfig{}
+	mc := map[api.Channel]MeasureConfig{}
+
+	_, err := Diff("localhost", true, n, CalnexConfig(mc), nil, nil)
+	require.Error(t, err)
+
+	_, err = Diff("localhost", true, n, CalnexConfig(mc), &api.MeasureSettings{Duration: api.MeasureDuration(time.Second)}, nil)
 	require.Error(t, err)
 }
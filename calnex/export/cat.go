@@ -0,0 +1,95 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteCAT writes entries as CSV in the layout of the measurement data exported by the device:
+// one sample per line, unix time of the sample and its value.
+// Calnex Analysis Tool (CAT) expects values in seconds as the device records them,
+// so entries should not be converted to other units.
+// Sub-second part of the sample time is not kept by the exporter and is written as zero
+func WriteCAT(w io.Writer, entries []*Entry) error {
+	cw := csv.NewWriter(w)
+	for _, e := range entries {
+		if err := cw.Write([]string{
+			fmt.Sprintf("%d.000000", e.Int.Time),
+			strconv.FormatFloat(e.Float.Value, 'f', 12, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type catFile struct {
+	source   string
+	channel  string
+	protocol string
+}
+
+// name returns file name of the device channel, safe for any file system
+func (f catFile) name() string {
+	r := strings.NewReplacer(":", "_", "/", "_", "\\", "_", "[", "", "]", "")
+	return r.Replace(fmt.Sprintf("%s_ch%s_%s.csv", f.source, f.channel, f.protocol))
+}
+
+// CATWriter writes entries as CSV files loadable by Calnex Analysis Tool, one per device channel,
+// so measurements collected by the pipeline can still be analysed in the vendor GUI.
+// Entries are buffered in memory and written on Close
+type CATWriter struct {
+	Dir   string
+	files map[catFile][]*Entry
+}
+
+// NewCATWriter returns a CATWriter writing to dir
+func NewCATWriter(dir string) *CATWriter {
+	return &CATWriter{Dir: dir, files: map[catFile][]*Entry{}}
+}
+
+// Write buffers the entry in the file of its device channel
+func (c *CATWriter) Write(entry *Entry) error {
+	f := catFile{source: entry.Normal.Source, channel: entry.Normal.Channel, protocol: entry.Normal.Protocol}
+	c.files[f] = append(c.files[f], entry)
+	return nil
+}
+
+// Close writes every device channel into <dir>/<source>_ch<channel>_<protocol>.csv sorted by sample time.
+// Existing files are replaced
+func (c *CATWriter) Close() error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	for f, entries := range c.files {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Int.Time < entries[j].Int.Time })
+		if err := writeFileAtomic(filepath.Join(c.Dir, f.name()), func(w io.Writer) error { return WriteCAT(w, entries) }); err != nil {
+			return err
+		}
+		delete(c.files, f)
+	}
+	return nil
+}
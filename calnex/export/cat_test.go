@@ -0,0 +1,63 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCAT(t *testing.T) {
+	entries := []*Entry{parquetEntry(1607961193, "1"), parquetEntry(1607961194, "1")}
+	entries[1].Float.Value = 1.5e-9
+	var b bytes.Buffer
+	require.NoError(t, WriteCAT(&b, entries))
+	require.Equal(t, "1607961193.000000,-0.000000250501\n1607961194.000000,0.000000001500\n", b.String())
+
+	// readable the same way as data of the device
+	lines, err := csv.NewReader(&b).ReadAll()
+	require.NoError(t, err)
+	e, err := entryFromCSV(lines[0], "1", "localhost", "ntp", "calnex01.example.com")
+	require.NoError(t, err)
+	require.Equal(t, entries[0], e)
+}
+
+func TestCATFileName(t *testing.T) {
+	require.Equal(t, "calnex01.example.com_ch1_ntp.csv", catFile{source: "calnex01.example.com", channel: "1", protocol: "ntp"}.name())
+	require.Equal(t, "fd00__1_8443_chc_ptp.csv", catFile{source: "[fd00::1]:8443", channel: "c", protocol: "ptp"}.name())
+}
+
+func TestCATWriter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cat")
+	w := NewCATWriter(dir)
+	require.NoError(t, w.Write(parquetEntry(1607961194, "1")))
+	require.NoError(t, w.Write(parquetEntry(1607961193, "1")))
+	require.NoError(t, w.Write(parquetEntry(1607961193, "2")))
+	require.NoError(t, w.Close())
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "calnex01.example.com_ch1_ntp.csv"))
+	require.NoError(t, err)
+	require.Equal(t, "1607961193.000000,-0.000000250501\n1607961194.000000,-0.000000250501\n", string(data))
+}
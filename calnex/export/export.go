@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/facebook/time/calnex/api"
 	log "github.com/sirupsen/logrus"
@@ -29,10 +30,65 @@ import (
 var errNoUsedChannels = errors.New("no used channels")
 var errNoTarget = errors.New("no target succeeds")
 
-// Export data from the device about specified channels via protocol to the output
-func Export(source string, insecureTLS bool, channels []api.Channel, output io.WriteCloser) (err error) {
-	var success bool
+// EntryWriter writes exported entries
+type EntryWriter interface {
+	Write(entry *Entry) error
+}
+
+// JSONWriter writes entries as JSON lines
+type JSONWriter struct {
+	Output io.Writer
+}
+
+// Write writes a single entry as JSON line
+func (j *JSONWriter) Write(entry *Entry) error {
+	entryj, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(j.Output, string(entryj))
+	return err
+}
+
+// Export data from the device about specified channels via protocol to the output as JSON lines
+func Export(source string, insecureTLS bool, channels []api.Channel, output io.WriteCloser) error {
+	return ExportEntries(source, insecureTLS, channels, &JSONWriter{Output: output})
+}
+
+// ExportEntries exports data from the device about specified channels via protocol to the entry writer
+func ExportEntries(source string, insecureTLS bool, channels []api.Channel, output EntryWriter) error {
 	calnexAPI := api.NewAPI(source, insecureTLS)
+	return exportEntries(calnexAPI, source, channels, calnexAPI.FetchCsv, nil, output)
+}
+
+// ExportWindow exports samples taken within the window of the device clock.
+// The window must be complete according to the device clock, and the device clock
+// must be within maxOffset from the local one. 0 maxOffset disables the offset check
+func ExportWindow(source string, insecureTLS bool, channels []api.Channel, w Window, maxOffset time.Duration, output EntryWriter) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	calnexAPI := api.NewAPI(source, insecureTLS)
+	offset, err := calnexAPI.FetchClockOffset()
+	if err != nil {
+		return fmt.Errorf("failed to fetch device clock: %w", err)
+	}
+	if err := w.checkClock(time.Now(), offset, maxOffset); err != nil {
+		return err
+	}
+	log.Infof("Exporting %s, device clock offset is %v", w, offset)
+	fetch := func(channel api.Channel) ([][]string, error) {
+		return calnexAPI.FetchCsvRange(channel, w.Start, w.End)
+	}
+	// device may return whole seconds around the boundaries
+	filter := func(e *Entry) bool {
+		return w.Contains(int64(e.Int.Time))
+	}
+	return exportEntries(calnexAPI, source, channels, fetch, filter, output)
+}
+
+func exportEntries(calnexAPI *api.API, source string, channels []api.Channel, fetch func(api.Channel) ([][]string, error), filter func(*Entry) bool, output EntryWriter) (err error) {
+	var success bool
 
 	if len(channels) == 0 {
 		channels, err = calnexAPI.FetchUsedChannels()
@@ -57,7 +113,7 @@ func Export(source string, insecureTLS bool, channels []api.Channel, output io.W
 			continue
 		}
 
-		csvLines, err := calnexAPI.FetchCsv(channel)
+		csvLines, err := fetch(channel)
 		if err != nil {
 			log.Errorf("Failed to fetch data from channel %s: %v", channel, err)
 			success = success || false
@@ -72,9 +128,13 @@ func Export(source string, insecureTLS bool, channels []api.Channel, output io.W
 				log.Errorf("Failed to generate scribe line for data channel %s: %v", channel, err)
 				break
 			}
+			if filter != nil && !filter(entry) {
+				continue
+			}
 
-			entryj, _ := json.Marshal(entry)
-			fmt.Fprintln(output, string(entryj))
+			if err := output.Write(entry); err != nil {
+				return err
+			}
 		}
 		success = success || printSuccess
 	}
@@ -23,6 +23,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/facebook/time/calnex/api"
 	"github.com/stretchr/testify/require"
@@ -79,3 +80,54 @@ func TestExportFail(t *testing.T) {
 	err = Export("localhost", true, []api.Channel{api.ChannelONE}, w)
 	require.ErrorIs(t, errNoTarget, err)
 }
+
+type entries struct {
+	data []*Entry
+}
+
+func (e *entries) Write(entry *Entry) error {
+	e.data = append(e.data, entry)
+	return nil
+}
+
+func TestExportWindow(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if strings.Contains(r.URL.Path, "getsettings") {
+			// FetchUsedChannels
+			fmt.Fprintln(w, "[measure]\nch0\\used=No\nch6\\used=Yes\nch7\\used=No")
+		} else if strings.Contains(r.URL.Path, "probe_type") {
+			// FetchChannelProtocol
+			fmt.Fprintln(w, "measure/ch6/ptp_synce/mode/probe_type=2")
+		} else if strings.Contains(r.URL.Path, "measure/ch6/ptp_synce/ntp/server_ip") {
+			// FetchChannelTargetName
+			fmt.Fprintln(w, "measure/ch6/ptp_synce/ntp/server_ip=127.0.0.1")
+		} else if strings.Contains(r.URL.Path, "api/getdata") {
+			// FetchCsvRange. Device returns a sample on each boundary
+			require.Equal(t, "1607961000", r.URL.Query().Get("start"))
+			require.Equal(t, "1607964600", r.URL.Query().Get("end"))
+			fmt.Fprintln(w, "1607960999.773740,-000.000000250501")
+			fmt.Fprintln(w, "1607961000.773740,-000.000000250501")
+			fmt.Fprintln(w, "1607964599.773740,-000.000000250501")
+			fmt.Fprintln(w, "1607964600.773740,-000.000000250501")
+		} else if strings.Contains(r.URL.Path, "api/version") {
+			// FetchClockOffset
+			fmt.Fprintln(w, "{\"firmware\":\"2.13.1.0.5583D-20210924\"}")
+		}
+	}))
+	defer ts.Close()
+
+	parsed, _ := url.Parse(ts.URL)
+	e := &entries{}
+	w := Window{Start: time.Unix(1607961000, 0), End: time.Unix(1607964600, 0)}
+	require.NoError(t, ExportWindow(parsed.Host, true, []api.Channel{}, w, time.Minute, e))
+	require.Len(t, e.data, 2)
+	require.Equal(t, 1607961000, e.data[0].Int.Time)
+	require.Equal(t, 1607964599, e.data[1].Int.Time)
+
+	// window in the future
+	w = LastWindow(time.Now().Add(time.Hour), time.Hour)
+	require.ErrorIs(t, ExportWindow(parsed.Host, true, []api.Channel{}, w, time.Minute, e), errWindowNotComplete)
+
+	require.ErrorIs(t, ExportWindow(parsed.Host, true, []api.Channel{}, Window{}, time.Minute, e), errBadWindow)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/facebook/time/calnex/api"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var errBadMaxAbs = errors.New("max absolute value must not be negative")
+
+// Maintenance is a window of the device clock when samples are known to be garbage,
+// such as antenna or cabling work
+type Maintenance struct {
+	Start time.Time `yaml:"start"`
+	End   time.Time `yaml:"end"`
+	// Source limits the maintenance to a single device. All devices if empty
+	Source string `yaml:"source"`
+	// Channels limits the maintenance to channels of the devices, such as 1 or c. All channels if empty
+	Channels []string `yaml:"channels"`
+	Reason   string   `yaml:"reason"`
+}
+
+// Window returns time range of the maintenance
+func (m *Maintenance) Window() Window {
+	return Window{Start: m.Start, End: m.End}
+}
+
+// Validate checks the maintenance window and channels
+func (m *Maintenance) Validate() error {
+	if err := m.Window().Validate(); err != nil {
+		return err
+	}
+	for i, name := range m.Channels {
+		c, err := api.ChannelFromString(strings.ToLower(name))
+		if err != nil {
+			return fmt.Errorf("channel %q: %w", name, err)
+		}
+		// normalize, so both "C" and "c" match the channel
+		m.Channels[i] = c.String()
+	}
+	return nil
+}
+
+// Covers returns true if the entry was sampled during the maintenance on the affected device and channel
+func (m *Maintenance) Covers(entry *Entry) bool {
+	if entry.Int == nil || !m.Window().Contains(int64(entry.Int.Time)) {
+		return false
+	}
+	if entry.Normal == nil {
+		return m.Source == "" && len(m.Channels) == 0
+	}
+	if m.Source != "" && m.Source != entry.Normal.Source {
+		return false
+	}
+	if len(m.Channels) == 0 {
+		return true
+	}
+	for _, c := range m.Channels {
+		if c == entry.Normal.Channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Calendar is a list of maintenances.
+// Example:
+//
+//   - start: 2022-01-10T10:00:00Z
+//     end: 2022-01-10T12:00:00Z
+//     source: calnex01.example.com
+//     channels: [1, c]
+//     reason: GNSS antenna replacement
+type Calendar []*Maintenance
+
+// ReadCalendar reads and validates maintenance calendar from the yaml
+func ReadCalendar(r io.Reader) (Calendar, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	raw := Calendar{}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(true)
+	if err := d.Decode(&raw); err != nil && err != io.EOF {
+		return nil, err
+	}
+	c := Calendar{}
+	for i, m := range raw {
+		if m == nil {
+			continue
+		}
+		if err := m.Validate(); err != nil {
+			return nil, fmt.Errorf("maintenance %d: %w", i, err)
+		}
+		c = append(c, m)
+	}
+	return c, nil
+}
+
+// ReadCalendarFile reads and validates maintenance calendar from the yaml file
+func ReadCalendarFile(path string) (Calendar, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ReadCalendar(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// find returns maintenance covering the entry, nil if there is none
+func (c Calendar) find(entry *Entry) *Maintenance {
+	for _, m := range c {
+		if m.Covers(entry) {
+			return m
+		}
+	}
+	return nil
+}
+
+// FilterWriter drops garbage samples before passing entries to the output,
+// so they never reach downstream dashboards
+type FilterWriter struct {
+	Output EntryWriter
+	// MaxAbs drops samples with absolute value above it, and NaNs, as bogus. Disabled if 0
+	MaxAbs float64
+	// Calendar drops samples taken during maintenance
+	Calendar Calendar
+	// Bogus counts samples dropped by MaxAbs
+	Bogus int
+	// Maintenance counts samples dropped by Calendar
+	Maintenance int
+}
+
+// Validate checks the filter settings
+func (w *FilterWriter) Validate() error {
+	if w.MaxAbs < 0 {
+		return errBadMaxAbs
+	}
+	return nil
+}
+
+// Write passes the entry to the output unless it's filtered out
+func (w *FilterWriter) Write(entry *Entry) error {
+	if w.MaxAbs > 0 && entry.Float != nil && !(math.Abs(entry.Float.Value) <= w.MaxAbs) {
+		w.Bogus++
+		return nil
+	}
+	if w.Calendar.find(entry) != nil {
+		w.Maintenance++
+		return nil
+	}
+	return w.Output.Write(entry)
+}
+
+// Close closes the output if it's closable
+func (w *FilterWriter) Close() error {
+	if c, ok := w.Output.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sample(source, channel string, ts int, v float64) *Entry {
+	return &Entry{
+		Float:  &FloatData{Value: v},
+		Int:    &IntData{Time: ts},
+		Normal: &NormalData{Channel: channel, Source: source, Protocol: "ntp"},
+	}
+}
+
+func TestMaintenanceCovers(t *testing.T) {
+	start := time.Unix(1000, 0)
+	m := &Maintenance{Start: start, End: start.Add(time.Minute)}
+	require.True(t, m.Covers(sample("calnex01", "1", 1000, 0)))
+	require.True(t, m.Covers(sample("calnex02", "c", 1059, 0)))
+	require.False(t, m.Covers(sample("calnex01", "1", 999, 0)))
+	require.False(t, m.Covers(sample("calnex01", "1", 1060, 0)))
+	require.False(t, m.Covers(&Entry{Float: &FloatData{}}))
+
+	m = &Maintenance{Start: start, End: start.Add(time.Minute), Source: "calnex01", Channels: []string{"C", "1"}}
+	require.NoError(t, m.Validate())
+	require.Equal(t, []string{"c", "1"}, m.Channels)
+	require.True(t, m.Covers(sample("calnex01", "c", 1000, 0)))
+	require.False(t, m.Covers(sample("calnex01", "2", 1000, 0)))
+	require.False(t, m.Covers(sample("calnex02", "c", 1000, 0)))
+}
+
+func TestMaintenanceValidate(t *testing.T) {
+	start := time.Unix(1000, 0)
+	require.ErrorIs(t, (&Maintenance{Start: start, End: start}).Validate(), errBadWindow)
+	require.Error(t, (&Maintenance{Start: start, End: start.Add(time.Second), Channels: []string{"z"}}).Validate())
+}
+
+func TestReadCalendar(t *testing.T) {
+	c, err := ReadCalendar(strings.NewReader(`
+- start: 2022-01-10T10:00:00Z
+  end: 2022-01-10T12:00:00Z
+  source: calnex01.example.com
+  channels: ["C"]
+  reason: GNSS antenna replacement
+- start: 2022-01-11T10:00:00Z
+  end: 2022-01-11T11:00:00Z
+`))
+	require.NoError(t, err)
+	require.Len(t, c, 2)
+	require.Equal(t, time.Date(2022, 1, 10, 12, 0, 0, 0, time.UTC), c[0].End.UTC())
+	require.Equal(t, []string{"c"}, c[0].Channels)
+	require.Equal(t, "GNSS antenna replacement", c[0].Reason)
+	require.Empty(t, c[1].Source)
+
+	c, err = ReadCalendar(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Empty(t, c)
+
+	_, err = ReadCalendar(strings.NewReader("- start: 2022-01-10T10:00:00Z\n  end: 2022-01-10T09:00:00Z\n"))
+	require.ErrorIs(t, err, errBadWindow)
+
+	_, err = ReadCalendar(strings.NewReader("- start: 2022-01-10T10:00:00Z\n  end: 2022-01-10T12:00:00Z\n  oleg: true\n"))
+	require.Error(t, err)
+}
+
+func TestReadCalendarFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maintenance.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("- start: 2022-01-10T10:00:00Z\n  end: 2022-01-10T12:00:00Z\n"), 0644))
+	c, err := ReadCalendarFile(path)
+	require.NoError(t, err)
+	require.Len(t, c, 1)
+
+	_, err = ReadCalendarFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestFilterWriter(t *testing.T) {
+	out := &recordWriter{}
+	start := time.Unix(1000, 0)
+	w := &FilterWriter{
+		Output:   out,
+		MaxAbs:   0.001,
+		Calendar: Calendar{{Start: start, End: start.Add(time.Minute), Channels: []string{"1"}}},
+	}
+	require.NoError(t, w.Validate())
+	entries := []*Entry{
+		sample("calnex01", "1", 900, 0.0001),
+		sample("calnex01", "1", 901, -0.002),
+		sample("calnex01", "1", 902, math.NaN()),
+		sample("calnex01", "1", 1000, 0.0001),
+		sample("calnex01", "2", 1000, 0.0001),
+		sample("calnex01", "1", 1000, 1),
+	}
+	for _, e := range entries {
+		require.NoError(t, w.Write(e))
+	}
+	require.Equal(t, []*Entry{entries[0], entries[4]}, out.entries)
+	require.Equal(t, 3, w.Bogus)
+	require.Equal(t, 1, w.Maintenance)
+	require.NoError(t, w.Close())
+
+	require.ErrorIs(t, (&FilterWriter{MaxAbs: -1}).Validate(), errBadMaxAbs)
+}
+
+func TestFilterWriterDisabled(t *testing.T) {
+	out := &recordWriter{}
+	w := &FilterWriter{Output: out}
+	e := sample("calnex01", "1", 1000, 1e9)
+	require.NoError(t, w.Write(e))
+	require.Equal(t, []*Entry{e}, out.entries)
+}
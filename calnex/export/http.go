@@ -0,0 +1,200 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Supported compression of HTTP uploads
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// DefaultBatchSize is how many entries are uploaded in a single request by default
+const DefaultBatchSize = 1000
+
+var (
+	errCompression   = errors.New("unsupported compression")
+	errPartialUpload = errors.New("some batches failed to upload")
+)
+
+// UploadReport is a summary of HTTP uploads
+type UploadReport struct {
+	Batches       int      `json:"batches"`
+	Entries       int      `json:"entries"`
+	FailedBatches int      `json:"failed_batches"`
+	FailedEntries int      `json:"failed_entries"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+// HTTPWriter uploads entries to the URL as JSON lines in batches.
+// A failed batch doesn't stop the export, failures are reported on Close
+type HTTPWriter struct {
+	URL         string
+	BatchSize   int
+	Compression string
+	// Retries of a batch failed with network error, 429 or 5xx
+	Retries    int
+	RetryDelay time.Duration
+	Client     *http.Client
+
+	batch  []*Entry
+	report UploadReport
+}
+
+// NewHTTPWriter returns HTTPWriter uploading to the URL with defaults
+func NewHTTPWriter(url string) *HTTPWriter {
+	return &HTTPWriter{
+		URL:         url,
+		BatchSize:   DefaultBatchSize,
+		Compression: CompressionGzip,
+		Retries:     3,
+		RetryDelay:  time.Second,
+		Client:      &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Write adds the entry to the batch and uploads the batch once full
+func (h *HTTPWriter) Write(entry *Entry) error {
+	switch h.Compression {
+	case CompressionNone, CompressionGzip:
+	default:
+		return fmt.Errorf("%w: %q", errCompression, h.Compression)
+	}
+	h.batch = append(h.batch, entry)
+	if len(h.batch) >= h.BatchSize {
+		h.flush()
+	}
+	return nil
+}
+
+// Close uploads the remaining entries. It returns an error if any batch failed
+func (h *HTTPWriter) Close() error {
+	h.flush()
+	if h.report.FailedBatches > 0 {
+		return fmt.Errorf("%w: %d of %d batches, %d of %d entries", errPartialUpload,
+			h.report.FailedBatches, h.report.Batches, h.report.FailedEntries, h.report.Entries)
+	}
+	return nil
+}
+
+// Report returns summary of uploads so far
+func (h *HTTPWriter) Report() UploadReport {
+	return h.report
+}
+
+// flush uploads the batch and records the outcome
+func (h *HTTPWriter) flush() {
+	if len(h.batch) == 0 {
+		return
+	}
+	n := len(h.batch)
+	h.report.Batches++
+	h.report.Entries += n
+	err := h.upload(h.batch)
+	h.batch = h.batch[:0]
+	if err != nil {
+		log.Errorf("Failed to upload batch of %d entries: %v", n, err)
+		h.report.FailedBatches++
+		h.report.FailedEntries += n
+		h.report.Errors = append(h.report.Errors, err.Error())
+	}
+}
+
+// encode returns batch as compressed JSON lines
+func (h *HTTPWriter) encode(batch []*Entry) ([]byte, error) {
+	var b bytes.Buffer
+	var w io.Writer = &b
+	var gz *gzip.Writer
+	if h.Compression == CompressionGzip {
+		gz = gzip.NewWriter(&b)
+		w = gz
+	}
+	e := json.NewEncoder(w)
+	for _, entry := range batch {
+		if err := e.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// retriable returns true if the request may succeed later
+func retriable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// upload posts the batch retrying temporary failures with exponential backoff
+func (h *HTTPWriter) upload(batch []*Entry) error {
+	body, err := h.encode(batch)
+	if err != nil {
+		return err
+	}
+	delay := h.RetryDelay
+	for attempt := 0; ; attempt++ {
+		code, err := h.post(body)
+		if err == nil {
+			return nil
+		}
+		if (code != 0 && !retriable(code)) || attempt >= h.Retries {
+			return err
+		}
+		log.Warningf("Upload attempt %d failed, retrying in %v: %v", attempt+1, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post sends a single request. Status code is 0 on network errors
+func (h *HTTPWriter) post(body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if h.Compression == CompressionGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain to reuse the connection
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("upload failed: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testEntry(t int) *Entry {
+	return &Entry{
+		Float:  &FloatData{Value: 0.000001},
+		Int:    &IntData{Time: t},
+		Normal: &NormalData{Channel: "1", Target: "ntp01", Protocol: "ntp", Source: "calnex01"},
+	}
+}
+
+// uploadServer records uploaded lines per request and responds with codes in order, then 200
+type uploadServer struct {
+	sync.Mutex
+	codes    []int
+	requests int
+	batches  [][]string
+}
+
+func (u *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.Lock()
+	defer u.Unlock()
+	u.requests++
+	if len(u.codes) > 0 {
+		code := u.codes[0]
+		u.codes = u.codes[1:]
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	u.batches = append(u.batches, strings.Split(strings.TrimSpace(string(b)), "\n"))
+}
+
+func testHTTPWriter(url string) *HTTPWriter {
+	h := NewHTTPWriter(url)
+	h.BatchSize = 2
+	h.RetryDelay = 0
+	return h
+}
+
+func TestHTTPWriterBatches(t *testing.T) {
+	for _, compression := range []string{CompressionGzip, CompressionNone} {
+		u := &uploadServer{}
+		ts := httptest.NewServer(u)
+		h := testHTTPWriter(ts.URL)
+		h.Compression = compression
+		for i := 0; i < 5; i++ {
+			require.NoError(t, h.Write(testEntry(i)))
+		}
+		require.Len(t, u.batches, 2)
+		require.NoError(t, h.Close())
+		ts.Close()
+
+		require.Len(t, u.batches, 3)
+		require.Equal(t, []int{2, 2, 1}, []int{len(u.batches[0]), len(u.batches[1]), len(u.batches[2])})
+		require.Contains(t, u.batches[2][0], "\"time\":4")
+		require.Equal(t, UploadReport{Batches: 3, Entries: 5}, h.Report())
+	}
+}
+
+func TestHTTPWriterRetry(t *testing.T) {
+	u := &uploadServer{codes: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	ts := httptest.NewServer(u)
+	defer ts.Close()
+	h := testHTTPWriter(ts.URL)
+	require.NoError(t, h.Write(testEntry(1)))
+	require.NoError(t, h.Close())
+	require.Equal(t, 3, u.requests)
+	require.Len(t, u.batches, 1)
+}
+
+func TestHTTPWriterPartialFailure(t *testing.T) {
+	// first batch is rejected, it's not retried
+	u := &uploadServer{codes: []int{http.StatusBadRequest}}
+	ts := httptest.NewServer(u)
+	defer ts.Close()
+	h := testHTTPWriter(ts.URL)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, h.Write(testEntry(i)))
+	}
+	err := h.Close()
+	require.ErrorIs(t, err, errPartialUpload)
+	require.Equal(t, 2, u.requests)
+	r := h.Report()
+	require.Equal(t, 2, r.Batches)
+	require.Equal(t, 1, r.FailedBatches)
+	require.Equal(t, 2, r.FailedEntries)
+	require.Equal(t, []string{"upload failed: 400 Bad Request"}, r.Errors)
+}
+
+func TestHTTPWriterRetriesExhausted(t *testing.T) {
+	u := &uploadServer{codes: []int{500, 500, 500, 500}}
+	ts := httptest.NewServer(u)
+	defer ts.Close()
+	h := testHTTPWriter(ts.URL)
+	require.NoError(t, h.Write(testEntry(1)))
+	require.ErrorIs(t, h.Close(), errPartialUpload)
+	require.Equal(t, 4, u.requests)
+}
+
+func TestHTTPWriterCompression(t *testing.T) {
+	h := testHTTPWriter("http://localhost")
+	h.Compression = "zstd"
+	require.ErrorIs(t, h.Write(testEntry(1)), errCompression)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift
+const (
+	parquetMagic = "PAR1"
+
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetUTF8     = 0
+
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+
+	parquetCreatedBy = "github.com/facebook/time/calnex"
+)
+
+// parquetColumn is a column of the exported data
+type parquetColumn struct {
+	name  string
+	typ   int32
+	value func(e *Entry, b *bytes.Buffer)
+}
+
+var parquetColumns = []parquetColumn{
+	{name: "time", typ: parquetInt64, value: func(e *Entry, b *bytes.Buffer) {
+		_ = binary.Write(b, binary.LittleEndian, int64(e.Int.Time))
+	}},
+	{name: "value", typ: parquetDouble, value: func(e *Entry, b *bytes.Buffer) {
+		_ = binary.Write(b, binary.LittleEndian, math.Float64bits(e.Float.Value))
+	}},
+	{name: "channel", typ: parquetByteArray, value: func(e *Entry, b *bytes.Buffer) { parquetString(b, e.Normal.Channel) }},
+	{name: "target", typ: parquetByteArray, value: func(e *Entry, b *bytes.Buffer) { parquetString(b, e.Normal.Target) }},
+	{name: "protocol", typ: parquetByteArray, value: func(e *Entry, b *bytes.Buffer) { parquetString(b, e.Normal.Protocol) }},
+	{name: "source", typ: parquetByteArray, value: func(e *Entry, b *bytes.Buffer) { parquetString(b, e.Normal.Source) }},
+}
+
+func parquetString(b *bytes.Buffer, s string) {
+	_ = binary.Write(b, binary.LittleEndian, uint32(len(s)))
+	b.WriteString(s)
+}
+
+// WriteParquet writes entries as a single row group uncompressed parquet file
+func WriteParquet(w io.Writer, entries []*Entry) error {
+	// offset is tracked to fill column chunk metadata
+	offset := int64(len(parquetMagic))
+	if _, err := io.WriteString(w, parquetMagic); err != nil {
+		return err
+	}
+
+	chunks := make([]*thriftWriter, 0, len(parquetColumns))
+	var totalSize int64
+	for _, c := range parquetColumns {
+		data := &bytes.Buffer{}
+		for _, e := range entries {
+			c.value(e, data)
+		}
+
+		header := newThriftWriter()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(data.Len()))
+		header.i32(3, int32(data.Len()))
+		header.structField(5)
+		header.i32(1, int32(len(entries)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		if _, err := w.Write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := w.Write(data.Bytes()); err != nil {
+			return err
+		}
+		size := int64(header.buf.Len() + data.Len())
+
+		// ColumnChunk
+		chunk := newThriftWriter()
+		chunk.begin()
+		chunk.i64(2, offset)
+		chunk.structField(3)
+		chunk.i32(1, c.typ)
+		chunk.i32List(2, parquetPlain)
+		chunk.stringList(3, c.name)
+		chunk.i32(4, parquetUncompressed)
+		chunk.i64(5, int64(len(entries)))
+		chunk.i64(6, size)
+		chunk.i64(7, size)
+		chunk.i64(9, offset)
+		chunk.end()
+		chunk.end()
+		chunks = append(chunks, chunk)
+
+		offset += size
+		totalSize += size
+	}
+
+	// FileMetaData
+	meta := newThriftWriter()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(parquetColumns)+1)
+	meta.begin()
+	meta.string(4, "schema")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.end()
+	for _, c := range parquetColumns {
+		meta.begin()
+		meta.i32(1, c.typ)
+		meta.i32(3, parquetRequired)
+		meta.string(4, c.name)
+		if c.typ == parquetByteArray {
+			meta.i32(6, parquetUTF8)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(len(entries)))
+	meta.list(4, thriftStruct, 1)
+	meta.begin()
+	meta.list(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		meta.buf.Write(chunk.buf.Bytes())
+	}
+	meta.i64(2, totalSize)
+	meta.i64(3, int64(len(entries)))
+	meta.end()
+	meta.string(6, parquetCreatedBy)
+	meta.end()
+
+	if _, err := w.Write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(meta.buf.Len())); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, parquetMagic)
+	return err
+}
+
+type parquetPartition struct {
+	source  string
+	channel string
+	date    string
+}
+
+// path returns hive style partition path
+func (p parquetPartition) path(dir string) string {
+	return filepath.Join(dir, "source="+p.source, "channel="+p.channel, "date="+p.date)
+}
+
+// ParquetWriter writes entries as parquet files partitioned by device, channel and date (UTC).
+// Entries are buffered in memory and written on Close
+type ParquetWriter struct {
+	Dir        string
+	partitions map[parquetPartition][]*Entry
+}
+
+// NewParquetWriter returns a ParquetWriter writing to dir
+func NewParquetWriter(dir string) *ParquetWriter {
+	return &ParquetWriter{Dir: dir, partitions: map[parquetPartition][]*Entry{}}
+}
+
+// Write buffers the entry in its partition
+func (p *ParquetWriter) Write(entry *Entry) error {
+	part := parquetPartition{
+		source:  entry.Normal.Source,
+		channel: entry.Normal.Channel,
+		date:    time.Unix(int64(entry.Int.Time), 0).UTC().Format("2006-01-02"),
+	}
+	p.partitions[part] = append(p.partitions[part], entry)
+	return nil
+}
+
+// Close writes every partition into <dir>/source=<source>/channel=<channel>/date=<date>/<first sample time>.parquet.
+// Export with the same first sample replaces the file
+func (p *ParquetWriter) Close() error {
+	for part, entries := range p.partitions {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Int.Time < entries[j].Int.Time })
+		dir := part.path(p.Dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := writeParquetFile(filepath.Join(dir, fmt.Sprintf("%d.parquet", entries[0].Int.Time)), entries); err != nil {
+			return err
+		}
+		delete(p.partitions, part)
+	}
+	return nil
+}
+
+// writeParquetFile atomically writes entries to the parquet file
+func writeParquetFile(path string, entries []*Entry) error {
+	return writeFileAtomic(path, func(w io.Writer) error { return WriteParquet(w, entries) })
+}
+
+// writeFileAtomic writes the file via a temporary one renamed on success
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
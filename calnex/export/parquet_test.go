@@ -0,0 +1,103 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parquetEntry(ts int, channel string) *Entry {
+	return &Entry{
+		Int:    &IntData{Time: ts},
+		Float:  &FloatData{Value: -2.50501e-7},
+		Normal: &NormalData{Channel: channel, Target: "localhost", Protocol: "ntp", Source: "calnex01.example.com"},
+	}
+}
+
+func TestThriftWriter(t *testing.T) {
+	w := newThriftWriter()
+	w.i32(1, 1)
+	w.i64(3, -1)
+	w.string(20, "a")
+	w.structField(21)
+	w.i32(1, 2)
+	w.end()
+	w.i32List(22, 1, 2)
+	require.Equal(t, []byte{
+		0x15, 0x02, // field 1 i32 1
+		0x26, 0x01, // field 3 i64 -1
+		0x08, 0x28, 0x01, 'a', // field 20 binary long form
+		0x1c, 0x15, 0x04, 0x00, // field 21 struct {field 1 i32 2}
+		0x19, 0x25, 0x02, 0x04, // field 22 list<i32> [1, 2]
+	}, w.buf.Bytes())
+}
+
+func TestWriteParquet(t *testing.T) {
+	entries := []*Entry{parquetEntry(1607961193, "1"), parquetEntry(1607961194, "1")}
+	var b bytes.Buffer
+	require.NoError(t, WriteParquet(&b, entries))
+
+	data := b.Bytes()
+	require.Equal(t, parquetMagic, string(data[:4]))
+	require.Equal(t, parquetMagic, string(data[len(data)-4:]))
+	metaLen := int(binary.LittleEndian.Uint32(data[len(data)-8 : len(data)-4]))
+	require.Less(t, metaLen, len(data))
+	meta := data[len(data)-8-metaLen : len(data)-8]
+	for _, c := range parquetColumns {
+		require.Contains(t, string(meta), c.name)
+	}
+	require.Contains(t, string(meta), parquetCreatedBy)
+	// plain encoded values
+	require.Contains(t, string(data), "calnex01.example.com")
+}
+
+func TestParquetWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := NewParquetWriter(dir)
+	// 2020-12-14 and 2020-12-15 UTC
+	require.NoError(t, w.Write(parquetEntry(1607990399, "1")))
+	require.NoError(t, w.Write(parquetEntry(1607990400, "1")))
+	require.NoError(t, w.Write(parquetEntry(1607990398, "1")))
+	require.NoError(t, w.Write(parquetEntry(1607990398, "2")))
+	require.NoError(t, w.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*", "*", "*.parquet"))
+	require.NoError(t, err)
+	rel := []string{}
+	for _, f := range files {
+		r, err := filepath.Rel(dir, f)
+		require.NoError(t, err)
+		rel = append(rel, r)
+	}
+	require.ElementsMatch(t, []string{
+		"source=calnex01.example.com/channel=1/date=2020-12-14/1607990398.parquet",
+		"source=calnex01.example.com/channel=1/date=2020-12-15/1607990400.parquet",
+		"source=calnex01.example.com/channel=2/date=2020-12-14/1607990398.parquet",
+	}, rel)
+
+	// nothing is left to write
+	require.NoError(t, w.Close())
+	data, err := ioutil.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Equal(t, parquetMagic, string(data[:4]))
+}
@@ -0,0 +1,200 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/facebook/time/calnex/api"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Units of sample values
+const (
+	UnitSecond      = "s"
+	UnitMillisecond = "ms"
+	UnitMicrosecond = "us"
+	UnitNanosecond  = "ns"
+)
+
+var unitToSeconds = map[string]float64{
+	UnitSecond:      1,
+	UnitMillisecond: 1e-3,
+	UnitMicrosecond: 1e-6,
+	UnitNanosecond:  1e-9,
+}
+
+var errBadClamp = errors.New("clamp min is above max")
+
+// Transform is applied to sample values of a channel before they are written.
+// Steps run in order: unit conversion, scale, sign flip, outlier clamping
+type Transform struct {
+	// From and To are units to convert values between: s, ms, us or ns. Both or none must be set
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	// Scale multiplies values. 0 means no scaling
+	Scale float64 `yaml:"scale"`
+	// Negate flips the sign, for probes reporting offsets from the opposite side
+	Negate bool `yaml:"negate"`
+	// Min and Max clamp outliers. Not clamped if nil
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+// Validate checks the transform
+func (t *Transform) Validate() error {
+	if (t.From == "") != (t.To == "") {
+		return fmt.Errorf("both units are required to convert %q to %q", t.From, t.To)
+	}
+	if t.From != "" {
+		if _, ok := unitToSeconds[t.From]; !ok {
+			return fmt.Errorf("unsupported unit %q", t.From)
+		}
+		if _, ok := unitToSeconds[t.To]; !ok {
+			return fmt.Errorf("unsupported unit %q", t.To)
+		}
+	}
+	if t.Min != nil && t.Max != nil && *t.Min > *t.Max {
+		return errBadClamp
+	}
+	return nil
+}
+
+// Apply returns the transformed value and whether it was clamped
+func (t *Transform) Apply(v float64) (float64, bool) {
+	if t.From != "" {
+		v = v * unitToSeconds[t.From] / unitToSeconds[t.To]
+	}
+	if t.Scale != 0 {
+		v *= t.Scale
+	}
+	if t.Negate {
+		v = -v
+	}
+	if t.Min != nil && v < *t.Min {
+		return *t.Min, true
+	}
+	if t.Max != nil && v > *t.Max {
+		return *t.Max, true
+	}
+	return v, false
+}
+
+// Transforms are keyed by channel name such as 1 or c, or by protocol such as ntp or ptp.
+// Channel transform takes precedence over protocol one.
+// Example:
+//
+//	ntp:        # NTP offsets in s to ns
+//	  from: s
+//	  to: ns
+//	c:
+//	  negate: true
+//	  min: -1000000
+//	  max: 1000000
+type Transforms map[string]*Transform
+
+// ReadTransforms reads and validates transforms from the yaml
+func ReadTransforms(r io.Reader) (Transforms, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	raw := Transforms{}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(true)
+	if err := d.Decode(&raw); err != nil && err != io.EOF {
+		return nil, err
+	}
+	// normalize keys, so both "C" and "c" match the channel
+	ts := Transforms{}
+	for k, t := range raw {
+		if t == nil {
+			continue
+		}
+		if err := t.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		name := strings.ToLower(k)
+		if c, err := api.ChannelFromString(name); err == nil {
+			ts[c.String()] = t
+			continue
+		}
+		if p, err := api.ProbeFromString(name); err == nil {
+			ts[p.String()] = t
+			continue
+		}
+		return nil, fmt.Errorf("%q is neither a channel nor a protocol", k)
+	}
+	return ts, nil
+}
+
+// ReadTransformsFile reads and validates transforms from the yaml file
+func ReadTransformsFile(path string) (Transforms, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := ReadTransforms(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return ts, nil
+}
+
+// find returns transform of the entry, nil if there is none
+func (ts Transforms) find(entry *Entry) *Transform {
+	if entry.Normal == nil {
+		return nil
+	}
+	if t, ok := ts[entry.Normal.Channel]; ok {
+		return t
+	}
+	return ts[entry.Normal.Protocol]
+}
+
+// TransformWriter transforms values of entries before passing them to the output
+type TransformWriter struct {
+	Output     EntryWriter
+	Transforms Transforms
+	// Clamped counts values clamped as outliers
+	Clamped int
+}
+
+// Write transforms a single entry and writes it to the output
+func (w *TransformWriter) Write(entry *Entry) error {
+	if t := w.Transforms.find(entry); t != nil && entry.Float != nil {
+		v, clamped := t.Apply(entry.Float.Value)
+		if clamped {
+			w.Clamped++
+		}
+		entry.Float.Value = v
+	}
+	return w.Output.Write(entry)
+}
+
+// Close closes the output if it's closable
+func (w *TransformWriter) Close() error {
+	if c, ok := w.Output.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordWriter struct {
+	entries []*Entry
+}
+
+func (r *recordWriter) Write(entry *Entry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func TestTransformApply(t *testing.T) {
+	min, max := -100.0, 100.0
+	tr := &Transform{From: UnitSecond, To: UnitNanosecond}
+	v, clamped := tr.Apply(0.000001)
+	require.InDelta(t, 1000.0, v, 1e-9)
+	require.False(t, clamped)
+
+	tr = &Transform{From: UnitNanosecond, To: UnitMicrosecond, Scale: 2, Negate: true, Min: &min, Max: &max}
+	v, clamped = tr.Apply(5000)
+	require.InDelta(t, -10.0, v, 1e-9)
+	require.False(t, clamped)
+	v, clamped = tr.Apply(-500000)
+	require.Equal(t, max, v)
+	require.True(t, clamped)
+	v, clamped = tr.Apply(500000)
+	require.Equal(t, min, v)
+	require.True(t, clamped)
+
+	// no-op
+	v, clamped = (&Transform{}).Apply(42)
+	require.Equal(t, 42.0, v)
+	require.False(t, clamped)
+}
+
+func TestTransformValidate(t *testing.T) {
+	min, max := 1.0, -1.0
+	require.NoError(t, (&Transform{}).Validate())
+	require.NoError(t, (&Transform{From: UnitMillisecond, To: UnitSecond}).Validate())
+	require.Error(t, (&Transform{From: UnitSecond}).Validate())
+	require.Error(t, (&Transform{From: "min", To: UnitSecond}).Validate())
+	require.Error(t, (&Transform{From: UnitSecond, To: "h"}).Validate())
+	require.ErrorIs(t, (&Transform{Min: &min, Max: &max}).Validate(), errBadClamp)
+}
+
+func TestReadTransforms(t *testing.T) {
+	ts, err := ReadTransforms(strings.NewReader("ntp:\n  from: s\n  to: ns\nC:\n  negate: true\n  max: 10\n"))
+	require.NoError(t, err)
+	require.Len(t, ts, 2)
+	require.Equal(t, UnitNanosecond, ts["ntp"].To)
+	require.True(t, ts["c"].Negate)
+	require.Equal(t, 10.0, *ts["c"].Max)
+
+	ts, err = ReadTransforms(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Empty(t, ts)
+
+	_, err = ReadTransforms(strings.NewReader("x:\n  negate: true\n"))
+	require.Error(t, err)
+	_, err = ReadTransforms(strings.NewReader("1:\n  invert: true\n"))
+	require.Error(t, err)
+	_, err = ReadTransforms(strings.NewReader("1:\n  from: s\n"))
+	require.Error(t, err)
+}
+
+func TestReadTransformsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transforms.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("1:\n  scale: 1000\n"), 0644))
+	ts, err := ReadTransformsFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 1000.0, ts["1"].Scale)
+
+	_, err = ReadTransformsFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestTransformWriter(t *testing.T) {
+	max := 1000.0
+	out := &recordWriter{}
+	w := &TransformWriter{
+		Output: out,
+		Transforms: Transforms{
+			"ntp": {From: UnitSecond, To: UnitNanosecond},
+			"c":   {From: UnitSecond, To: UnitNanosecond, Negate: true, Max: &max},
+		},
+	}
+	entries := []*Entry{
+		{Float: &FloatData{Value: 0.0000005}, Normal: &NormalData{Channel: "1", Protocol: "ntp"}},
+		{Float: &FloatData{Value: -0.000002}, Normal: &NormalData{Channel: "c", Protocol: "ntp"}},
+		{Float: &FloatData{Value: 0.5}, Normal: &NormalData{Channel: "2", Protocol: "ptp"}},
+	}
+	for _, e := range entries {
+		require.NoError(t, w.Write(e))
+	}
+	require.Len(t, out.entries, 3)
+	require.InDelta(t, 500.0, out.entries[0].Float.Value, 1e-9)
+	// channel transform takes precedence and clamps
+	require.Equal(t, 1000.0, out.entries[1].Float.Value)
+	// no transform
+	require.Equal(t, 0.5, out.entries[2].Float.Value)
+	require.Equal(t, 1, w.Clamped)
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,72 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	errBadWindow         = errors.New("window start must be before its end")
+	errClockOffset       = errors.New("device clock offset is too large")
+	errWindowNotComplete = errors.New("window is not complete according to the device clock")
+)
+
+// Window is a [Start, End) range of the device clock to export samples from.
+// Consecutive windows sharing boundaries never overlap nor miss samples
+type Window struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// LastWindow returns the latest complete window of the duration d aligned to d, for example the previous hour
+func LastWindow(now time.Time, d time.Duration) Window {
+	end := now.Truncate(d)
+	return Window{Start: end.Add(-d), End: end}
+}
+
+// Validate checks the window is not empty
+func (w Window) Validate() error {
+	if !w.Start.Before(w.End) {
+		return errBadWindow
+	}
+	return nil
+}
+
+// Contains returns true if the sample time in unix seconds belongs to the window
+func (w Window) Contains(ts int64) bool {
+	return ts >= w.Start.Unix() && ts < w.End.Unix()
+}
+
+func (w Window) String() string {
+	return fmt.Sprintf("[%s, %s)", w.Start.UTC().Format(time.RFC3339), w.End.UTC().Format(time.RFC3339))
+}
+
+// checkClock verifies the window is complete according to the device clock.
+// Samples are timestamped by the device, so exporting a window the device hasn't reached yet
+// would miss samples, and a device clock too far away from ours means boundaries are meaningless
+func (w Window) checkClock(now time.Time, offset, maxOffset time.Duration) error {
+	if maxOffset > 0 && (offset > maxOffset || offset < -maxOffset) {
+		return fmt.Errorf("%w: %v exceeds %v", errClockOffset, offset, maxOffset)
+	}
+	if device := now.Add(offset); device.Before(w.End) {
+		return fmt.Errorf("%w: %s, device time is %s", errWindowNotComplete, w, device.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastWindow(t *testing.T) {
+	now := time.Date(2021, 12, 14, 15, 53, 13, 0, time.UTC)
+	w := LastWindow(now, time.Hour)
+	require.Equal(t, time.Date(2021, 12, 14, 14, 0, 0, 0, time.UTC), w.Start)
+	require.Equal(t, time.Date(2021, 12, 14, 15, 0, 0, 0, time.UTC), w.End)
+	require.NoError(t, w.Validate())
+	require.Equal(t, "[2021-12-14T14:00:00Z, 2021-12-14T15:00:00Z)", w.String())
+
+	// next window starts exactly where the previous one ends
+	next := LastWindow(now.Add(time.Hour), time.Hour)
+	require.Equal(t, w.End, next.Start)
+}
+
+func TestWindowContains(t *testing.T) {
+	w := Window{Start: time.Unix(100, 0), End: time.Unix(200, 0)}
+	require.False(t, w.Contains(99))
+	require.True(t, w.Contains(100))
+	require.True(t, w.Contains(199))
+	require.False(t, w.Contains(200))
+}
+
+func TestWindowValidate(t *testing.T) {
+	require.ErrorIs(t, Window{Start: time.Unix(100, 0), End: time.Unix(100, 0)}.Validate(), errBadWindow)
+	require.ErrorIs(t, Window{Start: time.Unix(200, 0), End: time.Unix(100, 0)}.Validate(), errBadWindow)
+}
+
+func TestWindowCheckClock(t *testing.T) {
+	now := time.Date(2021, 12, 14, 15, 0, 30, 0, time.UTC)
+	w := LastWindow(now, time.Hour)
+	require.NoError(t, w.checkClock(now, 0, time.Second))
+	require.NoError(t, w.checkClock(now, -20*time.Second, 0))
+	require.ErrorIs(t, w.checkClock(now, 2*time.Second, time.Second), errClockOffset)
+	// device is behind and hasn't finished the window yet
+	require.ErrorIs(t, w.checkClock(now, -time.Minute, 0), errWindowNotComplete)
+}
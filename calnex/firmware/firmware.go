@@ -17,7 +17,9 @@ limitations under the License.
 package firmware
 
 import (
+	"context"
 	"strings"
+	"time"
 
 	"github.com/facebook/time/calnex/api"
 	version "github.com/hashicorp/go-version"
@@ -28,14 +30,17 @@ import (
 type FW interface {
 	// Version returns latest fw version available
 	Version() (*version.Version, error)
-	// Path returns local FW path
+	// Path returns local FW path. It fails if the image can't be verified
 	Path() (string, error)
 }
 
+// upgradeTimeout is how long it takes to install the firmware and reboot
+const upgradeTimeout = 30 * time.Minute
+
 // Firmware checks target Calnex firmware version via protocol and upgrades if apply is specified
 func Firmware(target string, insecureTLS bool, fw FW, apply bool) error {
-	api := api.NewAPI(target, insecureTLS)
-	cv, err := api.FetchVersion()
+	calnexAPI := api.NewAPI(target, insecureTLS)
+	cv, err := calnexAPI.FetchVersion()
 	if err != nil {
 		return err
 	}
@@ -55,27 +60,45 @@ func Firmware(target string, insecureTLS bool, fw FW, apply bool) error {
 
 	log.Infof("%s is running %s, latest is %s. Needs an update", target, calnexVersion, v)
 
+	// image is verified before anything is changed on the device
+	p, err := fw.Path()
+	if err != nil {
+		return err
+	}
+
 	if !apply {
 		log.Infof("dry run. Exiting")
 		return nil
 	}
 
-	status, err := api.FetchStatus()
+	status, err := calnexAPI.FetchStatus()
 	if err != nil {
 		return err
 	}
 	if status.MeasurementActive {
 		log.Infof("stopping measurement")
 		// stop measurement
-		if err = api.StopMeasure(); err != nil {
+		if err = calnexAPI.StopMeasure(); err != nil {
 			return err
 		}
 	}
 	log.Infof("updating firmware")
-	p, err := fw.Path()
-	if err != nil {
+	if _, err = calnexAPI.PushVersion(p); err != nil {
 		return err
 	}
-	_, err = api.PushVersion(p)
-	return err
+
+	log.Infof("waiting for %s to run %s", target, v)
+	ctx, cancel := context.WithTimeout(context.Background(), upgradeTimeout)
+	defer cancel()
+	return api.WaitForOperation(ctx, calnexAPI.PollInterval, func() (bool, error) {
+		cv, err := calnexAPI.FetchVersion()
+		if err != nil {
+			return false, err
+		}
+		calnexVersion, err := version.NewVersion(strings.ToLower(cv.Firmware))
+		if err != nil {
+			return false, err
+		}
+		return calnexVersion.GreaterThanOrEqual(v), nil
+	})
 }
@@ -46,11 +46,12 @@ func TestFirmware(t *testing.T) {
 		Filepath: filepath,
 	}
 
+	installed := "2.11.1.0.5583D-20210924"
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter,
 		r *http.Request) {
 		if strings.Contains(r.URL.Path, "version") {
 			// FetchVersion
-			fmt.Fprintln(w, "{ \"firmware\": \"2.11.1.0.5583D-20210924\" }")
+			fmt.Fprintf(w, "{ \"firmware\": \"%s\" }\n", installed)
 		} else if strings.Contains(r.URL.Path, "getstatus") {
 			// FetchStatus
 			fmt.Fprintln(w, "{\n\"referenceReady\": true,\n\"modulesReady\": true,\n\"measurementActive\": true\n}")
@@ -59,6 +60,7 @@ func TestFirmware(t *testing.T) {
 			fmt.Fprintln(w, "{\n\"result\": true\n}")
 		} else if strings.Contains(r.URL.Path, "updatefirmware") {
 			// PushVersion
+			installed = "2.13.1.0.5583D-20210924"
 			fmt.Fprintln(w, "{\n\"result\": true\n}")
 		}
 	}))
@@ -26,6 +26,8 @@ import (
 // OSSFW is an open source implementation of the FW interface
 type OSSFW struct {
 	Filepath string
+	// Verifiers must all pass before the file path is returned
+	Verifiers []Verifier
 }
 
 // Version downloads latest firmware version
@@ -37,7 +39,12 @@ func (f *OSSFW) Version() (*version.Version, error) {
 	return v, err
 }
 
-// Path downloads latest firmware version
+// Path downloads latest firmware version and verifies it
 func (f *OSSFW) Path() (string, error) {
+	for _, v := range f.Verifiers {
+		if err := v.Verify(f.Filepath); err != nil {
+			return "", err
+		}
+	}
 	return f.Filepath, nil
 }
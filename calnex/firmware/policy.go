@@ -0,0 +1,240 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firmware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/facebook/time/calnex/api"
+	version "github.com/hashicorp/go-version"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	errNoAllowedVersions   = errors.New("policy must allow at least one version")
+	errRecommendedDisallow = errors.New("recommended version is not allowed by the policy")
+	errFirmwareDisallowed  = errors.New("firmware file version is not allowed by the policy")
+)
+
+// Policy describes firmware versions allowed in the fleet.
+// Example:
+//
+//	allowed:
+//	  - ">= 2.13.1, < 3.0"
+//	  - "3.1.0"
+//	recommended: "2.13.1"
+type Policy struct {
+	// Allowed are version constraints, device is compliant if it satisfies any of them
+	Allowed []string `yaml:"allowed"`
+	// Recommended is the version non-compliant devices should be upgraded to
+	Recommended string `yaml:"recommended"`
+
+	constraints []version.Constraints
+	recommended *version.Version
+}
+
+// ReadPolicy reads and validates policy from the yaml file
+func ReadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(true)
+	if err := d.Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate parses version constraints and checks recommended version is allowed
+func (p *Policy) Validate() error {
+	if len(p.Allowed) == 0 {
+		return errNoAllowedVersions
+	}
+	p.constraints = nil
+	for _, a := range p.Allowed {
+		c, err := version.NewConstraint(a)
+		if err != nil {
+			return fmt.Errorf("invalid allowed version %q: %w", a, err)
+		}
+		p.constraints = append(p.constraints, c)
+	}
+	p.recommended = nil
+	if p.Recommended != "" {
+		v, err := version.NewVersion(strings.ToLower(p.Recommended))
+		if err != nil {
+			return fmt.Errorf("invalid recommended version %q: %w", p.Recommended, err)
+		}
+		if !p.Allows(v) {
+			return fmt.Errorf("%w: %s", errRecommendedDisallow, p.Recommended)
+		}
+		p.recommended = v
+	}
+	return nil
+}
+
+// Allows checks if the version satisfies any of the allowed constraints. Policy must be validated
+func (p *Policy) Allows(v *version.Version) bool {
+	// constraints don't match pre-release versions such as 2.13.1.0.5583D, compare the core
+	core, err := version.NewVersion(coreVersion(v))
+	if err != nil {
+		core = v
+	}
+	for _, c := range p.constraints {
+		if c.Check(v) || c.Check(core) {
+			return true
+		}
+	}
+	return false
+}
+
+// coreVersion strips pre-release and metadata
+func coreVersion(v *version.Version) string {
+	segments := v.Segments()
+	s := make([]string, len(segments))
+	for i, seg := range segments {
+		s[i] = fmt.Sprintf("%d", seg)
+	}
+	return strings.Join(s, ".")
+}
+
+// DeviceCompliance is firmware compliance of a single device
+type DeviceCompliance struct {
+	Target    string `json:"target"`
+	Version   string `json:"version,omitempty"`
+	Compliant bool   `json:"compliant"`
+	// Upgrade is the recommended version for non-compliant devices
+	Upgrade string `json:"upgrade,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ComplianceReport is firmware compliance of the fleet
+type ComplianceReport struct {
+	Devices      []*DeviceCompliance `json:"devices"`
+	Compliant    int                 `json:"compliant"`
+	NonCompliant int                 `json:"non_compliant"`
+	Unreachable  int                 `json:"unreachable"`
+}
+
+// NeedUpgrade returns targets of reachable non-compliant devices
+func (r *ComplianceReport) NeedUpgrade() []string {
+	targets := []string{}
+	for _, d := range r.Devices {
+		if d.Error == "" && !d.Compliant {
+			targets = append(targets, d.Target)
+		}
+	}
+	return targets
+}
+
+// Compliance fetches firmware versions of targets in parallel and checks them against the policy
+func Compliance(targets []string, insecureTLS bool, p *Policy) *ComplianceReport {
+	r := &ComplianceReport{Devices: make([]*DeviceCompliance, len(targets))}
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			r.Devices[i] = deviceCompliance(target, insecureTLS, p)
+		}(i, t)
+	}
+	wg.Wait()
+	sort.Slice(r.Devices, func(i, j int) bool { return r.Devices[i].Target < r.Devices[j].Target })
+	for _, d := range r.Devices {
+		switch {
+		case d.Error != "":
+			r.Unreachable++
+		case d.Compliant:
+			r.Compliant++
+		default:
+			r.NonCompliant++
+		}
+	}
+	return r
+}
+
+func deviceCompliance(target string, insecureTLS bool, p *Policy) *DeviceCompliance {
+	d := &DeviceCompliance{Target: target}
+	cv, err := api.NewAPI(target, insecureTLS).FetchVersion()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Version = cv.Firmware
+	v, err := version.NewVersion(strings.ToLower(cv.Firmware))
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Compliant = p.Allows(v)
+	if !d.Compliant {
+		d.Upgrade = p.Recommended
+	}
+	return d
+}
+
+// Remediate upgrades non-compliant devices to fw in stages of stageSize devices.
+// Next stage starts only after all devices of the previous one were upgraded successfully
+func Remediate(r *ComplianceReport, insecureTLS bool, p *Policy, fw FW, stageSize int, apply bool) error {
+	v, err := fw.Version()
+	if err != nil {
+		return err
+	}
+	if !p.Allows(v) {
+		return fmt.Errorf("%w: %s", errFirmwareDisallowed, v)
+	}
+	targets := r.NeedUpgrade()
+	if stageSize <= 0 {
+		stageSize = len(targets)
+	}
+	for start := 0; start < len(targets); start += stageSize {
+		end := start + stageSize
+		if end > len(targets) {
+			end = len(targets)
+		}
+		stage := targets[start:end]
+		log.Infof("stage %d: upgrading %s to %s", start/stageSize+1, strings.Join(stage, ", "), v)
+		var wg sync.WaitGroup
+		errs := make([]error, len(stage))
+		for i, t := range stage {
+			wg.Add(1)
+			go func(i int, target string) {
+				defer wg.Done()
+				errs[i] = Firmware(target, insecureTLS, fw, apply)
+			}(i, t)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				return fmt.Errorf("stage %d failed on %s, stopping: %w", start/stageSize+1, stage[i], err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firmware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/require"
+)
+
+func testPolicy(t *testing.T) *Policy {
+	p := &Policy{
+		Allowed:     []string{">= 2.13.1, < 3.0", "3.1.0"},
+		Recommended: "2.13.1",
+	}
+	require.NoError(t, p.Validate())
+	return p
+}
+
+func TestPolicyAllows(t *testing.T) {
+	p := testPolicy(t)
+	for v, allowed := range map[string]bool{
+		"2.13.1.0.5583d-20210924": true,
+		"2.14":                    true,
+		"2.11.1.0.5583d-20210924": false,
+		"3.0.0":                   false,
+		"3.1.0":                   true,
+	} {
+		require.Equal(t, allowed, p.Allows(version.Must(version.NewVersion(v))), v)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	require.ErrorIs(t, (&Policy{}).Validate(), errNoAllowedVersions)
+	require.Error(t, (&Policy{Allowed: []string{"latest"}}).Validate())
+	err := (&Policy{Allowed: []string{">= 3.0"}, Recommended: "2.13"}).Validate()
+	require.ErrorIs(t, err, errRecommendedDisallow)
+}
+
+func TestReadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("allowed:\n  - \">= 2.13.1, < 3.0\"\nrecommended: \"2.13.1\"\n"), 0644))
+	p, err := ReadPolicy(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{">= 2.13.1, < 3.0"}, p.Allowed)
+	require.Equal(t, "2.13.1", p.Recommended)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("allow: []\n"), 0644))
+	_, err = ReadPolicy(path)
+	require.Error(t, err)
+}
+
+// fakeDevice serves FetchVersion and upgrades to upgradeTo on PushVersion
+func fakeDevice(installed, upgradeTo string) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "version"):
+			fmt.Fprintf(w, "{ \"firmware\": \"%s\" }\n", installed)
+		case strings.Contains(r.URL.Path, "getstatus"):
+			fmt.Fprintln(w, "{\n\"referenceReady\": true,\n\"modulesReady\": true,\n\"measurementActive\": false\n}")
+		case strings.Contains(r.URL.Path, "updatefirmware"):
+			installed = upgradeTo
+			fmt.Fprintln(w, "{\n\"result\": true\n}")
+		}
+	}))
+}
+
+func host(ts *httptest.Server) string {
+	parsed, _ := url.Parse(ts.URL)
+	return parsed.Host
+}
+
+func TestComplianceAndRemediate(t *testing.T) {
+	p := testPolicy(t)
+	old := fakeDevice("2.11.1.0.5583D-20210924", "2.13.1.0.5583D-20210924")
+	defer old.Close()
+	current := fakeDevice("2.13.1.0.5583D-20210924", "")
+	defer current.Close()
+	down := fakeDevice("", "")
+	down.Close()
+
+	r := Compliance([]string{host(old), host(current), host(down)}, true, p)
+	require.Equal(t, 1, r.Compliant)
+	require.Equal(t, 1, r.NonCompliant)
+	require.Equal(t, 1, r.Unreachable)
+	require.Equal(t, []string{host(old)}, r.NeedUpgrade())
+	for _, d := range r.Devices {
+		if d.Target == host(old) {
+			require.Equal(t, "2.13.1", d.Upgrade)
+			require.False(t, d.Compliant)
+		}
+	}
+
+	dir := t.TempDir()
+	fwPath := filepath.Join(dir, "sentinel_fw_v2.13.1.0.5583D-20210924.tar")
+	f, err := os.Create(fwPath)
+	require.NoError(t, err)
+	f.Close()
+	require.NoError(t, Remediate(r, true, p, &OSSFW{Filepath: fwPath}, 1, true))
+
+	r = Compliance([]string{host(old), host(current)}, true, p)
+	require.Equal(t, 2, r.Compliant)
+}
+
+func TestRemediateDisallowedFirmware(t *testing.T) {
+	p := testPolicy(t)
+	fw := &OSSFW{Filepath: "/tmp/sentinel_fw_v3.0.0.tar"}
+	err := Remediate(&ComplianceReport{}, true, p, fw, 1, true)
+	require.ErrorIs(t, err, errFirmwareDisallowed)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firmware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrVerification means firmware image is corrupted or tampered with and must not be uploaded
+var ErrVerification = errors.New("firmware verification failed")
+
+// Verifier checks integrity of the firmware image before it's uploaded to the device
+type Verifier interface {
+	Verify(path string) error
+}
+
+// sha256File returns SHA-256 digest of the file
+func sha256File(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// ChecksumVerifier checks SHA-256 of the image against the manifest in sha256sum format:
+// hex digest and file name per line
+type ChecksumVerifier struct {
+	Manifest string
+}
+
+// checksum returns expected digest of the file from the manifest
+func (v *ChecksumVerifier) checksum(name string) ([]byte, error) {
+	f, err := os.Open(v.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks files read in binary mode with *
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid checksum of %s in manifest %s: %v", ErrVerification, name, v.Manifest, err)
+		}
+		return sum, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: %s is not in manifest %s", ErrVerification, name, v.Manifest)
+}
+
+// Verify checks the image checksum matches the manifest
+func (v *ChecksumVerifier) Verify(path string) error {
+	expected, err := v.checksum(filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	sum, err := sha256File(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sum, expected) {
+		return fmt.Errorf("%w: %s checksum is %x, manifest has %x", ErrVerification, path, sum, expected)
+	}
+	return nil
+}
+
+// SignatureVerifier checks detached ed25519 signature of the image SHA-256 digest.
+// Digest is signed rather than the image, so images of hundreds of megabytes are never read into memory
+type SignatureVerifier struct {
+	PublicKey ed25519.PublicKey
+	// Signature is a file with base64 encoded signature. Image path with .sig suffix if empty
+	Signature string
+}
+
+// ParsePublicKey parses base64 encoded ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// Verify checks the image signature
+func (v *SignatureVerifier) Verify(path string) error {
+	sigPath := v.Signature
+	if sigPath == "" {
+		sigPath = path + ".sig"
+	}
+	encoded, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("%w: decoding signature %s: %v", ErrVerification, sigPath, err)
+	}
+	sum, err := sha256File(path)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(v.PublicKey, sum, sig) {
+		return fmt.Errorf("%w: %s signature is invalid", ErrVerification, path)
+	}
+	return nil
+}
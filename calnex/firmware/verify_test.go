@@ -0,0 +1,133 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firmware
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const fwName = "sentinel_fw_v2.13.1.0.5583D-20210924.tar"
+
+func writeImage(t *testing.T, dir string) string {
+	path := filepath.Join(dir, fwName)
+	require.NoError(t, ioutil.WriteFile(path, []byte("firmware image"), 0644))
+	return path
+}
+
+func TestChecksumVerifier(t *testing.T) {
+	dir := t.TempDir()
+	path := writeImage(t, dir)
+	sum := sha256.Sum256([]byte("firmware image"))
+
+	manifest := filepath.Join(dir, "SHA256SUMS")
+	content := fmt.Sprintf("%x  other.tar\n%x *%s\n", sha256.Sum256(nil), sum, fwName)
+	require.NoError(t, ioutil.WriteFile(manifest, []byte(content), 0644))
+	v := &ChecksumVerifier{Manifest: manifest}
+	require.NoError(t, v.Verify(path))
+
+	// corrupted image
+	require.NoError(t, ioutil.WriteFile(path, []byte("firmware imagf"), 0644))
+	err := v.Verify(path)
+	require.ErrorIs(t, err, ErrVerification)
+	require.Contains(t, err.Error(), "checksum is")
+
+	// image missing from the manifest
+	require.NoError(t, ioutil.WriteFile(manifest, []byte(fmt.Sprintf("%x  other.tar\n", sum)), 0644))
+	err = v.Verify(path)
+	require.ErrorIs(t, err, ErrVerification)
+	require.Contains(t, err.Error(), "is not in manifest")
+
+	require.NoError(t, ioutil.WriteFile(manifest, []byte(fmt.Sprintf("oleg  %s\n", fwName)), 0644))
+	require.ErrorIs(t, v.Verify(path), ErrVerification)
+
+	require.Error(t, (&ChecksumVerifier{Manifest: filepath.Join(dir, "missing")}).Verify(path))
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	dir := t.TempDir()
+	path := writeImage(t, dir)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte("firmware image"))
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, sum[:]))
+	require.NoError(t, ioutil.WriteFile(path+".sig", []byte(sig+"\n"), 0644))
+
+	parsed, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub))
+	require.NoError(t, err)
+	v := &SignatureVerifier{PublicKey: parsed}
+	require.NoError(t, v.Verify(path))
+
+	// tampered image
+	require.NoError(t, ioutil.WriteFile(path, []byte("evil image"), 0644))
+	err = v.Verify(path)
+	require.ErrorIs(t, err, ErrVerification)
+	require.Contains(t, err.Error(), "signature is invalid")
+
+	// explicit signature path
+	v.Signature = filepath.Join(dir, "garbage.sig")
+	require.NoError(t, ioutil.WriteFile(v.Signature, []byte("!!!"), 0644))
+	require.ErrorIs(t, v.Verify(path), ErrVerification)
+
+	v.Signature = filepath.Join(dir, "missing.sig")
+	require.Error(t, v.Verify(path))
+}
+
+func TestParsePublicKey(t *testing.T) {
+	_, err := ParsePublicKey("!!!")
+	require.Error(t, err)
+	_, err = ParsePublicKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	require.EqualError(t, err, "public key must be 32 bytes, got 5")
+}
+
+func TestFirmwareVerificationFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := writeImage(t, dir)
+	manifest := filepath.Join(dir, "SHA256SUMS")
+	require.NoError(t, ioutil.WriteFile(manifest, []byte(fmt.Sprintf("%x  %s\n", sha256.Sum256(nil), fwName)), 0644))
+	fw := &OSSFW{Filepath: path, Verifiers: []Verifier{&ChecksumVerifier{Manifest: manifest}}}
+
+	touched := false
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "version") {
+			fmt.Fprintln(w, `{"firmware": "2.11.1.0.5583D-20210924"}`)
+			return
+		}
+		touched = true
+	}))
+	defer ts.Close()
+	parsed, _ := url.Parse(ts.URL)
+
+	err := Firmware(parsed.Host, true, fw, true)
+	require.ErrorIs(t, err, ErrVerification)
+	// neither measurement is stopped nor image is uploaded
+	require.False(t, touched)
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+}
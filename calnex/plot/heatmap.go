@@ -0,0 +1,95 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plot
+
+import (
+	"fmt"
+	"io"
+)
+
+// Default number of heatmap cells
+const (
+	TimeBuckets   = 96
+	OffsetBuckets = 24
+)
+
+// Heatmap is a number of samples per time and offset bucket
+type Heatmap struct {
+	Counts [][]int
+	Max    int
+}
+
+// NewHeatmap buckets points of the series. Counts are indexed by time bucket, then by offset bucket
+func NewHeatmap(s *Series, timeBuckets, offsetBuckets int) *Heatmap {
+	h := &Heatmap{Counts: make([][]int, timeBuckets)}
+	for i := range h.Counts {
+		h.Counts[i] = make([]int, offsetBuckets)
+	}
+	if len(s.Points) == 0 {
+		return h
+	}
+	tmin, tmax, omin, omax := s.bounds()
+	for _, p := range s.Points {
+		ti := bucket(float64(p.Time-tmin)/float64(tmax-tmin), timeBuckets)
+		oi := bucket((p.Offset-omin)/(omax-omin), offsetBuckets)
+		h.Counts[ti][oi]++
+		if h.Counts[ti][oi] > h.Max {
+			h.Max = h.Counts[ti][oi]
+		}
+	}
+	return h
+}
+
+// bucket returns index of the bucket for the value in [0, 1]
+func bucket(v float64, n int) int {
+	i := int(v * float64(n))
+	if i >= n {
+		i = n - 1
+	}
+	return i
+}
+
+// WriteHeatmapSVG renders distribution of offsets over time of the series as SVG.
+// Darker cells have more samples
+func WriteHeatmapSVG(w io.Writer, s *Series, width, height int) error {
+	if len(s.Points) == 0 {
+		return errNoPoints
+	}
+	c := newCanvas(s, width, height)
+	h := NewHeatmap(s, TimeBuckets, OffsetBuckets)
+	if err := c.header(w, s.Name()+" heatmap"); err != nil {
+		return err
+	}
+	cw := float64(width-2*margin) / TimeBuckets
+	ch := float64(height-margin) / OffsetBuckets
+	top := c.y(c.omax)
+	for ti, counts := range h.Counts {
+		for oi, n := range counts {
+			if n == 0 {
+				continue
+			}
+			// offsets grow upwards
+			y := top + float64(OffsetBuckets-1-oi)*ch
+			if _, err := fmt.Fprintf(w, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"steelblue\" fill-opacity=\"%.2f\"/>\n",
+				margin+float64(ti)*cw, y, cw, ch, 0.1+0.9*float64(n)/float64(h.Max)); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprint(w, "</svg>\n")
+	return err
+}
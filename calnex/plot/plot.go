@@ -0,0 +1,203 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package plot renders offset-over-time plots, offset heatmaps and percentile tables
+from samples exported from Calnex devices, so service reports can be generated
+without a notebook. Plots are plain SVG, the report is a single HTML page.
+*/
+package plot
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/facebook/time/calnex/compare"
+	"github.com/facebook/time/calnex/export"
+)
+
+// Default plot size in pixels
+const (
+	Width  = 800
+	Height = 240
+)
+
+// margin around the plot area for labels
+const margin = 60
+
+var errNoPoints = errors.New("no points to plot")
+
+// Point is a single sample
+type Point struct {
+	Time   int64
+	Offset float64
+}
+
+// Series is samples of a single target measured by a single device channel, sorted by time
+type Series struct {
+	Source  string
+	Channel string
+	Target  string
+	Points  []Point
+}
+
+// Name returns a human readable name of the series
+func (s *Series) Name() string {
+	return fmt.Sprintf("%s ch%s %s", s.Source, s.Channel, s.Target)
+}
+
+// Percentiles returns percentiles of absolute offsets
+func (s *Series) Percentiles() compare.Percentiles {
+	values := make([]float64, len(s.Points))
+	for i, p := range s.Points {
+		values[i] = p.Offset
+	}
+	return compare.AbsPercentiles(values)
+}
+
+// bounds returns time and offset ranges of the series. Offset range always includes 0
+func (s *Series) bounds() (tmin, tmax int64, omin, omax float64) {
+	tmin, tmax = s.Points[0].Time, s.Points[len(s.Points)-1].Time
+	for _, p := range s.Points {
+		omin = math.Min(omin, p.Offset)
+		omax = math.Max(omax, p.Offset)
+	}
+	if tmin == tmax {
+		tmax++
+	}
+	if omin == omax {
+		omax = omin + 1e-9
+	}
+	return tmin, tmax, omin, omax
+}
+
+// SeriesFromEntries groups exported entries into series sorted by name
+func SeriesFromEntries(entries []*export.Entry) []*Series {
+	type key struct{ source, channel, target string }
+	byKey := map[key]*Series{}
+	res := []*Series{}
+	for _, e := range entries {
+		k := key{e.Normal.Source, e.Normal.Channel, e.Normal.Target}
+		s, ok := byKey[k]
+		if !ok {
+			s = &Series{Source: k.source, Channel: k.channel, Target: k.target}
+			byKey[k] = s
+			res = append(res, s)
+		}
+		s.Points = append(s.Points, Point{Time: int64(e.Int.Time), Offset: e.Float.Value})
+	}
+	for _, s := range res {
+		sort.SliceStable(s.Points, func(i, j int) bool { return s.Points[i].Time < s.Points[j].Time })
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res
+}
+
+// formatOffset formats offset in seconds with a suitable unit
+func formatOffset(v float64) string {
+	return time.Duration(math.Round(v * 1e9)).String()
+}
+
+func formatTime(t int64) string {
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+// canvas maps series values to pixels of the plot area
+type canvas struct {
+	width, height int
+	tmin, tmax    int64
+	omin, omax    float64
+}
+
+func newCanvas(s *Series, width, height int) *canvas {
+	c := &canvas{width: width, height: height}
+	c.tmin, c.tmax, c.omin, c.omax = s.bounds()
+	return c
+}
+
+func (c *canvas) x(t int64) float64 {
+	return margin + float64(t-c.tmin)/float64(c.tmax-c.tmin)*float64(c.width-2*margin)
+}
+
+func (c *canvas) y(v float64) float64 {
+	return float64(c.height-margin/2) - (v-c.omin)/(c.omax-c.omin)*float64(c.height-margin)
+}
+
+// header writes SVG header with axes and labels
+func (c *canvas) header(w io.Writer, title string) error {
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="10">
+<text x="%d" y="12" font-size="12">%s</text>
+<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="black"/>
+<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="gray" stroke-dasharray="2"/>
+<text x="%d" y="%.1f" text-anchor="end">%s</text>
+<text x="%d" y="%.1f" text-anchor="end">%s</text>
+<text x="%d" y="%.1f" text-anchor="end">0</text>
+<text x="%d" y="%d">%s</text>
+<text x="%d" y="%d" text-anchor="end">%s</text>
+`,
+		c.width, c.height,
+		margin, html.EscapeString(title),
+		margin, c.y(c.omin), margin, c.y(c.omax),
+		margin, c.y(0), c.width-margin, c.y(0),
+		margin-4, c.y(c.omax)+4, formatOffset(c.omax),
+		margin-4, c.y(c.omin), formatOffset(c.omin),
+		margin-4, c.y(0)+4,
+		margin, c.height-2, formatTime(c.tmin),
+		c.width-margin, c.height-2, formatTime(c.tmax),
+	)
+	return err
+}
+
+// WriteSVG renders offset over time of the series as SVG. Points sharing a pixel column
+// are reduced to their min and max, so long measurements still produce small files
+func WriteSVG(w io.Writer, s *Series, width, height int) error {
+	if len(s.Points) == 0 {
+		return errNoPoints
+	}
+	c := newCanvas(s, width, height)
+	if err := c.header(w, s.Name()); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(w, `<polyline fill="none" stroke="steelblue" points="`); err != nil {
+		return err
+	}
+	for i := 0; i < len(s.Points); {
+		col := int(c.x(s.Points[i].Time))
+		lo, hi := s.Points[i], s.Points[i]
+		for i++; i < len(s.Points) && int(c.x(s.Points[i].Time)) == col; i++ {
+			if s.Points[i].Offset < lo.Offset {
+				lo = s.Points[i]
+			}
+			if s.Points[i].Offset > hi.Offset {
+				hi = s.Points[i]
+			}
+		}
+		// keep the time order within the column
+		if hi.Time < lo.Time {
+			lo, hi = hi, lo
+		}
+		if _, err := fmt.Fprintf(w, "%.1f,%.1f %.1f,%.1f ", c.x(lo.Time), c.y(lo.Offset), c.x(hi.Time), c.y(hi.Offset)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(w, "\"/>\n</svg>\n")
+	return err
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/facebook/time/calnex/compare"
+	"github.com/facebook/time/calnex/export"
+	"github.com/stretchr/testify/require"
+)
+
+func entry(source, target string, t int, v float64) *export.Entry {
+	return &export.Entry{
+		Float:  &export.FloatData{Value: v},
+		Int:    &export.IntData{Time: t},
+		Normal: &export.NormalData{Channel: "1", Target: target, Protocol: "ntp", Source: source},
+	}
+}
+
+// validXML checks the document is well formed
+func validXML(t *testing.T, doc string) {
+	d := xml.NewDecoder(strings.NewReader(doc))
+	d.Strict = false
+	for {
+		_, err := d.Token()
+		if err != nil {
+			require.Equal(t, "EOF", err.Error())
+			return
+		}
+	}
+}
+
+func testSeries(n int) *Series {
+	s := &Series{Source: "calnex01", Channel: "1", Target: "ntp01"}
+	for i := 0; i < n; i++ {
+		s.Points = append(s.Points, Point{Time: int64(1607961000 + i), Offset: float64(i%10-5) * 1e-6})
+	}
+	return s
+}
+
+func TestSeriesFromEntries(t *testing.T) {
+	series := SeriesFromEntries([]*export.Entry{
+		entry("calnex02", "ntp01", 11, 2),
+		entry("calnex01", "ntp01", 11, 1),
+		entry("calnex01", "ntp01", 10, -1),
+	})
+	require.Equal(t, []*Series{
+		{Source: "calnex01", Channel: "1", Target: "ntp01", Points: []Point{{10, -1}, {11, 1}}},
+		{Source: "calnex02", Channel: "1", Target: "ntp01", Points: []Point{{11, 2}}},
+	}, series)
+	require.Equal(t, "calnex01 ch1 ntp01", series[0].Name())
+	require.Equal(t, compare.Percentiles{P50: 1, P90: 1, P99: 1, Max: 1}, series[0].Percentiles())
+}
+
+func TestFormatOffset(t *testing.T) {
+	require.Equal(t, "1.5µs", formatOffset(0.0000015))
+	require.Equal(t, "-250ns", formatOffset(-0.00000025))
+	require.Equal(t, "0s", formatOffset(0))
+}
+
+func TestWriteSVG(t *testing.T) {
+	var b bytes.Buffer
+	require.ErrorIs(t, WriteSVG(&b, &Series{}, Width, Height), errNoPoints)
+
+	// a day of samples is reduced to 2 points per pixel column
+	s := testSeries(86400)
+	require.NoError(t, WriteSVG(&b, s, Width, Height))
+	validXML(t, b.String())
+	require.Contains(t, b.String(), "calnex01 ch1 ntp01")
+	require.Contains(t, b.String(), "2020-12-14T15:50:00Z")
+	require.Contains(t, b.String(), "-5µs")
+	require.LessOrEqual(t, strings.Count(b.String(), ","), 2*(Width-2*margin+1))
+
+	// a single sample
+	b.Reset()
+	require.NoError(t, WriteSVG(&b, testSeries(1), Width, Height))
+	validXML(t, b.String())
+}
+
+func TestHeatmap(t *testing.T) {
+	s := testSeries(100)
+	h := NewHeatmap(s, 10, 10)
+	total := 0
+	for _, counts := range h.Counts {
+		for _, n := range counts {
+			total += n
+		}
+	}
+	require.Equal(t, 100, total)
+	// the earliest sample has the lowest offset
+	require.Equal(t, 1, h.Counts[0][0])
+	require.Equal(t, 1, h.Max)
+
+	var b bytes.Buffer
+	require.NoError(t, WriteHeatmapSVG(&b, s, Width, Height))
+	validXML(t, b.String())
+	require.Contains(t, b.String(), "<rect")
+	require.ErrorIs(t, WriteHeatmapSVG(&b, &Series{}, Width, Height), errNoPoints)
+}
+
+func TestWriteHTML(t *testing.T) {
+	var b bytes.Buffer
+	s := testSeries(10)
+	s.Target = "<ntp01>"
+	require.NoError(t, WriteHTML(&b, "Weekly report", []*Series{s, {}}))
+	require.Contains(t, b.String(), "<h1>Weekly report</h1>")
+	require.Contains(t, b.String(), "<td>10</td><td>2µs</td><td>4µs</td><td>5µs</td><td>5µs</td>")
+	require.Contains(t, b.String(), "&lt;ntp01&gt;")
+	require.NotContains(t, b.String(), "<ntp01>")
+	require.Equal(t, 2, strings.Count(b.String(), "<svg"))
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plot
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+)
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Values are absolute offsets.</p>
+<table border="1">
+<tr><th>Series</th><th>Samples</th><th>p50</th><th>p90</th><th>p99</th><th>max</th></tr>
+{{range .Series}}<tr><td>{{.Name}}</td><td>{{.Samples}}</td><td>{{.P50}}</td><td>{{.P90}}</td><td>{{.P99}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{range .Series}}
+<h2>{{.Name}}</h2>
+{{.Plot}}
+{{.Heatmap}}
+{{end}}
+</body>
+</html>
+`))
+
+type reportSeries struct {
+	Name               string
+	Samples            int
+	P50, P90, P99, Max string
+	Plot, Heatmap      template.HTML
+}
+
+// WriteHTML writes a report page with a percentile table, offset plot and heatmap of every series
+func WriteHTML(w io.Writer, title string, series []*Series) error {
+	data := struct {
+		Title  string
+		Series []reportSeries
+	}{Title: title}
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		p := s.Percentiles()
+		rs := reportSeries{
+			Name:    s.Name(),
+			Samples: len(s.Points),
+			P50:     formatOffset(p.P50),
+			P90:     formatOffset(p.P90),
+			P99:     formatOffset(p.P99),
+			Max:     formatOffset(p.Max),
+		}
+		var b bytes.Buffer
+		if err := WriteSVG(&b, s, Width, Height); err != nil {
+			return err
+		}
+		// SVG is generated by us with escaped labels
+		rs.Plot = template.HTML(b.String())
+		b.Reset()
+		if err := WriteHeatmapSVG(&b, s, Width, Height); err != nil {
+			return err
+		}
+		rs.Heatmap = template.HTML(b.String())
+		data.Series = append(data.Series, rs)
+	}
+	return reportTemplate.Execute(w, data)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package cliconfig provides consistent configuration of the command line tools from flags,
+environment variables and a flag file, in this order of precedence.
+
+Flag foo-bar of the tool with prefix TOOL is read from TOOL_FOO_BAR environment variable
+or foo-bar key of the yaml flag file. Secrets such as device credentials can be sourced from files:
+TOOL_FOO_BAR_FILE environment variable or foo-bar_file key point to the file holding the value,
+so it doesn't show up in the process list, environment or the flag file.
+*/
+package cliconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FlagFile is the name of the flag pointing to the flag file
+const FlagFile = "flagfile"
+
+// secretSuffix marks keys with a path to the file holding the value
+const secretSuffix = "_file"
+
+var errUnknownFlag = errors.New("unknown flag")
+
+// Config of the tool
+type Config struct {
+	// EnvPrefix of the environment variables
+	EnvPrefix string
+	// File is a yaml file mapping flag names to values. Ignored if empty
+	File string
+}
+
+// EnvName returns name of the environment variable of the flag
+func (c *Config) EnvName(name string) string {
+	name = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	if c.EnvPrefix == "" {
+		return name
+	}
+	return c.EnvPrefix + "_" + name
+}
+
+// ReadSecret reads the value from the file, dropping trailing newline
+func ReadSecret(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// lookupEnv returns value of the flag from environment
+func (c *Config) lookupEnv(name string) (string, bool, error) {
+	env := c.EnvName(name)
+	if v, ok := os.LookupEnv(env); ok {
+		return v, true, nil
+	}
+	path, ok := os.LookupEnv(env + strings.ToUpper(secretSuffix))
+	if !ok {
+		return "", false, nil
+	}
+	v, err := ReadSecret(path)
+	return v, true, err
+}
+
+// readFile returns values of the flags from the flag file
+func (c *Config) readFile() (map[string][]string, error) {
+	b, err := ioutil.ReadFile(c.File)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]yaml.Node{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", c.File, err)
+	}
+	values := map[string][]string{}
+	for k, node := range raw {
+		var v []string
+		if node.Kind == yaml.SequenceNode {
+			err = node.Decode(&v)
+		} else {
+			var s string
+			err = node.Decode(&s)
+			v = []string{s}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s key %s: %w", c.File, k, err)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+// Apply sets the flags which are not set on the command line from environment, then from the flag file.
+// Flag file path itself can come from the FlagFile flag or its environment variable
+func (c *Config) Apply(f Flags) error {
+	set := map[string]bool{}
+	var err error
+	f.VisitAll(func(name string) {
+		if err != nil {
+			return
+		}
+		if f.IsSet(name) {
+			set[name] = true
+			return
+		}
+		v, ok, lerr := c.lookupEnv(name)
+		if lerr != nil {
+			err = fmt.Errorf("reading %s: %w", c.EnvName(name), lerr)
+			return
+		}
+		if !ok {
+			return
+		}
+		if err = f.Set(name, v); err != nil {
+			err = fmt.Errorf("setting %s from %s: %w", name, c.EnvName(name), err)
+			return
+		}
+		set[name] = true
+	})
+	if err != nil || c.File == "" {
+		return err
+	}
+
+	values, err := c.readFile()
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	f.VisitAll(func(name string) { known[name] = true })
+	for k, v := range values {
+		name := k
+		if !known[name] && strings.HasSuffix(name, secretSuffix) {
+			name = strings.TrimSuffix(name, secretSuffix)
+			if len(v) != 1 {
+				return fmt.Errorf("%s must be a single path", k)
+			}
+			s, err := ReadSecret(v[0])
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", k, err)
+			}
+			v = []string{s}
+		}
+		if !known[name] {
+			return fmt.Errorf("%w %q in %s", errUnknownFlag, k, c.File)
+		}
+		if set[name] {
+			continue
+		}
+		for _, s := range v {
+			if err := f.Set(name, s); err != nil {
+				return fmt.Errorf("setting %s from %s: %w", name, c.File, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cliconfig
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+// multi is a repeated flag
+type multi []string
+
+func (m *multi) String() string { return strings.Join(*m, ",") }
+
+func (m *multi) Set(v string) error {
+	*m = append(*m, v)
+	return nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func setenv(t *testing.T, key, value string) {
+	require.NoError(t, os.Setenv(key, value))
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
+
+func TestEnvName(t *testing.T) {
+	c := &Config{EnvPrefix: "CALNEX"}
+	require.Equal(t, "CALNEX_PASSWORD_FILE", c.EnvName("password-file"))
+	require.Equal(t, "CALNEX_INSECURETLS", c.EnvName("insecureTLS"))
+	require.Equal(t, "A_B", (&Config{}).EnvName("a.b"))
+}
+
+func TestApplyStd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cliconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &Config{EnvPrefix: "CLICONFIGTEST"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	port := fs.Int("port", 123, "")
+	refid := fs.String("refid", "OLEG", "")
+	interval := fs.Duration("interval", time.Second, "")
+	password := fs.String("password", "", "")
+	var ips multi
+	fs.Var(&ips, "ip", "")
+	fs.StringVar(&c.File, FlagFile, "", "")
+
+	flagFile := writeFile(t, dir, "flags.yaml", "port: 1123\nrefid: FILE\ninterval: 5s\nip: [\"::1\", \"127.0.0.1\"]\n")
+	secret := writeFile(t, dir, "secret", "hunter2\n")
+	setenv(t, "CLICONFIGTEST_REFID", "ENV")
+	setenv(t, "CLICONFIGTEST_PASSWORD_FILE", secret)
+	setenv(t, "CLICONFIGTEST_FLAGFILE", flagFile)
+
+	require.NoError(t, fs.Parse([]string{"-interval", "1m"}))
+	require.NoError(t, c.Apply(StdFlags(fs)))
+	// command line wins over env and file, env wins over file
+	require.Equal(t, time.Minute, *interval)
+	require.Equal(t, "ENV", *refid)
+	require.Equal(t, 1123, *port)
+	require.Equal(t, "hunter2", *password)
+	require.Equal(t, multi{"::1", "127.0.0.1"}, ips)
+	require.Equal(t, flagFile, c.File)
+}
+
+func TestApplyPflag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cliconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	secret := writeFile(t, dir, "secret", "hunter2")
+	c := &Config{EnvPrefix: "CLICONFIGTEST", File: writeFile(t, dir, "flags.yaml", "target: calnex01.example.com\ntoken_file: "+secret+"\nchannel: [a, b]\n")}
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	target := fs.String("target", "", "")
+	token := fs.String("token", "", "")
+	insecure := fs.Bool("insecureTLS", false, "")
+	channels := fs.StringSlice("channel", nil, "")
+	setenv(t, "CLICONFIGTEST_INSECURETLS", "true")
+
+	require.NoError(t, fs.Parse(nil))
+	require.NoError(t, c.Apply(PFlags(fs)))
+	require.Equal(t, "calnex01.example.com", *target)
+	require.Equal(t, "hunter2", *token)
+	require.True(t, *insecure)
+	require.Equal(t, []string{"a", "b"}, *channels)
+	// env and file values count as set, so required flags are satisfied
+	require.True(t, fs.Changed("target"))
+}
+
+func TestApplyErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cliconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("port", 123, "")
+	require.NoError(t, fs.Parse(nil))
+
+	c := &Config{EnvPrefix: "CLICONFIGTEST", File: writeFile(t, dir, "unknown.yaml", "nope: 1\n")}
+	require.True(t, errors.Is(c.Apply(StdFlags(fs)), errUnknownFlag))
+
+	c.File = writeFile(t, dir, "bad.yaml", "port: abc\n")
+	require.Error(t, c.Apply(StdFlags(fs)))
+
+	c.File = filepath.Join(dir, "missing.yaml")
+	require.Error(t, c.Apply(StdFlags(fs)))
+
+	setenv(t, "CLICONFIGTEST_PORT_FILE", filepath.Join(dir, "missing"))
+	require.Error(t, (&Config{EnvPrefix: "CLICONFIGTEST"}).Apply(StdFlags(fs)))
+}
+
+func TestReadSecret(t *testing.T) {
+	v, err := ReadSecret("")
+	require.NoError(t, err)
+	require.Empty(t, v)
+}
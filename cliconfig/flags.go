@@ -0,0 +1,83 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cliconfig
+
+import (
+	"flag"
+
+	"github.com/spf13/pflag"
+)
+
+// Flags is a set of parsed command line flags
+type Flags interface {
+	// VisitAll calls fn for every defined flag
+	VisitAll(fn func(name string))
+	// IsSet returns true if the flag is set on the command line
+	IsSet(name string) bool
+	// Set the flag value
+	Set(name, value string) error
+}
+
+// stdFlags wraps standard library flags
+type stdFlags struct {
+	fs     *flag.FlagSet
+	parsed map[string]bool
+}
+
+// StdFlags returns parsed standard library flags
+func StdFlags(fs *flag.FlagSet) Flags {
+	parsed := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { parsed[f.Name] = true })
+	return &stdFlags{fs: fs, parsed: parsed}
+}
+
+func (s *stdFlags) VisitAll(fn func(name string)) {
+	s.fs.VisitAll(func(f *flag.Flag) { fn(f.Name) })
+}
+
+func (s *stdFlags) IsSet(name string) bool {
+	return s.parsed[name]
+}
+
+func (s *stdFlags) Set(name, value string) error {
+	return s.fs.Set(name, value)
+}
+
+// pFlags wraps pflag flags used by cobra commands
+type pFlags struct {
+	fs     *pflag.FlagSet
+	parsed map[string]bool
+}
+
+// PFlags returns parsed pflag flags
+func PFlags(fs *pflag.FlagSet) Flags {
+	parsed := map[string]bool{}
+	fs.Visit(func(f *pflag.Flag) { parsed[f.Name] = true })
+	return &pFlags{fs: fs, parsed: parsed}
+}
+
+func (p *pFlags) VisitAll(fn func(name string)) {
+	p.fs.VisitAll(func(f *pflag.Flag) { fn(f.Name) })
+}
+
+func (p *pFlags) IsSet(name string) bool {
+	return p.parsed[name]
+}
+
+func (p *pFlags) Set(name, value string) error {
+	return p.fs.Set(name, value)
+}
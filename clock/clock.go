@@ -0,0 +1,132 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package clock abstracts reading of time, so code disciplining, smearing or
+serving time can be tested deterministically with a Simulated clock
+instead of the system one.
+*/
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a source of the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the system clock
+type System struct{}
+
+// Now returns current time of the system clock
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Default returns c, or the system clock if c is nil
+func Default(c Clock) Clock {
+	if c == nil {
+		return System{}
+	}
+	return c
+}
+
+// Simulated is a fully controlled clock. It tracks the true (reference) time, advanced only by Advance,
+// and its own reading, which runs with a frequency error and can be stepped. It is safe for concurrent use
+type Simulated struct {
+	// Tick advances the clock after every reading, so consecutive readings are increasing like the real ones. 0 disables it
+	Tick time.Duration
+
+	mux       sync.Mutex
+	reference time.Time
+	now       time.Time
+	// frequency error in parts per billion
+	ppb float64
+}
+
+// NewSimulated returns a Simulated clock without errors, reading start
+func NewSimulated(start time.Time) *Simulated {
+	return &Simulated{reference: start, now: start}
+}
+
+// Now returns current reading of the clock
+func (s *Simulated) Now() time.Time {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := s.now
+	if s.Tick > 0 {
+		s.advance(s.Tick)
+	}
+	return now
+}
+
+// True returns the true time
+func (s *Simulated) True() time.Time {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.reference
+}
+
+// Offset returns the error of the clock: its reading minus the true time
+func (s *Simulated) Offset() time.Duration {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.now.Sub(s.reference)
+}
+
+// advance must be called with mux held
+func (s *Simulated) advance(d time.Duration) {
+	s.reference = s.reference.Add(d)
+	s.now = s.now.Add(d + time.Duration(float64(d)*s.ppb/1e9))
+}
+
+// Advance moves the true time forward by d. The clock moves by d adjusted by the frequency error
+func (s *Simulated) Advance(d time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.advance(d)
+}
+
+// Step injects a step of the clock reading by d, like a clock step or a time jump. The true time doesn't change
+func (s *Simulated) Step(d time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.now = s.now.Add(d)
+}
+
+// Set sets the clock reading. The true time doesn't change
+func (s *Simulated) Set(t time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.now = t
+}
+
+// SetFrequency sets the frequency error of the clock in parts per billion. Positive clock runs fast
+func (s *Simulated) SetFrequency(ppb float64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.ppb = ppb
+}
+
+// Frequency returns the frequency error of the clock in parts per billion
+func (s *Simulated) Frequency() float64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.ppb
+}
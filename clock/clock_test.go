@@ -0,0 +1,60 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	require.Equal(t, System{}, Default(nil))
+	s := NewSimulated(time.Unix(1640995200, 0))
+	require.Equal(t, s, Default(s))
+	require.WithinDuration(t, time.Now(), System{}.Now(), time.Second)
+}
+
+func TestSimulated(t *testing.T) {
+	start := time.Unix(1640995200, 0)
+	s := NewSimulated(start)
+	require.Equal(t, start, s.Now())
+	require.Equal(t, start, s.Now())
+
+	s.SetFrequency(100000)
+	require.Equal(t, float64(100000), s.Frequency())
+	s.Advance(10 * time.Second)
+	require.Equal(t, start.Add(10*time.Second), s.True())
+	require.Equal(t, time.Millisecond, s.Offset())
+
+	s.Step(-time.Second)
+	require.Equal(t, -999*time.Millisecond, s.Offset())
+	require.Equal(t, start.Add(10*time.Second), s.True())
+
+	s.Set(s.True())
+	require.Equal(t, time.Duration(0), s.Offset())
+}
+
+func TestSimulatedTick(t *testing.T) {
+	start := time.Unix(1640995200, 0)
+	s := NewSimulated(start)
+	s.Tick = time.Microsecond
+	require.Equal(t, start, s.Now())
+	require.Equal(t, start.Add(time.Microsecond), s.Now())
+	require.Equal(t, start.Add(2*time.Microsecond), s.True())
+}
@@ -0,0 +1,190 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/facebook/time/metrics"
+)
+
+// DefaultAgentHistory is the number of recent results kept by the agent
+const DefaultAgentHistory = 60
+
+// AgentResult is a single health check run by the agent
+type AgentResult struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration_ns"`
+	Health   *HostHealth   `json:"health"`
+}
+
+// AgentStatus is served on /status of the agent
+type AgentStatus struct {
+	// Latest is the most recent result, nil if nothing was checked yet
+	Latest *AgentResult `json:"latest"`
+	// Recent results, oldest first
+	Recent []*AgentResult `json:"recent"`
+	// Stale is true if the latest result is older than MaxAge
+	Stale bool `json:"stale"`
+}
+
+// Agent runs health checks periodically and serves cached results over HTTP,
+// so health systems can scrape it instead of running ntpcheck every minute
+type Agent struct {
+	Config   *HealthConfig
+	Interval time.Duration
+	// History is the number of recent results kept. DefaultAgentHistory if 0
+	History int
+	// MaxAge after which results are considered stale. 3 intervals if 0
+	MaxAge time.Duration
+
+	registry *metrics.Prometheus
+	check    func(*HealthConfig) *HostHealth
+	now      func() time.Time
+
+	sync.Mutex
+	results []*AgentResult
+}
+
+// NewAgent returns Agent checking health of sync paths in the config every interval
+func NewAgent(c *HealthConfig, interval time.Duration) *Agent {
+	return &Agent{
+		Config:   c,
+		Interval: interval,
+		registry: metrics.NewPrometheus(),
+		check:    Health,
+		now:      time.Now,
+	}
+}
+
+func (a *Agent) history() int {
+	if a.History > 0 {
+		return a.History
+	}
+	return DefaultAgentHistory
+}
+
+func (a *Agent) maxAge() time.Duration {
+	if a.MaxAge > 0 {
+		return a.MaxAge
+	}
+	return 3 * a.Interval
+}
+
+// Check runs health check once and caches the result
+func (a *Agent) Check() *AgentResult {
+	start := a.now()
+	h := a.check(a.Config)
+	r := &AgentResult{Time: start, Duration: a.now().Sub(start), Health: h}
+
+	a.Lock()
+	a.results = append(a.results, r)
+	if over := len(a.results) - a.history(); over > 0 {
+		a.results = a.results[over:]
+	}
+	a.Unlock()
+
+	a.record(r)
+	return r
+}
+
+// record exports the result as metrics
+func (a *Agent) record(r *AgentResult) {
+	a.registry.Counter("ntpcheck_checks_total", "Health checks run by the agent", nil).Inc()
+	a.registry.Histogram("ntpcheck_check_duration_seconds", "Duration of health checks", metrics.DefaultBuckets, nil).Observe(r.Duration.Seconds())
+	a.registry.Gauge("ntpcheck_last_check_timestamp_seconds", "Unix time of the latest health check", nil).Set(float64(r.Time.UnixNano()) / float64(time.Second))
+	a.registry.Gauge("ntpcheck_health_severity", "Host time health severity: 0 ok, 1 warning, 2 critical", nil).Set(float64(r.Health.Severity))
+	for _, c := range r.Health.Components {
+		labels := metrics.Labels{"component": c.Name}
+		skipped := 0.0
+		if c.Skipped {
+			skipped = 1
+		}
+		a.registry.Gauge("ntpcheck_component_severity", "Severity of the sync path component", labels).Set(float64(c.Severity))
+		a.registry.Gauge("ntpcheck_component_skipped", "Whether the sync path component is not checked", labels).Set(skipped)
+	}
+}
+
+// Status returns cached results
+func (a *Agent) Status() *AgentStatus {
+	a.Lock()
+	defer a.Unlock()
+	s := &AgentStatus{Recent: append([]*AgentResult{}, a.results...)}
+	if len(a.results) > 0 {
+		s.Latest = a.results[len(a.results)-1]
+	}
+	s.Stale = s.Latest == nil || a.now().Sub(s.Latest.Time) > a.maxAge()
+	return s
+}
+
+// Run checks health every Interval until ctx is done
+func (a *Agent) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		r := a.Check()
+		log.Debugf("host time health: %s", r.Health.Verdict)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (a *Agent) handleStatus(w http.ResponseWriter, r *http.Request) {
+	js, err := json.Marshal(a.Status())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(js); err != nil {
+		log.Errorf("Failed to reply: %v", err)
+	}
+}
+
+// handleHealthz replies with 200 unless the latest result is stale or critical
+func (a *Agent) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	s := a.Status()
+	switch {
+	case s.Latest == nil:
+		http.Error(w, "no health check completed yet", http.StatusServiceUnavailable)
+	case s.Stale:
+		http.Error(w, fmt.Sprintf("latest health check is from %s", s.Latest.Time.Format(time.RFC3339)), http.StatusServiceUnavailable)
+	case s.Latest.Health.Severity >= SeverityCritical:
+		http.Error(w, s.Latest.Health.Verdict, http.StatusServiceUnavailable)
+	default:
+		fmt.Fprintln(w, s.Latest.Health.Verdict)
+	}
+}
+
+// Handler returns handler serving /status (JSON), /metrics (Prometheus) and /healthz
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", a.handleStatus)
+	mux.Handle("/metrics", a.registry)
+	mux.HandleFunc("/healthz", a.handleHealthz)
+	return mux
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAgent(severity *Severity, now *time.Time) *Agent {
+	a := NewAgent(&DefaultHealthConfig, time.Minute)
+	a.History = 2
+	a.now = func() time.Time { return *now }
+	a.check = func(*HealthConfig) *HostHealth {
+		return &HostHealth{
+			Verdict:  "ok",
+			Severity: *severity,
+			Components: []*HealthComponent{
+				{Name: HealthNTP, Severity: *severity, Message: "no alerts"},
+				{Name: HealthPTP4L, Skipped: true, Message: "ptp4l socket is not set"},
+			},
+		}
+	}
+	return a
+}
+
+func agentGet(h http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestAgentStatus(t *testing.T) {
+	severity := Severity(0)
+	now := time.Unix(1647000000, 0)
+	a := newTestAgent(&severity, &now)
+	s := a.Status()
+	require.Nil(t, s.Latest)
+	require.True(t, s.Stale)
+
+	for i := 0; i < 3; i++ {
+		a.Check()
+		now = now.Add(time.Minute)
+	}
+	s = a.Status()
+	require.Len(t, s.Recent, 2)
+	require.Equal(t, time.Unix(1647000060, 0), s.Recent[0].Time)
+	require.Equal(t, s.Recent[1], s.Latest)
+	require.False(t, s.Stale)
+
+	now = now.Add(3 * time.Minute)
+	require.True(t, a.Status().Stale)
+}
+
+func TestAgentHandler(t *testing.T) {
+	severity := Severity(0)
+	now := time.Unix(1647000000, 0)
+	a := newTestAgent(&severity, &now)
+	h := a.Handler()
+
+	rec := agentGet(h, "/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "no health check completed yet\n", rec.Body.String())
+
+	a.Check()
+	rec = agentGet(h, "/healthz")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "ok\n", rec.Body.String())
+
+	rec = agentGet(h, "/status")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var s struct {
+		Latest struct {
+			Health struct {
+				Verdict  string
+				Severity string
+			}
+		}
+		Recent []interface{}
+		Stale  bool
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+	require.Len(t, s.Recent, 1)
+	require.Equal(t, "ok", s.Latest.Health.Verdict)
+	require.False(t, s.Stale)
+
+	severity = SeverityCritical
+	a.Check()
+	rec = agentGet(h, "/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = agentGet(h, "/metrics")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "ntpcheck_checks_total 2\n")
+	require.Contains(t, rec.Body.String(), "ntpcheck_health_severity 2\n")
+	require.Contains(t, rec.Body.String(), "ntpcheck_last_check_timestamp_seconds 1.647e+09\n")
+	require.Contains(t, rec.Body.String(), `ntpcheck_component_severity{component="ntp"} 2`)
+	require.Contains(t, rec.Body.String(), `ntpcheck_component_skipped{component="ptp4l"} 1`)
+
+	severity = 0
+	a.Check()
+	now = now.Add(time.Hour)
+	rec = agentGet(h, "/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "latest health check is from")
+}
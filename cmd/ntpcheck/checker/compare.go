@@ -0,0 +1,241 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/roughtime"
+	"github.com/facebook/time/oscillatord"
+)
+
+// ClockSource is a local time source which can be compared against the system clock
+type ClockSource interface {
+	Name() string
+	Sample() *SourceSample
+}
+
+// SourceSample is a single reading of a ClockSource.
+// Offset is the source time minus the system time, nil if the source has no time (oscillatord)
+type SourceSample struct {
+	Source   string            `json:"source"`
+	OffsetNS *int64            `json:"offset_ns,omitempty"`
+	DelayNS  int64             `json:"delay_ns,omitempty"`
+	Status   map[string]string `json:"status,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+func offsetSample(name string, offset, delay time.Duration) *SourceSample {
+	o := offset.Nanoseconds()
+	return &SourceSample{Source: name, OffsetNS: &o, DelayNS: delay.Nanoseconds()}
+}
+
+func errorSample(name string, err error) *SourceSample {
+	return &SourceSample{Source: name, Error: err.Error()}
+}
+
+// CompareRecord is a set of samples of all sources taken at the same time
+type CompareRecord struct {
+	Time    time.Time       `json:"time"`
+	Samples []*SourceSample `json:"samples"`
+	// SpreadNS is the max difference between offsets of all sources including the system clock itself
+	SpreadNS int64 `json:"spread_ns"`
+}
+
+// PHCSource reads PHC device time
+type PHCSource struct {
+	Device string
+}
+
+// Name of the source
+func (s *PHCSource) Name() string {
+	return "phc:" + s.Device
+}
+
+// Sample PHC offset from the system clock
+func (s *PHCSource) Sample() *SourceSample {
+	offset, err := phcOffset(s.Device)
+	if err != nil {
+		return errorSample(s.Name(), err)
+	}
+	// phcOffset is system time minus PHC time
+	return offsetSample(s.Name(), -offset, 0)
+}
+
+// NTPSource queries NTP server with a single client request
+type NTPSource struct {
+	Address string
+	Timeout time.Duration
+}
+
+// Name of the source
+func (s *NTPSource) Name() string {
+	return "ntp:" + s.Address
+}
+
+// Sample NTP server offset from the system clock
+func (s *NTPSource) Sample() *SourceSample {
+	offset, delay, err := ntpOffset(s.Address, s.Timeout)
+	if err != nil {
+		return errorSample(s.Name(), err)
+	}
+	return offsetSample(s.Name(), offset, delay)
+}
+
+func ntpOffset(address string, timeout time.Duration) (offset, delay time.Duration, err error) {
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, 0, err
+	}
+	request := &ntp.Packet{Settings: 0x1B}
+	if err := request.SetRandomTransmitTime(); err != nil {
+		return 0, 0, err
+	}
+	t1 := time.Now()
+	if err := binary.Write(conn, binary.BigEndian, request); err != nil {
+		return 0, 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	response, t4, err := ntp.ReadPacketWithTimestamp(conn)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := ntp.MatchOrigin(request, response); err != nil {
+		return 0, 0, err
+	}
+	t2 := ntp.Unix(response.RxTimeSec, response.RxTimeFrac)
+	t3 := ntp.Unix(response.TxTimeSec, response.TxTimeFrac)
+	offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delay = t4.Sub(t1) - t3.Sub(t2)
+	return offset, delay, nil
+}
+
+// RoughtimeSource queries Roughtime server. Radius of the signed midpoint is recorded in the status
+type RoughtimeSource struct {
+	Server  *roughtime.Server
+	Timeout time.Duration
+}
+
+// Name of the source
+func (s *RoughtimeSource) Name() string {
+	return "roughtime:" + s.Server.Address
+}
+
+// Sample Roughtime server offset from the system clock
+func (s *RoughtimeSource) Sample() *SourceSample {
+	r, err := roughtime.Query(s.Server, s.Timeout)
+	if err != nil {
+		return errorSample(s.Name(), err)
+	}
+	sample := offsetSample(s.Name(), r.Offset, r.Delay)
+	sample.Status = map[string]string{"radius": r.Radius.String()}
+	return sample
+}
+
+// OscillatordSource reads oscillatord status. It has no time, but lock and GNSS fix explain disagreements
+type OscillatordSource struct {
+	Address string
+	Timeout time.Duration
+}
+
+// Name of the source
+func (s *OscillatordSource) Name() string {
+	return "oscillatord:" + s.Address
+}
+
+// Sample oscillatord status
+func (s *OscillatordSource) Sample() *SourceSample {
+	status, err := oscillatord.FetchStatus(s.Address, s.Timeout)
+	if err != nil {
+		return errorSample(s.Name(), err)
+	}
+	return &SourceSample{
+		Source: s.Name(),
+		Status: map[string]string{
+			"lock":           fmt.Sprintf("%v", status.Oscillator.Lock),
+			"temperature":    fmt.Sprintf("%.2f", status.Oscillator.Temperature),
+			"gnss_fix":       status.GNSS.Fix.String(),
+			"gnss_fix_ok":    fmt.Sprintf("%v", status.GNSS.FixOK),
+			"antenna_status": status.GNSS.AntennaStatus.String(),
+			"antenna_power":  status.GNSS.AntennaPower.String(),
+		},
+	}
+}
+
+// SampleAll samples all sources in parallel and correlates them into a single record
+func SampleAll(sources []ClockSource, now time.Time) *CompareRecord {
+	r := &CompareRecord{
+		Time:    now,
+		Samples: make([]*SourceSample, len(sources)),
+	}
+	var wg sync.WaitGroup
+	for i, s := range sources {
+		wg.Add(1)
+		go func(i int, s ClockSource) {
+			defer wg.Done()
+			r.Samples[i] = s.Sample()
+		}(i, s)
+	}
+	wg.Wait()
+	// system clock is the reference with zero offset
+	var min, max int64
+	for _, s := range r.Samples {
+		if s.OffsetNS == nil {
+			continue
+		}
+		if *s.OffsetNS < min {
+			min = *s.OffsetNS
+		}
+		if *s.OffsetNS > max {
+			max = *s.OffsetNS
+		}
+	}
+	r.SpreadNS = max - min
+	return r
+}
+
+// Compare samples sources every interval and writes records to w as JSON lines.
+// It stops after count records if count is positive, or when ctx is done
+func Compare(ctx context.Context, sources []ClockSource, interval time.Duration, count int, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; count <= 0 || i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+		if err := enc.Encode(SampleAll(sources, time.Now())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/roughtime"
+)
+
+type fakeSource struct {
+	name   string
+	sample *SourceSample
+}
+
+func (s *fakeSource) Name() string {
+	return s.name
+}
+
+func (s *fakeSource) Sample() *SourceSample {
+	return s.sample
+}
+
+func TestSampleAll(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	sources := []ClockSource{
+		&fakeSource{name: "a", sample: offsetSample("a", 300*time.Nanosecond, 0)},
+		&fakeSource{name: "b", sample: offsetSample("b", -200*time.Nanosecond, 10*time.Microsecond)},
+		&fakeSource{name: "c", sample: errorSample("c", errors.New("no such device"))},
+		&fakeSource{name: "d", sample: &SourceSample{Source: "d", Status: map[string]string{"lock": "true"}}},
+	}
+	r := SampleAll(sources, now)
+	require.Equal(t, now, r.Time)
+	require.Len(t, r.Samples, 4)
+	require.Equal(t, "a", r.Samples[0].Source)
+	require.Equal(t, "no such device", r.Samples[2].Error)
+	require.Equal(t, int64(500), r.SpreadNS)
+
+	// system clock is included in the spread
+	r = SampleAll(sources[:1], now)
+	require.Equal(t, int64(300), r.SpreadNS)
+}
+
+func TestCompare(t *testing.T) {
+	sources := []ClockSource{
+		&fakeSource{name: "a", sample: offsetSample("a", time.Microsecond, 0)},
+	}
+	var buf bytes.Buffer
+	err := Compare(context.Background(), sources, time.Millisecond, 3, &buf)
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(&buf)
+	lines := 0
+	for scanner.Scan() {
+		r := &CompareRecord{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), r))
+		require.Equal(t, int64(1000), r.SpreadNS)
+		lines++
+	}
+	require.Equal(t, 3, lines)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Compare(ctx, sources, time.Millisecond, 0, &buf)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNTPSource(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, ntp.PacketSizeBytes)
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request, err := ntp.BytesToPacket(buf)
+		if err != nil {
+			return
+		}
+		// server is one second ahead
+		now := time.Now().Add(time.Second)
+		sec, frac := ntp.Time(now)
+		response := &ntp.Packet{
+			Settings:    0x24,
+			Stratum:     1,
+			OrigTimeSec: request.TxTimeSec, OrigTimeFrac: request.TxTimeFrac,
+			RxTimeSec: sec, RxTimeFrac: frac,
+			TxTimeSec: sec, TxTimeFrac: frac,
+		}
+		b, _ := response.Bytes()
+		_, _ = conn.WriteTo(b, addr)
+	}()
+	s := &NTPSource{Address: conn.LocalAddr().String(), Timeout: time.Second}
+	require.Equal(t, "ntp:"+s.Address, s.Name())
+	sample := s.Sample()
+	require.Empty(t, sample.Error)
+	require.InDelta(t, time.Second.Nanoseconds(), *sample.OffsetNS, float64(100*time.Millisecond))
+}
+
+func TestOscillatordSource(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		buf := make([]byte, 1)
+		if _, err := c.Read(buf); err != nil {
+			return
+		}
+		_, _ = c.Write([]byte(`{"oscillator":{"model":"sa5x","lock":true,"temperature":45.5},"gnss":{"fix":3,"fixOk":true,"antenna_power":1,"antenna_status":2}}`))
+	}()
+	s := &OscillatordSource{Address: ln.Addr().String(), Timeout: time.Second}
+	sample := s.Sample()
+	require.Empty(t, sample.Error)
+	require.Nil(t, sample.OffsetNS)
+	require.Equal(t, "true", sample.Status["lock"])
+	require.Equal(t, "true", sample.Status["gnss_fix_ok"])
+	require.Equal(t, "45.50", sample.Status["temperature"])
+}
+
+func TestRoughtimeSourceUnreachable(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	s := &RoughtimeSource{Server: &roughtime.Server{Address: addr}, Timeout: 100 * time.Millisecond}
+	require.Equal(t, "roughtime:"+addr, s.Name())
+	sample := s.Sample()
+	require.NotEmpty(t, sample.Error)
+	require.Nil(t, sample.OffsetNS)
+}
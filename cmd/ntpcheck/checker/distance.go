@@ -0,0 +1,99 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net"
+	"sort"
+	"strings"
+)
+
+// RootDistance returns the root distance of the peer in ms as defined by RFC 5905:
+// half of the total round-trip delay to the primary reference plus total dispersion and jitter
+func (p *Peer) RootDistance() float64 {
+	return (p.RootDelay+p.Delay)/2 + p.RootDisp + p.Dispersion + p.Jitter
+}
+
+// RootDistance returns the root distance of the system in ms: root delay / 2 + root dispersion
+func (s *SystemVariables) RootDistance() float64 {
+	return s.RootDelay/2 + s.RootDisp
+}
+
+// refIDBytes parses refid reported by ntpd (dotted quad) or chrony (hex).
+// Returns false for non-address refids such as "GPS"
+func refIDBytes(refid string) ([]byte, bool) {
+	if ip := net.ParseIP(refid); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, true
+		}
+		return nil, false
+	}
+	if len(refid) == 8 {
+		if b, err := hex.DecodeString(refid); err == nil {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// addrRefID returns the refid a server synced to ip would report:
+// IPv4 address itself, or first 4 bytes of MD5 of IPv6 address
+func addrRefID(ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	sum := md5.Sum(ip.To16())
+	return sum[:4]
+}
+
+// LocalAddrs returns local addresses we use to talk to peers
+func (r *NTPCheckResult) LocalAddrs() []net.IP {
+	seen := map[string]bool{}
+	addrs := []net.IP{}
+	for _, p := range r.Peers {
+		ip := net.ParseIP(strings.Trim(p.DSTAdr, "[]"))
+		if ip == nil || ip.IsUnspecified() || seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		addrs = append(addrs, ip)
+	}
+	return addrs
+}
+
+// FindLoops returns peers synchronized to us, which means their refid points
+// at one of the local addresses. Peers are sorted by address
+func (r *NTPCheckResult) FindLoops() []*Peer {
+	local := map[string]bool{}
+	for _, ip := range r.LocalAddrs() {
+		local[string(addrRefID(ip))] = true
+	}
+	loops := []*Peer{}
+	for _, p := range r.Peers {
+		// stratum 1 servers are synced to a reference clock
+		if p.Stratum <= 1 {
+			continue
+		}
+		if b, ok := refIDBytes(p.RefID); ok && local[string(b)] {
+			loops = append(loops, p)
+		}
+	}
+	sort.Slice(loops, func(i, j int) bool { return loops[i].SRCAdr < loops[j].SRCAdr })
+	return loops
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/facebook/time/ntp/control"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerRootDistance(t *testing.T) {
+	p := &Peer{RootDelay: 10, Delay: 2, RootDisp: 3, Dispersion: 0.5, Jitter: 0.25}
+	require.InDelta(t, 9.75, p.RootDistance(), 1e-9)
+}
+
+func TestSystemRootDistance(t *testing.T) {
+	s := &SystemVariables{RootDelay: 10, RootDisp: 5}
+	require.InDelta(t, 10.0, s.RootDistance(), 1e-9)
+}
+
+func TestRefIDBytes(t *testing.T) {
+	b, ok := refIDBytes("192.0.2.10")
+	require.True(t, ok)
+	require.Equal(t, []byte{192, 0, 2, 10}, b)
+
+	b, ok = refIDBytes("C000020A")
+	require.True(t, ok)
+	require.Equal(t, []byte{192, 0, 2, 10}, b)
+
+	_, ok = refIDBytes("GPS")
+	require.False(t, ok)
+	_, ok = refIDBytes("2001:db8::1")
+	require.False(t, ok)
+}
+
+func TestAddrRefID(t *testing.T) {
+	require.Equal(t, []byte{192, 0, 2, 10}, addrRefID(net.ParseIP("192.0.2.10")))
+	// first 4 bytes of md5 of 2001:db8::1
+	require.Equal(t, []byte{0x39, 0xab, 0x9b, 0x37}, addrRefID(net.ParseIP("2001:db8::1")))
+}
+
+func loopResult() *NTPCheckResult {
+	return &NTPCheckResult{
+		SysVars: &SystemVariables{Stratum: 3},
+		Peers: map[uint16]*Peer{
+			1: {Selection: control.SelSYSPeer, SRCAdr: "192.0.2.1", DSTAdr: "192.0.2.10", Stratum: 2, RefID: "198.51.100.1"},
+			2: {Selection: control.SelCandidate, SRCAdr: "192.0.2.2", DSTAdr: "192.0.2.10", Stratum: 4, RefID: "192.0.2.10"},
+			3: {Selection: control.SelReject, SRCAdr: "2001:db8::2", DSTAdr: "2001:db8::1", Stratum: 4, RefID: "39AB9B37"},
+			4: {Selection: control.SelCandidate, SRCAdr: "192.0.2.4", DSTAdr: "192.0.2.10", Stratum: 1, RefID: "GPS"},
+		},
+	}
+}
+
+func TestLocalAddrs(t *testing.T) {
+	addrs := loopResult().LocalAddrs()
+	require.Len(t, addrs, 2)
+}
+
+func TestFindLoops(t *testing.T) {
+	r := loopResult()
+	loops := r.FindLoops()
+	require.Len(t, loops, 2)
+	require.Equal(t, "192.0.2.2", loops[0].SRCAdr)
+	require.Equal(t, "2001:db8::2", loops[1].SRCAdr)
+
+	delete(r.Peers, 2)
+	delete(r.Peers, 3)
+	require.Empty(t, r.FindLoops())
+}
+
+func TestPreflightLoop(t *testing.T) {
+	c := PreflightConfig{}
+	res := preflight(loopResult(), &c, nil, time.Now())
+	require.False(t, res.Passed)
+	failed := res.Failed()
+	require.Len(t, failed, 1)
+	require.Equal(t, PreflightLoop, failed[0].Name)
+	require.Equal(t, "peer 192.0.2.2 (stratum 4) is synchronized to us, refid 192.0.2.10", failed[0].Message)
+}
+
+func TestPreflightRootDistance(t *testing.T) {
+	r := preflightResult()
+	r.SysVars.RootDelay = 20
+	r.SysVars.RootDisp = 95
+	c := PreflightConfig{MaxRootDistance: 100}
+	res := preflight(r, &c, nil, time.Now())
+	require.False(t, res.Passed)
+	require.Equal(t, PreflightRootDist, res.Failed()[0].Name)
+	require.Equal(t, "root distance 105.000ms exceeds 100.000ms", res.Failed()[0].Message)
+}
+
+func TestRulesEvaluateLoop(t *testing.T) {
+	r := loopResult()
+	r.Peers[2].Selection = control.SelSYSPeer
+	r.Peers[1].Selection = control.SelCandidate
+	r.Peers[1].Reach = 255
+	r.Peers[2].Reach = 255
+	r.Peers[3].Reach = 255
+	r.Peers[4].Reach = 255
+	alerts := DefaultRules.Evaluate(r)
+	require.Equal(t, []*Alert{
+		{Rule: RuleLoop, Severity: SeverityCritical, Peer: "192.0.2.2", Value: 4, Message: "peer 192.0.2.2 is synchronized to us, refid 192.0.2.10"},
+		{Rule: RuleLoop, Severity: SeverityWarning, Peer: "2001:db8::2", Value: 4, Message: "peer 2001:db8::2 is synchronized to us, refid 39AB9B37"},
+	}, alerts)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"fmt"
+	"time"
+
+	ptpcheck "github.com/facebook/time/cmd/ptpcheck/checker"
+)
+
+// Health component names
+const (
+	HealthNTP     = "ntp"
+	HealthPTP4L   = "ptp4l"
+	HealthPHC2Sys = "phc2sys"
+)
+
+// HealthConfig describes which sync paths to check. Components with empty source are skipped
+type HealthConfig struct {
+	// NTPServer is the ntpd/chrony address as in RunCheck
+	NTPServer string
+	// NTPRules are evaluated against NTP check result. NTP is skipped if nil
+	NTPRules *Rules
+	// PTP4LSocket is the ptp4l UDS management socket such as /var/run/ptp4l
+	PTP4LSocket string
+	// PTPOffset is the abs ptp4l offset from master in ns
+	PTPOffset Threshold
+	// PHCDevice disciplined by ptp4l, such as /dev/ptp0. phc2sys is checked by measuring
+	// the offset between this device and the system clock
+	PHCDevice string
+	// PHCOffset is the abs offset between PHC and system clock in ns
+	PHCOffset Threshold
+}
+
+// DefaultHealthConfig checks NTP only, with default rules
+var DefaultHealthConfig = HealthConfig{
+	NTPRules:  &DefaultRules,
+	PTPOffset: Threshold{Warning: 1000, Critical: 100000},
+	PHCOffset: Threshold{Warning: 5000, Critical: 1000000},
+}
+
+// HealthComponent is the health of a single sync path component
+type HealthComponent struct {
+	Name     string   `json:"name"`
+	Severity Severity `json:"severity"`
+	Skipped  bool     `json:"skipped"`
+	Message  string   `json:"message"`
+	Alerts   []*Alert `json:"alerts,omitempty"`
+}
+
+// HostHealth is a unified verdict over NTP and PTP sync paths
+type HostHealth struct {
+	Verdict    string             `json:"verdict"`
+	Severity   Severity           `json:"severity"`
+	Components []*HealthComponent `json:"components"`
+}
+
+type healthSources struct {
+	ntp       func(address string) (*NTPCheckResult, error)
+	ptp       func(address string) (*ptpcheck.PTPCheckResult, error)
+	phcOffset func(device string) (time.Duration, error)
+}
+
+// Health checks all configured sync paths and combines them into a single verdict
+func Health(c *HealthConfig) *HostHealth {
+	return health(c, &healthSources{
+		ntp:       RunCheck,
+		ptp:       ptpcheck.RunCheck,
+		phcOffset: phcOffset,
+	})
+}
+
+func health(c *HealthConfig, s *healthSources) *HostHealth {
+	h := &HostHealth{
+		Components: []*HealthComponent{
+			healthNTP(c, s.ntp),
+			healthPTP4L(c, s.ptp),
+			healthPHC2Sys(c, s.phcOffset),
+		},
+	}
+	checked := 0
+	for _, comp := range h.Components {
+		if comp.Skipped {
+			continue
+		}
+		checked++
+		if comp.Severity > h.Severity {
+			h.Severity = comp.Severity
+		}
+	}
+	if checked == 0 {
+		h.Severity = SeverityCritical
+	}
+	h.Verdict = "ok"
+	if h.Severity != 0 {
+		h.Verdict = h.Severity.String()
+	}
+	return h
+}
+
+func healthOK(name, format string, a ...interface{}) *HealthComponent {
+	return &HealthComponent{Name: name, Message: fmt.Sprintf(format, a...)}
+}
+
+func healthFailed(name string, s Severity, format string, a ...interface{}) *HealthComponent {
+	return &HealthComponent{Name: name, Severity: s, Message: fmt.Sprintf(format, a...)}
+}
+
+func healthSkipped(name, format string, a ...interface{}) *HealthComponent {
+	return &HealthComponent{Name: name, Skipped: true, Message: fmt.Sprintf(format, a...)}
+}
+
+func healthNTP(c *HealthConfig, run func(string) (*NTPCheckResult, error)) *HealthComponent {
+	if c.NTPRules == nil {
+		return healthSkipped(HealthNTP, "NTP check is disabled")
+	}
+	r, err := run(c.NTPServer)
+	if err != nil {
+		return healthFailed(HealthNTP, SeverityCritical, "failed to query NTP daemon: %v", err)
+	}
+	alerts := c.NTPRules.Evaluate(r)
+	if len(alerts) == 0 {
+		return healthOK(HealthNTP, "no alerts")
+	}
+	comp := healthFailed(HealthNTP, MaxSeverity(alerts), "%d alert(s), %s: %s", len(alerts), alerts[0].Rule, alerts[0].Message)
+	comp.Alerts = alerts
+	return comp
+}
+
+func healthPTP4L(c *HealthConfig, run func(string) (*ptpcheck.PTPCheckResult, error)) *HealthComponent {
+	if c.PTP4LSocket == "" {
+		return healthSkipped(HealthPTP4L, "ptp4l socket is not set")
+	}
+	r, err := run(c.PTP4LSocket)
+	if err != nil {
+		return healthFailed(HealthPTP4L, SeverityCritical, "failed to query ptp4l: %v", err)
+	}
+	if !r.GrandmasterPresent {
+		return healthFailed(HealthPTP4L, SeverityCritical, "no grandmaster, clock %s is free running", r.ClockIdentity)
+	}
+	if s, t, ok := c.PTPOffset.above(r.OffsetFromMasterNS); ok {
+		return healthFailed(HealthPTP4L, s, "offset from master %.0fns exceeds %.0fns", r.OffsetFromMasterNS, t)
+	}
+	return healthOK(HealthPTP4L, "grandmaster %s, offset from master %.0fns", r.GrandmasterIdentity, r.OffsetFromMasterNS)
+}
+
+func healthPHC2Sys(c *HealthConfig, phcOffsetFunc func(string) (time.Duration, error)) *HealthComponent {
+	if c.PHCDevice == "" {
+		return healthSkipped(HealthPHC2Sys, "PHC device is not set")
+	}
+	offset, err := phcOffsetFunc(c.PHCDevice)
+	if err != nil {
+		return healthFailed(HealthPHC2Sys, SeverityCritical, "failed to read %s: %v", c.PHCDevice, err)
+	}
+	if s, t, ok := c.PHCOffset.above(float64(offset)); ok {
+		return healthFailed(HealthPHC2Sys, s, "%s to system clock offset %v exceeds %v", c.PHCDevice, offset, time.Duration(t))
+	}
+	return healthOK(HealthPHC2Sys, "%s to system clock offset %v", c.PHCDevice, offset)
+}
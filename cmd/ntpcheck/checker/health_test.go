@@ -0,0 +1,101 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ptpcheck "github.com/facebook/time/cmd/ptpcheck/checker"
+)
+
+func healthTestSources(ntpRes *NTPCheckResult, ptpRes *ptpcheck.PTPCheckResult, offset time.Duration) *healthSources {
+	return &healthSources{
+		ntp: func(string) (*NTPCheckResult, error) {
+			if ntpRes == nil {
+				return nil, errors.New("connection refused")
+			}
+			return ntpRes, nil
+		},
+		ptp: func(string) (*ptpcheck.PTPCheckResult, error) {
+			if ptpRes == nil {
+				return nil, errors.New("connection refused")
+			}
+			return ptpRes, nil
+		},
+		phcOffset: func(string) (time.Duration, error) {
+			return offset, nil
+		},
+	}
+}
+
+func TestHealthNTPOnly(t *testing.T) {
+	c := DefaultHealthConfig
+	h := health(&c, healthTestSources(rulesResult(), nil, 0))
+	require.Equal(t, "ok", h.Verdict)
+	require.Equal(t, Severity(0), h.Severity)
+	require.Len(t, h.Components, 3)
+	require.False(t, h.Components[0].Skipped)
+	require.True(t, h.Components[1].Skipped)
+	require.True(t, h.Components[2].Skipped)
+}
+
+func TestHealthPTP(t *testing.T) {
+	c := DefaultHealthConfig
+	c.NTPRules = nil
+	c.PTP4LSocket = "/var/run/ptp4l"
+	c.PHCDevice = "/dev/ptp0"
+	ptpRes := &ptpcheck.PTPCheckResult{GrandmasterPresent: true, GrandmasterIdentity: "b8cef6.fffe.7c6e1a", OffsetFromMasterNS: -2000}
+	h := health(&c, healthTestSources(nil, ptpRes, 300*time.Nanosecond))
+	require.Equal(t, "warning", h.Verdict)
+	require.Equal(t, []*HealthComponent{
+		{Name: HealthNTP, Skipped: true, Message: "NTP check is disabled"},
+		{Name: HealthPTP4L, Severity: SeverityWarning, Message: "offset from master -2000ns exceeds 1000ns"},
+		{Name: HealthPHC2Sys, Message: "/dev/ptp0 to system clock offset 300ns"},
+	}, h.Components)
+
+	ptpRes.GrandmasterPresent = false
+	ptpRes.ClockIdentity = "0c42a1.fffe.6d7c04"
+	h = health(&c, healthTestSources(nil, ptpRes, 2*time.Millisecond))
+	require.Equal(t, "critical", h.Verdict)
+	require.Equal(t, "no grandmaster, clock 0c42a1.fffe.6d7c04 is free running", h.Components[1].Message)
+	require.Equal(t, SeverityCritical, h.Components[2].Severity)
+	require.Equal(t, "/dev/ptp0 to system clock offset 2ms exceeds 1ms", h.Components[2].Message)
+}
+
+func TestHealthNTPFailure(t *testing.T) {
+	c := DefaultHealthConfig
+	h := health(&c, healthTestSources(nil, nil, 0))
+	require.Equal(t, "critical", h.Verdict)
+	require.Equal(t, "failed to query NTP daemon: connection refused", h.Components[0].Message)
+
+	r := rulesResult()
+	r.Peers[1].Jitter = 2
+	h = health(&c, healthTestSources(r, nil, 0))
+	require.Equal(t, "warning", h.Verdict)
+	require.Len(t, h.Components[0].Alerts, 1)
+	require.Equal(t, "1 alert(s), jitter: sys.peer jitter 2.000ms exceeds 1.000ms", h.Components[0].Message)
+}
+
+func TestHealthNothingChecked(t *testing.T) {
+	c := HealthConfig{}
+	h := health(&c, healthTestSources(nil, nil, 0))
+	require.Equal(t, "critical", h.Verdict)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/facebook/time/ntp/control"
+)
+
+// This is a compatibility layer which presents data collected from chrony (or ntpd)
+// with ntpd system and peer variable names, as returned by NTP control protocol.
+// It allows tooling built around ntpd output keep working on hosts running chrony.
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// NTPDVariables returns system variables named as ntpd does
+func (s *SystemVariables) NTPDVariables() map[string]string {
+	return map[string]string{
+		"version":    s.Version,
+		"processor":  s.Processor,
+		"system":     s.System,
+		"leap":       strconv.Itoa(s.Leap),
+		"stratum":    strconv.Itoa(s.Stratum),
+		"precision":  strconv.Itoa(s.Precision),
+		"rootdelay":  formatFloat(s.RootDelay),
+		"rootdisp":   formatFloat(s.RootDisp),
+		"peer":       strconv.Itoa(s.Peer),
+		"tc":         strconv.Itoa(s.TC),
+		"mintc":      strconv.Itoa(s.MinTC),
+		"clock":      s.Clock,
+		"refid":      s.RefID,
+		"reftime":    s.RefTime,
+		"offset":     formatFloat(s.Offset),
+		"sys_jitter": formatFloat(s.SysJitter),
+		"frequency":  formatFloat(s.Frequency),
+		"clk_wander": formatFloat(s.ClkWander),
+		"clk_jitter": formatFloat(s.ClkJitter),
+		"tai":        strconv.Itoa(s.Tai),
+	}
+}
+
+// PeerStatusWord returns ntpd peer status word of the peer
+func (p *Peer) PeerStatusWord() *control.PeerStatusWord {
+	return &control.PeerStatusWord{
+		PeerStatus: control.PeerStatus{
+			Broadcast:   p.Broadcast,
+			Reachable:   p.Reachable,
+			AuthEnabled: p.AuthPossible,
+			AuthOK:      p.Authentic,
+			Configured:  p.Configured,
+		},
+		PeerSelection: p.Selection,
+	}
+}
+
+// NTPDVariables returns peer variables named as ntpd does. Like ntpd, reach and flash are hex
+func (p *Peer) NTPDVariables() map[string]string {
+	return map[string]string{
+		"srcadr":     p.SRCAdr,
+		"srcport":    strconv.Itoa(p.SRCPort),
+		"dstadr":     p.DSTAdr,
+		"dstport":    strconv.Itoa(p.DSTPort),
+		"leap":       strconv.Itoa(p.Leap),
+		"stratum":    strconv.Itoa(p.Stratum),
+		"precision":  strconv.Itoa(p.Precision),
+		"rootdelay":  formatFloat(p.RootDelay),
+		"rootdisp":   formatFloat(p.RootDisp),
+		"refid":      p.RefID,
+		"reftime":    p.RefTime,
+		"reach":      fmt.Sprintf("0x%x", p.Reach),
+		"unreach":    strconv.Itoa(p.Unreach),
+		"hmode":      strconv.Itoa(p.HMode),
+		"pmode":      strconv.Itoa(p.PMode),
+		"hpoll":      strconv.Itoa(p.HPoll),
+		"ppoll":      strconv.Itoa(p.PPoll),
+		"headway":    strconv.Itoa(p.Headway),
+		"flash":      fmt.Sprintf("0x%x", p.Flash),
+		"offset":     formatFloat(p.Offset),
+		"delay":      formatFloat(p.Delay),
+		"dispersion": formatFloat(p.Dispersion),
+		"jitter":     formatFloat(p.Jitter),
+		"xleave":     formatFloat(p.Xleave),
+		"rec":        p.Rec,
+		"filtdelay":  p.FiltDelay,
+		"filtoffset": p.FiltOffset,
+		"filtdisp":   p.FiltDisp,
+	}
+}
+
+// NTPDVariables returns ntpd variables keyed by association ID.
+// Association 0 holds system variables, peers are numbered from 1 like ntpd does
+func (r *NTPCheckResult) NTPDVariables() map[uint16]map[string]string {
+	vars := map[uint16]map[string]string{}
+	if r.SysVars != nil {
+		vars[0] = r.SysVars.NTPDVariables()
+	}
+	ids := make([]int, 0, len(r.Peers))
+	for id := range r.Peers {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for i, id := range ids {
+		vars[uint16(i+1)] = r.Peers[uint16(id)].NTPDVariables()
+	}
+	return vars
+}
+
+// FormatNTPDVariables formats variables as comma separated k=v pairs sorted by key
+func FormatNTPDVariables(vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := vars[k]
+		// like ntpd, quote strings with spaces
+		if strings.Contains(v, " ") {
+			v = `"` + v + `"`
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	return strings.Join(pairs, ", ")
+}
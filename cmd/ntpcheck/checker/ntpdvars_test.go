@@ -0,0 +1,133 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/facebook/time/ntp/chrony"
+	"github.com/facebook/time/ntp/control"
+)
+
+func TestSystemVariablesNTPDVariables(t *testing.T) {
+	p := &chrony.ReplyTracking{}
+	p.LeapStatus = 1
+	p.Stratum = 3
+	p.RootDelay = 0.003
+	p.RootDispersion = 0.001
+	p.RefID = 123456
+	p.RefTime = time.Unix(1587738257, 0)
+	p.RMSOffset = 0.010
+	p.FreqPPM = -12.5
+	p.SkewPPM = 0.03
+	p.LastUpdateInterval = 64.3
+	s := NewSystemVariablesFromChrony(p)
+	require.Equal(t, 6, s.TC)
+	require.Equal(t, 0.03, s.ClkWander)
+
+	vars := s.NTPDVariables()
+	require.Equal(t, "3", vars["stratum"])
+	require.Equal(t, "3", vars["rootdelay"])
+	require.Equal(t, "0001E240", vars["refid"])
+	require.Equal(t, "-12.5", vars["frequency"])
+	require.Equal(t, "6", vars["tc"])
+
+	// ntpd parser must produce the same data
+	packet := &control.NTPControlMsg{
+		NTPControlMsgHead: control.NTPControlMsgHead{
+			VnMode: control.MakeVnMode(3, control.Mode),
+			REMOp:  control.OpReadVariables,
+		},
+		Data: []byte(FormatNTPDVariables(vars)),
+	}
+	parsed, err := NewSystemVariablesFromNTP(packet)
+	require.NoError(t, err)
+	require.Equal(t, s, parsed)
+}
+
+func TestPeerNTPDVariables(t *testing.T) {
+	s := &chrony.ReplySourceData{
+		SourceData: chrony.SourceData{
+			IPAddr:       net.ParseIP("192.168.0.2"),
+			Poll:         10,
+			Stratum:      2,
+			State:        chrony.SourceStateSync,
+			Mode:         chrony.SourceModeClient,
+			Flags:        chrony.NTPFlagsTests,
+			Reachability: 255,
+		},
+	}
+	n := &chrony.ReplyNTPData{
+		NTPData: chrony.NTPData{
+			LocalAddr:      net.ParseIP("192.168.0.3"),
+			RemotePort:     123,
+			Stratum:        2,
+			Poll:           10,
+			Precision:      -23,
+			RootDelay:      0.001,
+			RootDispersion: 0.002,
+			RefID:          3232235521,
+			RefTime:        time.Unix(1587738257, 0),
+			Offset:         -0.0001,
+			PeerDelay:      0.0005,
+			PeerDispersion: 0.00002,
+		},
+	}
+	peer, err := NewPeerFromChrony(s, n)
+	require.NoError(t, err)
+
+	vars := peer.NTPDVariables()
+	require.Equal(t, "192.168.0.2", vars["srcadr"])
+	require.Equal(t, "0xff", vars["reach"])
+	require.Equal(t, "0x0", vars["flash"])
+	require.Equal(t, "-0.1", vars["offset"])
+
+	packet := &control.NTPControlMsg{
+		NTPControlMsgHead: control.NTPControlMsgHead{
+			VnMode: control.MakeVnMode(3, control.Mode),
+			REMOp:  control.OpReadVariables,
+			Status: peer.PeerStatusWord().Word(),
+		},
+		Data: []byte(FormatNTPDVariables(vars)),
+	}
+	parsed, err := NewPeerFromNTP(packet)
+	require.NoError(t, err)
+	// condition is described in ntpd terms
+	peer.Condition = control.PeerSelect[peer.Selection]
+	require.Equal(t, peer, parsed)
+}
+
+func TestNTPCheckResultNTPDVariables(t *testing.T) {
+	r := NewNTPCheckResult()
+	r.SysVars = &SystemVariables{Stratum: 2}
+	r.Peers[0] = &Peer{SRCAdr: "192.168.0.2"}
+	r.Peers[5] = &Peer{SRCAdr: "192.168.0.5"}
+	vars := r.NTPDVariables()
+	require.Equal(t, 3, len(vars))
+	require.Equal(t, "2", vars[0]["stratum"])
+	require.Equal(t, "192.168.0.2", vars[1]["srcadr"])
+	require.Equal(t, "192.168.0.5", vars[2]["srcadr"])
+}
+
+func TestFormatNTPDVariables(t *testing.T) {
+	vars := map[string]string{"stratum": "2", "version": "chrony 4.1", "leap": "0"}
+	require.Equal(t, `leap=0, stratum=2, version="chrony 4.1"`, FormatNTPDVariables(vars))
+}
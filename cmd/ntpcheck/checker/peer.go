@@ -91,6 +91,11 @@ func NewPeerFromNTP(p *control.NTPControlMsg) (*Peer, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newPeerFromMap(psWord, m)
+}
+
+// newPeerFromMap constructs Peer from peer status word and ntpd peer variables
+func newPeerFromMap(psWord *control.PeerStatusWord, m map[string]string) (*Peer, error) {
 	var reach, flash uint16
 	// data comes as k=v pairs in packet, and those kv pairs are parsed by GetAssociationInfo.
 	// If data is severely corrupted GetAssociationInfo will return error.
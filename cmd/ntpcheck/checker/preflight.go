@@ -0,0 +1,264 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/facebook/time/clock"
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// Preflight check names
+const (
+	PreflightSync     = "sync"
+	PreflightOffset   = "offset"
+	PreflightPeers    = "peers"
+	PreflightPHC      = "phc"
+	PreflightLeapFile = "leapfile"
+	PreflightRootDist = "rootdistance"
+	PreflightLoop     = "loop"
+)
+
+var errLeapFileExpired = errors.New("leap file expired")
+
+// PreflightConfig holds thresholds for preflight checks. Zero thresholds are not checked
+type PreflightConfig struct {
+	// MaxOffset is the max abs system offset in ms
+	MaxOffset float64
+	// MaxRootDistance is the max system root distance in ms
+	MaxRootDistance float64
+	// MaxPeerOffset is the max abs offset of good peers in ms
+	MaxPeerOffset float64
+	// MinGoodPeers is the min number of peers suitable for synchronization
+	MinGoodPeers int
+	// PHCDevice such as /dev/ptp0. Check is skipped if empty
+	PHCDevice string
+	// MaxPHCOffset is the max abs offset between PHC and system clock
+	MaxPHCOffset time.Duration
+	// LeapFile such as /usr/share/zoneinfo/leap-seconds.list. Check is skipped if empty
+	LeapFile string
+	// MaxLeapFileAge is the max age of the leap file without expiration date
+	MaxLeapFileAge time.Duration
+	// Clock is the system clock. Replaceable for tests
+	Clock clock.Clock
+}
+
+// DefaultPreflightConfig is a reasonable config for a server about to be put into a pool
+var DefaultPreflightConfig = PreflightConfig{
+	MaxOffset:       1,
+	MaxRootDistance: 100,
+	MaxPeerOffset:   10,
+	MinGoodPeers:    2,
+	MaxPHCOffset:    time.Millisecond,
+	LeapFile:        "/usr/share/zoneinfo/leap-seconds.list",
+	MaxLeapFileAge:  180 * 24 * time.Hour,
+}
+
+// PreflightCheck is a result of a single preflight check
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Skipped bool   `json:"skipped"`
+	Message string `json:"message"`
+}
+
+// PreflightResult is a result of all preflight checks
+type PreflightResult struct {
+	Passed bool              `json:"passed"`
+	Checks []*PreflightCheck `json:"checks"`
+}
+
+// Failed returns checks which didn't pass
+func (p *PreflightResult) Failed() []*PreflightCheck {
+	failed := []*PreflightCheck{}
+	for _, c := range p.Checks {
+		if !c.Passed && !c.Skipped {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+func passed(name, format string, a ...interface{}) *PreflightCheck {
+	return &PreflightCheck{Name: name, Passed: true, Message: fmt.Sprintf(format, a...)}
+}
+
+func failed(name, format string, a ...interface{}) *PreflightCheck {
+	return &PreflightCheck{Name: name, Message: fmt.Sprintf(format, a...)}
+}
+
+func skipped(name, format string, a ...interface{}) *PreflightCheck {
+	return &PreflightCheck{Name: name, Skipped: true, Message: fmt.Sprintf(format, a...)}
+}
+
+// Preflight checks whether the host can serve time. r is the result of RunCheck
+func Preflight(r *NTPCheckResult, c *PreflightConfig) *PreflightResult {
+	return preflight(r, c, phcOffset, clock.Default(c.Clock).Now())
+}
+
+func preflight(r *NTPCheckResult, c *PreflightConfig, phcOffsetFunc func(string) (time.Duration, error), now time.Time) *PreflightResult {
+	res := &PreflightResult{
+		Checks: []*PreflightCheck{
+			preflightSync(r),
+			preflightOffset(r, c),
+			preflightRootDistance(r, c),
+			preflightPeers(r, c),
+			preflightLoop(r),
+			preflightPHC(c, phcOffsetFunc),
+			preflightLeapFile(c, now),
+		},
+	}
+	res.Passed = len(res.Failed()) == 0
+	return res
+}
+
+func preflightSync(r *NTPCheckResult) *PreflightCheck {
+	syspeer, err := r.FindSysPeer()
+	if err != nil {
+		return failed(PreflightSync, "clock is not syncing: %v", err)
+	}
+	if r.LI == 3 {
+		return failed(PreflightSync, "leap indicator is set to 'alarm'")
+	}
+	if r.SysVars != nil && r.SysVars.Stratum >= 16 {
+		return failed(PreflightSync, "stratum %d is unsynchronized", r.SysVars.Stratum)
+	}
+	return passed(PreflightSync, "clock is syncing to %s", syspeer.SRCAdr)
+}
+
+func preflightOffset(r *NTPCheckResult, c *PreflightConfig) *PreflightCheck {
+	if c.MaxOffset == 0 {
+		return skipped(PreflightOffset, "max offset is not set")
+	}
+	if r.SysVars == nil {
+		return failed(PreflightOffset, "no system variables")
+	}
+	if math.Abs(r.SysVars.Offset) > c.MaxOffset {
+		return failed(PreflightOffset, "offset %.3fms exceeds %.3fms", r.SysVars.Offset, c.MaxOffset)
+	}
+	return passed(PreflightOffset, "offset %.3fms is within %.3fms", r.SysVars.Offset, c.MaxOffset)
+}
+
+func preflightRootDistance(r *NTPCheckResult, c *PreflightConfig) *PreflightCheck {
+	if c.MaxRootDistance == 0 {
+		return skipped(PreflightRootDist, "max root distance is not set")
+	}
+	if r.SysVars == nil {
+		return failed(PreflightRootDist, "no system variables")
+	}
+	distance := r.SysVars.RootDistance()
+	if distance > c.MaxRootDistance {
+		return failed(PreflightRootDist, "root distance %.3fms exceeds %.3fms", distance, c.MaxRootDistance)
+	}
+	return passed(PreflightRootDist, "root distance %.3fms is within %.3fms", distance, c.MaxRootDistance)
+}
+
+func preflightLoop(r *NTPCheckResult) *PreflightCheck {
+	if loops := r.FindLoops(); len(loops) > 0 {
+		return failed(PreflightLoop, "peer %s (stratum %d) is synchronized to us, refid %s", loops[0].SRCAdr, loops[0].Stratum, loops[0].RefID)
+	}
+	return passed(PreflightLoop, "no synchronization loops")
+}
+
+func preflightPeers(r *NTPCheckResult, c *PreflightConfig) *PreflightCheck {
+	peers, err := r.FindGoodPeers()
+	if err != nil && c.MinGoodPeers > 0 {
+		return failed(PreflightPeers, "%v", err)
+	}
+	if len(peers) < c.MinGoodPeers {
+		return failed(PreflightPeers, "%d good peer(s), expected at least %d", len(peers), c.MinGoodPeers)
+	}
+	if c.MaxPeerOffset > 0 {
+		for _, p := range peers {
+			if math.Abs(p.Offset) > c.MaxPeerOffset {
+				return failed(PreflightPeers, "peer %s offset %.3fms exceeds %.3fms", p.SRCAdr, p.Offset, c.MaxPeerOffset)
+			}
+		}
+	}
+	return passed(PreflightPeers, "%d good peer(s)", len(peers))
+}
+
+func preflightPHC(c *PreflightConfig, phcOffsetFunc func(string) (time.Duration, error)) *PreflightCheck {
+	if c.PHCDevice == "" {
+		return skipped(PreflightPHC, "PHC device is not set")
+	}
+	offset, err := phcOffsetFunc(c.PHCDevice)
+	if err != nil {
+		return failed(PreflightPHC, "failed to read %s: %v", c.PHCDevice, err)
+	}
+	if c.MaxPHCOffset > 0 && (offset > c.MaxPHCOffset || offset < -c.MaxPHCOffset) {
+		return failed(PreflightPHC, "%s offset %v exceeds %v", c.PHCDevice, offset, c.MaxPHCOffset)
+	}
+	return passed(PreflightPHC, "%s offset %v", c.PHCDevice, offset)
+}
+
+func preflightLeapFile(c *PreflightConfig, now time.Time) *PreflightCheck {
+	if c.LeapFile == "" {
+		return skipped(PreflightLeapFile, "leap file is not set")
+	}
+	expires, err := leapFileExpiration(c.LeapFile)
+	if err != nil {
+		return failed(PreflightLeapFile, "failed to read %s: %v", c.LeapFile, err)
+	}
+	if !expires.IsZero() {
+		if now.After(expires) {
+			return failed(PreflightLeapFile, "%s: %v on %s", c.LeapFile, errLeapFileExpired, expires.Format(time.RFC3339))
+		}
+		return passed(PreflightLeapFile, "%s expires on %s", c.LeapFile, expires.Format(time.RFC3339))
+	}
+	// no expiration date, fall back to the file age
+	st, err := os.Stat(c.LeapFile)
+	if err != nil {
+		return failed(PreflightLeapFile, "failed to stat %s: %v", c.LeapFile, err)
+	}
+	age := now.Sub(st.ModTime())
+	if c.MaxLeapFileAge > 0 && age > c.MaxLeapFileAge {
+		return failed(PreflightLeapFile, "%s is %v old, expected at most %v", c.LeapFile, age.Truncate(time.Hour), c.MaxLeapFileAge)
+	}
+	return passed(PreflightLeapFile, "%s is %v old", c.LeapFile, age.Truncate(time.Hour))
+}
+
+// leapFileExpiration returns expiration date from "#@" line of leap-seconds.list.
+// Zero time is returned if there is none
+func leapFileExpiration(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#@") {
+			continue
+		}
+		secs, err := strconv.ParseUint(strings.TrimSpace(line[2:]), 10, 32)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid expiration %q: %w", line, err)
+		}
+		return ntp.Unix(uint32(secs), 0), nil
+	}
+	return time.Time{}, scanner.Err()
+}
@@ -0,0 +1,43 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"time"
+
+	"github.com/facebook/time/phc"
+	"github.com/facebook/time/rtc"
+)
+
+// phcOffset returns offset between PHC device and system clock
+func phcOffset(device string) (time.Duration, error) {
+	res, err := phc.TimeAndOffsetFromDevice(device, phc.MethodIoctlSysOffsetExtended)
+	if err != nil {
+		return 0, err
+	}
+	return res.Offset, nil
+}
+
+// rtcOffset returns offset between RTC and system clock
+func rtcOffset(device string) (time.Duration, error) {
+	r, err := rtc.Open(device)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return r.Offset()
+}
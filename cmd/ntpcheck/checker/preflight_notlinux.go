@@ -0,0 +1,35 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"errors"
+	"time"
+)
+
+// phcOffset is not supported outside of linux
+func phcOffset(device string) (time.Duration, error) {
+	return 0, errors.New("PHC is only supported on linux")
+}
+
+// rtcOffset is not supported outside of linux
+func rtcOffset(device string) (time.Duration, error) {
+	return 0, errors.New("RTC is only supported on linux")
+}
@@ -0,0 +1,133 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/facebook/time/ntp/control"
+	"github.com/stretchr/testify/require"
+)
+
+func preflightResult() *NTPCheckResult {
+	return &NTPCheckResult{
+		SysVars: &SystemVariables{Stratum: 2, Offset: 0.1},
+		Peers: map[uint16]*Peer{
+			1: {Selection: control.SelSYSPeer, SRCAdr: "192.0.2.1", Offset: 0.2},
+			2: {Selection: control.SelCandidate, SRCAdr: "192.0.2.2", Offset: -0.3},
+			3: {Selection: control.SelReject, SRCAdr: "192.0.2.3", Offset: 100},
+		},
+	}
+}
+
+func TestPreflightPassed(t *testing.T) {
+	dir := t.TempDir()
+	leapFile := filepath.Join(dir, "leap-seconds.list")
+	// expires 28 June 2022
+	require.NoError(t, ioutil.WriteFile(leapFile, []byte("#$\t 3676924800\n#@\t3865363200\n2272060800\t10\t# 1 Jan 1972\n"), 0644))
+
+	c := DefaultPreflightConfig
+	c.LeapFile = leapFile
+	c.PHCDevice = "/dev/ptp0"
+	phcOffsetFunc := func(string) (time.Duration, error) { return time.Microsecond, nil }
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	res := preflight(preflightResult(), &c, phcOffsetFunc, now)
+	require.True(t, res.Passed, res.Failed())
+	require.Len(t, res.Checks, 7)
+	require.Empty(t, res.Failed())
+
+	// expired leap file
+	res = preflight(preflightResult(), &c, phcOffsetFunc, now.Add(365*24*time.Hour))
+	require.False(t, res.Passed)
+	require.Equal(t, PreflightLeapFile, res.Failed()[0].Name)
+}
+
+func TestPreflightSkipped(t *testing.T) {
+	c := PreflightConfig{}
+	res := preflight(preflightResult(), &c, nil, time.Now())
+	require.True(t, res.Passed)
+	skipped := 0
+	for _, check := range res.Checks {
+		if check.Skipped {
+			skipped++
+		}
+	}
+	require.Equal(t, 4, skipped)
+}
+
+func TestPreflightFailed(t *testing.T) {
+	c := DefaultPreflightConfig
+	c.LeapFile = ""
+	c.PHCDevice = "/dev/ptp0"
+	phcOffsetFunc := func(string) (time.Duration, error) { return 0, errors.New("no such device") }
+
+	r := preflightResult()
+	r.SysVars.Offset = 5
+	r.Peers[2].Offset = 50
+	res := preflight(r, &c, phcOffsetFunc, time.Now())
+	require.False(t, res.Passed)
+	names := []string{}
+	for _, check := range res.Failed() {
+		names = append(names, check.Name)
+	}
+	require.Equal(t, []string{PreflightOffset, PreflightPeers, PreflightPHC}, names)
+
+	// not syncing
+	r = preflightResult()
+	r.Peers[1].Selection = control.SelCandidate
+	require.False(t, preflightSync(r).Passed)
+
+	// alarm
+	r = preflightResult()
+	r.LI = 3
+	require.False(t, preflightSync(r).Passed)
+
+	// not enough peers
+	r = preflightResult()
+	delete(r.Peers, 2)
+	require.False(t, preflightPeers(r, &c).Passed)
+
+	// PHC too far
+	c.MaxPHCOffset = time.Microsecond
+	check := preflightPHC(&c, func(string) (time.Duration, error) { return -time.Millisecond, nil })
+	require.False(t, check.Passed)
+}
+
+func TestPreflightLeapFileAge(t *testing.T) {
+	dir := t.TempDir()
+	leapFile := filepath.Join(dir, "right-UTC")
+	require.NoError(t, ioutil.WriteFile(leapFile, []byte("TZif"), 0644))
+	now := time.Now()
+	require.NoError(t, os.Chtimes(leapFile, now, now.Add(-time.Hour)))
+
+	c := PreflightConfig{LeapFile: leapFile, MaxLeapFileAge: 24 * time.Hour}
+	require.True(t, preflightLeapFile(&c, now).Passed)
+	require.False(t, preflightLeapFile(&c, now.Add(48*time.Hour)).Passed)
+
+	c.LeapFile = filepath.Join(dir, "missing")
+	require.False(t, preflightLeapFile(&c, now).Passed)
+
+	require.NoError(t, ioutil.WriteFile(leapFile, []byte("#@ bad\n"), 0644))
+	c.LeapFile = leapFile
+	require.False(t, preflightLeapFile(&c, now).Passed)
+}
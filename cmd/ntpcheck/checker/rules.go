@@ -0,0 +1,256 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/facebook/time/ntp/control"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Rule names
+const (
+	RuleSync         = "sync"
+	RuleOffset       = "offset"
+	RuleJitter       = "jitter"
+	RuleReach        = "reach"
+	RuleStratum      = "stratum"
+	RuleRootDistance = "root_distance"
+	RuleLoop         = "loop"
+)
+
+// Severity of the alert
+type Severity int
+
+// Alert severities
+const (
+	SeverityWarning Severity = iota + 1
+	SeverityCritical
+)
+
+var severityToString = map[Severity]string{
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	str, ok := severityToString[s]
+	if !ok {
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+	return str
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Threshold is a pair of warning and critical limits. Zero limit is not checked
+type Threshold struct {
+	Warning  float64 `yaml:"warning"`
+	Critical float64 `yaml:"critical"`
+}
+
+// above returns severity if abs value exceeds the threshold
+func (t Threshold) above(value float64) (Severity, float64, bool) {
+	v := math.Abs(value)
+	if t.Critical > 0 && v > t.Critical {
+		return SeverityCritical, t.Critical, true
+	}
+	if t.Warning > 0 && v > t.Warning {
+		return SeverityWarning, t.Warning, true
+	}
+	return 0, 0, false
+}
+
+// below returns severity if value is below the threshold
+func (t Threshold) below(value float64) (Severity, float64, bool) {
+	if t.Critical > 0 && value < t.Critical {
+		return SeverityCritical, t.Critical, true
+	}
+	if t.Warning > 0 && value < t.Warning {
+		return SeverityWarning, t.Warning, true
+	}
+	return 0, 0, false
+}
+
+// Rules hold alert thresholds which can be read from a yaml file.
+// Example:
+//
+//	offset:           # abs sys.peer offset in ms
+//	  warning: 1
+//	  critical: 1000
+//	jitter:           # sys.peer jitter in ms
+//	  warning: 1
+//	  critical: 1000
+//	reach:            # min successful polls out of last 8, checked for every peer
+//	  warning: 8
+//	  critical: 1
+//	stratum:          # system stratum
+//	  warning: 4
+//	  critical: 15
+//	root_distance:    # root delay / 2 + root dispersion in ms
+//	  warning: 100
+//	  critical: 1500
+type Rules struct {
+	Offset       Threshold `yaml:"offset"`
+	Jitter       Threshold `yaml:"jitter"`
+	Reach        Threshold `yaml:"reach"`
+	Stratum      Threshold `yaml:"stratum"`
+	RootDistance Threshold `yaml:"root_distance"`
+}
+
+// DefaultRules are the same thresholds 'ntpcheck diag' uses
+var DefaultRules = Rules{
+	Offset:       Threshold{Warning: 1, Critical: 1000},
+	Jitter:       Threshold{Warning: 1, Critical: 1000},
+	Reach:        Threshold{Warning: 8},
+	Stratum:      Threshold{Warning: 4, Critical: 15},
+	RootDistance: Threshold{Warning: 100, Critical: 1500},
+}
+
+// ReadRules reads and validates rules from the yaml file
+func ReadRules(path string) (*Rules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Rules{}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(true)
+	if err := d.Decode(r); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate checks all thresholds are consistent
+func (r *Rules) Validate() error {
+	above := map[string]Threshold{
+		RuleOffset:       r.Offset,
+		RuleJitter:       r.Jitter,
+		RuleStratum:      r.Stratum,
+		RuleRootDistance: r.RootDistance,
+	}
+	for name, t := range above {
+		if t.Warning < 0 || t.Critical < 0 {
+			return fmt.Errorf("%s: thresholds must not be negative", name)
+		}
+		if t.Warning > 0 && t.Critical > 0 && t.Warning > t.Critical {
+			return fmt.Errorf("%s: warning threshold %v is above critical %v", name, t.Warning, t.Critical)
+		}
+	}
+	if r.Reach.Warning < 0 || r.Reach.Critical < 0 || r.Reach.Warning > 8 || r.Reach.Critical > 8 {
+		return fmt.Errorf("%s: thresholds must be within [0, 8]", RuleReach)
+	}
+	if r.Reach.Warning > 0 && r.Reach.Critical > r.Reach.Warning {
+		return fmt.Errorf("%s: critical threshold %v is above warning %v", RuleReach, r.Reach.Critical, r.Reach.Warning)
+	}
+	return nil
+}
+
+// Alert is a single violated rule
+type Alert struct {
+	Rule      string   `json:"rule"`
+	Severity  Severity `json:"severity"`
+	Peer      string   `json:"peer,omitempty"`
+	Value     float64  `json:"value"`
+	Threshold float64  `json:"threshold"`
+	Message   string   `json:"message"`
+}
+
+func newAlert(rule string, s Severity, peer string, value, threshold float64, format string, a ...interface{}) *Alert {
+	return &Alert{
+		Rule:      rule,
+		Severity:  s,
+		Peer:      peer,
+		Value:     value,
+		Threshold: threshold,
+		Message:   fmt.Sprintf(format, a...),
+	}
+}
+
+// MaxSeverity returns the highest severity of alerts, 0 if there are none
+func MaxSeverity(alerts []*Alert) Severity {
+	var max Severity
+	for _, a := range alerts {
+		if a.Severity > max {
+			max = a.Severity
+		}
+	}
+	return max
+}
+
+// Evaluate checks result of RunCheck against the rules. Alerts are sorted by severity, most severe first
+func (r *Rules) Evaluate(res *NTPCheckResult) []*Alert {
+	alerts := []*Alert{}
+	syspeer, err := res.FindSysPeer()
+	if err != nil {
+		alerts = append(alerts, newAlert(RuleSync, SeverityCritical, "", 0, 0, "clock is not syncing: %v", err))
+	} else {
+		if s, t, ok := r.Offset.above(syspeer.Offset); ok {
+			alerts = append(alerts, newAlert(RuleOffset, s, syspeer.SRCAdr, syspeer.Offset, t, "sys.peer offset %.3fms exceeds %.3fms", syspeer.Offset, t))
+		}
+		if s, t, ok := r.Jitter.above(syspeer.Jitter); ok {
+			alerts = append(alerts, newAlert(RuleJitter, s, syspeer.SRCAdr, syspeer.Jitter, t, "sys.peer jitter %.3fms exceeds %.3fms", syspeer.Jitter, t))
+		}
+	}
+	if res.SysVars != nil {
+		stratum := float64(res.SysVars.Stratum)
+		if s, t, ok := r.Stratum.above(stratum); ok {
+			alerts = append(alerts, newAlert(RuleStratum, s, "", stratum, t, "stratum %d exceeds %d", res.SysVars.Stratum, int(t)))
+		}
+		distance := res.SysVars.RootDistance()
+		if s, t, ok := r.RootDistance.above(distance); ok {
+			alerts = append(alerts, newAlert(RuleRootDistance, s, "", distance, t, "root distance %.3fms exceeds %.3fms", distance, t))
+		}
+	}
+	for _, p := range res.Peers {
+		polls := float64(bits.OnesCount8(p.Reach))
+		if s, t, ok := r.Reach.below(polls); ok {
+			alerts = append(alerts, newAlert(RuleReach, s, p.SRCAdr, polls, t, "peer %s reach %08b: %d of last 8 polls succeeded, expected at least %d", p.SRCAdr, p.Reach, int(polls), int(t)))
+		}
+	}
+	// a loop through the sys.peer breaks synchronization, others are merely useless
+	for _, p := range res.FindLoops() {
+		s := SeverityWarning
+		if p.Selection == control.SelSYSPeer {
+			s = SeverityCritical
+		}
+		alerts = append(alerts, newAlert(RuleLoop, s, p.SRCAdr, float64(p.Stratum), 0, "peer %s is synchronized to us, refid %s", p.SRCAdr, p.RefID))
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if alerts[i].Severity != alerts[j].Severity {
+			return alerts[i].Severity > alerts[j].Severity
+		}
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].Peer < alerts[j].Peer
+	})
+	return alerts
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/facebook/time/ntp/control"
+	"github.com/stretchr/testify/require"
+)
+
+func rulesResult() *NTPCheckResult {
+	return &NTPCheckResult{
+		SysVars: &SystemVariables{Stratum: 2, RootDelay: 10, RootDisp: 5},
+		Peers: map[uint16]*Peer{
+			1: {Selection: control.SelSYSPeer, SRCAdr: "192.0.2.1", Offset: 0.2, Jitter: 0.1, Reach: 255},
+			2: {Selection: control.SelCandidate, SRCAdr: "192.0.2.2", Offset: -0.3, Jitter: 0.2, Reach: 255},
+		},
+	}
+}
+
+func TestRulesEvaluateNoAlerts(t *testing.T) {
+	alerts := DefaultRules.Evaluate(rulesResult())
+	require.Empty(t, alerts)
+	require.Equal(t, Severity(0), MaxSeverity(alerts))
+}
+
+func TestRulesEvaluate(t *testing.T) {
+	r := rulesResult()
+	r.Peers[1].Offset = -2000
+	r.Peers[1].Jitter = 2
+	r.Peers[2].Reach = 0x7f
+	r.SysVars.Stratum = 5
+	r.SysVars.RootDisp = 200
+
+	rules := DefaultRules
+	rules.Reach.Critical = 1
+	alerts := rules.Evaluate(r)
+	require.Equal(t, []*Alert{
+		{Rule: RuleOffset, Severity: SeverityCritical, Peer: "192.0.2.1", Value: -2000, Threshold: 1000, Message: "sys.peer offset -2000.000ms exceeds 1000.000ms"},
+		{Rule: RuleJitter, Severity: SeverityWarning, Peer: "192.0.2.1", Value: 2, Threshold: 1, Message: "sys.peer jitter 2.000ms exceeds 1.000ms"},
+		{Rule: RuleReach, Severity: SeverityWarning, Peer: "192.0.2.2", Value: 7, Threshold: 8, Message: "peer 192.0.2.2 reach 01111111: 7 of last 8 polls succeeded, expected at least 8"},
+		{Rule: RuleRootDistance, Severity: SeverityWarning, Value: 205, Threshold: 100, Message: "root distance 205.000ms exceeds 100.000ms"},
+		{Rule: RuleStratum, Severity: SeverityWarning, Value: 5, Threshold: 4, Message: "stratum 5 exceeds 4"},
+	}, alerts)
+	require.Equal(t, SeverityCritical, MaxSeverity(alerts))
+
+	// zero thresholds are not checked
+	require.Empty(t, (&Rules{}).Evaluate(r))
+}
+
+func TestRulesEvaluateNoSync(t *testing.T) {
+	r := rulesResult()
+	r.Peers[1].Selection = control.SelCandidate
+	alerts := DefaultRules.Evaluate(r)
+	require.Len(t, alerts, 1)
+	require.Equal(t, RuleSync, alerts[0].Rule)
+	require.Equal(t, SeverityCritical, alerts[0].Severity)
+}
+
+func TestReadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("offset:\n  warning: 0.5\n  critical: 10\nreach:\n  critical: 4\n"), 0644))
+	r, err := ReadRules(path)
+	require.NoError(t, err)
+	require.Equal(t, &Rules{Offset: Threshold{Warning: 0.5, Critical: 10}, Reach: Threshold{Critical: 4}}, r)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("offset:\n  warn: 1\n"), 0644))
+	_, err = ReadRules(path)
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("jitter:\n  warning: 10\n  critical: 1\n"), 0644))
+	_, err = ReadRules(path)
+	require.EqualError(t, err, "jitter: warning threshold 10 is above critical 1")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("reach:\n  warning: 9\n"), 0644))
+	_, err = ReadRules(path)
+	require.EqualError(t, err, "reach: thresholds must be within [0, 8]")
+}
+
+func TestAlertJSON(t *testing.T) {
+	b, err := json.Marshal(&Alert{Rule: RuleStratum, Severity: SeverityCritical, Value: 16, Threshold: 15, Message: "m"})
+	require.NoError(t, err)
+	require.Equal(t, `{"rule":"stratum","severity":"critical","value":16,"threshold":15,"message":"m"}`, string(b))
+}
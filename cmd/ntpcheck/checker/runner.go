@@ -18,6 +18,7 @@ package checker
 
 import (
 	"bufio"
+	"fmt"
 	"net"
 	"os"
 	"regexp"
@@ -82,11 +83,34 @@ func getChecker(f flavour, conn net.Conn) Runner {
 	return NewNTPCheck(conn)
 }
 
+// Daemons RunCheckDaemon can talk to
+const (
+	DaemonNTPD   = "ntpd"
+	DaemonChrony = "chrony"
+)
+
 // RunCheck is a simple wrapper to connect to address and run NTPCheck.Run()
 func RunCheck(address string) (*NTPCheckResult, error) {
+	return runCheck(address, getFlavour())
+}
+
+// RunCheckDaemon is like RunCheck, but uses protocol of the daemon instead of detecting the local one.
+// It allows checking remote hosts running a different daemon. Empty daemon means detection
+func RunCheckDaemon(address, daemon string) (*NTPCheckResult, error) {
+	switch daemon {
+	case "":
+		return RunCheck(address)
+	case DaemonNTPD:
+		return runCheck(address, flavourNTPD)
+	case DaemonChrony:
+		return runCheck(address, flavourChrony)
+	}
+	return nil, fmt.Errorf("unsupported daemon %q", daemon)
+}
+
+func runCheck(address string, flavour flavour) (*NTPCheckResult, error) {
 	timeout := 5 * time.Second
 	deadline := time.Now().Add(timeout)
-	flavour := getFlavour()
 	if address == "" {
 		address = getPublicServer(flavour)
 	}
@@ -0,0 +1,154 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/facebook/time/clock"
+)
+
+// Sanity check names
+const (
+	SanityMinTime = "mintime"
+	SanityMaxTime = "maxtime"
+	SanityRTC     = "rtc"
+	SanityNTP     = "ntp"
+)
+
+// BuildTime is the earliest plausible time, RFC3339 formatted.
+// Set it at build time with -ldflags "-X github.com/facebook/time/cmd/ntpcheck/checker.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var BuildTime = "2021-12-01T00:00:00Z"
+
+// SanityConfig holds thresholds for startup time sanity checks. Zero thresholds are not checked
+type SanityConfig struct {
+	// MinTime is the earliest plausible time. BuildTime is used if zero
+	MinTime time.Time
+	// MaxFuture is how far past MinTime the clock can plausibly be
+	MaxFuture time.Duration
+	// RTCDevice such as /dev/rtc. Check is skipped if empty
+	RTCDevice string
+	// MaxRTCOffset is the max abs offset between RTC and system clock
+	MaxRTCOffset time.Duration
+	// Servers are NTP servers (host:port) to query. Check is skipped if empty
+	Servers []string
+	// MaxNTPOffset is the max abs offset of a server to agree with the system clock
+	MaxNTPOffset time.Duration
+	// MinServers is the min number of servers agreeing with the system clock
+	MinServers int
+	// Timeout of every NTP query
+	Timeout time.Duration
+	// Clock is the system clock. Replaceable for tests
+	Clock clock.Clock
+}
+
+// DefaultSanityConfig only catches clocks which are wildly off, the way it breaks TLS
+var DefaultSanityConfig = SanityConfig{
+	MaxFuture:    10 * 365 * 24 * time.Hour,
+	RTCDevice:    "/dev/rtc",
+	MaxRTCOffset: 10 * time.Minute,
+	MaxNTPOffset: 10 * time.Second,
+	MinServers:   1,
+	Timeout:      time.Second,
+}
+
+// ntpOffsetFunc returns offset of the server
+type ntpOffsetFunc func(address string, timeout time.Duration) (offset, delay time.Duration, err error)
+
+// Sanity checks system clock at startup against build time, RTC and NTP servers.
+// Host should not be marked healthy unless the result passed
+func Sanity(c *SanityConfig) (*PreflightResult, error) {
+	minTime := c.MinTime
+	if minTime.IsZero() {
+		t, err := time.Parse(time.RFC3339, BuildTime)
+		if err != nil {
+			return nil, fmt.Errorf("parsing build time %q: %w", BuildTime, err)
+		}
+		minTime = t
+	}
+	return sanity(c, minTime, clock.Default(c.Clock).Now(), rtcOffset, ntpOffset), nil
+}
+
+func sanity(c *SanityConfig, minTime, now time.Time, rtcOffsetFunc func(string) (time.Duration, error), ntpOffsetFunc ntpOffsetFunc) *PreflightResult {
+	res := &PreflightResult{
+		Checks: []*PreflightCheck{
+			sanityMinTime(minTime, now),
+			sanityMaxTime(c, minTime, now),
+			sanityRTC(c, rtcOffsetFunc),
+			sanityNTP(c, ntpOffsetFunc),
+		},
+	}
+	res.Passed = len(res.Failed()) == 0
+	return res
+}
+
+func sanityMinTime(minTime, now time.Time) *PreflightCheck {
+	if now.Before(minTime) {
+		return failed(SanityMinTime, "clock %s is before %s", now.Format(time.RFC3339), minTime.Format(time.RFC3339))
+	}
+	return passed(SanityMinTime, "clock %s is after %s", now.Format(time.RFC3339), minTime.Format(time.RFC3339))
+}
+
+func sanityMaxTime(c *SanityConfig, minTime, now time.Time) *PreflightCheck {
+	if c.MaxFuture == 0 {
+		return skipped(SanityMaxTime, "max future is not set")
+	}
+	maxTime := minTime.Add(c.MaxFuture)
+	if now.After(maxTime) {
+		return failed(SanityMaxTime, "clock %s is after %s", now.Format(time.RFC3339), maxTime.Format(time.RFC3339))
+	}
+	return passed(SanityMaxTime, "clock %s is before %s", now.Format(time.RFC3339), maxTime.Format(time.RFC3339))
+}
+
+func sanityRTC(c *SanityConfig, rtcOffsetFunc func(string) (time.Duration, error)) *PreflightCheck {
+	if c.RTCDevice == "" || c.MaxRTCOffset == 0 {
+		return skipped(SanityRTC, "RTC device is not set")
+	}
+	offset, err := rtcOffsetFunc(c.RTCDevice)
+	if err != nil {
+		return failed(SanityRTC, "failed to read %s: %v", c.RTCDevice, err)
+	}
+	if offset > c.MaxRTCOffset || offset < -c.MaxRTCOffset {
+		return failed(SanityRTC, "RTC offset %v exceeds %v", offset, c.MaxRTCOffset)
+	}
+	return passed(SanityRTC, "RTC offset %v is within %v", offset, c.MaxRTCOffset)
+}
+
+func sanityNTP(c *SanityConfig, ntpOffsetFunc ntpOffsetFunc) *PreflightCheck {
+	if len(c.Servers) == 0 || c.MaxNTPOffset == 0 {
+		return skipped(SanityNTP, "no servers")
+	}
+	agree := 0
+	var lastErr error
+	for _, server := range c.Servers {
+		offset, _, err := ntpOffsetFunc(server, c.Timeout)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", server, err)
+			continue
+		}
+		if offset > c.MaxNTPOffset || offset < -c.MaxNTPOffset {
+			lastErr = fmt.Errorf("%s: offset %v exceeds %v", server, offset, c.MaxNTPOffset)
+			continue
+		}
+		agree++
+	}
+	if agree < c.MinServers {
+		return failed(SanityNTP, "%d of %d servers agree with the clock, need %d. Last error: %v", agree, len(c.Servers), c.MinServers, lastErr)
+	}
+	return passed(SanityNTP, "%d of %d servers agree with the clock within %v", agree, len(c.Servers), c.MaxNTPOffset)
+}
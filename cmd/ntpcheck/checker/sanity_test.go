@@ -0,0 +1,108 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/facebook/time/clock"
+)
+
+func TestSanityPassed(t *testing.T) {
+	c := DefaultSanityConfig
+	c.Servers = []string{"192.0.2.1", "192.0.2.2"}
+	c.MinServers = 2
+	minTime := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	rtcOffsetFunc := func(string) (time.Duration, error) { return -time.Second, nil }
+	ntpOffsetFunc := func(string, time.Duration) (time.Duration, time.Duration, error) {
+		return time.Millisecond, time.Millisecond, nil
+	}
+
+	res := sanity(&c, minTime, now, rtcOffsetFunc, ntpOffsetFunc)
+	require.True(t, res.Passed, res.Failed())
+	require.Len(t, res.Checks, 4)
+}
+
+func TestSanitySkipped(t *testing.T) {
+	c := SanityConfig{}
+	minTime := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	res := sanity(&c, minTime, minTime, nil, nil)
+	require.True(t, res.Passed)
+	skipped := 0
+	for _, check := range res.Checks {
+		if check.Skipped {
+			skipped++
+		}
+	}
+	require.Equal(t, 3, skipped)
+}
+
+func TestSanityFailed(t *testing.T) {
+	c := DefaultSanityConfig
+	c.Servers = []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}
+	c.MinServers = 2
+	minTime := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	rtcOffsetFunc := func(string) (time.Duration, error) { return 0, errors.New("no rtc") }
+	ntpOffsetFunc := func(server string, _ time.Duration) (time.Duration, time.Duration, error) {
+		switch server {
+		case "192.0.2.1":
+			return time.Millisecond, 0, nil
+		case "192.0.2.2":
+			return time.Hour, 0, nil
+		}
+		return 0, 0, errors.New("timeout")
+	}
+
+	// clock is reset to the epoch
+	res := sanity(&c, minTime, time.Unix(0, 0), rtcOffsetFunc, ntpOffsetFunc)
+	require.False(t, res.Passed)
+	names := []string{}
+	for _, check := range res.Failed() {
+		names = append(names, check.Name)
+	}
+	require.Equal(t, []string{SanityMinTime, SanityRTC, SanityNTP}, names)
+
+	// clock is years in the future
+	res = sanity(&c, minTime, minTime.Add(20*365*24*time.Hour), rtcOffsetFunc, ntpOffsetFunc)
+	require.False(t, res.Passed)
+	require.Equal(t, SanityMaxTime, res.Failed()[0].Name)
+}
+
+func TestSanityBuildTime(t *testing.T) {
+	saved := BuildTime
+	defer func() { BuildTime = saved }()
+
+	BuildTime = "yesterday"
+	_, err := Sanity(&SanityConfig{})
+	require.Error(t, err)
+
+	BuildTime = "2021-12-01T00:00:00Z"
+	res, err := Sanity(&SanityConfig{})
+	require.NoError(t, err)
+	require.True(t, res.Passed)
+
+	// clock reset to the epoch
+	res, err = Sanity(&SanityConfig{Clock: clock.NewSimulated(time.Unix(0, 0))})
+	require.NoError(t, err)
+	require.False(t, res.Passed)
+	require.Equal(t, SanityMinTime, res.Failed()[0].Name)
+}
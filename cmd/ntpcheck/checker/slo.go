@@ -0,0 +1,163 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultSLOInterval is the period samples are aggregated into. SLO counts good minutes
+const DefaultSLOInterval = time.Minute
+
+// SLOConfig describes time sync objective, such as 99.9% of minutes within ±100µs
+type SLOConfig struct {
+	// Bound is the max absolute offset of a good sample
+	Bound time.Duration
+	// Target is the percent of intervals which must be good
+	Target float64
+	// Windows to report compliance and error budget over, such as 1h, 1d and 30d
+	Windows []time.Duration
+	// Interval is a period samples are aggregated into. It's good if all its samples are within Bound
+	Interval time.Duration
+}
+
+// SLOWindow is SLO compliance over a rolling window
+type SLOWindow struct {
+	Window time.Duration `json:"window"`
+	// Intervals with samples in the window. Intervals without samples are not counted
+	Intervals int `json:"intervals"`
+	Good      int `json:"good"`
+	// Compliance is the percent of good intervals
+	Compliance float64 `json:"compliance"`
+	// BudgetRemaining and BurnRate are not set with 100% target, which has no error budget.
+	// BudgetRemaining is the fraction of the error budget left in the window, negative if it's exceeded
+	BudgetRemaining float64 `json:"budget_remaining"`
+	// BurnRate is how fast error budget is spent. 1 spends exactly the budget over the window
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// SLO tracks compliance of offset samples with the objective over rolling windows.
+// Not safe for concurrent use
+type SLO struct {
+	Config *SLOConfig
+
+	// intervals by start unix time, true if all samples are within bound
+	intervals map[int64]bool
+	last      time.Time
+}
+
+// NewSLO returns SLO tracking samples against the config
+func NewSLO(c *SLOConfig) *SLO {
+	if c.Interval <= 0 {
+		c.Interval = DefaultSLOInterval
+	}
+	return &SLO{Config: c, intervals: map[int64]bool{}}
+}
+
+func (s *SLO) maxWindow() time.Duration {
+	var w time.Duration
+	for _, window := range s.Config.Windows {
+		if window > w {
+			w = window
+		}
+	}
+	return w
+}
+
+func (s *SLO) start(t time.Time) int64 {
+	return t.Truncate(s.Config.Interval).UnixNano()
+}
+
+// Add accounts offset sample taken at t. Samples older than the largest window are dropped
+func (s *SLO) Add(t time.Time, offset time.Duration) {
+	if t.After(s.last) {
+		s.last = t
+		oldest := s.start(s.last.Add(-s.maxWindow()))
+		for start := range s.intervals {
+			if start < oldest {
+				delete(s.intervals, start)
+			}
+		}
+	}
+	start := s.start(t)
+	if start < s.start(s.last.Add(-s.maxWindow())) {
+		return
+	}
+	good := offset <= s.Config.Bound && offset >= -s.Config.Bound
+	if prev, ok := s.intervals[start]; ok {
+		good = good && prev
+	}
+	s.intervals[start] = good
+}
+
+// Report returns compliance over every window ending with the latest sample
+func (s *SLO) Report() []*SLOWindow {
+	budget := 1 - s.Config.Target/100
+	res := make([]*SLOWindow, 0, len(s.Config.Windows))
+	for _, window := range s.Config.Windows {
+		w := &SLOWindow{Window: window}
+		oldest := s.start(s.last.Add(-window))
+		for start, good := range s.intervals {
+			// the interval window starts in is only partially covered
+			if start <= oldest {
+				continue
+			}
+			w.Intervals++
+			if good {
+				w.Good++
+			}
+		}
+		if w.Intervals > 0 {
+			bad := float64(w.Intervals-w.Good) / float64(w.Intervals)
+			w.Compliance = 100 * (1 - bad)
+			if budget > 0 {
+				w.BurnRate = bad / budget
+				w.BudgetRemaining = 1 - w.BurnRate
+			}
+		}
+		res = append(res, w)
+	}
+	return res
+}
+
+// windowName returns short window name such as 30d, 1h or 5m
+func windowName(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
+
+// Metrics returns SLO report as flat metrics, such as ntp.slo.1h.compliance
+func (s *SLO) Metrics() map[string]float64 {
+	m := map[string]float64{}
+	for _, w := range s.Report() {
+		prefix := fmt.Sprintf("ntp.slo.%s.", windowName(w.Window))
+		m[prefix+"intervals"] = float64(w.Intervals)
+		m[prefix+"good"] = float64(w.Good)
+		m[prefix+"compliance"] = w.Compliance
+		m[prefix+"budget_remaining"] = w.BudgetRemaining
+		m[prefix+"burn_rate"] = w.BurnRate
+	}
+	return m
+}
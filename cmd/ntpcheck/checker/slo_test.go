@@ -0,0 +1,94 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOReport(t *testing.T) {
+	s := NewSLO(&SLOConfig{Bound: 100 * time.Microsecond, Target: 99, Windows: []time.Duration{10 * time.Minute, time.Hour}})
+	require.Equal(t, DefaultSLOInterval, s.Config.Interval)
+
+	start := time.Unix(1640995200, 0)
+	for i := 0; i < 120; i++ {
+		offset := 50 * time.Microsecond
+		// one bad sample spoils the whole minute
+		if i == 100 {
+			offset = -150 * time.Microsecond
+		}
+		s.Add(start.Add(time.Duration(i)*30*time.Second), offset)
+	}
+
+	r := s.Report()
+	require.Len(t, r, 2)
+	require.Equal(t, 10*time.Minute, r[0].Window)
+	require.Equal(t, 10, r[0].Intervals)
+	require.Equal(t, 9, r[0].Good)
+	require.InDelta(t, 90, r[0].Compliance, 0.001)
+	require.InDelta(t, 10, r[0].BurnRate, 0.001)
+	require.InDelta(t, -9, r[0].BudgetRemaining, 0.001)
+	require.Equal(t, 60, r[1].Intervals)
+	require.Equal(t, 59, r[1].Good)
+	require.InDelta(t, 98.333, r[1].Compliance, 0.001)
+	require.InDelta(t, 1.667, r[1].BurnRate, 0.001)
+	require.InDelta(t, -0.667, r[1].BudgetRemaining, 0.001)
+
+	m := s.Metrics()
+	require.Equal(t, float64(10), m["ntp.slo.10m.intervals"])
+	require.InDelta(t, 90, m["ntp.slo.10m.compliance"], 0.001)
+	require.InDelta(t, 1.667, m["ntp.slo.1h.burn_rate"], 0.001)
+}
+
+func TestSLOPrune(t *testing.T) {
+	s := NewSLO(&SLOConfig{Bound: time.Millisecond, Target: 99.9, Windows: []time.Duration{time.Hour}})
+	start := time.Unix(1640995200, 0)
+	s.Add(start, 10*time.Millisecond)
+	s.Add(start.Add(2*time.Hour), 0)
+	require.Len(t, s.intervals, 1)
+
+	// late samples outside of the window are ignored
+	s.Add(start, 0)
+	require.Len(t, s.intervals, 1)
+
+	r := s.Report()
+	require.Equal(t, 1, r[0].Good)
+	require.Equal(t, float64(100), r[0].Compliance)
+	require.Equal(t, float64(1), r[0].BudgetRemaining)
+}
+
+func TestSLONoBudget(t *testing.T) {
+	s := NewSLO(&SLOConfig{Bound: time.Millisecond, Target: 100, Windows: []time.Duration{time.Hour}})
+	s.Add(time.Unix(1640995200, 0), 10*time.Millisecond)
+	r := s.Report()
+	require.Equal(t, &SLOWindow{Window: time.Hour, Intervals: 1}, r[0])
+}
+
+func TestSLONoSamples(t *testing.T) {
+	s := NewSLO(&SLOConfig{Target: 99.9, Windows: []time.Duration{time.Hour}})
+	require.Equal(t, []*SLOWindow{{Window: time.Hour}}, s.Report())
+}
+
+func TestWindowName(t *testing.T) {
+	require.Equal(t, "30d", windowName(30*24*time.Hour))
+	require.Equal(t, "25h", windowName(25*time.Hour))
+	require.Equal(t, "5m", windowName(5*time.Minute))
+	require.Equal(t, "30s", windowName(30*time.Second))
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package checker
 
 import (
+	"math"
 	"strconv"
 
 	"github.com/facebook/time/ntp/chrony"
@@ -59,8 +60,9 @@ func sanityCheckSysVars(sysVars *SystemVariables) error {
 	return nil
 }
 
+// NewSystemVariablesFromChrony constructs System from chrony tracking packet
 func NewSystemVariablesFromChrony(p *chrony.ReplyTracking) *SystemVariables {
-	return &SystemVariables{
+	s := &SystemVariables{
 		Leap:      int(p.LeapStatus),
 		Stratum:   int(p.Stratum),
 		RootDelay: secToMS(p.RootDelay),
@@ -69,7 +71,13 @@ func NewSystemVariablesFromChrony(p *chrony.ReplyTracking) *SystemVariables {
 		RefTime:   p.RefTime.String(),
 		Offset:    secToMS(p.RMSOffset),
 		Frequency: p.FreqPPM,
+		ClkWander: p.SkewPPM,
 	}
+	// ntpd reports time constant as log2 of the update interval
+	if p.LastUpdateInterval > 0 {
+		s.TC = int(math.Round(math.Log2(p.LastUpdateInterval)))
+	}
+	return s
 }
 
 // NewSystemVariablesFromNTP constructs System from NTPControlMsg packet
@@ -78,6 +86,11 @@ func NewSystemVariablesFromNTP(p *control.NTPControlMsg) (*SystemVariables, erro
 	if err != nil {
 		return nil, err
 	}
+	return newSystemVariablesFromMap(m)
+}
+
+// newSystemVariablesFromMap constructs System from ntpd system variables
+func newSystemVariablesFromMap(m map[string]string) (*SystemVariables, error) {
 	// data comes as k=v pairs in packet, and those kv pairs are parsed by GetAssociationInfo.
 	// If data is severely corrupted GetAssociationInfo will return error.
 	// It's ok to have some fields missing, thus we don't check for errors below.
@@ -0,0 +1,87 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+)
+
+var (
+	agentOpts     healthFlags
+	agentListen   string
+	agentInterval time.Duration
+	agentHistory  int
+)
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+	agentOpts.register(agentCmd)
+	agentCmd.Flags().StringVar(&agentListen, "listen", "localhost:8089", "address to serve /status, /metrics and /healthz on")
+	agentCmd.Flags().DurationVar(&agentInterval, "interval", time.Minute, "interval of health checks")
+	agentCmd.Flags().IntVar(&agentHistory, "history", checker.DefaultAgentHistory, "number of recent results served on /status")
+}
+
+func agentRun(c *checker.HealthConfig) error {
+	a := checker.NewAgent(c, agentInterval)
+	a.History = agentHistory
+	ln, err := net.Listen("tcp", agentListen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: a.Handler()}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		_ = a.Run(ctx)
+	}()
+	log.Infof("serving host time health on %s", ln.Addr())
+	if err := srv.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Check host time health periodically and serve cached results over HTTP (/status, /metrics, /healthz)",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		c, err := agentOpts.healthConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := agentRun(c); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
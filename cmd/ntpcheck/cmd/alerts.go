@@ -0,0 +1,89 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+)
+
+var (
+	alertsJSON  bool
+	alertsRules string
+)
+
+func printAlerts(alerts []*checker.Alert, jsonOut bool) error {
+	if jsonOut {
+		toPrint, err := json.Marshal(alerts)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(toPrint))
+		return nil
+	}
+	if len(alerts) == 0 {
+		fmt.Printf("%s no alerts\n", okString)
+		return nil
+	}
+	for _, a := range alerts {
+		s := warnString
+		if a.Severity == checker.SeverityCritical {
+			s = failString
+		}
+		fmt.Printf("%s %s: %s\n", s, a.Rule, a.Message)
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(alertsCmd)
+	alertsCmd.Flags().StringVarP(&server, "server", "S", "", "server to connect to")
+	alertsCmd.Flags().BoolVarP(&alertsJSON, "json", "j", false, "JSON output")
+	alertsCmd.Flags().StringVarP(&alertsRules, "rules", "r", "", "yaml file with alert thresholds. Defaults are used if empty")
+}
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "Evaluate alert rules. Exits with 1 on warning and 2 on critical alerts",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		rules := &checker.DefaultRules
+		if alertsRules != "" {
+			var err error
+			rules, err = checker.ReadRules(alertsRules)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		result, err := checker.RunCheck(server)
+		if err != nil {
+			log.Fatal(err)
+		}
+		alerts := rules.Evaluate(result)
+		if err := printAlerts(alerts, alertsJSON); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(int(checker.MaxSeverity(alerts)))
+	},
+}
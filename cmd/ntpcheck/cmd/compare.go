@@ -0,0 +1,85 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+)
+
+var (
+	comparePHC         []string
+	compareNTP         []string
+	compareOscillatord []string
+	compareRoughtime   []string
+	compareInterval    time.Duration
+	compareTimeout     time.Duration
+	compareCount       int
+)
+
+func init() {
+	RootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().StringSliceVar(&comparePHC, "phc", nil, "PHC devices to compare, such as /dev/ptp0")
+	compareCmd.Flags().StringSliceVar(&compareNTP, "ntp", nil, "NTP servers (host:port) to compare")
+	compareCmd.Flags().StringSliceVar(&compareOscillatord, "oscillatord", nil, "oscillatord monitoring addresses (host:port) to record status of")
+	compareCmd.Flags().StringSliceVar(&compareRoughtime, "roughtime", nil, "Roughtime servers to compare as host:port=base64 public key")
+	compareCmd.Flags().DurationVar(&compareInterval, "interval", time.Second, "sampling interval")
+	compareCmd.Flags().DurationVar(&compareTimeout, "timeout", 500*time.Millisecond, "NTP, Roughtime and oscillatord query timeout")
+	compareCmd.Flags().IntVar(&compareCount, "count", 0, "number of records to take. 0 to run until interrupted")
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Sample system clock, PHC, NTP and oscillatord simultaneously and print correlated records as JSON lines",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		sources := []checker.ClockSource{}
+		for _, d := range comparePHC {
+			sources = append(sources, &checker.PHCSource{Device: d})
+		}
+		for _, a := range compareNTP {
+			sources = append(sources, &checker.NTPSource{Address: a, Timeout: compareTimeout})
+		}
+		for _, a := range compareOscillatord {
+			sources = append(sources, &checker.OscillatordSource{Address: a, Timeout: compareTimeout})
+		}
+		for _, a := range compareRoughtime {
+			server, err := parseRoughtimeServer(a)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sources = append(sources, &checker.RoughtimeSource{Server: server, Timeout: compareTimeout})
+		}
+		if len(sources) == 0 {
+			log.Fatal("at least one of --phc, --ntp, --roughtime or --oscillatord must be specified")
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		if err := checker.Compare(ctx, sources, compareInterval, compareCount, os.Stdout); err != nil && ctx.Err() == nil {
+			log.Fatal(err)
+		}
+	},
+}
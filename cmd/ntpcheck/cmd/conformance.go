@@ -0,0 +1,74 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/ntp/conformance"
+)
+
+var (
+	conformanceServer   string
+	conformancePort     int
+	conformanceJSON     bool
+	conformanceMinScore float64
+	conformanceConfig   = conformance.DefaultConfig
+)
+
+func init() {
+	utilsCmd.AddCommand(conformanceCmd)
+	conformanceCmd.Flags().StringVarP(&conformanceServer, "server", "s", "", "Server to test")
+	conformanceCmd.Flags().IntVarP(&conformancePort, "port", "p", 123, "Port of the remote server")
+	conformanceCmd.Flags().BoolVarP(&conformanceJSON, "json", "j", false, "JSON output")
+	conformanceCmd.Flags().Float64Var(&conformanceMinScore, "min-score", 100, "Min score in percent to exit with 0")
+	conformanceCmd.Flags().DurationVar(&conformanceConfig.Timeout, "timeout", conformanceConfig.Timeout, "Timeout for every response")
+	conformanceCmd.Flags().DurationVar(&conformanceConfig.MaxOffset, "max-offset", conformanceConfig.MaxOffset, "Max offset to local clock considered sane")
+	conformanceCmd.Flags().IntVar(&conformanceConfig.BurstSize, "burst", conformanceConfig.BurstSize, "Number of requests sent at once to check rate limiting. 0 to skip")
+}
+
+var conformanceCmd = &cobra.Command{
+	Use:   "conformance",
+	Short: "Run protocol conformance checks against the server. Exits with 1 if score is below min",
+	Long:  "'conformance' sends crafted queries verifying version handling, Kiss-o'-Death, timestamp sanity and rate limiting and prints a scored report",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		if conformanceServer == "" {
+			log.Fatal("server must be specified")
+		}
+		r := conformance.Run(net.JoinHostPort(conformanceServer, strconv.Itoa(conformancePort)), &conformanceConfig)
+		if conformanceJSON {
+			toPrint, err := json.Marshal(r)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(toPrint))
+		} else if err := r.WriteText(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		if r.Score < conformanceMinScore {
+			os.Exit(1)
+		}
+	},
+}
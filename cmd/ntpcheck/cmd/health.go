@@ -0,0 +1,116 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+)
+
+var (
+	healthJSON bool
+	healthOpts healthFlags
+)
+
+func printHealth(h *checker.HostHealth, jsonOut bool) error {
+	if jsonOut {
+		toPrint, err := json.Marshal(h)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(toPrint))
+		return nil
+	}
+	for _, c := range h.Components {
+		s := okString
+		if c.Skipped {
+			s = warnString
+		} else if c.Severity == checker.SeverityWarning {
+			s = warnString
+		} else if c.Severity == checker.SeverityCritical {
+			s = failString
+		}
+		fmt.Printf("%s %s: %s\n", s, c.Name, c.Message)
+	}
+	fmt.Printf("host time health: %s\n", h.Verdict)
+	return nil
+}
+
+// healthFlags are flags selecting sync paths to check, shared by health and agent commands
+type healthFlags struct {
+	noNTP  bool
+	rules  string
+	config checker.HealthConfig
+}
+
+func (f *healthFlags) register(cmd *cobra.Command) {
+	f.config = checker.DefaultHealthConfig
+	cmd.Flags().StringVarP(&f.config.NTPServer, "server", "S", "", "NTP server to connect to")
+	cmd.Flags().BoolVar(&f.noNTP, "no-ntp", false, "skip NTP check, for hosts synced by PTP only")
+	cmd.Flags().StringVarP(&f.rules, "rules", "r", "", "yaml file with NTP alert thresholds. Defaults are used if empty")
+	cmd.Flags().StringVar(&f.config.PTP4LSocket, "ptp4l", "", "ptp4l management socket, such as /var/run/ptp4l. Skipped if empty")
+	cmd.Flags().Float64Var(&f.config.PTPOffset.Warning, "ptp-offset-warning", f.config.PTPOffset.Warning, "ptp4l offset from master warning threshold in ns. 0 to skip")
+	cmd.Flags().Float64Var(&f.config.PTPOffset.Critical, "ptp-offset-critical", f.config.PTPOffset.Critical, "ptp4l offset from master critical threshold in ns. 0 to skip")
+	cmd.Flags().StringVar(&f.config.PHCDevice, "phc", "", "PHC device synced to system clock by phc2sys, such as /dev/ptp0. Skipped if empty")
+	cmd.Flags().Float64Var(&f.config.PHCOffset.Warning, "phc-offset-warning", f.config.PHCOffset.Warning, "PHC to system clock offset warning threshold in ns. 0 to skip")
+	cmd.Flags().Float64Var(&f.config.PHCOffset.Critical, "phc-offset-critical", f.config.PHCOffset.Critical, "PHC to system clock offset critical threshold in ns. 0 to skip")
+}
+
+// healthConfig returns config with NTP rules applied
+func (f *healthFlags) healthConfig() (*checker.HealthConfig, error) {
+	c := f.config
+	if f.noNTP {
+		c.NTPRules = nil
+	} else if f.rules != "" {
+		rules, err := checker.ReadRules(f.rules)
+		if err != nil {
+			return nil, err
+		}
+		c.NTPRules = rules
+	}
+	return &c, nil
+}
+
+func init() {
+	RootCmd.AddCommand(healthCmd)
+	healthOpts.register(healthCmd)
+	healthCmd.Flags().BoolVarP(&healthJSON, "json", "j", false, "JSON output")
+}
+
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Unified host time health over NTP and PTP (ptp4l, phc2sys). Exits with 1 on warning and 2 on critical",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		c, err := healthOpts.healthConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		h := checker.Health(c)
+		if err := printHealth(h, healthJSON); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(int(h.Severity))
+	},
+}
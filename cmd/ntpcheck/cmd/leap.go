@@ -0,0 +1,63 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/leapsched"
+)
+
+var leapFile string
+
+func init() {
+	utilsCmd.AddCommand(armLeapCmd)
+	armLeapCmd.Flags().StringVarP(&leapFile, "srcfile", "s", "/usr/share/zoneinfo/right/UTC", "Source file of leap seconds")
+	utilsCmd.AddCommand(disarmLeapCmd)
+}
+
+var armLeapCmd = &cobra.Command{
+	Use:   "armleap",
+	Short: "Arm kernel leap second flag for the upcoming leap second",
+	Long: `'armleap' sets STA_INS or STA_DEL via adjtimex(2) for the next leap second from srcfile.
+It refuses to arm if the leap second is more than 24 hours away.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		l, err := leapsched.NewScheduler(leapFile).Arm()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Armed leap second %s at %s\n", l.Action, l.Time)
+	},
+}
+
+var disarmLeapCmd = &cobra.Command{
+	Use:   "disarmleap",
+	Short: "Clear kernel leap second flags",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		if err := leapsched.NewScheduler("").Disarm(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Disarmed leap second")
+	},
+}
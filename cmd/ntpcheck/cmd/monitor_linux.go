@@ -0,0 +1,98 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/ntp/capture"
+)
+
+var (
+	monitorIface    string
+	monitorPort     int
+	monitorInterval time.Duration
+)
+
+func init() {
+	utilsCmd.AddCommand(monitorCmd)
+	monitorCmd.Flags().StringVarP(&monitorIface, "iface", "i", "", "Interface to observe NTP traffic on")
+	monitorCmd.Flags().IntVarP(&monitorPort, "port", "p", 123, "NTP port")
+	monitorCmd.Flags().DurationVar(&monitorInterval, "interval", 10*time.Second, "Interval between printing stats")
+}
+
+func printMonitorStats(m *capture.Monitor) {
+	fmt.Printf("%-40s %-40s %3s %3s %10s %10s %10s %14s %14s\n", "client", "server", "ver", "st", "requests", "responses", "unanswered", "offset", "delay")
+	for _, s := range m.Stats() {
+		fmt.Printf("%-40s %-40s %3d %3d %10d %10d %10d %14v %14v\n", s.Client, s.Server, s.Version, s.Stratum, s.Requests, s.Responses, s.Unanswered, s.Offset, s.Delay)
+	}
+	if dropped := m.Dropped(); dropped > 0 {
+		fmt.Printf("%d client/server pair(s) dropped over the limit or as idle\n", dropped)
+	}
+}
+
+func monitorRun(iface string, port int, interval time.Duration) error {
+	handle, err := pcapgo.NewEthernetHandle(iface)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", iface, err)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	m := capture.NewMonitor(port)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// unblock the read
+				handle.Close()
+				return
+			case <-ticker.C:
+				printMonitorStats(m)
+			}
+		}
+	}()
+	err = m.Run(ctx, handle, layers.LinkTypeEthernet)
+	printMonitorStats(m)
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Passively observe NTP traffic and print per client/server stats",
+	Long:  "'monitor' captures NTP packets on the interface over AF_PACKET socket without sending anything, to audit which clients use which servers. Requires CAP_NET_RAW",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		if err := monitorRun(monitorIface, monitorPort, monitorInterval); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
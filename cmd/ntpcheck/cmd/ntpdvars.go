@@ -0,0 +1,73 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+)
+
+var ntpdVarsJSON bool
+
+func printNTPDVariables(r *checker.NTPCheckResult, jsonOut bool) error {
+	vars := r.NTPDVariables()
+	if jsonOut {
+		toPrint, err := json.Marshal(vars)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(toPrint))
+		return nil
+	}
+	ids := make([]int, 0, len(vars))
+	for id := range vars {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		fmt.Printf("associd=%d %s\n", id, checker.FormatNTPDVariables(vars[uint16(id)]))
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(ntpdVarsCmd)
+	ntpdVarsCmd.Flags().StringVarP(&server, "server", "S", "", "server to connect to")
+	ntpdVarsCmd.Flags().BoolVarP(&ntpdVarsJSON, "json", "j", false, "JSON output")
+}
+
+var ntpdVarsCmd = &cobra.Command{
+	Use:   "ntpdvars",
+	Short: "Print system and peer variables with ntpd names, for both ntpd and chrony",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		result, err := checker.RunNTPData(server)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := printNTPDVariables(result, ntpdVarsJSON); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
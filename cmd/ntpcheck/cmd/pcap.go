@@ -0,0 +1,62 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/ntp/capture"
+)
+
+var (
+	pcapFile string
+	pcapPort int
+)
+
+func init() {
+	utilsCmd.AddCommand(pcapCmd)
+	pcapCmd.Flags().StringVarP(&pcapFile, "file", "f", "", "pcap or pcapng file to read")
+	pcapCmd.Flags().IntVarP(&pcapPort, "port", "p", 123, "NTP port")
+	if err := pcapCmd.MarkFlagRequired("file"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+var pcapCmd = &cobra.Command{
+	Use:   "pcap",
+	Short: "Pair NTP requests with responses from a capture and print offsets and delays",
+	Long:  "'pcap' computes offsets and delays from capture timestamps, so they are relative to the clock of the host capture was taken on",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		packets, err := capture.ReadFile(pcapFile, pcapPort)
+		if err != nil {
+			log.Fatal(err)
+		}
+		exchanges, unanswered := capture.Pair(packets)
+		for _, e := range exchanges {
+			fmt.Printf("%s %s -> %s: offset %v, delay %v, server time %v, stratum %d\n",
+				e.Request.Time.UTC().Format("15:04:05.000000000"), e.Request.Src, e.Request.Dst, e.Offset, e.Delay, e.ServerTime, e.Response.NTP.Stratum)
+		}
+		for _, p := range unanswered {
+			fmt.Printf("%s %s -> %s: no response\n", p.Time.UTC().Format("15:04:05.000000000"), p.Src, p.Dst)
+		}
+		fmt.Printf("%d NTP packets, %d exchanges, %d unanswered requests\n", len(packets), len(exchanges), len(unanswered))
+	},
+}
@@ -0,0 +1,88 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+)
+
+var (
+	preflightJSON   bool
+	preflightConfig = checker.DefaultPreflightConfig
+)
+
+func printPreflight(r *checker.PreflightResult, jsonOut bool) error {
+	if jsonOut {
+		toPrint, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(toPrint))
+		return nil
+	}
+	for _, c := range r.Checks {
+		s := okString
+		if c.Skipped {
+			s = warnString
+		} else if !c.Passed {
+			s = failString
+		}
+		fmt.Printf("%s %s: %s\n", s, c.Name, c.Message)
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(preflightCmd)
+	preflightCmd.Flags().StringVarP(&server, "server", "S", "", "server to connect to")
+	preflightCmd.Flags().BoolVarP(&preflightJSON, "json", "j", false, "JSON output")
+	preflightCmd.Flags().Float64Var(&preflightConfig.MaxOffset, "max-offset", preflightConfig.MaxOffset, "max system offset in ms. 0 to skip")
+	preflightCmd.Flags().Float64Var(&preflightConfig.MaxRootDistance, "max-root-distance", preflightConfig.MaxRootDistance, "max system root distance in ms. 0 to skip")
+	preflightCmd.Flags().Float64Var(&preflightConfig.MaxPeerOffset, "max-peer-offset", preflightConfig.MaxPeerOffset, "max offset of good peers in ms. 0 to skip")
+	preflightCmd.Flags().IntVar(&preflightConfig.MinGoodPeers, "min-peers", preflightConfig.MinGoodPeers, "min number of good peers")
+	preflightCmd.Flags().StringVar(&preflightConfig.PHCDevice, "phc", preflightConfig.PHCDevice, "PHC device to check, such as /dev/ptp0. Skipped if empty")
+	preflightCmd.Flags().DurationVar(&preflightConfig.MaxPHCOffset, "max-phc-offset", preflightConfig.MaxPHCOffset, "max offset between PHC and system clock. 0 to skip")
+	preflightCmd.Flags().StringVar(&preflightConfig.LeapFile, "leapfile", preflightConfig.LeapFile, "leap seconds file to check. Skipped if empty")
+	preflightCmd.Flags().DurationVar(&preflightConfig.MaxLeapFileAge, "max-leapfile-age", preflightConfig.MaxLeapFileAge, "max age of the leap seconds file without expiration date. 0 to skip")
+}
+
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check if this host can serve time. Exits with non-zero code if it can't",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		result, err := checker.RunCheck(server)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r := checker.Preflight(result, &preflightConfig)
+		if err := printPreflight(r, preflightJSON); err != nil {
+			log.Fatal(err)
+		}
+		if !r.Passed {
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,103 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/ntp/rawprobe"
+)
+
+var (
+	rawprobeIface    string
+	rawprobeServer   string
+	rawprobePort     int
+	rawprobeVLAN     uint16
+	rawprobePriority uint8
+	rawprobeDstMAC   string
+	rawprobeSrcMAC   string
+	rawprobeSrcIP    string
+	rawprobeRequests int
+	rawprobeTimeout  time.Duration
+)
+
+func init() {
+	utilsCmd.AddCommand(rawprobeCmd)
+	rawprobeCmd.Flags().StringVarP(&rawprobeIface, "iface", "i", "", "Interface to send raw frames from")
+	rawprobeCmd.Flags().StringVarP(&rawprobeServer, "server", "s", "", "IP of the server to query")
+	rawprobeCmd.Flags().IntVarP(&rawprobePort, "port", "p", 123, "Port of the server")
+	rawprobeCmd.Flags().Uint16Var(&rawprobeVLAN, "vlan", 0, "VLAN ID to tag requests with. Untagged if 0")
+	rawprobeCmd.Flags().Uint8Var(&rawprobePriority, "priority", 0, "802.1p priority of tagged requests")
+	rawprobeCmd.Flags().StringVar(&rawprobeDstMAC, "dstmac", "", "MAC of the next hop: the server or the router towards it")
+	rawprobeCmd.Flags().StringVar(&rawprobeSrcMAC, "srcmac", "", "Source MAC. Defaults to the MAC of the interface")
+	rawprobeCmd.Flags().StringVar(&rawprobeSrcIP, "srcip", "", "Source IP. Defaults to the address of the interface")
+	rawprobeCmd.Flags().IntVarP(&rawprobeRequests, "requests", "r", 3, "How many requests to send")
+	rawprobeCmd.Flags().DurationVar(&rawprobeTimeout, "timeout", time.Second, "Timeout of each request")
+}
+
+// rawprobeConfig builds probe config from the flags
+func rawprobeConfig() (*rawprobe.Config, error) {
+	c := &rawprobe.Config{
+		Iface:    rawprobeIface,
+		VLAN:     rawprobeVLAN,
+		Priority: rawprobePriority,
+		Server:   net.ParseIP(rawprobeServer),
+		Port:     rawprobePort,
+		Timeout:  rawprobeTimeout,
+	}
+	var err error
+	if c.DstMAC, err = net.ParseMAC(rawprobeDstMAC); err != nil {
+		return nil, err
+	}
+	if rawprobeSrcMAC != "" {
+		if c.SrcMAC, err = net.ParseMAC(rawprobeSrcMAC); err != nil {
+			return nil, err
+		}
+	}
+	if rawprobeSrcIP != "" {
+		if c.SrcIP = net.ParseIP(rawprobeSrcIP); c.SrcIP == nil {
+			return nil, fmt.Errorf("bad source IP %q", rawprobeSrcIP)
+		}
+	}
+	return c, c.Validate()
+}
+
+var rawprobeCmd = &cobra.Command{
+	Use:   "rawprobe",
+	Short: "Query NTP server with raw frames over specific VLAN and next hop MAC",
+	Long:  "'rawprobe' crafts Ethernet, optional 802.1Q tag, IP and UDP headers itself and sends them over AF_PACKET socket with hardware timestamps, probing through trunk ports. Requires CAP_NET_RAW",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		c, err := rawprobeConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i := 0; i < rawprobeRequests; i++ {
+			r, err := rawprobe.Query(c)
+			if err != nil {
+				log.Errorf("request %d: %v", i, err)
+				continue
+			}
+			fmt.Printf("%s vlan %d: offset %v, delay %v, stratum %d, %s timestamps\n", c.Server, c.VLAN, r.Offset, r.Delay, r.Response.Stratum, r.Timestamping)
+		}
+	},
+}
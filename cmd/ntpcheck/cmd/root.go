@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/facebook/time/cliconfig"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -28,13 +29,20 @@ import (
 var RootCmd = &cobra.Command{
 	Use:   "ntpcheck",
 	Short: "Swiss Army Knife for NTP",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return flagConfig.Apply(cliconfig.PFlags(cmd.Flags()))
+	},
 }
 
+// flagConfig reads flags not set on the command line from NTPCHECK_<FLAG> environment variables and the flag file
+var flagConfig = cliconfig.Config{EnvPrefix: "NTPCHECK"}
+
 var verbose bool
 var server string
 
 func init() {
 	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	RootCmd.PersistentFlags().StringVar(&flagConfig.File, cliconfig.FlagFile, "", "Yaml file with flag values. Flags are also read from NTPCHECK_<FLAG> environment variables")
 }
 
 // ConfigureVerbosity configures log verbosity based on parsed flags. Needs to be called by any subcommand.
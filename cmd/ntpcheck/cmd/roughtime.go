@@ -0,0 +1,94 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+	"github.com/facebook/time/ntp/roughtime"
+)
+
+var (
+	roughtimeServer    string
+	roughtimePublicKey string
+	roughtimeNTP       string
+	roughtimeTimeout   time.Duration
+)
+
+// parseRoughtimeServer parses host:port=base64 public key
+func parseRoughtimeServer(s string) (*roughtime.Server, error) {
+	// base64 key may end with = padding, address can't have it
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return nil, fmt.Errorf("roughtime server %q must be host:port=public key", s)
+	}
+	key, err := roughtime.ParsePublicKey(s[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	return &roughtime.Server{Address: s[:i], PublicKey: key}, nil
+}
+
+func init() {
+	utilsCmd.AddCommand(roughtimeCmd)
+	roughtimeCmd.Flags().StringVarP(&roughtimeServer, "server", "s", "", "Roughtime server to query (host:port)")
+	roughtimeCmd.Flags().StringVarP(&roughtimePublicKey, "pubkey", "k", "", "Base64 Ed25519 public key of the server")
+	roughtimeCmd.Flags().StringVar(&roughtimeNTP, "ntp", "", "NTP server (host:port) to verify against the Roughtime answer")
+	roughtimeCmd.Flags().DurationVar(&roughtimeTimeout, "timeout", time.Second, "Query timeout")
+}
+
+var roughtimeCmd = &cobra.Command{
+	Use:   "roughtime",
+	Short: "Query Roughtime server. Exits with 1 if NTP answer is outside of the signed Roughtime radius",
+	Long:  "'roughtime' verifies signed time of Roughtime server and optionally checks integrity of NTP server answers against it",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		if roughtimeServer == "" || roughtimePublicKey == "" {
+			log.Fatal("server and public key must be specified")
+		}
+		key, err := roughtime.ParsePublicKey(roughtimePublicKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r, err := roughtime.Query(&roughtime.Server{Address: roughtimeServer, PublicKey: key}, roughtimeTimeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("roughtime: midpoint %s ± %v, offset %v, delay %v\n", r.Midpoint.UTC().Format(time.RFC3339Nano), r.Radius, r.Offset, r.Delay)
+		if roughtimeNTP == "" {
+			return
+		}
+		sample := (&checker.NTPSource{Address: roughtimeNTP, Timeout: roughtimeTimeout}).Sample()
+		if sample.Error != "" {
+			log.Fatal(sample.Error)
+		}
+		offset, delay := time.Duration(*sample.OffsetNS), time.Duration(sample.DelayNS)
+		fmt.Printf("ntp: offset %v, delay %v\n", offset, delay)
+		if !r.Consistent(offset, delay) {
+			fmt.Printf("%s NTP offset differs from Roughtime by %v\n", failString, offset-r.Offset)
+			os.Exit(1)
+		}
+		fmt.Printf("%s NTP agrees with Roughtime\n", okString)
+	},
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/rtc"
+)
+
+var (
+	rtcDevice    string
+	rtcMaxOffset time.Duration
+	rtcSync      bool
+	rtcWakeAlarm string
+)
+
+func init() {
+	utilsCmd.AddCommand(rtcCmd)
+	rtcCmd.Flags().StringVarP(&rtcDevice, "device", "d", rtc.DefaultDevice, "RTC device")
+	rtcCmd.Flags().DurationVar(&rtcMaxOffset, "max-offset", 2*time.Second, "Maximum allowed offset between RTC and system clock")
+	rtcCmd.Flags().BoolVar(&rtcSync, "sync", false, "Set RTC from the system clock if offset exceeds max-offset")
+	rtcCmd.Flags().StringVar(&rtcWakeAlarm, "wakealarm", "", "Set wake alarm to this RFC3339 time, 'off' disables it")
+}
+
+func rtcRun() error {
+	r, err := rtc.Open(rtcDevice)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if rtcWakeAlarm != "" {
+		enabled := rtcWakeAlarm != "off"
+		var t time.Time
+		if enabled {
+			if t, err = time.Parse(time.RFC3339, rtcWakeAlarm); err != nil {
+				return err
+			}
+		}
+		if err := r.SetWakeAlarm(t, enabled); err != nil {
+			return err
+		}
+	}
+
+	t, err := r.Time()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("RTC time:    %s\n", t)
+	if a, err := r.WakeAlarm(); err != nil {
+		fmt.Printf("Wake alarm:  %v\n", err)
+	} else {
+		fmt.Printf("Wake alarm:  %s (enabled: %v, pending: %v)\n", a.Time, a.Enabled, a.Pending)
+	}
+
+	if rtcSync {
+		offset, set, err := r.Sync(rtcMaxOffset)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Offset:      %v\n", offset)
+		if set {
+			fmt.Println("RTC is set from the system clock")
+		}
+		return nil
+	}
+
+	offset, err := r.Offset()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Offset:      %v\n", offset)
+	if offset > rtcMaxOffset || offset < -rtcMaxOffset {
+		return fmt.Errorf("RTC offset %v exceeds %v", offset, rtcMaxOffset)
+	}
+	return nil
+}
+
+var rtcCmd = &cobra.Command{
+	Use:   "rtc",
+	Short: "Validate and correct the hardware clock (RTC)",
+	Long: `'rtc' reads RTC time and wake alarm and compares RTC with the system clock.
+It fails if they are more than max-offset apart, unless --sync is set in which case RTC is set from the system clock.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		if err := rtcRun(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,75 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+)
+
+var (
+	sanityJSON    bool
+	sanityMinTime string
+	sanityConfig  = checker.DefaultSanityConfig
+)
+
+func init() {
+	RootCmd.AddCommand(sanityCmd)
+	sanityCmd.Flags().BoolVarP(&sanityJSON, "json", "j", false, "JSON output")
+	sanityCmd.Flags().StringVar(&sanityMinTime, "min-time", "", fmt.Sprintf("earliest plausible time in RFC3339. Build time %s if empty", checker.BuildTime))
+	sanityCmd.Flags().DurationVar(&sanityConfig.MaxFuture, "max-future", sanityConfig.MaxFuture, "how far past min time the clock can be. 0 to skip")
+	sanityCmd.Flags().StringVar(&sanityConfig.RTCDevice, "rtc", sanityConfig.RTCDevice, "RTC device to check. Skipped if empty")
+	sanityCmd.Flags().DurationVar(&sanityConfig.MaxRTCOffset, "max-rtc-offset", sanityConfig.MaxRTCOffset, "max offset between RTC and system clock. 0 to skip")
+	sanityCmd.Flags().StringSliceVar(&sanityConfig.Servers, "server", sanityConfig.Servers, "NTP servers (host:port) to compare with. Skipped if empty")
+	sanityCmd.Flags().DurationVar(&sanityConfig.MaxNTPOffset, "max-ntp-offset", sanityConfig.MaxNTPOffset, "max offset of a server agreeing with the system clock. 0 to skip")
+	sanityCmd.Flags().IntVar(&sanityConfig.MinServers, "min-servers", sanityConfig.MinServers, "min number of servers agreeing with the system clock")
+	sanityCmd.Flags().DurationVar(&sanityConfig.Timeout, "timeout", sanityConfig.Timeout, "timeout of NTP queries")
+}
+
+var sanityCmd = &cobra.Command{
+	Use:   "sanity",
+	Short: "Check at startup that the clock is not wildly off. Exits with non-zero code if it is",
+	Long: `'sanity' compares the system clock with build time, RTC and NTP servers.
+Use it before marking a freshly booted host healthy, so a clock far in the past or future doesn't break TLS.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		if sanityMinTime != "" {
+			t, err := time.Parse(time.RFC3339, sanityMinTime)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sanityConfig.MinTime = t
+		}
+		r, err := checker.Sanity(&sanityConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := printPreflight(r, sanityJSON); err != nil {
+			log.Fatal(err)
+		}
+		if !r.Passed {
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,289 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+	"github.com/facebook/time/ntp/control"
+)
+
+var shellDaemon string
+
+// tally codes of peer selection, same as ntpq shows
+var selectionTally = [8]string{" ", "x", ".", "-", "+", "#", "*", "o"}
+
+const shellHelp = `Commands:
+  peers              print peers
+  associations, as   print associations
+  readvar, rv [id]   print system variables, or peer variables of association id
+  host [address]     print or change the host to query
+  daemon [name]      print or change the daemon protocol: ntpd, chrony or empty to detect
+  help               print this help
+  quit, exit         leave the shell`
+
+// shell is an interactive ntpq-like shell
+type shell struct {
+	host   string
+	daemon string
+	out    io.Writer
+	run    func(address, daemon string) (*checker.NTPCheckResult, error)
+}
+
+// address returns host with the default port of the daemon if port is missing
+func (s *shell) address() string {
+	if s.host == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(s.host); err == nil {
+		return s.host
+	}
+	port := "123"
+	if s.daemon == checker.DaemonChrony {
+		port = "323"
+	}
+	return net.JoinHostPort(strings.Trim(s.host, "[]"), port)
+}
+
+// sortedPeers returns peers sorted by association ID
+func sortedPeers(r *checker.NTPCheckResult) ([]uint16, []*checker.Peer) {
+	ids := make([]int, 0, len(r.Peers))
+	for id := range r.Peers {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	assocs := make([]uint16, 0, len(ids))
+	peers := make([]*checker.Peer, 0, len(ids))
+	for _, id := range ids {
+		assocs = append(assocs, uint16(id))
+		peers = append(peers, r.Peers[uint16(id)])
+	}
+	return assocs, peers
+}
+
+// peerColor highlights peers by selection
+func peerColor(p *checker.Peer) func(format string, a ...interface{}) string {
+	switch p.Selection {
+	case control.SelSYSPeer, control.SelPPSPeer:
+		return color.GreenString
+	case control.SelCandidate, control.SelBackup:
+		return fmt.Sprintf
+	case control.SelOutlier:
+		return color.YellowString
+	}
+	return color.RedString
+}
+
+// table formats rows with tabwriter and colors every row but the header
+func table(out io.Writer, header string, rows []string, colors []func(string, ...interface{}) string) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tw, header)
+	for _, r := range rows {
+		fmt.Fprintln(tw, r)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	width := 0
+	for _, l := range lines {
+		if len(l) > width {
+			width = len(l)
+		}
+	}
+	fmt.Fprintln(out, lines[0])
+	fmt.Fprintln(out, strings.Repeat("=", width))
+	for i, l := range lines[1:] {
+		fmt.Fprintln(out, colors[i]("%s", l))
+	}
+	return nil
+}
+
+func pollSeconds(poll int) string {
+	if poll <= 0 || poll > 31 {
+		return "-"
+	}
+	return strconv.Itoa(1 << poll)
+}
+
+func peerType(hmode int) string {
+	switch hmode {
+	case 1, 2:
+		return "s"
+	case 3:
+		return "u"
+	case 5:
+		return "b"
+	}
+	return "-"
+}
+
+func (s *shell) peers(r *checker.NTPCheckResult) error {
+	_, peers := sortedPeers(r)
+	rows := make([]string, 0, len(peers))
+	colors := make([]func(string, ...interface{}) string, 0, len(peers))
+	for _, p := range peers {
+		tally := " "
+		if int(p.Selection) < len(selectionTally) {
+			tally = selectionTally[p.Selection]
+		}
+		rows = append(rows, fmt.Sprintf("%s%s\t%s\t%d\t%s\t%s\t%o\t%.3f\t%.3f\t%.3f",
+			tally, p.SRCAdr, p.RefID, p.Stratum, peerType(p.HMode), pollSeconds(p.HPoll), p.Reach, p.Delay, p.Offset, p.Jitter))
+		colors = append(colors, peerColor(p))
+	}
+	return table(s.out, " remote\trefid\tst\tt\tpoll\treach\tdelay\toffset\tjitter", rows, colors)
+}
+
+func (s *shell) associations(r *checker.NTPCheckResult) error {
+	assocs, peers := sortedPeers(r)
+	rows := make([]string, 0, len(peers))
+	colors := make([]func(string, ...interface{}) string, 0, len(peers))
+	yesNo := map[bool]string{true: "yes", false: "no"}
+	for i, p := range peers {
+		auth := "none"
+		if p.AuthPossible {
+			auth = yesNo[p.Authentic]
+		}
+		rows = append(rows, fmt.Sprintf("%d\t%d\t%04x\t%s\t%s\t%s\t%s",
+			i+1, assocs[i], p.PeerStatusWord().Word(), yesNo[p.Configured], yesNo[p.Reachable], auth, p.Condition))
+		colors = append(colors, peerColor(p))
+	}
+	return table(s.out, "ind\tassid\tstatus\tconf\treach\tauth\tcondition", rows, colors)
+}
+
+func (s *shell) readvar(r *checker.NTPCheckResult, args []string) error {
+	id := 0
+	if len(args) > 0 {
+		var err error
+		id, err = strconv.Atoi(args[0])
+		if err != nil || id < 0 {
+			return fmt.Errorf("invalid association id %q", args[0])
+		}
+	}
+	vars, ok := r.NTPDVariables()[uint16(id)]
+	if !ok {
+		return fmt.Errorf("no association %d", id)
+	}
+	fmt.Fprintf(s.out, "associd=%d %s\n", id, checker.FormatNTPDVariables(vars))
+	return nil
+}
+
+// exec runs a single command. It returns false when the shell should exit
+func (s *shell) exec(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true, nil
+	}
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "quit", "exit":
+		return false, nil
+	case "help", "?":
+		fmt.Fprintln(s.out, shellHelp)
+		return true, nil
+	case "host":
+		if len(args) > 0 {
+			s.host = args[0]
+		}
+		fmt.Fprintf(s.out, "current host is %q\n", s.address())
+		return true, nil
+	case "daemon":
+		if len(args) > 0 {
+			if args[0] != checker.DaemonNTPD && args[0] != checker.DaemonChrony && args[0] != "auto" {
+				return true, fmt.Errorf("unsupported daemon %q", args[0])
+			}
+			s.daemon = strings.TrimPrefix(args[0], "auto")
+		}
+		fmt.Fprintf(s.out, "current daemon is %q\n", s.daemon)
+		return true, nil
+	case "peers", "associations", "as", "readvar", "rv":
+	default:
+		return true, fmt.Errorf("unknown command %q, type 'help' for the list", cmd)
+	}
+
+	r, err := s.run(s.address(), s.daemon)
+	if err != nil {
+		return true, err
+	}
+	switch cmd {
+	case "peers":
+		return true, s.peers(r)
+	case "associations", "as":
+		return true, s.associations(r)
+	}
+	return true, s.readvar(r, args)
+}
+
+// loop reads commands from in until EOF or quit
+func (s *shell) loop(in io.Reader, prompt bool) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		if prompt {
+			fmt.Fprint(s.out, "ntpcheck> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		more, err := s.exec(scanner.Text())
+		if err != nil {
+			fmt.Fprintf(s.out, "%s %v\n", failString, err)
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(shellCmd)
+	shellCmd.Flags().StringVarP(&server, "server", "S", "", "host to query, local daemon if empty")
+	shellCmd.Flags().StringVarP(&shellDaemon, "daemon", "d", "", "daemon protocol: ntpd or chrony. Detected for the local daemon if empty")
+}
+
+var shellCmd = &cobra.Command{
+	Use:   "shell [command]...",
+	Short: "Interactive ntpq-like shell for both ntpd and chrony. Runs commands from arguments if given",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		s := &shell{host: server, daemon: shellDaemon, out: os.Stdout, run: checker.RunCheckDaemon}
+		if len(args) > 0 {
+			if _, err := s.exec(strings.Join(args, " ")); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		if err := s.loop(os.Stdin, true); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -0,0 +1,128 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+	"github.com/facebook/time/ntp/control"
+	"github.com/stretchr/testify/require"
+)
+
+func shellResult() *checker.NTPCheckResult {
+	return &checker.NTPCheckResult{
+		SysVars: &checker.SystemVariables{Stratum: 2, RefID: "192.0.2.1"},
+		Peers: map[uint16]*checker.Peer{
+			5: {Selection: control.SelCandidate, Condition: "candidate", SRCAdr: "192.0.2.2", RefID: "GPS", Stratum: 1, HMode: 3, HPoll: 6, Reach: 255, Delay: 0.5, Offset: -0.1, Jitter: 0.01, Configured: true, Reachable: true},
+			3: {Selection: control.SelSYSPeer, Condition: "sys.peer", SRCAdr: "192.0.2.1", RefID: "PPS", Stratum: 1, HMode: 3, HPoll: 6, Reach: 255, Delay: 0.4, Offset: 0.2, Jitter: 0.02, Configured: true, Reachable: true},
+		},
+	}
+}
+
+func newTestShell(out *bytes.Buffer) *shell {
+	return &shell{
+		out: out,
+		run: func(address, daemon string) (*checker.NTPCheckResult, error) {
+			if address == "unreachable:123" {
+				return nil, errors.New("timeout")
+			}
+			return shellResult(), nil
+		},
+	}
+}
+
+func TestShellPeers(t *testing.T) {
+	out := &bytes.Buffer{}
+	s := newTestShell(out)
+	more, err := s.exec("peers")
+	require.NoError(t, err)
+	require.True(t, more)
+	expected := ` remote    refid st t poll reach delay offset jitter
+====================================================
+*192.0.2.1 PPS   1  u 64   377   0.400 0.200  0.020
++192.0.2.2 GPS   1  u 64   377   0.500 -0.100 0.010
+`
+	require.Equal(t, expected, out.String())
+}
+
+func TestShellAssociations(t *testing.T) {
+	out := &bytes.Buffer{}
+	s := newTestShell(out)
+	_, err := s.exec("as")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "ind assid status conf reach auth condition", lines[0])
+	require.Equal(t, "1   3     9600   yes  yes   none sys.peer", lines[2])
+	require.Equal(t, "2   5     9400   yes  yes   none candidate", lines[3])
+}
+
+func TestShellReadvar(t *testing.T) {
+	out := &bytes.Buffer{}
+	s := newTestShell(out)
+	_, err := s.exec("rv")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out.String(), "associd=0 "))
+	require.Contains(t, out.String(), "stratum=2")
+
+	out.Reset()
+	_, err = s.exec("rv 1")
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "srcadr=192.0.2.1")
+
+	_, err = s.exec("rv 7")
+	require.EqualError(t, err, "no association 7")
+	_, err = s.exec("rv x")
+	require.Error(t, err)
+}
+
+func TestShellHostDaemon(t *testing.T) {
+	out := &bytes.Buffer{}
+	s := newTestShell(out)
+	require.Equal(t, "", s.address())
+	_, err := s.exec("host unreachable")
+	require.NoError(t, err)
+	require.Equal(t, "unreachable:123", s.address())
+	_, err = s.exec("peers")
+	require.EqualError(t, err, "timeout")
+
+	_, err = s.exec("daemon chrony")
+	require.NoError(t, err)
+	require.Equal(t, "unreachable:323", s.address())
+	_, err = s.exec("daemon openntpd")
+	require.Error(t, err)
+	_, err = s.exec("daemon auto")
+	require.NoError(t, err)
+	require.Equal(t, "", s.daemon)
+
+	s.host = "::1"
+	require.Equal(t, "[::1]:123", s.address())
+	s.host = "[::1]:1123"
+	require.Equal(t, "[::1]:1123", s.address())
+}
+
+func TestShellLoop(t *testing.T) {
+	out := &bytes.Buffer{}
+	s := newTestShell(out)
+	require.NoError(t, s.loop(strings.NewReader("\nbogus\npeers\nquit\npeers\n"), false))
+	require.Contains(t, out.String(), "unknown command \"bogus\"")
+	require.Equal(t, 1, strings.Count(out.String(), "remote"))
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/calnex/compare"
+	"github.com/facebook/time/cmd/ntpcheck/checker"
+)
+
+var (
+	sloBound    time.Duration
+	sloTarget   float64
+	sloWindows  []time.Duration
+	sloFile     string
+	sloInterval time.Duration
+	sloCount    int
+)
+
+func init() {
+	RootCmd.AddCommand(sloCmd)
+	sloCmd.Flags().StringVarP(&server, "server", "S", "", "server to connect to")
+	sloCmd.Flags().DurationVar(&sloBound, "bound", 100*time.Microsecond, "max absolute offset of a good minute")
+	sloCmd.Flags().Float64Var(&sloTarget, "target", 99.9, "percent of minutes which must be good")
+	sloCmd.Flags().DurationSliceVar(&sloWindows, "window", []time.Duration{time.Hour, 24 * time.Hour, 30 * 24 * time.Hour}, "rolling windows to report")
+	sloCmd.Flags().StringVar(&sloFile, "file", "", "calnex export JSON lines to compute SLO of instead of sampling the server")
+	sloCmd.Flags().DurationVar(&sloInterval, "interval", 10*time.Second, "sampling interval of the server")
+	sloCmd.Flags().IntVar(&sloCount, "count", 0, "number of samples to take. 0 to run until interrupted")
+}
+
+func printSLOMetrics(s *checker.SLO) error {
+	toPrint, err := json.Marshal(s.Metrics())
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(toPrint))
+	return nil
+}
+
+// sloFromFile computes SLO of all samples of calnex export
+func sloFromFile(s *checker.SLO, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := compare.ReadEntries(f)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		s.Add(time.Unix(int64(e.Int.Time), 0), time.Duration(e.Float.Value*float64(time.Second)))
+	}
+	return printSLOMetrics(s)
+}
+
+// sloFromServer samples offset of the server, printing metrics after every sample
+func sloFromServer(s *checker.SLO) error {
+	for i := 0; sloCount == 0 || i < sloCount; i++ {
+		if i > 0 {
+			time.Sleep(sloInterval)
+		}
+		result, err := checker.RunCheck(server)
+		if err != nil {
+			log.Errorf("failed to run check: %v", err)
+			continue
+		}
+		stats, err := checker.NewNTPStats(result)
+		if err != nil {
+			log.Errorf("failed to get stats: %v", err)
+			continue
+		}
+		s.Add(time.Now(), time.Duration(stats.PeerOffset*float64(time.Millisecond)))
+		if err := printSLOMetrics(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var sloCmd = &cobra.Command{
+	Use:   "slo",
+	Short: "Print time sync SLO compliance and error budget burn rates as JSON metrics",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+
+		if sloTarget <= 0 || sloTarget > 100 {
+			log.Fatalf("target must be in (0, 100], got %v", sloTarget)
+		}
+		s := checker.NewSLO(&checker.SLOConfig{Bound: sloBound, Target: sloTarget, Windows: sloWindows})
+		var err error
+		if sloFile != "" {
+			err = sloFromFile(s, sloFile)
+		} else {
+			err = sloFromServer(s)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
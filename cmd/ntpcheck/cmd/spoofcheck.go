@@ -0,0 +1,97 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/ntp/spoof"
+)
+
+var (
+	spoofServer string
+	spoofPort   int
+	spoofJSON   bool
+	spoofConfig = spoof.DefaultConfig
+)
+
+func printSpoofCheck(r *spoof.Result, jsonOut bool) error {
+	if jsonOut {
+		toPrint, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(toPrint))
+		return nil
+	}
+	for _, reply := range r.Replies {
+		if reply.Error != "" {
+			fmt.Printf("port %d: %s\n", reply.LocalPort, reply.Error)
+			continue
+		}
+		fmt.Printf("port %d: from %s, TTL %d (%d hops), stratum %d, refid %#08x, offset %v\n",
+			reply.LocalPort, reply.From, reply.TTL, reply.Hops, reply.Stratum, reply.RefID, reply.Offset)
+	}
+	if !r.Suspicious {
+		fmt.Printf("%s replies are consistent\n", okString)
+		return nil
+	}
+	for _, f := range r.Findings {
+		fmt.Printf("%s %s\n", failString, f)
+	}
+	return nil
+}
+
+func init() {
+	utilsCmd.AddCommand(spoofCheckCmd)
+	spoofCheckCmd.Flags().StringVarP(&spoofServer, "server", "s", "", "Server to query")
+	spoofCheckCmd.Flags().IntVarP(&spoofPort, "port", "p", 123, "Port of the remote server")
+	spoofCheckCmd.Flags().BoolVarP(&spoofJSON, "json", "j", false, "JSON output")
+	spoofCheckCmd.Flags().IntVar(&spoofConfig.Ports[1], "source-port", 0, "Source port of the second query, for example 123 to catch interception of server to server traffic. Ephemeral if 0")
+	spoofCheckCmd.Flags().IntVar(&spoofConfig.MinHops, "min-hops", spoofConfig.MinHops, "Min number of hops expected to the server")
+	spoofCheckCmd.Flags().DurationVar(&spoofConfig.Timeout, "timeout", spoofConfig.Timeout, "Timeout for replies")
+	spoofCheckCmd.Flags().DurationVar(&spoofConfig.MaxOffsetDiff, "max-offset-diff", spoofConfig.MaxOffsetDiff, "Max difference of offsets measured by both queries. 0 to skip")
+}
+
+var spoofCheckCmd = &cobra.Command{
+	Use:   "spoofcheck",
+	Short: "Detect middleboxes intercepting NTP. Exits with 1 if replies look spoofed",
+	Long:  "'spoofcheck' sends the same query from two source ports and compares replies and their TTL",
+	Run: func(cmd *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		if spoofServer == "" {
+			log.Fatal("server must be specified")
+		}
+		r, err := spoof.Check(net.JoinHostPort(spoofServer, strconv.Itoa(spoofPort)), &spoofConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := printSpoofCheck(r, spoofJSON); err != nil {
+			log.Fatal(err)
+		}
+		if r.Suspicious {
+			os.Exit(1)
+		}
+	},
+}
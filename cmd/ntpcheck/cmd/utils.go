@@ -26,9 +26,11 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/facebook/time/dialer"
 	"github.com/facebook/time/leaphash"
 	"github.com/facebook/time/leapsectz"
 	ntp "github.com/facebook/time/ntp/protocol"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -80,24 +82,74 @@ func signFile(fileName string) {
 	fmt.Printf("#h %s\n", leaphash.Compute(string(data)))
 }
 
-// ntpDate prints data similar to 'ntptime' command output
-func ntpDate(remoteServerAddr string, remoteServerPort string, requests int) error {
+// ntpDialer returns dialer based on proxy and relay settings.
+// If both are set the relay is reached via SOCKS5 proxy
+func ntpDialer(socks5, relay string, timeout time.Duration) dialer.Dialer {
+	var d dialer.Dialer = &dialer.Direct{Timeout: timeout}
+	if socks5 != "" {
+		d = &dialer.SOCKS5{Proxy: socks5, Timeout: timeout}
+	}
+	if relay != "" {
+		d = &dialer.TCPRelay{Relay: relay, Timeout: timeout, Forward: d}
+	}
+	return d
+}
+
+// transportDialer connects to the server via experimental NTP transport
+type transportDialer struct {
+	transport ntp.Transport
+	// path overrides address for unix sockets
+	path    string
+	timeout time.Duration
+}
+
+// Dial connects to the server. Network is defined by the transport
+func (d *transportDialer) Dial(network, address string) (net.Conn, error) {
+	if d.path != "" {
+		address = d.path
+	}
+	return d.transport.Dial(address, d.timeout)
+}
+
+// ntpTransportDialer returns dialer for the transport, falling back to ntpDialer for udp
+func ntpTransportDialer(transport, server, socks5, relay string, timeout time.Duration) (dialer.Dialer, error) {
+	if transport == "" || transport == ntp.TransportUDP {
+		return ntpDialer(socks5, relay, timeout), nil
+	}
+	t, err := ntp.NewTransport(transport)
+	if err != nil {
+		return nil, err
+	}
+	d := &transportDialer{transport: t, timeout: timeout}
+	if transport == ntp.TransportUnix {
+		d.path = server
+	}
+	return d, nil
+}
+
+// ntpDate prints data similar to 'ntptime' command output.
+// With randomOrigin transmit timestamp of requests is random and replies with different origin timestamp are discarded
+func ntpDate(remoteServerAddr string, remoteServerPort string, requests int, randomOrigin bool, d dialer.Dialer) error {
 	timeout := 5 * time.Second
 	addr := net.JoinHostPort(remoteServerAddr, remoteServerPort)
-	conn, err := net.DialTimeout("udp", addr, timeout)
+	conn, err := d.Dial("udp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to connect to %s: %w", addr, err)
 	}
 	defer conn.Close()
 
-	// Allow reading of kernel timestamps via socket
-	if err := ntp.EnableKernelTimestampsSocket(conn.(*net.UDPConn)); err != nil {
-		return err
+	// Allow reading of kernel timestamps via socket if we talk to the server directly
+	udpConn, direct := conn.(*net.UDPConn)
+	if direct {
+		if err := ntp.EnableKernelTimestampsSocket(udpConn); err != nil {
+			return err
+		}
 	}
 
 	fmt.Printf("Server: %s, Requests: %d\n", addr, requests)
 	var sumAvgNetworkDelay int64
 	var sumOffset int64
+	transactions := ntp.NewTransactions(timeout)
 
 	for i := 0; i < requests; i++ {
 		clientTransmitTime := time.Now()
@@ -108,6 +160,14 @@ func ntpDate(remoteServerAddr string, remoteServerPort string, requests int) err
 			TxTimeSec:  sec,
 			TxTimeFrac: frac,
 		}
+		if randomOrigin {
+			if err := request.SetRandomTransmitTime(); err != nil {
+				return err
+			}
+		}
+		if _, err := transactions.Start(request, clientTransmitTime); err != nil {
+			return err
+		}
 
 		if err := binary.Write(conn, binary.BigEndian, request); err != nil {
 			return fmt.Errorf("failed to send request, %w", err)
@@ -118,8 +178,21 @@ func ntpDate(remoteServerAddr string, remoteServerPort string, requests int) err
 
 		blockingRead := make(chan bool, 1)
 		go func() {
-			// This calls syscall.Recvmsg which has no timeout
-			response, clientReceiveTime, _, err = ntp.ReadPacketWithKernelTimestamp(conn.(*net.UDPConn))
+			for {
+				if direct {
+					// This calls syscall.Recvmsg which has no timeout
+					response, clientReceiveTime, _, err = ntp.ReadPacketWithKernelTimestamp(udpConn)
+				} else {
+					response, clientReceiveTime, err = ntp.ReadPacketWithTimestamp(conn)
+				}
+				if err != nil || !randomOrigin {
+					break
+				}
+				if _, err = transactions.Match(response, clientReceiveTime); err == nil {
+					break
+				}
+				log.Warningf("Discarding reply: %v", err)
+			}
 			blockingRead <- true
 		}()
 
@@ -155,6 +228,10 @@ func ntpDate(remoteServerAddr string, remoteServerPort string, requests int) err
 
 	fmt.Printf("Average:\n")
 	fmt.Printf("Offset: %fs (%fms), Network delay: %fs (%fms)\n", avgOffset/float64(time.Second.Nanoseconds()), avgOffset/float64(time.Millisecond.Nanoseconds()), avgNetworkDelay/float64(time.Second.Nanoseconds()), avgNetworkDelay/float64(time.Millisecond.Nanoseconds()))
+	if randomOrigin {
+		stats := transactions.Stats()
+		fmt.Printf("Replies: %d, Duplicate: %d, Late: %d, Unsolicited: %d\n", stats.Replies, stats.Duplicates, stats.Late, stats.Unsolicited)
+	}
 	return nil
 }
 
@@ -207,6 +284,10 @@ var signFileName string
 var remoteServerAddr string
 var remoteServerPort int
 var ntpdateRequests int
+var ntpdateSOCKS5 string
+var ntpdateRelay string
+var ntpdateRandomOrigin bool
+var ntpdateTransport string
 var sourceLeapSeconds string
 var destLeapSeconds string
 var offsetMonth int
@@ -227,6 +308,10 @@ func init() {
 	ntpdateCmd.Flags().StringVarP(&remoteServerAddr, "server", "s", "", "Server to query")
 	ntpdateCmd.Flags().IntVarP(&remoteServerPort, "port", "p", 123, "Port of the remote server")
 	ntpdateCmd.Flags().IntVarP(&ntpdateRequests, "requests", "r", 3, "How many requests to send")
+	ntpdateCmd.Flags().StringVar(&ntpdateSOCKS5, "socks5", "", "Query via SOCKS5 proxy (host:port) using UDP ASSOCIATE")
+	ntpdateCmd.Flags().BoolVar(&ntpdateRandomOrigin, "random-origin", false, "Send random transmit timestamp instead of the local time and discard replies not matching it, protecting against off-path spoofing")
+	ntpdateCmd.Flags().StringVar(&ntpdateRelay, "relay", "", "Query via UDP-over-TCP relay (host:port), for example SSH forwarded to a jump host")
+	ntpdateCmd.Flags().StringVar(&ntpdateTransport, "transport", ntp.TransportUDP, "Experimental transport: udp, tcp or unix. Server is the socket path for unix")
 	// printleap
 	utilsCmd.AddCommand(printLeapCmd)
 	printLeapCmd.Flags().StringVarP(&sourceLeapSeconds, "srcfile", "s", "/usr/share/zoneinfo/right/UTC", "Source file of leap seconds")
@@ -287,7 +372,12 @@ var ntpdateCmd = &cobra.Command{
 			fmt.Println("server must be specified")
 			os.Exit(1)
 		}
-		if err := ntpDate(remoteServerAddr, strconv.Itoa(remoteServerPort), ntpdateRequests); err != nil {
+		d, err := ntpTransportDialer(ntpdateTransport, remoteServerAddr, ntpdateSOCKS5, ntpdateRelay, 5*time.Second)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := ntpDate(remoteServerAddr, strconv.Itoa(remoteServerPort), ntpdateRequests, ntpdateRandomOrigin, d); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
@@ -0,0 +1,186 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/facebook/time/cliconfig"
+	"github.com/facebook/time/ntp/journal"
+	"github.com/facebook/time/ntp/prober"
+	"github.com/facebook/time/privsep"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	var (
+		logLevel    string
+		listenAddr  string
+		targets     string
+		pools       string
+		flowLabel   string
+		journalPath string
+		replayPath  string
+		dropUser    string
+		dropGroup   string
+		keepCaps    string
+		c           prober.Config
+		cc          = cliconfig.Config{EnvPrefix: "NTPEXPORTER"}
+	)
+
+	flag.StringVar(&cc.File, cliconfig.FlagFile, "", "Yaml file with flag values. Flags are also read from NTPEXPORTER_<FLAG> environment variables")
+
+	flag.StringVar(&logLevel, "loglevel", "info", "Set a log level. Can be: debug, info, warning, error")
+	flag.StringVar(&listenAddr, "listen", ":9123", "host:port to serve metrics on /metrics")
+	flag.StringVar(&targets, "targets", "", "Comma separated NTP servers to probe, host or host:port")
+	flag.StringVar(&pools, "pools", "", "Comma separated pool hostnames to probe, each rotating through the servers it resolves to and skipping unhealthy ones")
+	flag.DurationVar(&c.PoolInterval, "poolinterval", prober.DefaultPoolInterval, "Interval between re-resolutions of pools")
+	flag.DurationVar(&c.Interval, "interval", 15*time.Second, "Interval between probes")
+	flag.DurationVar(&c.Timeout, "timeout", time.Second, "Timeout of a single probe")
+	flag.StringVar(&c.Iface, "iface", "", "Interface to use hardware timestamps on, falling back to software timestamps. Userspace timestamps are used if empty")
+	flag.IntVar(&c.HopLimit, "hoplimit", 0, "Hop limit (TTL) of queries. System default if 0")
+	flag.StringVar(&flowLabel, "flowlabel", "", "IPv6 flow label of queries, like 0x1234. Disabled if empty")
+	flag.BoolVar(&c.DualStack, "dualstack", false, "Race queries over IPv4 and IPv6 to targets resolving to both, reporting each family and polling the healthier one")
+	flag.StringVar(&journalPath, "journal", "", "File to append every exchange to for later replay. Disabled if empty")
+	flag.StringVar(&replayPath, "replay", "", "Replay journal file, printing results as JSON lines, and exit")
+	flag.StringVar(&dropUser, "user", "", "User to drop privileges to once metrics listener and journal are open. Privileges are kept if empty")
+	flag.StringVar(&dropGroup, "group", "", "Group to drop privileges to. Primary group of -user if empty")
+	flag.StringVar(&keepCaps, "keepcaps", "", "Comma separated capabilities to keep after dropping privileges, like net_admin for hardware timestamps")
+	flag.Parse()
+	if err := cc.Apply(cliconfig.StdFlags(flag.CommandLine)); err != nil {
+		log.Fatalf("Failed to apply flags: %v", err)
+	}
+
+	switch logLevel {
+	case "debug":
+		log.SetLevel(log.DebugLevel)
+	case "info":
+		log.SetLevel(log.InfoLevel)
+	case "warning":
+		log.SetLevel(log.WarnLevel)
+	case "error":
+		log.SetLevel(log.ErrorLevel)
+	default:
+		log.Fatalf("Unrecognized log level: %v", logLevel)
+	}
+
+	if replayPath != "" {
+		if err := replayJournal(replayPath); err != nil {
+			log.Fatalf("Failed to replay journal: %v", err)
+		}
+		return
+	}
+
+	if flowLabel != "" {
+		label, err := strconv.ParseUint(flowLabel, 0, 20)
+		if err != nil {
+			log.Fatalf("Failed to parse flow label %q: %v", flowLabel, err)
+		}
+		c.FlowLabel = uint32(label)
+	}
+
+	for _, t := range strings.Split(targets, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			c.Targets = append(c.Targets, t)
+		}
+	}
+	for _, pl := range strings.Split(pools, ",") {
+		if pl = strings.TrimSpace(pl); pl != "" {
+			c.Pools = append(c.Pools, pl)
+		}
+	}
+	if len(c.Targets) == 0 && len(c.Pools) == 0 {
+		log.Fatalf("No targets to probe")
+	}
+
+	caps, err := privsep.ParseCapabilities(keepCaps)
+	if err != nil {
+		log.Fatalf("Invalid capabilities to keep: %v", err)
+	}
+	priv := &privsep.Config{User: dropUser, Group: dropGroup, Capabilities: caps}
+
+	p := prober.New(c)
+	var ln net.Listener
+	err = priv.Setup(
+		func() error {
+			if journalPath == "" {
+				return nil
+			}
+			w, err := journal.OpenWriter(journalPath)
+			if err != nil {
+				return fmt.Errorf("opening journal: %w", err)
+			}
+			p.Journal = w
+			return nil
+		},
+		func() error {
+			var err error
+			if ln, err = net.Listen("tcp", listenAddr); err != nil {
+				return fmt.Errorf("listening for metrics: %w", err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		log.Fatalf("Failed to set up: %v", err)
+	}
+	if p.Journal != nil {
+		defer p.Journal.Close()
+	}
+	if priv.Enabled() {
+		log.Infof("Dropped privileges to %s, keeping %v", priv.User, priv.Capabilities)
+	}
+
+	go func() {
+		if err := p.Run(context.Background()); err != nil {
+			log.Fatalf("Prober failed: %v", err)
+		}
+	}()
+
+	http.Handle("/metrics", p)
+	log.Infof("Probing %d target(s) every %s, serving metrics on %s", len(c.Targets), c.Interval, listenAddr)
+	log.Fatal(fmt.Errorf("serving metrics: %w", http.Serve(ln, nil)))
+}
+
+// replayJournal prints results of exchanges recorded in the journal file
+func replayJournal(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(os.Stdout)
+	var encErr error
+	err = prober.New(prober.Config{}).Replay(f, func(r prober.Result) {
+		if encErr == nil {
+			encErr = enc.Encode(r)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return encErr
+}
@@ -0,0 +1,76 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/facebook/time/ntp/loadgen"
+)
+
+func main() {
+	c := &loadgen.Config{}
+
+	var source string
+	var sources int
+	var verbose bool
+
+	flag.StringVar(&c.Server, "server", "127.0.0.1:123", "NTP server host:port to load")
+	flag.IntVar(&c.QPS, "qps", 10000, "Queries per second")
+	flag.DurationVar(&c.Duration, "duration", time.Minute, "Duration of the load")
+	flag.IntVar(&c.Ports, "ports", 16, "Source ports per source IP")
+	flag.StringVar(&source, "source", "", "First source IP of simulated clients. Default source IP if empty")
+	flag.IntVar(&sources, "sources", 1, "Number of consecutive source IPs starting with -source. Lab networks only, replies must be routed back")
+	flag.DurationVar(&c.Timeout, "timeout", time.Second, "How long to wait for responses after the last query")
+	flag.BoolVar(&verbose, "verbose", false, "Verbose logging")
+
+	flag.Parse()
+
+	if verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+	if source != "" {
+		ip := net.ParseIP(source)
+		if ip == nil {
+			log.Fatalf("Failed to parse source IP %q", source)
+		}
+		c.Sources = loadgen.SourceRange(ip, sources)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	stats, err := loadgen.Run(ctx, c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+}
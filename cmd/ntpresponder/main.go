@@ -21,16 +21,25 @@ import (
 	"flag"
 	"fmt"
 	syscall "golang.org/x/sys/unix"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/facebook/time/cliconfig"
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/protocol/nts"
 	"github.com/facebook/time/ntp/responder/announce"
 	"github.com/facebook/time/ntp/responder/checker"
+	"github.com/facebook/time/ntp/responder/management"
 	"github.com/facebook/time/ntp/responder/server"
 	"github.com/facebook/time/ntp/responder/stats"
+	"github.com/facebook/time/privsep"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -43,23 +52,137 @@ func main() {
 		debugger       bool
 		logLevel       string
 		monitoringport int
+		monitoringsock string
+		managementaddr string
+		aclPath        string
+		configPath     string
+		broadcastIP    string
+		flowLabel      string
+		smearStart     string
+		audit          bool
+		auditRate      int64
+		auditInterval  time.Duration
+		readLatency    bool
+		listenerCPUs   string
+		workerCPUs     string
+		replicaListen  string
+		replicaPeers   string
+		replicaEvery   time.Duration
+		replicaKey     string
+		ntsListen      string
+		ntsCert        string
+		ntsKey         string
+		ntsRotate      time.Duration
+		selfTest       string
+		selfTestEvery  time.Duration
+		selfTestOffset time.Duration
+		traceRate      int64
+		traceSize      int
+		traceSlow      time.Duration
+		regionsPath    string
+		maxRegions     int
+		canaryNets     string
+		canaryOffset   time.Duration
+		systemd        bool
+		dropUser       string
+		dropGroup      string
+		keepCaps       string
 	)
 
+	cc := cliconfig.Config{EnvPrefix: "NTPRESPONDER"}
+	flag.StringVar(&cc.File, cliconfig.FlagFile, "", "Yaml file with flag values. Flags are also read from NTPRESPONDER_<FLAG> environment variables")
 	flag.StringVar(&logLevel, "loglevel", "info", "Set a log level. Can be: debug, info, warning, error")
 	flag.StringVar(&s.ListenConfig.Iface, "interface", "lo", "Interface to add IPs to")
 	flag.StringVar(&s.RefID, "refid", "OLEG", "Reference ID of the server")
 	flag.IntVar(&s.ListenConfig.Port, "port", 123, "Port to run service on")
 	flag.IntVar(&monitoringport, "monitoringport", 0, "Port to run monitoring server on")
+	flag.StringVar(&monitoringsock, "monitoringsocket", "", "Unix socket to serve stats on, in addition to the monitoring port. Disabled if empty")
+	flag.StringVar(&managementaddr, "managementaddr", "", "host:port to run management API on. Disabled if empty")
+	flag.StringVar(&configPath, "config", "", "Path to the yaml config. Overrides listen, workers, reference, acl and ratelimit flags. Reloaded on SIGHUP and on change")
+	flag.StringVar(&aclPath, "acl", "", "File with IPs/networks allowed to query the server. Everyone is allowed if empty")
 	flag.IntVar(&s.Stratum, "stratum", 1, "Stratum of the server")
 	flag.IntVar(&s.Workers, "workers", runtime.NumCPU()*100, "How many workers (routines) to run")
 	flag.Var(&s.ListenConfig.IPs, "ip", fmt.Sprintf("IP to listen to. Repeat for multiple. Default: %s", server.DefaultServerIPs))
+	flag.StringVar(&s.Transport.Network, "transport", "", "Experimental transport to serve in addition to UDP: tcp or unix. Disabled if empty")
+	flag.StringVar(&s.Transport.Address, "transportaddr", ":123", "host:port for tcp or socket path for unix experimental transport")
+	flag.StringVar(&broadcastIP, "broadcastip", "", "Broadcast address or multicast group to periodically send time to. Disabled if empty")
+	flag.IntVar(&s.Broadcast.Port, "broadcastport", 123, "Port to send broadcast packets to")
+	flag.DurationVar(&s.Broadcast.Interval, "broadcastinterval", 64*time.Second, "Interval between broadcast packets")
 	flag.BoolVar(&debugger, "pprof", false, "Enable pprof")
 	flag.BoolVar(&s.ListenConfig.ShouldAnnounce, "announce", false, "Advertize IPs")
+	flag.IntVar(&s.ListenConfig.HopLimit, "hoplimit", 0, "Hop limit (TTL) of responses. System default if 0")
+	flag.StringVar(&flowLabel, "flowlabel", "", "IPv6 flow label of responses, like 0x1234. Disabled if empty")
 	flag.DurationVar(&s.ExtraOffset, "extraoffset", 0, "Extra offset to return to clients")
+	flag.StringVar(&smearStart, "smearstart", "", "Start of the leap smear window in RFC3339 format. Advertised to NTPv4 clients via experimental extension field")
+	flag.DurationVar(&s.Smear.Duration, "smearduration", 0, "Duration of the leap smear window. Disabled if 0")
+	flag.DurationVar(&s.Smear.Leap, "smearleap", time.Second, "Leap second smeared: 1s for inserted, -1s for deleted")
+	flag.StringVar(&s.Padding.Mode, "padding", server.PaddingOff, "Bound response size by request size to prevent amplification: limit drops extension fields that don't fit, match also pads up to the request size. Disabled if empty")
+	flag.DurationVar(&s.Impair.Delay, "impairdelay", 0, "Lab testing: delay responses by this much. Responses are marked with TEST refid")
+	flag.DurationVar(&s.Impair.Jitter, "impairjitter", 0, "Lab testing: jitter of the response delay")
+	flag.StringVar(&s.Impair.Distribution, "impairdistribution", server.DistributionUniform, "Lab testing: jitter distribution: uniform, normal or exponential")
+	flag.DurationVar(&s.Impair.Offset, "impairoffset", 0, "Lab testing: offset of the returned time")
+
+	flag.BoolVar(&audit, "audit", false, "Verify responses are generated statelessly and track per client state growth")
+	flag.Int64Var(&auditRate, "auditrate", 1000, "Verify every N-th response in audit mode")
+	flag.DurationVar(&auditInterval, "auditinterval", time.Minute, "Interval between state size samples in audit mode")
+
+	flag.BoolVar(&readLatency, "readlatency", false, "Track latency between kernel receive timestamps and userspace reads. Exposed via management API")
+
+	flag.Int64Var(&traceRate, "tracerate", 0, "Trace handling stages of every N-th request. Exposed via management API. Disabled if 0")
+	flag.IntVar(&traceSize, "tracesize", 100, "How many latest traces to keep")
+	flag.DurationVar(&traceSlow, "traceslow", 0, "Log traces of requests handled slower than this. Disabled if 0")
+
+	flag.StringVar(&regionsPath, "regions", "", "File with 'prefix region' per line to count requests per client region. Exposed via management API. Disabled if empty")
+	flag.IntVar(&maxRegions, "maxregions", 1000, "Max number of distinct regions to count. Further ones are counted as other. Unlimited if 0")
+	flag.StringVar(&canaryNets, "canarynets", "", "Canary: comma separated IPs/networks served time skewed by -canaryoffset, to verify client monitoring. Disabled if empty")
+	flag.DurationVar(&canaryOffset, "canaryoffset", 0, "Canary: offset of the time served to -canarynets, up to 1s")
+	flag.StringVar(&dropUser, "user", "", "User to drop privileges to once sockets are open. Privileges are kept if empty")
+	flag.StringVar(&dropGroup, "group", "", "Group to drop privileges to. Primary group of -user if empty")
+	flag.StringVar(&keepCaps, "keepcaps", "", "Comma separated capabilities to keep after dropping privileges, like sys_time. net_admin is kept to remove IPs on shutdown unless sockets are passed by systemd")
+	flag.BoolVar(&systemd, "systemd", false, "Serve sockets passed via systemd socket activation instead of -ip and report readiness and watchdog keep-alives via sd_notify")
+
+	flag.StringVar(&listenerCPUs, "listenercpus", "", "CPUs to pin listener threads to round robin, like 0-3,8. Ideally CPUs handling NIC RX queue IRQs. Disabled if empty")
+	flag.StringVar(&workerCPUs, "workercpus", "", "CPUs to pin worker threads to round robin, like 4-7. Disabled if empty")
+	flag.BoolVar(&s.Affinity.IncomingCPU, "incomingcpu", false, "Set SO_INCOMING_CPU of listener sockets to the CPU of the listener")
+
+	flag.StringVar(&selfTest, "selftest", "", "host:port of the server itself, like 127.0.0.1:123, to probe via the client path and alarm if offset or stratum diverge. Disabled if empty")
+	flag.DurationVar(&selfTestEvery, "selftestinterval", 10*time.Second, "Interval between self-test probes")
+	flag.DurationVar(&selfTestOffset, "selftestmaxoffset", time.Millisecond, "Max divergence of the self-test offset from the expected one. 0 to skip")
+
+	flag.StringVar(&replicaListen, "replicalisten", "", "host:port to receive rate limiter state from peers on. Disabled if empty")
+	flag.StringVar(&replicaPeers, "replicapeers", "", "Comma separated host:port of peers to replicate rate limiter state with. Disabled if empty")
+	flag.DurationVar(&replicaEvery, "replicainterval", time.Second, "Interval between rate limiter state replications")
+	flag.StringVar(&replicaKey, "replicakeyfile", "", "File with the secret shared by replication peers to authenticate rate limiter state. Required for replication")
+
+	flag.StringVar(&ntsListen, "ntslisten", fmt.Sprintf(":%d", nts.DefaultKEPort), "host:port to run NTS-KE on")
+	flag.StringVar(&ntsCert, "ntscert", "", "TLS certificate for NTS-KE. NTS is disabled if empty")
+	flag.StringVar(&ntsKey, "ntskey", "", "TLS private key for NTS-KE")
+	flag.DurationVar(&ntsRotate, "ntsrotate", 24*time.Hour, "Interval between NTS cookie key rotations. Cookies stay valid for one more interval")
 
 	flag.Parse()
+	if err := cc.Apply(cliconfig.StdFlags(flag.CommandLine)); err != nil {
+		log.Fatalf("Failed to apply flags: %v", err)
+	}
 	s.ListenConfig.IPs.SetDefault()
 
+	if flowLabel != "" {
+		label, err := strconv.ParseUint(flowLabel, 0, 20)
+		if err != nil {
+			log.Fatalf("Failed to parse flow label %q: %v", flowLabel, err)
+		}
+		s.ListenConfig.FlowLabel = uint32(label)
+	}
+
+	if configPath != "" {
+		c, err := server.ReadFileConfig(configPath)
+		if err != nil {
+			log.Fatalf("Failed to read config: %v", err)
+		}
+		if err := c.Apply(&s); err != nil {
+			log.Fatalf("Failed to apply config: %v", err)
+		}
+	}
+
 	switch logLevel {
 	case "debug":
 		log.SetLevel(log.DebugLevel)
@@ -73,6 +196,67 @@ func main() {
 		log.Fatalf("Unrecognized log level: %v", logLevel)
 	}
 
+	if broadcastIP != "" {
+		s.Broadcast.IP = net.ParseIP(broadcastIP)
+		if s.Broadcast.IP == nil {
+			log.Fatalf("Invalid broadcast address %s", broadcastIP)
+		}
+	} else {
+		s.Broadcast.Interval = 0
+	}
+
+	if smearStart != "" {
+		start, err := time.Parse(time.RFC3339, smearStart)
+		if err != nil {
+			log.Fatalf("Invalid smear start %s: %v", smearStart, err)
+		}
+		s.Smear.Start = start
+	} else {
+		s.Smear.Duration = 0
+	}
+
+	if err := s.Padding.Validate(); err != nil {
+		log.Fatalf("Invalid padding mode: %v", err)
+	}
+	if err := s.Impair.Validate(); err != nil {
+		log.Fatalf("Invalid impairment: %v", err)
+	}
+	if s.Impair.Enabled() {
+		log.Warningf("Impairment test mode: delay %s, %s jitter %s, offset %s. Refid is %s", s.Impair.Delay, s.Impair.Distribution, s.Impair.Jitter, s.Impair.Offset, server.ImpairRefID)
+	}
+
+	if canaryNets != "" || canaryOffset != 0 {
+		var nets []string
+		if canaryNets != "" {
+			nets = strings.Split(canaryNets, ",")
+		}
+		c, err := server.NewCanary(nets, canaryOffset)
+		if err != nil {
+			log.Fatalf("Invalid canary: %v", err)
+		}
+		s.Canary = c
+	}
+
+	if systemd {
+		sd, err := server.NewSystemd()
+		if err != nil {
+			log.Fatalf("Failed to set up systemd integration: %v", err)
+		}
+		if sd.Activated() {
+			log.Infof("Serving %d socket(s) passed by systemd", len(sd.Listeners))
+			s.ListenConfig.IPs = sd.IPs()
+		}
+		s.Systemd = sd
+	}
+
+	if dropUser != "" {
+		caps, err := privsep.ParseCapabilities(keepCaps)
+		if err != nil {
+			log.Fatalf("Invalid capabilities to keep: %v", err)
+		}
+		s.Privileges = &privsep.Config{User: dropUser, Group: dropGroup, Capabilities: caps}
+	}
+
 	if s.Workers < 1 {
 		log.Fatalf("Will not start without workers")
 	}
@@ -88,10 +272,74 @@ func main() {
 		log.Warningf("Will announce VIPs")
 	}
 
+	if aclPath != "" && configPath == "" {
+		s.ACL = &server.ACL{Path: aclPath}
+		if err := s.ACL.Load(); err != nil {
+			log.Fatalf("Failed to load acl: %v", err)
+		}
+	}
+
+	if audit {
+		s.Audit = server.NewAudit(auditRate, auditInterval)
+	}
+
+	var err error
+	if s.Affinity.ListenerCPUs, err = server.ParseCPUList(listenerCPUs); err != nil {
+		log.Fatalf("Failed to parse listener cpus: %v", err)
+	}
+	if s.Affinity.WorkerCPUs, err = server.ParseCPUList(workerCPUs); err != nil {
+		log.Fatalf("Failed to parse worker cpus: %v", err)
+	}
+
+	if readLatency {
+		s.ReadLatency = ntp.NewReadLatency()
+	}
+
+	if traceRate > 0 {
+		s.Tracer = server.NewTracer(traceRate, traceSize)
+		s.Tracer.Slow = traceSlow
+	}
+
+	if regionsPath != "" {
+		e, err := server.LoadPrefixEnricher(regionsPath)
+		if err != nil {
+			log.Fatalf("Failed to load regions: %v", err)
+		}
+		s.Regions = server.NewRegionStats(e, maxRegions)
+	}
+
+	if selfTest != "" {
+		s.SelfTest = server.NewSelfTest(selfTest, selfTestEvery, time.Second, selfTestOffset)
+	}
+
+	if replicaPeers != "" {
+		if s.RateLimiter == nil {
+			log.Warningf("Rate limiting is not configured, nothing to replicate")
+		}
+		key, err := server.ReadReplicationKey(replicaKey)
+		if err != nil {
+			log.Fatalf("Failed to read replication key: %v", err)
+		}
+		s.Replication = &server.Replication{Listen: replicaListen, Peers: strings.Split(replicaPeers, ","), Interval: replicaEvery, Key: key}
+	}
+
+	if ntsCert != "" {
+		n, err := server.NewNTS(ntsListen, ntsCert, ntsKey, ntsRotate)
+		if err != nil {
+			log.Fatalf("Failed to set up NTS: %v", err)
+		}
+		s.NTS = n
+	}
+
 	// Monitoring
 	// Replace with your implementation of Stats
 	st := &stats.JSONStats{}
 	go st.Start(monitoringport)
+	if monitoringsock != "" {
+		go func() {
+			log.Errorf("Unix socket stats server stopped: %v", st.StartUnix(monitoringsock))
+		}()
+	}
 
 	// Replace with your implementation of Announce
 	s.Announce = &announce.NoopAnnounce{}
@@ -114,6 +362,31 @@ func main() {
 	s.Stats = st
 	s.Checker = ch
 
+	if managementaddr != "" {
+		m := &management.Server{Responder: &s, Stats: st}
+		if s.Audit != nil {
+			m.Audit = s.Audit
+		}
+		if s.ReadLatency != nil {
+			m.Latency = s.ReadLatency
+		}
+		if s.SelfTest != nil {
+			m.SelfTest = s.SelfTest
+		}
+		if s.Tracer != nil {
+			m.Tracer = s.Tracer
+		}
+		if s.Regions != nil {
+			m.Regions = s.Regions
+		}
+		if s.Canary != nil {
+			m.Canary = s.Canary
+		}
+		go func() {
+			log.Println(m.Start(managementaddr))
+		}()
+	}
+
 	go func() {
 		select {
 		case <-sigStop:
@@ -129,6 +402,27 @@ func main() {
 		}
 	}()
 
+	if configPath != "" {
+		reload := func() {
+			if err := s.ReloadConfig(configPath); err != nil {
+				log.Errorf("Failed to reload config: %v", err)
+			}
+		}
+		sigHup := make(chan os.Signal, 1)
+		signal.Notify(sigHup, syscall.SIGHUP)
+		go func() {
+			for range sigHup {
+				log.Info("SIGHUP received, reloading config")
+				reload()
+			}
+		}()
+		go func() {
+			if err := server.WatchConfig(ctx, configPath, reload); err != nil {
+				log.Errorf("Failed to watch config: %v", err)
+			}
+		}()
+	}
+
 	go s.Start(ctx, cancelFunc)
 	<-shutdownFinish
 }
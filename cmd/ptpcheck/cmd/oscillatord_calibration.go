@@ -0,0 +1,122 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/oscillatord"
+)
+
+var (
+	calibrationStartFlag    bool
+	calibrationWaitFlag     time.Duration
+	calibrationIntervalFlag time.Duration
+)
+
+func init() {
+	oscillatordCmd.AddCommand(calibrationCmd)
+	calibrationCmd.Flags().StringVarP(&oscillatordAddressFlag, "address", "a", "127.0.0.1", "address to connect to")
+	calibrationCmd.Flags().IntVarP(&oscillatordPortFlag, "port", "p", 2958, "port to connect to")
+	calibrationCmd.Flags().BoolVarP(&oscillatorJSONFlag, "json", "j", false, "JSON output")
+	calibrationCmd.Flags().BoolVar(&calibrationStartFlag, "start", false, "request oscillatord to start calibration")
+	calibrationCmd.Flags().DurationVar(&calibrationWaitFlag, "wait", 0, "wait this long for calibration to finish. Don't wait if 0")
+	calibrationCmd.Flags().DurationVar(&calibrationIntervalFlag, "interval", time.Minute, "interval between polls while waiting for calibration")
+}
+
+func printCalibration(c *oscillatord.Calibration) {
+	fmt.Println("Disciplining:")
+	fmt.Printf("\tstatus: %s\n", c.Disciplining.Status)
+	fmt.Printf("\tconvergence: %d/%d (%.1f%%)\n", c.Disciplining.ConvergenceCount, c.Disciplining.ConvergenceThreshold, c.Disciplining.ConvergenceProgress)
+	fmt.Printf("\tready_for_holdover: %v\n", c.Disciplining.ReadyForHoldover)
+	fmt.Println("Calibration:")
+	fmt.Printf("\tvalid: %v\n", c.Parameters.Valid)
+	if c.Parameters.Date > 0 {
+		fmt.Printf("\tdate: %s\n", c.Parameters.Time().UTC().Format(time.RFC3339))
+	}
+	fmt.Printf("\tcoarse_equilibrium: %d\n", c.Parameters.CoarseEquilibrium)
+	fmt.Printf("\tctrl_load_nodes: %v\n", c.Parameters.CtrlLoadNodes)
+	fmt.Printf("\tctrl_drift_coeffs: %v\n", c.Parameters.CtrlDriftCoeffs)
+}
+
+func calibrationRun(address string, start bool, wait, interval time.Duration, jsonOut bool) error {
+	timeout := 1 * time.Second
+	dial := func() (io.ReadWriteCloser, error) {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("setting connection deadline: %w", err)
+		}
+		return conn, nil
+	}
+	conn, err := dial()
+	if err != nil {
+		return fmt.Errorf("connecting to oscillatord: %w", err)
+	}
+	requested := time.Now()
+	var c *oscillatord.Calibration
+	if start {
+		c, err = oscillatord.StartCalibration(conn)
+	} else {
+		c, err = oscillatord.ReadCalibration(conn)
+	}
+	conn.Close()
+	if err != nil {
+		return err
+	}
+	if start && wait > 0 {
+		log.Infof("calibration requested, waiting up to %v for it to finish", wait)
+		ctx, cancel := context.WithTimeout(context.Background(), wait)
+		defer cancel()
+		if c, err = oscillatord.WaitCalibration(ctx, dial, requested, interval); err != nil {
+			return fmt.Errorf("waiting for calibration: %w", err)
+		}
+	}
+	if jsonOut {
+		toPrint, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(toPrint))
+		return nil
+	}
+	printCalibration(c)
+	return nil
+}
+
+var calibrationCmd = &cobra.Command{
+	Use:   "calibration",
+	Short: "Print or start oscillator calibration done by oscillatord",
+	Run: func(c *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		address := net.JoinHostPort(oscillatordAddressFlag, fmt.Sprint(oscillatordPortFlag))
+		if err := calibrationRun(address, calibrationStartFlag, calibrationWaitFlag, calibrationIntervalFlag, oscillatorJSONFlag); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/facebook/time/oscillatord"
+	"github.com/facebook/time/snmp"
+)
+
+var (
+	snmpListenFlag    string
+	snmpCommunityFlag string
+	snmpPrefixFlag    string
+	snmpTargetsFlag   []string
+	snmpIntervalFlag  time.Duration
+)
+
+func init() {
+	oscillatordCmd.AddCommand(oscillatordSNMPCmd)
+	oscillatordSNMPCmd.Flags().StringVar(&snmpListenFlag, "listen", ":161", "address to serve SNMP on")
+	oscillatordSNMPCmd.Flags().StringVar(&snmpCommunityFlag, "community", "public", "SNMP community allowed to read")
+	oscillatordSNMPCmd.Flags().StringVar(&snmpPrefixFlag, "prefix", oscillatord.DefaultSNMPPrefix.String(), "OID of the exported subtree")
+	oscillatordSNMPCmd.Flags().StringSliceVarP(&snmpTargetsFlag, "target", "t", []string{"127.0.0.1:2958"}, "oscillatord monitoring addresses (host:port) to export")
+	oscillatordSNMPCmd.Flags().DurationVar(&snmpIntervalFlag, "interval", 10*time.Second, "interval between oscillatord polls")
+}
+
+func oscillatordSNMPRun(listen, community, prefix string, targets []string, interval time.Duration) error {
+	oid, err := snmp.ParseOID(prefix)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenPacket("udp", listen)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", listen, err)
+	}
+	defer conn.Close()
+
+	poller := oscillatord.NewPoller(targets, interval, time.Second)
+	events := make(chan oscillatord.Event)
+	go func() {
+		for e := range events {
+			log.Infof("%s", e)
+		}
+	}()
+	go func() {
+		if err := poller.Run(context.Background(), events); err != nil {
+			log.Errorf("polling oscillatord: %v", err)
+		}
+	}()
+
+	agent := &snmp.Agent{
+		Community: community,
+		Variables: func() []snmp.Variable {
+			return oscillatord.SNMPVariables(oid, poller.Status())
+		},
+	}
+	log.Infof("serving status of %d oscillatord instances under %s on %s", len(targets), oid, conn.LocalAddr())
+	return agent.Serve(conn)
+}
+
+var oscillatordSNMPCmd = &cobra.Command{
+	Use:   "snmp",
+	Short: "Export status of oscillatord instances over SNMPv2c",
+	Run: func(c *cobra.Command, args []string) {
+		ConfigureVerbosity()
+		if err := oscillatordSNMPRun(snmpListenFlag, snmpCommunityFlag, snmpPrefixFlag, snmpTargetsFlag, snmpIntervalFlag); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -18,6 +18,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -28,6 +29,8 @@ import (
 // flag
 var device string
 var method string
+var phcCount int
+var phcInterval time.Duration
 
 func init() {
 	RootCmd.AddCommand(phcCmd)
@@ -39,6 +42,20 @@ func init() {
 		string(phc.MethodIoctlSysOffsetExtended),
 		fmt.Sprintf("Method to get PHC time: %v", phc.SupportedMethods),
 	)
+	phcCmd.Flags().IntVarP(&phcCount, "count", "n", 1, "Number of measurements. Statistics with outliers filtered out are reported if more than 1")
+	phcCmd.Flags().DurationVarP(&phcInterval, "interval", "i", 10*time.Millisecond, "Interval between measurements")
+}
+
+func printPHCStats(device string, method phc.TimeMethod, count int, interval time.Duration) error {
+	stats, err := phc.OffsetStatsFromDevice(device, method, count, interval)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Samples: %d (%d used)\n", stats.Samples, stats.Used)
+	fmt.Printf("Offset: %s (mean %s, min %s, max %s)\n", stats.Offset, stats.MeanOffset, stats.MinOffset, stats.MaxOffset)
+	fmt.Printf("Delay: %s (min %s)\n", stats.Delay, stats.MinDelay)
+	fmt.Printf("Dispersion: %s\n", stats.Dispersion)
+	return nil
 }
 
 func printPHC(device string, method phc.TimeMethod) error {
@@ -66,6 +83,12 @@ var phcCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		ConfigureVerbosity()
 
+		if phcCount > 1 {
+			if err := printPHCStats(device, phc.TimeMethod(method), phcCount, phcInterval); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 		if err := printPHC(device, phc.TimeMethod(method)); err != nil {
 			log.Fatal(err)
 		}
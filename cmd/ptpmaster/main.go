@@ -0,0 +1,113 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/facebook/time/ptp/master"
+	ptp "github.com/facebook/time/ptp/protocol"
+)
+
+func main() {
+	c := master.DefaultConfig()
+
+	var ipaddr string
+	var destinations string
+	var clockType string
+	var phcMethod string
+	var logLevel string
+	var domain uint
+
+	flag.StringVar(&ipaddr, "ip", "::", "IP to bind on")
+	flag.StringVar(&c.Interface, "iface", "eth0", "Interface to derive clock identity from and join multicast group on")
+	flag.StringVar(&destinations, "dst", "", "Comma separated unicast destinations of sync and announce. Multicast if empty")
+	flag.UintVar(&domain, "domain", 0, "PTP domain number")
+	flag.DurationVar(&c.SyncInterval, "syncinterval", c.SyncInterval, "Interval of sync messages")
+	flag.DurationVar(&c.AnnounceInterval, "announceinterval", c.AnnounceInterval, "Interval of announce messages")
+	flag.DurationVar(&c.UTCOffset, "utcoffset", c.UTCOffset, "UTC offset to announce and to add to the system clock")
+	flag.StringVar(&clockType, "clock", "system", "Clock to distribute. Can be: system, phc")
+	flag.StringVar(&phcMethod, "phcmethod", defaultPHCMethod, "Method to read PHC")
+	flag.StringVar(&logLevel, "loglevel", "info", "Set a log level. Can be: debug, info, warning, error")
+
+	flag.Parse()
+
+	switch logLevel {
+	case "debug":
+		log.SetLevel(log.DebugLevel)
+	case "info":
+		log.SetLevel(log.InfoLevel)
+	case "warning":
+		log.SetLevel(log.WarnLevel)
+	case "error":
+		log.SetLevel(log.ErrorLevel)
+	default:
+		log.Fatalf("Unrecognized log level: %v", logLevel)
+	}
+
+	if domain > 255 {
+		log.Fatalf("Unsupported domain number %d", domain)
+	}
+	c.DomainNumber = uint8(domain)
+
+	c.IP = net.ParseIP(ipaddr)
+	if c.IP == nil {
+		log.Fatalf("Failed to parse IP %q", ipaddr)
+	}
+	if destinations != "" {
+		for _, d := range strings.Split(destinations, ",") {
+			ip := net.ParseIP(d)
+			if ip == nil {
+				log.Fatalf("Failed to parse destination %q", d)
+			}
+			c.Destinations = append(c.Destinations, ip)
+		}
+	}
+
+	iface, err := net.InterfaceByName(c.Interface)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.ClockIdentity, err = ptp.NewClockIdentity(iface.HardwareAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var clock master.Clock
+	switch clockType {
+	case "system":
+		log.Warning("Software timestamps of the system clock greatly reduce the precision")
+		clock = &master.SystemClock{UTCOffset: c.UTCOffset}
+	case "phc":
+		if clock, err = phcClock(c.Interface, phcMethod); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("Unrecognized clock: %s", clockType)
+	}
+
+	m := master.New(c, clock)
+	log.Infof("Starting PTP master %s in domain %d", c.ClockIdentity, c.DomainNumber)
+	if err := m.Run(context.Background()); err != nil {
+		log.Fatalf("Master run failed: %v", err)
+	}
+}
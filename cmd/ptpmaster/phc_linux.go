@@ -0,0 +1,30 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/facebook/time/phc"
+	"github.com/facebook/time/ptp/master"
+)
+
+// defaultPHCMethod is the default method to read PHC
+const defaultPHCMethod = string(phc.MethodSyscallClockGettime)
+
+// phcClock returns PHC of the interface read with the method
+func phcClock(iface, method string) (master.Clock, error) {
+	return &master.PHCClock{Iface: iface, Method: phc.TimeMethod(method)}, nil
+}
@@ -0,0 +1,52 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package dialer implements dialers which allow reaching time servers and devices
+in isolated management networks where direct UDP or TCP isn't possible:
+* SOCKS5 (CONNECT for TCP and UDP ASSOCIATE for UDP)
+* HTTP CONNECT proxy (TCP only)
+* UDP-over-TCP relay, which can be tunneled via SSH port forwarding to a jump host
+*/
+package dialer
+
+import (
+	"net"
+	"time"
+)
+
+// Dialer is a generic dialer. It's compatible with golang.org/x/net/proxy.Dialer
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// Direct connects to the address directly
+type Direct struct {
+	Timeout time.Duration
+}
+
+// Dial connects to the address directly
+func (d *Direct) Dial(network, address string) (net.Conn, error) {
+	return net.DialTimeout(network, address, d.Timeout)
+}
+
+// forward returns dialer or Direct if it's nil
+func forward(d Dialer, timeout time.Duration) Dialer {
+	if d == nil {
+		return &Direct{Timeout: timeout}
+	}
+	return d
+}
@@ -0,0 +1,94 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dialer
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPConnect connects via HTTP proxy using CONNECT method. Only TCP is supported
+type HTTPConnect struct {
+	Proxy   string
+	Header  http.Header
+	Timeout time.Duration
+	// Forward is used to reach the proxy. Direct if nil
+	Forward Dialer
+}
+
+// Dial connects to the address via HTTP proxy
+func (h *HTTPConnect) Dial(network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedNet, network)
+	}
+	conn, err := forward(h.Forward, h.Timeout).Dial("tcp", h.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if h.Timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(h.Timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		Host:   address,
+		Header: h.Header,
+	}
+	req.URL, err = req.URL.Parse("http://" + address)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", address, resp.Status)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn reads whatever was buffered while reading the proxy response first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
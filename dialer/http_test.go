@@ -0,0 +1,75 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dialer
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// connectProxy is a minimal HTTP CONNECT proxy
+func connectProxy(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Host == "forbidden:1" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		_, _ = io.Copy(conn, upstream)
+		conn.Close()
+	}))
+}
+
+func TestHTTPConnect(t *testing.T) {
+	echo := tcpEcho(t)
+	defer echo.Close()
+	proxy := connectProxy(t)
+	defer proxy.Close()
+
+	d := &HTTPConnect{Proxy: proxy.Listener.Addr().String(), Timeout: 5 * time.Second}
+	conn, err := d.Dial("tcp", echo.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	requireEcho(t, conn)
+
+	_, err = d.Dial("tcp", "forbidden:1")
+	require.Error(t, err)
+
+	_, err = d.Dial("udp", echo.Addr().String())
+	require.ErrorIs(t, err, errUnsupportedNet)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dialer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxFrameSize is the largest datagram we relay
+const maxFrameSize = 65535
+
+// relayIdleTimeout closes relayed sessions without traffic
+const relayIdleTimeout = 5 * time.Minute
+
+// TCPRelay tunnels UDP datagrams over TCP to a relay (see ServeRelay) which sends them to the target.
+// Typically relay runs on a jump host and is reached via SSH port forwarding:
+//
+//	ssh -L 4123:localhost:4123 jumphost
+//
+// Every datagram is framed with 2 byte big-endian length. First frame is the target address
+type TCPRelay struct {
+	Relay   string
+	Timeout time.Duration
+	// Forward is used to reach the relay. Direct if nil
+	Forward Dialer
+}
+
+// Dial connects to the address via relay. Only UDP is supported
+func (t *TCPRelay) Dial(network, address string) (net.Conn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedNet, network)
+	}
+	conn, err := forward(t.Forward, t.Timeout).Dial("tcp", t.Relay)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFrame(conn, []byte(address)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &relayConn{Conn: conn}, nil
+}
+
+// relayConn is a packet oriented connection over TCP stream
+type relayConn struct {
+	net.Conn
+}
+
+// Write sends one datagram
+func (c *relayConn) Write(b []byte) (int, error) {
+	if err := writeFrame(c.Conn, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Read receives one datagram. Datagram is truncated if b is too small
+func (c *relayConn) Read(b []byte) (int, error) {
+	frame, err := readFrame(c.Conn)
+	if err != nil {
+		return 0, err
+	}
+	return copy(b, frame), nil
+}
+
+func writeFrame(w io.Writer, b []byte) error {
+	if len(b) > maxFrameSize {
+		return fmt.Errorf("datagram of %d bytes is too big", len(b))
+	}
+	frame := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(frame, uint16(len(b)))
+	copy(frame[2:], b)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	l := make([]byte, 2)
+	if _, err := io.ReadFull(r, l); err != nil {
+		return nil, err
+	}
+	frame := make([]byte, binary.BigEndian.Uint16(l))
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// ServeRelay accepts TCPRelay connections and relays datagrams to the requested targets
+func ServeRelay(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := relay(conn); err != nil && err != io.EOF {
+				log.Debugf("[relay] %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// relay handles a single TCPRelay session
+func relay(conn net.Conn) error {
+	defer conn.Close()
+	target, err := readFrame(conn)
+	if err != nil {
+		return err
+	}
+	udp, err := net.Dial("udp", string(target))
+	if err != nil {
+		return err
+	}
+	defer udp.Close()
+
+	go func() {
+		buf := make([]byte, maxFrameSize)
+		for {
+			if err := udp.SetReadDeadline(time.Now().Add(relayIdleTimeout)); err != nil {
+				return
+			}
+			n, err := udp.Read(buf)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			if err := writeFrame(conn, buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(relayIdleTimeout)); err != nil {
+			return err
+		}
+		frame, err := readFrame(conn)
+		if err != nil {
+			return err
+		}
+		if _, err := udp.Write(frame); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dialer
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrame(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, writeFrame(&b, []byte("hello")))
+	require.Equal(t, []byte{0, 5, 'h', 'e', 'l', 'l', 'o'}, b.Bytes())
+
+	frame, err := readFrame(&b)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), frame)
+
+	require.Error(t, writeFrame(&b, make([]byte, maxFrameSize+1)))
+}
+
+func TestTCPRelay(t *testing.T) {
+	echo := udpEcho(t)
+	defer echo.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() { _ = ServeRelay(ln) }()
+
+	d := &TCPRelay{Relay: ln.Addr().String()}
+	conn, err := d.Dial("udp", echo.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	requireEcho(t, conn)
+
+	_, err = d.Dial("tcp", echo.LocalAddr().String())
+	require.ErrorIs(t, err, errUnsupportedNet)
+}
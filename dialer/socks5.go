@@ -0,0 +1,336 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dialer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc1928
+const (
+	socksVersion      = 5
+	socksAuthNone     = 0
+	socksAuthPassword = 2
+	socksAuthNoAccept = 0xff
+
+	socksCmdConnect      = 1
+	socksCmdUDPAssociate = 3
+
+	socksAtypIPv4   = 1
+	socksAtypDomain = 3
+	socksAtypIPv6   = 4
+)
+
+var socksReplies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+var (
+	errSOCKSVersion     = errors.New("unexpected SOCKS version")
+	errSOCKSAuth        = errors.New("no acceptable SOCKS authentication method")
+	errSOCKSAuthFailed  = errors.New("SOCKS authentication failed")
+	errUnsupportedNet   = errors.New("unsupported network")
+	errShortSOCKSHeader = errors.New("short SOCKS UDP header")
+)
+
+// SOCKS5 connects via SOCKS5 proxy. TCP uses CONNECT and UDP uses UDP ASSOCIATE
+type SOCKS5 struct {
+	Proxy    string
+	Username string
+	Password string
+	Timeout  time.Duration
+	// Forward is used to reach the proxy. Direct if nil
+	Forward Dialer
+}
+
+// Dial connects to the address via SOCKS5 proxy
+func (s *SOCKS5) Dial(network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		conn, err := s.connect(socksCmdConnect, address)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	case "udp", "udp4", "udp6":
+		return s.associate(network, address)
+	}
+	return nil, fmt.Errorf("%w: %s", errUnsupportedNet, network)
+}
+
+// connect establishes control connection and sends command
+func (s *SOCKS5) connect(cmd byte, address string) (net.Conn, error) {
+	conn, err := forward(s.Forward, s.Timeout).Dial("tcp", s.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if s.Timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(s.Timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := s.handshake(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := s.request(conn, cmd, address); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// associate opens UDP relay on the proxy
+func (s *SOCKS5) associate(network, address string) (net.Conn, error) {
+	header, err := encodeAddr(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctrl, err := forward(s.Forward, s.Timeout).Dial("tcp", s.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if s.Timeout > 0 {
+		if err := ctrl.SetDeadline(time.Now().Add(s.Timeout)); err != nil {
+			ctrl.Close()
+			return nil, err
+		}
+	}
+	if err := s.handshake(ctrl); err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	relay, err := s.request(ctrl, socksCmdUDPAssociate, "0.0.0.0:0")
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	if err := ctrl.SetDeadline(time.Time{}); err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	// proxy may reply with unspecified address meaning "same host as the proxy"
+	if relay.IP.IsUnspecified() {
+		if tcpAddr, ok := ctrl.RemoteAddr().(*net.TCPAddr); ok {
+			relay.IP = tcpAddr.IP
+		}
+	}
+	udp, err := net.DialUDP(network, nil, relay)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	// RSV(2) FRAG(1) ATYP+DST.ADDR+DST.PORT
+	return &socksUDPConn{UDPConn: udp, ctrl: ctrl, header: append([]byte{0, 0, 0}, header...)}, nil
+}
+
+// handshake negotiates authentication method
+func (s *SOCKS5) handshake(conn net.Conn) error {
+	methods := []byte{socksAuthNone}
+	if s.Username != "" {
+		methods = append(methods, socksAuthPassword)
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socksVersion {
+		return errSOCKSVersion
+	}
+	switch reply[1] {
+	case socksAuthNone:
+		return nil
+	case socksAuthPassword:
+		// https://datatracker.ietf.org/doc/html/rfc1929
+		req := []byte{1, byte(len(s.Username))}
+		req = append(req, s.Username...)
+		req = append(req, byte(len(s.Password)))
+		req = append(req, s.Password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errSOCKSAuthFailed
+		}
+		return nil
+	}
+	return errSOCKSAuth
+}
+
+// request sends command and returns bound address
+func (s *SOCKS5) request(conn net.Conn, cmd byte, address string) (*net.UDPAddr, error) {
+	addr, err := encodeAddr(address)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, cmd, 0}, addr...)); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 3)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != socksVersion {
+		return nil, errSOCKSVersion
+	}
+	if reply[1] != 0 {
+		msg, ok := socksReplies[reply[1]]
+		if !ok {
+			msg = fmt.Sprintf("unknown SOCKS error %d", reply[1])
+		}
+		return nil, errors.New(msg)
+	}
+	return readAddr(conn)
+}
+
+// encodeAddr encodes host:port as ATYP, DST.ADDR, DST.PORT
+func encodeAddr(address string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+	var b []byte
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append([]byte{socksAtypIPv4}, ip4...)
+		} else {
+			b = append([]byte{socksAtypIPv6}, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("hostname %q is too long", host)
+		}
+		b = append([]byte{socksAtypDomain, byte(len(host))}, host...)
+	}
+	return append(b, byte(port>>8), byte(port)), nil
+}
+
+// readAddr reads ATYP, ADDR, PORT
+func readAddr(r io.Reader) (*net.UDPAddr, error) {
+	atyp := make([]byte, 1)
+	if _, err := io.ReadFull(r, atyp); err != nil {
+		return nil, err
+	}
+	var ip net.IP
+	switch atyp[0] {
+	case socksAtypIPv4:
+		ip = make(net.IP, net.IPv4len)
+	case socksAtypIPv6:
+		ip = make(net.IP, net.IPv6len)
+	case socksAtypDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(r, l); err != nil {
+			return nil, err
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, err
+		}
+		ips, err := net.LookupIP(string(name))
+		if err != nil {
+			return nil, err
+		}
+		ip = ips[0]
+	default:
+		return nil, fmt.Errorf("unknown SOCKS address type %d", atyp[0])
+	}
+	if atyp[0] != socksAtypDomain {
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return nil, err
+		}
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: ip, Port: int(binary.BigEndian.Uint16(port))}, nil
+}
+
+// socksUDPConn wraps datagrams into SOCKS5 UDP request header
+type socksUDPConn struct {
+	*net.UDPConn
+	ctrl   net.Conn
+	header []byte
+}
+
+// Write sends datagram via relay
+func (c *socksUDPConn) Write(b []byte) (int, error) {
+	if _, err := c.UDPConn.Write(append(append([]byte{}, c.header...), b...)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Read receives datagram from the relay stripping the header
+func (c *socksUDPConn) Read(b []byte) (int, error) {
+	buf := make([]byte, len(b)+262)
+	n, err := c.UDPConn.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	if n < 4 {
+		return 0, errShortSOCKSHeader
+	}
+	hl := 4
+	switch buf[3] {
+	case socksAtypIPv4:
+		hl += net.IPv4len + 2
+	case socksAtypIPv6:
+		hl += net.IPv6len + 2
+	case socksAtypDomain:
+		hl += 1 + int(buf[4]) + 2
+	}
+	if n < hl {
+		return 0, errShortSOCKSHeader
+	}
+	return copy(b, buf[hl:n]), nil
+}
+
+// Close closes both relay and control connections
+func (c *socksUDPConn) Close() error {
+	c.ctrl.Close()
+	return c.UDPConn.Close()
+}
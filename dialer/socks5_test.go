@@ -0,0 +1,213 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dialer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// socksServer is a minimal SOCKS5 server supporting CONNECT and UDP ASSOCIATE
+func socksServer(t *testing.T, user, pass string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS(conn, user, pass)
+		}
+	}()
+	return ln
+}
+
+func serveSOCKS(conn net.Conn, user, pass string) {
+	defer conn.Close()
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+	methods := make([]byte, buf[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	if user == "" {
+		_, _ = conn.Write([]byte{socksVersion, socksAuthNone})
+	} else {
+		if !bytes.Contains(methods, []byte{socksAuthPassword}) {
+			_, _ = conn.Write([]byte{socksVersion, socksAuthNoAccept})
+			return
+		}
+		_, _ = conn.Write([]byte{socksVersion, socksAuthPassword})
+		hdr := make([]byte, 2)
+		_, _ = io.ReadFull(conn, hdr)
+		u := make([]byte, hdr[1])
+		_, _ = io.ReadFull(conn, u)
+		_, _ = io.ReadFull(conn, hdr[:1])
+		p := make([]byte, hdr[0])
+		_, _ = io.ReadFull(conn, p)
+		if string(u) != user || string(p) != pass {
+			_, _ = conn.Write([]byte{1, 1})
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+
+	req := make([]byte, 3)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	dst, err := readAddr(conn)
+	if err != nil {
+		return
+	}
+	switch req[1] {
+	case socksCmdConnect:
+		upstream, err := net.Dial("tcp", dst.String())
+		if err != nil {
+			_, _ = conn.Write([]byte{socksVersion, 5, 0, socksAtypIPv4, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer upstream.Close()
+		_, _ = conn.Write([]byte{socksVersion, 0, 0, socksAtypIPv4, 0, 0, 0, 0, 0, 0})
+		go func() { _, _ = io.Copy(upstream, conn) }()
+		_, _ = io.Copy(conn, upstream)
+	case socksCmdUDPAssociate:
+		relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+		if err != nil {
+			return
+		}
+		defer relay.Close()
+		// reply with unspecified address, client has to use proxy address
+		port := relay.LocalAddr().(*net.UDPAddr).Port
+		_, _ = conn.Write([]byte{socksVersion, 0, 0, socksAtypIPv4, 0, 0, 0, 0, byte(port >> 8), byte(port)})
+		go func() {
+			buf := make([]byte, 1500)
+			var client net.Addr
+			for {
+				n, addr, err := relay.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				if client == nil || addr.String() == client.String() {
+					// from client: strip header and send to target
+					client = addr
+					target, err := readAddr(bytes.NewReader(buf[3:n]))
+					if err != nil {
+						return
+					}
+					hl := 3 + 1 + len(target.IP.To4()) + 2
+					_, _ = relay.WriteTo(buf[hl:n], target)
+					continue
+				}
+				// from target: add header and send to client
+				src := addr.(*net.UDPAddr)
+				h, _ := encodeAddr(src.String())
+				_, _ = relay.WriteTo(append(append([]byte{0, 0, 0}, h...), buf[:n]...), client)
+			}
+		}()
+		// association lives as long as control connection
+		_, _ = io.Copy(ioutil.Discard, conn)
+	default:
+		_, _ = conn.Write([]byte{socksVersion, 7, 0, socksAtypIPv4, 0, 0, 0, 0, 0, 0})
+	}
+}
+
+func TestEncodeAddr(t *testing.T) {
+	b, err := encodeAddr("1.2.3.4:123")
+	require.NoError(t, err)
+	require.Equal(t, []byte{socksAtypIPv4, 1, 2, 3, 4, 0, 123}, b)
+
+	b, err = encodeAddr("[::1]:123")
+	require.NoError(t, err)
+	require.Equal(t, append(append([]byte{socksAtypIPv6}, net.ParseIP("::1")...), 0, 123), b)
+
+	b, err = encodeAddr("time.example.com:123")
+	require.NoError(t, err)
+	require.Equal(t, append(append([]byte{socksAtypDomain, 16}, "time.example.com"...), 0, 123), b)
+
+	_, err = encodeAddr("1.2.3.4")
+	require.Error(t, err)
+	_, err = encodeAddr("1.2.3.4:99999")
+	require.Error(t, err)
+}
+
+func TestReadAddr(t *testing.T) {
+	addr, err := readAddr(bytes.NewReader([]byte{socksAtypIPv4, 1, 2, 3, 4, 0, 123}))
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4:123", addr.String())
+
+	_, err = readAddr(bytes.NewReader([]byte{42}))
+	require.Error(t, err)
+}
+
+func TestSOCKS5Connect(t *testing.T) {
+	echo := tcpEcho(t)
+	defer echo.Close()
+	proxy := socksServer(t, "", "")
+	defer proxy.Close()
+
+	d := &SOCKS5{Proxy: proxy.Addr().String()}
+	conn, err := d.Dial("tcp", echo.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	requireEcho(t, conn)
+}
+
+func TestSOCKS5Auth(t *testing.T) {
+	echo := tcpEcho(t)
+	defer echo.Close()
+	proxy := socksServer(t, "user", "pass")
+	defer proxy.Close()
+
+	d := &SOCKS5{Proxy: proxy.Addr().String()}
+	_, err := d.Dial("tcp", echo.Addr().String())
+	require.ErrorIs(t, err, errSOCKSAuth)
+
+	d = &SOCKS5{Proxy: proxy.Addr().String(), Username: "user", Password: "wrong"}
+	_, err = d.Dial("tcp", echo.Addr().String())
+	require.ErrorIs(t, err, errSOCKSAuthFailed)
+
+	d = &SOCKS5{Proxy: proxy.Addr().String(), Username: "user", Password: "pass"}
+	conn, err := d.Dial("tcp", echo.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	requireEcho(t, conn)
+}
+
+func TestSOCKS5UDP(t *testing.T) {
+	echo := udpEcho(t)
+	defer echo.Close()
+	proxy := socksServer(t, "", "")
+	defer proxy.Close()
+
+	d := &SOCKS5{Proxy: proxy.Addr().String()}
+	conn, err := d.Dial("udp", echo.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	requireEcho(t, conn)
+
+	_, err = d.Dial("unix", "/tmp/sock")
+	require.ErrorIs(t, err, errUnsupportedNet)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leapsched
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// from linux/timex.h
+const (
+	adjStatus = 0x0010
+	staIns    = 0x0010
+	staDel    = 0x0020
+)
+
+// systemKernel controls leap second flags of the system clock via adjtimex
+type systemKernel struct{}
+
+func (k *systemKernel) Leap() (Action, error) {
+	tx := &unix.Timex{}
+	if _, err := unix.Adjtimex(tx); err != nil {
+		return ActionNone, err
+	}
+	return statusToAction(tx.Status), nil
+}
+
+func (k *systemKernel) SetLeap(a Action) error {
+	tx := &unix.Timex{}
+	if _, err := unix.Adjtimex(tx); err != nil {
+		return err
+	}
+	tx.Modes = adjStatus
+	tx.Status = actionToStatus(tx.Status, a)
+	_, err := unix.Adjtimex(tx)
+	return err
+}
+
+func statusToAction(status int32) Action {
+	switch {
+	case status&staIns != 0:
+		return ActionInsert
+	case status&staDel != 0:
+		return ActionDelete
+	}
+	return ActionNone
+}
+
+// actionToStatus keeps other status bits as they are
+func actionToStatus(status int32, a Action) int32 {
+	status &^= staIns | staDel
+	switch a {
+	case ActionInsert:
+		status |= staIns
+	case ActionDelete:
+		status |= staDel
+	}
+	return status
+}
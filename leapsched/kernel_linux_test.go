@@ -0,0 +1,39 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leapsched
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusAction(t *testing.T) {
+	// STA_PLL and STA_NANO are preserved
+	status := int32(0x2001)
+	require.Equal(t, ActionNone, statusToAction(status))
+
+	status = actionToStatus(status, ActionInsert)
+	require.Equal(t, int32(0x2011), status)
+	require.Equal(t, ActionInsert, statusToAction(status))
+
+	status = actionToStatus(status, ActionDelete)
+	require.Equal(t, int32(0x2021), status)
+	require.Equal(t, ActionDelete, statusToAction(status))
+
+	require.Equal(t, int32(0x2001), actionToStatus(status, ActionNone))
+}
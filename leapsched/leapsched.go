@@ -0,0 +1,162 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package leapsched arms and disarms kernel leap second handling based on the leap seconds
+from the system timezone database. Kernel inserts or deletes the leap second at the end
+of the current UTC day, so the flag is only armed within 24 hours before the leap second.
+*/
+package leapsched
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/facebook/time/clock"
+	"github.com/facebook/time/leapsectz"
+)
+
+// Window is how long before the leap second the kernel flag can be armed
+const Window = 24 * time.Hour
+
+// Action is what the kernel does at the end of the UTC day
+type Action int
+
+// Supported actions
+const (
+	ActionNone Action = iota
+	ActionInsert
+	ActionDelete
+)
+
+var actionToString = map[Action]string{
+	ActionNone:   "none",
+	ActionInsert: "insert",
+	ActionDelete: "delete",
+}
+
+func (a Action) String() string {
+	s, ok := actionToString[a]
+	if !ok {
+		return fmt.Sprintf("unknown(%d)", int(a))
+	}
+	return s
+}
+
+var (
+	// ErrNotSupported is returned on platforms without kernel leap second handling
+	ErrNotSupported = errors.New("kernel leap second handling is not supported")
+	// ErrNoLeap is returned when there is no upcoming leap second
+	ErrNoLeap = errors.New("no upcoming leap second")
+	// ErrOutsideWindow is returned when arming is attempted outside of the 24h window before the leap second
+	ErrOutsideWindow = errors.New("leap second is outside of the arming window")
+	errNotMidnight   = errors.New("leap second is not at the end of UTC day")
+)
+
+// Kernel controls kernel leap second flags
+type Kernel interface {
+	// Leap returns currently armed action
+	Leap() (Action, error)
+	// SetLeap arms the action, ActionNone disarms
+	SetLeap(Action) error
+}
+
+// Leap is an upcoming leap second
+type Leap struct {
+	// Time is the end of UTC day when the leap second happens
+	Time   time.Time
+	Action Action
+}
+
+// Next returns the first leap second after now. Insertion or deletion is derived from the change of the TAI offset
+func Next(leaps []leapsectz.LeapSecond, now time.Time) (*Leap, error) {
+	var prev int32
+	for _, l := range leaps {
+		t := l.Time()
+		if t.After(now) {
+			a := ActionInsert
+			if l.Nleap < prev {
+				a = ActionDelete
+			}
+			return &Leap{Time: t.UTC(), Action: a}, nil
+		}
+		prev = l.Nleap
+	}
+	return nil, ErrNoLeap
+}
+
+// CheckWindow verifies the leap second can be armed now: it must be at the end of UTC day within the next 24h
+func CheckWindow(l *Leap, now time.Time) error {
+	if !l.Time.Equal(l.Time.Truncate(Window)) {
+		return fmt.Errorf("%w: %s", errNotMidnight, l.Time.Format(time.RFC3339))
+	}
+	if now.Before(l.Time.Add(-Window)) || !now.Before(l.Time) {
+		return fmt.Errorf("%w: %s is %v away", ErrOutsideWindow, l.Time.Format(time.RFC3339), l.Time.Sub(now).Truncate(time.Second))
+	}
+	return nil
+}
+
+// Scheduler arms kernel leap second flag from the leap seconds file
+type Scheduler struct {
+	Kernel Kernel
+	// LeapFile is the timezone database file with leap seconds. System default is used if empty
+	LeapFile string
+	// Clock is the time the window is checked against
+	Clock clock.Clock
+}
+
+// NewScheduler returns Scheduler controlling the system clock
+func NewScheduler(leapFile string) *Scheduler {
+	return &Scheduler{
+		Kernel:   &systemKernel{},
+		LeapFile: leapFile,
+		Clock:    clock.System{},
+	}
+}
+
+// Upcoming returns the next leap second from the leap file
+func (s *Scheduler) Upcoming() (*Leap, error) {
+	leaps, err := leapsectz.Parse(s.LeapFile)
+	if err != nil {
+		return nil, err
+	}
+	return Next(leaps, s.Clock.Now())
+}
+
+// Arm sets kernel flag for the upcoming leap second. It refuses to arm outside of the 24h window
+func (s *Scheduler) Arm() (*Leap, error) {
+	l, err := s.Upcoming()
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckWindow(l, s.Clock.Now()); err != nil {
+		return l, err
+	}
+	armed, err := s.Kernel.Leap()
+	if err != nil {
+		return l, err
+	}
+	if armed == l.Action {
+		return l, nil
+	}
+	return l, s.Kernel.SetLeap(l.Action)
+}
+
+// Disarm clears kernel leap second flags
+func (s *Scheduler) Disarm() error {
+	return s.Kernel.SetLeap(ActionNone)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leapsched
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/facebook/time/clock"
+	"github.com/facebook/time/leapsectz"
+)
+
+// leap returns LeapSecond happening at the end of the UTC day before t with nleap TAI offset after it
+func leap(t time.Time, nleap int32) leapsectz.LeapSecond {
+	return leapsectz.LeapSecond{Tleap: uint64(t.Unix()) + uint64(nleap) - 1, Nleap: nleap}
+}
+
+var (
+	leap2016  = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	leap2015  = time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC)
+	testLeaps = []leapsectz.LeapSecond{
+		leap(leap2015, 36),
+		leap(leap2016, 37),
+	}
+)
+
+type fakeKernel struct {
+	action Action
+	err    error
+	sets   int
+}
+
+func (k *fakeKernel) Leap() (Action, error) {
+	return k.action, k.err
+}
+
+func (k *fakeKernel) SetLeap(a Action) error {
+	if k.err != nil {
+		return k.err
+	}
+	k.sets++
+	k.action = a
+	return nil
+}
+
+func TestNext(t *testing.T) {
+	l, err := Next(testLeaps, leap2016.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, &Leap{Time: leap2016, Action: ActionInsert}, l)
+
+	l, err = Next(testLeaps, leap2015.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, leap2015, l.Time)
+
+	_, err = Next(testLeaps, leap2016)
+	require.ErrorIs(t, err, ErrNoLeap)
+
+	negative := append(testLeaps, leap(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), 36))
+	l, err = Next(negative, leap2016)
+	require.NoError(t, err)
+	require.Equal(t, ActionDelete, l.Action)
+}
+
+func TestCheckWindow(t *testing.T) {
+	l := &Leap{Time: leap2016, Action: ActionInsert}
+	require.NoError(t, CheckWindow(l, leap2016.Add(-time.Hour)))
+	require.NoError(t, CheckWindow(l, leap2016.Add(-Window)))
+	require.ErrorIs(t, CheckWindow(l, leap2016.Add(-Window-time.Second)), ErrOutsideWindow)
+	require.ErrorIs(t, CheckWindow(l, leap2016), ErrOutsideWindow)
+
+	l.Time = leap2016.Add(time.Hour)
+	require.ErrorIs(t, CheckWindow(l, leap2016), errNotMidnight)
+}
+
+func testScheduler(t *testing.T, now time.Time) (*Scheduler, *fakeKernel) {
+	path := filepath.Join(t.TempDir(), "UTC")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, leapsectz.Write(f, '2', testLeaps, "UTC"))
+	require.NoError(t, f.Close())
+	k := &fakeKernel{}
+	return &Scheduler{Kernel: k, LeapFile: path, Clock: clock.NewSimulated(now)}, k
+}
+
+func TestSchedulerArm(t *testing.T) {
+	s, k := testScheduler(t, leap2016.Add(-time.Hour))
+	l, err := s.Arm()
+	require.NoError(t, err)
+	require.Equal(t, leap2016, l.Time)
+	require.Equal(t, ActionInsert, k.action)
+	require.Equal(t, 1, k.sets)
+
+	// already armed
+	_, err = s.Arm()
+	require.NoError(t, err)
+	require.Equal(t, 1, k.sets)
+
+	require.NoError(t, s.Disarm())
+	require.Equal(t, ActionNone, k.action)
+}
+
+func TestSchedulerArmOutsideWindow(t *testing.T) {
+	s, k := testScheduler(t, leap2016.Add(-48*time.Hour))
+	_, err := s.Arm()
+	require.ErrorIs(t, err, ErrOutsideWindow)
+	require.Equal(t, 0, k.sets)
+
+	// a day later the window opens
+	s.Clock.(*clock.Simulated).Advance(25 * time.Hour)
+	_, err = s.Arm()
+	require.NoError(t, err)
+	require.Equal(t, 1, k.sets)
+}
+
+func TestSchedulerArmKernelError(t *testing.T) {
+	s, k := testScheduler(t, leap2016.Add(-time.Hour))
+	k.err = errors.New("operation not permitted")
+	_, err := s.Arm()
+	require.EqualError(t, err, "operation not permitted")
+}
@@ -0,0 +1,91 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package metrics defines a small metrics interface used by the libraries of this repo,
+so their consumers aren't forced into a particular metrics stack:
+* Counter, Gauge and Histogram created by a Registry
+* Noop registry, used when nothing is configured
+* Prometheus registry serving text exposition format, without extra dependencies
+Other stacks plug in by implementing Registry.
+*/
+package metrics
+
+// Labels are dimensions of a metric, such as {"source": "calnex01.example.com"}
+type Labels map[string]string
+
+// Counter is a value which only goes up, such as the number of requests
+type Counter interface {
+	// Add increases the counter. Negative values are ignored
+	Add(v float64)
+	Inc()
+}
+
+// Gauge is a value which goes up and down, such as temperature
+type Gauge interface {
+	Set(v float64)
+	Add(v float64)
+}
+
+// Histogram counts observations, such as latencies, in buckets
+type Histogram interface {
+	Observe(v float64)
+}
+
+// Registry creates metrics. Metrics with the same name and labels are the same metric.
+// Names are expected to follow Prometheus conventions, like calnex_api_requests_total
+type Registry interface {
+	Counter(name, help string, labels Labels) Counter
+	Gauge(name, help string, labels Labels) Gauge
+	// Histogram with upper bounds of buckets in increasing order
+	Histogram(name, help string, buckets []float64, labels Labels) Histogram
+}
+
+// DefaultBuckets are histogram buckets for latencies in seconds, from 100us to 10s
+var DefaultBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// Default returns r, or the Noop registry if r is nil
+func Default(r Registry) Registry {
+	if r == nil {
+		return Noop{}
+	}
+	return r
+}
+
+// Noop is a registry of metrics which do nothing
+type Noop struct{}
+
+type noopMetric struct{}
+
+func (noopMetric) Add(float64)     {}
+func (noopMetric) Inc()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
+
+// Counter returns a counter which does nothing
+func (Noop) Counter(name, help string, labels Labels) Counter {
+	return noopMetric{}
+}
+
+// Gauge returns a gauge which does nothing
+func (Noop) Gauge(name, help string, labels Labels) Gauge {
+	return noopMetric{}
+}
+
+// Histogram returns a histogram which does nothing
+func (Noop) Histogram(name, help string, buckets []float64, labels Labels) Histogram {
+	return noopMetric{}
+}
@@ -0,0 +1,38 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	require.Equal(t, Noop{}, Default(nil))
+	p := NewPrometheus()
+	require.Equal(t, p, Default(p))
+}
+
+func TestNoop(t *testing.T) {
+	r := Default(nil)
+	r.Counter("requests_total", "", nil).Inc()
+	r.Counter("requests_total", "", nil).Add(2)
+	r.Gauge("temperature", "", Labels{"a": "b"}).Set(42)
+	r.Gauge("temperature", "", Labels{"a": "b"}).Add(1)
+	r.Histogram("latency_seconds", "", DefaultBuckets, nil).Observe(0.1)
+}
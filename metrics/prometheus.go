@@ -0,0 +1,279 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// metric kinds as in Prometheus TYPE lines
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// atomicFloat is a float64 updated without locks
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+func (f *atomicFloat) store(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		if atomic.CompareAndSwapUint64(&f.bits, old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+type counter struct {
+	atomicFloat
+}
+
+func (c *counter) Add(v float64) {
+	if v > 0 {
+		c.add(v)
+	}
+}
+
+func (c *counter) Inc() {
+	c.add(1)
+}
+
+type gauge struct {
+	atomicFloat
+}
+
+func (g *gauge) Set(v float64) {
+	g.store(v)
+}
+
+func (g *gauge) Add(v float64) {
+	g.add(v)
+}
+
+type histogram struct {
+	sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) Observe(v float64) {
+	h.Lock()
+	defer h.Unlock()
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// family is all series of a metric name
+type family struct {
+	kind    string
+	help    string
+	buckets []float64
+	// series by label string
+	series map[string]interface{}
+}
+
+// Prometheus is a registry serving metrics in Prometheus text exposition format.
+// Registering the same name with a different kind or buckets panics, as it's a programming error
+type Prometheus struct {
+	sync.Mutex
+	families map[string]*family
+}
+
+// NewPrometheus returns empty Prometheus registry
+func NewPrometheus() *Prometheus {
+	return &Prometheus{families: map[string]*family{}}
+}
+
+// escapeLabel escapes label value as required by the text format
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// labelString returns labels sorted by name in text format, like {a="1",b="2"}
+func labelString(labels Labels) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+`="`+escapeLabel(labels[k])+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func equalBuckets(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns series of the name and labels, creating it with create if needed
+func (p *Prometheus) get(name, help, kind string, buckets []float64, labels Labels, create func() interface{}) interface{} {
+	p.Lock()
+	defer p.Unlock()
+	f, found := p.families[name]
+	if !found {
+		f = &family{kind: kind, help: help, buckets: buckets, series: map[string]interface{}{}}
+		p.families[name] = f
+	}
+	if f.kind != kind || !equalBuckets(f.buckets, buckets) {
+		panic(fmt.Sprintf("metric %s is already registered as %s with buckets %v", name, f.kind, f.buckets))
+	}
+	key := labelString(labels)
+	s, found := f.series[key]
+	if !found {
+		s = create()
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter returns counter of the name and labels
+func (p *Prometheus) Counter(name, help string, labels Labels) Counter {
+	return p.get(name, help, kindCounter, nil, labels, func() interface{} { return &counter{} }).(*counter)
+}
+
+// Gauge returns gauge of the name and labels
+func (p *Prometheus) Gauge(name, help string, labels Labels) Gauge {
+	return p.get(name, help, kindGauge, nil, labels, func() interface{} { return &gauge{} }).(*gauge)
+}
+
+// Histogram returns histogram of the name and labels
+func (p *Prometheus) Histogram(name, help string, buckets []float64, labels Labels) Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of metric %s are not sorted: %v", name, buckets))
+	}
+	return p.get(name, help, kindHistogram, buckets, labels, func() interface{} {
+		return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	}).(*histogram)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// withLabel returns label string with extra label appended, for histogram buckets
+func withLabel(key, extra string) string {
+	if key == "" {
+		return "{" + extra + "}"
+	}
+	return key[:len(key)-1] + "," + extra + "}"
+}
+
+// WritePrometheus writes all metrics sorted by name and labels in Prometheus text exposition format
+func (p *Prometheus) WritePrometheus(w io.Writer) error {
+	p.Lock()
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	p.Unlock()
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		p.Lock()
+		f := p.families[name]
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		series := make([]interface{}, len(keys))
+		sort.Strings(keys)
+		for i, k := range keys {
+			series[i] = f.series[k]
+		}
+		p.Unlock()
+
+		if f.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", name, strings.ReplaceAll(f.help, "\n", " "))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, f.kind)
+		for i, key := range keys {
+			switch s := series[i].(type) {
+			case *counter:
+				fmt.Fprintf(bw, "%s%s %s\n", name, key, formatFloat(s.load()))
+			case *gauge:
+				fmt.Fprintf(bw, "%s%s %s\n", name, key, formatFloat(s.load()))
+			case *histogram:
+				s.Lock()
+				for j, b := range s.buckets {
+					fmt.Fprintf(bw, "%s_bucket%s %d\n", name, withLabel(key, `le="`+formatFloat(b)+`"`), s.counts[j])
+				}
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", name, withLabel(key, `le="+Inf"`), s.count)
+				fmt.Fprintf(bw, "%s_sum%s %s\n", name, key, formatFloat(s.sum))
+				fmt.Fprintf(bw, "%s_count%s %d\n", name, key, s.count)
+				s.Unlock()
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves Prometheus scrapes
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := p.WritePrometheus(w); err != nil {
+		log.Errorf("Failed to reply: %v", err)
+	}
+}
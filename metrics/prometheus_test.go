@@ -0,0 +1,112 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusWrite(t *testing.T) {
+	p := NewPrometheus()
+	p.Counter("requests_total", "Requests served", Labels{"source": "b"}).Inc()
+	c := p.Counter("requests_total", "Requests served", Labels{"source": "a"})
+	c.Add(2)
+	c.Add(-1)
+	p.Counter("requests_total", "Requests served", Labels{"source": "a"}).Inc()
+	g := p.Gauge("temperature", "", Labels{"model": `sa"5x\`})
+	g.Set(42.5)
+	g.Add(-0.5)
+	p.Gauge("inf", "", nil).Set(math.Inf(1))
+	h := p.Histogram("latency_seconds", "Latency\nof requests", []float64{0.1, 1}, nil)
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WritePrometheus(&b))
+	require.Equal(t, `# TYPE inf gauge
+inf +Inf
+# HELP latency_seconds Latency of requests
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+# HELP requests_total Requests served
+# TYPE requests_total counter
+requests_total{source="a"} 3
+requests_total{source="b"} 1
+# TYPE temperature gauge
+temperature{model="sa\"5x\\"} 42
+`, b.String())
+}
+
+func TestPrometheusHistogramLabels(t *testing.T) {
+	p := NewPrometheus()
+	p.Histogram("latency_seconds", "", []float64{1}, Labels{"source": "a"}).Observe(2)
+	var b bytes.Buffer
+	require.NoError(t, p.WritePrometheus(&b))
+	require.Contains(t, b.String(), `latency_seconds_bucket{source="a",le="1"} 0`)
+	require.Contains(t, b.String(), `latency_seconds_bucket{source="a",le="+Inf"} 1`)
+	require.Contains(t, b.String(), `latency_seconds_count{source="a"} 1`)
+}
+
+func TestPrometheusConflicts(t *testing.T) {
+	p := NewPrometheus()
+	p.Counter("requests_total", "", nil)
+	require.Panics(t, func() { p.Gauge("requests_total", "", nil) })
+	p.Histogram("latency_seconds", "", []float64{1}, nil)
+	require.Panics(t, func() { p.Histogram("latency_seconds", "", []float64{2}, nil) })
+	require.Panics(t, func() { p.Histogram("other_seconds", "", []float64{2, 1}, nil) })
+}
+
+func TestPrometheusConcurrent(t *testing.T) {
+	p := NewPrometheus()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				p.Counter("requests_total", "", nil).Inc()
+				p.Gauge("in_flight", "", nil).Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	var b bytes.Buffer
+	require.NoError(t, p.WritePrometheus(&b))
+	require.Contains(t, b.String(), "requests_total 1000\n")
+	require.Contains(t, b.String(), "in_flight 1000\n")
+}
+
+func TestPrometheusServeHTTP(t *testing.T) {
+	p := NewPrometheus()
+	p.Counter("requests_total", "", nil).Inc()
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, "text/plain; version=0.0.4", rec.Header().Get("Content-Type"))
+	require.Equal(t, "# TYPE requests_total counter\nrequests_total 1\n", rec.Body.String())
+}
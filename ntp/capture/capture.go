@@ -0,0 +1,191 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package capture reads NTP packets from pcap and pcapng files and pairs requests with responses,
+computing offsets and delays offline from capture timestamps.
+*/
+package capture
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// NTP modes of packets which are paired
+const (
+	modeClient = 3
+	modeServer = 4
+)
+
+// Packet is an NTP packet decoded from a capture
+type Packet struct {
+	// Time is the capture timestamp
+	Time time.Time
+	Src  *net.UDPAddr
+	Dst  *net.UDPAddr
+	NTP  *ntp.Packet
+}
+
+// Handle abstracts packet handles provided by pcapgo.Reader and pcapgo.NgReader
+type Handle interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+// Read decodes NTP packets sent from or to the port from pcap or pcapng data
+func Read(r io.ReadSeeker, port int) ([]*Packet, error) {
+	// try NgReader, if it fails - fall back to Reader
+	var handle Handle
+	handle, err := pcapgo.NewNgReader(r, pcapgo.DefaultNgReaderOptions)
+	if err != nil {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seeking: %w", err)
+		}
+		if handle, err = pcapgo.NewReader(r); err != nil {
+			return nil, fmt.Errorf("decoding capture: %w", err)
+		}
+	}
+	packets := []*Packet{}
+	for {
+		data, ci, err := handle.ReadPacketData()
+		if err == io.EOF {
+			return packets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading packet %d: %w", len(packets), err)
+		}
+		if p := decode(data, handle.LinkType(), port); p != nil {
+			p.Time = ci.Timestamp
+			packets = append(packets, p)
+		}
+	}
+}
+
+// ReadFile decodes NTP packets sent from or to the port from pcap or pcapng file
+func ReadFile(path string, port int) ([]*Packet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, port)
+}
+
+// decode returns NTP packet from the frame, nil if there is none
+func decode(data []byte, linkType layers.LinkType, port int) *Packet {
+	packet := gopacket.NewPacket(data, linkType, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if !ok || (int(udp.SrcPort) != port && int(udp.DstPort) != port) {
+		return nil
+	}
+	if len(udp.Payload) < ntp.PacketSizeBytes {
+		return nil
+	}
+	var srcIP, dstIP net.IP
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		srcIP, dstIP = ip.SrcIP, ip.DstIP
+	case *layers.IPv6:
+		srcIP, dstIP = ip.SrcIP, ip.DstIP
+	default:
+		return nil
+	}
+	p, err := ntp.BytesToPacket(udp.Payload[:ntp.PacketSizeBytes])
+	if err != nil {
+		return nil
+	}
+	return &Packet{
+		Src: &net.UDPAddr{IP: srcIP, Port: int(udp.SrcPort)},
+		Dst: &net.UDPAddr{IP: dstIP, Port: int(udp.DstPort)},
+		NTP: p,
+	}
+}
+
+// Exchange is a client request paired with the server response.
+// Offset and delay are computed with capture timestamps in place of client timestamps,
+// as clients such as chrony randomize the transmit timestamp.
+// They are accurate when the capture is taken on the client or close to it
+type Exchange struct {
+	Request  *Packet
+	Response *Packet
+	// Offset of the server clock relative to the capture clock
+	Offset time.Duration
+	// Delay is the round trip delay without server processing time
+	Delay time.Duration
+	// ServerTime is the time server took to process the request
+	ServerTime time.Duration
+}
+
+func newExchange(req, resp *Packet) *Exchange {
+	t1 := req.Time
+	t2 := ntp.Unix(resp.NTP.RxTimeSec, resp.NTP.RxTimeFrac)
+	t3 := ntp.Unix(resp.NTP.TxTimeSec, resp.NTP.TxTimeFrac)
+	t4 := resp.Time
+	return &Exchange{
+		Request:    req,
+		Response:   resp,
+		Offset:     (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:      t4.Sub(t1) - t3.Sub(t2),
+		ServerTime: t3.Sub(t2),
+	}
+}
+
+type exchangeKey struct {
+	client string
+	server string
+	txSec  uint32
+	txFrac uint32
+}
+
+// Pair matches responses to requests by addresses and origin timestamp.
+// Requests without responses are returned separately, responses without requests are ignored.
+// Both lists are sorted by capture time
+func Pair(packets []*Packet) ([]*Exchange, []*Packet) {
+	requests := map[exchangeKey]*Packet{}
+	exchanges := []*Exchange{}
+	for _, p := range packets {
+		switch p.NTP.Mode() {
+		case modeClient:
+			requests[exchangeKey{p.Src.String(), p.Dst.String(), p.NTP.TxTimeSec, p.NTP.TxTimeFrac}] = p
+		case modeServer:
+			k := exchangeKey{p.Dst.String(), p.Src.String(), p.NTP.OrigTimeSec, p.NTP.OrigTimeFrac}
+			req, ok := requests[k]
+			if !ok {
+				continue
+			}
+			delete(requests, k)
+			exchanges = append(exchanges, newExchange(req, p))
+		}
+	}
+	unanswered := make([]*Packet, 0, len(requests))
+	for _, p := range requests {
+		unanswered = append(unanswered, p)
+	}
+	sort.Slice(exchanges, func(i, j int) bool { return exchanges[i].Request.Time.Before(exchanges[j].Request.Time) })
+	sort.Slice(unanswered, func(i, j int) bool { return unanswered[i].Time.Before(unanswered[j].Time) })
+	return exchanges, unanswered
+}
@@ -0,0 +1,144 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capture
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+var (
+	clientIP = net.ParseIP("192.0.2.1").To4()
+	serverIP = net.ParseIP("192.0.2.2").To4()
+)
+
+func frame(t *testing.T, src, dst net.IP, srcPort, dstPort int, payload []byte) []byte {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: src, DstIP: dst}
+	udp := &layers.UDP{SrcPort: layers.UDPPort(srcPort), DstPort: layers.UDPPort(dstPort)}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, eth, ip, udp, gopacket.Payload(payload)))
+	return buf.Bytes()
+}
+
+func ntpBytes(t *testing.T, p *ntp.Packet) []byte {
+	b, err := p.Bytes()
+	require.NoError(t, err)
+	return b
+}
+
+type capturedFrame struct {
+	at   time.Time
+	data []byte
+}
+
+func writePcap(t *testing.T, frames []capturedFrame) []byte {
+	var buf bytes.Buffer
+	w := pcapgo.NewWriterNanos(&buf)
+	require.NoError(t, w.WriteFileHeader(65536, layers.LinkTypeEthernet))
+	for _, f := range frames {
+		ci := gopacket.CaptureInfo{Timestamp: f.at, CaptureLength: len(f.data), Length: len(f.data)}
+		require.NoError(t, w.WritePacket(ci, f.data))
+	}
+	return buf.Bytes()
+}
+
+// testFrames returns two requests with one answered by a server which is 1ms ahead, and some noise
+func testFrames(t *testing.T) []capturedFrame {
+	start := time.Unix(1650000000, 0)
+	req := &ntp.Packet{Settings: 0x23}
+	req.TxTimeSec, req.TxTimeFrac = 1, 2
+	resp := &ntp.Packet{Settings: 0x24, Stratum: 1, OrigTimeSec: 1, OrigTimeFrac: 2}
+	// 100us to the server, 10us to process, 100us back
+	resp.RxTimeSec, resp.RxTimeFrac = ntp.Time(start.Add(time.Millisecond + 100*time.Microsecond))
+	resp.TxTimeSec, resp.TxTimeFrac = ntp.Time(start.Add(time.Millisecond + 110*time.Microsecond))
+	lost := &ntp.Packet{Settings: 0x23, TxTimeSec: 3, TxTimeFrac: 4}
+	return []capturedFrame{
+		{start, frame(t, clientIP, serverIP, 40000, 123, ntpBytes(t, req))},
+		// not NTP
+		{start.Add(10 * time.Microsecond), frame(t, clientIP, serverIP, 40000, 53, ntpBytes(t, req))},
+		// too short
+		{start.Add(20 * time.Microsecond), frame(t, clientIP, serverIP, 40000, 123, []byte{1, 2, 3})},
+		{start.Add(30 * time.Microsecond), frame(t, clientIP, serverIP, 40001, 123, ntpBytes(t, lost))},
+		{start.Add(210 * time.Microsecond), frame(t, serverIP, clientIP, 123, 40000, ntpBytes(t, resp))},
+	}
+}
+
+func TestReadAndPair(t *testing.T) {
+	data := writePcap(t, testFrames(t))
+	packets, err := Read(bytes.NewReader(data), 123)
+	require.NoError(t, err)
+	require.Len(t, packets, 3)
+	require.Equal(t, "192.0.2.1:40000", packets[0].Src.String())
+	require.Equal(t, "192.0.2.2:123", packets[0].Dst.String())
+	require.Equal(t, uint8(3), packets[0].NTP.Mode())
+
+	exchanges, unanswered := Pair(packets)
+	require.Len(t, exchanges, 1)
+	require.Len(t, unanswered, 1)
+	require.Equal(t, "192.0.2.1:40001", unanswered[0].Src.String())
+
+	e := exchanges[0]
+	require.Equal(t, uint8(1), e.Response.NTP.Stratum)
+	require.InDelta(t, float64(time.Millisecond), float64(e.Offset), 10)
+	require.InDelta(t, float64(200*time.Microsecond), float64(e.Delay), 10)
+	require.InDelta(t, float64(10*time.Microsecond), float64(e.ServerTime), 10)
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ntp.pcap")
+	require.NoError(t, ioutil.WriteFile(path, writePcap(t, testFrames(t)), 0644))
+	packets, err := ReadFile(path, 123)
+	require.NoError(t, err)
+	require.Len(t, packets, 3)
+
+	_, err = ReadFile(filepath.Join(t.TempDir(), "missing.pcap"), 123)
+	require.Error(t, err)
+}
+
+func TestReadGarbage(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("not a capture at all")), 123)
+	require.Error(t, err)
+}
+
+func TestPairUnsolicited(t *testing.T) {
+	resp := &Packet{
+		Src: &net.UDPAddr{IP: serverIP, Port: 123},
+		Dst: &net.UDPAddr{IP: clientIP, Port: 40000},
+		NTP: &ntp.Packet{Settings: 0x24, OrigTimeSec: 1},
+	}
+	exchanges, unanswered := Pair([]*Packet{resp})
+	require.Empty(t, exchanges)
+	require.Empty(t, unanswered)
+}
@@ -0,0 +1,239 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capture
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// DefaultPendingTimeout is how long a request waits for its response before it's counted as unanswered
+const DefaultPendingTimeout = 5 * time.Second
+
+// DefaultMaxPeers limits the number of client/server pairs tracked, so spoofed sources can't exhaust memory
+const DefaultMaxPeers = 100000
+
+// DefaultPeerIdleTimeout is how long a client/server pair without traffic is tracked
+const DefaultPeerIdleTimeout = 10 * time.Minute
+
+// PeerStats are statistics of NTP traffic between a client and a server
+type PeerStats struct {
+	Client     string    `json:"client"`
+	Server     string    `json:"server"`
+	Version    uint8     `json:"version"`
+	Stratum    uint8     `json:"stratum"`
+	Requests   uint64    `json:"requests"`
+	Responses  uint64    `json:"responses"`
+	Unanswered uint64    `json:"unanswered"`
+	First      time.Time `json:"first"`
+	Last       time.Time `json:"last"`
+	// Offset and Delay are of the last exchange
+	Offset   time.Duration `json:"offset_ns"`
+	Delay    time.Duration `json:"delay_ns"`
+	MinDelay time.Duration `json:"min_delay_ns"`
+	MaxDelay time.Duration `json:"max_delay_ns"`
+}
+
+type peerKey struct {
+	client string
+	server string
+}
+
+// Monitor passively observes NTP traffic and keeps per client/server statistics without sending any packets.
+// Offsets and delays are relative to the clock of the monitoring host, so they are only meaningful
+// when it's on the path close to clients
+type Monitor struct {
+	Port           int
+	PendingTimeout time.Duration
+	// MaxPeers limits the number of client/server pairs tracked, new ones are dropped above it. 0 is unlimited
+	MaxPeers int
+	// PeerIdleTimeout is how long a client/server pair without traffic is tracked. 0 keeps them forever
+	PeerIdleTimeout time.Duration
+
+	sync.Mutex
+	peers   map[peerKey]*PeerStats
+	pending map[exchangeKey]*Packet
+	// dropped is the number of client/server pairs not tracked because of MaxPeers or expired as idle
+	dropped uint64
+	// now is the latest capture timestamp seen
+	now time.Time
+	// pruned is when pending requests were last pruned
+	pruned time.Time
+}
+
+// NewMonitor returns a new Monitor of NTP traffic on the port
+func NewMonitor(port int) *Monitor {
+	return &Monitor{
+		Port:            port,
+		PendingTimeout:  DefaultPendingTimeout,
+		MaxPeers:        DefaultMaxPeers,
+		PeerIdleTimeout: DefaultPeerIdleTimeout,
+		peers:           map[peerKey]*PeerStats{},
+		pending:         map[exchangeKey]*Packet{},
+	}
+}
+
+// Run reads packets from the source until the context is cancelled or the source is exhausted.
+// Source is provided by the caller, such as pcapgo.EthernetHandle for live capture
+func (m *Monitor) Run(ctx context.Context, source gopacket.PacketDataSource, linkType layers.LinkType) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		data, ci, err := source.ReadPacketData()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// closing the source is a way to interrupt a blocking read
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if p := decode(data, linkType, m.Port); p != nil {
+			p.Time = ci.Timestamp
+			m.Observe(p)
+		}
+	}
+}
+
+// peer returns stats of the client/server pair, nil if it's not tracked because of MaxPeers
+func (m *Monitor) peer(client, server string, t time.Time) *PeerStats {
+	k := peerKey{client: client, server: server}
+	s, ok := m.peers[k]
+	if !ok {
+		if m.MaxPeers > 0 && len(m.peers) >= m.MaxPeers {
+			m.dropped++
+			return nil
+		}
+		s = &PeerStats{Client: client, Server: server, First: t}
+		m.peers[k] = s
+	}
+	s.Last = t
+	return s
+}
+
+// Observe accounts the packet
+func (m *Monitor) Observe(p *Packet) {
+	m.Lock()
+	defer m.Unlock()
+	if p.Time.After(m.now) {
+		m.now = p.Time
+	}
+	// make room for new pairs first
+	m.prune()
+	switch p.NTP.Mode() {
+	case modeClient:
+		s := m.peer(p.Src.IP.String(), p.Dst.IP.String(), p.Time)
+		if s == nil {
+			break
+		}
+		s.Requests++
+		s.Version = p.NTP.Version()
+		m.pending[exchangeKey{p.Src.String(), p.Dst.String(), p.NTP.TxTimeSec, p.NTP.TxTimeFrac}] = p
+	case modeServer:
+		k := exchangeKey{p.Dst.String(), p.Src.String(), p.NTP.OrigTimeSec, p.NTP.OrigTimeFrac}
+		req, ok := m.pending[k]
+		if !ok {
+			break
+		}
+		delete(m.pending, k)
+		e := newExchange(req, p)
+		s := m.peer(p.Dst.IP.String(), p.Src.IP.String(), p.Time)
+		if s == nil {
+			break
+		}
+		s.Responses++
+		s.Stratum = p.NTP.Stratum
+		s.Offset = e.Offset
+		s.Delay = e.Delay
+		if s.Responses == 1 || e.Delay < s.MinDelay {
+			s.MinDelay = e.Delay
+		}
+		if e.Delay > s.MaxDelay {
+			s.MaxDelay = e.Delay
+		}
+	}
+}
+
+// prune counts requests pending for longer than the timeout as unanswered and forgets idle client/server pairs.
+// Called with the lock held
+func (m *Monitor) prune() {
+	if m.now.Sub(m.pruned) < m.PendingTimeout {
+		return
+	}
+	m.pruned = m.now
+	for k, req := range m.pending {
+		if m.now.Sub(req.Time) < m.PendingTimeout {
+			continue
+		}
+		delete(m.pending, k)
+		// stats are created by the request
+		if s, ok := m.peers[peerKey{client: req.Src.IP.String(), server: req.Dst.IP.String()}]; ok {
+			s.Unanswered++
+		}
+	}
+	if m.PeerIdleTimeout <= 0 {
+		return
+	}
+	for k, s := range m.peers {
+		if m.now.Sub(s.Last) > m.PeerIdleTimeout {
+			delete(m.peers, k)
+			m.dropped++
+		}
+	}
+}
+
+// Stats returns copies of per client/server statistics sorted by server and client
+func (m *Monitor) Stats() []PeerStats {
+	m.Lock()
+	defer m.Unlock()
+	stats := make([]PeerStats, 0, len(m.peers))
+	for _, s := range m.peers {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Server != stats[j].Server {
+			return stats[i].Server < stats[j].Server
+		}
+		return stats[i].Client < stats[j].Client
+	})
+	return stats
+}
+
+// Dropped returns the number of client/server pairs not tracked because of MaxPeers or forgotten as idle
+func (m *Monitor) Dropped() uint64 {
+	m.Lock()
+	defer m.Unlock()
+	return m.dropped
+}
+
+// Pending returns the number of requests waiting for responses
+func (m *Monitor) Pending() int {
+	m.Lock()
+	defer m.Unlock()
+	return len(m.pending)
+}
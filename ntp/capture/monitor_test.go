@@ -0,0 +1,116 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capture
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorRun(t *testing.T) {
+	r, err := pcapgo.NewReader(bytes.NewReader(writePcap(t, testFrames(t))))
+	require.NoError(t, err)
+	m := NewMonitor(123)
+	require.NoError(t, m.Run(context.Background(), r, layers.LinkTypeEthernet))
+
+	stats := m.Stats()
+	require.Len(t, stats, 1)
+	s := stats[0]
+	require.Equal(t, "192.0.2.1", s.Client)
+	require.Equal(t, "192.0.2.2", s.Server)
+	require.Equal(t, uint8(4), s.Version)
+	require.Equal(t, uint8(1), s.Stratum)
+	require.Equal(t, uint64(2), s.Requests)
+	require.Equal(t, uint64(1), s.Responses)
+	require.Equal(t, uint64(0), s.Unanswered)
+	require.InDelta(t, float64(time.Millisecond), float64(s.Offset), 10)
+	require.InDelta(t, float64(200*time.Microsecond), float64(s.MinDelay), 10)
+	require.Equal(t, s.MinDelay, s.MaxDelay)
+	require.True(t, time.Unix(1650000000, 0).Equal(s.First))
+	require.True(t, time.Unix(1650000000, 0).Add(210*time.Microsecond).Equal(s.Last))
+	// request from port 40001 is still waiting
+	require.Equal(t, 1, m.Pending())
+}
+
+func TestMonitorUnanswered(t *testing.T) {
+	r, err := pcapgo.NewReader(bytes.NewReader(writePcap(t, testFrames(t))))
+	require.NoError(t, err)
+	m := NewMonitor(123)
+	require.NoError(t, m.Run(context.Background(), r, layers.LinkTypeEthernet))
+
+	packets, err := Read(bytes.NewReader(writePcap(t, testFrames(t))), 123)
+	require.NoError(t, err)
+	// same request seen much later
+	later := *packets[0]
+	later.Time = later.Time.Add(time.Minute)
+	m.Observe(&later)
+
+	s := m.Stats()[0]
+	require.Equal(t, uint64(3), s.Requests)
+	require.Equal(t, uint64(1), s.Unanswered)
+	require.Equal(t, later.Time, s.Last)
+	require.Equal(t, 1, m.Pending())
+}
+
+func TestMonitorPeersLimit(t *testing.T) {
+	packets, err := Read(bytes.NewReader(writePcap(t, testFrames(t))), 123)
+	require.NoError(t, err)
+	m := NewMonitor(123)
+	m.MaxPeers = 2
+	for _, p := range packets {
+		m.Observe(p)
+	}
+
+	request := func(client string, at time.Time) *Packet {
+		p := *packets[0]
+		p.Src = &net.UDPAddr{IP: net.ParseIP(client), Port: 40000}
+		p.Time = at
+		return &p
+	}
+	start := packets[0].Time
+	m.Observe(request("192.0.2.10", start))
+	// above the limit new pairs are dropped
+	m.Observe(request("192.0.2.11", start))
+	require.Len(t, m.Stats(), 2)
+	require.Equal(t, uint64(1), m.Dropped())
+
+	// idle pairs are forgotten, making room for new ones
+	m.Observe(request("192.0.2.10", start.Add(DefaultPeerIdleTimeout)))
+	m.Observe(request("192.0.2.11", start.Add(DefaultPeerIdleTimeout+DefaultPendingTimeout)))
+	stats := m.Stats()
+	require.Len(t, stats, 2)
+	require.Equal(t, "192.0.2.10", stats[0].Client)
+	require.Equal(t, "192.0.2.11", stats[1].Client)
+	require.Equal(t, uint64(2), m.Dropped())
+}
+
+func TestMonitorRunCancelled(t *testing.T) {
+	r, err := pcapgo.NewReader(bytes.NewReader(writePcap(t, testFrames(t))))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := NewMonitor(123)
+	require.ErrorIs(t, m.Run(ctx, r, layers.LinkTypeEthernet), context.Canceled)
+	require.Empty(t, m.Stats())
+}
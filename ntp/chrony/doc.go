@@ -17,7 +17,8 @@ limitations under the License.
 /*
 Package chrony implements Chrony (https://chrony.tuxfamily.org) network protocol v6 used for monitoring of the timeserver.
 
-As of now, only monitoring part of protocol that is used to communicate between `chronyc` and `chronyd` is implemented.
+As of now, monitoring part of protocol that is used to communicate between `chronyc` and `chronyd` is implemented,
+along with a few commands (online, offline, burst, makestep). Commands are only accepted by chronyd over the unix socket (ChronySocketPath).
 Chronyc/chronyd protocol is not documented (https://chrony.tuxfamily.org/faq.html#_is_the_code_chronyc_code_code_chronyd_code_protocol_documented_anywhere).
 
 Library allows communicating with Chrony NTP server,
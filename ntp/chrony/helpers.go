@@ -61,8 +61,9 @@ func (ip *ipAddr) ToNetIP() net.IP {
 
 func newIPAddr(ip net.IP) *ipAddr {
 	family := ipAddrInet6
-	if ip.To4() != nil {
+	if ip4 := ip.To4(); ip4 != nil {
 		family = ipAddrInet4
+		ip = ip4
 	}
 	var nIP [16]byte
 	copy(nIP[:], ip)
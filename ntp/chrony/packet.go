@@ -70,16 +70,21 @@ func (t PacketType) String() string {
 
 // request types. Only those we suppor, there are more
 const (
+	reqOnline      CommandType = 1
+	reqOffline     CommandType = 2
+	reqBurst       CommandType = 3
 	reqNSources    CommandType = 14
 	reqSourceData  CommandType = 15
 	reqTracking    CommandType = 33
 	reqSourceStats CommandType = 34
+	reqMakeStep    CommandType = 43
 	reqServerStats CommandType = 54
 	reqNTPData     CommandType = 57
 )
 
 // reply types
 const (
+	rpyNull         ReplyType = 1
 	rpyNSources     ReplyType = 2
 	rpySourceData   ReplyType = 3
 	rpyTracking     ReplyType = 5
@@ -280,6 +285,43 @@ type RequestSourceStats struct {
 	data [maxDataLen - 4]uint8 //nolint:unused,structcheck
 }
 
+// RequestOnline - packet to set sources in the subnet online.
+// Command packets are only accepted by chronyd over the unix socket
+type RequestOnline struct {
+	RequestHead
+	Mask    ipAddr
+	Address ipAddr
+	EOR     int32
+	data    [maxDataLen - 44]uint8 //nolint:unused,structcheck
+}
+
+// RequestOffline - packet to set sources in the subnet offline
+type RequestOffline struct {
+	RequestHead
+	Mask    ipAddr
+	Address ipAddr
+	EOR     int32
+	data    [maxDataLen - 44]uint8 //nolint:unused,structcheck
+}
+
+// RequestBurst - packet to start a burst of measurements of sources in the subnet
+type RequestBurst struct {
+	RequestHead
+	Mask          ipAddr
+	Address       ipAddr
+	NGoodSamples  int32
+	NTotalSamples int32
+	EOR           int32
+	data          [maxDataLen - 52]uint8 //nolint:unused,structcheck
+}
+
+// RequestMakeStep - packet to step the clock immediately
+type RequestMakeStep struct {
+	RequestHead
+	// we actually need this to send proper packet
+	data [maxDataLen]uint8 //nolint:unused,structcheck
+}
+
 // ReplyHead is the first (common) part of the reply packet,
 // in a format that can be directly passed to binary.Read
 type ReplyHead struct {
@@ -605,6 +647,11 @@ type ReplyServerStats2 struct {
 	ServerStats2
 }
 
+// ReplyNull is a reply to commands which don't return any data
+type ReplyNull struct {
+	ReplyHead
+}
+
 // here go request constuctors
 
 // NewSourcesPacket creates new packet to request number of sources (peers)
@@ -676,6 +723,77 @@ func NewServerStatsPacket() *RequestServerStats {
 	}
 }
 
+// newSubnet returns mask and address of the subnet. Nil subnet means all sources
+func newSubnet(subnet *net.IPNet) (mask ipAddr, address ipAddr) {
+	if subnet == nil {
+		return mask, address
+	}
+	address = *newIPAddr(subnet.IP)
+	mask.Family = address.Family
+	m := subnet.Mask
+	if address.Family == ipAddrInet4 && len(m) == net.IPv6len {
+		m = m[12:]
+	}
+	copy(mask.IP[:], m)
+	return mask, address
+}
+
+// NewOnlinePacket creates new packet to set sources in the subnet online. Nil subnet means all sources
+func NewOnlinePacket(subnet *net.IPNet) *RequestOnline {
+	mask, address := newSubnet(subnet)
+	return &RequestOnline{
+		RequestHead: RequestHead{
+			Version: protoVersionNumber,
+			PKTType: pktTypeCmdRequest,
+			Command: reqOnline,
+		},
+		Mask:    mask,
+		Address: address,
+	}
+}
+
+// NewOfflinePacket creates new packet to set sources in the subnet offline. Nil subnet means all sources
+func NewOfflinePacket(subnet *net.IPNet) *RequestOffline {
+	mask, address := newSubnet(subnet)
+	return &RequestOffline{
+		RequestHead: RequestHead{
+			Version: protoVersionNumber,
+			PKTType: pktTypeCmdRequest,
+			Command: reqOffline,
+		},
+		Mask:    mask,
+		Address: address,
+	}
+}
+
+// NewBurstPacket creates new packet to make a burst of measurements of sources in the subnet.
+// Burst ends after goodSamples good or totalSamples measurements. Nil subnet means all sources
+func NewBurstPacket(subnet *net.IPNet, goodSamples, totalSamples int32) *RequestBurst {
+	mask, address := newSubnet(subnet)
+	return &RequestBurst{
+		RequestHead: RequestHead{
+			Version: protoVersionNumber,
+			PKTType: pktTypeCmdRequest,
+			Command: reqBurst,
+		},
+		Mask:          mask,
+		Address:       address,
+		NGoodSamples:  goodSamples,
+		NTotalSamples: totalSamples,
+	}
+}
+
+// NewMakeStepPacket creates new packet to step the clock by the current offset instead of slewing
+func NewMakeStepPacket() *RequestMakeStep {
+	return &RequestMakeStep{
+		RequestHead: RequestHead{
+			Version: protoVersionNumber,
+			PKTType: pktTypeCmdRequest,
+			Command: reqMakeStep,
+		},
+	}
+}
+
 // decodePacket decodes bytes to valid response packet
 func decodePacket(response []byte) (ResponsePacket, error) {
 	var err error
@@ -689,6 +807,10 @@ func decodePacket(response []byte) (ResponsePacket, error) {
 		return nil, fmt.Errorf("got status %s (%d)", head.Status, head.Status)
 	}
 	switch head.Reply {
+	case rpyNull:
+		return &ReplyNull{
+			ReplyHead: *head,
+		}, nil
 	case rpyNSources:
 		data := new(replySourcesContent)
 		if err = binary.Read(r, binary.BigEndian, data); err != nil {
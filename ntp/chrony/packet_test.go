@@ -17,6 +17,7 @@ limitations under the License.
 package chrony
 
 import (
+	"encoding/binary"
 	"net"
 	"testing"
 	"time"
@@ -326,3 +327,69 @@ func TestDecodeNTPData(t *testing.T) {
 	}
 	require.Equal(t, want, packet)
 }
+
+func TestDecodeNull(t *testing.T) {
+	raw := []uint8{
+		0x06, 0x02, 0x00, 0x00, 0x00, 0x2b, 0x00, 0x01, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x1a, 0x5e, 0x01,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	packet, err := decodePacket(raw)
+	require.Nil(t, err)
+	want := &ReplyNull{
+		ReplyHead: ReplyHead{
+			Version:  protoVersionNumber,
+			PKTType:  pktTypeCmdReply,
+			Command:  reqMakeStep,
+			Reply:    rpyNull,
+			Status:   sttSuccess,
+			Sequence: 739925505,
+		},
+	}
+	require.Equal(t, want, packet)
+}
+
+func TestNewOnlinePacketSubnet(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("192.168.0.0/24")
+	require.Nil(t, err)
+	packet := NewOnlinePacket(subnet)
+	require.Equal(t, reqOnline, packet.Command)
+	require.Equal(t, ipAddrInet4, packet.Address.Family)
+	require.Equal(t, ipAddrInet4, packet.Mask.Family)
+	require.Equal(t, []uint8{192, 168, 0, 0}, packet.Address.IP[:4])
+	require.Equal(t, []uint8{255, 255, 255, 0}, packet.Mask.IP[:4])
+	require.Equal(t, net.IPv4(192, 168, 0, 0).To4(), packet.Address.ToNetIP())
+
+	// mask in 16-byte form must still land in the IPv4 part
+	subnet.Mask = net.CIDRMask(120, 128)
+	packet = NewOnlinePacket(subnet)
+	require.Equal(t, []uint8{255, 255, 255, 0}, packet.Mask.IP[:4])
+}
+
+func TestNewOfflinePacketAll(t *testing.T) {
+	packet := NewOfflinePacket(nil)
+	require.Equal(t, reqOffline, packet.Command)
+	require.Equal(t, ipAddr{}, packet.Mask)
+	require.Equal(t, ipAddr{}, packet.Address)
+}
+
+func TestNewBurstPacket(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("2401:db00::/32")
+	require.Nil(t, err)
+	packet := NewBurstPacket(subnet, 2, 4)
+	require.Equal(t, reqBurst, packet.Command)
+	require.Equal(t, ipAddrInet6, packet.Address.Family)
+	require.Equal(t, ipAddrInet6, packet.Mask.Family)
+	require.Equal(t, []uint8{0xff, 0xff, 0xff, 0xff, 0x00}, packet.Mask.IP[:5])
+	require.Equal(t, int32(2), packet.NGoodSamples)
+	require.Equal(t, int32(4), packet.NTotalSamples)
+}
+
+func TestRequestPacketSizes(t *testing.T) {
+	// all requests must be padded to the same length as replies
+	want := binary.Size(NewTrackingPacket())
+	require.Equal(t, want, binary.Size(NewOnlinePacket(nil)))
+	require.Equal(t, want, binary.Size(NewOfflinePacket(nil)))
+	require.Equal(t, want, binary.Size(NewBurstPacket(nil, 1, 1)))
+	require.Equal(t, want, binary.Size(NewMakeStepPacket()))
+}
@@ -0,0 +1,388 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package conformance verifies NTP server behavior with a sequence of crafted queries:
+version handling, invalid request handling, timestamp sanity, Kiss-o'-Death and rate limiting.
+Every check passes, fails or is skipped if it can't be evaluated, and the report is scored by check weights.
+*/
+package conformance
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// Status of a check
+type Status string
+
+// Check statuses
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+var errNoResponse = errors.New("no response")
+
+// Config of the suite
+type Config struct {
+	// Timeout for every response
+	Timeout time.Duration
+	// MaxOffset is the max offset between server and local clock considered sane
+	MaxOffset time.Duration
+	// BurstSize is the number of requests sent at once to trigger rate limiting. Rate limiting is not checked if 0
+	BurstSize int
+}
+
+// DefaultConfig is suitable for servers in the same datacenter
+var DefaultConfig = Config{
+	Timeout:   time.Second,
+	MaxOffset: time.Second,
+	BurstSize: 50,
+}
+
+// Check is a single conformance check
+type Check struct {
+	Name        string
+	Description string
+	Weight      int
+	run         func(s *suite) (Status, string)
+}
+
+// Outcome of a check
+type Outcome struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Weight  int    `json:"weight"`
+	Details string `json:"details,omitempty"`
+}
+
+// Report of the suite run
+type Report struct {
+	Server   string     `json:"server"`
+	Time     time.Time  `json:"time"`
+	Outcomes []*Outcome `json:"outcomes"`
+	// Score is the percentage of weight of passed checks among evaluated ones
+	Score   float64 `json:"score"`
+	Passed  int     `json:"passed"`
+	Failed  int     `json:"failed"`
+	Skipped int     `json:"skipped"`
+}
+
+// suite is a run of checks against the server
+type suite struct {
+	server string
+	config *Config
+}
+
+// exchange is a response to a raw request
+type exchange struct {
+	response *ntp.Packet
+	// length of the response in bytes
+	length int
+	t1, t4 time.Time
+}
+
+// request returns client request of the version with random transmit timestamp
+func request(version uint8) *ntp.Packet {
+	p := &ntp.Packet{Settings: version<<3 | 3}
+	if err := p.SetRandomTransmitTime(); err != nil {
+		p.TxTimeSec, p.TxTimeFrac = ntp.Time(time.Now())
+	}
+	return p
+}
+
+func packetBytes(p *ntp.Packet) []byte {
+	b, _ := p.Bytes()
+	return b
+}
+
+// send sends raw request from a new socket and waits for the response
+func (s *suite) send(b []byte) (*exchange, error) {
+	conn, err := net.Dial("udp", s.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return s.sendConn(conn, b)
+}
+
+func (s *suite) sendConn(conn net.Conn, b []byte) (*exchange, error) {
+	if err := conn.SetDeadline(time.Now().Add(s.config.Timeout)); err != nil {
+		return nil, err
+	}
+	t1 := time.Now()
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, ntp.MaxPacketSizeBytes)
+	n, err := conn.Read(buf)
+	t4 := time.Now()
+	if err != nil {
+		var nerr net.Error
+		if errors.As(err, &nerr) && nerr.Timeout() {
+			return nil, errNoResponse
+		}
+		return nil, err
+	}
+	if n < ntp.PacketSizeBytes {
+		return &exchange{length: n, t1: t1, t4: t4}, nil
+	}
+	response, err := ntp.BytesToPacket(buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	return &exchange{response: response, length: n, t1: t1, t4: t4}, nil
+}
+
+func leap(p *ntp.Packet) uint8    { return p.Settings >> 6 }
+func version(p *ntp.Packet) uint8 { return (p.Settings >> 3) & 0x7 }
+func mode(p *ntp.Packet) uint8    { return p.Settings & 0x7 }
+
+// isKoD returns true if the packet is a Kiss-o'-Death
+func isKoD(p *ntp.Packet) bool {
+	return p.Stratum == 0 && leap(p) == 3
+}
+
+func kissCode(p *ntp.Packet) string {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, p.ReferenceID)
+	return string(b)
+}
+
+// Checks in the order they run. Rate limiting runs last so it doesn't affect other checks
+var Checks = []*Check{
+	{Name: "version4", Description: "NTPv4 request gets NTPv4 server response", Weight: 3, run: checkVersion(4)},
+	{Name: "version3", Description: "NTPv3 request gets NTPv3 server response", Weight: 2, run: checkVersion(3)},
+	{Name: "origin", Description: "transmit timestamp of the request is returned as origin timestamp", Weight: 3, run: checkOrigin},
+	{Name: "timestamps", Description: "receive and transmit timestamps are sane and close to local clock", Weight: 3, run: checkTimestamps},
+	{Name: "header", Description: "stratum, leap indicator and reference timestamp are valid for a synchronized server", Weight: 2, run: checkHeader},
+	{Name: "unknown-version", Description: "request with unsupported version is not answered as a valid response", Weight: 1, run: checkUnknownVersion},
+	{Name: "server-mode", Description: "packet in server mode is not answered", Weight: 1, run: checkServerMode},
+	{Name: "short-packet", Description: "truncated request is not answered", Weight: 1, run: checkShortPacket},
+	{Name: "ratelimit", Description: "burst of requests is rate limited with drops or well-formed Kiss-o'-Death", Weight: 2, run: checkRateLimit},
+}
+
+func checkVersion(v uint8) func(s *suite) (Status, string) {
+	return func(s *suite) (Status, string) {
+		e, err := s.send(packetBytes(request(v)))
+		if err != nil {
+			return StatusFail, err.Error()
+		}
+		if e.response == nil {
+			return StatusFail, fmt.Sprintf("response is %d bytes", e.length)
+		}
+		if version(e.response) != v || mode(e.response) != 4 {
+			return StatusFail, fmt.Sprintf("response version %d mode %d", version(e.response), mode(e.response))
+		}
+		return StatusPass, ""
+	}
+}
+
+func checkOrigin(s *suite) (Status, string) {
+	r := request(4)
+	e, err := s.send(packetBytes(r))
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	if e.response == nil {
+		return StatusFail, fmt.Sprintf("response is %d bytes", e.length)
+	}
+	if err := ntp.MatchOrigin(r, e.response); err != nil {
+		return StatusFail, err.Error()
+	}
+	return StatusPass, ""
+}
+
+func checkTimestamps(s *suite) (Status, string) {
+	e, err := s.send(packetBytes(request(4)))
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	if e.response == nil {
+		return StatusFail, fmt.Sprintf("response is %d bytes", e.length)
+	}
+	rx := ntp.Unix(e.response.RxTimeSec, e.response.RxTimeFrac)
+	tx := ntp.Unix(e.response.TxTimeSec, e.response.TxTimeFrac)
+	if tx.Before(rx) {
+		return StatusFail, fmt.Sprintf("transmit %s is before receive %s", tx, rx)
+	}
+	// server processing can't take longer than the whole exchange
+	if tx.Sub(rx) > e.t4.Sub(e.t1) {
+		return StatusFail, fmt.Sprintf("server processing %s is longer than round trip %s", tx.Sub(rx), e.t4.Sub(e.t1))
+	}
+	offset := (rx.Sub(e.t1) + tx.Sub(e.t4)) / 2
+	if offset > s.config.MaxOffset || offset < -s.config.MaxOffset {
+		return StatusFail, fmt.Sprintf("offset %s exceeds %s", offset, s.config.MaxOffset)
+	}
+	return StatusPass, fmt.Sprintf("offset %s", offset)
+}
+
+func checkHeader(s *suite) (Status, string) {
+	e, err := s.send(packetBytes(request(4)))
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	if e.response == nil {
+		return StatusFail, fmt.Sprintf("response is %d bytes", e.length)
+	}
+	p := e.response
+	if leap(p) == 3 {
+		return StatusFail, "leap indicator reports unsynchronized clock"
+	}
+	if p.Stratum < 1 || p.Stratum > 15 {
+		return StatusFail, fmt.Sprintf("stratum %d", p.Stratum)
+	}
+	ref := ntp.Unix(p.RefTimeSec, p.RefTimeFrac)
+	tx := ntp.Unix(p.TxTimeSec, p.TxTimeFrac)
+	if p.RefTimeSec != 0 && ref.After(tx) {
+		return StatusFail, fmt.Sprintf("reference time %s is after transmit %s", ref, tx)
+	}
+	return StatusPass, fmt.Sprintf("stratum %d", p.Stratum)
+}
+
+// expectNoResponse passes if invalid request is ignored
+func (s *suite) expectNoResponse(b []byte) (Status, string) {
+	e, err := s.send(b)
+	if errors.Is(err, errNoResponse) {
+		return StatusPass, ""
+	}
+	if err != nil {
+		return StatusSkip, err.Error()
+	}
+	if e.response != nil && isKoD(e.response) {
+		return StatusPass, "answered with kiss code " + kissCode(e.response)
+	}
+	return StatusFail, fmt.Sprintf("answered with %d bytes", e.length)
+}
+
+func checkUnknownVersion(s *suite) (Status, string) {
+	return s.expectNoResponse(packetBytes(request(7)))
+}
+
+func checkServerMode(s *suite) (Status, string) {
+	r := request(4)
+	r.Settings = 4<<3 | 4
+	return s.expectNoResponse(packetBytes(r))
+}
+
+func checkShortPacket(s *suite) (Status, string) {
+	return s.expectNoResponse(packetBytes(request(4))[:ntp.PacketSizeBytes/2])
+}
+
+func checkRateLimit(s *suite) (Status, string) {
+	if s.config.BurstSize <= 0 {
+		return StatusSkip, "burst size is not set"
+	}
+	conn, err := net.Dial("udp", s.server)
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	defer conn.Close()
+	requests := map[[2]uint32]bool{}
+	for i := 0; i < s.config.BurstSize; i++ {
+		r := request(4)
+		requests[[2]uint32{r.TxTimeSec, r.TxTimeFrac}] = true
+		if _, err := conn.Write(packetBytes(r)); err != nil {
+			return StatusFail, err.Error()
+		}
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(s.config.Timeout)); err != nil {
+		return StatusFail, err.Error()
+	}
+	answered, kods := 0, 0
+	buf := make([]byte, ntp.MaxPacketSizeBytes)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		if n < ntp.PacketSizeBytes {
+			continue
+		}
+		p, err := ntp.BytesToPacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		if !requests[[2]uint32{p.OrigTimeSec, p.OrigTimeFrac}] {
+			return StatusFail, "response doesn't match any request"
+		}
+		if !isKoD(p) {
+			answered++
+			continue
+		}
+		kods++
+		if code := kissCode(p); code != "RATE" {
+			return StatusFail, fmt.Sprintf("unexpected kiss code %q", code)
+		}
+	}
+	details := fmt.Sprintf("%d of %d answered, %d Kiss-o'-Death", answered, s.config.BurstSize, kods)
+	if answered == s.config.BurstSize {
+		return StatusSkip, details + ", no rate limiting observed"
+	}
+	if answered == 0 && kods == 0 {
+		return StatusFail, details
+	}
+	return StatusPass, details
+}
+
+// Run runs all checks against the server
+func Run(server string, c *Config) *Report {
+	s := &suite{server: server, config: c}
+	r := &Report{Server: server, Time: time.Now()}
+	passed, evaluated := 0, 0
+	for _, check := range Checks {
+		status, details := check.run(s)
+		r.Outcomes = append(r.Outcomes, &Outcome{Name: check.Name, Status: status, Weight: check.Weight, Details: details})
+		switch status {
+		case StatusPass:
+			r.Passed++
+			passed += check.Weight
+			evaluated += check.Weight
+		case StatusFail:
+			r.Failed++
+			evaluated += check.Weight
+		case StatusSkip:
+			r.Skipped++
+		}
+	}
+	if evaluated > 0 {
+		r.Score = 100 * float64(passed) / float64(evaluated)
+	}
+	return r
+}
+
+// WriteText writes human readable report
+func (r *Report) WriteText(w io.Writer) error {
+	for _, o := range r.Outcomes {
+		line := fmt.Sprintf("[%s] %s", o.Status, o.Name)
+		if o.Details != "" {
+			line += ": " + o.Details
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s: score %.1f%%, %d passed, %d failed, %d skipped\n", r.Server, r.Score, r.Passed, r.Failed, r.Skipped)
+	return err
+}
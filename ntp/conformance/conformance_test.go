@@ -0,0 +1,145 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer is a configurable NTP server
+type fakeServer struct {
+	// limit is the number of requests answered before rate limiting. Unlimited if 0
+	limit int
+	// kod makes server answer rate limited requests with RATE Kiss-o'-Death
+	kod bool
+	// sloppy server answers any packet with version 4 response
+	sloppy bool
+	offset time.Duration
+}
+
+func (f *fakeServer) start(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		seen := 0
+		buf := make([]byte, ntp.MaxPacketSizeBytes)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request := &ntp.Packet{}
+			_ = binary.Read(bytes.NewReader(buf[:n]), binary.BigEndian, request)
+			if !f.sloppy && (n < ntp.PacketSizeBytes || !request.ValidSettingsFormat()) {
+				continue
+			}
+			response := &ntp.Packet{Settings: request.Settings&0x38 | 4, Stratum: 1, OrigTimeSec: request.TxTimeSec, OrigTimeFrac: request.TxTimeFrac}
+			if f.sloppy {
+				response.Settings = 0x24
+			}
+			seen++
+			if f.limit > 0 && seen > f.limit {
+				if !f.kod {
+					continue
+				}
+				response.Settings |= 0xC0
+				response.Stratum = 0
+				response.ReferenceID = binary.BigEndian.Uint32([]byte("RATE"))
+			}
+			now := time.Now().Add(f.offset)
+			response.RefTimeSec, response.RefTimeFrac = ntp.Time(now.Add(-time.Second))
+			response.RxTimeSec, response.RxTimeFrac = ntp.Time(now)
+			response.TxTimeSec, response.TxTimeFrac = ntp.Time(now)
+			b, _ := response.Bytes()
+			_, _ = conn.WriteTo(b, addr)
+		}
+	}()
+	return conn
+}
+
+func outcomes(r *Report) map[string]Status {
+	m := map[string]Status{}
+	for _, o := range r.Outcomes {
+		m[o.Name] = o.Status
+	}
+	return m
+}
+
+var testConfig = Config{Timeout: 100 * time.Millisecond, MaxOffset: time.Second, BurstSize: 20}
+
+func TestRunConformant(t *testing.T) {
+	server := (&fakeServer{limit: 15}).start(t)
+	defer server.Close()
+
+	r := Run(server.LocalAddr().String(), &testConfig)
+	for _, o := range r.Outcomes {
+		require.Equal(t, StatusPass, o.Status, "%s: %s", o.Name, o.Details)
+	}
+	require.Equal(t, float64(100), r.Score)
+	require.Equal(t, len(Checks), r.Passed)
+}
+
+func TestRunKoD(t *testing.T) {
+	server := (&fakeServer{limit: 15, kod: true}).start(t)
+	defer server.Close()
+
+	r := Run(server.LocalAddr().String(), &testConfig)
+	require.Equal(t, StatusPass, outcomes(r)["ratelimit"])
+	require.Contains(t, r.Outcomes[len(r.Outcomes)-1].Details, "10 of 20 answered, 10 Kiss-o'-Death")
+}
+
+func TestRunNonConformant(t *testing.T) {
+	server := (&fakeServer{sloppy: true, offset: time.Hour}).start(t)
+	defer server.Close()
+
+	r := Run(server.LocalAddr().String(), &testConfig)
+	require.Equal(t, map[string]Status{
+		"version4":        StatusPass,
+		"version3":        StatusFail,
+		"origin":          StatusPass,
+		"timestamps":      StatusFail,
+		"header":          StatusPass,
+		"unknown-version": StatusFail,
+		"server-mode":     StatusFail,
+		"short-packet":    StatusFail,
+		"ratelimit":       StatusSkip,
+	}, outcomes(r))
+	require.Equal(t, 1, r.Skipped)
+	require.Equal(t, float64(50), r.Score)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	require.Contains(t, buf.String(), "[fail] version3: response version 4 mode 4\n")
+	require.Contains(t, buf.String(), "score 50.0%, 3 passed, 5 failed, 1 skipped\n")
+}
+
+func TestRunUnreachable(t *testing.T) {
+	server := (&fakeServer{}).start(t)
+	addr := server.LocalAddr().String()
+	server.Close()
+
+	r := Run(addr, &Config{Timeout: 100 * time.Millisecond})
+	require.Equal(t, float64(0), r.Score)
+	require.Equal(t, StatusSkip, outcomes(r)["ratelimit"])
+}
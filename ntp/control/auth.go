@@ -0,0 +1,66 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package control
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// Supported key types, as in ntp.keys
+const (
+	KeyTypeMD5  = "MD5"
+	KeyTypeSHA1 = "SHA1"
+)
+
+// Key is a symmetric key used to authenticate control requests which modify ntpd state.
+// It must match one of the keys in ntp.keys which is also set as controlkey in ntp.conf
+type Key struct {
+	ID     uint32
+	Type   string
+	Secret []byte
+}
+
+func (k *Key) hash() (hash.Hash, error) {
+	switch k.Type {
+	case KeyTypeMD5:
+		return md5.New(), nil
+	case KeyTypeSHA1:
+		return sha1.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Type)
+}
+
+// Sign pads payload to 64 bit boundary and appends key ID and message digest to it.
+// Digest is calculated the same way ntpd does: hash(secret + padded payload)
+func (k *Key) Sign(payload []byte) ([]byte, error) {
+	h, err := k.hash()
+	if err != nil {
+		return nil, err
+	}
+	for len(payload)%8 != 0 {
+		payload = append(payload, 0)
+	}
+	h.Write(k.Secret)
+	h.Write(payload)
+	keyID := make([]byte, 4)
+	binary.BigEndian.PutUint32(keyID, k.ID)
+	return h.Sum(append(payload, keyID...)), nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package control
+
+import (
+	"crypto/md5"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySignMD5(t *testing.T) {
+	key := &Key{ID: 42, Type: KeyTypeMD5, Secret: []byte("secret")}
+	payload := []byte{0x1e, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x61, 0x62}
+	signed, err := key.Sign(payload)
+	require.Nil(t, err)
+	// 14 bytes padded to 16, then 4 bytes of key ID and 16 bytes of digest
+	require.Equal(t, 36, len(signed))
+	padded := append(append([]byte{}, payload...), 0, 0)
+	require.Equal(t, padded, signed[:16])
+	require.Equal(t, []byte{0x00, 0x00, 0x00, 0x2a}, signed[16:20])
+	digest := md5.Sum(append([]byte("secret"), padded...))
+	require.Equal(t, digest[:], signed[20:])
+}
+
+func TestKeySignSHA1(t *testing.T) {
+	key := &Key{ID: 1, Type: KeyTypeSHA1, Secret: []byte("secret")}
+	signed, err := key.Sign(make([]byte, 16))
+	require.Nil(t, err)
+	require.Equal(t, 16+4+20, len(signed))
+}
+
+func TestKeySignUnsupported(t *testing.T) {
+	key := &Key{ID: 1, Type: "AES128CMAC", Secret: []byte("secret")}
+	_, err := key.Sign(make([]byte, 16))
+	require.Error(t, err)
+}
@@ -34,6 +34,16 @@ type NTPClient struct {
 // This function will always return single NTPControlMsg, even if under the hood it was split across multiple packets.
 // Resulting NTPControlMsg will have Data section composed of combined Data sections from all packages.
 func (n *NTPClient) CommunicateWithData(packet *NTPControlMsgHead, data []uint8) (*NTPControlMsg, error) {
+	return n.communicate(packet, data, nil)
+}
+
+// CommunicateWithAuth is the same as CommunicateWithData, but signs the request with the key.
+// ntpd requires this for operations which change its state, like OpWriteVariables and OpConfigure.
+func (n *NTPClient) CommunicateWithAuth(packet *NTPControlMsgHead, data []uint8, key *Key) (*NTPControlMsg, error) {
+	return n.communicate(packet, data, key)
+}
+
+func (n *NTPClient) communicate(packet *NTPControlMsgHead, data []uint8, key *Key) (*NTPControlMsg, error) {
 	packet.Sequence = n.Sequence
 	if len(data) > 0 {
 		packet.Count = uint16(len(data))
@@ -49,8 +59,14 @@ func (n *NTPClient) CommunicateWithData(packet *NTPControlMsgHead, data []uint8)
 	if err != nil {
 		return nil, err
 	}
+	payload := buf.Bytes()
+	if key != nil {
+		if payload, err = key.Sign(payload); err != nil {
+			return nil, err
+		}
+	}
 	// send full payload
-	_, err = n.Connection.Write(buf.Bytes())
+	_, err = n.Connection.Write(payload)
 	if err != nil {
 		return nil, err
 	}
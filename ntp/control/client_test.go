@@ -30,6 +30,7 @@ var vnMode = MakeVnMode(3, Mode)
 type fakeConn struct {
 	readCount int
 	outputs   []*bytes.Buffer
+	written   []byte
 }
 
 func newConn(outputs []*bytes.Buffer) *fakeConn {
@@ -50,6 +51,7 @@ func (c *fakeConn) Read(p []byte) (n int, err error) {
 
 func (c *fakeConn) Write(p []byte) (n int, err error) {
 	// here we may require writes
+	c.written = append(c.written, p...)
 	return 0, nil
 }
 
@@ -0,0 +1,105 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package control
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxDataLen is the max size of data section of a single control message
+const maxDataLen = 468
+
+// configSucceeded is what ntpd replies with when configuration was applied without errors
+const configSucceeded = "Config Succeeded"
+
+func (n *NTPClient) writeRequest(op int, associationID uint16, data []uint8, key *Key) (*NTPControlMsg, error) {
+	if key == nil {
+		return nil, errors.Errorf("key is required for operation=%d", op)
+	}
+	if len(data) > maxDataLen {
+		return nil, errors.Errorf("request data is too long: %d > %d", len(data), maxDataLen)
+	}
+	packet := &NTPControlMsgHead{
+		VnMode:        MakeVnMode(3, Mode),
+		REMOp:         MakeREMOp(false, false, false, op),
+		AssociationID: associationID,
+	}
+	resp, err := n.CommunicateWithAuth(packet, data, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.GetError(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Configure sends runtime configuration commands to ntpd, same as `ntpq -c ":config <line>"` does.
+// Each line is a ntp.conf statement, for example "server time.example.com iburst", "unpeer time.example.com" or "disable monitor".
+// Lines are applied one by one, first failed line stops the processing.
+func (n *NTPClient) Configure(key *Key, lines ...string) error {
+	for _, line := range lines {
+		resp, err := n.writeRequest(OpConfigure, 0, []uint8(line), key)
+		if err != nil {
+			return errors.Wrapf(err, "configuring %q", line)
+		}
+		reply := strings.TrimRight(string(resp.Data), "\x00\r\n")
+		if !strings.HasPrefix(reply, configSucceeded) {
+			return errors.Errorf("configuring %q: %s", line, reply)
+		}
+	}
+	return nil
+}
+
+// AddServer adds new server association to ntpd, options are the same as in ntp.conf, like "iburst"
+func (n *NTPClient) AddServer(key *Key, address string, options ...string) error {
+	return n.Configure(key, strings.Join(append([]string{"server", address}, options...), " "))
+}
+
+// RemovePeer removes association with peer identified by address
+func (n *NTPClient) RemovePeer(key *Key, address string) error {
+	return n.Configure(key, fmt.Sprintf("unpeer %s", address))
+}
+
+// Enable enables system flags, like "monitor" or "stats"
+func (n *NTPClient) Enable(key *Key, flags ...string) error {
+	return n.Configure(key, strings.Join(append([]string{"enable"}, flags...), " "))
+}
+
+// Disable disables system flags, like "monitor" or "stats"
+func (n *NTPClient) Disable(key *Key, flags ...string) error {
+	return n.Configure(key, strings.Join(append([]string{"disable"}, flags...), " "))
+}
+
+// WriteVariables sets variables of association, or system variables if associationID is 0,
+// same as `ntpq -c "writevar <associationID> k=v"` does.
+func (n *NTPClient) WriteVariables(key *Key, associationID uint16, vars map[string]string) error {
+	if len(vars) == 0 {
+		return nil
+	}
+	pairs := make([]string, 0, len(vars))
+	for k, v := range vars {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(pairs)
+	_, err := n.writeRequest(OpWriteVariables, associationID, []uint8(strings.Join(pairs, ",")), key)
+	return err
+}
@@ -0,0 +1,86 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package control
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = &Key{ID: 1, Type: KeyTypeMD5, Secret: []byte("secret")}
+
+func configResponse(op uint8, status uint16, data string) *bytes.Buffer {
+	head := []byte{
+		0x1e, op, 0x00, 0x01,
+		uint8(status >> 8), uint8(status), 0x00, 0x00,
+		0x00, 0x00, 0x00, uint8(len(data)),
+	}
+	return bytes.NewBuffer(append(head, []byte(data)...))
+}
+
+func TestConfigure(t *testing.T) {
+	conn := newConn([]*bytes.Buffer{
+		configResponse(0x88, 0, "Config Succeeded\r\n"),
+	})
+	client := NTPClient{Sequence: 1, Connection: conn}
+	err := client.AddServer(testKey, "time.example.com", "iburst")
+	require.Nil(t, err)
+	line := "server time.example.com iburst"
+	require.Equal(t, []byte{0x1e, 0x08, 0x00, 0x01}, conn.written[:4])
+	require.Equal(t, uint8(len(line)), conn.written[11])
+	require.Equal(t, line, string(conn.written[12:12+len(line)]))
+	// padded to 48 bytes, key ID and MD5 digest
+	require.Equal(t, 48+4+16, len(conn.written))
+}
+
+func TestConfigureSyntaxError(t *testing.T) {
+	conn := newConn([]*bytes.Buffer{
+		configResponse(0x88, 0, "column 1 syntax error"),
+	})
+	client := NTPClient{Sequence: 1, Connection: conn}
+	err := client.Disable(testKey, "nonsense")
+	require.EqualError(t, err, `configuring "disable nonsense": column 1 syntax error`)
+}
+
+func TestConfigurePermissionDenied(t *testing.T) {
+	conn := newConn([]*bytes.Buffer{
+		configResponse(0xc8, 0x0100, ""),
+	})
+	client := NTPClient{Sequence: 1, Connection: conn}
+	err := client.RemovePeer(testKey, "time.example.com")
+	require.EqualError(t, err, `configuring "unpeer time.example.com": request failed with error permission (1)`)
+}
+
+func TestConfigureNoKey(t *testing.T) {
+	client := NTPClient{Sequence: 1, Connection: newConn(nil)}
+	err := client.Enable(nil, "monitor")
+	require.Error(t, err)
+}
+
+func TestWriteVariables(t *testing.T) {
+	conn := newConn([]*bytes.Buffer{
+		configResponse(0x83, 0, ""),
+	})
+	client := NTPClient{Sequence: 1, Connection: conn}
+	err := client.WriteVariables(testKey, 0, map[string]string{"leapfile": "/etc/leap", "foo": "bar"})
+	require.Nil(t, err)
+	data := "foo=bar,leapfile=/etc/leap"
+	require.Equal(t, uint8(0x03), conn.written[1])
+	require.Equal(t, data, string(conn.written[12:12+len(data)]))
+}
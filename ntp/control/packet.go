@@ -28,10 +28,24 @@ const Mode = 6
 
 // Supported operation codes
 const (
-	OpReadStatus    = 1
-	OpReadVariables = 2
+	OpReadStatus     = 1
+	OpReadVariables  = 2
+	OpWriteVariables = 3
+	OpConfigure      = 8
 )
 
+// ErrorDesc stores human-readable descriptions of error codes returned in Status field of error responses
+var ErrorDesc = [8]string{
+	"unspecified",      // 0
+	"permission",       // 1, authentication failure
+	"bad_format",       // 2
+	"bad_operation",    // 3
+	"bad_association",  // 4
+	"unknown_variable", // 5
+	"bad_value",        // 6
+	"restricted",       // 7
+}
+
 // NormalizeData turns bytes that contain kv ASCII string info a map[string]string
 func NormalizeData(data []byte) (map[string]string, error) {
 	result := map[string]string{}
@@ -324,6 +338,18 @@ func (n NTPControlMsgHead) GetOperation() uint8 {
 	return uint8(n.REMOp & 0x1f) // last 5 bits
 }
 
+// GetError returns error decoded from Status field if packet has error flag set
+func (n NTPControlMsgHead) GetError() error {
+	if !n.HasError() {
+		return nil
+	}
+	code := n.Status >> 8
+	if int(code) < len(ErrorDesc) {
+		return errors.Errorf("request failed with error %s (%d)", ErrorDesc[code], code)
+	}
+	return errors.Errorf("request failed with error %d", code)
+}
+
 // GetSystemStatus returns parsed SystemStatusWord struct if present
 func (n NTPControlMsg) GetSystemStatus() (*SystemStatusWord, error) {
 	if n.GetOperation() != OpReadStatus {
@@ -0,0 +1,318 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package journal implements a compact append-only binary log of NTP client exchanges:
+raw request and response packets with client side timestamps.
+Journals written in production can be replayed later through the analysis pipeline,
+so incidents can be re-analyzed with improved algorithms.
+
+File starts with a header, followed by records framed as
+
+	uvarint payload length | payload | CRC32 (IEEE) of payload, big endian
+
+A record torn by a crash in the middle of a write is detected and reported as ErrTruncated,
+OpenWriter drops it before appending.
+*/
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// Version of the journal format
+const Version = 1
+
+var magic = []byte("NTPJ")
+
+// maxRecordSize protects reader from allocating huge buffers on corrupted length
+const maxRecordSize = 64 * 1024
+
+// Journal errors
+var (
+	ErrBadHeader = errors.New("not a journal or unsupported version")
+	ErrTruncated = errors.New("truncated record")
+	ErrCorrupted = errors.New("record checksum mismatch")
+)
+
+// Record is a single client exchange
+type Record struct {
+	// Target is the server queried
+	Target string
+	// Sent and Received are client transmit and receive timestamps (T1 and T4)
+	Sent     time.Time
+	Received time.Time
+	// Timestamping is how client timestamps were taken, e.g. hardware
+	Timestamping string
+	// HopLimit of the response, 0 if unknown
+	HopLimit int
+	Request  []byte
+	Response []byte
+}
+
+// Packets returns parsed request and response
+func (r *Record) Packets() (*ntp.Packet, *ntp.Packet, error) {
+	request, err := ntp.BytesToPacket(r.Request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing request: %w", err)
+	}
+	response, err := ntp.BytesToPacket(r.Response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return request, response, nil
+}
+
+func appendBytes(b, v []byte) []byte {
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+// marshal encodes the record payload. Receive time is stored relative to send time to keep it small
+func (r *Record) marshal() []byte {
+	b := make([]byte, 0, 32+len(r.Target)+len(r.Timestamping)+len(r.Request)+len(r.Response))
+	b = appendBytes(b, []byte(r.Target))
+	b = appendVarint(b, r.Sent.UnixNano())
+	// wall clock difference, monotonic clock readings are not persisted
+	b = appendVarint(b, r.Received.UnixNano()-r.Sent.UnixNano())
+	b = appendBytes(b, []byte(r.Timestamping))
+	b = appendUvarint(b, uint64(r.HopLimit))
+	b = appendBytes(b, r.Request)
+	return appendBytes(b, r.Response)
+}
+
+// unmarshal decodes the record payload
+func (r *Record) unmarshal(b []byte) error {
+	rd := bytes.NewReader(b)
+	readBytes := func() ([]byte, error) {
+		l, err := binary.ReadUvarint(rd)
+		if err != nil {
+			return nil, err
+		}
+		if l > uint64(rd.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v := make([]byte, l)
+		_, err = io.ReadFull(rd, v)
+		return v, err
+	}
+	target, err := readBytes()
+	if err != nil {
+		return err
+	}
+	sent, err := binary.ReadVarint(rd)
+	if err != nil {
+		return err
+	}
+	rtt, err := binary.ReadVarint(rd)
+	if err != nil {
+		return err
+	}
+	timestamping, err := readBytes()
+	if err != nil {
+		return err
+	}
+	hopLimit, err := binary.ReadUvarint(rd)
+	if err != nil {
+		return err
+	}
+	if r.Request, err = readBytes(); err != nil {
+		return err
+	}
+	if r.Response, err = readBytes(); err != nil {
+		return err
+	}
+	r.Target = string(target)
+	r.Sent = time.Unix(0, sent)
+	r.Received = r.Sent.Add(time.Duration(rtt))
+	r.Timestamping = string(timestamping)
+	r.HopLimit = int(hopLimit)
+	return nil
+}
+
+// Writer appends records to the journal. It is safe for concurrent use
+type Writer struct {
+	sync.Mutex
+	w io.Writer
+}
+
+// NewWriter writes the header and returns Writer appending to w
+func NewWriter(w io.Writer) (*Writer, error) {
+	if _, err := w.Write(append(append([]byte{}, magic...), Version)); err != nil {
+		return nil, fmt.Errorf("writing header: %w", err)
+	}
+	return &Writer{w: w}, nil
+}
+
+// OpenWriter opens the journal file for appending, creating it if needed.
+// A tail torn by a crash is truncated to the end of the last valid record, so new records stay readable
+func OpenWriter(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if st.Size() == 0 {
+		w, err := NewWriter(f)
+		if err != nil {
+			f.Close()
+		}
+		return w, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for err == nil {
+		_, err = r.Next()
+	}
+	if err != io.EOF {
+		if err := f.Truncate(r.Offset()); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: truncating torn tail: %w", path, err)
+		}
+	}
+	return &Writer{w: f}, nil
+}
+
+// Write appends the record with a single write, so concurrent writers don't interleave
+func (w *Writer) Write(r *Record) error {
+	payload := r.marshal()
+	frame := appendUvarint(make([]byte, 0, len(payload)+binary.MaxVarintLen64+4), uint64(len(payload)))
+	frame = append(frame, payload...)
+	frame = append(frame, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(frame[len(frame)-4:], crc32.ChecksumIEEE(payload))
+	w.Lock()
+	defer w.Unlock()
+	_, err := w.w.Write(frame)
+	return err
+}
+
+// Close closes the underlying writer if it is a Closer
+func (w *Writer) Close() error {
+	w.Lock()
+	defer w.Unlock()
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func readHeader(r io.Reader) error {
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return ErrBadHeader
+	}
+	if !bytes.Equal(header[:len(magic)], magic) || header[len(magic)] != Version {
+		return ErrBadHeader
+	}
+	return nil
+}
+
+// Reader reads records of the journal
+type Reader struct {
+	r *bufio.Reader
+	// off is the offset of the end of the last valid record
+	off int64
+}
+
+// NewReader checks the header and returns Reader of records from r
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	if err := readHeader(br); err != nil {
+		return nil, err
+	}
+	return &Reader{r: br, off: int64(len(magic) + 1)}, nil
+}
+
+// Offset returns offset in the journal of the end of the last valid record read
+func (r *Reader) Offset() int64 {
+	return r.off
+}
+
+// Next returns the next record. It returns io.EOF at the end of the journal
+func (r *Reader) Next() (*Record, error) {
+	l, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, ErrTruncated
+	}
+	if l > maxRecordSize {
+		return nil, ErrCorrupted
+	}
+	frame := make([]byte, l+4)
+	if _, err := io.ReadFull(r.r, frame); err != nil {
+		return nil, ErrTruncated
+	}
+	payload := frame[:l]
+	if binary.BigEndian.Uint32(frame[l:]) != crc32.ChecksumIEEE(payload) {
+		return nil, ErrCorrupted
+	}
+	rec := &Record{}
+	if err := rec.unmarshal(payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	r.off += int64(len(appendUvarint(nil, l))) + int64(len(frame))
+	return rec, nil
+}
+
+// Replay calls f for every record of the journal in order. It stops at the first error of f
+func Replay(r io.Reader, f func(*Record) error) error {
+	jr, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		rec, err := jr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(rec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journal
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecord(t *testing.T, target string, sent time.Time) *Record {
+	request, err := (&ntp.Packet{Settings: 0x23, TxTimeSec: 1, TxTimeFrac: 2}).Bytes()
+	require.NoError(t, err)
+	response, err := (&ntp.Packet{Settings: 0x24, Stratum: 1, OrigTimeSec: 1, OrigTimeFrac: 2}).Bytes()
+	require.NoError(t, err)
+	return &Record{
+		Target:       target,
+		Sent:         sent,
+		Received:     sent.Add(150 * time.Microsecond),
+		Timestamping: "hardware",
+		HopLimit:     61,
+		Request:      request,
+		Response:     response,
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	sent := time.Unix(1640995200, 123456789)
+	records := []*Record{testRecord(t, "time1.example.com", sent), testRecord(t, "[fd00::1]:123", sent.Add(time.Second))}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	for _, r := range records {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+
+	got := []*Record{}
+	require.NoError(t, Replay(bytes.NewReader(buf.Bytes()), func(r *Record) error {
+		got = append(got, r)
+		return nil
+	}))
+	require.Len(t, got, 2)
+	for i := range records {
+		require.True(t, records[i].Sent.Equal(got[i].Sent))
+		require.True(t, records[i].Received.Equal(got[i].Received))
+		got[i].Sent, got[i].Received = records[i].Sent, records[i].Received
+	}
+	require.Equal(t, records, got)
+
+	request, response, err := got[0].Packets()
+	require.NoError(t, err)
+	require.Equal(t, uint8(0x23), request.Settings)
+	require.Equal(t, uint8(1), response.Stratum)
+}
+
+func TestOpenWriterAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	sent := time.Unix(1640995200, 0)
+	for i := 0; i < 2; i++ {
+		w, err := OpenWriter(path)
+		require.NoError(t, err)
+		require.NoError(t, w.Write(testRecord(t, "time1.example.com", sent)))
+		require.NoError(t, w.Close())
+	}
+
+	_, err := OpenWriter(t.TempDir())
+	require.Error(t, err)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := NewReader(f)
+	require.NoError(t, err)
+	count := 0
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		count++
+	}
+	require.Equal(t, 2, count)
+}
+
+func TestOpenWriterTruncatesTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	sent := time.Unix(1640995200, 0)
+	w, err := OpenWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testRecord(t, "time1.example.com", sent)))
+	require.NoError(t, w.Write(testRecord(t, "time2.example.com", sent)))
+	require.NoError(t, w.Close())
+
+	// crash in the middle of the second record
+	st, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, st.Size()-10))
+
+	w, err = OpenWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testRecord(t, "time3.example.com", sent)))
+	require.NoError(t, w.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	targets := []string{}
+	require.NoError(t, Replay(f, func(r *Record) error {
+		targets = append(targets, r.Target)
+		return nil
+	}))
+	require.Equal(t, []string{"time1.example.com", "time3.example.com"}, targets)
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("NTPX\x01")))
+	require.ErrorIs(t, err, ErrBadHeader)
+	_, err = NewReader(bytes.NewReader([]byte("NTPJ\x02")))
+	require.ErrorIs(t, err, ErrBadHeader)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testRecord(t, "time1.example.com", time.Unix(1640995200, 0))))
+	b := buf.Bytes()
+
+	// torn write
+	r, err := NewReader(bytes.NewReader(b[:len(b)-3]))
+	require.NoError(t, err)
+	_, err = r.Next()
+	require.ErrorIs(t, err, ErrTruncated)
+
+	// flipped bit
+	corrupted := append([]byte{}, b...)
+	corrupted[10] ^= 1
+	r, err = NewReader(bytes.NewReader(corrupted))
+	require.NoError(t, err)
+	_, err = r.Next()
+	require.ErrorIs(t, err, ErrCorrupted)
+}
@@ -0,0 +1,260 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package loadgen generates sustained NTP query load against a server and measures response latency distribution.
+It is meant to validate performance changes of the responder in the lab.
+*/
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// tick is how often a batch of queries is sent to reach the QPS
+const tick = time.Millisecond
+
+// Config of the load
+type Config struct {
+	// Server is host:port of the NTP server
+	Server string
+	// QPS is the target rate of queries
+	QPS int
+	// Duration of the load
+	Duration time.Duration
+	// Ports is the number of source ports per source IP, to spread the load across server RX queues
+	Ports int
+	// Sources are local IPs queries are sent from, simulating many clients.
+	// On linux they don't have to be configured on the host, only routed back to it, as sockets are bound with IP_FREEBIND
+	Sources []net.IP
+	// Timeout is how long to wait for responses after the last query
+	Timeout time.Duration
+}
+
+// Latency is the distribution of response latencies
+type Latency struct {
+	Min  time.Duration `json:"min_ns"`
+	Max  time.Duration `json:"max_ns"`
+	Mean time.Duration `json:"mean_ns"`
+	P50  time.Duration `json:"p50_ns"`
+	P90  time.Duration `json:"p90_ns"`
+	P99  time.Duration `json:"p99_ns"`
+	P999 time.Duration `json:"p999_ns"`
+}
+
+// Stats of the load
+type Stats struct {
+	Sent       int64         `json:"sent"`
+	Received   int64         `json:"received"`
+	Lost       int64         `json:"lost"`
+	SendErrors int64         `json:"send_errors"`
+	Duration   time.Duration `json:"duration_ns"`
+	// QPS is the achieved rate of queries
+	QPS     float64  `json:"qps"`
+	Latency *Latency `json:"latency"`
+}
+
+// client is a source socket with latencies of its responses
+type client struct {
+	conn      *net.UDPConn
+	latencies []time.Duration
+}
+
+func (c *client) read(received *int64) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := c.conn.Read(buf)
+		now := time.Now()
+		if err != nil {
+			return
+		}
+		p, err := ntp.BytesToPacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		// origin is the transmit time of the query
+		sent := ntp.Unix(p.OrigTimeSec, p.OrigTimeFrac)
+		atomic.AddInt64(received, 1)
+		c.latencies = append(c.latencies, now.Sub(sent))
+	}
+}
+
+func dial(ctx context.Context, src net.IP, server string) (*net.UDPConn, error) {
+	d := &net.Dialer{Control: freebind}
+	if src != nil {
+		d.LocalAddr = &net.UDPAddr{IP: src}
+	}
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+func (c *Config) clients(ctx context.Context) ([]*client, error) {
+	sources := c.Sources
+	if len(sources) == 0 {
+		sources = []net.IP{nil}
+	}
+	ports := c.Ports
+	if ports < 1 {
+		ports = 1
+	}
+	clients := []*client{}
+	for _, src := range sources {
+		for i := 0; i < ports; i++ {
+			conn, err := dial(ctx, src, c.Server)
+			if err != nil {
+				for _, cl := range clients {
+					cl.conn.Close()
+				}
+				return nil, fmt.Errorf("dialing %s from %v: %w", c.Server, src, err)
+			}
+			clients = append(clients, &client{conn: conn})
+		}
+	}
+	return clients, nil
+}
+
+// Run sends queries at c.QPS for c.Duration or until ctx is done and returns stats of responses
+func Run(ctx context.Context, c *Config) (*Stats, error) {
+	if c.QPS <= 0 {
+		return nil, fmt.Errorf("invalid QPS %d", c.QPS)
+	}
+	clients, err := c.clients(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	stats := &Stats{}
+	for _, cl := range clients {
+		wg.Add(1)
+		go func(cl *client) {
+			defer wg.Done()
+			cl.read(&stats.Received)
+		}(cl)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Duration)
+	defer cancel()
+	start := time.Now()
+	send(ctx, clients, c.QPS, start, stats)
+	stats.Duration = time.Since(start)
+
+	time.Sleep(c.Timeout)
+	for _, cl := range clients {
+		cl.conn.Close()
+	}
+	wg.Wait()
+
+	stats.QPS = float64(stats.Sent) / stats.Duration.Seconds()
+	stats.Lost = stats.Sent - stats.SendErrors - stats.Received
+	latencies := []time.Duration{}
+	for _, cl := range clients {
+		latencies = append(latencies, cl.latencies...)
+	}
+	stats.Latency = latency(latencies)
+	return stats, nil
+}
+
+// send paces queries in batches every tick, catching up when it falls behind
+func send(ctx context.Context, clients []*client, qps int, start time.Time, stats *Stats) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	request := &ntp.Packet{Settings: 0x1B}
+	next := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			due := int64(now.Sub(start).Seconds() * float64(qps))
+			for stats.Sent < due {
+				cl := clients[next%len(clients)]
+				next++
+				request.TxTimeSec, request.TxTimeFrac = ntp.Time(time.Now())
+				b, err := request.Bytes()
+				if err == nil {
+					_, err = cl.conn.Write(b)
+				}
+				if err != nil {
+					stats.SendErrors++
+					log.Debugf("failed to send query: %v", err)
+				}
+				stats.Sent++
+			}
+		}
+	}
+}
+
+// latency returns distribution of latencies
+func latency(latencies []time.Duration) *Latency {
+	l := &Latency{}
+	if len(latencies) == 0 {
+		return l
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var sum time.Duration
+	for _, d := range latencies {
+		sum += d
+	}
+	percentile := func(q float64) time.Duration {
+		i := int(math.Ceil(q*float64(len(latencies)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return latencies[i]
+	}
+	l.Min = latencies[0]
+	l.Max = latencies[len(latencies)-1]
+	l.Mean = sum / time.Duration(len(latencies))
+	l.P50 = percentile(0.5)
+	l.P90 = percentile(0.9)
+	l.P99 = percentile(0.99)
+	l.P999 = percentile(0.999)
+	return l
+}
+
+// SourceRange returns count consecutive IPs starting with first, for example to simulate clients of a lab subnet
+func SourceRange(first net.IP, count int) []net.IP {
+	ips := make([]net.IP, 0, count)
+	ip := append(net.IP{}, first...)
+	if v4 := first.To4(); v4 != nil {
+		ip = append(net.IP{}, v4...)
+	}
+	for i := 0; i < count; i++ {
+		ips = append(ips, append(net.IP{}, ip...))
+		for j := len(ip) - 1; j >= 0; j-- {
+			ip[j]++
+			if ip[j] != 0 {
+				break
+			}
+		}
+	}
+	return ips
+}
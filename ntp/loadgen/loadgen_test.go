@@ -0,0 +1,111 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadgen
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// echoServer answers every query with origin set to its transmit time, dropping every dropEvery-th one
+func echoServer(t *testing.T, dropEvery int) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	go func() {
+		buf := make([]byte, 1024)
+		n := 0
+		for {
+			l, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			n++
+			if dropEvery > 0 && n%dropEvery == 0 {
+				continue
+			}
+			p, err := ntp.BytesToPacket(buf[:l])
+			require.NoError(t, err)
+			p.OrigTimeSec, p.OrigTimeFrac = p.TxTimeSec, p.TxTimeFrac
+			b, err := p.Bytes()
+			require.NoError(t, err)
+			_, _ = conn.WriteToUDP(b, addr)
+		}
+	}()
+	return conn
+}
+
+func TestRun(t *testing.T) {
+	server := echoServer(t, 10)
+	defer server.Close()
+
+	c := &Config{
+		Server:   server.LocalAddr().String(),
+		QPS:      1000,
+		Duration: 200 * time.Millisecond,
+		Ports:    4,
+		Timeout:  100 * time.Millisecond,
+	}
+	stats, err := Run(context.Background(), c)
+	require.NoError(t, err)
+	require.InDelta(t, 200, stats.Sent, 20)
+	require.Zero(t, stats.SendErrors)
+	require.InDelta(t, stats.Sent/10, stats.Lost, 1)
+	require.Equal(t, stats.Sent, stats.Received+stats.Lost)
+	require.Greater(t, stats.Latency.Min, time.Duration(0))
+	require.LessOrEqual(t, stats.Latency.P50, stats.Latency.P99)
+	require.LessOrEqual(t, stats.Latency.P99, stats.Latency.Max)
+}
+
+func TestRunInvalid(t *testing.T) {
+	_, err := Run(context.Background(), &Config{Server: "127.0.0.1:123"})
+	require.Error(t, err)
+}
+
+func TestLatency(t *testing.T) {
+	require.Equal(t, &Latency{}, latency(nil))
+
+	latencies := []time.Duration{}
+	for i := 1000; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Microsecond)
+	}
+	l := latency(latencies)
+	require.Equal(t, time.Microsecond, l.Min)
+	require.Equal(t, time.Millisecond, l.Max)
+	require.Equal(t, 500500*time.Nanosecond, l.Mean)
+	require.Equal(t, 500*time.Microsecond, l.P50)
+	require.Equal(t, 900*time.Microsecond, l.P90)
+	require.Equal(t, 990*time.Microsecond, l.P99)
+	require.Equal(t, 999*time.Microsecond, l.P999)
+}
+
+func TestSourceRange(t *testing.T) {
+	ips := SourceRange(net.ParseIP("192.0.2.254"), 3)
+	require.Equal(t, []net.IP{
+		net.ParseIP("192.0.2.254").To4(),
+		net.ParseIP("192.0.2.255").To4(),
+		net.ParseIP("192.0.3.0").To4(),
+	}, ips)
+
+	ips = SourceRange(net.ParseIP("fd00::ffff"), 2)
+	require.Equal(t, []net.IP{net.ParseIP("fd00::ffff"), net.ParseIP("fd00::1:0")}, ips)
+}
@@ -0,0 +1,264 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPort is the NTP port used when SRV records are not available
+const DefaultPort = 123
+
+// health scoring parameters
+const (
+	// healthAlpha is the weight of the latest result in the score
+	healthAlpha = 0.25
+	// MinScore is the score below which server is skipped while healthy ones are available
+	MinScore = 0.5
+	// DefaultRetryInterval is how often an unhealthy server is handed out anyway, so it can recover
+	DefaultRetryInterval = 5 * time.Minute
+)
+
+var errNoServers = errors.New("no servers resolved")
+
+// Resolver is the subset of net.Resolver used by the Pool
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Server is a single address the pool hostname resolved to
+type Server struct {
+	Address   string    `json:"address"`
+	Score     float64   `json:"score"`
+	Successes int       `json:"successes"`
+	Failures  int       `json:"failures"`
+	Resolved  time.Time `json:"resolved"`
+
+	// retry is when the unhealthy server is handed out next
+	retry time.Time
+}
+
+// Healthy returns whether the server score is above MinScore
+func (s Server) Healthy() bool {
+	return s.Score >= MinScore
+}
+
+// Pool expands pool hostname into multiple servers, re-resolves it periodically
+// and rotates through servers preferring healthy ones. Unhealthy servers are retried
+// every RetryInterval, so their score can recover
+type Pool struct {
+	// Host is the pool hostname, such as pool.ntp.org
+	Host string
+	// Port is used for addresses resolved via A/AAAA records. DefaultPort if 0
+	Port int
+	// SRV enables lookup of _ntp._udp.Host SRV records before A/AAAA
+	SRV bool
+	// Interval between re-resolutions in Run
+	Interval time.Duration
+	// Resolver is net.DefaultResolver if nil
+	Resolver Resolver
+	// RetryInterval between attempts of an unhealthy server. DefaultRetryInterval if 0
+	RetryInterval time.Duration
+
+	sync.Mutex
+	servers map[string]*Server
+	order   []string
+	next    int
+}
+
+func (p *Pool) resolver() Resolver {
+	if p.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return p.Resolver
+}
+
+func (p *Pool) retryInterval() time.Duration {
+	if p.RetryInterval == 0 {
+		return DefaultRetryInterval
+	}
+	return p.RetryInterval
+}
+
+func (p *Pool) port() int {
+	if p.Port == 0 {
+		return DefaultPort
+	}
+	return p.Port
+}
+
+// lookup returns host:port pairs the pool hostname currently resolves to
+func (p *Pool) lookup(ctx context.Context) ([]string, error) {
+	r := p.resolver()
+	if p.SRV {
+		_, srvs, err := r.LookupSRV(ctx, "ntp", "udp", p.Host)
+		if err != nil {
+			log.Debugf("SRV lookup of %s failed, falling back to A/AAAA: %v", p.Host, err)
+		}
+		addrs := []string{}
+		for _, srv := range srvs {
+			ips, err := r.LookupHost(ctx, srv.Target)
+			if err != nil {
+				log.Warningf("Failed to resolve SRV target %s: %v", srv.Target, err)
+				continue
+			}
+			for _, ip := range ips {
+				addrs = append(addrs, net.JoinHostPort(ip, strconv.Itoa(int(srv.Port))))
+			}
+		}
+		if len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+	ips, err := r.LookupHost(ctx, p.Host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip, strconv.Itoa(p.port())))
+	}
+	return addrs, nil
+}
+
+// Resolve looks up the pool hostname and updates the list of servers.
+// Servers which are still present keep their score, new ones start healthy.
+// On error the previous list is kept
+func (p *Pool) Resolve(ctx context.Context) error {
+	addrs, err := p.lookup(ctx)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return errNoServers
+	}
+	now := time.Now()
+
+	p.Lock()
+	defer p.Unlock()
+	servers := make(map[string]*Server, len(addrs))
+	for _, addr := range addrs {
+		s, ok := p.servers[addr]
+		if !ok {
+			s = &Server{Address: addr, Score: 1}
+		}
+		s.Resolved = now
+		servers[addr] = s
+	}
+	order := make([]string, 0, len(servers))
+	for addr := range servers {
+		order = append(order, addr)
+	}
+	sort.Strings(order)
+	p.servers = servers
+	p.order = order
+	p.next = 0
+	return nil
+}
+
+// Next returns the address of the next server in rotation.
+// Unhealthy servers are skipped unless all of them are unhealthy or it's time to retry them
+func (p *Pool) Next() (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.order) == 0 {
+		return "", errNoServers
+	}
+	now := time.Now()
+	for i := 0; i < len(p.order); i++ {
+		addr := p.order[(p.next+i)%len(p.order)]
+		s := p.servers[addr]
+		if s.Healthy() || !now.Before(s.retry) {
+			if !s.Healthy() {
+				s.retry = now.Add(p.retryInterval())
+			}
+			p.next = (p.next + i + 1) % len(p.order)
+			return addr, nil
+		}
+	}
+	addr := p.order[p.next]
+	p.next = (p.next + 1) % len(p.order)
+	return addr, nil
+}
+
+// Report updates health score of the server after a query
+func (p *Pool) Report(addr string, ok bool) {
+	p.Lock()
+	defer p.Unlock()
+	s, found := p.servers[addr]
+	if !found {
+		// server was removed by re-resolution
+		return
+	}
+	result := 0.0
+	if ok {
+		result = 1
+		s.Successes++
+	} else {
+		s.Failures++
+	}
+	s.Score = s.Score*(1-healthAlpha) + result*healthAlpha
+	if s.Healthy() {
+		s.retry = time.Time{}
+	} else if s.retry.IsZero() {
+		s.retry = time.Now().Add(p.retryInterval())
+	}
+}
+
+// Servers returns a copy of current servers sorted by address
+func (p *Pool) Servers() []Server {
+	p.Lock()
+	defer p.Unlock()
+	res := make([]Server, 0, len(p.order))
+	for _, addr := range p.order {
+		res = append(res, *p.servers[addr])
+	}
+	return res
+}
+
+// Run resolves the pool hostname and keeps re-resolving it every Interval until ctx is done.
+// Only the initial resolution error is returned, later ones are logged and the previous list is kept.
+// It returns right after the first resolution if Interval is not positive
+func (p *Pool) Run(ctx context.Context) error {
+	if err := p.Resolve(ctx); err != nil {
+		return err
+	}
+	if p.Interval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.Resolve(ctx); err != nil {
+				log.Warningf("Failed to re-resolve %s: %v", p.Host, err)
+			}
+		}
+	}
+}
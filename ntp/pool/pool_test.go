@@ -0,0 +1,208 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	sync.Mutex
+	srv   []*net.SRV
+	hosts map[string][]string
+}
+
+func (f *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.srv) == 0 {
+		return "", nil, errors.New("no such host")
+	}
+	return "_" + service + "._" + proto + "." + name, f.srv, nil
+}
+
+func (f *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	f.Lock()
+	defer f.Unlock()
+	ips, ok := f.hosts[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return ips, nil
+}
+
+func (f *fakeResolver) set(host string, ips []string) {
+	f.Lock()
+	defer f.Unlock()
+	f.hosts[host] = ips
+}
+
+func addresses(servers []Server) []string {
+	res := []string{}
+	for _, s := range servers {
+		res = append(res, s.Address)
+	}
+	return res
+}
+
+func TestPoolResolve(t *testing.T) {
+	r := &fakeResolver{hosts: map[string][]string{"pool.example.com": {"192.0.2.2", "192.0.2.1", "2001:db8::1"}}}
+	p := &Pool{Host: "pool.example.com", Resolver: r}
+	_, err := p.Next()
+	require.ErrorIs(t, err, errNoServers)
+
+	require.NoError(t, p.Resolve(context.Background()))
+	require.Equal(t, []string{"192.0.2.1:123", "192.0.2.2:123", "[2001:db8::1]:123"}, addresses(p.Servers()))
+
+	// score survives re-resolution, removed servers are dropped
+	p.Report("192.0.2.2:123", false)
+	r.set("pool.example.com", []string{"192.0.2.2", "192.0.2.3"})
+	require.NoError(t, p.Resolve(context.Background()))
+	servers := p.Servers()
+	require.Equal(t, []string{"192.0.2.2:123", "192.0.2.3:123"}, addresses(servers))
+	require.Equal(t, 0.75, servers[0].Score)
+	require.Equal(t, 1, servers[0].Failures)
+	require.Equal(t, 1.0, servers[1].Score)
+
+	// failed lookup keeps the previous list
+	r.set("pool.example.com", []string{})
+	require.ErrorIs(t, p.Resolve(context.Background()), errNoServers)
+	require.Len(t, p.Servers(), 2)
+}
+
+func TestPoolResolveSRV(t *testing.T) {
+	r := &fakeResolver{
+		srv: []*net.SRV{{Target: "a.example.com.", Port: 1123}, {Target: "b.example.com.", Port: 123}},
+		hosts: map[string][]string{
+			"a.example.com.":   {"192.0.2.1"},
+			"pool.example.com": {"192.0.2.9"},
+		},
+	}
+	p := &Pool{Host: "pool.example.com", SRV: true, Resolver: r}
+	require.NoError(t, p.Resolve(context.Background()))
+	// unresolvable target is skipped
+	require.Equal(t, []string{"192.0.2.1:1123"}, addresses(p.Servers()))
+
+	// fall back to A/AAAA without SRV records
+	r.srv = nil
+	p.Port = 4123
+	require.NoError(t, p.Resolve(context.Background()))
+	require.Equal(t, []string{"192.0.2.9:4123"}, addresses(p.Servers()))
+}
+
+func TestPoolNext(t *testing.T) {
+	r := &fakeResolver{hosts: map[string][]string{"pool.example.com": {"192.0.2.1", "192.0.2.2", "192.0.2.3"}}}
+	p := &Pool{Host: "pool.example.com", Resolver: r}
+	require.NoError(t, p.Resolve(context.Background()))
+
+	got := []string{}
+	for i := 0; i < 4; i++ {
+		addr, err := p.Next()
+		require.NoError(t, err)
+		got = append(got, addr)
+	}
+	require.Equal(t, []string{"192.0.2.1:123", "192.0.2.2:123", "192.0.2.3:123", "192.0.2.1:123"}, got)
+
+	// three failures in a row make the server unhealthy
+	for i := 0; i < 3; i++ {
+		p.Report("192.0.2.2:123", false)
+	}
+	require.False(t, p.Servers()[1].Healthy())
+	got = []string{}
+	for i := 0; i < 3; i++ {
+		addr, err := p.Next()
+		require.NoError(t, err)
+		got = append(got, addr)
+	}
+	require.Equal(t, []string{"192.0.2.3:123", "192.0.2.1:123", "192.0.2.3:123"}, got)
+
+	// successes bring it back
+	for i := 0; i < 3; i++ {
+		p.Report("192.0.2.2:123", true)
+	}
+	require.True(t, p.Servers()[1].Healthy())
+
+	// all unhealthy, plain rotation
+	for _, s := range p.Servers() {
+		for i := 0; i < 5; i++ {
+			p.Report(s.Address, false)
+		}
+	}
+	addr, err := p.Next()
+	require.NoError(t, err)
+	require.NotEmpty(t, addr)
+
+	// reports for unknown servers are ignored
+	p.Report("198.51.100.1:123", true)
+	require.Len(t, p.Servers(), 3)
+}
+
+func TestPoolRetry(t *testing.T) {
+	r := &fakeResolver{hosts: map[string][]string{"pool.example.com": {"192.0.2.1", "192.0.2.2"}}}
+	p := &Pool{Host: "pool.example.com", Resolver: r, RetryInterval: time.Hour}
+	require.NoError(t, p.Resolve(context.Background()))
+	for i := 0; i < 3; i++ {
+		p.Report("192.0.2.1:123", false)
+	}
+	next := func(n int) []string {
+		got := []string{}
+		for i := 0; i < n; i++ {
+			addr, err := p.Next()
+			require.NoError(t, err)
+			got = append(got, addr)
+		}
+		return got
+	}
+	require.Equal(t, []string{"192.0.2.2:123", "192.0.2.2:123"}, next(2))
+
+	// once retry is due, the unhealthy server is handed out once per interval
+	p.servers["192.0.2.1:123"].retry = time.Now().Add(-time.Second)
+	require.Equal(t, []string{"192.0.2.1:123", "192.0.2.2:123", "192.0.2.2:123"}, next(3))
+
+	// successful retries bring it back into rotation
+	for i := 0; i < 3; i++ {
+		p.Report("192.0.2.1:123", true)
+	}
+	require.True(t, p.Servers()[0].Healthy())
+	require.Equal(t, []string{"192.0.2.1:123", "192.0.2.2:123"}, next(2))
+	require.True(t, p.servers["192.0.2.1:123"].retry.IsZero())
+}
+
+func TestPoolRun(t *testing.T) {
+	r := &fakeResolver{hosts: map[string][]string{"pool.example.com": {"192.0.2.1"}}}
+	p := &Pool{Host: "pool.example.com", Resolver: r, Interval: 10 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx)
+	}()
+	require.Eventually(t, func() bool { return len(p.Servers()) == 1 }, time.Second, time.Millisecond)
+	r.set("pool.example.com", []string{"192.0.2.1", "192.0.2.2"})
+	require.Eventually(t, func() bool { return len(p.Servers()) == 2 }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	p = &Pool{Host: "unknown.example.com", Resolver: r, Interval: time.Millisecond}
+	require.Error(t, p.Run(context.Background()))
+}
@@ -0,0 +1,182 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/facebook/time/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+// Address families of dual stack targets
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// raceEvery is how many polls of the preferred family are done between races of both families
+const raceEvery = 10
+
+// dualStack is the state of dual stack target
+type dualStack struct {
+	preferred string
+	// polls of the preferred family since the last race
+	polls int
+}
+
+// attempt is a query of the single family
+type attempt struct {
+	family string
+	now    time.Time
+	e      *exchange
+	err    error
+}
+
+// familyAddresses returns addresses of both families the target resolves to.
+// It returns nil for IP literals, single stack names and resolution errors, which are probed as usual
+func (p *Prober) familyAddresses(target string) map[string]string {
+	host, port, err := net.SplitHostPort(address(target))
+	if err != nil || net.ParseIP(host) != nil {
+		return nil
+	}
+	ips, err := p.lookup(host)
+	if err != nil {
+		return nil
+	}
+	addrs := map[string]string{}
+	for _, ip := range ips {
+		family := FamilyIPv6
+		if ip.To4() != nil {
+			family = FamilyIPv4
+		}
+		if _, ok := addrs[family]; !ok {
+			addrs[family] = net.JoinHostPort(ip.String(), port)
+		}
+	}
+	if len(addrs) < 2 {
+		return nil
+	}
+	return addrs
+}
+
+// healthier returns the family with better results. Must be called with the lock held.
+// Reachability wins, then lower failure ratio, then lower delay. IPv6 is preferred on a tie
+func (p *Prober) healthier(target string) string {
+	r4, r6 := p.families[target+"/"+FamilyIPv4], p.families[target+"/"+FamilyIPv6]
+	if r4.Reachable != r6.Reachable {
+		if r4.Reachable {
+			return FamilyIPv4
+		}
+		return FamilyIPv6
+	}
+	ratio4, ratio6 := float64(r4.Failures)/float64(r4.Probes), float64(r6.Failures)/float64(r6.Probes)
+	if ratio4 != ratio6 {
+		if ratio4 < ratio6 {
+			return FamilyIPv4
+		}
+		return FamilyIPv6
+	}
+	if r4.Reachable && r4.Delay < r6.Delay {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// probeDualStack queries both families of the target in parallel, or only the preferred one between races.
+// Target result is updated with the exchange of the preferred family
+func (p *Prober) probeDualStack(target string, addrs map[string]string) *Result {
+	p.Lock()
+	ds, ok := p.dual[target]
+	if !ok {
+		ds = &dualStack{}
+		p.dual[target] = ds
+	}
+	families := []string{ds.preferred}
+	race := ds.preferred == "" || ds.polls >= raceEvery
+	if race {
+		families = []string{FamilyIPv4, FamilyIPv6}
+	}
+	p.Unlock()
+
+	attempts := make([]attempt, len(families))
+	var wg sync.WaitGroup
+	for i, family := range families {
+		wg.Add(1)
+		go func(i int, family string) {
+			defer wg.Done()
+			now := clock.Default(p.Config.Clock).Now()
+			e, err := p.query(addrs[family], &p.Config)
+			attempts[i] = attempt{family: family, now: now, e: e, err: err}
+		}(i, family)
+	}
+	wg.Wait()
+
+	p.Lock()
+	for _, a := range attempts {
+		update(p.families, target+"/"+a.family, target, a.now, a.e, a.err).Family = a.family
+	}
+	if race {
+		if preferred := p.healthier(target); preferred != ds.preferred {
+			log.Infof("[prober] %s: preferring %s", target, preferred)
+			ds.preferred = preferred
+		}
+		ds.polls = 0
+	} else if attempts[0].err != nil {
+		// preferred family failed, both are raced on the next poll
+		ds.polls = raceEvery
+	}
+	ds.polls++
+	for _, family := range []string{FamilyIPv4, FamilyIPv6} {
+		if r, ok := p.families[target+"/"+family]; ok {
+			r.Preferred = family == ds.preferred
+		}
+	}
+	chosen := attempts[0]
+	for _, a := range attempts {
+		if a.family == ds.preferred {
+			chosen = a
+		}
+	}
+	r := update(p.results, target, target, chosen.now, chosen.e, chosen.err)
+	p.Unlock()
+
+	if chosen.err == nil {
+		p.write(target, chosen.e)
+	}
+	return r
+}
+
+// FamilyResults returns copy of the last per family results of dual stack targets sorted by target and family
+func (p *Prober) FamilyResults() []Result {
+	p.Lock()
+	defer p.Unlock()
+	results := make([]Result, 0, len(p.families))
+	for _, r := range p.families {
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Target != results[j].Target {
+			return results[i].Target < results[j].Target
+		}
+		return results[i].Family < results[j].Family
+	})
+	return results
+}
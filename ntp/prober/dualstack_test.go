@@ -0,0 +1,149 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	addrIPv4 = "192.0.2.1:123"
+	addrIPv6 = "[2001:db8::1]:123"
+)
+
+// newDualStackProber returns prober of the dual stack target answering with delay per address.
+// Addresses without delay fail
+func newDualStackProber(delays map[string]time.Duration) (*Prober, map[string]int) {
+	var mux sync.Mutex
+	queries := map[string]int{}
+	p := New(Config{DualStack: true})
+	p.lookup = func(host string) ([]net.IP, error) {
+		if host != "dual.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}, nil
+	}
+	p.query = func(address string, c *Config) (*exchange, error) {
+		mux.Lock()
+		queries[address]++
+		delay, ok := delays[address]
+		mux.Unlock()
+		if !ok {
+			return nil, errors.New("timeout")
+		}
+		t1 := time.Unix(1600000000, 0)
+		response := &ntp.Packet{Settings: 0x24}
+		response.RxTimeSec, response.RxTimeFrac = ntp.Time(t1)
+		response.TxTimeSec, response.TxTimeFrac = ntp.Time(t1)
+		return &exchange{request: &ntp.Packet{}, response: response, t1: t1, t4: t1.Add(delay)}, nil
+	}
+	return p, queries
+}
+
+func TestFamilyAddresses(t *testing.T) {
+	p, _ := newDualStackProber(nil)
+	require.Equal(t, map[string]string{FamilyIPv4: addrIPv4, FamilyIPv6: addrIPv6}, p.familyAddresses("dual.example.com"))
+	require.Nil(t, p.familyAddresses("192.0.2.1"))
+	require.Nil(t, p.familyAddresses("unknown.example.com"))
+
+	p.lookup = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("192.0.2.1")}, nil
+	}
+	require.Nil(t, p.familyAddresses("dual.example.com"))
+}
+
+func TestProbeDualStackPrefersFaster(t *testing.T) {
+	p, queries := newDualStackProber(map[string]time.Duration{addrIPv4: time.Millisecond, addrIPv6: 2 * time.Millisecond})
+	r := p.Probe("dual.example.com")
+	require.True(t, r.Reachable)
+	require.Equal(t, time.Millisecond, r.Delay)
+	require.Empty(t, r.Family)
+
+	// only the preferred family is polled until the next race
+	for i := 1; i < raceEvery; i++ {
+		p.Probe("dual.example.com")
+	}
+	require.Equal(t, map[string]int{addrIPv4: raceEvery, addrIPv6: 1}, queries)
+	p.Probe("dual.example.com")
+	require.Equal(t, map[string]int{addrIPv4: raceEvery + 1, addrIPv6: 2}, queries)
+
+	results := p.FamilyResults()
+	require.Len(t, results, 2)
+	require.Equal(t, FamilyIPv4, results[0].Family)
+	require.True(t, results[0].Preferred)
+	require.Equal(t, int64(raceEvery+1), results[0].Probes)
+	require.Equal(t, FamilyIPv6, results[1].Family)
+	require.False(t, results[1].Preferred)
+	require.Equal(t, 2*time.Millisecond, results[1].Delay)
+	require.Equal(t, int64(raceEvery+1), p.Results()[0].Probes)
+}
+
+func TestProbeDualStackFailover(t *testing.T) {
+	// equally fast families prefer IPv6
+	delays := map[string]time.Duration{addrIPv4: time.Millisecond, addrIPv6: time.Millisecond}
+	p, queries := newDualStackProber(delays)
+	p.Probe("dual.example.com")
+	require.True(t, p.FamilyResults()[1].Preferred)
+
+	// preferred family fails, the target is unreachable until the next race
+	delete(delays, addrIPv6)
+	r := p.Probe("dual.example.com")
+	require.False(t, r.Reachable)
+	require.Equal(t, map[string]int{addrIPv4: 1, addrIPv6: 2}, queries)
+
+	r = p.Probe("dual.example.com")
+	require.True(t, r.Reachable)
+	require.Equal(t, map[string]int{addrIPv4: 2, addrIPv6: 3}, queries)
+	results := p.FamilyResults()
+	require.True(t, results[0].Preferred)
+	require.False(t, results[1].Reachable)
+	require.Equal(t, int64(2), results[1].Failures)
+}
+
+func TestProbeDualStackDisabled(t *testing.T) {
+	p, queries := newDualStackProber(map[string]time.Duration{"dual.example.com:123": time.Millisecond})
+	p.Config.DualStack = false
+	require.True(t, p.Probe("dual.example.com").Reachable)
+	require.Equal(t, map[string]int{"dual.example.com:123": 1}, queries)
+	require.Empty(t, p.FamilyResults())
+}
+
+func TestWritePrometheusFamilies(t *testing.T) {
+	p, _ := newDualStackProber(map[string]time.Duration{addrIPv4: time.Millisecond})
+	p.Probe("dual.example.com")
+	var buf bytes.Buffer
+	require.NoError(t, p.WritePrometheus(&buf))
+	out := buf.String()
+	for _, line := range []string{
+		`ntp_probe_success{target="dual.example.com"} 1`,
+		`ntp_probe_success{target="dual.example.com",family="ipv4"} 1`,
+		`ntp_probe_success{target="dual.example.com",family="ipv6"} 0`,
+		`ntp_probe_family_preferred{target="dual.example.com",family="ipv4"} 1`,
+		`ntp_probe_family_preferred{target="dual.example.com",family="ipv6"} 0`,
+	} {
+		require.Contains(t, out, line+"\n")
+	}
+	require.NotContains(t, out, `ntp_probe_family_preferred{target="dual.example.com"}`)
+}
@@ -0,0 +1,481 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package prober periodically queries NTP servers and exports offset, delay, stratum
+and reachability as Prometheus metrics, the NTP analog of blackbox_exporter.
+*/
+package prober
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/facebook/time/clock"
+	"github.com/facebook/time/ntp/journal"
+	"github.com/facebook/time/ntp/pool"
+	ntp "github.com/facebook/time/ntp/protocol"
+	log "github.com/sirupsen/logrus"
+)
+
+// Timestamping of the probes
+const (
+	HWTIMESTAMP        = "hardware"
+	SWTIMESTAMP        = "software"
+	USERSPACETIMESTAMP = "userspace"
+)
+
+// MetricsPrefix is the prefix of all exported metrics
+const MetricsPrefix = "ntp_probe_"
+
+// DefaultPoolInterval is how often pools are re-resolved
+const DefaultPoolInterval = time.Hour
+
+// Config of the prober
+type Config struct {
+	// Targets are host:port or host of NTP servers
+	Targets  []string
+	Interval time.Duration
+	Timeout  time.Duration
+	// Iface to enable hardware timestamps on. Falls back to software timestamps if they are not supported.
+	// Timestamps are taken in userspace if empty
+	Iface string
+	// HopLimit of the requests. System default if 0
+	HopLimit int
+	// FlowLabel of IPv6 requests. None if 0
+	FlowLabel uint32
+	// Clock to take userspace timestamps with. System clock if nil
+	Clock clock.Clock
+	// DualStack races queries over IPv4 and IPv6 to targets resolving to both,
+	// reporting each family separately and polling the healthier one
+	DualStack bool
+	// Pools are pool hostnames, each probed as a single target rotating through servers it resolves to
+	Pools []string
+	// PoolInterval between re-resolutions of pools. DefaultPoolInterval if 0
+	PoolInterval time.Duration
+}
+
+// Result of the last probe of the target
+type Result struct {
+	Target       string
+	Time         time.Time
+	Reachable    bool
+	Offset       time.Duration
+	Delay        time.Duration
+	Stratum      uint8
+	Timestamping string
+	// HopLimit of the last response, 0 if unknown
+	HopLimit int
+	Error    string
+	// Probes and Failures are totals since start
+	Probes   int64
+	Failures int64
+	// PathChanges counts responses arriving with a different hop limit than the previous one
+	PathChanges int64
+	// Family is set for per family results of dual stack targets
+	Family string
+	// Preferred is true if the family is polled between races
+	Preferred bool
+	// Address is the server of the pool queried by the last probe
+	Address string
+}
+
+// exchange is a single NTP request and response with the timestamps of both ends
+type exchange struct {
+	request      *ntp.Packet
+	response     *ntp.Packet
+	t1, t4       time.Time
+	timestamping string
+	hopLimit     int
+}
+
+type queryFunc func(address string, c *Config) (*exchange, error)
+
+// Prober queries NTP servers
+type Prober struct {
+	Config Config
+	// Journal records every exchange if set
+	Journal *journal.Writer
+	query   queryFunc
+	lookup  func(host string) ([]net.IP, error)
+
+	sync.Mutex
+	results map[string]*Result
+	// families are per family results of dual stack targets
+	families map[string]*Result
+	dual     map[string]*dualStack
+	// pools are keyed by hostname
+	pools map[string]*pool.Pool
+}
+
+// New returns prober for the config
+func New(c Config) *Prober {
+	p := &Prober{
+		Config:   c,
+		query:    query,
+		lookup:   net.LookupIP,
+		results:  map[string]*Result{},
+		families: map[string]*Result{},
+		dual:     map[string]*dualStack{},
+		pools:    map[string]*pool.Pool{},
+	}
+	if p.Config.PoolInterval <= 0 {
+		p.Config.PoolInterval = DefaultPoolInterval
+	}
+	for _, host := range c.Pools {
+		p.pools[host] = &pool.Pool{Host: host, Interval: p.Config.PoolInterval}
+	}
+	return p
+}
+
+// address adds default NTP port to the target if it has none
+func address(target string) string {
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(target, "123")
+}
+
+// Probe queries the target once and records the result
+func (p *Prober) Probe(target string) *Result {
+	if pl, ok := p.pools[target]; ok {
+		return p.probePool(target, pl)
+	}
+	if p.Config.DualStack {
+		if addrs := p.familyAddresses(target); addrs != nil {
+			return p.probeDualStack(target, addrs)
+		}
+	}
+	now := clock.Default(p.Config.Clock).Now()
+	e, err := p.query(address(target), &p.Config)
+	if err == nil {
+		p.write(target, e)
+	}
+	return p.observe(target, now, e, err)
+}
+
+// probePool queries the next server of the pool and reports the outcome back to the pool health scoring.
+// Pool is resolved on the first probe if it's not resolved yet
+func (p *Prober) probePool(target string, pl *pool.Pool) *Result {
+	now := clock.Default(p.Config.Clock).Now()
+	if len(pl.Servers()) == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), p.Config.Timeout)
+		if err := pl.Resolve(ctx); err != nil {
+			log.Debugf("[prober] failed to resolve pool %s: %v", target, err)
+		}
+		cancel()
+	}
+	addr, err := pl.Next()
+	var e *exchange
+	if err == nil {
+		if e, err = p.query(addr, &p.Config); err == nil {
+			p.write(target, e)
+		}
+	}
+	p.Lock()
+	defer p.Unlock()
+	r := update(p.results, target, target, now, e, err)
+	r.Address = addr
+	if addr != "" {
+		pl.Report(addr, r.Reachable)
+	}
+	return r
+}
+
+// resolvePools keeps pools resolved until ctx is done, retrying the initial resolution every PoolInterval
+func (p *Prober) resolvePools(ctx context.Context) {
+	for _, pl := range p.pools {
+		go func(pl *pool.Pool) {
+			for {
+				err := pl.Run(ctx)
+				if err == nil {
+					return
+				}
+				log.Warningf("[prober] failed to resolve pool %s: %v", pl.Host, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(p.Config.PoolInterval):
+				}
+			}
+		}(pl)
+	}
+}
+
+// write records the exchange in the journal if it's enabled
+func (p *Prober) write(target string, e *exchange) {
+	if p.Journal == nil {
+		return
+	}
+	if err := p.Journal.Write(e.record(target)); err != nil {
+		log.Errorf("[prober] failed to write journal: %v", err)
+	}
+}
+
+// Replay feeds exchanges recorded in the journal through the same analysis as live probes.
+// f, if not nil, is called with the result after every exchange
+func (p *Prober) Replay(r io.Reader, f func(Result)) error {
+	return journal.Replay(r, func(rec *journal.Record) error {
+		request, response, err := rec.Packets()
+		if err != nil {
+			return err
+		}
+		e := &exchange{request: request, response: response, t1: rec.Sent, t4: rec.Received, timestamping: rec.Timestamping, hopLimit: rec.HopLimit}
+		res := p.observe(rec.Target, rec.Sent, e, nil)
+		if f != nil {
+			p.Lock()
+			r := *res
+			p.Unlock()
+			f(r)
+		}
+		return nil
+	})
+}
+
+// record returns journal record of the exchange
+func (e *exchange) record(target string) *journal.Record {
+	r := &journal.Record{Target: target, Sent: e.t1, Received: e.t4, Timestamping: e.timestamping, HopLimit: e.hopLimit}
+	// packets were parsed from or serialized to bytes already, so errors are not expected here
+	r.Request, _ = e.request.Bytes()
+	r.Response, _ = e.response.Bytes()
+	return r
+}
+
+// observe updates the result of the target with the exchange done at the time
+func (p *Prober) observe(target string, now time.Time, e *exchange, err error) *Result {
+	p.Lock()
+	defer p.Unlock()
+	return update(p.results, target, target, now, e, err)
+}
+
+// update updates the result stored under the key with the exchange done at the time.
+// Must be called with the lock held
+func update(results map[string]*Result, key, target string, now time.Time, e *exchange, err error) *Result {
+	if err == nil && e.response.Settings&0xC0 == 0xC0 {
+		err = fmt.Errorf("server is not synchronized")
+	}
+
+	r, ok := results[key]
+	if !ok {
+		r = &Result{Target: target}
+		results[key] = r
+	}
+	r.Time = now
+	r.Probes++
+	if err != nil {
+		log.Debugf("[prober] %s: %v", target, err)
+		r.Failures++
+		r.Reachable = false
+		r.Error = err.Error()
+		return r
+	}
+	t2 := ntp.Unix(e.response.RxTimeSec, e.response.RxTimeFrac)
+	t3 := ntp.Unix(e.response.TxTimeSec, e.response.TxTimeFrac)
+	r.Reachable = true
+	r.Error = ""
+	r.Offset = (t2.Sub(e.t1) + t3.Sub(e.t4)) / 2
+	r.Delay = e.t4.Sub(e.t1) - t3.Sub(t2)
+	r.Stratum = e.response.Stratum
+	r.Timestamping = e.timestamping
+	if e.hopLimit != 0 {
+		if r.HopLimit != 0 && r.HopLimit != e.hopLimit {
+			log.Infof("[prober] %s: hop limit changed from %d to %d", target, r.HopLimit, e.hopLimit)
+			r.PathChanges++
+		}
+		r.HopLimit = e.hopLimit
+	}
+	return r
+}
+
+// ProbeAll queries all targets and pools in parallel
+func (p *Prober) ProbeAll() {
+	var wg sync.WaitGroup
+	for _, target := range append(append([]string{}, p.Config.Targets...), p.Config.Pools...) {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			p.Probe(target)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// Run probes all targets every interval until context is cancelled
+func (p *Prober) Run(ctx context.Context) error {
+	p.resolvePools(ctx)
+	for {
+		p.ProbeAll()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.Config.Interval):
+		}
+	}
+}
+
+// Results returns copy of the last results sorted by target
+func (p *Prober) Results() []Result {
+	p.Lock()
+	defer p.Unlock()
+	results := make([]Result, 0, len(p.results))
+	for _, r := range p.results {
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Target < results[j].Target })
+	return results
+}
+
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value func(r *Result) (float64, bool)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var metrics = []metric{
+	{"success", "gauge", "Whether the last probe succeeded", func(r *Result) (float64, bool) { return boolValue(r.Reachable), true }},
+	{"offset_seconds", "gauge", "Offset of the server clock", func(r *Result) (float64, bool) { return r.Offset.Seconds(), r.Reachable }},
+	{"delay_seconds", "gauge", "Round trip delay to the server", func(r *Result) (float64, bool) { return r.Delay.Seconds(), r.Reachable }},
+	{"stratum", "gauge", "Stratum of the server", func(r *Result) (float64, bool) { return float64(r.Stratum), r.Reachable }},
+	{"hardware_timestamps", "gauge", "Whether the last probe used hardware timestamps", func(r *Result) (float64, bool) { return boolValue(r.Timestamping == HWTIMESTAMP), r.Reachable }},
+	{"hop_limit", "gauge", "Hop limit of the last response", func(r *Result) (float64, bool) { return float64(r.HopLimit), r.Reachable && r.HopLimit != 0 }},
+	{"timestamp_seconds", "gauge", "Unix time of the last probe", func(r *Result) (float64, bool) { return float64(r.Time.UnixNano()) / 1e9, true }},
+	{"probes_total", "counter", "Probes sent", func(r *Result) (float64, bool) { return float64(r.Probes), true }},
+	{"failures_total", "counter", "Probes failed", func(r *Result) (float64, bool) { return float64(r.Failures), true }},
+	{"path_changes_total", "counter", "Changes of the response hop limit", func(r *Result) (float64, bool) { return float64(r.PathChanges), true }},
+	{"family_preferred", "gauge", "Whether the address family of dual stack target is polled", func(r *Result) (float64, bool) { return boolValue(r.Preferred), r.Family != "" }},
+}
+
+// labels returns Prometheus labels of the result
+func (r *Result) labels() string {
+	if r.Family == "" {
+		return fmt.Sprintf("target=%q", r.Target)
+	}
+	return fmt.Sprintf("target=%q,family=%q", r.Target, r.Family)
+}
+
+// WritePrometheus writes the last results in Prometheus text exposition format
+func (p *Prober) WritePrometheus(w io.Writer) error {
+	results := append(p.Results(), p.FamilyResults()...)
+	for _, m := range metrics {
+		name := MetricsPrefix + m.name
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, m.help, name, m.kind); err != nil {
+			return err
+		}
+		for i := range results {
+			v, ok := m.value(&results[i])
+			if !ok {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s{%s} %s\n", name, results[i].labels(), strconv.FormatFloat(v, 'g', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves metrics
+func (p *Prober) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := p.WritePrometheus(w); err != nil {
+		log.Errorf("[prober] failed to write metrics: %v", err)
+	}
+}
+
+// tune enables receiving of hop limit and applies configured hop limit and flow label to the connection.
+// It returns control message to send requests with
+func (c *Config) tune(conn *net.UDPConn) ([]byte, error) {
+	opts := []ntp.SocketOption{ntp.WithRecvTTL()}
+	if c.HopLimit > 0 {
+		opts = append(opts, ntp.WithHopLimit(c.HopLimit))
+	}
+	var oob []byte
+	if raddr, ok := conn.RemoteAddr().(*net.UDPAddr); ok && c.FlowLabel != 0 && raddr.IP.To4() == nil {
+		opts = append(opts, ntp.WithFlowLabel(c.FlowLabel))
+		oob = ntp.FlowLabelControlMessage(c.FlowLabel)
+	}
+	return oob, ntp.TuneSocket(conn, opts...)
+}
+
+// queryUserspace sends request and takes timestamps in userspace
+func queryUserspace(address string, c *Config) (*exchange, error) {
+	raddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return nil, err
+	}
+	oob, err := c.tune(conn)
+	if err != nil {
+		return nil, err
+	}
+	request, b, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	clk := clock.Default(c.Clock)
+	t1 := clk.Now()
+	if _, err := ntp.WriteWithControl(conn, b, nil, nil, oob); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	buf := make([]byte, ntp.PacketSizeBytes)
+	roob := make([]byte, ntp.ControlHeaderSizeBytes)
+	n, oobn, _, _, err := conn.ReadMsgUDP(buf, roob)
+	t4 := clk.Now()
+	if err != nil {
+		return nil, err
+	}
+	response, err := ntp.BytesToPacket(buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	if err := ntp.MatchOrigin(request, response); err != nil {
+		return nil, err
+	}
+	hopLimit, _ := ntp.ParseTTL(roob[:oobn])
+	return &exchange{request: request, response: response, t1: t1, t4: t4, timestamping: USERSPACETIMESTAMP, hopLimit: hopLimit}, nil
+}
+
+// newRequest returns client request with random origin protecting against off-path spoofing
+func newRequest() (*ntp.Packet, []byte, error) {
+	request := &ntp.Packet{Settings: 0x23}
+	if err := request.SetRandomTransmitTime(); err != nil {
+		return nil, nil, err
+	}
+	b, err := request.Bytes()
+	return request, b, err
+}
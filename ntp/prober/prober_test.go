@@ -0,0 +1,249 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebook/time/clock"
+	"github.com/facebook/time/ntp/journal"
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer answers NTP requests with the time shifted by offset
+func startServer(t *testing.T, offset time.Duration) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		buf := make([]byte, ntp.PacketSizeBytes)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := ntp.BytesToPacket(buf)
+			if err != nil {
+				continue
+			}
+			now := time.Now().Add(offset)
+			response := &ntp.Packet{Settings: 0x24, Stratum: 2, OrigTimeSec: request.TxTimeSec, OrigTimeFrac: request.TxTimeFrac}
+			response.RxTimeSec, response.RxTimeFrac = ntp.Time(now)
+			response.TxTimeSec, response.TxTimeFrac = ntp.Time(now)
+			b, _ := response.Bytes()
+			_, _ = conn.WriteTo(b, addr)
+		}
+	}()
+	return conn
+}
+
+func TestAddress(t *testing.T) {
+	require.Equal(t, "time.example.com:123", address("time.example.com"))
+	require.Equal(t, "[2001:db8::1]:123", address("2001:db8::1"))
+	require.Equal(t, "127.0.0.1:1123", address("127.0.0.1:1123"))
+}
+
+func TestProbeUserspace(t *testing.T) {
+	server := startServer(t, time.Second)
+	defer server.Close()
+
+	p := New(Config{Targets: []string{server.LocalAddr().String()}, Timeout: time.Second})
+	r := p.Probe(server.LocalAddr().String())
+	require.Empty(t, r.Error)
+	require.True(t, r.Reachable)
+	require.Equal(t, uint8(2), r.Stratum)
+	require.Equal(t, USERSPACETIMESTAMP, r.Timestamping)
+	require.InDelta(t, time.Second, r.Offset, float64(50*time.Millisecond))
+	require.Less(t, r.Delay, 50*time.Millisecond)
+	require.NotZero(t, r.HopLimit)
+}
+
+func TestProbeSimulatedClock(t *testing.T) {
+	server := startServer(t, 0)
+	defer server.Close()
+
+	// local clock is a second behind
+	sim := clock.NewSimulated(time.Now())
+	sim.Step(-time.Second)
+	p := New(Config{Timeout: time.Second, Clock: sim})
+	r := p.Probe(server.LocalAddr().String())
+	require.True(t, r.Reachable)
+	require.Equal(t, sim.Now(), r.Time)
+	require.InDelta(t, time.Second, r.Offset, float64(50*time.Millisecond))
+	require.Equal(t, time.Duration(0), r.Delay)
+}
+
+func TestProbePathChanges(t *testing.T) {
+	hops := []int{60, 60, 0, 58}
+	p := New(Config{Targets: []string{"a"}})
+	p.query = func(address string, c *Config) (*exchange, error) {
+		e := &exchange{response: &ntp.Packet{Settings: 0x24}, hopLimit: hops[0]}
+		hops = hops[1:]
+		return e, nil
+	}
+	var r *Result
+	for i := 0; i < 4; i++ {
+		r = p.Probe("a")
+	}
+	require.Equal(t, 58, r.HopLimit)
+	require.Equal(t, int64(1), r.PathChanges)
+}
+
+func TestProbeFailure(t *testing.T) {
+	p := New(Config{Targets: []string{"a", "b"}})
+	p.query = func(address string, c *Config) (*exchange, error) {
+		if address == "a:123" {
+			return nil, errors.New("timeout")
+		}
+		return &exchange{response: &ntp.Packet{Settings: 0xE4}}, nil
+	}
+	p.ProbeAll()
+	p.ProbeAll()
+	results := p.Results()
+	require.Len(t, results, 2)
+	require.Equal(t, "a", results[0].Target)
+	require.Equal(t, "timeout", results[0].Error)
+	require.Equal(t, "server is not synchronized", results[1].Error)
+	for _, r := range results {
+		require.False(t, r.Reachable)
+		require.Equal(t, int64(2), r.Probes)
+		require.Equal(t, int64(2), r.Failures)
+	}
+}
+
+type poolResolver map[string][]string
+
+func (r poolResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return "", nil, errors.New("no SRV")
+}
+
+func (r poolResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return ips, nil
+}
+
+func TestProbePool(t *testing.T) {
+	p := New(Config{Targets: []string{"a"}, Pools: []string{"pool.example.com", "unknown.example.com"}, Timeout: time.Second})
+	require.Equal(t, DefaultPoolInterval, p.Config.PoolInterval)
+	for _, pl := range p.pools {
+		pl.Resolver = poolResolver{"pool.example.com": {"192.0.2.1", "192.0.2.2"}}
+	}
+	queried := []string{}
+	p.query = func(address string, c *Config) (*exchange, error) {
+		queried = append(queried, address)
+		if address == "192.0.2.1:123" {
+			return nil, errors.New("timeout")
+		}
+		return &exchange{response: &ntp.Packet{Settings: 0x24, Stratum: 1}}, nil
+	}
+	for i := 0; i < 6; i++ {
+		p.Probe("pool.example.com")
+	}
+	// failing server is skipped once it's unhealthy
+	require.Equal(t, []string{"192.0.2.1:123", "192.0.2.2:123", "192.0.2.1:123", "192.0.2.2:123", "192.0.2.1:123", "192.0.2.2:123"}, queried)
+	r := p.Probe("pool.example.com")
+	require.True(t, r.Reachable)
+	require.Equal(t, "pool.example.com", r.Target)
+	require.Equal(t, "192.0.2.2:123", r.Address)
+	require.Equal(t, int64(7), r.Probes)
+	require.Equal(t, int64(3), r.Failures)
+
+	r = p.Probe("unknown.example.com")
+	require.False(t, r.Reachable)
+	require.Equal(t, "no servers resolved", r.Error)
+
+	p.ProbeAll()
+	require.Len(t, p.Results(), 3)
+}
+
+func TestJournalReplay(t *testing.T) {
+	server := startServer(t, time.Second)
+	defer server.Close()
+	target := server.LocalAddr().String()
+
+	var buf bytes.Buffer
+	w, err := journal.NewWriter(&buf)
+	require.NoError(t, err)
+	p := New(Config{Targets: []string{target}, Timeout: time.Second})
+	p.Journal = w
+	live := []Result{}
+	for i := 0; i < 2; i++ {
+		live = append(live, *p.Probe(target))
+	}
+
+	replayed := []Result{}
+	r := New(Config{})
+	require.NoError(t, r.Replay(bytes.NewReader(buf.Bytes()), func(res Result) {
+		replayed = append(replayed, res)
+	}))
+	require.Len(t, replayed, 2)
+	for i := range live {
+		require.True(t, live[i].Reachable)
+		require.Equal(t, live[i].Offset, replayed[i].Offset)
+		// live delay is measured with monotonic clock, journal keeps wall clock
+		require.InDelta(t, live[i].Delay, replayed[i].Delay, float64(10*time.Microsecond))
+		require.Equal(t, live[i].Stratum, replayed[i].Stratum)
+		require.Equal(t, live[i].HopLimit, replayed[i].HopLimit)
+		require.Equal(t, USERSPACETIMESTAMP, replayed[i].Timestamping)
+	}
+	require.Equal(t, int64(2), r.Results()[0].Probes)
+}
+
+func TestWritePrometheus(t *testing.T) {
+	p := New(Config{})
+	p.results = map[string]*Result{
+		"b": {Target: "b", Time: time.Unix(1600000000, 0), Probes: 3, Failures: 3},
+		"a": {Target: "a", Time: time.Unix(1600000000, 500000000), Reachable: true, Offset: -1500 * time.Microsecond, Delay: 200 * time.Microsecond, Stratum: 1, Timestamping: HWTIMESTAMP, HopLimit: 62, Probes: 3, PathChanges: 2},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, p.WritePrometheus(&buf))
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE ntp_probe_success gauge",
+		`ntp_probe_success{target="a"} 1`,
+		`ntp_probe_success{target="b"} 0`,
+		`ntp_probe_offset_seconds{target="a"} -0.0015`,
+		`ntp_probe_delay_seconds{target="a"} 0.0002`,
+		`ntp_probe_stratum{target="a"} 1`,
+		`ntp_probe_hardware_timestamps{target="a"} 1`,
+		`ntp_probe_hop_limit{target="a"} 62`,
+		`ntp_probe_path_changes_total{target="a"} 2`,
+		`ntp_probe_timestamp_seconds{target="a"} 1.6000000005e+09`,
+		"# TYPE ntp_probe_failures_total counter",
+		`ntp_probe_failures_total{target="b"} 3`,
+	} {
+		require.Contains(t, out, line+"\n")
+	}
+	// unreachable targets have no measurements
+	require.NotContains(t, out, `ntp_probe_offset_seconds{target="b"}`)
+	require.NotContains(t, out, `ntp_probe_hop_limit{target="b"}`)
+	require.Less(t, strings.Index(out, `success{target="a"}`), strings.Index(out, `success{target="b"}`))
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, out, rec.Body.String())
+}
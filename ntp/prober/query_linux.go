@@ -0,0 +1,95 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"fmt"
+	"net"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/timestamp"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// query sends request with hardware or software socket timestamps if iface is set
+func query(address string, c *Config) (*exchange, error) {
+	if c.Iface == "" {
+		return queryUserspace(address, c)
+	}
+	raddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	oob, err := c.tune(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	connFd, err := timestamp.ConnFd(conn)
+	if err != nil {
+		return nil, err
+	}
+	ts := HWTIMESTAMP
+	if err := timestamp.EnableHWTimestampsSocket(connFd, c.Iface); err != nil {
+		log.Debugf("[prober] failed to enable hardware timestamps on %s, falling back to software timestamps: %v", c.Iface, err)
+		if err := timestamp.EnableSWTimestampsSocket(connFd); err != nil {
+			return nil, fmt.Errorf("failed to enable timestamps: %w", err)
+		}
+		ts = SWTIMESTAMP
+	}
+	// recvmsg is used directly, it must block until the timeout
+	if err := unix.SetNonblock(connFd, false); err != nil {
+		return nil, err
+	}
+	tv := unix.NsecToTimeval(c.Timeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(connFd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+
+	request, b, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Sendmsg(connFd, b, oob, nil, 0); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	t1, _, err := timestamp.ReadTXtimestamp(connFd)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, timestamp.PayloadSizeBytes)
+	roob := make([]byte, timestamp.ControlSizeBytes)
+	n, oobn, _, t4, err := timestamp.ReadPacketWithRXTimestampOOB(connFd, buf, roob)
+	if err != nil {
+		return nil, err
+	}
+	response, err := ntp.BytesToPacket(buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	if err := ntp.MatchOrigin(request, response); err != nil {
+		return nil, err
+	}
+	hopLimit, _ := ntp.ParseTTL(roob[:oobn])
+	return &exchange{request: request, response: response, t1: t1, t4: t4, timestamping: ts, hopLimit: hopLimit}, nil
+}
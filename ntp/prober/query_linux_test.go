@@ -0,0 +1,46 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuerySocketTimestamps(t *testing.T) {
+	server := startServer(t, -time.Second)
+	defer server.Close()
+
+	// loopback has no hardware timestamps, software timestamps are used instead
+	e, err := query(server.LocalAddr().String(), &Config{Iface: "lo", Timeout: time.Second, HopLimit: 3})
+	require.NoError(t, err)
+	require.Equal(t, SWTIMESTAMP, e.timestamping)
+	require.True(t, e.t4.After(e.t1))
+	require.Less(t, e.t4.Sub(e.t1), 50*time.Millisecond)
+	require.NotZero(t, e.hopLimit)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	server := startServer(t, 0)
+	addr := server.LocalAddr().String()
+	server.Close()
+
+	_, err := query(addr, &Config{Iface: "lo", Timeout: 100 * time.Millisecond})
+	require.Error(t, err)
+}
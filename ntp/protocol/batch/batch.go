@@ -0,0 +1,86 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batch
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/facebook/time/ntp/protocol"
+	"golang.org/x/net/ipv4"
+)
+
+var errShortMessage = errors.New("message has no buffers")
+
+// Reader reads multiple messages with a single syscall.
+// Both ipv4.PacketConn and ipv6.PacketConn implement it as they share the Message type
+type Reader interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// Writer writes multiple messages with a single syscall.
+// Both ipv4.PacketConn and ipv6.PacketConn implement it
+type Writer interface {
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// NewMessages allocates n messages with buffers big enough for NTP packet, kernel timestamp and destination address
+func NewMessages(n int) []ipv4.Message {
+	ms := make([]ipv4.Message, n)
+	for i := range ms {
+		ms[i].Buffers = [][]byte{make([]byte, protocol.PacketSizeBytes)}
+		ms[i].OOB = make([]byte, protocol.PacketInfoControlSizeBytes)
+	}
+	return ms
+}
+
+// ParseMessage decodes NTP packet, kernel timestamp, remote and destination addresses from the message received by ReadBatch
+func ParseMessage(m *ipv4.Message) (ntp *protocol.Packet, kernelRxTime time.Time, remAddr *net.UDPAddr, dst net.IP, err error) {
+	if len(m.Buffers) == 0 {
+		return nil, time.Time{}, nil, nil, errShortMessage
+	}
+	kernelRxTime, dst, err = protocol.ParseControlMessage(m.OOB[:m.NN])
+	if err != nil {
+		return nil, time.Time{}, nil, nil, err
+	}
+	remAddr, _ = m.Addr.(*net.UDPAddr)
+	ntp, err = protocol.BytesToPacket(m.Buffers[0][:m.N])
+	return ntp, kernelRxTime, remAddr, dst, err
+}
+
+// ReadWithKernelTimestamp reads up to len(ms) messages and returns how many were read.
+// Use ParseMessage to decode each of them.
+// Requires protocol.EnableKernelTimestampsSocket and optionally protocol.EnablePacketInfo on the underlying connection
+func ReadWithKernelTimestamp(r Reader, ms []ipv4.Message) (int, error) {
+	for i := range ms {
+		// OOB may have been truncated or reused for sending
+		ms[i].OOB = ms[i].OOB[:cap(ms[i].OOB)]
+		ms[i].N, ms[i].NN, ms[i].Flags = 0, 0, 0
+	}
+	return r.ReadBatch(ms, 0)
+}
+
+// SetMessageSource sets control message of m so it's sent from the src address with WriteBatch.
+// Default source address is used if src is nil, unspecified or multicast
+func SetMessageSource(m *ipv4.Message, src net.IP) {
+	if src == nil || src.IsUnspecified() || src.IsMulticast() {
+		m.OOB = nil
+		return
+	}
+	m.OOB = protocol.SourceControlMessage(src)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batch
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/ipv4"
+)
+
+func TestReadWithKernelTimestamp(t *testing.T) {
+	request := &protocol.Packet{Settings: 0xE3, Poll: 3, Precision: -6, TxTimeSec: 3794210679, TxTimeFrac: 2718216404}
+	requestBytes, err := request.Bytes()
+	require.NoError(t, err)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, protocol.EnableKernelTimestampsSocket(conn))
+	pc := ipv4.NewPacketConn(conn)
+	// destination via x/net must work together with our timestamping
+	require.NoError(t, pc.SetControlMessage(ipv4.FlagDst, true))
+
+	dst := &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: conn.LocalAddr().(*net.UDPAddr).Port}
+	cconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer cconn.Close()
+	for i := 0; i < 2; i++ {
+		_, err = cconn.WriteToUDP(requestBytes, dst)
+		require.NoError(t, err)
+	}
+
+	ms := NewMessages(4)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	read := 0
+	for read < 2 {
+		n, err := ReadWithKernelTimestamp(pc, ms[read:])
+		require.NoError(t, err)
+		read += n
+	}
+	for _, m := range ms[:read] {
+		packet, rxTimestamp, returnaddr, dstIP, err := ParseMessage(&m)
+		require.NoError(t, err)
+		require.Equal(t, request, packet)
+		require.Equal(t, time.Now().Unix()/10, rxTimestamp.Unix()/10, "kernel timestamps should be within 10s")
+		require.Equal(t, cconn.LocalAddr().String(), returnaddr.String())
+		require.True(t, dst.IP.Equal(dstIP))
+	}
+
+	// reply must come from the address request was sent to
+	reply := []ipv4.Message{{Buffers: [][]byte{requestBytes}, Addr: cconn.LocalAddr()}}
+	SetMessageSource(&reply[0], dst.IP)
+	var w Writer = pc
+	n, err := w.WriteBatch(reply, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.NoError(t, cconn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, protocol.PacketSizeBytes)
+	_, from, err := cconn.ReadFromUDP(buf)
+	require.NoError(t, err)
+	require.Equal(t, dst.String(), from.String())
+}
+
+func TestParseMessageNoBuffers(t *testing.T) {
+	_, _, _, _, err := ParseMessage(&ipv4.Message{})
+	require.ErrorIs(t, err, errShortMessage)
+}
+
+func TestSetMessageSourceDefault(t *testing.T) {
+	m := &ipv4.Message{OOB: []byte{1}}
+	SetMessageSource(m, net.IPv4zero)
+	require.Nil(t, m.OOB)
+}
@@ -0,0 +1,128 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// NTP mode numbers relevant for broadcast/manycast
+const (
+	modeServer    = 4
+	modeBroadcast = 5
+	liUnsync      = 3
+)
+
+var (
+	// MulticastGroupIPv4 is the IANA assigned NTP multicast group
+	MulticastGroupIPv4 = net.IPv4(224, 0, 1, 1)
+	// MulticastGroupIPv6 is the site-local NTP multicast group
+	MulticastGroupIPv6 = net.ParseIP("ff05::101")
+)
+
+var errNotBroadcast = errors.New("not a valid broadcast packet")
+
+// Mode returns mode of the packet
+func (p *Packet) Mode() uint8 {
+	return p.Settings & 0x7
+}
+
+// Version returns protocol version of the packet
+func (p *Packet) Version() uint8 {
+	return (p.Settings >> 3) & 0x7
+}
+
+// Leap returns leap indicator of the packet
+func (p *Packet) Leap() uint8 {
+	return p.Settings >> 6
+}
+
+// ValidBroadcastFormat verifies that LI | VN  |Mode fields of the broadcast packet are set correctly
+// LI: must not be 3 (unsynchronized server)
+// VN: must be 1,2,3 or 4
+// Mode: must be 5
+func (p *Packet) ValidBroadcastFormat() bool {
+	v := p.Version()
+	return p.Leap() != liUnsync && v >= vnFirst && v <= vnLast && p.Mode() == modeBroadcast
+}
+
+// SetBroadcast turns packet into a broadcast (mode 5) packet transmitted at now
+// Stratum, Precision, Root delay/dispersion and Reference ID are left untouched
+func (p *Packet) SetBroadcast(version uint8, poll int8, now time.Time) {
+	p.Settings = version<<3 | modeBroadcast
+	p.Poll = poll
+	// there is no request to originate from
+	p.OrigTimeSec, p.OrigTimeFrac = 0, 0
+	p.RxTimeSec, p.RxTimeFrac = 0, 0
+	p.TxTimeSec, p.TxTimeFrac = Time(now)
+	// Same logic as responder uses. Once per 1000s is consistent enough
+	p.RefTimeSec, p.RefTimeFrac = Time(time.Unix(now.Unix()/1000*1000, 0))
+}
+
+// BroadcastOffset returns offset between server and local clocks based on broadcast packet.
+// As there is no round trip, one-way network delay has to be provided (calibrated or estimated)
+func BroadcastOffset(p *Packet, received time.Time, delay time.Duration) time.Duration {
+	serverTime := Unix(p.TxTimeSec, p.TxTimeFrac).Add(delay)
+	return serverTime.Sub(received)
+}
+
+// EnableBroadcast allows sending packets to the broadcast address via socket
+func EnableBroadcast(conn *net.UDPConn) error {
+	connfd, err := connFd(conn)
+	if err != nil {
+		return err
+	}
+	return syscall.SetsockoptInt(connfd, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+}
+
+// ListenBroadcast opens a socket to receive broadcast or multicast (if ip is multicast group) NTP packets.
+// iface is used to join multicast group. Nil means system default
+func ListenBroadcast(ip net.IP, port int, iface *net.Interface) (*net.UDPConn, error) {
+	var conn *net.UDPConn
+	var err error
+	addr := &net.UDPAddr{IP: ip, Port: port}
+	if ip.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", iface, addr)
+	} else {
+		// broadcast is received on wildcard address
+		conn, err = net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := EnableKernelTimestampsSocket(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// ReadBroadcastPacket reads broadcast NTP packet along with kernel receive timestamp
+func ReadBroadcastPacket(conn *net.UDPConn) (ntp *Packet, kernelRxTime time.Time, remAddr net.Addr, err error) {
+	ntp, kernelRxTime, remAddr, err = ReadPacketWithKernelTimestamp(conn)
+	if err != nil {
+		return nil, time.Time{}, nil, err
+	}
+	if !ntp.ValidBroadcastFormat() {
+		return nil, time.Time{}, remAddr, errNotBroadcast
+	}
+	return ntp, kernelRxTime, remAddr, nil
+}
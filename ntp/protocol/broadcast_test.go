@@ -0,0 +1,95 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketFields(t *testing.T) {
+	// LI 3, VN 4, Mode 3
+	require.Equal(t, uint8(3), ntpRequest.Leap())
+	require.Equal(t, uint8(4), ntpRequest.Version())
+	require.Equal(t, uint8(3), ntpRequest.Mode())
+}
+
+func TestSetBroadcast(t *testing.T) {
+	now := time.Unix(usec, unsec)
+	p := &Packet{Stratum: 1, OrigTimeSec: 42, RxTimeSec: 42}
+	p.SetBroadcast(4, 6, now)
+
+	require.Equal(t, uint8(0x25), p.Settings)
+	require.Equal(t, uint8(modeBroadcast), p.Mode())
+	require.Equal(t, int8(6), p.Poll)
+	require.Equal(t, uint8(1), p.Stratum)
+	require.Equal(t, uint32(0), p.OrigTimeSec)
+	require.Equal(t, uint32(0), p.RxTimeSec)
+	require.Equal(t, nsec, p.TxTimeSec)
+	require.Equal(t, nfrac, p.TxTimeFrac)
+	require.True(t, p.ValidBroadcastFormat())
+}
+
+func TestValidBroadcastFormat(t *testing.T) {
+	require.False(t, ntpRequest.ValidBroadcastFormat())
+	require.False(t, ntpResponse.ValidBroadcastFormat())
+	// unsynchronized
+	require.False(t, (&Packet{Settings: 0xE5}).ValidBroadcastFormat())
+	// version 0
+	require.False(t, (&Packet{Settings: 0x05}).ValidBroadcastFormat())
+	require.True(t, (&Packet{Settings: 0x1D}).ValidBroadcastFormat())
+}
+
+func TestBroadcastOffset(t *testing.T) {
+	sent := time.Unix(usec, unsec)
+	p := &Packet{}
+	p.SetBroadcast(4, 6, sent)
+	received := sent.Add(forwardDelay).Add(-offset)
+
+	require.InDelta(t, offset.Nanoseconds(), BroadcastOffset(p, received, forwardDelay).Nanoseconds(), 1)
+}
+
+func TestListenReadBroadcast(t *testing.T) {
+	conn, err := ListenBroadcast(net.ParseIP("127.0.0.1"), 0, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, EnableBroadcast(conn))
+
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	p := &Packet{Stratum: 1}
+	p.SetBroadcast(4, 6, time.Now())
+	b, err := p.Bytes()
+	require.NoError(t, err)
+	_, err = client.Write(b)
+	require.NoError(t, err)
+
+	received, rxTime, _, err := ReadBroadcastPacket(conn)
+	require.NoError(t, err)
+	require.Equal(t, p, received)
+	require.False(t, rxTime.IsZero())
+
+	_, err = client.Write(ntpRequestBytes)
+	require.NoError(t, err)
+	_, _, _, err = ReadBroadcastPacket(conn)
+	require.ErrorIs(t, err, errNotBroadcast)
+}
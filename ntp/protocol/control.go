@@ -0,0 +1,51 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"net"
+	"time"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// ParseControlMessage extracts kernel timestamp and destination address from raw control messages.
+// It understands anything enabled by EnableKernelTimestampsSocket and EnablePacketInfo,
+// as well as IP_PKTINFO/IPV6_PKTINFO enabled via x/net SetControlMessage(FlagDst, true).
+// Zero time and nil address are returned if corresponding control message is missing
+func ParseControlMessage(oob []byte) (rxTime time.Time, dst net.IP, err error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	for _, m := range msgs {
+		if ts, ok := parseTimestamp(m); ok {
+			rxTime = ts
+			continue
+		}
+		if ip := parseDst(m); ip != nil {
+			dst = ip
+		}
+	}
+	return rxTime, dst, nil
+}
+
+// SourceControlMessage returns control message to send from the src address,
+// suitable for WriteMsgUDP or OOB of x/net batch messages
+func SourceControlMessage(src net.IP) []byte {
+	return srcControlMessage(src)
+}
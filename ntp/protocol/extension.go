@@ -0,0 +1,129 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// extensionHeaderSize is the size of Field Type and Length
+const extensionHeaderSize = 4
+
+// extensionMinLastSize is the minimum size of the last extension field when there is no MAC (RFC 7822)
+const extensionMinLastSize = 28
+
+// ExtensionPadding is an experimental extension field type carrying only zeros, used to pad responses to the request size.
+// It's taken from the range not assigned by IANA, so it may change
+const ExtensionPadding uint16 = 0xF5EB
+
+var errExtensionTooShort = errors.New("extension field is too short")
+
+// ExtensionField is an NTPv4 extension field as described in RFC 7822
+/*
+   0                   1                   2                   3
+   0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  |          Field Type           |            Length             |
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  .                                                               .
+  .                            Value                              .
+  .                                                               .
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  |                       Padding (as needed)                     |
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+*/
+type ExtensionField struct {
+	Type  uint16
+	Value []byte
+}
+
+// size returns size of the field padded to 4 bytes, but not less than min
+func (e *ExtensionField) size(min int) int {
+	size := extensionHeaderSize + len(e.Value)
+	if rem := size % 4; rem != 0 {
+		size += 4 - rem
+	}
+	if size < min {
+		size = min
+	}
+	return size
+}
+
+// MarshalExtensionFields encodes extension fields to be appended to the packet.
+// Last field is padded to 28 bytes as there is no MAC
+func MarshalExtensionFields(fields []ExtensionField) []byte {
+	total := 0
+	for i := range fields {
+		min := 0
+		if i == len(fields)-1 {
+			min = extensionMinLastSize
+		}
+		total += fields[i].size(min)
+	}
+	b := make([]byte, total)
+	pos := 0
+	for i, f := range fields {
+		min := 0
+		if i == len(fields)-1 {
+			min = extensionMinLastSize
+		}
+		size := f.size(min)
+		binary.BigEndian.PutUint16(b[pos:], f.Type)
+		binary.BigEndian.PutUint16(b[pos+2:], uint16(size))
+		copy(b[pos+extensionHeaderSize:], f.Value)
+		pos += size
+	}
+	return b
+}
+
+// PadExtensionFields appends ExtensionPadding field to fields so they are encoded into exactly size bytes.
+// False is returned if there is not enough room for the padding field
+func PadExtensionFields(fields []ExtensionField, size int) ([]ExtensionField, bool) {
+	used := 0
+	for i := range fields {
+		used += fields[i].size(0)
+	}
+	pad := size - used
+	if pad < extensionMinLastSize || pad%4 != 0 {
+		return nil, false
+	}
+	padded := append([]ExtensionField{}, fields...)
+	return append(padded, ExtensionField{Type: ExtensionPadding, Value: make([]byte, pad-extensionHeaderSize)}), true
+}
+
+// ParseExtensionFields decodes extension fields following the packet header.
+// Value includes padding as receiver can't tell it apart
+func ParseExtensionFields(b []byte) ([]ExtensionField, error) {
+	fields := []ExtensionField{}
+	for len(b) > 0 {
+		if len(b) < extensionHeaderSize {
+			return nil, errExtensionTooShort
+		}
+		size := int(binary.BigEndian.Uint16(b[2:]))
+		if size < extensionHeaderSize || size%4 != 0 || size > len(b) {
+			return nil, fmt.Errorf("invalid extension field length %d", size)
+		}
+		fields = append(fields, ExtensionField{
+			Type:  binary.BigEndian.Uint16(b),
+			Value: b[extensionHeaderSize:size],
+		})
+		b = b[size:]
+	}
+	return fields, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalExtensionFields(t *testing.T) {
+	fields := []ExtensionField{
+		{Type: 0x0104, Value: []byte{1, 2, 3, 4, 5}},
+		{Type: 0x0204, Value: []byte{6}},
+	}
+	b := MarshalExtensionFields(fields)
+	// first is padded to 4 bytes, last to 28 bytes
+	require.Equal(t, 12+28, len(b))
+	require.Equal(t, []byte{0x01, 0x04, 0, 12, 1, 2, 3, 4, 5, 0, 0, 0}, b[:12])
+	require.Equal(t, []byte{0x02, 0x04, 0, 28, 6}, b[12:17])
+
+	parsed, err := ParseExtensionFields(b)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(parsed))
+	require.Equal(t, uint16(0x0104), parsed[0].Type)
+	require.Equal(t, []byte{1, 2, 3, 4, 5, 0, 0, 0}, parsed[0].Value)
+	require.Equal(t, uint16(0x0204), parsed[1].Type)
+	require.Equal(t, 24, len(parsed[1].Value))
+}
+
+func TestParseExtensionFieldsError(t *testing.T) {
+	_, err := ParseExtensionFields([]byte{1, 2})
+	require.ErrorIs(t, err, errExtensionTooShort)
+	// length is not a multiple of 4
+	_, err = ParseExtensionFields([]byte{1, 2, 0, 5, 0, 0, 0, 0})
+	require.Error(t, err)
+	// length is bigger than the data
+	_, err = ParseExtensionFields([]byte{1, 2, 0, 16, 0, 0, 0, 0})
+	require.Error(t, err)
+}
+
+func TestPadExtensionFields(t *testing.T) {
+	fields := []ExtensionField{{Type: 0x0104, Value: []byte{1, 2, 3, 4, 5}}}
+	padded, ok := PadExtensionFields(fields, 48)
+	require.True(t, ok)
+	require.Len(t, padded, 2)
+	require.Len(t, fields, 1)
+	require.Equal(t, ExtensionPadding, padded[1].Type)
+	b := MarshalExtensionFields(padded)
+	require.Equal(t, 48, len(b))
+	require.Equal(t, []byte{0xF5, 0xEB, 0, 36}, b[12:16])
+
+	padded, ok = PadExtensionFields(nil, 28)
+	require.True(t, ok)
+	require.Equal(t, 28, len(MarshalExtensionFields(padded)))
+
+	// not enough room
+	_, ok = PadExtensionFields(fields, 36)
+	require.False(t, ok)
+	// not a multiple of 4
+	_, ok = PadExtensionFields(nil, 30)
+	require.False(t, ok)
+}
+
+func TestSmearInfo(t *testing.T) {
+	s := &SmearInfo{
+		Active:   true,
+		Start:    time.Unix(1483185600, 0),
+		Duration: 24 * time.Hour,
+		Offset:   -250 * time.Millisecond,
+	}
+	packet, err := ntpResponse.Bytes()
+	require.NoError(t, err)
+	packet = append(packet, MarshalExtensionFields([]ExtensionField{s.ExtensionField()})...)
+
+	parsed, err := SmearInfoFromBytes(packet)
+	require.NoError(t, err)
+	require.Equal(t, s.Active, parsed.Active)
+	require.True(t, s.Start.Equal(parsed.Start))
+	require.Equal(t, s.Duration, parsed.Duration)
+	require.Equal(t, s.Offset, parsed.Offset)
+
+	now := time.Unix(1483228800, 0)
+	require.Equal(t, now.Add(250*time.Millisecond), parsed.Unsmear(now))
+}
+
+func TestSmearInfoFromBytesMissing(t *testing.T) {
+	s, err := SmearInfoFromBytes(ntpResponseBytes)
+	require.NoError(t, err)
+	require.Nil(t, s)
+
+	packet := append(append([]byte{}, ntpResponseBytes...), MarshalExtensionFields([]ExtensionField{{Type: 0x0104}})...)
+	s, err = SmearInfoFromBytes(packet)
+	require.NoError(t, err)
+	require.Nil(t, s)
+}
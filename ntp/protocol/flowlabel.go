@@ -0,0 +1,83 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"fmt"
+	"net"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// FlowLabelMask covers 20 bits of IPv6 flow label
+const FlowLabelMask = 0xfffff
+
+// WithFlowLabel registers IPv6 flow label with the kernel flow label manager, so packets can carry it
+// via FlowLabelControlMessage. Registration is only enforced once some process holds an exclusive label. Linux only allows labels below 0x80000 unless net.ipv6.flowlabel_state_ranges is 0.
+// The label is shared, so it survives restarts of the process while the kernel lingers it
+func WithFlowLabel(label uint32) SocketOption {
+	return SocketOption{
+		Name: "IPV6_FLOWLABEL_MGR",
+		apply: func(connfd int) error {
+			if label == 0 || label&^FlowLabelMask != 0 {
+				return fmt.Errorf("invalid flow label %#x", label)
+			}
+			return setFlowLabel(connfd, label)
+		},
+	}
+}
+
+// WithRecvFlowLabel enables IPV6_FLOWINFO to read flow label of incoming packets with ParseFlowLabel
+func WithRecvFlowLabel() SocketOption {
+	return SocketOption{
+		Name: "IPV6_FLOWINFO",
+		apply: func(connfd int) error {
+			return setRecvFlowLabel(connfd)
+		},
+	}
+}
+
+// FlowLabelControlMessage returns control message to send IPv6 packet with the flow label registered by WithFlowLabel,
+// suitable for WriteMsgUDP. It is nil where setting flow labels is not supported
+func FlowLabelControlMessage(label uint32) []byte {
+	return flowLabelControlMessage(label)
+}
+
+// ParseFlowLabel extracts IPv6 flow label of the incoming packet from raw control messages.
+// False is returned if there is none
+func ParseFlowLabel(oob []byte) (uint32, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, m := range msgs {
+		if label, ok := parseFlowLabel(m); ok {
+			return label, true
+		}
+	}
+	return 0, false
+}
+
+// WriteWithControl sends b to addr from the src address like WriteFrom, adding extra control messages,
+// such as FlowLabelControlMessage. addr can be nil for connected sockets
+func WriteWithControl(conn *net.UDPConn, b []byte, addr *net.UDPAddr, src net.IP, oob []byte) (int, error) {
+	if src != nil && !src.IsUnspecified() && !src.IsMulticast() {
+		oob = append(srcControlMessage(src), oob...)
+	}
+	n, _, err := conn.WriteMsgUDP(b, oob, addr)
+	return n, err
+}
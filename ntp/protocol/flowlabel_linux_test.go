@@ -0,0 +1,64 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlowLabel(t *testing.T) {
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback, Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, TuneSocket(conn, WithRecvFlowLabel(), WithFlowLabel(0x1234)))
+	require.Error(t, TuneSocket(conn, WithFlowLabel(0x100000)))
+
+	_, err = WriteWithControl(conn, []byte("hello"), conn.LocalAddr().(*net.UDPAddr), nil, FlowLabelControlMessage(0x1234))
+	require.NoError(t, err)
+	buf := make([]byte, 16)
+	oob := make([]byte, PacketInfoControlSizeBytes)
+	_, oobn, _, _, err := conn.ReadMsgUDP(buf, oob)
+	require.NoError(t, err)
+	label, ok := ParseFlowLabel(oob[:oobn])
+	require.True(t, ok)
+	require.Equal(t, uint32(0x1234), label)
+
+	_, ok = ParseFlowLabel(nil)
+	require.False(t, ok)
+}
+
+func TestHopLimit(t *testing.T) {
+	for _, ip := range []net.IP{net.IPv6loopback, net.ParseIP("127.0.0.1")} {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: 0})
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, TuneSocket(conn, WithRecvTTL(), WithHopLimit(7)))
+
+		_, err = conn.WriteToUDP([]byte("hello"), conn.LocalAddr().(*net.UDPAddr))
+		require.NoError(t, err)
+		buf := make([]byte, 16)
+		oob := make([]byte, PacketInfoControlSizeBytes)
+		_, oobn, _, _, err := conn.ReadMsgUDP(buf, oob)
+		require.NoError(t, err)
+		ttl, ok := ParseTTL(oob[:oobn])
+		require.True(t, ok)
+		require.Equal(t, 7, ttl, ip)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/facebook/time/metrics"
+)
+
+// latencyBuckets are upper bounds of read latency histogram buckets
+var latencyBuckets = []time.Duration{
+	time.Microsecond,
+	2 * time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	20 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	200 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// ReadLatencyBuckets returns upper bounds of read latency histogram buckets in seconds,
+// to register Histogram with the same resolution
+func ReadLatencyBuckets() []float64 {
+	res := make([]float64, len(latencyBuckets))
+	for i, b := range latencyBuckets {
+		res[i] = b.Seconds()
+	}
+	return res
+}
+
+// LatencyBucket is a number of packets read within the latency
+type LatencyBucket struct {
+	// UpTo is the upper bound of the bucket. 0 for packets above all bounds
+	UpTo  time.Duration `json:"up_to_ns"`
+	Count int64         `json:"count"`
+}
+
+// ReadLatencyStats are aggregate stats of read latency
+type ReadLatencyStats struct {
+	Count int64 `json:"count"`
+	// Negative is the number of packets with kernel timestamp after the read time, for example if clock stepped
+	Negative int64           `json:"negative"`
+	Min      time.Duration   `json:"min_ns"`
+	Max      time.Duration   `json:"max_ns"`
+	Mean     time.Duration   `json:"mean_ns"`
+	StdDev   time.Duration   `json:"stddev_ns"`
+	P50      time.Duration   `json:"p50_ns"`
+	P99      time.Duration   `json:"p99_ns"`
+	Buckets  []LatencyBucket `json:"buckets"`
+}
+
+// ReadLatency aggregates delays between kernel receive timestamps of packets and the time they are read in userspace.
+// It quantifies timestamping overhead and scheduling jitter of the host. Nil ReadLatency ignores observations
+type ReadLatency struct {
+	// Histogram additionally receives every non-negative latency in seconds. Ignored if nil
+	Histogram metrics.Histogram
+
+	sync.Mutex
+	count    int64
+	negative int64
+	sum      float64
+	sumSq    float64
+	min      time.Duration
+	max      time.Duration
+	buckets  []int64
+}
+
+// NewReadLatency returns empty ReadLatency
+func NewReadLatency() *ReadLatency {
+	return &ReadLatency{buckets: make([]int64, len(latencyBuckets)+1)}
+}
+
+// Observe records latency of the packet with kernel timestamp read at the time read
+func (l *ReadLatency) Observe(kernel, read time.Time) {
+	if l == nil || kernel.IsZero() {
+		return
+	}
+	d := read.Sub(kernel)
+	if l.Histogram != nil && d >= 0 {
+		l.Histogram.Observe(d.Seconds())
+	}
+	l.Lock()
+	defer l.Unlock()
+	if d < 0 {
+		l.negative++
+		return
+	}
+	if l.count == 0 || d < l.min {
+		l.min = d
+	}
+	if d > l.max {
+		l.max = d
+	}
+	l.count++
+	l.sum += float64(d)
+	l.sumSq += float64(d) * float64(d)
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	l.buckets[i]++
+}
+
+// percentile returns upper bound of the bucket containing q quantile, max if it's above all buckets
+func (l *ReadLatency) percentile(q float64) time.Duration {
+	rank := int64(math.Ceil(q * float64(l.count)))
+	var seen int64
+	for i, c := range l.buckets[:len(latencyBuckets)] {
+		seen += c
+		if seen >= rank {
+			if latencyBuckets[i] > l.max {
+				return l.max
+			}
+			return latencyBuckets[i]
+		}
+	}
+	return l.max
+}
+
+// Stats returns aggregate stats of observed latencies
+func (l *ReadLatency) Stats() *ReadLatencyStats {
+	l.Lock()
+	defer l.Unlock()
+	s := &ReadLatencyStats{Count: l.count, Negative: l.negative}
+	for i, c := range l.buckets {
+		b := LatencyBucket{Count: c}
+		if i < len(latencyBuckets) {
+			b.UpTo = latencyBuckets[i]
+		}
+		s.Buckets = append(s.Buckets, b)
+	}
+	if l.count == 0 {
+		return s
+	}
+	mean := l.sum / float64(l.count)
+	s.Min = l.min
+	s.Max = l.max
+	s.Mean = time.Duration(mean)
+	s.StdDev = time.Duration(math.Sqrt(math.Max(0, l.sumSq/float64(l.count)-mean*mean)))
+	s.P50 = l.percentile(0.5)
+	s.P99 = l.percentile(0.99)
+	return s
+}
+
+// Reset forgets all observations
+func (l *ReadLatency) Reset() {
+	l.Lock()
+	defer l.Unlock()
+	l.count = 0
+	l.negative = 0
+	l.sum = 0
+	l.sumSq = 0
+	l.min = 0
+	l.max = 0
+	l.buckets = make([]int64, len(latencyBuckets)+1)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/facebook/time/metrics"
+)
+
+func TestReadLatency(t *testing.T) {
+	l := NewReadLatency()
+	kernel := time.Unix(1600000000, 0)
+	for _, d := range []time.Duration{3, 4, 4, 4, 5, 5, 7, 9} {
+		l.Observe(kernel, kernel.Add(d*time.Microsecond))
+	}
+	// clock stepped back
+	l.Observe(kernel, kernel.Add(-time.Second))
+	// no kernel timestamp
+	l.Observe(time.Time{}, kernel)
+
+	s := l.Stats()
+	require.Equal(t, int64(8), s.Count)
+	require.Equal(t, int64(1), s.Negative)
+	require.Equal(t, 3*time.Microsecond, s.Min)
+	require.Equal(t, 9*time.Microsecond, s.Max)
+	require.Equal(t, 5125*time.Nanosecond, s.Mean)
+	require.InDelta(t, float64(1833*time.Nanosecond), float64(s.StdDev), float64(time.Nanosecond))
+	require.Equal(t, 5*time.Microsecond, s.P50)
+	// above 5us bucket, capped by max
+	require.Equal(t, 9*time.Microsecond, s.P99)
+	require.Equal(t, LatencyBucket{UpTo: 5 * time.Microsecond, Count: 6}, s.Buckets[2])
+	require.Equal(t, LatencyBucket{UpTo: 10 * time.Microsecond, Count: 2}, s.Buckets[3])
+	require.Len(t, s.Buckets, len(latencyBuckets)+1)
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"p50_ns":5000`)
+
+	l.Reset()
+	require.Equal(t, int64(0), l.Stats().Count)
+	require.Zero(t, l.Stats().P99)
+}
+
+func TestReadLatencyOutliers(t *testing.T) {
+	l := NewReadLatency()
+	kernel := time.Unix(1600000000, 0)
+	l.Observe(kernel, kernel.Add(time.Second))
+	s := l.Stats()
+	require.Equal(t, time.Second, s.P50)
+	require.Equal(t, int64(1), s.Buckets[len(latencyBuckets)].Count)
+	require.Zero(t, s.Buckets[len(latencyBuckets)].UpTo)
+}
+
+func TestReadLatencyNil(t *testing.T) {
+	var l *ReadLatency
+	l.Observe(time.Now(), time.Now())
+}
+
+func TestReadLatencyHistogram(t *testing.T) {
+	r := metrics.NewPrometheus()
+	l := NewReadLatency()
+	l.Histogram = r.Histogram("read_latency_seconds", "", ReadLatencyBuckets(), nil)
+	kernel := time.Unix(1600000000, 0)
+	l.Observe(kernel, kernel.Add(3*time.Microsecond))
+	l.Observe(kernel, kernel.Add(-time.Microsecond))
+
+	var b bytes.Buffer
+	require.NoError(t, r.WritePrometheus(&b))
+	require.Contains(t, b.String(), `read_latency_seconds_bucket{le="2e-06"} 0`)
+	require.Contains(t, b.String(), `read_latency_seconds_bucket{le="5e-06"} 1`)
+	require.Contains(t, b.String(), "read_latency_seconds_count 1\n")
+	require.Len(t, ReadLatencyBuckets(), len(latencyBuckets))
+}
@@ -274,6 +274,21 @@ func TestReadPacketWithKernelTimestamp(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestReadPacketWithTimestamp(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	go func() {
+		_, _ = client.Write(ntpRequestBytes)
+	}()
+
+	request, rxTimestamp, err := ReadPacketWithTimestamp(server)
+	require.NoError(t, err)
+	require.Equal(t, ntpRequest, request)
+	require.Equal(t, time.Now().Unix()/10, rxTimestamp.Unix()/10, "timestamps should be within 10s")
+}
+
 func Benchmark_PacketToBytesConversion(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, _ = ntpResponse.Bytes()
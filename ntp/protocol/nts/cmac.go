@@ -0,0 +1,80 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+)
+
+// dbl is doubling in GF(2^128) as defined by RFC 5297
+func dbl(b []byte) []byte {
+	res := make([]byte, len(b))
+	var carry byte
+	for i := len(b) - 1; i >= 0; i-- {
+		res[i] = b[i]<<1 | carry
+		carry = b[i] >> 7
+	}
+	// xor with 0^120 || 10000111 if msb was set, in constant time
+	res[len(res)-1] ^= byte(subtle.ConstantTimeSelect(int(carry), 0x87, 0))
+	return res
+}
+
+func xor(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+// cmac computes AES-CMAC (RFC 4493) of the message
+type cmac struct {
+	block  cipher.Block
+	k1, k2 []byte
+}
+
+func newCMAC(block cipher.Block) *cmac {
+	l := make([]byte, block.BlockSize())
+	block.Encrypt(l, l)
+	k1 := dbl(l)
+	return &cmac{block: block, k1: k1, k2: dbl(k1)}
+}
+
+// sum returns MAC of the message
+func (c *cmac) sum(m []byte) []byte {
+	bs := c.block.BlockSize()
+	x := make([]byte, bs)
+	last := make([]byte, bs)
+	n := (len(m) + bs - 1) / bs
+	if n == 0 {
+		n = 1
+	}
+	for i := 0; i < n-1; i++ {
+		xor(x, x, m[i*bs:(i+1)*bs])
+		c.block.Encrypt(x, x)
+	}
+	rest := m[(n-1)*bs:]
+	if len(rest) == bs {
+		xor(last, rest, c.k1)
+	} else {
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xor(last, last, c.k2)
+	}
+	xor(x, x, last)
+	c.block.Encrypt(x, x)
+	return x
+}
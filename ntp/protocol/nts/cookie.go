@@ -0,0 +1,83 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+// KeySize is the size of client-to-server and server-to-client keys of AEAD_AES_SIV_CMAC_256
+const KeySize = 32
+
+// cookiePlaintextSize is AEAD algorithm followed by both keys
+const cookiePlaintextSize = 2 + 2*KeySize
+
+// CookieSize is the size of cookies issued by the server: key id, nonce and sealed keys
+const CookieSize = 4 + NonceSize + cookiePlaintextSize + sivSize
+
+var errCookie = errors.New("malformed cookie")
+
+// Cookie is the state of NTS association the server offloads to the client
+type Cookie struct {
+	AEAD uint16
+	C2S  []byte
+	S2C  []byte
+}
+
+// SealCookie encrypts the cookie with the current key. Key id is authenticated as associated data
+func (k *Keys) SealCookie(c *Cookie) ([]byte, error) {
+	if len(c.C2S) != KeySize || len(c.S2C) != KeySize {
+		return nil, errCookie
+	}
+	id, a, err := k.Current()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 4+NonceSize, CookieSize)
+	binary.BigEndian.PutUint32(b, id)
+	nonce := b[4 : 4+NonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, cookiePlaintextSize)
+	binary.BigEndian.PutUint16(plaintext, c.AEAD)
+	copy(plaintext[2:], c.C2S)
+	copy(plaintext[2+KeySize:], c.S2C)
+	return a.Seal(b, nonce, plaintext, b[:4]), nil
+}
+
+// OpenCookie decrypts the cookie issued with the current or one of the previous keys
+func (k *Keys) OpenCookie(b []byte) (*Cookie, error) {
+	if len(b) != CookieSize {
+		return nil, errCookie
+	}
+	a, err := k.Get(binary.BigEndian.Uint32(b))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := a.Open(nil, b[4:4+NonceSize], b[4+NonceSize:], b[:4])
+	if err != nil {
+		return nil, err
+	}
+	return &Cookie{
+		AEAD: binary.BigEndian.Uint16(plaintext),
+		C2S:  plaintext[2 : 2+KeySize],
+		S2C:  plaintext[2+KeySize:],
+	}, nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testKeys(t *testing.T) *Keys {
+	k := &Keys{Keep: 1}
+	require.NoError(t, k.RotateRandom())
+	return k
+}
+
+func testCookie() *Cookie {
+	return &Cookie{AEAD: AEADAESSIVCMAC256, C2S: bytes.Repeat([]byte{1}, KeySize), S2C: bytes.Repeat([]byte{2}, KeySize)}
+}
+
+func TestCookie(t *testing.T) {
+	k := testKeys(t)
+	b, err := k.SealCookie(testCookie())
+	require.NoError(t, err)
+	require.Len(t, b, CookieSize)
+
+	// cookies issued before rotation are still accepted
+	require.NoError(t, k.RotateRandom())
+	c, err := k.OpenCookie(b)
+	require.NoError(t, err)
+	require.Equal(t, testCookie(), c)
+
+	// but not after the key is removed
+	require.NoError(t, k.RotateRandom())
+	_, err = k.OpenCookie(b)
+	require.ErrorIs(t, err, errUnknownKey)
+
+	b, err = k.SealCookie(testCookie())
+	require.NoError(t, err)
+	b[len(b)-1] ^= 1
+	_, err = k.OpenCookie(b)
+	require.ErrorIs(t, err, errAuthFailed)
+	_, err = k.OpenCookie(b[:10])
+	require.ErrorIs(t, err, errCookie)
+	_, err = k.SealCookie(&Cookie{C2S: []byte{1}})
+	require.ErrorIs(t, err, errCookie)
+	_, err = (&Keys{}).SealCookie(testCookie())
+	require.ErrorIs(t, err, errNoKey)
+}
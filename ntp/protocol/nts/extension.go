@@ -0,0 +1,82 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// NTS extension field types (RFC 8915)
+const (
+	ExtUniqueIdentifier  uint16 = 0x0104
+	ExtCookie            uint16 = 0x0204
+	ExtCookiePlaceholder uint16 = 0x0304
+	ExtAuthenticator     uint16 = 0x0404
+)
+
+// authenticatorHeaderSize is the size of Nonce Length and Ciphertext Length
+const authenticatorHeaderSize = 4
+
+var errAuthenticator = errors.New("malformed NTS authenticator")
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// SealAuthenticator returns NTS Authenticator and Encrypted Extension Fields field.
+// ad is the packet from the start of the header up to the authenticator,
+// plaintext is encrypted extension fields, if any
+/*
+   0                   1                   2                   3
+   0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  |          Nonce Length         |      Ciphertext Length        |
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  .                      Nonce, including padding                 .
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  .                    Ciphertext, including padding              .
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+*/
+func SealAuthenticator(a cipher.AEAD, nonce, ad, plaintext []byte) ntp.ExtensionField {
+	c := a.Seal(nil, nonce, plaintext, ad)
+	v := make([]byte, authenticatorHeaderSize+pad4(len(nonce))+pad4(len(c)))
+	binary.BigEndian.PutUint16(v, uint16(len(nonce)))
+	binary.BigEndian.PutUint16(v[2:], uint16(len(c)))
+	copy(v[authenticatorHeaderSize:], nonce)
+	copy(v[authenticatorHeaderSize+pad4(len(nonce)):], c)
+	return ntp.ExtensionField{Type: ExtAuthenticator, Value: v}
+}
+
+// OpenAuthenticator verifies NTS Authenticator field against ad and returns decrypted extension fields
+func OpenAuthenticator(a cipher.AEAD, ad []byte, f ntp.ExtensionField) ([]byte, error) {
+	v := f.Value
+	if f.Type != ExtAuthenticator || len(v) < authenticatorHeaderSize {
+		return nil, errAuthenticator
+	}
+	nonceLen := int(binary.BigEndian.Uint16(v))
+	cLen := int(binary.BigEndian.Uint16(v[2:]))
+	if authenticatorHeaderSize+pad4(nonceLen)+pad4(cLen) > len(v) {
+		return nil, errAuthenticator
+	}
+	nonce := v[authenticatorHeaderSize : authenticatorHeaderSize+nonceLen]
+	start := authenticatorHeaderSize + pad4(nonceLen)
+	return a.Open(nil, nonce, v[start:start+cLen], ad)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"testing"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticator(t *testing.T) {
+	a, err := NewAEAD(make([]byte, 32))
+	require.NoError(t, err)
+	nonce := []byte("0123456789abcdef")
+	ad := []byte("ntp header and unique identifier")
+	plaintext := []byte("cookie")
+
+	f := SealAuthenticator(a, nonce, ad, plaintext)
+	require.Equal(t, ExtAuthenticator, f.Type)
+	// 4 bytes of lengths, nonce and 22 bytes of ciphertext padded to 24
+	require.Len(t, f.Value, 4+16+24)
+
+	// goes through marshalling like any other extension field
+	fields, err := ntp.ParseExtensionFields(ntp.MarshalExtensionFields([]ntp.ExtensionField{f}))
+	require.NoError(t, err)
+	p, err := OpenAuthenticator(a, ad, fields[0])
+	require.NoError(t, err)
+	require.Equal(t, plaintext, p)
+
+	_, err = OpenAuthenticator(a, []byte("other header"), fields[0])
+	require.ErrorIs(t, err, errAuthFailed)
+	_, err = OpenAuthenticator(a, ad, ntp.ExtensionField{Type: ExtCookie, Value: f.Value})
+	require.ErrorIs(t, err, errAuthenticator)
+	_, err = OpenAuthenticator(a, ad, ntp.ExtensionField{Type: ExtAuthenticator, Value: f.Value[:20]})
+	require.ErrorIs(t, err, errAuthenticator)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ALPN is the protocol id of NTS Key Establishment
+const ALPN = "ntske/1"
+
+// exporterLabel is the TLS exporter label to derive NTS keys
+const exporterLabel = "EXPORTER-network-time-security"
+
+// ProtocolNTPv4 is the NTS Next Protocol id of NTPv4
+const ProtocolNTPv4 = 0
+
+// NTS-KE record types (RFC 8915)
+const (
+	RecordEndOfMessage  uint16 = 0
+	RecordNextProtocol  uint16 = 1
+	RecordError         uint16 = 2
+	RecordWarning       uint16 = 3
+	RecordAEADAlgorithm uint16 = 4
+	RecordNewCookie     uint16 = 5
+	RecordServer        uint16 = 6
+	RecordPort          uint16 = 7
+)
+
+// NTS-KE error codes
+const (
+	ErrorUnrecognizedCritical uint16 = 0
+	ErrorBadRequest           uint16 = 1
+	ErrorInternalServer       uint16 = 2
+)
+
+// criticalBit marks records receiver must understand
+const criticalBit = 0x8000
+
+// maxRecordBody limits record size a peer can make us allocate
+const maxRecordBody = 1024
+
+var errRecordTooLong = errors.New("NTS-KE record is too long")
+
+// Record is an NTS-KE record
+/*
+   0                   1                   2                   3
+   0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  |C|         Record Type         |          Body Length          |
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  .                                                               .
+  .                           Record Body                         .
+  .                                                               .
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+*/
+type Record struct {
+	Critical bool
+	Type     uint16
+	Body     []byte
+}
+
+// Uint16s returns body as a list of 16 bit values, as in Next Protocol and AEAD Algorithm records
+func (r *Record) Uint16s() []uint16 {
+	res := make([]uint16, len(r.Body)/2)
+	for i := range res {
+		res[i] = binary.BigEndian.Uint16(r.Body[2*i:])
+	}
+	return res
+}
+
+// Uint16Record returns a record with body of 16 bit values
+func Uint16Record(critical bool, typ uint16, values ...uint16) Record {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+	return Record{Critical: critical, Type: typ, Body: b}
+}
+
+// MarshalRecords encodes records
+func MarshalRecords(records []Record) []byte {
+	b := []byte{}
+	for _, r := range records {
+		h := make([]byte, 4)
+		t := r.Type
+		if r.Critical {
+			t |= criticalBit
+		}
+		binary.BigEndian.PutUint16(h, t)
+		binary.BigEndian.PutUint16(h[2:], uint16(len(r.Body)))
+		b = append(append(b, h...), r.Body...)
+	}
+	return b
+}
+
+// ReadRecords reads records up to and including End of Message
+func ReadRecords(r io.Reader) ([]Record, error) {
+	records := []Record{}
+	h := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, h); err != nil {
+			return nil, err
+		}
+		t := binary.BigEndian.Uint16(h)
+		size := int(binary.BigEndian.Uint16(h[2:]))
+		if size > maxRecordBody {
+			return nil, fmt.Errorf("%w: %d", errRecordTooLong, size)
+		}
+		rec := Record{Critical: t&criticalBit != 0, Type: t &^ criticalBit, Body: make([]byte, size)}
+		if _, err := io.ReadFull(r, rec.Body); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+		if rec.Type == RecordEndOfMessage {
+			return records, nil
+		}
+	}
+}
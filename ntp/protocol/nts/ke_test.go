@@ -0,0 +1,136 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecords(t *testing.T) {
+	records := []Record{
+		Uint16Record(true, RecordNextProtocol, ProtocolNTPv4),
+		Uint16Record(false, RecordAEADAlgorithm, AEADAESSIVCMAC512, AEADAESSIVCMAC256),
+		{Critical: true, Type: RecordEndOfMessage, Body: []byte{}},
+	}
+	b := MarshalRecords(records)
+	require.Equal(t, []byte{0x80, 1, 0, 2, 0, 0, 0, 4, 0, 4, 0, 17, 0, 15, 0x80, 0, 0, 0}, b)
+
+	// records after end of message are not read
+	got, err := ReadRecords(bytes.NewReader(append(b, 0, 5, 0, 0)))
+	require.NoError(t, err)
+	require.Equal(t, records, got)
+	require.Equal(t, []uint16{AEADAESSIVCMAC512, AEADAESSIVCMAC256}, got[1].Uint16s())
+
+	_, err = ReadRecords(bytes.NewReader(b[:6]))
+	require.Error(t, err)
+	_, err = ReadRecords(bytes.NewReader([]byte{0, 5, 0xff, 0xff}))
+	require.ErrorIs(t, err, errRecordTooLong)
+}
+
+func fakeExport(context []byte) ([]byte, error) {
+	return bytes.Repeat(context[4:], KeySize), nil
+}
+
+func TestKEServerRespond(t *testing.T) {
+	s := &KEServer{Keys: testKeys(t), Cookies: 2, Server: "ntp.example.com", Port: 1123}
+	eom := Record{Critical: true, Type: RecordEndOfMessage}
+
+	response := s.respond([]Record{
+		Uint16Record(true, RecordNextProtocol, ProtocolNTPv4),
+		Uint16Record(false, RecordAEADAlgorithm, AEADAESSIVCMAC256),
+		eom,
+	}, fakeExport)
+	require.Len(t, response, 7)
+	require.Equal(t, []uint16{ProtocolNTPv4}, response[0].Uint16s())
+	require.Equal(t, []uint16{AEADAESSIVCMAC256}, response[1].Uint16s())
+	require.Equal(t, RecordNewCookie, response[2].Type)
+	c, err := s.Keys.OpenCookie(response[2].Body)
+	require.NoError(t, err)
+	require.Equal(t, bytes.Repeat([]byte{0}, KeySize), c.C2S)
+	require.Equal(t, bytes.Repeat([]byte{1}, KeySize), c.S2C)
+	require.Equal(t, Record{Critical: true, Type: RecordServer, Body: []byte("ntp.example.com")}, response[4])
+	require.Equal(t, []uint16{1123}, response[5].Uint16s())
+	require.Equal(t, eom, response[6])
+
+	// no supported AEAD
+	response = s.respond([]Record{Uint16Record(true, RecordNextProtocol, ProtocolNTPv4), Uint16Record(false, RecordAEADAlgorithm, AEADAESSIVCMAC512), eom}, fakeExport)
+	require.Equal(t, []Record{Uint16Record(true, RecordNextProtocol, ProtocolNTPv4), Uint16Record(true, RecordAEADAlgorithm), eom}, response)
+
+	// no supported protocol
+	response = s.respond([]Record{Uint16Record(true, RecordNextProtocol, 42), eom}, fakeExport)
+	require.Equal(t, []Record{Uint16Record(true, RecordNextProtocol), eom}, response)
+
+	require.Equal(t, errorResponse(ErrorBadRequest), s.respond([]Record{eom}, fakeExport))
+	require.Equal(t, errorResponse(ErrorUnrecognizedCritical), s.respond([]Record{Uint16Record(true, RecordNextProtocol, 0), {Critical: true, Type: 0x4242}, eom}, fakeExport))
+	// unknown non-critical records are ignored
+	require.Len(t, s.respond([]Record{Uint16Record(true, RecordNextProtocol, 0), Uint16Record(false, RecordAEADAlgorithm, AEADAESSIVCMAC256), {Type: 0x4242}, eom}, fakeExport), 7)
+}
+
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestKEServer(t *testing.T) {
+	s := &KEServer{Keys: testKeys(t), TLSConfig: &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}}, Timeout: time.Second}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig())
+	require.NoError(t, err)
+	go s.Serve(ln)
+	defer ln.Close()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{ALPN}, MinVersion: tls.VersionTLS13})
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(MarshalRecords([]Record{
+		Uint16Record(true, RecordNextProtocol, ProtocolNTPv4),
+		Uint16Record(false, RecordAEADAlgorithm, AEADAESSIVCMAC256),
+		{Critical: true, Type: RecordEndOfMessage},
+	}))
+	require.NoError(t, err)
+	response, err := ReadRecords(conn)
+	require.NoError(t, err)
+	require.Len(t, response, 3+DefaultCookies)
+
+	// both sides derive the same keys
+	state := conn.ConnectionState()
+	c2s, err := state.ExportKeyingMaterial(exporterLabel, []byte{0, 0, 0, 15, 0}, KeySize)
+	require.NoError(t, err)
+	c, err := s.Keys.OpenCookie(response[2].Body)
+	require.NoError(t, err)
+	require.Equal(t, c2s, c.C2S)
+}
@@ -0,0 +1,190 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultKEPort is the NTS-KE TCP port
+const DefaultKEPort = 4460
+
+// DefaultCookies is how many cookies are issued per key establishment
+const DefaultCookies = 8
+
+var errNotTLS = errors.New("connection is not TLS")
+
+// KEServer is an NTS Key Establishment server issuing cookies for NTPv4 with AEAD_AES_SIV_CMAC_256
+type KEServer struct {
+	Keys *Keys
+	// TLSConfig with server certificates. ALPN and TLS 1.3 are enforced
+	TLSConfig *tls.Config
+	// Server and Port of NTP server advertised to clients. Empty and 0 mean the same host and the default port
+	Server string
+	Port   int
+	// Cookies issued per key establishment
+	Cookies int
+	// Timeout of the whole key establishment
+	Timeout time.Duration
+}
+
+// tlsConfig returns TLS config enforcing NTS-KE requirements
+func (s *KEServer) tlsConfig() *tls.Config {
+	c := s.TLSConfig.Clone()
+	c.NextProtos = []string{ALPN}
+	c.MinVersion = tls.VersionTLS13
+	return c
+}
+
+// ListenAndServe accepts NTS-KE connections on the TCP address
+func (s *KEServer) ListenAndServe(addr string) error {
+	ln, err := tls.Listen("tcp", addr, s.tlsConfig())
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts NTS-KE connections on the TLS listener until it's closed
+func (s *KEServer) Serve(ln net.Listener) error {
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := s.handle(conn); err != nil {
+				log.Debugf("[nts-ke] %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (s *KEServer) handle(conn net.Conn) error {
+	defer conn.Close()
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return errNotTLS
+	}
+	if s.Timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(s.Timeout)); err != nil {
+			return err
+		}
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	state := tlsConn.ConnectionState()
+	if state.NegotiatedProtocol != ALPN {
+		return errors.New("ALPN is not negotiated")
+	}
+	request, err := ReadRecords(conn)
+	if err != nil {
+		return err
+	}
+	response := s.respond(request, func(context []byte) ([]byte, error) {
+		return state.ExportKeyingMaterial(exporterLabel, context, KeySize)
+	})
+	_, err = conn.Write(MarshalRecords(response))
+	return err
+}
+
+// errorResponse returns records of NTS-KE error
+func errorResponse(code uint16) []Record {
+	return []Record{
+		Uint16Record(true, RecordError, code),
+		{Critical: true, Type: RecordEndOfMessage},
+	}
+}
+
+func contains(values []uint16, v uint16) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// respond returns response records to the request. export derives keys from TLS session for the context
+func (s *KEServer) respond(request []Record, export func(context []byte) ([]byte, error)) []Record {
+	var protocols, aeads []uint16
+	seenProtocols := false
+	for _, r := range request {
+		switch r.Type {
+		case RecordEndOfMessage:
+		case RecordNextProtocol:
+			seenProtocols = true
+			protocols = r.Uint16s()
+		case RecordAEADAlgorithm:
+			aeads = r.Uint16s()
+		default:
+			if r.Critical {
+				return errorResponse(ErrorUnrecognizedCritical)
+			}
+		}
+	}
+	if !seenProtocols {
+		return errorResponse(ErrorBadRequest)
+	}
+	if !contains(protocols, ProtocolNTPv4) {
+		// none of the protocols is supported
+		return []Record{Uint16Record(true, RecordNextProtocol), {Critical: true, Type: RecordEndOfMessage}}
+	}
+	response := []Record{Uint16Record(true, RecordNextProtocol, ProtocolNTPv4)}
+	if !contains(aeads, AEADAESSIVCMAC256) {
+		return append(response, Uint16Record(true, RecordAEADAlgorithm), Record{Critical: true, Type: RecordEndOfMessage})
+	}
+	response = append(response, Uint16Record(true, RecordAEADAlgorithm, AEADAESSIVCMAC256))
+
+	c := &Cookie{AEAD: AEADAESSIVCMAC256}
+	var err error
+	// context is the protocol, the algorithm and the direction
+	if c.C2S, err = export([]byte{0, ProtocolNTPv4, 0, AEADAESSIVCMAC256, 0}); err != nil {
+		log.Errorf("[nts-ke] failed to export c2s key: %v", err)
+		return errorResponse(ErrorInternalServer)
+	}
+	if c.S2C, err = export([]byte{0, ProtocolNTPv4, 0, AEADAESSIVCMAC256, 1}); err != nil {
+		log.Errorf("[nts-ke] failed to export s2c key: %v", err)
+		return errorResponse(ErrorInternalServer)
+	}
+	cookies := s.Cookies
+	if cookies <= 0 {
+		cookies = DefaultCookies
+	}
+	for i := 0; i < cookies; i++ {
+		b, err := s.Keys.SealCookie(c)
+		if err != nil {
+			log.Errorf("[nts-ke] failed to seal cookie: %v", err)
+			return errorResponse(ErrorInternalServer)
+		}
+		response = append(response, Record{Type: RecordNewCookie, Body: b})
+	}
+	if s.Server != "" {
+		response = append(response, Record{Critical: true, Type: RecordServer, Body: []byte(s.Server)})
+	}
+	if s.Port != 0 {
+		response = append(response, Uint16Record(true, RecordPort, uint16(s.Port)))
+	}
+	return append(response, Record{Critical: true, Type: RecordEndOfMessage})
+}
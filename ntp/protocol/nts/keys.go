@@ -0,0 +1,133 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errNoKey      = errors.New("no key")
+	errKeyExists  = errors.New("key id is already in use")
+	errUnknownKey = errors.New("unknown key id")
+)
+
+// Keys holds the current key and previous keys still accepted after rotation.
+// Servers use it for cookie keys: new cookies are sealed with the current key,
+// cookies issued before rotation can still be opened with previous keys
+type Keys struct {
+	// Keep is how many previous keys are still accepted after rotation
+	Keep int
+	// OnRotate is called after every rotation with ids of the new and removed keys
+	OnRotate func(current uint32, removed []uint32)
+
+	sync.RWMutex
+	order []uint32
+	keys  map[uint32]cipher.AEAD
+}
+
+// Rotate makes the key current. Previous keys beyond Keep are removed
+func (k *Keys) Rotate(id uint32, key []byte) error {
+	a, err := NewAEAD(key)
+	if err != nil {
+		return err
+	}
+	k.Lock()
+	if k.keys == nil {
+		k.keys = map[uint32]cipher.AEAD{}
+	}
+	if _, ok := k.keys[id]; ok {
+		k.Unlock()
+		return fmt.Errorf("%w: %d", errKeyExists, id)
+	}
+	k.keys[id] = a
+	k.order = append(k.order, id)
+	removed := []uint32{}
+	for len(k.order) > k.Keep+1 {
+		removed = append(removed, k.order[0])
+		delete(k.keys, k.order[0])
+		k.order = k.order[1:]
+	}
+	onRotate := k.OnRotate
+	k.Unlock()
+
+	if onRotate != nil {
+		onRotate(id, removed)
+	}
+	return nil
+}
+
+// Current returns the current key and its id
+func (k *Keys) Current() (uint32, cipher.AEAD, error) {
+	k.RLock()
+	defer k.RUnlock()
+	if len(k.order) == 0 {
+		return 0, nil, errNoKey
+	}
+	id := k.order[len(k.order)-1]
+	return id, k.keys[id], nil
+}
+
+// Get returns the key by id if it's still accepted
+func (k *Keys) Get(id uint32) (cipher.AEAD, error) {
+	k.RLock()
+	defer k.RUnlock()
+	a, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", errUnknownKey, id)
+	}
+	return a, nil
+}
+
+// RotateRandom rotates to a new random key with the next id
+func (k *Keys) RotateRandom() error {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	k.RLock()
+	var id uint32
+	if len(k.order) > 0 {
+		id = k.order[len(k.order)-1] + 1
+	}
+	k.RUnlock()
+	return k.Rotate(id, key)
+}
+
+// RunRotation rotates to a new random key every interval until ctx is done
+func (k *Keys) RunRotation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.RotateRandom(); err != nil {
+				log.Errorf("[nts] failed to rotate keys: %v", err)
+			}
+		}
+	}
+}
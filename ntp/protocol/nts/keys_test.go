@@ -0,0 +1,58 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeys(t *testing.T) {
+	rotations := [][]uint32{}
+	k := &Keys{Keep: 1, OnRotate: func(current uint32, removed []uint32) {
+		rotations = append(rotations, append([]uint32{current}, removed...))
+	}}
+	_, _, err := k.Current()
+	require.ErrorIs(t, err, errNoKey)
+
+	require.NoError(t, k.Rotate(1, make([]byte, 32)))
+	require.NoError(t, k.Rotate(2, make([]byte, 32)))
+	require.ErrorIs(t, k.Rotate(2, make([]byte, 32)), errKeyExists)
+	require.ErrorIs(t, k.Rotate(3, make([]byte, 8)), errKeySize)
+
+	id, a, err := k.Current()
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), id)
+	nonce := make([]byte, NonceSize)
+	c := a.Seal(nil, nonce, []byte("cookie"), nil)
+
+	// previous key is still accepted
+	_, err = k.Get(1)
+	require.NoError(t, err)
+
+	require.NoError(t, k.Rotate(3, make([]byte, 64)))
+	_, err = k.Get(1)
+	require.ErrorIs(t, err, errUnknownKey)
+	old, err := k.Get(2)
+	require.NoError(t, err)
+	p, err := old.Open(nil, nonce, c, nil)
+	require.NoError(t, err)
+	require.Equal(t, "cookie", string(p))
+
+	require.Equal(t, [][]uint32{{1}, {2}, {3, 1}}, rotations)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// MinUniqueIDSize is the minimum size of the Unique Identifier field body
+const MinUniqueIDSize = 32
+
+// extensionHeaderSize is the size of Field Type and Length of an extension field
+const extensionHeaderSize = 4
+
+// KissNTSNAK is the kiss code of NTS negative acknowledgment
+const KissNTSNAK = "NTSN"
+
+var (
+	errNoUniqueID      = errors.New("no unique identifier")
+	errNoCookie        = errors.New("no cookie")
+	errMultipleCookies = errors.New("multiple cookies")
+	errNoAuthenticator = errors.New("no authenticator")
+	errUnsupportedAEAD = errors.New("unsupported AEAD algorithm")
+)
+
+// Request is an authenticated NTS request
+type Request struct {
+	UniqueID []byte
+	Cookie   *Cookie
+	// Cookies is how many fresh cookies to return: one instead of the used one plus one per placeholder
+	Cookies int
+}
+
+// placeholders counts cookie placeholders large enough to be replaced with cookies without amplification
+func placeholders(fields []ntp.ExtensionField) int {
+	n := 0
+	for _, f := range fields {
+		if f.Type == ExtCookiePlaceholder && len(f.Value) >= CookieSize {
+			n++
+		}
+	}
+	return n
+}
+
+// VerifyRequest verifies NTS extension fields following the request header.
+// Unique identifier is returned even if verification fails, so the server can respond with NTS NAK.
+// Fields following the authenticator are not authenticated and ignored
+func (k *Keys) VerifyRequest(header, ext []byte) (*Request, []byte, error) {
+	fields, err := ntp.ParseExtensionFields(ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := &Request{Cookies: 1}
+	var cookie []byte
+	var auth *ntp.ExtensionField
+	offset := 0
+	for i, f := range fields {
+		if f.Type == ExtAuthenticator {
+			auth = &fields[i]
+			fields = fields[:i]
+			break
+		}
+		switch f.Type {
+		case ExtUniqueIdentifier:
+			r.UniqueID = f.Value
+		case ExtCookie:
+			if cookie != nil {
+				return nil, r.UniqueID, errMultipleCookies
+			}
+			cookie = f.Value
+		}
+		offset += extensionHeaderSize + len(f.Value)
+	}
+	if len(r.UniqueID) < MinUniqueIDSize {
+		return nil, nil, errNoUniqueID
+	}
+	if cookie == nil {
+		return nil, r.UniqueID, errNoCookie
+	}
+	if auth == nil {
+		return nil, r.UniqueID, errNoAuthenticator
+	}
+	// cookie value includes padding
+	if len(cookie) > CookieSize {
+		cookie = cookie[:CookieSize]
+	}
+	if r.Cookie, err = k.OpenCookie(cookie); err != nil {
+		return nil, r.UniqueID, err
+	}
+	if r.Cookie.AEAD != AEADAESSIVCMAC256 {
+		return nil, r.UniqueID, fmt.Errorf("%w: %d", errUnsupportedAEAD, r.Cookie.AEAD)
+	}
+	a, err := NewAEAD(r.Cookie.C2S)
+	if err != nil {
+		return nil, r.UniqueID, err
+	}
+	ad := append(append([]byte{}, header...), ext[:offset]...)
+	plaintext, err := OpenAuthenticator(a, ad, *auth)
+	if err != nil {
+		return nil, r.UniqueID, err
+	}
+	encrypted, err := ntp.ParseExtensionFields(plaintext)
+	if err != nil {
+		return nil, r.UniqueID, err
+	}
+	r.Cookies += placeholders(fields) + placeholders(encrypted)
+	return r, r.UniqueID, nil
+}
+
+// Response returns extension fields to append to the response header: other fields, unique identifier
+// and authenticator with fresh cookies encrypted by the server-to-client key
+func (k *Keys) Response(r *Request, header []byte, fields []ntp.ExtensionField) ([]byte, error) {
+	fields = append(append([]ntp.ExtensionField{}, fields...), ntp.ExtensionField{Type: ExtUniqueIdentifier, Value: r.UniqueID})
+	ext := ntp.MarshalExtensionFields(fields)
+
+	cookies := make([]ntp.ExtensionField, r.Cookies)
+	for i := range cookies {
+		c, err := k.SealCookie(r.Cookie)
+		if err != nil {
+			return nil, err
+		}
+		cookies[i] = ntp.ExtensionField{Type: ExtCookie, Value: c}
+	}
+	a, err := NewAEAD(r.Cookie.S2C)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	ad := append(append([]byte{}, header...), ext...)
+	auth := SealAuthenticator(a, nonce, ad, ntp.MarshalExtensionFields(cookies))
+	return append(ext, ntp.MarshalExtensionFields([]ntp.ExtensionField{auth})...), nil
+}
+
+// NAK returns extension fields of NTS negative acknowledgment.
+// The response header must carry KissNTSNAK kiss code
+func NAK(uniqueID []byte) []byte {
+	return ntp.MarshalExtensionFields([]ntp.ExtensionField{{Type: ExtUniqueIdentifier, Value: uniqueID}})
+}
@@ -0,0 +1,116 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"bytes"
+	"testing"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+var testHeader = bytes.Repeat([]byte{0x23}, ntp.PacketSizeBytes)
+
+// clientRequest builds extension fields of NTS request the way clients do
+func clientRequest(t *testing.T, uid, cookie []byte, placeholders int) []byte {
+	fields := []ntp.ExtensionField{{Type: ExtUniqueIdentifier, Value: uid}, {Type: ExtCookie, Value: cookie}}
+	for i := 0; i < placeholders; i++ {
+		fields = append(fields, ntp.ExtensionField{Type: ExtCookiePlaceholder, Value: make([]byte, CookieSize)})
+	}
+	ext := ntp.MarshalExtensionFields(fields)
+	a, err := NewAEAD(testCookie().C2S)
+	require.NoError(t, err)
+	auth := SealAuthenticator(a, make([]byte, NonceSize), append(append([]byte{}, testHeader...), ext...), nil)
+	return append(ext, ntp.MarshalExtensionFields([]ntp.ExtensionField{auth})...)
+}
+
+func TestVerifyRequest(t *testing.T) {
+	k := testKeys(t)
+	cookie, err := k.SealCookie(testCookie())
+	require.NoError(t, err)
+	uid := bytes.Repeat([]byte{7}, MinUniqueIDSize)
+
+	r, gotUID, err := k.VerifyRequest(testHeader, clientRequest(t, uid, cookie, 2))
+	require.NoError(t, err)
+	require.Equal(t, uid, gotUID)
+	require.Equal(t, testCookie(), r.Cookie)
+	require.Equal(t, 3, r.Cookies)
+
+	// response is authenticated by the server-to-client key and carries fresh cookies
+	smear := ntp.ExtensionField{Type: ntp.ExtensionSmear, Value: make([]byte, 8)}
+	ext, err := k.Response(r, testHeader, []ntp.ExtensionField{smear})
+	require.NoError(t, err)
+	fields, err := ntp.ParseExtensionFields(ext)
+	require.NoError(t, err)
+	require.Len(t, fields, 3)
+	require.Equal(t, smear, fields[0])
+	require.Equal(t, uid, fields[1].Value)
+	a, err := NewAEAD(testCookie().S2C)
+	require.NoError(t, err)
+	adSize := len(ext) - extensionHeaderSize - len(fields[2].Value)
+	plaintext, err := OpenAuthenticator(a, append(append([]byte{}, testHeader...), ext[:adSize]...), fields[2])
+	require.NoError(t, err)
+	cookies, err := ntp.ParseExtensionFields(plaintext)
+	require.NoError(t, err)
+	require.Len(t, cookies, 3)
+	c, err := k.OpenCookie(cookies[0].Value[:CookieSize])
+	require.NoError(t, err)
+	require.Equal(t, testCookie(), c)
+}
+
+func TestVerifyRequestErrors(t *testing.T) {
+	k := testKeys(t)
+	cookie, err := k.SealCookie(testCookie())
+	require.NoError(t, err)
+	uid := bytes.Repeat([]byte{7}, MinUniqueIDSize)
+
+	// tampered header
+	header := append([]byte{}, testHeader...)
+	header[0] = 0x24
+	_, gotUID, err := k.VerifyRequest(header, clientRequest(t, uid, cookie, 0))
+	require.ErrorIs(t, err, errAuthFailed)
+	require.Equal(t, uid, gotUID)
+
+	// cookie key is gone
+	require.NoError(t, k.RotateRandom())
+	require.NoError(t, k.RotateRandom())
+	_, gotUID, err = k.VerifyRequest(testHeader, clientRequest(t, uid, cookie, 0))
+	require.ErrorIs(t, err, errUnknownKey)
+	require.Equal(t, uid, gotUID)
+
+	_, gotUID, err = k.VerifyRequest(testHeader, clientRequest(t, uid[:8], cookie, 0))
+	require.ErrorIs(t, err, errNoUniqueID)
+	require.Nil(t, gotUID)
+
+	ext := ntp.MarshalExtensionFields([]ntp.ExtensionField{{Type: ExtUniqueIdentifier, Value: uid}})
+	_, _, err = k.VerifyRequest(testHeader, ext)
+	require.ErrorIs(t, err, errNoCookie)
+	ext = ntp.MarshalExtensionFields([]ntp.ExtensionField{{Type: ExtUniqueIdentifier, Value: uid}, {Type: ExtCookie, Value: cookie}})
+	_, _, err = k.VerifyRequest(testHeader, ext)
+	require.ErrorIs(t, err, errNoAuthenticator)
+	ext = ntp.MarshalExtensionFields([]ntp.ExtensionField{{Type: ExtUniqueIdentifier, Value: uid}, {Type: ExtCookie, Value: cookie}, {Type: ExtCookie, Value: cookie}})
+	_, _, err = k.VerifyRequest(testHeader, ext)
+	require.ErrorIs(t, err, errMultipleCookies)
+}
+
+func TestNAK(t *testing.T) {
+	uid := bytes.Repeat([]byte{7}, MinUniqueIDSize)
+	fields, err := ntp.ParseExtensionFields(NAK(uid))
+	require.NoError(t, err)
+	require.Equal(t, []ntp.ExtensionField{{Type: ExtUniqueIdentifier, Value: uid}}, fields)
+}
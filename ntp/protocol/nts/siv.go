@@ -0,0 +1,194 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package nts implements cryptography of Network Time Security (RFC 8915):
+AES-SIV-CMAC authenticated encryption (RFC 5297), NTS extension fields
+and keys with rotation. It's shared by NTS clients and servers.
+*/
+package nts
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// AEAD algorithm identifiers from IANA AEAD registry
+const (
+	AEADAESSIVCMAC256 = 15
+	AEADAESSIVCMAC384 = 16
+	AEADAESSIVCMAC512 = 17
+)
+
+// sivSize is the size of the synthetic IV prepended to the ciphertext
+const sivSize = aes.BlockSize
+
+// NonceSize is the size of nonces used by NTS
+const NonceSize = 16
+
+var (
+	errKeySize      = errors.New("AES-SIV key must be 32, 48 or 64 bytes")
+	errAuthFailed   = errors.New("message authentication failed")
+	errCiphertext   = errors.New("ciphertext is too short")
+	errNonceSize    = errors.New("invalid nonce size")
+	errNoComponents = errors.New("no components to authenticate")
+)
+
+// SIV is AES-SIV-CMAC deterministic authenticated encryption with associated data (RFC 5297)
+type SIV struct {
+	mac *cmac
+	ctr cipher.Block
+}
+
+// NewSIV returns SIV for the key. First half of the key is used for CMAC, second half for CTR
+func NewSIV(key []byte) (*SIV, error) {
+	switch len(key) {
+	case 32, 48, 64:
+	default:
+		return nil, errKeySize
+	}
+	half := len(key) / 2
+	macBlock, err := aes.NewCipher(key[:half])
+	if err != nil {
+		return nil, err
+	}
+	ctrBlock, err := aes.NewCipher(key[half:])
+	if err != nil {
+		return nil, err
+	}
+	return &SIV{mac: newCMAC(macBlock), ctr: ctrBlock}, nil
+}
+
+// s2v is the string to vector PRF. The last component is the plaintext
+func (s *SIV) s2v(components ...[]byte) ([]byte, error) {
+	if len(components) == 0 {
+		return nil, errNoComponents
+	}
+	d := s.mac.sum(make([]byte, aes.BlockSize))
+	for _, c := range components[:len(components)-1] {
+		d = dbl(d)
+		xor(d, d, s.mac.sum(c))
+	}
+	last := components[len(components)-1]
+	var t []byte
+	if len(last) >= aes.BlockSize {
+		// xorend: xor d into the last block
+		t = append([]byte{}, last...)
+		end := t[len(t)-aes.BlockSize:]
+		xor(end, end, d)
+	} else {
+		t = make([]byte, aes.BlockSize)
+		copy(t, last)
+		t[len(last)] = 0x80
+		xor(t, t, dbl(d))
+	}
+	return s.mac.sum(t), nil
+}
+
+// crypt runs CTR mode keyed by the synthetic IV
+func (s *SIV) crypt(dst, v, src []byte) {
+	q := append([]byte{}, v...)
+	// clear 31st and 63rd bits counting from the right for compatibility with 32/64 bit counters
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+	cipher.NewCTR(s.ctr, q).XORKeyStream(dst, src)
+}
+
+// Seal encrypts and authenticates plaintext and authenticates associated data components.
+// Nonce, if any, must be passed as the last component. Result is appended to dst
+func (s *SIV) Seal(dst, plaintext []byte, ad ...[]byte) []byte {
+	// s2v can only fail without components, and plaintext is always there
+	v, _ := s.s2v(components(ad, plaintext)...)
+	res, out := sliceForAppend(dst, sivSize+len(plaintext))
+	copy(out, v)
+	s.crypt(out[sivSize:], v, plaintext)
+	return res
+}
+
+// Open decrypts and verifies ciphertext produced by Seal with the same associated data.
+// Tag is compared in constant time. Result is appended to dst
+func (s *SIV) Open(dst, ciphertext []byte, ad ...[]byte) ([]byte, error) {
+	if len(ciphertext) < sivSize {
+		return nil, errCiphertext
+	}
+	v := ciphertext[:sivSize]
+	res, out := sliceForAppend(dst, len(ciphertext)-sivSize)
+	s.crypt(out, v, ciphertext[sivSize:])
+	expected, err := s.s2v(components(ad, out)...)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(expected, v) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errAuthFailed
+	}
+	return res, nil
+}
+
+// components returns associated data followed by the plaintext without modifying ad
+func components(ad [][]byte, plaintext []byte) [][]byte {
+	res := make([][]byte, 0, len(ad)+1)
+	return append(append(res, ad...), plaintext)
+}
+
+// sliceForAppend extends dst by n bytes, returning the whole slice and the tail
+func sliceForAppend(dst []byte, n int) (res, tail []byte) {
+	total := len(dst) + n
+	if cap(dst) >= total {
+		res = dst[:total]
+	} else {
+		res = make([]byte, total)
+		copy(res, dst)
+	}
+	return res, res[len(dst):]
+}
+
+// aead adapts SIV to cipher.AEAD with the nonce as the last associated data component, as NTS does
+type aead struct {
+	siv *SIV
+}
+
+// NewAEAD returns AEAD_AES_SIV_CMAC_256/384/512 depending on the key size, with NonceSize nonces
+func NewAEAD(key []byte) (cipher.AEAD, error) {
+	s, err := NewSIV(key)
+	if err != nil {
+		return nil, err
+	}
+	return &aead{siv: s}, nil
+}
+
+func (a *aead) NonceSize() int { return NonceSize }
+
+func (a *aead) Overhead() int { return sivSize }
+
+func (a *aead) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != NonceSize {
+		panic(fmt.Sprintf("nts: %v: %d", errNonceSize, len(nonce)))
+	}
+	return a.siv.Seal(dst, plaintext, additionalData, nonce)
+}
+
+func (a *aead) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, errNonceSize
+	}
+	return a.siv.Open(dst, ciphertext, additionalData, nonce)
+}
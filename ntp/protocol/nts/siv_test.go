@@ -0,0 +1,132 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nts
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	require.NoError(t, err)
+	return b
+}
+
+// RFC 4493 test vectors
+func TestCMAC(t *testing.T) {
+	block, err := aes.NewCipher(unhex(t, "2b7e1516 28aed2a6 abf71588 09cf4f3c"))
+	require.NoError(t, err)
+	c := newCMAC(block)
+	require.Equal(t, unhex(t, "fbeed618 35713366 7c85e08f 7236a8de"), c.k1)
+	require.Equal(t, unhex(t, "f7ddac30 6ae266cc f90bc11e e46d513b"), c.k2)
+
+	require.Equal(t, unhex(t, "bb1d6929 e9593728 7fa37d12 9b756746"), c.sum(nil))
+	require.Equal(t, unhex(t, "070a16b4 6b4d4144 f79bdd9d d04a287c"), c.sum(unhex(t, "6bc1bee2 2e409f96 e93d7e11 7393172a")))
+	m := unhex(t, "6bc1bee2 2e409f96 e93d7e11 7393172a ae2d8a57 1e03ac9c 9eb76fac 45af8e51 30c81c46 a35ce411")
+	require.Equal(t, unhex(t, "dfa66747 de9ae630 30ca3261 1497c827"), c.sum(m))
+}
+
+// RFC 5297 A.1 deterministic authenticated encryption
+func TestSIVDeterministic(t *testing.T) {
+	s, err := NewSIV(unhex(t, "fffefdfc fbfaf9f8 f7f6f5f4 f3f2f1f0 f0f1f2f3 f4f5f6f7 f8f9fafb fcfdfeff"))
+	require.NoError(t, err)
+	ad := unhex(t, "10111213 14151617 18191a1b 1c1d1e1f 20212223 24252627")
+	plaintext := unhex(t, "11223344 55667788 99aabbcc ddee")
+	expected := unhex(t, "85632d07 c6e8f37f 950acd32 0a2ecc93 40c02b96 90c4dc04 daef7f6a fe5c")
+
+	c := s.Seal(nil, plaintext, ad)
+	require.Equal(t, expected, c)
+	p, err := s.Open(nil, c, ad)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, p)
+}
+
+// RFC 5297 A.2 nonce-based authenticated encryption
+func TestSIVNonce(t *testing.T) {
+	s, err := NewSIV(unhex(t, "7f7e7d7c 7b7a7978 77767574 73727170 40414243 44454647 48494a4b 4c4d4e4f"))
+	require.NoError(t, err)
+	ad1 := unhex(t, "00112233 44556677 8899aabb ccddeeff deaddada deaddada ffeeddcc bbaa9988 77665544 33221100")
+	ad2 := unhex(t, "10203040 50607080 90a0")
+	nonce := unhex(t, "09f91102 9d74e35b d84156c5 635688c0")
+	plaintext := unhex(t, "74686973 20697320 736f6d65 20706c61 696e7465 78742074 6f20656e 63727970 74207573 696e6720 5349562d 414553")
+	expected := unhex(t, "7bdb6e3b 432667eb 06f4d14b ff2fbd0f cb900f2f ddbe4043 26601965 c889bf17 dba77ceb 094fa663 b7a3f748 ba8af829 ea64ad54 4a272e9c 485b62a3 fd5c0d")
+
+	c := s.Seal(nil, plaintext, ad1, ad2, nonce)
+	require.Equal(t, expected, c)
+	p, err := s.Open(nil, c, ad1, ad2, nonce)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, p)
+}
+
+func TestSIVTampering(t *testing.T) {
+	s, err := NewSIV(make([]byte, 32))
+	require.NoError(t, err)
+	ad := []byte("header")
+	c := s.Seal(nil, []byte("cookie"), ad)
+
+	for i := range c {
+		bad := append([]byte{}, c...)
+		bad[i] ^= 1
+		_, err := s.Open(nil, bad, ad)
+		require.ErrorIs(t, err, errAuthFailed, "byte %d", i)
+	}
+	_, err = s.Open(nil, c, []byte("other"))
+	require.ErrorIs(t, err, errAuthFailed)
+	_, err = s.Open(nil, c[:sivSize-1], ad)
+	require.ErrorIs(t, err, errCiphertext)
+
+	_, err = NewSIV(make([]byte, 16))
+	require.ErrorIs(t, err, errKeySize)
+}
+
+func TestSIVDoesNotModifyAD(t *testing.T) {
+	s, err := NewSIV(make([]byte, 64))
+	require.NoError(t, err)
+	ad := make([][]byte, 1, 4)
+	ad[0] = []byte("header")
+	c := s.Seal(nil, []byte("one"), ad...)
+	s.Seal(nil, []byte("two"), ad...)
+	p, err := s.Open([]byte("prefix"), c, ad...)
+	require.NoError(t, err)
+	require.Equal(t, "prefixone", string(p))
+}
+
+func TestAEAD(t *testing.T) {
+	a, err := NewAEAD(make([]byte, 32))
+	require.NoError(t, err)
+	require.Equal(t, NonceSize, a.NonceSize())
+	require.Equal(t, sivSize, a.Overhead())
+
+	nonce := make([]byte, NonceSize)
+	c := a.Seal(nil, nonce, []byte("cookie"), []byte("header"))
+	require.Len(t, c, len("cookie")+a.Overhead())
+	p, err := a.Open(nil, nonce, c, []byte("header"))
+	require.NoError(t, err)
+	require.Equal(t, "cookie", string(p))
+
+	nonce[0] = 1
+	_, err = a.Open(nil, nonce, c, []byte("header"))
+	require.ErrorIs(t, err, errAuthFailed)
+	_, err = a.Open(nil, nonce[:8], c, []byte("header"))
+	require.ErrorIs(t, err, errNonceSize)
+	require.Panics(t, func() { a.Seal(nil, nonce[:8], nil, nil) })
+}
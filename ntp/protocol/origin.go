@@ -0,0 +1,51 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrOriginMismatch means the response is not a reply to the request, possibly spoofed
+var ErrOriginMismatch = errors.New("origin timestamp of the response doesn't match transmit timestamp of the request")
+
+// SetRandomTransmitTime sets transmit timestamp of the client request to a random value instead of the clock reading.
+// Server echoes it back as the origin timestamp, so off-path attackers can't guess it to spoof replies,
+// and client clock is not disclosed. Actual send time must be recorded by the client to calculate the offset
+func (p *Packet) SetRandomTransmitTime() error {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	p.SetTxTimeRaw(binary.BigEndian.Uint64(b))
+	// zero timestamp means "unknown"
+	if p.TxTimeSec == 0 && p.TxTimeFrac == 0 {
+		p.TxTimeFrac = 1
+	}
+	return nil
+}
+
+// MatchOrigin checks the response is a reply to the request by strict comparison of its origin timestamp
+// with the transmit timestamp of the request
+func MatchOrigin(request, response *Packet) error {
+	if response.OrigTimeSec != request.TxTimeSec || response.OrigTimeFrac != request.TxTimeFrac {
+		return ErrOriginMismatch
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRandomTransmitTime(t *testing.T) {
+	a := &Packet{}
+	b := &Packet{}
+	require.NoError(t, a.SetRandomTransmitTime())
+	require.NoError(t, b.SetRandomTransmitTime())
+	require.NotEqual(t, [2]uint32{a.TxTimeSec, a.TxTimeFrac}, [2]uint32{b.TxTimeSec, b.TxTimeFrac})
+	require.False(t, a.TxTimeSec == 0 && a.TxTimeFrac == 0)
+}
+
+func TestMatchOrigin(t *testing.T) {
+	request := &Packet{Settings: 0x23}
+	require.NoError(t, request.SetRandomTransmitTime())
+	response := &Packet{}
+	response.OrigTimeSec = request.TxTimeSec
+	response.OrigTimeFrac = request.TxTimeFrac
+	require.NoError(t, MatchOrigin(request, response))
+
+	response.OrigTimeFrac++
+	require.ErrorIs(t, MatchOrigin(request, response), ErrOriginMismatch)
+}
@@ -17,8 +17,6 @@ limitations under the License.
 package protocol
 
 import (
-	"bytes"
-	"encoding/binary"
 	"net"
 	"time"
 	"unsafe"
@@ -29,6 +27,9 @@ import (
 // PacketSizeBytes sets the size of NTP packet
 const PacketSizeBytes = 48
 
+// MaxPacketSizeBytes is a buffer to read NTP packet with extension fields
+const MaxPacketSizeBytes = 1500
+
 // ControlHeaderSizeBytes is a buffer to read packet header with Kernel timestamps
 const ControlHeaderSizeBytes = 32
 
@@ -122,18 +123,17 @@ func (p *Packet) ValidSettingsFormat() bool {
 	return false
 }
 
-// Bytes converts Packet to []bytes
+// Bytes converts Packet to []bytes. It never fails, use Raw to avoid allocation of the slice
 func (p *Packet) Bytes() ([]byte, error) {
-	var bytes bytes.Buffer
-	err := binary.Write(&bytes, binary.BigEndian, p)
-	return bytes.Bytes(), err
+	b := p.Raw()
+	return b[:], nil
 }
 
-// BytesToPacket converts []bytes to Packet
+// BytesToPacket converts []bytes to Packet.
+// Bytes after the packet header, such as extension fields, are ignored
 func BytesToPacket(ntpPacketBytes []byte) (*Packet, error) {
 	packet := &Packet{}
-	reader := bytes.NewReader(ntpPacketBytes)
-	err := binary.Read(reader, binary.BigEndian, packet)
+	err := packet.decode(ntpPacketBytes)
 	return packet, err
 }
 
@@ -149,6 +149,19 @@ func ReadNTPPacket(conn *net.UDPConn) (ntp *Packet, remAddr net.Addr, err error)
 	return ntp, remAddr, err
 }
 
+// ReadPacketWithTimestamp reads incoming NTP packet from any connection.
+// Receive time is taken in userspace, so it's less precise than the kernel one
+func ReadPacketWithTimestamp(conn net.Conn) (ntp *Packet, rxTime time.Time, err error) {
+	buf := make([]byte, PacketSizeBytes)
+	n, err := conn.Read(buf)
+	rxTime = time.Now()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	ntp, err = BytesToPacket(buf[:n])
+	return ntp, rxTime, err
+}
+
 // ReadPacketWithKernelTimestamp reads kernel timestamp from incoming packet
 func ReadPacketWithKernelTimestamp(conn *net.UDPConn) (ntp *Packet, kernelRxTime time.Time, remAddr net.Addr, err error) {
 	buf := make([]byte, PacketSizeBytes)
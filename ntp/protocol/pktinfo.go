@@ -0,0 +1,114 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"net"
+	"time"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// ErrShortPacket is returned for packets shorter than NTP header
+var ErrShortPacket = errors.New("packet is shorter than NTP header")
+
+// PacketInfoControlSizeBytes is a buffer to read packet headers with kernel timestamp and destination address
+const PacketInfoControlSizeBytes = 128
+
+// EnablePacketInfo enables socket options to read destination address of incoming packets.
+// It's required to reply from the address request arrived on when listening on multiple or anycast addresses
+func EnablePacketInfo(conn *net.UDPConn) error {
+	connfd, err := connFd(conn)
+	if err != nil {
+		return err
+	}
+	v6, err := isIPv6(connfd)
+	if err != nil {
+		return err
+	}
+	return enablePacketInfo(connfd, v6)
+}
+
+// ReadPacketWithKernelTimestampAndDst reads kernel timestamp and destination address of the incoming packet.
+// Requires EnableKernelTimestampsSocket and EnablePacketInfo
+func ReadPacketWithKernelTimestampAndDst(conn *net.UDPConn) (ntp *Packet, kernelRxTime time.Time, remAddr *net.UDPAddr, dst net.IP, err error) {
+	ntp, _, kernelRxTime, remAddr, dst, err = ReadPacketWithExtensions(conn)
+	return ntp, kernelRxTime, remAddr, dst, err
+}
+
+// ReadPacketWithExtensions is ReadPacketWithKernelTimestampAndDst which also returns raw extension fields following the header
+func ReadPacketWithExtensions(conn *net.UDPConn) (ntp *Packet, ext []byte, kernelRxTime time.Time, remAddr *net.UDPAddr, dst net.IP, err error) {
+	buf := make([]byte, MaxPacketSizeBytes)
+	oob := make([]byte, PacketInfoControlSizeBytes)
+
+	n, oobn, _, remAddr, err := conn.ReadMsgUDP(buf, oob)
+	if err != nil {
+		return nil, nil, time.Time{}, nil, nil, err
+	}
+	kernelRxTime, dst, err = ParseControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, nil, time.Time{}, nil, nil, err
+	}
+
+	if n < PacketSizeBytes {
+		return nil, nil, time.Time{}, nil, nil, ErrShortPacket
+	}
+	packet, err := BytesToPacket(buf[:n])
+	if n > PacketSizeBytes {
+		ext = buf[PacketSizeBytes:n]
+	}
+	return packet, ext, kernelRxTime, remAddr, dst, err
+}
+
+// WriteFrom sends b to addr from the src address. Default source address is used if src is nil, unspecified or multicast
+func WriteFrom(conn *net.UDPConn, b []byte, addr *net.UDPAddr, src net.IP) (int, error) {
+	if src == nil || src.IsUnspecified() || src.IsMulticast() {
+		return conn.WriteToUDP(b, addr)
+	}
+	n, _, err := conn.WriteMsgUDP(b, srcControlMessage(src), addr)
+	return n, err
+}
+
+// controlMessage returns buffer with a single control message header and pointer to its data
+func controlMessage(level, typ, datalen int) ([]byte, unsafe.Pointer) {
+	b := make([]byte, syscall.CmsgSpace(datalen))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = int32(level)
+	h.Type = int32(typ)
+	h.SetLen(syscall.CmsgLen(datalen))
+	return b, unsafe.Pointer(&b[syscall.CmsgLen(0)])
+}
+
+// parseInet6Dst extracts destination address from IPV6_PKTINFO
+func parseInet6Dst(m syscall.SocketControlMessage) net.IP {
+	if m.Header.Level != syscall.IPPROTO_IPV6 || m.Header.Type != syscall.IPV6_PKTINFO || len(m.Data) < syscall.SizeofInet6Pktinfo {
+		return nil
+	}
+	info := (*syscall.Inet6Pktinfo)(unsafe.Pointer(&m.Data[0]))
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, info.Addr[:])
+	return ip
+}
+
+// inet6SrcControlMessage returns IPV6_PKTINFO control message to send from the src
+func inet6SrcControlMessage(src net.IP) []byte {
+	b, data := controlMessage(syscall.IPPROTO_IPV6, syscall.IPV6_PKTINFO, syscall.SizeofInet6Pktinfo)
+	copy((*syscall.Inet6Pktinfo)(data).Addr[:], src.To16())
+	return b
+}
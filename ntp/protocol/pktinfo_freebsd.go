@@ -0,0 +1,86 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"time"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+func enablePacketInfo(connfd int, v6 bool) error {
+	if v6 {
+		if err := syscall.SetsockoptInt(connfd, syscall.IPPROTO_IPV6, syscall.IPV6_RECVPKTINFO, 1); err != nil {
+			return fmt.Errorf("failed to enable IPV6_RECVPKTINFO: %w", err)
+		}
+		// dual stack socket receives IPv4 packets as well. Best effort
+		_ = syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_RECVDSTADDR, 1)
+		return nil
+	}
+	if err := syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_RECVDSTADDR, 1); err != nil {
+		return fmt.Errorf("failed to enable IP_RECVDSTADDR: %w", err)
+	}
+	return nil
+}
+
+// parseTimestamp extracts kernel timestamp from SO_TIMESTAMP
+func parseTimestamp(m syscall.SocketControlMessage) (time.Time, bool) {
+	if m.Header.Level != syscall.SOL_SOCKET || m.Header.Type != syscall.SO_TIMESTAMP || len(m.Data) < int(unsafe.Sizeof(syscall.Timeval{})) {
+		return time.Time{}, false
+	}
+	tv := (*syscall.Timeval)(unsafe.Pointer(&m.Data[0]))
+	return time.Unix(tv.Unix()), true
+}
+
+// parseDst extracts destination address from IP_RECVDSTADDR or IPV6_PKTINFO
+func parseDst(m syscall.SocketControlMessage) net.IP {
+	if m.Header.Level == syscall.IPPROTO_IP && m.Header.Type == syscall.IP_RECVDSTADDR && len(m.Data) >= net.IPv4len {
+		return net.IPv4(m.Data[0], m.Data[1], m.Data[2], m.Data[3])
+	}
+	return parseInet6Dst(m)
+}
+
+// srcControlMessage returns control message to send from the src
+func srcControlMessage(src net.IP) []byte {
+	if v4 := src.To4(); v4 != nil {
+		b, data := controlMessage(syscall.IPPROTO_IP, syscall.IP_SENDSRCADDR, net.IPv4len)
+		copy((*[net.IPv4len]byte)(data)[:], v4)
+		return b
+	}
+	return inet6SrcControlMessage(src)
+}
+
+// parseTTL extracts TTL from single byte IP_RECVTTL control message
+func parseTTL(m syscall.SocketControlMessage) (int, bool) {
+	if m.Header.Level != syscall.IPPROTO_IP || m.Header.Type != syscall.IP_RECVTTL || len(m.Data) < 1 {
+		return 0, false
+	}
+	return int(m.Data[0]), true
+}
+
+// flowLabelControlMessage is nil as setting flow labels is not supported
+func flowLabelControlMessage(label uint32) []byte {
+	return nil
+}
+
+// parseFlowLabel never finds flow label as receiving it is not supported
+func parseFlowLabel(m syscall.SocketControlMessage) (uint32, bool) {
+	return 0, false
+}
@@ -0,0 +1,100 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+func enablePacketInfo(connfd int, v6 bool) error {
+	if v6 {
+		if err := syscall.SetsockoptInt(connfd, syscall.IPPROTO_IPV6, syscall.IPV6_RECVPKTINFO, 1); err != nil {
+			return fmt.Errorf("failed to enable IPV6_RECVPKTINFO: %w", err)
+		}
+		// dual stack socket receives IPv4 packets as well. Best effort
+		_ = syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_PKTINFO, 1)
+		return nil
+	}
+	if err := syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_PKTINFO, 1); err != nil {
+		return fmt.Errorf("failed to enable IP_PKTINFO: %w", err)
+	}
+	return nil
+}
+
+// parseTimestamp extracts kernel timestamp from SO_TIMESTAMPNS or SO_TIMESTAMP
+func parseTimestamp(m syscall.SocketControlMessage) (time.Time, bool) {
+	if m.Header.Level != syscall.SOL_SOCKET {
+		return time.Time{}, false
+	}
+	switch {
+	case m.Header.Type == syscall.SO_TIMESTAMPNS && len(m.Data) >= int(unsafe.Sizeof(syscall.Timespec{})):
+		ts := (*syscall.Timespec)(unsafe.Pointer(&m.Data[0]))
+		return time.Unix(ts.Unix()), true
+	case m.Header.Type == syscall.SO_TIMESTAMP && len(m.Data) >= int(unsafe.Sizeof(syscall.Timeval{})):
+		tv := (*syscall.Timeval)(unsafe.Pointer(&m.Data[0]))
+		return time.Unix(tv.Unix()), true
+	}
+	return time.Time{}, false
+}
+
+// parseDst extracts destination address from IP_PKTINFO or IPV6_PKTINFO
+func parseDst(m syscall.SocketControlMessage) net.IP {
+	if m.Header.Level == syscall.IPPROTO_IP && m.Header.Type == syscall.IP_PKTINFO && len(m.Data) >= syscall.SizeofInet4Pktinfo {
+		info := (*syscall.Inet4Pktinfo)(unsafe.Pointer(&m.Data[0]))
+		return net.IPv4(info.Addr[0], info.Addr[1], info.Addr[2], info.Addr[3])
+	}
+	return parseInet6Dst(m)
+}
+
+// srcControlMessage returns control message to send from the src
+func srcControlMessage(src net.IP) []byte {
+	if v4 := src.To4(); v4 != nil {
+		b, data := controlMessage(syscall.IPPROTO_IP, syscall.IP_PKTINFO, syscall.SizeofInet4Pktinfo)
+		copy((*syscall.Inet4Pktinfo)(data).Spec_dst[:], v4)
+		return b
+	}
+	return inet6SrcControlMessage(src)
+}
+
+// parseTTL extracts TTL from IP_TTL control message enabled by IP_RECVTTL
+func parseTTL(m syscall.SocketControlMessage) (int, bool) {
+	if m.Header.Level != syscall.IPPROTO_IP || m.Header.Type != syscall.IP_TTL || len(m.Data) < 4 {
+		return 0, false
+	}
+	return int(*(*int32)(unsafe.Pointer(&m.Data[0]))), true
+}
+
+// flowLabelControlMessage returns IPV6_FLOWINFO control message with the flow label
+func flowLabelControlMessage(label uint32) []byte {
+	b, data := controlMessage(syscall.IPPROTO_IPV6, ipv6FlowInfo, 4)
+	binary.BigEndian.PutUint32((*[4]byte)(data)[:], label&FlowLabelMask)
+	return b
+}
+
+// parseFlowLabel extracts flow label from IPV6_FLOWINFO control message
+func parseFlowLabel(m syscall.SocketControlMessage) (uint32, bool) {
+	if m.Header.Level != syscall.IPPROTO_IPV6 || m.Header.Type != ipv6FlowInfo || len(m.Data) < 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(m.Data) & FlowLabelMask, true
+}
@@ -0,0 +1,141 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"net"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+	syscall "golang.org/x/sys/unix"
+)
+
+func TestEnablePacketInfo(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, EnablePacketInfo(conn))
+	connfd, err := connFd(conn)
+	require.NoError(t, err)
+	enabled, err := syscall.GetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_PKTINFO)
+	require.NoError(t, err)
+	require.Equal(t, 1, enabled)
+}
+
+func TestReadPacketWithKernelTimestampAndDst(t *testing.T) {
+	// listen on all addresses
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, EnableKernelTimestampsSocket(conn))
+	require.NoError(t, EnablePacketInfo(conn))
+
+	// whole 127.0.0.0/8 is local
+	dst := &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: conn.LocalAddr().(*net.UDPAddr).Port}
+	cconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer cconn.Close()
+	_, err = cconn.WriteToUDP(ntpRequestBytes, dst)
+	require.NoError(t, err)
+
+	request, rxTimestamp, returnaddr, dstIP, err := ReadPacketWithKernelTimestampAndDst(conn)
+	require.NoError(t, err)
+	require.Equal(t, ntpRequest, request)
+	require.Equal(t, time.Now().Unix()/10, rxTimestamp.Unix()/10, "kernel timestamps should be within 10s")
+	require.Equal(t, cconn.LocalAddr().String(), returnaddr.String())
+	require.True(t, dst.IP.Equal(dstIP))
+
+	// reply must come from the address request was sent to
+	_, err = WriteFrom(conn, ntpResponseBytes, returnaddr, dstIP)
+	require.NoError(t, err)
+	require.NoError(t, cconn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, PacketSizeBytes)
+	_, from, err := cconn.ReadFromUDP(buf)
+	require.NoError(t, err)
+	require.Equal(t, dst.String(), from.String())
+}
+
+func TestReadPacketWithExtensions(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, EnableKernelTimestampsSocket(conn))
+	require.NoError(t, EnablePacketInfo(conn))
+	cconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer cconn.Close()
+
+	ext := MarshalExtensionFields([]ExtensionField{{Type: 0x0104, Value: []byte("unique")}})
+	_, err = cconn.WriteToUDP(append(append([]byte{}, ntpRequestBytes...), ext...), conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	request, gotExt, _, _, _, err := ReadPacketWithExtensions(conn)
+	require.NoError(t, err)
+	require.Equal(t, ntpRequest, request)
+	require.Equal(t, ext, gotExt)
+
+	// plain packet has no extensions
+	_, err = cconn.WriteToUDP(ntpRequestBytes, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	_, gotExt, _, _, _, err = ReadPacketWithExtensions(conn)
+	require.NoError(t, err)
+	require.Nil(t, gotExt)
+
+	// truncated packet is not padded with zeros
+	_, err = cconn.WriteToUDP(ntpRequestBytes[:PacketSizeBytes/2], conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	_, _, _, _, _, err = ReadPacketWithExtensions(conn)
+	require.ErrorIs(t, err, ErrShortPacket)
+}
+
+func TestWriteFromDefault(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+	cconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer cconn.Close()
+
+	_, err = WriteFrom(conn, ntpResponseBytes, cconn.LocalAddr().(*net.UDPAddr), nil)
+	require.NoError(t, err)
+	require.NoError(t, cconn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, PacketSizeBytes)
+	_, from, err := cconn.ReadFromUDP(buf)
+	require.NoError(t, err)
+	require.Equal(t, conn.LocalAddr().String(), from.String())
+}
+
+func TestParseControlMessage(t *testing.T) {
+	ts := syscall.NsecToTimespec(time.Unix(1647963000, 42).UnixNano())
+	b, data := controlMessage(syscall.SOL_SOCKET, syscall.SO_TIMESTAMPNS, int(unsafe.Sizeof(ts)))
+	*(*syscall.Timespec)(data) = ts
+	info, data := controlMessage(syscall.IPPROTO_IP, syscall.IP_PKTINFO, syscall.SizeofInet4Pktinfo)
+	copy((*syscall.Inet4Pktinfo)(data).Addr[:], net.ParseIP("127.0.0.2").To4())
+	b = append(b, info...)
+
+	rxTime, dst, err := ParseControlMessage(b)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1647963000, 42), rxTime)
+	require.Equal(t, "127.0.0.2", dst.String())
+
+	rxTime, dst, err = ParseControlMessage(nil)
+	require.NoError(t, err)
+	require.True(t, rxTime.IsZero())
+	require.Nil(t, dst)
+}
@@ -0,0 +1,191 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+)
+
+// Poll exponent limits, RFC 5905 section 7.3
+const (
+	MinPollLimit   int8 = 4  // 16s
+	MaxPollLimit   int8 = 17 // 36h
+	MinPollDefault int8 = 6  // 64s
+	MaxPollDefault int8 = 10 // 1024s
+)
+
+// Kiss codes changing the poll, RFC 5905 section 7.4
+const (
+	KissRate = "RATE"
+	KissDeny = "DENY"
+	KissRstr = "RSTR"
+)
+
+const (
+	// pollLimit is the hysteresis of the poll adjust counter
+	pollLimit = 30
+	// pollGate is the threshold of offset change in jitters, beyond which the poll is decreased
+	pollGate = 4
+	// jitterWeight is the weight of the newest sample in jitter average
+	jitterWeight = 0.25
+)
+
+// KissCode returns kiss code of a Kiss-o'-Death packet, empty string otherwise
+func (p *Packet) KissCode() string {
+	if p.Stratum != 0 || p.Settings>>6 != 3 {
+		return ""
+	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, p.ReferenceID)
+	return string(b)
+}
+
+// PollInterval converts poll exponent to interval
+func PollInterval(poll int8) time.Duration {
+	return time.Duration(1<<uint(poll)) * time.Second
+}
+
+// PollManager adapts poll interval of a single server, the way RFC 5905 clock discipline does.
+// Poll grows while offsets are stable relative to measured jitter, shrinks when they change,
+// backs off on timeouts and RATE kisses and is always clamped between MinPoll and MaxPoll.
+// DENY and RSTR kisses stop polling the server
+type PollManager struct {
+	sync.Mutex
+	minPoll    int8
+	maxPoll    int8
+	poll       int8
+	counter    int
+	jitter     float64
+	lastOffset float64
+	samples    int
+	stopped    bool
+}
+
+// NewPollManager returns PollManager starting at minPoll. Limits are clamped to MinPollLimit and MaxPollLimit
+func NewPollManager(minPoll, maxPoll int8) *PollManager {
+	minPoll = clampPoll(minPoll, MinPollLimit, MaxPollLimit)
+	maxPoll = clampPoll(maxPoll, minPoll, MaxPollLimit)
+	return &PollManager{minPoll: minPoll, maxPoll: maxPoll, poll: minPoll}
+}
+
+func clampPoll(poll, min, max int8) int8 {
+	if poll < min {
+		return min
+	}
+	if poll > max {
+		return max
+	}
+	return poll
+}
+
+func (m *PollManager) setPoll(poll int8) {
+	m.poll = clampPoll(poll, m.minPoll, m.maxPoll)
+	m.counter = 0
+}
+
+// Update adjusts the poll with the offset measured by a successful query
+func (m *PollManager) Update(offset time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	o := offset.Seconds()
+	m.samples++
+	if m.samples == 1 {
+		m.lastOffset = o
+		return
+	}
+	diff := o - m.lastOffset
+	m.lastOffset = o
+	if m.samples == 2 {
+		m.jitter = math.Abs(diff)
+		return
+	}
+	// offset change is compared with the jitter of previous samples
+	stable := math.Abs(diff) <= pollGate*m.jitter
+	m.jitter = math.Sqrt((1-jitterWeight)*m.jitter*m.jitter + jitterWeight*diff*diff)
+	if stable {
+		m.counter += int(m.poll)
+		if m.counter > pollLimit {
+			m.setPoll(m.poll + 1)
+		}
+		return
+	}
+	m.counter -= 2 * int(m.poll)
+	if m.counter < -pollLimit {
+		m.setPoll(m.poll - 1)
+	}
+}
+
+// Timeout backs the poll off after a query without response
+func (m *PollManager) Timeout() {
+	m.Lock()
+	defer m.Unlock()
+	m.setPoll(m.poll + 1)
+}
+
+// Kiss handles Kiss-o'-Death with the code. serverPoll is the poll of the KoD packet
+func (m *PollManager) Kiss(code string, serverPoll int8) {
+	m.Lock()
+	defer m.Unlock()
+	switch code {
+	case KissRate:
+		poll := m.poll + 1
+		if serverPoll > poll {
+			poll = serverPoll
+		}
+		m.setPoll(poll)
+	case KissDeny, KissRstr:
+		m.stopped = true
+	}
+}
+
+// Response adjusts the poll with the server response and the offset measured from it
+func (m *PollManager) Response(p *Packet, offset time.Duration) {
+	if code := p.KissCode(); code != "" {
+		m.Kiss(code, p.Poll)
+		return
+	}
+	m.Update(offset)
+}
+
+// Poll returns current poll exponent
+func (m *PollManager) Poll() int8 {
+	m.Lock()
+	defer m.Unlock()
+	return m.poll
+}
+
+// Interval returns current poll interval
+func (m *PollManager) Interval() time.Duration {
+	return PollInterval(m.Poll())
+}
+
+// Jitter returns measured offset jitter
+func (m *PollManager) Jitter() time.Duration {
+	m.Lock()
+	defer m.Unlock()
+	return time.Duration(m.jitter * float64(time.Second))
+}
+
+// Stopped returns true if the server asked not to be polled any more
+func (m *PollManager) Stopped() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.stopped
+}
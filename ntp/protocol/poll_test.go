@@ -0,0 +1,112 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollInterval(t *testing.T) {
+	require.Equal(t, 16*time.Second, PollInterval(4))
+	require.Equal(t, 1024*time.Second, PollInterval(10))
+}
+
+func TestNewPollManagerClamps(t *testing.T) {
+	m := NewPollManager(1, 20)
+	require.Equal(t, MinPollLimit, m.Poll())
+	require.Equal(t, MinPollLimit, m.minPoll)
+	require.Equal(t, MaxPollLimit, m.maxPoll)
+
+	m = NewPollManager(8, 6)
+	require.Equal(t, int8(8), m.minPoll)
+	require.Equal(t, int8(8), m.maxPoll)
+}
+
+func TestPollManagerStable(t *testing.T) {
+	m := NewPollManager(MinPollDefault, MaxPollDefault)
+	require.Equal(t, 64*time.Second, m.Interval())
+	for i := 0; i < 1000; i++ {
+		m.Update(time.Duration(i%2) * time.Microsecond)
+	}
+	require.Equal(t, MaxPollDefault, m.Poll())
+	require.InDelta(t, time.Microsecond, m.Jitter(), float64(time.Microsecond)/10)
+}
+
+func TestPollManagerUnstable(t *testing.T) {
+	m := NewPollManager(MinPollDefault, MaxPollDefault)
+	m.setPoll(9)
+	offset := time.Microsecond
+	for i := 0; i < 10; i++ {
+		// every change is way beyond jitter
+		m.Update(offset)
+		offset *= 10
+	}
+	require.Equal(t, MinPollDefault, m.Poll())
+}
+
+func TestPollManagerTimeout(t *testing.T) {
+	m := NewPollManager(6, 8)
+	m.Timeout()
+	require.Equal(t, int8(7), m.Poll())
+	m.Timeout()
+	m.Timeout()
+	require.Equal(t, int8(8), m.Poll())
+}
+
+func kod(code string, poll int8) *Packet {
+	return &Packet{
+		Settings:    0xe4,
+		Poll:        poll,
+		ReferenceID: binary.BigEndian.Uint32([]byte(code)),
+	}
+}
+
+func TestKissCode(t *testing.T) {
+	require.Equal(t, KissRate, kod(KissRate, 0).KissCode())
+	require.Equal(t, "", (&Packet{Settings: 0x24, Stratum: 1, ReferenceID: binary.BigEndian.Uint32([]byte("GPS\x00"))}).KissCode())
+}
+
+func TestPollManagerKiss(t *testing.T) {
+	m := NewPollManager(MinPollDefault, MaxPollDefault)
+	m.Response(kod(KissRate, 0), 0)
+	require.Equal(t, int8(7), m.Poll())
+	// server asks for a longer poll
+	m.Response(kod(KissRate, 9), 0)
+	require.Equal(t, int8(9), m.Poll())
+	m.Response(kod(KissRate, 12), 0)
+	require.Equal(t, MaxPollDefault, m.Poll())
+	require.False(t, m.Stopped())
+
+	// unknown codes are ignored
+	m.Response(kod("XXXX", 0), 0)
+	require.False(t, m.Stopped())
+
+	m.Response(kod(KissDeny, 0), 0)
+	require.True(t, m.Stopped())
+}
+
+func TestPollManagerResponse(t *testing.T) {
+	m := NewPollManager(MinPollDefault, MaxPollDefault)
+	p := &Packet{Settings: 0x24, Stratum: 1}
+	m.Response(p, time.Millisecond)
+	m.Response(p, 2*time.Millisecond)
+	require.Equal(t, time.Millisecond, m.Jitter())
+}
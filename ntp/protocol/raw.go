@@ -0,0 +1,129 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Offsets of Packet fields on the wire, for tools patching packets in place (fuzzers, tc-bpf injectors)
+const (
+	OffsetSettings       = 0
+	OffsetStratum        = 1
+	OffsetPoll           = 2
+	OffsetPrecision      = 3
+	OffsetRootDelay      = 4
+	OffsetRootDispersion = 8
+	OffsetReferenceID    = 12
+	OffsetRefTime        = 16
+	OffsetOrigTime       = 24
+	OffsetRxTime         = 32
+	OffsetTxTime         = 40
+)
+
+// Raw returns wire representation of the packet
+func (p *Packet) Raw() [PacketSizeBytes]byte {
+	var b [PacketSizeBytes]byte
+	b[OffsetSettings] = p.Settings
+	b[OffsetStratum] = p.Stratum
+	b[OffsetPoll] = uint8(p.Poll)
+	b[OffsetPrecision] = uint8(p.Precision)
+	binary.BigEndian.PutUint32(b[OffsetRootDelay:], p.RootDelay)
+	binary.BigEndian.PutUint32(b[OffsetRootDispersion:], p.RootDispersion)
+	binary.BigEndian.PutUint32(b[OffsetReferenceID:], p.ReferenceID)
+	binary.BigEndian.PutUint64(b[OffsetRefTime:], p.RefTimeRaw())
+	binary.BigEndian.PutUint64(b[OffsetOrigTime:], p.OrigTimeRaw())
+	binary.BigEndian.PutUint64(b[OffsetRxTime:], p.RxTimeRaw())
+	binary.BigEndian.PutUint64(b[OffsetTxTime:], p.TxTimeRaw())
+	return b
+}
+
+// SetRaw sets all fields of the packet from its wire representation
+func (p *Packet) SetRaw(b [PacketSizeBytes]byte) {
+	p.Settings = b[OffsetSettings]
+	p.Stratum = b[OffsetStratum]
+	p.Poll = int8(b[OffsetPoll])
+	p.Precision = int8(b[OffsetPrecision])
+	p.RootDelay = binary.BigEndian.Uint32(b[OffsetRootDelay:])
+	p.RootDispersion = binary.BigEndian.Uint32(b[OffsetRootDispersion:])
+	p.ReferenceID = binary.BigEndian.Uint32(b[OffsetReferenceID:])
+	p.SetRefTimeRaw(binary.BigEndian.Uint64(b[OffsetRefTime:]))
+	p.SetOrigTimeRaw(binary.BigEndian.Uint64(b[OffsetOrigTime:]))
+	p.SetRxTimeRaw(binary.BigEndian.Uint64(b[OffsetRxTime:]))
+	p.SetTxTimeRaw(binary.BigEndian.Uint64(b[OffsetTxTime:]))
+}
+
+// decode parses the packet header from the beginning of b, ignoring extension fields after it.
+// Errors match binary.Read: io.EOF if b is empty, io.ErrUnexpectedEOF if it's too short
+func (p *Packet) decode(b []byte) error {
+	if len(b) == 0 {
+		return io.EOF
+	}
+	if len(b) < PacketSizeBytes {
+		return io.ErrUnexpectedEOF
+	}
+	var raw [PacketSizeBytes]byte
+	copy(raw[:], b)
+	p.SetRaw(raw)
+	return nil
+}
+
+// timestamp joins seconds and fractions into NTP 64-bit timestamp
+func timestamp(sec, frac uint32) uint64 {
+	return uint64(sec)<<32 | uint64(frac)
+}
+
+// RefTimeRaw returns reference timestamp as on the wire
+func (p *Packet) RefTimeRaw() uint64 {
+	return timestamp(p.RefTimeSec, p.RefTimeFrac)
+}
+
+// SetRefTimeRaw sets reference timestamp as on the wire
+func (p *Packet) SetRefTimeRaw(t uint64) {
+	p.RefTimeSec, p.RefTimeFrac = uint32(t>>32), uint32(t)
+}
+
+// OrigTimeRaw returns origin timestamp as on the wire
+func (p *Packet) OrigTimeRaw() uint64 {
+	return timestamp(p.OrigTimeSec, p.OrigTimeFrac)
+}
+
+// SetOrigTimeRaw sets origin timestamp as on the wire
+func (p *Packet) SetOrigTimeRaw(t uint64) {
+	p.OrigTimeSec, p.OrigTimeFrac = uint32(t>>32), uint32(t)
+}
+
+// RxTimeRaw returns receive timestamp as on the wire
+func (p *Packet) RxTimeRaw() uint64 {
+	return timestamp(p.RxTimeSec, p.RxTimeFrac)
+}
+
+// SetRxTimeRaw sets receive timestamp as on the wire
+func (p *Packet) SetRxTimeRaw(t uint64) {
+	p.RxTimeSec, p.RxTimeFrac = uint32(t>>32), uint32(t)
+}
+
+// TxTimeRaw returns transmit timestamp as on the wire
+func (p *Packet) TxTimeRaw() uint64 {
+	return timestamp(p.TxTimeSec, p.TxTimeFrac)
+}
+
+// SetTxTimeRaw sets transmit timestamp as on the wire
+func (p *Packet) SetTxTimeRaw(t uint64) {
+	p.TxTimeSec, p.TxTimeFrac = uint32(t>>32), uint32(t)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRaw(t *testing.T) {
+	raw := ntpResponse.Raw()
+	require.Equal(t, ntpResponseBytes, raw[:])
+
+	p := &Packet{}
+	p.SetRaw(raw)
+	require.Equal(t, ntpResponse, p)
+}
+
+// Raw must stay identical to the reflection based encoding of the struct
+func TestRawMatchesBinary(t *testing.T) {
+	p := &Packet{
+		Settings:       0xe3,
+		Stratum:        2,
+		Poll:           -3,
+		Precision:      -20,
+		RootDelay:      0x01020304,
+		RootDispersion: 0x05060708,
+		ReferenceID:    0x090a0b0c,
+		RefTimeSec:     0x11121314,
+		RefTimeFrac:    0x15161718,
+		OrigTimeSec:    0x21222324,
+		OrigTimeFrac:   0x25262728,
+		RxTimeSec:      0x31323334,
+		RxTimeFrac:     0x35363738,
+		TxTimeSec:      0x41424344,
+		TxTimeFrac:     0x45464748,
+	}
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.BigEndian, p))
+	raw := p.Raw()
+	require.Equal(t, buf.Bytes(), raw[:])
+	require.Equal(t, PacketSizeBytes, binary.Size(p))
+}
+
+func TestRawTimestamps(t *testing.T) {
+	p := &Packet{}
+	p.SetRefTimeRaw(0x0102030405060708)
+	p.SetOrigTimeRaw(0x1112131415161718)
+	p.SetRxTimeRaw(0x2122232425262728)
+	p.SetTxTimeRaw(0x3132333435363738)
+	require.Equal(t, uint32(0x01020304), p.RefTimeSec)
+	require.Equal(t, uint32(0x05060708), p.RefTimeFrac)
+	require.Equal(t, uint32(0x31323334), p.TxTimeSec)
+	require.Equal(t, uint32(0x35363738), p.TxTimeFrac)
+	require.Equal(t, uint64(0x0102030405060708), p.RefTimeRaw())
+	require.Equal(t, uint64(0x1112131415161718), p.OrigTimeRaw())
+	require.Equal(t, uint64(0x2122232425262728), p.RxTimeRaw())
+	require.Equal(t, uint64(0x3132333435363738), p.TxTimeRaw())
+
+	raw := p.Raw()
+	require.Equal(t, uint64(0x0102030405060708), binary.BigEndian.Uint64(raw[OffsetRefTime:]))
+	require.Equal(t, uint64(0x1112131415161718), binary.BigEndian.Uint64(raw[OffsetOrigTime:]))
+	require.Equal(t, uint64(0x2122232425262728), binary.BigEndian.Uint64(raw[OffsetRxTime:]))
+	require.Equal(t, uint64(0x3132333435363738), binary.BigEndian.Uint64(raw[OffsetTxTime:]))
+}
+
+func TestBytesToPacketShort(t *testing.T) {
+	_, err := BytesToPacket(nil)
+	require.Equal(t, io.EOF, err)
+	packet, err := BytesToPacket(ntpResponseBytes[:PacketSizeBytes-1])
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	require.Equal(t, &Packet{}, packet)
+	// extension fields are ignored
+	packet, err = BytesToPacket(append(append([]byte{}, ntpResponseBytes...), 0, 0, 0, 0))
+	require.NoError(t, err)
+	require.Equal(t, ntpResponse, packet)
+}
+
+func Benchmark_PacketToRawConversion(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = ntpResponse.Raw()
+	}
+}
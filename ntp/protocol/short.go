@@ -0,0 +1,71 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"math"
+	"time"
+)
+
+// shortUnit is a value of the lowest bit of NTP short format, 1/65536 of a second
+const shortUnit = float64(time.Second) / 65536
+
+// MaxShortDuration is the largest duration representable in NTP short format, just under 65536 seconds
+const MaxShortDuration = time.Duration(math.MaxUint32) * time.Second / 65536
+
+/*
+ShortToDuration converts NTP short format (16 bit seconds, 16 bit fraction), used by
+root delay and root dispersion, to duration rounded to the nearest nanosecond (half away from zero).
+
+Short format resolution is ~15.26µs, so DurationToShort(ShortToDuration(v)) == v for any v
+*/
+func ShortToDuration(v uint32) time.Duration {
+	return time.Duration(math.Round(float64(v) * shortUnit))
+}
+
+/*
+DurationToShort converts duration to NTP short format rounded to the nearest 1/65536 of a second
+(half away from zero), so the error is at most ~7.63µs.
+
+Root delay and root dispersion can't be negative: negative durations convert to 0,
+durations above MaxShortDuration to the largest value
+*/
+func DurationToShort(d time.Duration) uint32 {
+	if d <= 0 {
+		return 0
+	}
+	v := math.Round(float64(d) / shortUnit)
+	if v >= math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(v)
+}
+
+// ShortToSeconds converts NTP short format to seconds. Conversion is exact
+func ShortToSeconds(v uint32) float64 {
+	return float64(v) / 65536
+}
+
+// RootDelayDuration returns root delay of the packet as duration
+func (p *Packet) RootDelayDuration() time.Duration {
+	return ShortToDuration(p.RootDelay)
+}
+
+// RootDispersionDuration returns root dispersion of the packet as duration
+func (p *Packet) RootDispersionDuration() time.Duration {
+	return ShortToDuration(p.RootDispersion)
+}
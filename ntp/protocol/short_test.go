@@ -0,0 +1,91 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortToDuration(t *testing.T) {
+	require.Equal(t, time.Duration(0), ShortToDuration(0))
+	require.Equal(t, time.Second, ShortToDuration(65536))
+	// 15258.7890625ns is rounded up
+	require.Equal(t, 15259*time.Nanosecond, ShortToDuration(1))
+	// 152587.890625ns is rounded up
+	require.Equal(t, 152588*time.Nanosecond, ShortToDuration(10))
+	require.Equal(t, MaxShortDuration, ShortToDuration(math.MaxUint32))
+}
+
+func TestDurationToShort(t *testing.T) {
+	require.Equal(t, uint32(0), DurationToShort(0))
+	require.Equal(t, uint32(0), DurationToShort(-time.Second))
+	require.Equal(t, uint32(65536), DurationToShort(time.Second))
+	require.Equal(t, uint32(32768), DurationToShort(500*time.Millisecond))
+	// half of the unit is rounded away from zero
+	require.Equal(t, uint32(0), DurationToShort(7629*time.Nanosecond))
+	require.Equal(t, uint32(1), DurationToShort(7630*time.Nanosecond))
+	require.Equal(t, uint32(10), DurationToShort(152*time.Microsecond))
+	require.Equal(t, uint32(math.MaxUint32), DurationToShort(MaxShortDuration))
+	require.Equal(t, uint32(math.MaxUint32), DurationToShort(24*time.Hour))
+}
+
+func TestShortToSeconds(t *testing.T) {
+	require.Equal(t, 1.5, ShortToSeconds(98304))
+	require.Equal(t, 1.0/65536, ShortToSeconds(1))
+}
+
+func TestShortRoundTrip(t *testing.T) {
+	f := func(v uint32) bool {
+		return DurationToShort(ShortToDuration(v)) == v
+	}
+	require.NoError(t, quick.Check(f, nil))
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	halfUnit := time.Second / 65536 / 2
+	f := func(v int64) bool {
+		d := time.Duration(v) % MaxShortDuration
+		if d < 0 {
+			d = -d
+		}
+		diff := ShortToDuration(DurationToShort(d)) - d
+		return diff <= halfUnit+1 && diff >= -halfUnit-1
+	}
+	require.NoError(t, quick.Check(f, nil))
+}
+
+func TestShortMonotonic(t *testing.T) {
+	f := func(a, b int64) bool {
+		da, db := time.Duration(a)%MaxShortDuration, time.Duration(b)%MaxShortDuration
+		if da > db {
+			da, db = db, da
+		}
+		return DurationToShort(da) <= DurationToShort(db)
+	}
+	require.NoError(t, quick.Check(f, nil))
+}
+
+func TestPacketRootDuration(t *testing.T) {
+	p := &Packet{RootDelay: 65536, RootDispersion: 32768}
+	require.Equal(t, time.Second, p.RootDelayDuration())
+	require.Equal(t, 500*time.Millisecond, p.RootDispersionDuration())
+}
@@ -0,0 +1,109 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// ExtensionSmear is an experimental extension field type carrying leap smear status.
+// It's taken from the range not assigned by IANA, so it may change
+const ExtensionSmear uint16 = 0xF5EA
+
+// smearValueSize is the size of encoded SmearInfo
+const smearValueSize = 20
+
+// smearFlagActive is set while smear window is in progress
+const smearFlagActive = 1 << 0
+
+// SmearInfo describes leap smear applied to the time server returns
+/*
+   0                   1                   2                   3
+   0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  |     Flags     |                   Reserved                    |
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  |                  Window Start (NTP seconds)                   |
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  |                  Window Duration (seconds)                    |
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+  |                                                               |
+  +                  Offset (signed nanoseconds)                  +
+  |                                                               |
+  +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+*/
+type SmearInfo struct {
+	// Active is true while smear is in progress
+	Active bool
+	// Start of the smear window
+	Start time.Time
+	// Duration of the smear window
+	Duration time.Duration
+	// Offset is smeared minus unsmeared time at the moment of response
+	Offset time.Duration
+}
+
+// ExtensionField encodes SmearInfo as an extension field
+func (s *SmearInfo) ExtensionField() ExtensionField {
+	v := make([]byte, smearValueSize)
+	if s.Active {
+		v[0] = smearFlagActive
+	}
+	sec, _ := Time(s.Start)
+	binary.BigEndian.PutUint32(v[4:], sec)
+	binary.BigEndian.PutUint32(v[8:], uint32(s.Duration/time.Second))
+	binary.BigEndian.PutUint64(v[12:], uint64(s.Offset))
+	return ExtensionField{Type: ExtensionSmear, Value: v}
+}
+
+// Unsmear removes smear offset from the time received from the server
+func (s *SmearInfo) Unsmear(t time.Time) time.Time {
+	return t.Add(-s.Offset)
+}
+
+// SmearInfoFromExtension decodes SmearInfo from the extension field
+func SmearInfoFromExtension(e ExtensionField) (*SmearInfo, bool) {
+	if e.Type != ExtensionSmear || len(e.Value) < smearValueSize {
+		return nil, false
+	}
+	v := e.Value
+	return &SmearInfo{
+		Active:   v[0]&smearFlagActive != 0,
+		Start:    Unix(binary.BigEndian.Uint32(v[4:]), 0),
+		Duration: time.Duration(binary.BigEndian.Uint32(v[8:])) * time.Second,
+		Offset:   time.Duration(binary.BigEndian.Uint64(v[12:])),
+	}, true
+}
+
+// SmearInfoFromBytes looks for smear extension field in the whole NTP packet.
+// Returns nil if the server doesn't smear or doesn't support the extension
+func SmearInfoFromBytes(packet []byte) (*SmearInfo, error) {
+	if len(packet) <= PacketSizeBytes {
+		return nil, nil
+	}
+	fields, err := ParseExtensionFields(packet[PacketSizeBytes:])
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		if s, ok := SmearInfoFromExtension(f); ok {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// ErrNotSupported is returned when socket option is not supported on this platform or kernel
+var ErrNotSupported = errors.New("socket option is not supported")
+
+// SocketOption tunes the socket
+type SocketOption struct {
+	Name  string
+	apply func(connfd int) error
+}
+
+// WithRecvBuffer sets SO_RCVBUF. Privileged processes may exceed net.core.rmem_max
+func WithRecvBuffer(size int) SocketOption {
+	return SocketOption{
+		Name: "SO_RCVBUF",
+		apply: func(connfd int) error {
+			return setBuffer(connfd, syscall.SO_RCVBUF, size)
+		},
+	}
+}
+
+// WithSendBuffer sets SO_SNDBUF. Privileged processes may exceed net.core.wmem_max
+func WithSendBuffer(size int) SocketOption {
+	return SocketOption{
+		Name: "SO_SNDBUF",
+		apply: func(connfd int) error {
+			return setBuffer(connfd, syscall.SO_SNDBUF, size)
+		},
+	}
+}
+
+// WithBusyPoll sets SO_BUSY_POLL to busy poll the device queue for usec microseconds on blocking reads
+func WithBusyPoll(usec int) SocketOption {
+	return SocketOption{
+		Name: "SO_BUSY_POLL",
+		apply: func(connfd int) error {
+			return setBusyPoll(connfd, usec)
+		},
+	}
+}
+
+// WithRecvErr enables IP_RECVERR (IPV6_RECVERR for IPv6 sockets) to receive ICMP errors on the error queue
+// instead of failing the next read
+func WithRecvErr() SocketOption {
+	return SocketOption{
+		Name: "IP_RECVERR",
+		apply: func(connfd int) error {
+			v6, err := isIPv6(connfd)
+			if err != nil {
+				return err
+			}
+			return setRecvErr(connfd, v6)
+		},
+	}
+}
+
+// WithGRO enables UDP generic receive offload.
+// Kernel may coalesce datagrams from the same flow, so reader must split them using UDP_GRO control message
+func WithGRO() SocketOption {
+	return SocketOption{
+		Name: "UDP_GRO",
+		apply: func(connfd int) error {
+			return setGRO(connfd)
+		},
+	}
+}
+
+// TuneSocket applies options to the socket. It stops on the first failure
+func TuneSocket(conn *net.UDPConn, opts ...SocketOption) error {
+	connfd, err := connFd(conn)
+	if err != nil {
+		return err
+	}
+	for _, o := range opts {
+		if err := o.apply(connfd); err != nil {
+			return fmt.Errorf("failed to set %s: %w", o.Name, err)
+		}
+	}
+	return nil
+}
+
+// Supported checks if the option can be applied to a UDP socket of the network (udp4 or udp6)
+func Supported(network string, opt SocketOption) bool {
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	return TuneSocket(conn, opt) == nil
+}
+
+// isIPv6 checks if the socket is IPv6
+func isIPv6(connfd int) (bool, error) {
+	sa, err := syscall.Getsockname(connfd)
+	if err != nil {
+		return false, err
+	}
+	_, v6 := sa.(*syscall.SockaddrInet6)
+	return v6, nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	syscall "golang.org/x/sys/unix"
+)
+
+func setBuffer(connfd, opt, size int) error {
+	return syscall.SetsockoptInt(connfd, syscall.SOL_SOCKET, opt, size)
+}
+
+func setBusyPoll(connfd, usec int) error {
+	return ErrNotSupported
+}
+
+func setRecvErr(connfd int, v6 bool) error {
+	return ErrNotSupported
+}
+
+func setGRO(connfd int) error {
+	return ErrNotSupported
+}
+
+func setFlowLabel(connfd int, label uint32) error {
+	return ErrNotSupported
+}
+
+func setRecvFlowLabel(connfd int) error {
+	return ErrNotSupported
+}
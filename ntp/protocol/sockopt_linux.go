@@ -0,0 +1,96 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"net"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// udpGRO is UDP_GRO from linux/udp.h, available since 5.0
+const udpGRO = 104
+
+// setBuffer tries privileged SO_RCVBUFFORCE/SO_SNDBUFFORCE first to bypass the sysctl limit
+func setBuffer(connfd, opt, size int) error {
+	force := syscall.SO_RCVBUFFORCE
+	if opt == syscall.SO_SNDBUF {
+		force = syscall.SO_SNDBUFFORCE
+	}
+	if err := syscall.SetsockoptInt(connfd, syscall.SOL_SOCKET, force, size); err == nil {
+		return nil
+	}
+	return syscall.SetsockoptInt(connfd, syscall.SOL_SOCKET, opt, size)
+}
+
+func setBusyPoll(connfd, usec int) error {
+	return syscall.SetsockoptInt(connfd, syscall.SOL_SOCKET, syscall.SO_BUSY_POLL, usec)
+}
+
+func setRecvErr(connfd int, v6 bool) error {
+	if v6 {
+		return syscall.SetsockoptInt(connfd, syscall.IPPROTO_IPV6, syscall.IPV6_RECVERR, 1)
+	}
+	return syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_RECVERR, 1)
+}
+
+func setGRO(connfd int) error {
+	if err := syscall.SetsockoptInt(connfd, syscall.IPPROTO_UDP, udpGRO, 1); err != nil {
+		if err == syscall.ENOPROTOOPT {
+			return ErrNotSupported
+		}
+		return err
+	}
+	return nil
+}
+
+// Linux IPv6 flow label manager, linux/in6.h
+const (
+	ipv6FlowLabelMgr = 32
+	ipv6FlowInfo     = 11
+	ipv6FlAGet       = 0
+	ipv6FlFCreate    = 1
+	ipv6FlSAny       = 255
+)
+
+// in6FlowLabelReq is struct in6_flowlabel_req
+type in6FlowLabelReq struct {
+	Dst     [16]byte
+	Label   [4]byte // big endian
+	Action  uint8
+	Share   uint8
+	Flags   uint16
+	Expires uint16
+	Linger  uint16
+	Pad     uint32
+}
+
+func setFlowLabel(connfd int, label uint32) error {
+	req := in6FlowLabelReq{Action: ipv6FlAGet, Share: ipv6FlSAny, Flags: ipv6FlFCreate}
+	// kernel requires a destination of the label, but only uses it for packets sent without one.
+	// Packets to any destination can carry the label
+	copy(req.Dst[:], net.IPv6loopback)
+	binary.BigEndian.PutUint32(req.Label[:], label)
+	b := (*[unsafe.Sizeof(req)]byte)(unsafe.Pointer(&req))[:]
+	return syscall.SetsockoptString(connfd, syscall.IPPROTO_IPV6, ipv6FlowLabelMgr, string(b))
+}
+
+func setRecvFlowLabel(connfd int) error {
+	return syscall.SetsockoptInt(connfd, syscall.IPPROTO_IPV6, ipv6FlowInfo, 1)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	syscall "golang.org/x/sys/unix"
+)
+
+func TestTuneSocket(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	err = TuneSocket(conn, WithRecvBuffer(4096), WithSendBuffer(8192), WithRecvErr())
+	require.NoError(t, err)
+
+	connfd, err := connFd(conn)
+	require.NoError(t, err)
+	// kernel doubles the value to account for bookkeeping overhead
+	rcvbuf, err := syscall.GetsockoptInt(connfd, syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+	require.NoError(t, err)
+	require.Equal(t, 2*4096, rcvbuf)
+	sndbuf, err := syscall.GetsockoptInt(connfd, syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	require.NoError(t, err)
+	require.Equal(t, 2*8192, sndbuf)
+	recverr, err := syscall.GetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_RECVERR)
+	require.NoError(t, err)
+	require.Equal(t, 1, recverr)
+}
+
+func TestTuneSocketError(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	err = TuneSocket(conn, WithBusyPoll(-1))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "SO_BUSY_POLL")
+}
+
+func TestSupported(t *testing.T) {
+	require.True(t, Supported("udp4", WithRecvBuffer(4096)))
+	require.True(t, Supported("udp4", WithRecvErr()))
+	require.False(t, Supported("nope", WithRecvErr()))
+}
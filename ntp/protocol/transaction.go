@@ -0,0 +1,153 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Errors returned by Transactions.Match for replies which must be discarded
+var (
+	ErrTransactionExists = errors.New("transaction with the same transmit timestamp is already pending")
+	ErrDuplicateReply    = errors.New("duplicate reply to already answered request")
+	ErrLateReply         = errors.New("late reply to expired request")
+	ErrUnsolicitedReply  = errors.New("reply doesn't match any request")
+)
+
+// TransactionStats counts requests and replies seen by Transactions
+type TransactionStats struct {
+	Requests    uint64 `json:"requests"`
+	Replies     uint64 `json:"replies"`
+	Duplicates  uint64 `json:"duplicates"`
+	Late        uint64 `json:"late"`
+	Unsolicited uint64 `json:"unsolicited"`
+	Expired     uint64 `json:"expired"`
+}
+
+type transaction struct {
+	id       uint64
+	sent     time.Time
+	finished time.Time
+	expired  bool
+}
+
+// Transactions tracks client requests by their transmit timestamp and matches replies by origin timestamp.
+// Each request gets a monotonic transaction ID. Replies repeated by middleboxes or replayed, replies arriving
+// after the request timed out, and replies to unknown requests are detected and counted.
+// Transmit timestamps should be unique per request, see SetRandomTransmitTime
+type Transactions struct {
+	// Timeout after which pending request is considered lost.
+	// Finished transactions are remembered for another Timeout to detect duplicates
+	Timeout time.Duration
+
+	sync.Mutex
+	seq     uint64
+	pending map[uint64]*transaction
+	done    map[uint64]*transaction
+	stats   TransactionStats
+}
+
+// NewTransactions returns Transactions with given request timeout
+func NewTransactions(timeout time.Duration) *Transactions {
+	return &Transactions{
+		Timeout: timeout,
+		pending: map[uint64]*transaction{},
+		done:    map[uint64]*transaction{},
+	}
+}
+
+func transactionKey(sec, frac uint32) uint64 {
+	return uint64(sec)<<32 | uint64(frac)
+}
+
+// prune expires pending transactions and forgets old finished ones. Must be called with lock held
+func (t *Transactions) prune(now time.Time) {
+	for k, tr := range t.pending {
+		if now.Sub(tr.sent) > t.Timeout {
+			delete(t.pending, k)
+			tr.expired = true
+			tr.finished = now
+			t.done[k] = tr
+			t.stats.Expired++
+		}
+	}
+	for k, tr := range t.done {
+		if now.Sub(tr.finished) > t.Timeout {
+			delete(t.done, k)
+		}
+	}
+}
+
+// Start registers request sent at given time and returns its transaction ID
+func (t *Transactions) Start(request *Packet, now time.Time) (uint64, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.prune(now)
+	key := transactionKey(request.TxTimeSec, request.TxTimeFrac)
+	if _, ok := t.pending[key]; ok {
+		return 0, ErrTransactionExists
+	}
+	// reused transmit timestamp starts a new transaction
+	delete(t.done, key)
+	t.seq++
+	t.pending[key] = &transaction{id: t.seq, sent: now}
+	t.stats.Requests++
+	return t.seq, nil
+}
+
+// Match finds the pending transaction for the response received at given time.
+// Transaction ID is returned along with ErrDuplicateReply or ErrLateReply when the transaction is known but already finished
+func (t *Transactions) Match(response *Packet, now time.Time) (uint64, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.prune(now)
+	key := transactionKey(response.OrigTimeSec, response.OrigTimeFrac)
+	if tr, ok := t.pending[key]; ok {
+		delete(t.pending, key)
+		tr.finished = now
+		t.done[key] = tr
+		t.stats.Replies++
+		return tr.id, nil
+	}
+	tr, ok := t.done[key]
+	if !ok {
+		t.stats.Unsolicited++
+		return 0, ErrUnsolicitedReply
+	}
+	if tr.expired {
+		t.stats.Late++
+		return tr.id, ErrLateReply
+	}
+	t.stats.Duplicates++
+	return tr.id, ErrDuplicateReply
+}
+
+// Pending returns number of requests waiting for a reply
+func (t *Transactions) Pending() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.pending)
+}
+
+// Stats returns copy of the counters
+func (t *Transactions) Stats() TransactionStats {
+	t.Lock()
+	defer t.Unlock()
+	return t.stats
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func reply(request *Packet) *Packet {
+	return &Packet{OrigTimeSec: request.TxTimeSec, OrigTimeFrac: request.TxTimeFrac}
+}
+
+func TestTransactions(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tr := NewTransactions(time.Second)
+
+	a := &Packet{TxTimeSec: 1, TxTimeFrac: 1}
+	b := &Packet{TxTimeSec: 1, TxTimeFrac: 2}
+	idA, err := tr.Start(a, now)
+	require.NoError(t, err)
+	idB, err := tr.Start(b, now)
+	require.NoError(t, err)
+	require.Less(t, idA, idB)
+	require.Equal(t, 2, tr.Pending())
+
+	_, err = tr.Start(a, now)
+	require.ErrorIs(t, err, ErrTransactionExists)
+
+	id, err := tr.Match(reply(a), now.Add(10*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, idA, id)
+
+	// middlebox retransmission
+	id, err = tr.Match(reply(a), now.Add(20*time.Millisecond))
+	require.ErrorIs(t, err, ErrDuplicateReply)
+	require.Equal(t, idA, id)
+
+	_, err = tr.Match(&Packet{OrigTimeSec: 42}, now)
+	require.ErrorIs(t, err, ErrUnsolicitedReply)
+
+	// b times out and its reply arrives later
+	id, err = tr.Match(reply(b), now.Add(1500*time.Millisecond))
+	require.ErrorIs(t, err, ErrLateReply)
+	require.Equal(t, idB, id)
+	require.Equal(t, 0, tr.Pending())
+
+	// everything is forgotten after another timeout
+	_, err = tr.Match(reply(a), now.Add(5*time.Second))
+	require.ErrorIs(t, err, ErrUnsolicitedReply)
+
+	require.Equal(t, TransactionStats{
+		Requests:    2,
+		Replies:     1,
+		Duplicates:  1,
+		Late:        1,
+		Unsolicited: 2,
+		Expired:     1,
+	}, tr.Stats())
+}
+
+func TestTransactionsReuse(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tr := NewTransactions(time.Second)
+	a := &Packet{TxTimeSec: 1, TxTimeFrac: 1}
+	id1, err := tr.Start(a, now)
+	require.NoError(t, err)
+	_, err = tr.Match(reply(a), now)
+	require.NoError(t, err)
+	id2, err := tr.Start(a, now)
+	require.NoError(t, err)
+	require.Equal(t, id1+1, id2)
+	id, err := tr.Match(reply(a), now)
+	require.NoError(t, err)
+	require.Equal(t, id2, id)
+}
@@ -0,0 +1,351 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Transport networks
+const (
+	TransportUDP  = "udp"
+	TransportTCP  = "tcp"
+	TransportUnix = "unix"
+)
+
+// Limits of stream transport listeners
+const (
+	// DefaultMaxStreamConns is the default limit of concurrent client connections
+	DefaultMaxStreamConns = 1024
+	// DefaultStreamIdleTimeout is the default time client connection is kept open without a full packet received
+	DefaultStreamIdleTimeout = 30 * time.Second
+)
+
+var (
+	errUnsupportedTransport = errors.New("unsupported transport")
+	errFrameTooBig          = errors.New("packet is too big for the frame")
+	errUnknownClient        = errors.New("client is not connected")
+)
+
+// Transport carries NTP packets between clients and servers.
+// Every Read and Write of its connections is a single whole packet, so packet logic doesn't depend on the transport
+type Transport interface {
+	// Dial connects to the server
+	Dial(address string, timeout time.Duration) (net.Conn, error)
+	// Listen returns connection receiving packets from all clients. Replies are sent with WriteTo to the source address
+	Listen(address string) (net.PacketConn, error)
+}
+
+// NewTransport returns transport for the network: udp (default), or experimental tcp and unix
+func NewTransport(network string) (Transport, error) {
+	switch network {
+	case TransportUDP, "":
+		return &UDPTransport{}, nil
+	case TransportTCP, TransportUnix:
+		return &StreamTransport{Network: network}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", errUnsupportedTransport, network)
+}
+
+// UDPTransport is the standard NTP transport
+type UDPTransport struct{}
+
+// Dial connects to the server
+func (t *UDPTransport) Dial(address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("udp", address, timeout)
+}
+
+// Listen for clients
+func (t *UDPTransport) Listen(address string) (net.PacketConn, error) {
+	return net.ListenPacket("udp", address)
+}
+
+// StreamTransport carries packets over stream sockets, such as TCP to pass firewalls or unix sockets for local testing.
+// Every packet is framed with 2 byte big-endian length
+type StreamTransport struct {
+	Network string
+	// MaxConns limits concurrent client connections of the listener, further ones are closed right away.
+	// DefaultMaxStreamConns if 0
+	MaxConns int
+	// IdleTimeout closes client connections which didn't send a full packet for this long.
+	// DefaultStreamIdleTimeout if 0
+	IdleTimeout time.Duration
+}
+
+// Dial connects to the server
+func (t *StreamTransport) Dial(address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout(t.Network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &framedConn{Conn: conn}, nil
+}
+
+// Listen for clients
+func (t *StreamTransport) Listen(address string) (net.PacketConn, error) {
+	ln, err := net.Listen(t.Network, address)
+	if err != nil {
+		return nil, err
+	}
+	maxConns := t.MaxConns
+	if maxConns <= 0 {
+		maxConns = DefaultMaxStreamConns
+	}
+	idle := t.IdleTimeout
+	if idle <= 0 {
+		idle = DefaultStreamIdleTimeout
+	}
+	return newStreamPacketConn(ln, maxConns, idle), nil
+}
+
+// framedConn is a packet oriented connection over stream
+type framedConn struct {
+	net.Conn
+}
+
+// Write sends one packet
+func (c *framedConn) Write(b []byte) (int, error) {
+	if err := writeFrame(c.Conn, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Read receives one packet. Packet is truncated if b is too small
+func (c *framedConn) Read(b []byte) (int, error) {
+	frame, err := readFrame(c.Conn)
+	if err != nil {
+		return 0, err
+	}
+	return copy(b, frame), nil
+}
+
+func writeFrame(w io.Writer, b []byte) error {
+	if len(b) > 0xffff {
+		return fmt.Errorf("%w: %d bytes", errFrameTooBig, len(b))
+	}
+	frame := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(frame, uint16(len(b)))
+	copy(frame[2:], b)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	l := make([]byte, 2)
+	if _, err := io.ReadFull(r, l); err != nil {
+		return nil, err
+	}
+	frame := make([]byte, binary.BigEndian.Uint16(l))
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// StreamAddr identifies a client connected via StreamTransport
+type StreamAddr struct {
+	// Remote is the address of the connection, it may be empty for unix sockets
+	Remote net.Addr
+	id     uint64
+}
+
+// Network of the connection
+func (a *StreamAddr) Network() string {
+	return a.Remote.Network()
+}
+
+func (a *StreamAddr) String() string {
+	return fmt.Sprintf("%s#%d", a.Remote, a.id)
+}
+
+// AddrIP returns IP of the client address, nil if it has none (unix sockets)
+func AddrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	case *StreamAddr:
+		return AddrIP(a.Remote)
+	}
+	return nil
+}
+
+type streamPacket struct {
+	b    []byte
+	from *StreamAddr
+}
+
+// streamPacketConn presents packets from all accepted stream connections as a single net.PacketConn
+type streamPacketConn struct {
+	ln       net.Listener
+	maxConns int
+	idle     time.Duration
+	packets  chan streamPacket
+	done     chan struct{}
+	once     sync.Once
+
+	sync.Mutex
+	conns    map[uint64]net.Conn
+	nextID   uint64
+	deadline time.Time
+}
+
+func newStreamPacketConn(ln net.Listener, maxConns int, idle time.Duration) *streamPacketConn {
+	c := &streamPacketConn{
+		ln:       ln,
+		maxConns: maxConns,
+		idle:     idle,
+		packets:  make(chan streamPacket),
+		done:     make(chan struct{}),
+		conns:    map[uint64]net.Conn{},
+	}
+	go c.accept()
+	return c
+}
+
+func (c *streamPacketConn) accept() {
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			return
+		}
+		c.Lock()
+		if len(c.conns) >= c.maxConns {
+			c.Unlock()
+			conn.Close()
+			continue
+		}
+		c.nextID++
+		from := &StreamAddr{Remote: conn.RemoteAddr(), id: c.nextID}
+		c.conns[from.id] = conn
+		c.Unlock()
+		go c.read(conn, from)
+	}
+}
+
+func (c *streamPacketConn) read(conn net.Conn, from *StreamAddr) {
+	defer func() {
+		c.Lock()
+		delete(c.conns, from.id)
+		c.Unlock()
+		conn.Close()
+	}()
+	for {
+		// idle clients are disconnected, deadline is refreshed with every packet
+		if err := conn.SetReadDeadline(time.Now().Add(c.idle)); err != nil {
+			return
+		}
+		frame, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		select {
+		case c.packets <- streamPacket{b: frame, from: from}:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// ReadFrom returns next packet from any of the clients
+func (c *streamPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.Lock()
+	deadline := c.deadline
+	c.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		t := time.NewTimer(time.Until(deadline))
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case p := <-c.packets:
+		return copy(b, p.b), p.from, nil
+	case <-timeout:
+		return 0, nil, &net.OpError{Op: "read", Net: c.ln.Addr().Network(), Addr: c.ln.Addr(), Err: errTimeout{}}
+	case <-c.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteTo sends packet to the client it came from
+func (c *streamPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	a, ok := addr.(*StreamAddr)
+	if !ok {
+		return 0, fmt.Errorf("%w: %v", errUnknownClient, addr)
+	}
+	c.Lock()
+	conn, ok := c.conns[a.id]
+	c.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("%w: %v", errUnknownClient, addr)
+	}
+	if err := writeFrame(conn, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close stops accepting clients and closes all connections
+func (c *streamPacketConn) Close() error {
+	err := c.ln.Close()
+	c.once.Do(func() { close(c.done) })
+	c.Lock()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	c.Unlock()
+	return err
+}
+
+// LocalAddr is the listening address
+func (c *streamPacketConn) LocalAddr() net.Addr {
+	return c.ln.Addr()
+}
+
+// SetDeadline sets read deadline. Writes go to the client connections directly
+func (c *streamPacketConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets deadline for ReadFrom
+func (c *streamPacketConn) SetReadDeadline(t time.Time) error {
+	c.Lock()
+	c.deadline = t
+	c.Unlock()
+	return nil
+}
+
+// SetWriteDeadline is a no-op
+func (c *streamPacketConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// errTimeout implements net.Error timeout
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "i/o timeout" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
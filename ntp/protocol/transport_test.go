@@ -0,0 +1,166 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransport(t *testing.T) {
+	tr, err := NewTransport("")
+	require.NoError(t, err)
+	require.IsType(t, &UDPTransport{}, tr)
+
+	tr, err = NewTransport(TransportTCP)
+	require.NoError(t, err)
+	require.Equal(t, &StreamTransport{Network: "tcp"}, tr)
+
+	_, err = NewTransport("sctp")
+	require.ErrorIs(t, err, errUnsupportedTransport)
+}
+
+func testTransportRoundTrip(t *testing.T, tr Transport, address string) {
+	server, err := tr.Listen(address)
+	require.NoError(t, err)
+	defer server.Close()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, from, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = server.WriteTo(append([]byte("re:"), buf[:n]...), from)
+		}
+	}()
+
+	conn, err := tr.Dial(server.LocalAddr().String(), time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(time.Second)))
+
+	buf := make([]byte, 1024)
+	for _, msg := range []string{"first", "second"} {
+		_, err = conn.Write([]byte(msg))
+		require.NoError(t, err)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		require.Equal(t, "re:"+msg, string(buf[:n]))
+	}
+}
+
+func TestTransportUDP(t *testing.T) {
+	testTransportRoundTrip(t, &UDPTransport{}, "127.0.0.1:0")
+}
+
+func TestTransportTCP(t *testing.T) {
+	testTransportRoundTrip(t, &StreamTransport{Network: TransportTCP}, "127.0.0.1:0")
+}
+
+func TestTransportUnix(t *testing.T) {
+	testTransportRoundTrip(t, &StreamTransport{Network: TransportUnix}, filepath.Join(t.TempDir(), "ntp.sock"))
+}
+
+func TestStreamPacketConnReadDeadline(t *testing.T) {
+	tr := &StreamTransport{Network: TransportTCP}
+	server, err := tr.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	require.NoError(t, server.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, _, err = server.ReadFrom(make([]byte, 10))
+	var nerr net.Error
+	require.ErrorAs(t, err, &nerr)
+	require.True(t, nerr.Timeout())
+
+	require.NoError(t, server.Close())
+	require.NoError(t, server.SetReadDeadline(time.Time{}))
+	_, _, err = server.ReadFrom(make([]byte, 10))
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestStreamPacketConnMaxConns(t *testing.T) {
+	tr := &StreamTransport{Network: TransportTCP, MaxConns: 1}
+	server, err := tr.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	first, err := tr.Dial(server.LocalAddr().String(), time.Second)
+	require.NoError(t, err)
+	defer first.Close()
+	_, err = first.Write([]byte("first"))
+	require.NoError(t, err)
+	_, _, err = server.ReadFrom(make([]byte, 10))
+	require.NoError(t, err)
+
+	// connections past the limit are closed by the server
+	second, err := tr.Dial(server.LocalAddr().String(), time.Second)
+	require.NoError(t, err)
+	defer second.Close()
+	require.NoError(t, second.SetDeadline(time.Now().Add(time.Second)))
+	_, err = second.Read(make([]byte, 10))
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestStreamPacketConnIdleTimeout(t *testing.T) {
+	tr := &StreamTransport{Network: TransportTCP, IdleTimeout: 50 * time.Millisecond}
+	server, err := tr.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	conn, err := tr.Dial(server.LocalAddr().String(), time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(time.Second)))
+	// partial frame never completes
+	_, err = conn.(*framedConn).Conn.Write([]byte{0, 48})
+	require.NoError(t, err)
+	_, err = conn.Read(make([]byte, 10))
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestStreamPacketConnUnknownClient(t *testing.T) {
+	tr := &StreamTransport{Network: TransportTCP}
+	server, err := tr.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	_, err = server.WriteTo([]byte("x"), &StreamAddr{Remote: server.LocalAddr(), id: 42})
+	require.ErrorIs(t, err, errUnknownClient)
+	_, err = server.WriteTo([]byte("x"), &net.UDPAddr{})
+	require.ErrorIs(t, err, errUnknownClient)
+}
+
+func TestWriteFrameTooBig(t *testing.T) {
+	c := &framedConn{}
+	_, err := c.Write(make([]byte, 0x10000))
+	require.ErrorIs(t, err, errFrameTooBig)
+}
+
+func TestAddrIP(t *testing.T) {
+	ip := net.ParseIP("192.0.2.1")
+	require.Equal(t, ip, AddrIP(&net.UDPAddr{IP: ip}))
+	require.Equal(t, ip, AddrIP(&StreamAddr{Remote: &net.TCPAddr{IP: ip}}))
+	require.Nil(t, AddrIP(&StreamAddr{Remote: &net.UnixAddr{Name: "@", Net: "unix"}}))
+}
@@ -0,0 +1,85 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// WithRecvTTL enables IP_RECVTTL (IPV6_RECVHOPLIMIT for IPv6 sockets) to read TTL of incoming packets with ParseTTL
+func WithRecvTTL() SocketOption {
+	return SocketOption{
+		Name: "IP_RECVTTL",
+		apply: func(connfd int) error {
+			v6, err := isIPv6(connfd)
+			if err != nil {
+				return err
+			}
+			if v6 {
+				if err := syscall.SetsockoptInt(connfd, syscall.IPPROTO_IPV6, syscall.IPV6_RECVHOPLIMIT, 1); err != nil {
+					return err
+				}
+				// dual stack socket receives IPv4 packets as well. Best effort
+				_ = syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_RECVTTL, 1)
+				return nil
+			}
+			return syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_RECVTTL, 1)
+		},
+	}
+}
+
+// ParseTTL extracts TTL or hop limit of the incoming packet from raw control messages.
+// False is returned if there is none
+func ParseTTL(oob []byte) (int, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, m := range msgs {
+		if ttl, ok := parseTTL(m); ok {
+			return ttl, true
+		}
+		if m.Header.Level == syscall.IPPROTO_IPV6 && m.Header.Type == syscall.IPV6_HOPLIMIT && len(m.Data) >= 4 {
+			return int(*(*int32)(unsafe.Pointer(&m.Data[0]))), true
+		}
+	}
+	return 0, false
+}
+
+// WithHopLimit sets IPV6_UNICAST_HOPS (IP_TTL for IPv4 sockets) of outgoing packets
+func WithHopLimit(hops int) SocketOption {
+	return SocketOption{
+		Name: "IPV6_UNICAST_HOPS",
+		apply: func(connfd int) error {
+			v6, err := isIPv6(connfd)
+			if err != nil {
+				return err
+			}
+			if v6 {
+				if err := syscall.SetsockoptInt(connfd, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, hops); err != nil {
+					return err
+				}
+				// dual stack socket sends IPv4 packets as well. Best effort
+				_ = syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_TTL, hops)
+				return nil
+			}
+			return syscall.SetsockoptInt(connfd, syscall.IPPROTO_IP, syscall.IP_TTL, hops)
+		},
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTTL(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, TuneSocket(conn, WithRecvTTL()))
+
+	_, err = conn.WriteToUDP([]byte("hello"), conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	buf := make([]byte, 16)
+	oob := make([]byte, PacketInfoControlSizeBytes)
+	_, oobn, _, _, err := conn.ReadMsgUDP(buf, oob)
+	require.NoError(t, err)
+	ttl, ok := ParseTTL(oob[:oobn])
+	require.True(t, ok)
+	require.Equal(t, 64, ttl)
+
+	_, ok = ParseTTL(nil)
+	require.False(t, ok)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rawprobe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// EtherTypes of the frames
+const (
+	EtherTypeIPv4 = 0x0800
+	EtherTypeIPv6 = 0x86DD
+	EtherTypeVLAN = 0x8100
+)
+
+const (
+	ethHeaderLen  = 14
+	vlanTagLen    = 4
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	udpHeaderLen  = 8
+	protocolUDP   = 17
+	defaultTTL    = 64
+)
+
+var (
+	errShortFrame   = errors.New("frame is too short")
+	errNotUDP       = errors.New("frame is not UDP over IP")
+	errMixedFamily  = errors.New("source and destination IPs are of different families")
+	errBadMAC       = errors.New("source and destination MACs are required")
+	errBadVLAN      = errors.New("vlan must be between 0 and 4094")
+	errBadPriority  = errors.New("priority must be between 0 and 7")
+	errBadUDPLength = errors.New("bad UDP length")
+)
+
+// Frame is an Ethernet frame carrying UDP datagram, optionally tagged with 802.1Q VLAN
+type Frame struct {
+	SrcMAC net.HardwareAddr
+	DstMAC net.HardwareAddr
+	// VLAN ID of 802.1Q tag. Frame is untagged if 0
+	VLAN uint16
+	// Priority is 802.1p priority code point of the VLAN tag
+	Priority uint8
+	SrcIP    net.IP
+	DstIP    net.IP
+	SrcPort  int
+	DstPort  int
+	// TTL or hop limit. Defaults to 64
+	TTL     uint8
+	Payload []byte
+}
+
+// Bytes returns wire format of the frame with IP and UDP checksums filled in
+func (f *Frame) Bytes() ([]byte, error) {
+	if len(f.SrcMAC) != 6 || len(f.DstMAC) != 6 {
+		return nil, errBadMAC
+	}
+	if f.VLAN > 4094 {
+		return nil, errBadVLAN
+	}
+	if f.Priority > 7 {
+		return nil, errBadPriority
+	}
+	src4, dst4 := f.SrcIP.To4(), f.DstIP.To4()
+	if (src4 == nil) != (dst4 == nil) || f.SrcIP.To16() == nil || f.DstIP.To16() == nil {
+		return nil, errMixedFamily
+	}
+	ttl := f.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	b := make([]byte, 0, ethHeaderLen+vlanTagLen+ipv6HeaderLen+udpHeaderLen+len(f.Payload))
+	b = append(b, f.DstMAC...)
+	b = append(b, f.SrcMAC...)
+	if f.VLAN != 0 {
+		b = appendUint16(b, EtherTypeVLAN)
+		b = appendUint16(b, uint16(f.Priority)<<13|f.VLAN)
+	}
+
+	udpLen := udpHeaderLen + len(f.Payload)
+	var pseudo []byte
+	if src4 != nil {
+		b = appendUint16(b, EtherTypeIPv4)
+		ip := make([]byte, ipv4HeaderLen)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(ipv4HeaderLen+udpLen))
+		// don't fragment
+		binary.BigEndian.PutUint16(ip[6:], 0x4000)
+		ip[8] = ttl
+		ip[9] = protocolUDP
+		copy(ip[12:], src4)
+		copy(ip[16:], dst4)
+		binary.BigEndian.PutUint16(ip[10:], checksum(0, ip))
+		b = append(b, ip...)
+		pseudo = append(append([]byte{}, src4...), dst4...)
+		pseudo = append(pseudo, 0, protocolUDP)
+		pseudo = appendUint16(pseudo, uint16(udpLen))
+	} else {
+		b = appendUint16(b, EtherTypeIPv6)
+		ip := make([]byte, ipv6HeaderLen)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(udpLen))
+		ip[6] = protocolUDP
+		ip[7] = ttl
+		copy(ip[8:], f.SrcIP.To16())
+		copy(ip[24:], f.DstIP.To16())
+		b = append(b, ip...)
+		pseudo = append(append([]byte{}, f.SrcIP.To16()...), f.DstIP.To16()...)
+		pseudo = append(pseudo, 0, 0)
+		pseudo = appendUint16(pseudo, uint16(udpLen))
+		pseudo = append(pseudo, 0, 0, 0, protocolUDP)
+	}
+
+	udp := make([]byte, udpHeaderLen, udpLen)
+	binary.BigEndian.PutUint16(udp[0:], uint16(f.SrcPort))
+	binary.BigEndian.PutUint16(udp[2:], uint16(f.DstPort))
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpLen))
+	udp = append(udp, f.Payload...)
+	sum := checksum(sum16(0, pseudo), udp)
+	if sum == 0 {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(udp[6:], sum)
+	return append(b, udp...), nil
+}
+
+// ParseFrame parses Ethernet frame with UDP datagram.
+// VLAN tag is optional as it's often stripped by the NIC on receive
+func ParseFrame(b []byte) (*Frame, error) {
+	if len(b) < ethHeaderLen {
+		return nil, errShortFrame
+	}
+	f := &Frame{
+		DstMAC: net.HardwareAddr(append([]byte{}, b[0:6]...)),
+		SrcMAC: net.HardwareAddr(append([]byte{}, b[6:12]...)),
+	}
+	etherType := binary.BigEndian.Uint16(b[12:])
+	b = b[ethHeaderLen:]
+	if etherType == EtherTypeVLAN {
+		if len(b) < vlanTagLen {
+			return nil, errShortFrame
+		}
+		tci := binary.BigEndian.Uint16(b)
+		f.Priority = uint8(tci >> 13)
+		f.VLAN = tci & 0x0fff
+		etherType = binary.BigEndian.Uint16(b[2:])
+		b = b[vlanTagLen:]
+	}
+
+	switch etherType {
+	case EtherTypeIPv4:
+		if len(b) < ipv4HeaderLen {
+			return nil, errShortFrame
+		}
+		ihl := int(b[0]&0x0f) * 4
+		if b[0]>>4 != 4 || ihl < ipv4HeaderLen || len(b) < ihl {
+			return nil, errNotUDP
+		}
+		if b[9] != protocolUDP {
+			return nil, errNotUDP
+		}
+		f.TTL = b[8]
+		f.SrcIP = net.IP(append([]byte{}, b[12:16]...))
+		f.DstIP = net.IP(append([]byte{}, b[16:20]...))
+		b = b[ihl:]
+	case EtherTypeIPv6:
+		if len(b) < ipv6HeaderLen {
+			return nil, errShortFrame
+		}
+		// extension headers are not supported
+		if b[0]>>4 != 6 || b[6] != protocolUDP {
+			return nil, errNotUDP
+		}
+		f.TTL = b[7]
+		f.SrcIP = net.IP(append([]byte{}, b[8:24]...))
+		f.DstIP = net.IP(append([]byte{}, b[24:40]...))
+		b = b[ipv6HeaderLen:]
+	default:
+		return nil, fmt.Errorf("%w: ethertype 0x%04x", errNotUDP, etherType)
+	}
+
+	if len(b) < udpHeaderLen {
+		return nil, errShortFrame
+	}
+	f.SrcPort = int(binary.BigEndian.Uint16(b[0:]))
+	f.DstPort = int(binary.BigEndian.Uint16(b[2:]))
+	udpLen := int(binary.BigEndian.Uint16(b[4:]))
+	if udpLen < udpHeaderLen || udpLen > len(b) {
+		return nil, errBadUDPLength
+	}
+	f.Payload = append([]byte{}, b[udpHeaderLen:udpLen]...)
+	return f, nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// sum16 adds b to the one's complement sum
+func sum16(sum uint32, b []byte) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	return sum
+}
+
+// checksum returns internet checksum (RFC 1071) of b continuing the partial sum
+func checksum(sum uint32, b []byte) uint16 {
+	sum = sum16(sum, b)
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
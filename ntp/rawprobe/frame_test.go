@@ -0,0 +1,106 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rawprobe
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testSrcMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	testDstMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}
+)
+
+func TestFrameIPv4(t *testing.T) {
+	f := &Frame{
+		SrcMAC:  testSrcMAC,
+		DstMAC:  testDstMAC,
+		SrcIP:   net.ParseIP("192.0.2.1"),
+		DstIP:   net.ParseIP("192.0.2.2"),
+		SrcPort: 1000,
+		DstPort: 123,
+		Payload: []byte("hello"),
+	}
+	b, err := f.Bytes()
+	require.NoError(t, err)
+	require.Len(t, b, ethHeaderLen+ipv4HeaderLen+udpHeaderLen+5)
+	// checksum over header with checksum filled in is 0
+	require.Equal(t, uint16(0), checksum(0, b[ethHeaderLen:ethHeaderLen+ipv4HeaderLen]))
+	pseudo := []byte{192, 0, 2, 1, 192, 0, 2, 2, 0, protocolUDP, 0, 13}
+	require.Equal(t, uint16(0), checksum(sum16(0, pseudo), b[ethHeaderLen+ipv4HeaderLen:]))
+
+	parsed, err := ParseFrame(b)
+	require.NoError(t, err)
+	f.TTL = defaultTTL
+	f.SrcIP = f.SrcIP.To4()
+	f.DstIP = f.DstIP.To4()
+	require.Equal(t, f, parsed)
+}
+
+func TestFrameIPv6VLAN(t *testing.T) {
+	f := &Frame{
+		SrcMAC:   testSrcMAC,
+		DstMAC:   testDstMAC,
+		VLAN:     100,
+		Priority: 5,
+		SrcIP:    net.ParseIP("2001:db8::1"),
+		DstIP:    net.ParseIP("2001:db8::2"),
+		SrcPort:  1000,
+		DstPort:  123,
+		TTL:      3,
+		Payload:  make([]byte, 48),
+	}
+	b, err := f.Bytes()
+	require.NoError(t, err)
+	require.Len(t, b, ethHeaderLen+vlanTagLen+ipv6HeaderLen+udpHeaderLen+48)
+	require.Equal(t, []byte{0x81, 0x00, 0xa0, 0x64, 0x86, 0xdd}, b[12:18])
+
+	parsed, err := ParseFrame(b)
+	require.NoError(t, err)
+	require.Equal(t, f, parsed)
+}
+
+func TestFrameErrors(t *testing.T) {
+	f := &Frame{SrcMAC: testSrcMAC, DstMAC: testDstMAC, SrcIP: net.ParseIP("192.0.2.1"), DstIP: net.ParseIP("2001:db8::2")}
+	_, err := f.Bytes()
+	require.ErrorIs(t, err, errMixedFamily)
+	f.DstIP = net.ParseIP("192.0.2.2")
+	f.VLAN = 4095
+	_, err = f.Bytes()
+	require.ErrorIs(t, err, errBadVLAN)
+	f.VLAN = 0
+	f.DstMAC = nil
+	_, err = f.Bytes()
+	require.ErrorIs(t, err, errBadMAC)
+
+	_, err = ParseFrame([]byte{1, 2, 3})
+	require.ErrorIs(t, err, errShortFrame)
+	arp := make([]byte, 60)
+	arp[12], arp[13] = 0x08, 0x06
+	_, err = ParseFrame(arp)
+	require.ErrorIs(t, err, errNotUDP)
+
+	f.DstMAC = testDstMAC
+	f.Payload = []byte("hello")
+	b, err := f.Bytes()
+	require.NoError(t, err)
+	_, err = ParseFrame(b[:len(b)-1])
+	require.ErrorIs(t, err, errBadUDPLength)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rawprobe
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/timestamp"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// frameSize fits full frames of the standard MTU
+const frameSize = 2048
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// Query sends a single NTP request to the server over AF_PACKET socket.
+// Requires CAP_NET_RAW
+func Query(c *Config) (*Result, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	iface, err := net.InterfaceByName(c.Iface)
+	if err != nil {
+		return nil, err
+	}
+	srcMAC, srcIP, err := c.resolve(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	// bound socket keeps the kernel from answering the response with ICMP port unreachable
+	var srcPort int
+	if conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: srcIP}); err == nil {
+		defer conn.Close()
+		srcPort = conn.LocalAddr().(*net.UDPAddr).Port
+	} else {
+		log.Debugf("[rawprobe] %s is not local, using random source port: %v", srcIP, err)
+		srcPort = 32768 + rand.Intn(28232)
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open packet socket: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: iface.Index}); err != nil {
+		return nil, fmt.Errorf("failed to bind packet socket to %s: %w", c.Iface, err)
+	}
+
+	ts := HWTIMESTAMP
+	if err := timestamp.EnableHWTimestampsSocket(fd, c.Iface); err != nil {
+		log.Debugf("[rawprobe] failed to enable hardware timestamps on %s, falling back to software timestamps: %v", c.Iface, err)
+		if err := timestamp.EnableSWTimestampsSocket(fd); err != nil {
+			return nil, fmt.Errorf("failed to enable timestamps: %w", err)
+		}
+		ts = SWTIMESTAMP
+	}
+
+	request := &ntp.Packet{Settings: 0x23}
+	if err := request.SetRandomTransmitTime(); err != nil {
+		return nil, err
+	}
+	payload, err := request.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	f := &Frame{
+		SrcMAC:   srcMAC,
+		DstMAC:   c.DstMAC,
+		VLAN:     c.VLAN,
+		Priority: c.Priority,
+		SrcIP:    srcIP,
+		DstIP:    c.Server,
+		SrcPort:  srcPort,
+		DstPort:  c.port(),
+		Payload:  payload,
+	}
+	b, err := f.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.Timeout)
+	etherType := uint16(EtherTypeIPv4)
+	if srcIP.To4() == nil {
+		etherType = EtherTypeIPv6
+	}
+	if c.VLAN != 0 {
+		etherType = EtherTypeVLAN
+	}
+	to := &unix.SockaddrLinklayer{Protocol: htons(etherType), Ifindex: iface.Index, Halen: 6}
+	copy(to.Addr[:], c.DstMAC)
+	if err := unix.Sendto(fd, b, 0, to); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	t1, _, err := timestamp.ReadTXtimestamp(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, frameSize)
+	oob := make([]byte, timestamp.ControlSizeBytes)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, errTimeout
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		ready, err := unix.Poll(fds, int(remaining/time.Millisecond)+1)
+		if err != nil && err != unix.EINTR {
+			return nil, err
+		}
+		if ready == 0 {
+			continue
+		}
+		n, _, t4, tsErr := timestamp.ReadPacketWithRXTimestampBuf(fd, buf, oob)
+		if n == 0 && tsErr != nil {
+			return nil, tsErr
+		}
+		response, err := ParseFrame(buf[:n])
+		if err != nil || !c.match(response, srcIP, srcPort) {
+			continue
+		}
+		packet, err := ntp.BytesToPacket(response.Payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := ntp.MatchOrigin(request, packet); err != nil {
+			log.Debugf("[rawprobe] ignoring response: %v", err)
+			continue
+		}
+		if tsErr != nil {
+			return nil, tsErr
+		}
+		return newResult(packet, t1, t4, ts), nil
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rawprobe
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+// serveOnce answers single NTP request
+func serveOnce(conn *net.UDPConn) {
+	buf := make([]byte, 128)
+	n, addr, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		return
+	}
+	request, err := ntp.BytesToPacket(buf[:n])
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	sec, frac := ntp.Time(now)
+	response := &ntp.Packet{
+		Settings:     0x24,
+		Stratum:      1,
+		OrigTimeSec:  request.TxTimeSec,
+		OrigTimeFrac: request.TxTimeFrac,
+		RxTimeSec:    sec,
+		RxTimeFrac:   frac,
+		TxTimeSec:    sec,
+		TxTimeFrac:   frac,
+	}
+	b, err := response.Bytes()
+	if err != nil {
+		return
+	}
+	_, _ = conn.WriteToUDP(b, addr)
+}
+
+func TestQueryLoopback(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("packet sockets require root")
+	}
+	// kernel drops IPv4 frames from and to 127.0.0.1 injected on lo as martians, IPv6 loopback is fine
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("::1")})
+	require.NoError(t, err)
+	defer conn.Close()
+	go serveOnce(conn)
+
+	zeroMAC := net.HardwareAddr{0, 0, 0, 0, 0, 0}
+	r, err := Query(&Config{
+		Iface:   "lo",
+		SrcMAC:  zeroMAC,
+		DstMAC:  zeroMAC,
+		Server:  net.ParseIP("::1"),
+		Port:    conn.LocalAddr().(*net.UDPAddr).Port,
+		Timeout: time.Second,
+	})
+	require.NoError(t, err)
+	require.Equal(t, SWTIMESTAMP, r.Timestamping)
+	require.Equal(t, uint8(1), r.Response.Stratum)
+	require.True(t, r.T4.After(r.T1))
+	require.InDelta(t, 0, r.Offset, float64(100*time.Millisecond))
+}
+
+func TestQueryTimeout(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("packet sockets require root")
+	}
+	zeroMAC := net.HardwareAddr{0, 0, 0, 0, 0, 0}
+	_, err := Query(&Config{Iface: "lo", SrcMAC: zeroMAC, DstMAC: zeroMAC, Server: net.ParseIP("::1"), Port: 9, Timeout: 50 * time.Millisecond})
+	require.ErrorIs(t, err, errTimeout)
+}
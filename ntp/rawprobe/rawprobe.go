@@ -0,0 +1,160 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package rawprobe queries NTP servers with frames crafted from Ethernet up and sent over AF_PACKET sockets.
+It allows probing through specific VLANs of trunk ports and next hop MACs regardless of the host routing,
+measuring L2 path differences like a dedicated test device does, but from commodity servers.
+Frames are timestamped by the NIC when it supports hardware timestamps.
+*/
+package rawprobe
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// Timestamping of the probes
+const (
+	HWTIMESTAMP = "hardware"
+	SWTIMESTAMP = "software"
+)
+
+var (
+	errNoIface  = errors.New("interface is required")
+	errNoServer = errors.New("server IP is required")
+	errNoDstMAC = errors.New("destination MAC is required")
+	errNoSrcIP  = errors.New("no usable source IP on the interface")
+	errTimeout  = errors.New("timed out waiting for response")
+)
+
+// Config of the probe
+type Config struct {
+	Iface string
+	// VLAN ID to tag the request with. Untagged if 0
+	VLAN uint16
+	// Priority is 802.1p priority of the tagged request
+	Priority uint8
+	// DstMAC is the next hop: the server itself or the router towards it
+	DstMAC net.HardwareAddr
+	// SrcMAC defaults to the MAC of the interface
+	SrcMAC net.HardwareAddr
+	// SrcIP defaults to the first global address of the interface of the server's family
+	SrcIP  net.IP
+	Server net.IP
+	// Port of the server. Defaults to 123
+	Port    int
+	Timeout time.Duration
+}
+
+// Result of the probe
+type Result struct {
+	Response     *ntp.Packet
+	T1           time.Time
+	T4           time.Time
+	Offset       time.Duration
+	Delay        time.Duration
+	Timestamping string
+}
+
+// newResult calculates offset and delay of the exchange
+func newResult(response *ntp.Packet, t1, t4 time.Time, timestamping string) *Result {
+	t2 := ntp.Unix(response.RxTimeSec, response.RxTimeFrac)
+	t3 := ntp.Unix(response.TxTimeSec, response.TxTimeFrac)
+	return &Result{
+		Response:     response,
+		T1:           t1,
+		T4:           t4,
+		Offset:       (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:        t4.Sub(t1) - t3.Sub(t2),
+		Timestamping: timestamping,
+	}
+}
+
+// Validate the config
+func (c *Config) Validate() error {
+	if c.Iface == "" {
+		return errNoIface
+	}
+	if c.Server == nil {
+		return errNoServer
+	}
+	if len(c.DstMAC) != 6 {
+		return errNoDstMAC
+	}
+	if c.VLAN > 4094 {
+		return errBadVLAN
+	}
+	if c.Priority > 7 {
+		return errBadPriority
+	}
+	return nil
+}
+
+// port returns server port
+func (c *Config) port() int {
+	if c.Port == 0 {
+		return 123
+	}
+	return c.Port
+}
+
+// resolve fills in source MAC and IP from the interface
+func (c *Config) resolve(iface *net.Interface) (net.HardwareAddr, net.IP, error) {
+	srcMAC := c.SrcMAC
+	if srcMAC == nil {
+		srcMAC = iface.HardwareAddr
+	}
+	if c.SrcIP != nil {
+		return srcMAC, c.SrcIP, nil
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, nil, err
+	}
+	srcIP := pickSrcIP(addrs, c.Server.To4() != nil)
+	if srcIP == nil {
+		return nil, nil, fmt.Errorf("%w %s", errNoSrcIP, iface.Name)
+	}
+	return srcMAC, srcIP, nil
+}
+
+// pickSrcIP returns first global unicast address of the family
+func pickSrcIP(addrs []net.Addr, v4 bool) net.IP {
+	for _, a := range addrs {
+		n, ok := a.(*net.IPNet)
+		if !ok || (n.IP.To4() != nil) != v4 {
+			continue
+		}
+		if n.IP.IsGlobalUnicast() || n.IP.IsLoopback() {
+			return n.IP
+		}
+	}
+	return nil
+}
+
+// match returns true if frame is the response to the request from the src
+func (c *Config) match(f *Frame, srcIP net.IP, srcPort int) bool {
+	// tag may be stripped by the NIC
+	if f.VLAN != 0 && f.VLAN != c.VLAN {
+		return false
+	}
+	return f.SrcIP.Equal(c.Server) && f.SrcPort == c.port() && f.DstIP.Equal(srcIP) && f.DstPort == srcPort
+}
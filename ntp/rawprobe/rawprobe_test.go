@@ -0,0 +1,81 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rawprobe
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	c := &Config{}
+	require.ErrorIs(t, c.Validate(), errNoIface)
+	c.Iface = "eth0"
+	require.ErrorIs(t, c.Validate(), errNoServer)
+	c.Server = net.ParseIP("192.0.2.2")
+	require.ErrorIs(t, c.Validate(), errNoDstMAC)
+	c.DstMAC = testDstMAC
+	c.Priority = 8
+	require.ErrorIs(t, c.Validate(), errBadPriority)
+	c.Priority = 0
+	require.NoError(t, c.Validate())
+	require.Equal(t, 123, c.port())
+}
+
+func TestPickSrcIP(t *testing.T) {
+	v4 := &net.IPNet{IP: net.ParseIP("192.0.2.1"), Mask: net.CIDRMask(24, 32)}
+	v6 := &net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(64, 128)}
+	ll := &net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)}
+	addrs := []net.Addr{ll, v4, v6}
+	require.Equal(t, "192.0.2.1", pickSrcIP(addrs, true).String())
+	require.Equal(t, "2001:db8::1", pickSrcIP(addrs, false).String())
+	require.Nil(t, pickSrcIP([]net.Addr{ll}, false))
+}
+
+func TestMatch(t *testing.T) {
+	c := &Config{Server: net.ParseIP("192.0.2.2"), VLAN: 100}
+	srcIP := net.ParseIP("192.0.2.1")
+	f := &Frame{SrcIP: c.Server, DstIP: srcIP, SrcPort: 123, DstPort: 1000}
+	// tag stripped by the NIC
+	require.True(t, c.match(f, srcIP, 1000))
+	f.VLAN = 100
+	require.True(t, c.match(f, srcIP, 1000))
+	f.VLAN = 200
+	require.False(t, c.match(f, srcIP, 1000))
+	f.VLAN = 0
+	require.False(t, c.match(f, srcIP, 1001))
+	// our own request seen on the way out
+	require.False(t, c.match(&Frame{SrcIP: srcIP, DstIP: c.Server, SrcPort: 1000, DstPort: 123}, srcIP, 1000))
+}
+
+func TestNewResult(t *testing.T) {
+	t1 := time.Unix(1600000000, 0)
+	// server is 10ms ahead, 2ms each way, 1ms processing
+	t2 := t1.Add(12 * time.Millisecond)
+	t3 := t2.Add(time.Millisecond)
+	t4 := t1.Add(5 * time.Millisecond)
+	response := &ntp.Packet{}
+	response.RxTimeSec, response.RxTimeFrac = ntp.Time(t2)
+	response.TxTimeSec, response.TxTimeFrac = ntp.Time(t3)
+	r := newResult(response, t1, t4, SWTIMESTAMP)
+	require.InDelta(t, float64(10*time.Millisecond), float64(r.Offset), float64(time.Microsecond))
+	require.InDelta(t, float64(4*time.Millisecond), float64(r.Delay), float64(time.Microsecond))
+}
@@ -0,0 +1,303 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package management implements HTTP/JSON management API of the responder.
+It allows automation to look at runtime stats, reload ACL, override stratum
+and drain/undrain the server without restarts.
+
+	GET  /status          - runtime stats and state
+	POST /drain           - withdraw announcement
+	POST /undrain         - allow announcement
+	POST /acl/reload      - re-read ACL file
+	POST /stratum?value=N - override stratum. value=0 resets the override
+	GET  /audit           - stateless audit results, if audit is enabled
+	GET  /readlatency     - kernel to userspace packet read latency, if enabled
+	POST /readlatency     - reset read latency stats
+	GET  /selftest        - self-test loopback probe results, if enabled
+	GET  /traces          - latest sampled request traces, if enabled
+	GET  /regions         - request counters per client region, if enabled
+*/
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/responder/server"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errBadStratum = errors.New("stratum must be between 0 and 15")
+	errNoAudit    = errors.New("audit is not enabled")
+	errNoLatency  = errors.New("read latency tracking is not enabled")
+	errNoSelfTest = errors.New("self-test is not enabled")
+	errNoTracer   = errors.New("tracing is not enabled")
+	errNoRegions  = errors.New("region stats are not enabled")
+	errNoCanary   = errors.New("canary is not enabled")
+)
+
+// Responder is an interface of the server which can be managed
+type Responder interface {
+	// Drain withdraws announcement of the IPs
+	Drain()
+	// Undrain allows announcement of the IPs
+	Undrain()
+	// Drained returns true if server is drained
+	Drained() bool
+	// SetStratum overrides stratum. 0 resets the override
+	SetStratum(int)
+	// CurrentStratum returns stratum server is responding with
+	CurrentStratum() int
+	// ReloadACL re-reads ACL
+	ReloadACL() error
+}
+
+// Stats is an interface of stats which can be exposed via management API
+type Stats interface {
+	// Values returns current values of all counters
+	Values() map[string]int64
+}
+
+// Auditor is an interface of the stateless audit which can be exposed via management API
+type Auditor interface {
+	// Report returns current audit results
+	Report() *server.AuditReport
+}
+
+// LatencyTracker is an interface of the packet read latency tracking which can be exposed via management API
+type LatencyTracker interface {
+	// Stats returns aggregate read latency stats
+	Stats() *ntp.ReadLatencyStats
+	// Reset forgets all observations
+	Reset()
+}
+
+// SelfTester is an interface of the self-test loopback probe which can be exposed via management API
+type SelfTester interface {
+	// Report returns current self-test results
+	Report() *server.SelfTestReport
+}
+
+// Tracer is an interface of the request tracing which can be exposed via management API
+type Tracer interface {
+	// Report returns latest traces
+	Report() *server.TraceReport
+}
+
+// RegionStats is an interface of the per region request counters which can be exposed via management API
+type RegionStats interface {
+	// Report returns counters of every region
+	Report() map[string]server.RegionCount
+}
+
+// CanaryReporter is an interface of the canary which can be exposed via management API
+type CanaryReporter interface {
+	// Report returns current canary state
+	Report() *server.CanaryReport
+}
+
+// Status is a runtime state of the server
+type Status struct {
+	Drained bool             `json:"drained"`
+	Stratum int              `json:"stratum"`
+	Stats   map[string]int64 `json:"stats"`
+}
+
+// Result is a response to the management operation
+type Result struct {
+	Result  bool   `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+// Server is a management API server
+type Server struct {
+	Responder Responder
+	Stats     Stats
+	Audit     Auditor
+	Latency   LatencyTracker
+	SelfTest  SelfTester
+	Tracer    Tracer
+	Regions   RegionStats
+	Canary    CanaryReporter
+}
+
+// Handler returns http handler serving management API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/audit", s.handleAudit)
+	mux.HandleFunc("/readlatency", s.handleReadLatency)
+	mux.HandleFunc("/selftest", s.handleSelfTest)
+	mux.HandleFunc("/traces", s.handleTraces)
+	mux.HandleFunc("/regions", s.handleRegions)
+	mux.HandleFunc("/canary", s.handleCanary)
+	mux.HandleFunc("/drain", s.post(func(r *http.Request) error {
+		s.Responder.Drain()
+		return nil
+	}))
+	mux.HandleFunc("/undrain", s.post(func(r *http.Request) error {
+		s.Responder.Undrain()
+		return nil
+	}))
+	mux.HandleFunc("/acl/reload", s.post(func(r *http.Request) error {
+		return s.Responder.ReloadACL()
+	}))
+	mux.HandleFunc("/stratum", s.post(func(r *http.Request) error {
+		stratum, err := strconv.Atoi(r.URL.Query().Get("value"))
+		if err != nil {
+			return err
+		}
+		if stratum < 0 || stratum > 15 {
+			return errBadStratum
+		}
+		s.Responder.SetStratum(stratum)
+		return nil
+	}))
+	return mux
+}
+
+// Start the management server on the addr
+func (s *Server) Start(addr string) error {
+	log.Infof("Starting management server on %s", addr)
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	status := &Status{
+		Drained: s.Responder.Drained(),
+		Stratum: s.Responder.CurrentStratum(),
+	}
+	if s.Stats != nil {
+		status.Stats = s.Stats.Values()
+	}
+	reply(w, http.StatusOK, status)
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Audit == nil {
+		reply(w, http.StatusNotFound, &Result{Result: false, Message: errNoAudit.Error()})
+		return
+	}
+	reply(w, http.StatusOK, s.Audit.Report())
+}
+
+func (s *Server) handleReadLatency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Latency == nil {
+		reply(w, http.StatusNotFound, &Result{Result: false, Message: errNoLatency.Error()})
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.Latency.Reset()
+		reply(w, http.StatusOK, &Result{Result: true})
+		return
+	}
+	reply(w, http.StatusOK, s.Latency.Stats())
+}
+
+func (s *Server) handleSelfTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if s.SelfTest == nil {
+		reply(w, http.StatusNotFound, &Result{Result: false, Message: errNoSelfTest.Error()})
+		return
+	}
+	reply(w, http.StatusOK, s.SelfTest.Report())
+}
+
+func (s *Server) handleTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Tracer == nil {
+		reply(w, http.StatusNotFound, &Result{Result: false, Message: errNoTracer.Error()})
+		return
+	}
+	reply(w, http.StatusOK, s.Tracer.Report())
+}
+
+func (s *Server) handleRegions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Regions == nil {
+		reply(w, http.StatusNotFound, &Result{Result: false, Message: errNoRegions.Error()})
+		return
+	}
+	reply(w, http.StatusOK, s.Regions.Report())
+}
+
+func (s *Server) handleCanary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Canary == nil {
+		reply(w, http.StatusNotFound, &Result{Result: false, Message: errNoCanary.Error()})
+		return
+	}
+	reply(w, http.StatusOK, s.Canary.Report())
+}
+
+// post wraps management operation into http handler
+func (s *Server) post(op func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if err := op(r); err != nil {
+			log.Errorf("[management] %s failed: %v", r.URL.Path, err)
+			reply(w, http.StatusBadRequest, &Result{Result: false, Message: err.Error()})
+			return
+		}
+		log.Infof("[management] %s succeeded", r.URL.Path)
+		reply(w, http.StatusOK, &Result{Result: true})
+	}
+}
+
+func reply(w http.ResponseWriter, code int, v interface{}) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err = w.Write(js); err != nil {
+		log.Errorf("Failed to reply: %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/responder/server"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResponder struct {
+	drained bool
+	stratum int
+	aclErr  error
+}
+
+func (f *fakeResponder) Drain()              { f.drained = true }
+func (f *fakeResponder) Undrain()            { f.drained = false }
+func (f *fakeResponder) Drained() bool       { return f.drained }
+func (f *fakeResponder) SetStratum(s int)    { f.stratum = s }
+func (f *fakeResponder) CurrentStratum() int { return f.stratum }
+func (f *fakeResponder) ReloadACL() error    { return f.aclErr }
+
+type fakeStats struct{}
+
+func (f *fakeStats) Values() map[string]int64 { return map[string]int64{"requests": 42} }
+
+type fakeAudit struct{}
+
+func (f *fakeAudit) Report() *server.AuditReport {
+	return &server.AuditReport{Responses: 10, Sampled: 1, State: map[string]int{"ratelimit": 3}}
+}
+
+func TestStatus(t *testing.T) {
+	s := &Server{Responder: &fakeResponder{stratum: 1}, Stats: &fakeStats{}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/status")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	status := &Status{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(status))
+	require.Equal(t, &Status{Stratum: 1, Stats: map[string]int64{"requests": 42}}, status)
+}
+
+func TestDrainUndrain(t *testing.T) {
+	r := &fakeResponder{}
+	s := &Server{Responder: r}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/drain")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	require.False(t, r.drained)
+
+	resp, err = http.Post(ts.URL+"/drain", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, r.drained)
+
+	resp, err = http.Post(ts.URL+"/undrain", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.False(t, r.drained)
+}
+
+func TestStratum(t *testing.T) {
+	r := &fakeResponder{stratum: 1}
+	s := &Server{Responder: r}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/stratum?value=3", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 3, r.stratum)
+
+	for _, v := range []string{"16", "-1", "foo"} {
+		resp, err = http.Post(ts.URL+"/stratum?value="+v, "", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.Equal(t, 3, r.stratum)
+	}
+}
+
+func TestACLReloadFail(t *testing.T) {
+	r := &fakeResponder{aclErr: errors.New("boom")}
+	s := &Server{Responder: r}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/acl/reload", "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	result := &Result{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(result))
+	require.Equal(t, &Result{Result: false, Message: "boom"}, result)
+}
+
+func TestAudit(t *testing.T) {
+	s := &Server{Responder: &fakeResponder{}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/audit")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	s.Audit = &fakeAudit{}
+	resp, err = http.Get(ts.URL + "/audit")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	report := &server.AuditReport{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(report))
+	require.Equal(t, &server.AuditReport{Responses: 10, Sampled: 1, State: map[string]int{"ratelimit": 3}}, report)
+}
+
+type fakeSelfTest struct{}
+
+func (f *fakeSelfTest) Report() *server.SelfTestReport {
+	return &server.SelfTestReport{Probes: 5, Failures: 3, Consecutive: 3, Alarm: true, Stratum: 1, Error: "stratum 1, expected 2"}
+}
+
+func TestSelfTest(t *testing.T) {
+	s := &Server{Responder: &fakeResponder{}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/selftest")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	s.SelfTest = &fakeSelfTest{}
+	resp, err = http.Get(ts.URL + "/selftest")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	report := &server.SelfTestReport{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(report))
+	require.Equal(t, (&fakeSelfTest{}).Report(), report)
+}
+
+type fakeTracer struct{}
+
+func (f *fakeTracer) Report() *server.TraceReport {
+	return &server.TraceReport{Requests: 10, Traced: 1, Max: map[string]time.Duration{"send": time.Millisecond}, Traces: []*server.Trace{}}
+}
+
+func TestTraces(t *testing.T) {
+	s := &Server{Responder: &fakeResponder{}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/traces")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	s.Tracer = &fakeTracer{}
+	resp, err = http.Get(ts.URL + "/traces")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	report := &server.TraceReport{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(report))
+	require.Equal(t, (&fakeTracer{}).Report(), report)
+}
+
+type fakeRegions struct{}
+
+func (f *fakeRegions) Report() map[string]server.RegionCount {
+	return map[string]server.RegionCount{"eu": {Requests: 10, Responses: 9, RateLimited: 1}}
+}
+
+func TestRegions(t *testing.T) {
+	s := &Server{Responder: &fakeResponder{}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/regions")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	s.Regions = &fakeRegions{}
+	resp, err = http.Get(ts.URL + "/regions")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	report := map[string]server.RegionCount{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	require.Equal(t, (&fakeRegions{}).Report(), report)
+}
+
+type fakeCanary struct{}
+
+func (f *fakeCanary) Report() *server.CanaryReport {
+	return &server.CanaryReport{Offset: time.Millisecond, Networks: []string{"fd00::/64"}, Served: 42}
+}
+
+func TestCanary(t *testing.T) {
+	s := &Server{Responder: &fakeResponder{}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/canary")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	s.Canary = &fakeCanary{}
+	resp, err = http.Get(ts.URL + "/canary")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	report := &server.CanaryReport{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(report))
+	require.Equal(t, (&fakeCanary{}).Report(), report)
+}
+
+func TestReadLatency(t *testing.T) {
+	s := &Server{Responder: &fakeResponder{}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/readlatency")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	l := ntp.NewReadLatency()
+	kernel := time.Unix(1600000000, 0)
+	l.Observe(kernel, kernel.Add(3*time.Microsecond))
+	s.Latency = l
+	resp, err = http.Get(ts.URL + "/readlatency")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	stats := &ntp.ReadLatencyStats{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(stats))
+	require.Equal(t, int64(1), stats.Count)
+	require.Equal(t, 3*time.Microsecond, stats.Max)
+
+	resp, err = http.Post(ts.URL+"/readlatency", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int64(0), l.Stats().Count)
+}
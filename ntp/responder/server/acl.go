@@ -0,0 +1,126 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ACL is a list of client networks allowed to query the server.
+// Empty ACL allows everyone
+type ACL struct {
+	// Path is a file with one IP or CIDR per line. Lines starting with # are ignored
+	Path string
+
+	sync.RWMutex
+	nets []*net.IPNet
+}
+
+// parseNet parses single IP or CIDR
+func parseNet(text string) (*net.IPNet, error) {
+	if !strings.Contains(text, "/") {
+		ip := net.ParseIP(text)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip address %q", text)
+		}
+		bits := net.IPv6len * 8
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+			bits = net.IPv4len * 8
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipnet, err := net.ParseCIDR(text)
+	return ipnet, err
+}
+
+// parseACL reads networks from the reader
+func parseACL(r io.Reader) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		ipnet, err := parseNet(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, scanner.Err()
+}
+
+// Load (re)reads the ACL from Path. On error the previous list is kept
+func (a *ACL) Load() error {
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	nets, err := parseACL(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse acl %s: %w", a.Path, err)
+	}
+	a.Set(nets)
+	return nil
+}
+
+// Set replaces the list of allowed networks
+func (a *ACL) Set(nets []*net.IPNet) {
+	a.Lock()
+	a.nets = nets
+	a.Unlock()
+}
+
+// Len returns number of networks in the ACL
+func (a *ACL) Len() int {
+	if a == nil {
+		return 0
+	}
+	a.RLock()
+	defer a.RUnlock()
+	return len(a.nets)
+}
+
+// Allowed checks if ip is allowed to query the server
+func (a *ACL) Allowed(ip net.IP) bool {
+	if a == nil {
+		return true
+	}
+	a.RLock()
+	defer a.RUnlock()
+	if len(a.nets) == 0 {
+		return true
+	}
+	for _, n := range a.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseACL(t *testing.T) {
+	acl := `
+# comment
+10.0.0.0/8
+
+192.168.0.1
+fd00::/64
+`
+	nets, err := parseACL(strings.NewReader(acl))
+	require.NoError(t, err)
+	require.Len(t, nets, 3)
+	require.Equal(t, "10.0.0.0/8", nets[0].String())
+	require.Equal(t, "192.168.0.1/32", nets[1].String())
+	require.Equal(t, "fd00::/64", nets[2].String())
+}
+
+func TestParseACLInvalid(t *testing.T) {
+	_, err := parseACL(strings.NewReader("10.0.0.0/8\nfoo\n"))
+	require.EqualError(t, err, "line 2: invalid ip address \"foo\"")
+}
+
+func TestACLAllowed(t *testing.T) {
+	var nilACL *ACL
+	require.True(t, nilACL.Allowed(net.ParseIP("1.2.3.4")))
+
+	a := &ACL{}
+	require.True(t, a.Allowed(net.ParseIP("1.2.3.4")))
+
+	_, n, _ := net.ParseCIDR("10.0.0.0/8")
+	a.Set([]*net.IPNet{n})
+	require.True(t, a.Allowed(net.ParseIP("10.1.2.3")))
+	require.False(t, a.Allowed(net.ParseIP("1.2.3.4")))
+	require.False(t, a.Allowed(net.ParseIP("fd00::1")))
+}
+
+func TestACLLoad(t *testing.T) {
+	f, err := ioutil.TempFile("", "acl")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("10.0.0.0/8\n")
+	require.NoError(t, err)
+	f.Close()
+
+	a := &ACL{Path: f.Name()}
+	require.NoError(t, a.Load())
+	require.Equal(t, 1, a.Len())
+
+	// broken file keeps the previous list
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("foo\n"), 0644))
+	require.Error(t, a.Load())
+	require.Equal(t, 1, a.Len())
+}
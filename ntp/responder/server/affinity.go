@@ -0,0 +1,81 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errBadCPUList = errors.New("bad cpu list")
+
+// AffinityConfig pins OS threads running listeners and workers to specific CPUs.
+// Pinning listeners to CPUs handling IRQs of the NIC RX queues keeps packets on the same core
+// from the interrupt to the read, reducing latency jitter at high packet rates
+type AffinityConfig struct {
+	// ListenerCPUs are assigned to listeners round robin. Listeners are not pinned if empty
+	ListenerCPUs []int
+	// WorkerCPUs are assigned to workers round robin. Workers are not pinned if empty
+	WorkerCPUs []int
+	// IncomingCPU sets SO_INCOMING_CPU on listener sockets to the CPU of the listener,
+	// so the kernel prefers the socket for packets from the RX queue processed on that CPU
+	IncomingCPU bool
+}
+
+// listenerCPU returns CPU of the i-th listener, -1 if listeners are not pinned
+func (c *AffinityConfig) listenerCPU(i int) int {
+	return pick(c.ListenerCPUs, i)
+}
+
+// workerCPU returns CPU of the i-th worker, -1 if workers are not pinned
+func (c *AffinityConfig) workerCPU(i int) int {
+	return pick(c.WorkerCPUs, i)
+}
+
+func pick(cpus []int, i int) int {
+	if len(cpus) == 0 {
+		return -1
+	}
+	return cpus[i%len(cpus)]
+}
+
+// ParseCPUList parses list of CPUs in the kernel cpulist format, like 0-3,8,10-11
+func ParseCPUList(s string) ([]int, error) {
+	cpus := []int{}
+	if s == "" {
+		return cpus, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("%w: %q", errBadCPUList, part)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("%w: %q", errBadCPUList, part)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
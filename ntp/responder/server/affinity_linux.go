@@ -0,0 +1,47 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// pinThread locks calling goroutine to its OS thread and binds the thread to the cpu
+func pinThread(cpu int) error {
+	runtime.LockOSThread()
+	set := unix.CPUSet{}
+	set.Set(cpu)
+	return unix.SchedSetaffinity(0, &set)
+}
+
+// setIncomingCPU sets SO_INCOMING_CPU on the socket
+func setIncomingCPU(conn *net.UDPConn, cpu int) error {
+	sc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := sc.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_INCOMING_CPU, cpu)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,59 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestPinThread(t *testing.T) {
+	var pinErr, getErr error
+	set := unix.CPUSet{}
+	done := make(chan struct{})
+	go func() {
+		// thread exits together with the locked goroutine
+		defer close(done)
+		if pinErr = pinThread(0); pinErr == nil {
+			getErr = unix.SchedGetaffinity(0, &set)
+		}
+	}()
+	<-done
+	require.NoError(t, pinErr)
+	require.NoError(t, getErr)
+	require.Equal(t, 1, set.Count())
+	require.True(t, set.IsSet(0))
+}
+
+func TestSetIncomingCPU(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, setIncomingCPU(conn, 0))
+
+	sc, err := conn.SyscallConn()
+	require.NoError(t, err)
+	var cpu int
+	require.NoError(t, sc.Control(func(fd uintptr) {
+		cpu, err = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_INCOMING_CPU)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 0, cpu)
+}
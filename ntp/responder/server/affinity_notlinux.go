@@ -0,0 +1,35 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+var errAffinityUnsupported = errors.New("cpu affinity is only supported on linux")
+
+func pinThread(cpu int) error {
+	return errAffinityUnsupported
+}
+
+func setIncomingCPU(conn *net.UDPConn, cpu int) error {
+	return errAffinityUnsupported
+}
@@ -0,0 +1,51 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := ParseCPUList("0-3,8, 10-11")
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 3, 8, 10, 11}, cpus)
+
+	cpus, err = ParseCPUList("")
+	require.NoError(t, err)
+	require.Empty(t, cpus)
+
+	for _, bad := range []string{"a", "-1", "3-1", "1-b", "1,,2"} {
+		_, err = ParseCPUList(bad)
+		require.True(t, errors.Is(err, errBadCPUList), bad)
+	}
+}
+
+func TestAffinityConfigCPU(t *testing.T) {
+	c := &AffinityConfig{ListenerCPUs: []int{0, 1}, WorkerCPUs: []int{4}}
+	require.Equal(t, 0, c.listenerCPU(0))
+	require.Equal(t, 1, c.listenerCPU(1))
+	require.Equal(t, 0, c.listenerCPU(2))
+	require.Equal(t, 4, c.workerCPU(7))
+
+	c = &AffinityConfig{}
+	require.Equal(t, -1, c.listenerCPU(0))
+	require.Equal(t, -1, c.workerCPU(0))
+}
@@ -0,0 +1,196 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	log "github.com/sirupsen/logrus"
+)
+
+// auditMaxViolations is how many latest violations are kept for the report
+const auditMaxViolations = 100
+
+// Audit verifies the server is stateless before pointing public traffic at it.
+// Sampled responses are regenerated from scratch and compared with the ones
+// produced by the workers, and size of all known state (rate limiter clients, goroutines)
+// is tracked to detect per client state growth.
+// Nil Audit disables auditing
+type Audit struct {
+	// keep these first, so they are aligned to 64-bit for sync/atomic on 32-bit platforms
+	responses  int64
+	sampled    int64
+	mismatches int64
+
+	// SampleRate is how often responses are verified: every SampleRate-th one. 0 disables verification
+	SampleRate int64
+	// Interval between state size samples
+	Interval time.Duration
+	// GrowthSamples is how many consecutive samples of growing state are reported as a violation
+	GrowthSamples int
+
+	// static fills static headers the same way workers do
+	static func(*ntp.Packet)
+
+	sync.Mutex
+	sources    map[string]func() int
+	state      map[string]*auditState
+	violations []string
+}
+
+// auditState tracks the size of a single state source
+type auditState struct {
+	last    int
+	peak    int
+	growing int
+}
+
+// AuditReport is a snapshot of the audit results
+type AuditReport struct {
+	Responses  int64          `json:"responses"`
+	Sampled    int64          `json:"sampled"`
+	Mismatches int64          `json:"mismatches"`
+	State      map[string]int `json:"state"`
+	Peak       map[string]int `json:"peak"`
+	Violations []string       `json:"violations"`
+}
+
+// NewAudit returns Audit verifying every sampleRate-th response and sampling state every interval
+func NewAudit(sampleRate int64, interval time.Duration) *Audit {
+	a := &Audit{
+		SampleRate:    sampleRate,
+		Interval:      interval,
+		GrowthSamples: 10,
+		sources:       map[string]func() int{},
+		state:         map[string]*auditState{},
+	}
+	a.AddSource("goroutines", runtime.NumGoroutine)
+	return a
+}
+
+// AddSource registers a function returning size of some server state, such as number of tracked clients
+func (a *Audit) AddSource(name string, size func() int) {
+	a.Lock()
+	defer a.Unlock()
+	a.sources[name] = size
+}
+
+// sample returns true if the response should be verified
+func (a *Audit) sample() bool {
+	if a == nil || a.SampleRate <= 0 {
+		return false
+	}
+	return atomic.AddInt64(&a.responses, 1)%a.SampleRate == 0
+}
+
+// verify regenerates response from scratch and compares it with the one produced by the worker.
+// Any difference means some state leaked from the previous requests
+func (a *Audit) verify(response *ntp.Packet, now, received time.Time, request *ntp.Packet) {
+	atomic.AddInt64(&a.sampled, 1)
+	fresh := &ntp.Packet{}
+	if a.static != nil {
+		a.static(fresh)
+	}
+	generateResponse(now, received, request, fresh)
+	if *fresh == *response {
+		return
+	}
+	atomic.AddInt64(&a.mismatches, 1)
+	a.violation("response %+v differs from stateless %+v", *response, *fresh)
+}
+
+func (a *Audit) violation(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Errorf("[audit] %s", msg)
+	a.Lock()
+	defer a.Unlock()
+	a.violations = append(a.violations, msg)
+	if len(a.violations) > auditMaxViolations {
+		a.violations = a.violations[len(a.violations)-auditMaxViolations:]
+	}
+}
+
+// collect samples all state sources and reports the ones growing for GrowthSamples in a row
+func (a *Audit) collect() {
+	a.Lock()
+	grown := map[string]int{}
+	for name, size := range a.sources {
+		v := size()
+		st, ok := a.state[name]
+		if !ok {
+			a.state[name] = &auditState{last: v, peak: v}
+			continue
+		}
+		if v > st.last {
+			st.growing++
+		} else {
+			st.growing = 0
+		}
+		st.last = v
+		if v > st.peak {
+			st.peak = v
+		}
+		if a.GrowthSamples > 0 && st.growing == a.GrowthSamples {
+			grown[name] = v
+		}
+	}
+	a.Unlock()
+	for name, v := range grown {
+		a.violation("%s grew for %d consecutive samples to %d", name, a.GrowthSamples, v)
+	}
+}
+
+// Run samples state every Interval until ctx is done
+func (a *Audit) Run(ctx context.Context) {
+	log.Warningf("[audit] verifying every %d response(s), sampling state every %v", a.SampleRate, a.Interval)
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	a.collect()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.collect()
+		}
+	}
+}
+
+// Report returns current audit results
+func (a *Audit) Report() *AuditReport {
+	r := &AuditReport{
+		Responses:  atomic.LoadInt64(&a.responses),
+		Sampled:    atomic.LoadInt64(&a.sampled),
+		Mismatches: atomic.LoadInt64(&a.mismatches),
+		State:      map[string]int{},
+		Peak:       map[string]int{},
+	}
+	a.Lock()
+	defer a.Unlock()
+	for name, st := range a.state {
+		r.State[name] = st.last
+		r.Peak[name] = st.peak
+	}
+	r.Violations = append([]string{}, a.violations...)
+	return r
+}
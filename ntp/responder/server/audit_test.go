@@ -0,0 +1,119 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditSample(t *testing.T) {
+	var a *Audit
+	require.False(t, a.sample())
+
+	a = NewAudit(3, time.Minute)
+	sampled := 0
+	for i := 0; i < 9; i++ {
+		if a.sample() {
+			sampled++
+		}
+	}
+	require.Equal(t, 3, sampled)
+	require.Equal(t, int64(9), a.Report().Responses)
+
+	a.SampleRate = 0
+	require.False(t, a.sample())
+}
+
+func TestAuditVerify(t *testing.T) {
+	s := &Server{Stratum: 1, RefID: "FB"}
+	a := NewAudit(1, time.Minute)
+	a.static = s.fillStaticHeaders
+
+	now := time.Now()
+	request := &ntp.Packet{Settings: 0x1B, TxTimeSec: 1, TxTimeFrac: 2}
+	response := &ntp.Packet{}
+	s.fillStaticHeaders(response)
+	generateResponse(now, now, request, response)
+	a.verify(response, now, now, request)
+	r := a.Report()
+	require.Equal(t, int64(1), r.Sampled)
+	require.Equal(t, int64(0), r.Mismatches)
+	require.Empty(t, r.Violations)
+
+	// leftover from previous requests
+	response.RootDelay = 42
+	a.verify(response, now, now, request)
+	r = a.Report()
+	require.Equal(t, int64(2), r.Sampled)
+	require.Equal(t, int64(1), r.Mismatches)
+	require.Len(t, r.Violations, 1)
+}
+
+func TestAuditCollect(t *testing.T) {
+	a := NewAudit(0, time.Minute)
+	a.GrowthSamples = 3
+	size := 0
+	a.AddSource("clients", func() int { return size })
+
+	a.collect()
+	for i := 0; i < 2; i++ {
+		size++
+		a.collect()
+	}
+	// shrinking resets growth
+	size = 1
+	a.collect()
+	require.Empty(t, a.Report().Violations)
+
+	for i := 0; i < 3; i++ {
+		size++
+		a.collect()
+	}
+	r := a.Report()
+	require.Equal(t, []string{"clients grew for 3 consecutive samples to 4"}, r.Violations)
+	require.Equal(t, 4, r.State["clients"])
+	require.Equal(t, 4, r.Peak["clients"])
+	require.Contains(t, r.State, "goroutines")
+
+	// reported only once per growth streak
+	size++
+	a.collect()
+	require.Len(t, a.Report().Violations, 1)
+
+	for i := 0; i < auditMaxViolations+10; i++ {
+		a.violation("test")
+	}
+	require.Len(t, a.Report().Violations, auditMaxViolations)
+}
+
+func TestAuditRun(t *testing.T) {
+	a := NewAudit(0, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return a.Report().State["goroutines"] > 0 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
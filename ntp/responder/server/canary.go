@@ -0,0 +1,139 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxCanaryOffset limits the canary offset, so misconfiguration never serves grossly wrong time
+const MaxCanaryOffset = time.Second
+
+// DefaultCanaryLogInterval is how often an active canary is logged
+const DefaultCanaryLogInterval = time.Minute
+
+var (
+	errNoCanaryNets   = errors.New("canary needs at least one network")
+	errNoCanaryOffset = errors.New("canary needs non zero offset")
+)
+
+// Canary serves time skewed by a constant offset to clients of test networks, while everyone else gets correct time.
+// It's a controlled way to verify client side monitoring catches bad servers
+type Canary struct {
+	// keep first, so it's aligned to 64-bit for sync/atomic on 32-bit platforms
+	served int64
+
+	Offset time.Duration
+	// LogInterval is how often the active canary is logged
+	LogInterval time.Duration
+
+	nets []*net.IPNet
+}
+
+// CanaryReport is a snapshot of the canary state
+type CanaryReport struct {
+	Offset   time.Duration `json:"offset_ns"`
+	Networks []string      `json:"networks"`
+	Served   int64         `json:"served"`
+}
+
+// NewCanary returns Canary serving time skewed by offset to the networks (IPs or CIDRs).
+// Both must be set explicitly and offset is limited by MaxCanaryOffset
+func NewCanary(networks []string, offset time.Duration) (*Canary, error) {
+	if len(networks) == 0 {
+		return nil, errNoCanaryNets
+	}
+	if offset == 0 {
+		return nil, errNoCanaryOffset
+	}
+	if offset > MaxCanaryOffset || offset < -MaxCanaryOffset {
+		return nil, fmt.Errorf("canary offset %s exceeds %s", offset, MaxCanaryOffset)
+	}
+	c := &Canary{Offset: offset, LogInterval: DefaultCanaryLogInterval}
+	for _, n := range networks {
+		ipnet, err := parseNet(strings.TrimSpace(n))
+		if err != nil {
+			return nil, fmt.Errorf("canary network: %w", err)
+		}
+		c.nets = append(c.nets, ipnet)
+	}
+	return c, nil
+}
+
+// offset returns offset of the time served to the client, 0 for clients outside of canary networks
+func (c *Canary) offset(ip net.IP) time.Duration {
+	if c == nil || ip == nil {
+		return 0
+	}
+	for _, n := range c.nets {
+		if n.Contains(ip) {
+			atomic.AddInt64(&c.served, 1)
+			return c.Offset
+		}
+	}
+	return 0
+}
+
+// Served returns number of requests answered with skewed time
+func (c *Canary) Served() int64 {
+	return atomic.LoadInt64(&c.served)
+}
+
+// Report returns current canary state
+func (c *Canary) Report() *CanaryReport {
+	return &CanaryReport{Offset: c.Offset, Networks: c.networks(), Served: c.Served()}
+}
+
+// Run logs the active canary every LogInterval until ctx is done, so it's never forgotten enabled
+func (c *Canary) Run(ctx context.Context) {
+	log.Warningf("[canary] serving time with %s", c)
+	interval := c.LogInterval
+	if interval <= 0 {
+		interval = DefaultCanaryLogInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			log.Warningf("[canary] still serving time with %s, %d skewed response(s) so far", c, c.Served())
+		}
+	}
+}
+
+func (c *Canary) networks() []string {
+	nets := make([]string, 0, len(c.nets))
+	for _, n := range c.nets {
+		nets = append(nets, n.String())
+	}
+	return nets
+}
+
+// String returns description of the canary for logging
+func (c *Canary) String() string {
+	return fmt.Sprintf("offset %s to %s", c.Offset, strings.Join(c.networks(), ", "))
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCanaryValidation(t *testing.T) {
+	_, err := NewCanary(nil, time.Millisecond)
+	require.ErrorIs(t, err, errNoCanaryNets)
+
+	_, err = NewCanary([]string{"fd00::/64"}, 0)
+	require.ErrorIs(t, err, errNoCanaryOffset)
+
+	_, err = NewCanary([]string{"fd00::/64"}, -2*time.Second)
+	require.EqualError(t, err, "canary offset -2s exceeds 1s")
+
+	_, err = NewCanary([]string{"fd00::/64", "oleg"}, time.Millisecond)
+	require.EqualError(t, err, `canary network: invalid ip address "oleg"`)
+}
+
+func TestCanaryOffset(t *testing.T) {
+	c, err := NewCanary([]string{"fd00::/64", " 192.168.0.1"}, -100*time.Microsecond)
+	require.NoError(t, err)
+	require.Equal(t, "offset -100µs to fd00::/64, 192.168.0.1/32", c.String())
+
+	require.Equal(t, -100*time.Microsecond, c.offset(net.ParseIP("fd00::1")))
+	require.Equal(t, -100*time.Microsecond, c.offset(net.ParseIP("192.168.0.1")))
+	require.Equal(t, time.Duration(0), c.offset(net.ParseIP("fd00:1::1")))
+	require.Equal(t, time.Duration(0), c.offset(net.ParseIP("192.168.0.2")))
+	// unix socket clients have no IP
+	require.Equal(t, time.Duration(0), c.offset(nil))
+	require.Equal(t, int64(2), c.Served())
+	require.Equal(t, &CanaryReport{Offset: -100 * time.Microsecond, Networks: []string{"fd00::/64", "192.168.0.1/32"}, Served: 2}, c.Report())
+
+	// canary is disabled by default
+	var disabled *Canary
+	require.Equal(t, time.Duration(0), disabled.offset(net.ParseIP("fd00::1")))
+}
+
+func TestCanaryRun(t *testing.T) {
+	c, err := NewCanary([]string{"fd00::/64"}, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, DefaultCanaryLogInterval, c.LogInterval)
+	c.LogInterval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	require.Eventually(t, func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+}
@@ -20,6 +20,9 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
 )
 
 // DefaultServerIPs is a default list of IPs server will bind to if nothing else is specified
@@ -31,6 +34,51 @@ type ListenConfig struct {
 	Port           int
 	ShouldAnnounce bool
 	Iface          string
+	// HopLimit of the responses. System default if 0
+	HopLimit int
+	// FlowLabel of IPv6 responses, for traffic engineering of time traffic. None if 0
+	FlowLabel uint32
+}
+
+// BroadcastConfig describes periodic broadcast (mode 5) of time to the lab networks
+type BroadcastConfig struct {
+	// IP is a broadcast address or a multicast group to send packets to
+	IP       net.IP
+	Port     int
+	Interval time.Duration
+}
+
+// TransportConfig describes experimental listener using non-UDP transport,
+// such as TCP for diagnostics through firewalls or unix socket for local testing
+type TransportConfig struct {
+	// Network is tcp or unix. Disabled if empty
+	Network string
+	// Address is host:port for tcp or socket path for unix
+	Address string
+}
+
+// SmearConfig describes leap smear advertised to clients via experimental extension field.
+// Time is smeared linearly over the window which ends at the leap second
+type SmearConfig struct {
+	Start    time.Time
+	Duration time.Duration
+	// Leap is +1s for inserted and -1s for deleted leap second
+	Leap time.Duration
+}
+
+// Info returns smear status at the time now. Nil if smear is not configured
+func (c *SmearConfig) Info(now time.Time) *ntp.SmearInfo {
+	if c == nil || c.Duration <= 0 {
+		return nil
+	}
+	info := &ntp.SmearInfo{Start: c.Start, Duration: c.Duration}
+	elapsed := now.Sub(c.Start)
+	if elapsed >= 0 && elapsed < c.Duration {
+		info.Active = true
+		// smeared clock absorbs the leap second gradually
+		info.Offset = -time.Duration(float64(c.Leap) * float64(elapsed) / float64(c.Duration))
+	}
+	return info
 }
 
 // MultiIPs is a wrapper allowing to set multiple IPs with flag parser
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -61,3 +62,26 @@ func TestConfigSetDefault(t *testing.T) {
 
 	require.Equal(t, DefaultServerIPs, m)
 }
+
+func TestSmearConfigInfo(t *testing.T) {
+	var c *SmearConfig
+	require.Nil(t, c.Info(time.Now()))
+	c = &SmearConfig{}
+	require.Nil(t, c.Info(time.Now()))
+
+	start := time.Unix(1483185600, 0)
+	c = &SmearConfig{Start: start, Duration: 12 * time.Hour, Leap: time.Second}
+
+	info := c.Info(start.Add(-time.Hour))
+	require.False(t, info.Active)
+	require.Equal(t, time.Duration(0), info.Offset)
+	require.Equal(t, 12*time.Hour, info.Duration)
+
+	info = c.Info(start.Add(3 * time.Hour))
+	require.True(t, info.Active)
+	require.Equal(t, -250*time.Millisecond, info.Offset)
+
+	info = c.Info(start.Add(12 * time.Hour))
+	require.False(t, info.Active)
+	require.Equal(t, time.Duration(0), info.Offset)
+}
@@ -0,0 +1,247 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var errNoFileConfig = errors.New("server is not set up from config file")
+
+// FileConfig is a responder configuration which can be read from a yaml file.
+// Example:
+//
+//	listen:
+//	  ips: ["::1", "127.0.0.1"]
+//	  port: 123
+//	  interface: lo
+//	  announce: false
+//	workers: 100
+//	reference:
+//	  refid: OLEG
+//	  stratum: 1
+//	  extraoffset: 0s
+//	acl:
+//	  - 10.0.0.0/8
+//	  - fd00::/8
+//	ratelimit:
+//	  rate: 1
+//	  burst: 8
+//
+// Reference sources are not supported: the server always serves the local clock,
+// reference only sets what is reported to clients.
+type FileConfig struct {
+	Listen    ListenFileConfig    `yaml:"listen"`
+	Workers   int                 `yaml:"workers"`
+	Reference ReferenceFileConfig `yaml:"reference"`
+	ACL       []string            `yaml:"acl"`
+	RateLimit RateLimitFileConfig `yaml:"ratelimit"`
+}
+
+// ListenFileConfig describes listeners. Changes require restart
+type ListenFileConfig struct {
+	IPs       []string `yaml:"ips"`
+	Port      int      `yaml:"port"`
+	Interface string   `yaml:"interface"`
+	Announce  bool     `yaml:"announce"`
+}
+
+// ReferenceFileConfig describes what server reports as its reference
+type ReferenceFileConfig struct {
+	RefID       string        `yaml:"refid"`
+	Stratum     int           `yaml:"stratum"`
+	ExtraOffset time.Duration `yaml:"extraoffset"`
+}
+
+// RateLimitFileConfig is a per client rate limit. Zero rate disables it
+type RateLimitFileConfig struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// ConfigError is a validation error pointing to the offending key
+type ConfigError struct {
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid value of %q: %v", e.Key, e.Err)
+}
+
+// Unwrap returns underlying error
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ReadFileConfig reads and validates config from the yaml file
+func ReadFileConfig(path string) (*FileConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &FileConfig{}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(true)
+	if err := d.Decode(c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks all values of the config
+func (c *FileConfig) Validate() error {
+	if len(c.Listen.IPs) == 0 {
+		return &ConfigError{Key: "listen.ips", Err: fmt.Errorf("at least one ip is required")}
+	}
+	for i, ip := range c.Listen.IPs {
+		if net.ParseIP(ip) == nil {
+			return &ConfigError{Key: fmt.Sprintf("listen.ips[%d]", i), Err: fmt.Errorf("invalid ip address %q", ip)}
+		}
+	}
+	if c.Listen.Port < 1 || c.Listen.Port > 65535 {
+		return &ConfigError{Key: "listen.port", Err: fmt.Errorf("must be between 1 and 65535, got %d", c.Listen.Port)}
+	}
+	if c.Workers < 1 {
+		return &ConfigError{Key: "workers", Err: fmt.Errorf("must be positive, got %d", c.Workers)}
+	}
+	if len(c.Reference.RefID) > 4 {
+		return &ConfigError{Key: "reference.refid", Err: fmt.Errorf("must be at most 4 characters, got %q", c.Reference.RefID)}
+	}
+	if c.Reference.Stratum < 1 || c.Reference.Stratum > 15 {
+		return &ConfigError{Key: "reference.stratum", Err: fmt.Errorf("must be between 1 and 15, got %d", c.Reference.Stratum)}
+	}
+	if _, err := c.aclNets(); err != nil {
+		return err
+	}
+	if c.RateLimit.Rate < 0 {
+		return &ConfigError{Key: "ratelimit.rate", Err: fmt.Errorf("must not be negative, got %v", c.RateLimit.Rate)}
+	}
+	if c.RateLimit.Rate > 0 && c.RateLimit.Burst < 1 {
+		return &ConfigError{Key: "ratelimit.burst", Err: fmt.Errorf("must be positive when rate is set, got %d", c.RateLimit.Burst)}
+	}
+	return nil
+}
+
+// aclNets parses acl entries
+func (c *FileConfig) aclNets() ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for i, entry := range c.ACL {
+		n, err := parseNet(entry)
+		if err != nil {
+			return nil, &ConfigError{Key: fmt.Sprintf("acl[%d]", i), Err: err}
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Apply sets all values of the config to the server. Must be called before Start,
+// ACL and rate limiter are created here if the server has none
+func (c *FileConfig) Apply(s *Server) error {
+	nets, err := c.aclNets()
+	if err != nil {
+		return err
+	}
+
+	s.ListenConfig.IPs = MultiIPs{}
+	for _, ip := range c.Listen.IPs {
+		if err := s.ListenConfig.IPs.Set(ip); err != nil {
+			return err
+		}
+	}
+	s.ListenConfig.Port = c.Listen.Port
+	s.ListenConfig.Iface = c.Listen.Interface
+	s.ListenConfig.ShouldAnnounce = c.Listen.Announce
+	s.Workers = c.Workers
+	s.ExtraOffset = c.Reference.ExtraOffset
+	s.RefID = c.Reference.RefID
+	s.Stratum = c.Reference.Stratum
+	if s.ACL == nil {
+		s.ACL = &ACL{}
+	}
+	s.ACL.Set(nets)
+	if s.RateLimiter == nil {
+		s.RateLimiter = &RateLimiter{}
+	}
+	s.RateLimiter.Set(c.RateLimit.Rate, c.RateLimit.Burst)
+	s.config = c
+	return nil
+}
+
+// ReloadConfig reads config from the path and applies changes to the running server
+func (s *Server) ReloadConfig(path string) error {
+	c, err := ReadFileConfig(path)
+	if err != nil {
+		return err
+	}
+	return s.Reload(c)
+}
+
+// Reload applies changes of the config to the running server.
+// Reference, ACL and rate limits are applied live, other changes require restart.
+// Server must be set up via Apply first
+func (s *Server) Reload(c *FileConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	nets, err := c.aclNets()
+	if err != nil {
+		return err
+	}
+
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+	if s.config == nil {
+		return errNoFileConfig
+	}
+	if !reflect.DeepEqual(s.config.Listen, c.Listen) {
+		log.Warningf("[server] listen config change requires restart")
+	}
+	if s.config.Workers != c.Workers {
+		log.Warningf("[server] workers change requires restart")
+	}
+	// existing client state is dropped on change
+	if s.config.RateLimit != c.RateLimit {
+		s.RateLimiter.Set(c.RateLimit.Rate, c.RateLimit.Burst)
+	}
+
+	s.headersLock.Lock()
+	s.RefID = c.Reference.RefID
+	s.Stratum = c.Reference.Stratum
+	s.headersLock.Unlock()
+	atomic.AddInt64(&s.headersVersion, 1)
+	atomic.StoreInt64((*int64)(&s.ExtraOffset), int64(c.Reference.ExtraOffset))
+
+	s.ACL.Set(nets)
+	s.config = c
+	log.Infof("[server] config reloaded")
+	return nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testFileConfig = `
+listen:
+  ips: ["::1", "127.0.0.1"]
+  port: 123
+  interface: lo
+workers: 10
+reference:
+  refid: OLEG
+  stratum: 1
+  extraoffset: 1s
+acl:
+  - 10.0.0.0/8
+  - fd00::1
+ratelimit:
+  rate: 1
+  burst: 8
+`
+
+func writeTestConfig(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "ntpresponder")
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return f.Name()
+}
+
+func TestReadFileConfig(t *testing.T) {
+	path := writeTestConfig(t, testFileConfig)
+	defer os.Remove(path)
+
+	c, err := ReadFileConfig(path)
+	require.NoError(t, err)
+	expected := &FileConfig{
+		Listen:    ListenFileConfig{IPs: []string{"::1", "127.0.0.1"}, Port: 123, Interface: "lo"},
+		Workers:   10,
+		Reference: ReferenceFileConfig{RefID: "OLEG", Stratum: 1, ExtraOffset: time.Second},
+		ACL:       []string{"10.0.0.0/8", "fd00::1"},
+		RateLimit: RateLimitFileConfig{Rate: 1, Burst: 8},
+	}
+	require.Equal(t, expected, c)
+}
+
+func TestReadFileConfigUnknownKey(t *testing.T) {
+	path := writeTestConfig(t, testFileConfig+"foo: bar\n")
+	defer os.Remove(path)
+
+	_, err := ReadFileConfig(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "field foo not found")
+}
+
+func TestFileConfigValidate(t *testing.T) {
+	valid := func() *FileConfig {
+		return &FileConfig{
+			Listen:    ListenFileConfig{IPs: []string{"::1"}, Port: 123},
+			Workers:   1,
+			Reference: ReferenceFileConfig{RefID: "OLEG", Stratum: 1},
+		}
+	}
+	require.NoError(t, valid().Validate())
+
+	cases := map[string]func(c *FileConfig){
+		"listen.ips":        func(c *FileConfig) { c.Listen.IPs = nil },
+		"listen.ips[1]":     func(c *FileConfig) { c.Listen.IPs = append(c.Listen.IPs, "foo") },
+		"listen.port":       func(c *FileConfig) { c.Listen.Port = 0 },
+		"workers":           func(c *FileConfig) { c.Workers = 0 },
+		"reference.refid":   func(c *FileConfig) { c.Reference.RefID = "TOOLONG" },
+		"reference.stratum": func(c *FileConfig) { c.Reference.Stratum = 16 },
+		"acl[0]":            func(c *FileConfig) { c.ACL = []string{"10.0.0.0/33"} },
+		"ratelimit.rate":    func(c *FileConfig) { c.RateLimit.Rate = -1 },
+		"ratelimit.burst":   func(c *FileConfig) { c.RateLimit.Rate = 1 },
+	}
+	for key, breakIt := range cases {
+		c := valid()
+		breakIt(c)
+		err := c.Validate()
+		var cerr *ConfigError
+		require.True(t, errors.As(err, &cerr), key)
+		require.Equal(t, key, cerr.Key)
+	}
+}
+
+func TestFileConfigApplyAndReload(t *testing.T) {
+	path := writeTestConfig(t, testFileConfig)
+	defer os.Remove(path)
+
+	c, err := ReadFileConfig(path)
+	require.NoError(t, err)
+	s := &Server{}
+	require.NoError(t, c.Apply(s))
+	require.Equal(t, 2, len(s.ListenConfig.IPs))
+	require.Equal(t, 10, s.Workers)
+	require.Equal(t, "OLEG", s.RefID)
+	require.Equal(t, time.Second, s.ExtraOffset)
+	require.Equal(t, 2, s.ACL.Len())
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+listen:
+  ips: ["::1", "127.0.0.1"]
+  port: 123
+workers: 10
+reference:
+  refid: GPS
+  stratum: 2
+`), 0644))
+	require.NoError(t, s.ReloadConfig(path))
+	require.Equal(t, "GPS", s.RefID)
+	require.Equal(t, 2, s.CurrentStratum())
+	require.Equal(t, 0, s.ACL.Len())
+
+	// broken config keeps running one
+	require.NoError(t, ioutil.WriteFile(path, []byte("workers: 0\n"), 0644))
+	require.Error(t, s.ReloadConfig(path))
+	require.Equal(t, "GPS", s.RefID)
+}
+
+func TestFileConfigReloadLive(t *testing.T) {
+	c := &FileConfig{
+		Listen:    ListenFileConfig{IPs: []string{"::1"}, Port: 123},
+		Workers:   1,
+		Reference: ReferenceFileConfig{RefID: "OLEG", Stratum: 1},
+	}
+	s := &Server{}
+	require.ErrorIs(t, s.Reload(c), errNoFileConfig)
+	require.NoError(t, c.Apply(s))
+	acl := s.ACL
+
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := *c
+			r.Reference.ExtraOffset = time.Duration(i) * time.Millisecond
+			errs <- s.Reload(&r)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.NotZero(t, s.extraOffset())
+	require.Same(t, acl, s.ACL)
+
+	r := *c
+	r.Reference.ExtraOffset = time.Second
+	require.NoError(t, s.Reload(&r))
+	require.Equal(t, time.Second, s.extraOffset())
+}
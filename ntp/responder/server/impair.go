@@ -0,0 +1,86 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ImpairRefID is the reference ID of responses from impaired server, so they are never mistaken for real time
+const ImpairRefID = "TEST"
+
+// Jitter distributions
+const (
+	DistributionUniform     = "uniform"
+	DistributionNormal      = "normal"
+	DistributionExponential = "exponential"
+)
+
+var errNegativeImpair = errors.New("impairment delay and jitter must not be negative")
+
+// ImpairConfig describes lab test mode where responses are deliberately delayed and offset,
+// to validate client implementations and monitoring thresholds
+type ImpairConfig struct {
+	// Delay is added before sending the response, after its timestamps are generated, like asymmetric network delay
+	Delay time.Duration
+	// Jitter is the scale of random variation of the delay
+	Jitter time.Duration
+	// Distribution of the jitter: uniform within ±Jitter, normal with Jitter standard deviation
+	// or exponential with Jitter mean (only adds delay, like queueing)
+	Distribution string
+	// Offset is added to all response timestamps
+	Offset time.Duration
+}
+
+// Enabled returns true if any impairment is configured
+func (c *ImpairConfig) Enabled() bool {
+	return c.Delay != 0 || c.Jitter != 0 || c.Offset != 0
+}
+
+// Validate checks the config
+func (c *ImpairConfig) Validate() error {
+	if c.Delay < 0 || c.Jitter < 0 {
+		return errNegativeImpair
+	}
+	switch c.Distribution {
+	case "", DistributionUniform, DistributionNormal, DistributionExponential:
+		return nil
+	}
+	return fmt.Errorf("unsupported jitter distribution %q", c.Distribution)
+}
+
+// delay returns random delay of the next response. It's never negative
+func (c *ImpairConfig) delay() time.Duration {
+	d := float64(c.Delay)
+	if c.Jitter > 0 {
+		switch c.Distribution {
+		case DistributionNormal:
+			d += rand.NormFloat64() * float64(c.Jitter)
+		case DistributionExponential:
+			d += rand.ExpFloat64() * float64(c.Jitter)
+		default:
+			d += (2*rand.Float64() - 1) * float64(c.Jitter)
+		}
+	}
+	if d < 0 {
+		return 0
+	}
+	return time.Duration(d)
+}
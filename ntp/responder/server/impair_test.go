@@ -0,0 +1,104 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/facebook/time/clock"
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/responder/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpairConfigEnabled(t *testing.T) {
+	require.False(t, (&ImpairConfig{Distribution: DistributionNormal}).Enabled())
+	require.True(t, (&ImpairConfig{Offset: -time.Millisecond}).Enabled())
+	require.True(t, (&ImpairConfig{Jitter: time.Millisecond}).Enabled())
+}
+
+func TestImpairConfigValidate(t *testing.T) {
+	require.NoError(t, (&ImpairConfig{}).Validate())
+	require.NoError(t, (&ImpairConfig{Delay: time.Millisecond, Distribution: DistributionExponential}).Validate())
+	require.ErrorIs(t, (&ImpairConfig{Delay: -time.Millisecond}).Validate(), errNegativeImpair)
+	require.Error(t, (&ImpairConfig{Distribution: "pareto"}).Validate())
+}
+
+func TestImpairDelay(t *testing.T) {
+	c := &ImpairConfig{Delay: 10 * time.Millisecond}
+	require.Equal(t, 10*time.Millisecond, c.delay())
+
+	c.Jitter = 5 * time.Millisecond
+	for i := 0; i < 1000; i++ {
+		d := c.delay()
+		require.GreaterOrEqual(t, d, 5*time.Millisecond)
+		require.LessOrEqual(t, d, 15*time.Millisecond)
+	}
+
+	c.Distribution = DistributionExponential
+	for i := 0; i < 1000; i++ {
+		require.GreaterOrEqual(t, c.delay(), 10*time.Millisecond)
+	}
+
+	// delay is never negative even if jitter is bigger
+	c = &ImpairConfig{Jitter: time.Second, Distribution: DistributionNormal}
+	for i := 0; i < 1000; i++ {
+		require.GreaterOrEqual(t, c.delay(), time.Duration(0))
+	}
+}
+
+func TestFillStaticHeadersImpairRefID(t *testing.T) {
+	s := &Server{RefID: "OLEG", Impair: ImpairConfig{Offset: time.Second}}
+	response := &ntp.Packet{}
+	s.fillStaticHeaders(response)
+	require.Equal(t, uint32(0x54455354), response.ReferenceID)
+}
+
+func TestServeImpairDelay(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+	cconn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer cconn.Close()
+
+	delay := 50 * time.Millisecond
+	task := &task{
+		pc:       pc,
+		from:     cconn.LocalAddr(),
+		delay:    delay,
+		received: time.Now(),
+		request:  &ntp.Packet{Settings: 0x1B},
+		stats:    &stats.JSONStats{},
+	}
+	start := time.Now()
+	task.serve(newResponseTemplate(&ntp.Packet{}), clock.System{}, 0, nil)
+	// worker is not blocked by the delay
+	require.Less(t, time.Since(start), delay)
+
+	require.NoError(t, cconn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, ntp.MaxPacketSizeBytes)
+	n, _, err := cconn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), delay)
+	response, err := ntp.BytesToPacket(buf[:n])
+	require.NoError(t, err)
+	// transmit timestamp is taken before the delay
+	require.Less(t, ntp.Unix(response.TxTimeSec, response.TxTimeFrac).Sub(start), delay)
+}
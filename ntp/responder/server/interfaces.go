@@ -38,6 +38,12 @@ type Stats interface {
 	IncWorkers()
 	// IncReadError atomically add 1 to the counter
 	IncReadError()
+	// IncACLDenied atomically add 1 to the counter
+	IncACLDenied()
+	// IncRateLimited atomically add 1 to the counter
+	IncRateLimited()
+	// IncNTSNAK atomically add 1 to the counter
+	IncNTSNAK()
 
 	// DecListeners atomically removes 1 from the counter
 	DecListeners()
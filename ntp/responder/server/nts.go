@@ -0,0 +1,98 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/protocol/nts"
+	log "github.com/sirupsen/logrus"
+)
+
+// NTS serves Network Time Security: NTS-KE over TLS issuing cookies and
+// authenticated NTP requests carrying them. Nil NTS disables it
+type NTS struct {
+	// Listen is the NTS-KE address
+	Listen   string
+	CertFile string
+	KeyFile  string
+	// RotateInterval of cookie master keys. Cookies stay valid for one more interval
+	RotateInterval time.Duration
+	Keys           *nts.Keys
+}
+
+// NewNTS returns NTS with a fresh master key
+func NewNTS(listen, certFile, keyFile string, rotateInterval time.Duration) (*NTS, error) {
+	n := &NTS{Listen: listen, CertFile: certFile, KeyFile: keyFile, RotateInterval: rotateInterval, Keys: &nts.Keys{Keep: 1}}
+	if err := n.Keys.RotateRandom(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Run serves NTS-KE and rotates master keys until ctx is done
+func (n *NTS) Run(ctx context.Context) error {
+	cert, err := tls.LoadX509KeyPair(n.CertFile, n.KeyFile)
+	if err != nil {
+		return err
+	}
+	if n.RotateInterval > 0 {
+		go n.Keys.RunRotation(ctx, n.RotateInterval)
+	}
+	ke := &nts.KEServer{Keys: n.Keys, TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}, Timeout: 10 * time.Second}
+	log.Infof("Starting NTS-KE on %s", n.Listen)
+	return ke.ListenAndServe(n.Listen)
+}
+
+// respond returns response with NTS extension fields. fields are other extension fields to authenticate.
+// Requests failing verification get NTS NAK, requests without unique identifier are dropped
+func (n *NTS) respond(request *ntp.Packet, ext []byte, response *ntp.Packet, fields []ntp.ExtensionField, stats Stats) ([]byte, bool) {
+	header, err := request.Bytes()
+	if err != nil {
+		return nil, false
+	}
+	r, uid, err := n.Keys.VerifyRequest(header, ext)
+	if err != nil {
+		log.Debugf("NTS request verification failed: %v", err)
+		if uid == nil {
+			return nil, false
+		}
+		stats.IncNTSNAK()
+		nak := *response
+		nak.Stratum = 0
+		nak.ReferenceID = binary.BigEndian.Uint32([]byte(nts.KissNTSNAK))
+		b, err := nak.Bytes()
+		if err != nil {
+			return nil, false
+		}
+		return append(b, nts.NAK(uid)...), true
+	}
+	b, err := response.Bytes()
+	if err != nil {
+		return nil, false
+	}
+	ntsExt, err := n.Keys.Response(r, b, fields)
+	if err != nil {
+		log.Errorf("Failed to build NTS response: %v", err)
+		return nil, false
+	}
+	return append(b, ntsExt...), true
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/protocol/nts"
+	"github.com/facebook/time/ntp/responder/stats"
+	"github.com/stretchr/testify/require"
+)
+
+// ntsRequest returns extension fields of NTS request authenticated with the c2s key
+func ntsRequest(t *testing.T, request *ntp.Packet, uid, cookie, c2s []byte) []byte {
+	header, err := request.Bytes()
+	require.NoError(t, err)
+	ext := ntp.MarshalExtensionFields([]ntp.ExtensionField{
+		{Type: nts.ExtUniqueIdentifier, Value: uid},
+		{Type: nts.ExtCookie, Value: cookie},
+	})
+	a, err := nts.NewAEAD(c2s)
+	require.NoError(t, err)
+	auth := nts.SealAuthenticator(a, make([]byte, nts.NonceSize), append(header, ext...), nil)
+	return append(ext, ntp.MarshalExtensionFields([]ntp.ExtensionField{auth})...)
+}
+
+func TestNTSRespond(t *testing.T) {
+	n, err := NewNTS(":4460", "", "", time.Hour)
+	require.NoError(t, err)
+	c := &nts.Cookie{AEAD: nts.AEADAESSIVCMAC256, C2S: bytes.Repeat([]byte{1}, nts.KeySize), S2C: bytes.Repeat([]byte{2}, nts.KeySize)}
+	cookie, err := n.Keys.SealCookie(c)
+	require.NoError(t, err)
+	uid := bytes.Repeat([]byte{7}, nts.MinUniqueIDSize)
+	request := &ntp.Packet{Settings: 0x23, TxTimeSec: 42}
+	response := &ntp.Packet{Settings: 0x24, Stratum: 1}
+	st := &stats.JSONStats{}
+
+	b, ok := n.respond(request, ntsRequest(t, request, uid, cookie, c.C2S), response, nil, st)
+	require.True(t, ok)
+	fields, err := ntp.ParseExtensionFields(b[ntp.PacketSizeBytes:])
+	require.NoError(t, err)
+	require.Len(t, fields, 2)
+	require.Equal(t, uid, fields[0].Value)
+	require.Equal(t, nts.ExtAuthenticator, fields[1].Type)
+	a, err := nts.NewAEAD(c.S2C)
+	require.NoError(t, err)
+	_, err = nts.OpenAuthenticator(a, b[:len(b)-4-len(fields[1].Value)], fields[1])
+	require.NoError(t, err)
+
+	// wrong key gets NAK
+	b, ok = n.respond(request, ntsRequest(t, request, uid, cookie, c.S2C), response, nil, st)
+	require.True(t, ok)
+	nak, err := ntp.BytesToPacket(b)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), nak.Stratum)
+	require.Equal(t, binary.BigEndian.Uint32([]byte("NTSN")), nak.ReferenceID)
+	require.Equal(t, nts.NAK(uid), b[ntp.PacketSizeBytes:])
+	require.Equal(t, int64(1), st.Values()["ntsNAK"])
+	// response buffer of the worker is intact
+	require.Equal(t, uint8(1), response.Stratum)
+
+	// no unique identifier, nothing to respond to
+	_, ok = n.respond(request, ntsRequest(t, request, uid[:8], cookie, c.C2S), response, nil, st)
+	require.False(t, ok)
+}
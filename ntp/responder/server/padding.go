@@ -0,0 +1,82 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"fmt"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// Padding modes
+const (
+	PaddingOff   = ""
+	PaddingLimit = "limit"
+	PaddingMatch = "match"
+)
+
+// legacyMACSizes are sizes of the legacy MAC field: crypto-NAK, MD5 and SHA1 with key ID
+var legacyMACSizes = map[int]bool{4: true, 20: true, 24: true}
+
+// PaddingConfig controls response size relative to the request size, so the server can't be used for amplification
+type PaddingConfig struct {
+	// Mode is one of:
+	// limit: extension fields which would make the response bigger than the request are dropped;
+	// match: same as limit, plus the response is padded up to the request size with
+	// padding extension field for NTPv4 or zero legacy MAC field if there are no extension fields
+	Mode string
+}
+
+// Validate checks the config
+func (c *PaddingConfig) Validate() error {
+	switch c.Mode {
+	case PaddingOff, PaddingLimit, PaddingMatch:
+		return nil
+	}
+	return fmt.Errorf("unsupported padding mode %q", c.Mode)
+}
+
+// apply returns response header followed by fields which fit into requestSize.
+// Nil config means padding is off.
+// NTS responses are not handled here as RFC 8915 already bounds their size by cookie placeholders
+func (c *PaddingConfig) apply(header []byte, fields []ntp.ExtensionField, requestSize int, version uint8) []byte {
+	if c == nil || c.Mode == PaddingOff {
+		if len(fields) == 0 {
+			return header
+		}
+		return append(header, ntp.MarshalExtensionFields(fields)...)
+	}
+	var ext []byte
+	for ; len(fields) > 0; fields = fields[:len(fields)-1] {
+		ext = ntp.MarshalExtensionFields(fields)
+		if len(header)+len(ext) <= requestSize {
+			break
+		}
+		ext = nil
+	}
+	if c.Mode == PaddingMatch {
+		room := requestSize - len(header)
+		if room > len(ext) {
+			if padded, ok := ntp.PadExtensionFields(fields, room); ok && version == 4 {
+				ext = ntp.MarshalExtensionFields(padded)
+			} else if len(ext) == 0 && legacyMACSizes[room] {
+				ext = make([]byte, room)
+			}
+		}
+	}
+	return append(header, ext...)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"testing"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaddingValidate(t *testing.T) {
+	for _, mode := range []string{PaddingOff, PaddingLimit, PaddingMatch} {
+		c := PaddingConfig{Mode: mode}
+		require.NoError(t, c.Validate())
+	}
+	c := PaddingConfig{Mode: "pad"}
+	require.Error(t, c.Validate())
+}
+
+func TestPaddingApplyOff(t *testing.T) {
+	header := make([]byte, ntp.PacketSizeBytes)
+	fields := []ntp.ExtensionField{{Type: ntp.ExtensionSmear, Value: make([]byte, 20)}}
+	var c *PaddingConfig
+	require.Len(t, c.apply(header, nil, ntp.PacketSizeBytes, 4), ntp.PacketSizeBytes)
+	// response is bigger than the request
+	c = &PaddingConfig{}
+	require.Len(t, c.apply(header, fields, ntp.PacketSizeBytes, 4), ntp.PacketSizeBytes+28)
+}
+
+func TestPaddingApplyLimit(t *testing.T) {
+	c := &PaddingConfig{Mode: PaddingLimit}
+	fields := []ntp.ExtensionField{
+		{Type: ntp.ExtensionSmear, Value: make([]byte, 20)},
+		{Type: 0x0104, Value: make([]byte, 32)},
+	}
+	// no room for extension fields
+	b := c.apply(make([]byte, ntp.PacketSizeBytes), fields, ntp.PacketSizeBytes, 4)
+	require.Len(t, b, ntp.PacketSizeBytes)
+	// room for the first field only
+	b = c.apply(make([]byte, ntp.PacketSizeBytes), fields, ntp.PacketSizeBytes+40, 4)
+	require.Len(t, b, ntp.PacketSizeBytes+28)
+	// everything fits, no padding
+	b = c.apply(make([]byte, ntp.PacketSizeBytes), fields, ntp.PacketSizeBytes+100, 4)
+	require.Len(t, b, ntp.PacketSizeBytes+24+36)
+}
+
+func TestPaddingApplyMatch(t *testing.T) {
+	c := &PaddingConfig{Mode: PaddingMatch}
+	fields := []ntp.ExtensionField{{Type: ntp.ExtensionSmear, Value: make([]byte, 20)}}
+
+	b := c.apply(make([]byte, ntp.PacketSizeBytes), fields, ntp.PacketSizeBytes+64, 4)
+	require.Len(t, b, ntp.PacketSizeBytes+64)
+	parsed, err := ntp.ParseExtensionFields(b[ntp.PacketSizeBytes:])
+	require.NoError(t, err)
+	require.Len(t, parsed, 2)
+	require.Equal(t, ntp.ExtensionSmear, parsed[0].Type)
+	require.Equal(t, ntp.ExtensionPadding, parsed[1].Type)
+
+	// legacy MAC sized request
+	b = c.apply(make([]byte, ntp.PacketSizeBytes), nil, ntp.PacketSizeBytes+20, 3)
+	require.Equal(t, make([]byte, ntp.PacketSizeBytes+20), b)
+
+	// NTPv3 has no extension fields and the size doesn't match a MAC
+	b = c.apply(make([]byte, ntp.PacketSizeBytes), nil, ntp.PacketSizeBytes+64, 3)
+	require.Len(t, b, ntp.PacketSizeBytes)
+
+	// not a multiple of 4, only what fits
+	b = c.apply(make([]byte, ntp.PacketSizeBytes), fields, ntp.PacketSizeBytes+30, 4)
+	require.Len(t, b, ntp.PacketSizeBytes+28)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// rateLimitCleanupInterval is how often idle clients are removed from the limiter
+const rateLimitCleanupInterval = time.Minute
+
+// bucket is a token bucket of a single client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a per client token bucket rate limiter.
+// Zero Rate disables rate limiting
+type RateLimiter struct {
+	sync.Mutex
+	rate        float64
+	burst       float64
+	clients     map[string]*bucket
+	lastCleanup time.Time
+}
+
+// NewRateLimiter returns RateLimiter allowing rate requests per second with bursts of burst requests per client
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	r := &RateLimiter{}
+	r.Set(rate, burst)
+	return r
+}
+
+// Set changes the limits. Existing client state is dropped
+func (r *RateLimiter) Set(rate float64, burst int) {
+	r.Lock()
+	defer r.Unlock()
+	r.rate = rate
+	r.burst = float64(burst)
+	if r.burst < 1 {
+		r.burst = 1
+	}
+	r.clients = make(map[string]*bucket)
+}
+
+// Allow checks if the client is allowed to send one more request at the moment
+func (r *RateLimiter) Allow(ip net.IP, now time.Time) bool {
+	if r == nil {
+		return true
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.rate <= 0 {
+		return true
+	}
+
+	if now.Sub(r.lastCleanup) > rateLimitCleanupInterval {
+		r.cleanup(now)
+	}
+
+	key := string(ip.To16())
+	b, ok := r.clients[key]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.clients[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * r.rate
+	if b.tokens > r.burst {
+		b.tokens = r.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Len returns number of clients tracked by the limiter
+func (r *RateLimiter) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.Lock()
+	defer r.Unlock()
+	return len(r.clients)
+}
+
+// cleanup removes clients whose buckets are refilled already
+func (r *RateLimiter) cleanup(now time.Time) {
+	r.lastCleanup = now
+	full := time.Duration(r.burst / r.rate * float64(time.Second))
+	for k, b := range r.clients {
+		if now.Sub(b.last) > full {
+			delete(r.clients, k)
+		}
+	}
+}
+
+// ClientState is the rate limiter state of a single client
+type ClientState struct {
+	IP     net.IP
+	Tokens float64
+	Last   time.Time
+}
+
+// Changed returns state of clients seen after since
+func (r *RateLimiter) Changed(since time.Time) []ClientState {
+	if r == nil {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+	res := []ClientState{}
+	for k, b := range r.clients {
+		if b.last.After(since) {
+			res = append(res, ClientState{IP: net.IP(k), Tokens: b.tokens, Last: b.last})
+		}
+	}
+	return res
+}
+
+// Merge applies client state replicated from another instance at the moment now. The most recent state of a client wins.
+// States with invalid tokens are dropped, tokens are clamped to the burst and times from the future to now
+func (r *RateLimiter) Merge(states []ClientState, now time.Time) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.rate <= 0 {
+		return
+	}
+	for _, st := range states {
+		if math.IsNaN(st.Tokens) || math.IsInf(st.Tokens, 0) || st.IP.To16() == nil {
+			continue
+		}
+		last := st.Last
+		if last.After(now) {
+			last = now
+		}
+		key := string(st.IP.To16())
+		if b, ok := r.clients[key]; ok && !last.After(b.last) {
+			continue
+		}
+		tokens := math.Max(0, math.Min(st.Tokens, r.burst))
+		r.clients[key] = &bucket{tokens: tokens, last: last}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterDisabled(t *testing.T) {
+	var nilLimiter *RateLimiter
+	require.True(t, nilLimiter.Allow(net.ParseIP("1.2.3.4"), time.Now()))
+
+	r := NewRateLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		require.True(t, r.Allow(net.ParseIP("1.2.3.4"), time.Now()))
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Unix(1585231321, 0)
+	client := net.ParseIP("1.2.3.4")
+	other := net.ParseIP("fd00::1")
+	r := NewRateLimiter(1, 2)
+
+	require.True(t, r.Allow(client, now))
+	require.True(t, r.Allow(client, now))
+	require.False(t, r.Allow(client, now))
+	// other clients are not affected
+	require.True(t, r.Allow(other, now))
+
+	// one token per second
+	now = now.Add(time.Second)
+	require.True(t, r.Allow(client, now))
+	require.False(t, r.Allow(client, now))
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	now := time.Unix(1585231321, 0)
+	r := NewRateLimiter(1, 2)
+	require.True(t, r.Allow(net.ParseIP("1.2.3.4"), now))
+	require.Len(t, r.clients, 1)
+
+	now = now.Add(2 * rateLimitCleanupInterval)
+	require.True(t, r.Allow(net.ParseIP("fd00::1"), now))
+	require.Len(t, r.clients, 1)
+}
+
+func TestRateLimiterLen(t *testing.T) {
+	var r *RateLimiter
+	require.Equal(t, 0, r.Len())
+	r = NewRateLimiter(1, 1)
+	r.Allow(net.ParseIP("192.0.2.1"), time.Now())
+	require.Equal(t, 1, r.Len())
+}
+
+func TestRateLimiterChangedMerge(t *testing.T) {
+	now := time.Unix(1585231321, 0)
+	client := net.ParseIP("1.2.3.4")
+	r := NewRateLimiter(1, 2)
+	r.Allow(client, now)
+	r.Allow(net.ParseIP("fd00::1"), now.Add(-time.Second))
+
+	changed := r.Changed(now.Add(-time.Millisecond))
+	require.Equal(t, []ClientState{{IP: client.To16(), Tokens: 1, Last: now}}, changed)
+
+	standby := NewRateLimiter(1, 2)
+	standby.Merge(changed, now)
+	require.True(t, standby.Allow(client, now))
+	require.False(t, standby.Allow(client, now))
+
+	// older state doesn't override newer one
+	standby.Merge([]ClientState{{IP: client, Tokens: 2, Last: now.Add(-time.Second)}}, now)
+	require.False(t, standby.Allow(client, now))
+
+	var nilLimiter *RateLimiter
+	require.Nil(t, nilLimiter.Changed(now))
+	nilLimiter.Merge(changed, now)
+}
+
+func TestRateLimiterMergeInvalid(t *testing.T) {
+	now := time.Unix(1585231321, 0)
+	client := net.ParseIP("1.2.3.4")
+	r := NewRateLimiter(1, 2)
+
+	r.Merge([]ClientState{
+		{IP: client, Tokens: math.NaN(), Last: now},
+		{IP: net.ParseIP("fd00::1"), Tokens: math.Inf(1), Last: now},
+		{IP: nil, Tokens: 1, Last: now},
+	}, now)
+	require.Equal(t, 0, r.Len())
+
+	// tokens are clamped to the burst, future times to now
+	r.Merge([]ClientState{{IP: client, Tokens: 1e9, Last: now.Add(time.Hour)}}, now)
+	require.Equal(t, []ClientState{{IP: client.To16(), Tokens: 2, Last: now}}, r.Changed(time.Time{}))
+
+	// negative tokens are clamped to zero
+	r.Merge([]ClientState{{IP: client, Tokens: -1e9, Last: now.Add(time.Second)}}, now.Add(time.Second))
+	require.Equal(t, []ClientState{{IP: client.To16(), Tokens: 0, Last: now.Add(time.Second)}}, r.Changed(time.Time{}))
+	require.False(t, r.Allow(client, now.Add(time.Second)))
+}
@@ -0,0 +1,224 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Region labels used when client can't be mapped
+const (
+	// RegionUnknown is the label of clients enricher has no region for
+	RegionUnknown = "unknown"
+	// RegionOther is the label of clients of regions above MaxRegions
+	RegionOther = "other"
+)
+
+// Enricher maps client IP to a region or POP label. Implementation is up to the operator,
+// for example a GeoIP database lookup or a PrefixEnricher built from the topology
+type Enricher interface {
+	// Region returns a label of the client. Empty if unknown
+	Region(ip net.IP) string
+}
+
+type prefixRegion struct {
+	net   *net.IPNet
+	label string
+}
+
+// PrefixEnricher maps clients to regions by the longest matching prefix
+type PrefixEnricher struct {
+	prefixes []prefixRegion
+}
+
+// ParsePrefixEnricher reads "IP or CIDR, label" pairs separated by whitespace, one per line. Lines starting with # are ignored
+func ParsePrefixEnricher(r io.Reader) (*PrefixEnricher, error) {
+	e := &PrefixEnricher{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected prefix and region, got %q", line, text)
+		}
+		ipnet, err := parseNet(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		e.prefixes = append(e.prefixes, prefixRegion{net: ipnet, label: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// longest prefix first
+	sort.SliceStable(e.prefixes, func(i, j int) bool {
+		oi, _ := e.prefixes[i].net.Mask.Size()
+		oj, _ := e.prefixes[j].net.Mask.Size()
+		return oi > oj
+	})
+	return e, nil
+}
+
+// LoadPrefixEnricher reads PrefixEnricher from the file
+func LoadPrefixEnricher(path string) (*PrefixEnricher, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	e, err := ParsePrefixEnricher(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse regions %s: %w", path, err)
+	}
+	return e, nil
+}
+
+// Region returns label of the longest prefix containing the ip
+func (e *PrefixEnricher) Region(ip net.IP) string {
+	for _, p := range e.prefixes {
+		if p.net.Contains(ip) {
+			return p.label
+		}
+	}
+	return ""
+}
+
+// RegionCount is a set of counters of a single region
+type RegionCount struct {
+	Requests    int64 `json:"requests"`
+	Responses   int64 `json:"responses"`
+	ACLDenied   int64 `json:"aclDenied"`
+	RateLimited int64 `json:"rateLimited"`
+}
+
+// regionCounters are counters of a single region. Nil means region stats are disabled
+type regionCounters struct {
+	// keep these aligned to 64-bit for sync/atomic
+	requests    int64
+	responses   int64
+	aclDenied   int64
+	rateLimited int64
+}
+
+func (c *regionCounters) incRequests() {
+	if c != nil {
+		atomic.AddInt64(&c.requests, 1)
+	}
+}
+
+func (c *regionCounters) incResponses() {
+	if c != nil {
+		atomic.AddInt64(&c.responses, 1)
+	}
+}
+
+func (c *regionCounters) incACLDenied() {
+	if c != nil {
+		atomic.AddInt64(&c.aclDenied, 1)
+	}
+}
+
+func (c *regionCounters) incRateLimited() {
+	if c != nil {
+		atomic.AddInt64(&c.rateLimited, 1)
+	}
+}
+
+// RegionStats aggregates requests by region of the client, so operators can see
+// per region query patterns without logging client IPs. Nil RegionStats disables aggregation
+type RegionStats struct {
+	Enricher Enricher
+	// MaxRegions limits number of distinct labels. Further regions are counted as RegionOther. 0 is unlimited
+	MaxRegions int
+
+	sync.RWMutex
+	regions map[string]*regionCounters
+}
+
+// NewRegionStats returns RegionStats labeling clients with the enricher
+func NewRegionStats(e Enricher, maxRegions int) *RegionStats {
+	return &RegionStats{Enricher: e, MaxRegions: maxRegions, regions: map[string]*regionCounters{}}
+}
+
+// label returns region of the client
+func (r *RegionStats) label(ip net.IP) string {
+	if ip == nil {
+		return RegionUnknown
+	}
+	label := r.Enricher.Region(ip)
+	if label == "" {
+		return RegionUnknown
+	}
+	return label
+}
+
+// counters returns counters of the client region
+func (r *RegionStats) counters(ip net.IP) *regionCounters {
+	if r == nil {
+		return nil
+	}
+	label := r.label(ip)
+	r.RLock()
+	c, found := r.regions[label]
+	r.RUnlock()
+	if found {
+		return c
+	}
+	r.Lock()
+	defer r.Unlock()
+	if c, found = r.regions[label]; found {
+		return c
+	}
+	if r.MaxRegions > 0 && len(r.regions) >= r.MaxRegions {
+		label = RegionOther
+		if c, found = r.regions[label]; found {
+			return c
+		}
+	}
+	c = &regionCounters{}
+	r.regions[label] = c
+	return c
+}
+
+// Report returns counters of every region seen so far
+func (r *RegionStats) Report() map[string]RegionCount {
+	r.RLock()
+	defer r.RUnlock()
+	res := make(map[string]RegionCount, len(r.regions))
+	for label, c := range r.regions {
+		res[label] = RegionCount{
+			Requests:    atomic.LoadInt64(&c.requests),
+			Responses:   atomic.LoadInt64(&c.responses),
+			ACLDenied:   atomic.LoadInt64(&c.aclDenied),
+			RateLimited: atomic.LoadInt64(&c.rateLimited),
+		}
+	}
+	return res
+}
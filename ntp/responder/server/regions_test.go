@@ -0,0 +1,74 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrefixEnricher(t *testing.T) {
+	e, err := ParsePrefixEnricher(strings.NewReader(`
+# pops
+2401:db00::/32 apac
+2401:db00:1::/48 sin1
+10.0.0.0/8     emea
+192.168.0.1    lab
+`))
+	require.NoError(t, err)
+	require.Equal(t, "sin1", e.Region(net.ParseIP("2401:db00:1::1")))
+	require.Equal(t, "apac", e.Region(net.ParseIP("2401:db00:2::1")))
+	require.Equal(t, "emea", e.Region(net.ParseIP("10.1.2.3")))
+	require.Equal(t, "lab", e.Region(net.ParseIP("192.168.0.1")))
+	require.Equal(t, "", e.Region(net.ParseIP("192.168.0.2")))
+
+	_, err = ParsePrefixEnricher(strings.NewReader("10.0.0.0/8"))
+	require.Error(t, err)
+	_, err = ParsePrefixEnricher(strings.NewReader("10.0.0.0/33 emea"))
+	require.Error(t, err)
+}
+
+type fakeEnricher map[string]string
+
+func (f fakeEnricher) Region(ip net.IP) string {
+	return f[ip.String()]
+}
+
+func TestRegionStats(t *testing.T) {
+	r := NewRegionStats(fakeEnricher{"10.0.0.1": "emea", "10.0.0.2": "apac", "10.0.0.3": "amer"}, 3)
+	r.counters(net.ParseIP("10.0.0.1")).incRequests()
+	r.counters(net.ParseIP("10.0.0.1")).incResponses()
+	r.counters(net.ParseIP("10.0.0.2")).incRateLimited()
+	r.counters(net.ParseIP("10.0.0.9")).incACLDenied()
+	r.counters(nil).incRequests()
+	// limit is reached
+	r.counters(net.ParseIP("10.0.0.3")).incRequests()
+
+	require.Equal(t, map[string]RegionCount{
+		"emea":        {Requests: 1, Responses: 1},
+		"apac":        {RateLimited: 1},
+		RegionUnknown: {Requests: 1, ACLDenied: 1},
+		RegionOther:   {Requests: 1},
+	}, r.Report())
+
+	// disabled
+	var nilStats *RegionStats
+	nilStats.counters(net.ParseIP("10.0.0.1")).incRequests()
+}
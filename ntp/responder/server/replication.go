@@ -0,0 +1,299 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// replicationMagic starts every replication packet
+const replicationMagic = "NTRS"
+
+const replicationVersion = 2
+
+const (
+	// header is magic, version, reserved byte, number of entries, sequence and send time
+	replicationHeaderSize = 24
+	replicationEntrySize  = 32
+	// replicationMACSize is the size of HMAC-SHA256 trailing the packet
+	replicationMACSize = sha256.Size
+	// replicationMaxEntries keeps packets below typical MTU
+	replicationMaxEntries = (1400 - replicationHeaderSize - replicationMACSize) / replicationEntrySize
+	// replicationMaxSkew is how far the send time of accepted packets may be from the local time
+	replicationMaxSkew = 30 * time.Second
+)
+
+var (
+	errReplicationPacket  = errors.New("malformed replication packet")
+	errReplicationVersion = errors.New("unsupported replication version")
+	errReplicationAuth    = errors.New("replication packet authentication failed")
+	errReplicationReplay  = errors.New("replayed or stale replication packet")
+	errReplicationKey     = errors.New("replication key is not set")
+)
+
+// Replication sends rate limiter client state to peer instances and merges state received from them,
+// so failover between redundant servers doesn't reset rate counters. Only the state changed since
+// the previous send is replicated. Servers don't keep interleaved mode state, so there is nothing else to replicate.
+// Packets are authenticated with HMAC-SHA256 using the shared Key, replays are rejected by sequence and send time
+type Replication struct {
+	// Listen is the address to receive state on. Empty disables receiving
+	Listen string
+	// Peers are host:port to send state to. State is accepted from their IPs only
+	Peers []string
+	// Interval between sends
+	Interval time.Duration
+	// Key is the secret shared by all peers
+	Key []byte
+
+	limiter *RateLimiter
+	peers   []*net.UDPAddr
+	// seq is the sequence of the next packet sent. It starts from the start time, so it keeps growing across restarts
+	seq uint64
+	// received holds the last sequence accepted from each peer IP
+	received map[string]uint64
+}
+
+// replicationPacket is a batch of client states
+type replicationPacket struct {
+	Seq    uint64
+	Time   time.Time
+	States []ClientState
+}
+
+// replicationMAC returns HMAC-SHA256 of b
+func replicationMAC(key, b []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(b)
+	return m.Sum(nil)
+}
+
+// encodeClientStates packs client states into authenticated packets numbered from seq
+func encodeClientStates(key []byte, seq uint64, now time.Time, states []ClientState) [][]byte {
+	packets := [][]byte{}
+	for len(states) > 0 {
+		n := len(states)
+		if n > replicationMaxEntries {
+			n = replicationMaxEntries
+		}
+		size := replicationHeaderSize + n*replicationEntrySize
+		b := make([]byte, size, size+replicationMACSize)
+		copy(b, replicationMagic)
+		b[4] = replicationVersion
+		binary.BigEndian.PutUint16(b[6:], uint16(n))
+		binary.BigEndian.PutUint64(b[8:], seq)
+		binary.BigEndian.PutUint64(b[16:], uint64(now.UnixNano()))
+		for i, st := range states[:n] {
+			e := b[replicationHeaderSize+i*replicationEntrySize:]
+			copy(e, st.IP.To16())
+			binary.BigEndian.PutUint64(e[16:], math.Float64bits(st.Tokens))
+			binary.BigEndian.PutUint64(e[24:], uint64(st.Last.UnixNano()))
+		}
+		packets = append(packets, append(b, replicationMAC(key, b)...))
+		states = states[n:]
+		seq++
+	}
+	return packets
+}
+
+// decodeClientStates verifies and unpacks client states from the packet
+func decodeClientStates(key []byte, b []byte) (*replicationPacket, error) {
+	if len(b) < replicationHeaderSize+replicationMACSize || string(b[:4]) != replicationMagic {
+		return nil, errReplicationPacket
+	}
+	if b[4] != replicationVersion {
+		return nil, fmt.Errorf("%w: %d", errReplicationVersion, b[4])
+	}
+	n := int(binary.BigEndian.Uint16(b[6:]))
+	size := replicationHeaderSize + n*replicationEntrySize
+	if len(b) != size+replicationMACSize {
+		return nil, errReplicationPacket
+	}
+	if !hmac.Equal(b[size:], replicationMAC(key, b[:size])) {
+		return nil, errReplicationAuth
+	}
+	p := &replicationPacket{
+		Seq:    binary.BigEndian.Uint64(b[8:]),
+		Time:   time.Unix(0, int64(binary.BigEndian.Uint64(b[16:]))),
+		States: make([]ClientState, n),
+	}
+	for i := range p.States {
+		e := b[replicationHeaderSize+i*replicationEntrySize:]
+		p.States[i] = ClientState{
+			IP:     net.IP(append([]byte{}, e[:16]...)),
+			Tokens: math.Float64frombits(binary.BigEndian.Uint64(e[16:])),
+			Last:   time.Unix(0, int64(binary.BigEndian.Uint64(e[24:]))),
+		}
+	}
+	return p, nil
+}
+
+// accept checks the packet from the peer is fresh and newer than anything accepted from it before
+func (r *Replication) accept(addr *net.UDPAddr, p *replicationPacket, now time.Time) error {
+	skew := now.Sub(p.Time)
+	if skew > replicationMaxSkew || skew < -replicationMaxSkew {
+		return fmt.Errorf("%w: sent at %v", errReplicationReplay, p.Time)
+	}
+	peer := string(addr.IP.To16())
+	if last, ok := r.received[peer]; ok && p.Seq <= last {
+		return fmt.Errorf("%w: sequence %d, last %d", errReplicationReplay, p.Seq, last)
+	}
+	r.received[peer] = p.Seq
+	return nil
+}
+
+// ReadReplicationKey reads the replication key from the file, surrounding whitespace is ignored
+func ReadReplicationKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errReplicationKey
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(b)
+	if len(key) == 0 {
+		return nil, errReplicationKey
+	}
+	return key, nil
+}
+
+// resolvePeers resolves peer addresses
+func (r *Replication) resolvePeers() error {
+	r.peers = nil
+	for _, p := range r.Peers {
+		addr, err := net.ResolveUDPAddr("udp", p)
+		if err != nil {
+			return fmt.Errorf("resolving peer %s: %w", p, err)
+		}
+		r.peers = append(r.peers, addr)
+	}
+	return nil
+}
+
+// fromPeer returns true if the address belongs to one of the peers
+func (r *Replication) fromPeer(addr *net.UDPAddr) bool {
+	for _, p := range r.peers {
+		if p.IP.Equal(addr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run replicates state of the limiter until ctx is done
+func (r *Replication) Run(ctx context.Context, limiter *RateLimiter) error {
+	if len(r.Key) == 0 {
+		return errReplicationKey
+	}
+	if err := r.resolvePeers(); err != nil {
+		return err
+	}
+	laddr := &net.UDPAddr{}
+	if r.Listen != "" {
+		var err error
+		if laddr, err = net.ResolveUDPAddr("udp", r.Listen); err != nil {
+			return err
+		}
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return err
+	}
+	r.limiter = limiter
+	return r.run(ctx, conn)
+}
+
+func (r *Replication) run(ctx context.Context, conn *net.UDPConn) error {
+	r.seq = uint64(time.Now().UnixNano())
+	r.received = map[string]uint64{}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	if r.Listen != "" {
+		go r.receive(conn)
+	}
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	var since time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			now := time.Now()
+			r.send(conn, r.limiter.Changed(since))
+			since = now
+		}
+	}
+}
+
+// send sends state to all peers
+func (r *Replication) send(conn *net.UDPConn, states []ClientState) {
+	packets := encodeClientStates(r.Key, r.seq, time.Now(), states)
+	r.seq += uint64(len(packets))
+	for _, b := range packets {
+		for _, p := range r.peers {
+			if _, err := conn.WriteToUDP(b, p); err != nil {
+				log.Debugf("[replication] failed to send to %s: %v", p, err)
+			}
+		}
+	}
+}
+
+// receive merges state received from peers until conn is closed
+func (r *Replication) receive(conn *net.UDPConn) {
+	b := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFromUDP(b)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Debugf("[replication] read error: %v", err)
+			continue
+		}
+		if !r.fromPeer(addr) {
+			log.Debugf("[replication] ignoring state from %s", addr)
+			continue
+		}
+		p, err := decodeClientStates(r.Key, b[:n])
+		if err != nil {
+			log.Debugf("[replication] bad packet from %s: %v", addr, err)
+			continue
+		}
+		now := time.Now()
+		if err := r.accept(addr, p, now); err != nil {
+			log.Debugf("[replication] rejecting packet from %s: %v", addr, err)
+			continue
+		}
+		r.limiter.Merge(p.States, now)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testReplicationKey = []byte("correct horse battery staple")
+
+func TestEncodeClientStates(t *testing.T) {
+	now := time.Unix(1585231321, 42)
+	states := []ClientState{}
+	for i := 0; i < replicationMaxEntries+1; i++ {
+		states = append(states, ClientState{IP: net.IPv4(192, 0, 2, byte(i)).To16(), Tokens: float64(i) / 2, Last: now})
+	}
+	packets := encodeClientStates(testReplicationKey, 42, now, states)
+	require.Len(t, packets, 2)
+
+	decoded := []ClientState{}
+	for i, b := range packets {
+		require.LessOrEqual(t, len(b), 1400)
+		p, err := decodeClientStates(testReplicationKey, b)
+		require.NoError(t, err)
+		require.Equal(t, uint64(42+i), p.Seq)
+		require.True(t, now.Equal(p.Time))
+		decoded = append(decoded, p.States...)
+	}
+	require.Len(t, decoded, len(states))
+	for i := range states {
+		require.True(t, states[i].IP.Equal(decoded[i].IP))
+		require.Equal(t, states[i].Tokens, decoded[i].Tokens)
+		require.True(t, states[i].Last.Equal(decoded[i].Last))
+	}
+	require.Empty(t, encodeClientStates(testReplicationKey, 0, now, nil))
+}
+
+func TestDecodeClientStatesErrors(t *testing.T) {
+	b := encodeClientStates(testReplicationKey, 1, time.Now(), []ClientState{{IP: net.ParseIP("fd00::1"), Last: time.Now()}})[0]
+
+	_, err := decodeClientStates(testReplicationKey, b[:10])
+	require.ErrorIs(t, err, errReplicationPacket)
+	_, err = decodeClientStates(testReplicationKey, b[:len(b)-1])
+	require.ErrorIs(t, err, errReplicationPacket)
+	_, err = decodeClientStates(testReplicationKey, []byte("hello world"))
+	require.ErrorIs(t, err, errReplicationPacket)
+
+	_, err = decodeClientStates([]byte("wrong key"), b)
+	require.ErrorIs(t, err, errReplicationAuth)
+
+	// tampering is detected
+	tampered := append([]byte{}, b...)
+	tampered[replicationHeaderSize+16]++
+	_, err = decodeClientStates(testReplicationKey, tampered)
+	require.ErrorIs(t, err, errReplicationAuth)
+
+	b[4] = 42
+	_, err = decodeClientStates(testReplicationKey, b)
+	require.ErrorIs(t, err, errReplicationVersion)
+}
+
+func TestReplicationAccept(t *testing.T) {
+	now := time.Unix(1585231321, 0)
+	r := &Replication{received: map[string]uint64{}}
+	peer := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4242}
+	other := &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: 4242}
+
+	require.NoError(t, r.accept(peer, &replicationPacket{Seq: 10, Time: now}, now))
+	// replayed and older packets are rejected
+	require.ErrorIs(t, r.accept(peer, &replicationPacket{Seq: 10, Time: now}, now), errReplicationReplay)
+	require.ErrorIs(t, r.accept(peer, &replicationPacket{Seq: 9, Time: now}, now), errReplicationReplay)
+	require.NoError(t, r.accept(peer, &replicationPacket{Seq: 11, Time: now}, now))
+	// sequences are tracked per peer
+	require.NoError(t, r.accept(other, &replicationPacket{Seq: 1, Time: now}, now))
+
+	// packets sent too long ago or in the future are rejected
+	require.ErrorIs(t, r.accept(peer, &replicationPacket{Seq: 12, Time: now.Add(-time.Minute)}, now), errReplicationReplay)
+	require.ErrorIs(t, r.accept(peer, &replicationPacket{Seq: 12, Time: now.Add(time.Minute)}, now), errReplicationReplay)
+	require.NoError(t, r.accept(peer, &replicationPacket{Seq: 12, Time: now.Add(time.Second)}, now))
+}
+
+func TestReplication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	standbyConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	primaryConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+
+	primary := &Replication{Peers: []string{standbyConn.LocalAddr().String()}, Interval: 10 * time.Millisecond, Key: testReplicationKey, limiter: NewRateLimiter(1, 1)}
+	standby := &Replication{Listen: standbyConn.LocalAddr().String(), Peers: []string{primaryConn.LocalAddr().String()}, Interval: time.Hour, Key: testReplicationKey, limiter: NewRateLimiter(1, 1)}
+	require.NoError(t, primary.resolvePeers())
+	require.NoError(t, standby.resolvePeers())
+	go standby.run(ctx, standbyConn)
+	go primary.run(ctx, primaryConn)
+
+	client := net.ParseIP("192.0.2.1")
+	now := time.Now()
+	require.True(t, primary.limiter.Allow(client, now))
+	require.Eventually(t, func() bool { return standby.limiter.Len() == 1 }, time.Second, 10*time.Millisecond)
+	// failover keeps the client limited
+	require.False(t, standby.limiter.Allow(client, now))
+}
+
+func TestReplicationFromPeer(t *testing.T) {
+	r := &Replication{Peers: []string{"127.0.0.1:4242"}}
+	require.NoError(t, r.resolvePeers())
+	require.True(t, r.fromPeer(&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}))
+	require.False(t, r.fromPeer(&net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: 4242}))
+
+	r.Peers = []string{"nope:port"}
+	require.Error(t, r.resolvePeers())
+}
+
+func TestReplicationRequiresKey(t *testing.T) {
+	r := &Replication{Peers: []string{"127.0.0.1:4242"}, Interval: time.Second}
+	require.ErrorIs(t, r.Run(context.Background(), NewRateLimiter(1, 1)), errReplicationKey)
+}
+
+func TestReadReplicationKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(path, []byte("secret\n"), 0600))
+	key, err := ReadReplicationKey(path)
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), key)
+
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, ioutil.WriteFile(empty, []byte(" \n"), 0600))
+	_, err = ReadReplicationKey(empty)
+	require.ErrorIs(t, err, errReplicationKey)
+	_, err = ReadReplicationKey("")
+	require.ErrorIs(t, err, errReplicationKey)
+	_, err = ReadReplicationKey(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
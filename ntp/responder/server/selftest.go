@@ -0,0 +1,148 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/facebook/time/ntp/prober"
+	log "github.com/sirupsen/logrus"
+)
+
+// SelfTest periodically queries the server's own listening address via the client path
+// and alarms if the offset or stratum of the responses diverges from expectations,
+// catching broken timestamping or reference drift from inside the process.
+// Nil SelfTest disables self-testing
+type SelfTest struct {
+	// Address to query, such as 127.0.0.1:123. It must be allowed by ACL and rate limiter
+	Address string
+	// Iface to take hardware or software timestamps on. Taken in userspace if empty
+	Iface    string
+	Interval time.Duration
+	Timeout  time.Duration
+	// MaxOffset is the max abs difference between the measured and the expected offset
+	MaxOffset time.Duration
+	// Failures is how many consecutive failed probes raise the alarm
+	Failures int
+
+	// expect returns stratum and offset the server is supposed to respond with
+	expect func() (int, time.Duration)
+	probe  func() *prober.Result
+
+	sync.Mutex
+	report SelfTestReport
+}
+
+// SelfTestReport is a snapshot of the self-test results
+type SelfTestReport struct {
+	Probes      int64         `json:"probes"`
+	Failures    int64         `json:"failures"`
+	Consecutive int           `json:"consecutive_failures"`
+	Alarm       bool          `json:"alarm"`
+	LastProbe   time.Time     `json:"last_probe"`
+	Offset      time.Duration `json:"offset_ns"`
+	Delay       time.Duration `json:"delay_ns"`
+	Stratum     uint8         `json:"stratum"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// NewSelfTest returns SelfTest querying the address every interval
+func NewSelfTest(address string, interval, timeout, maxOffset time.Duration) *SelfTest {
+	return &SelfTest{
+		Address:   address,
+		Interval:  interval,
+		Timeout:   timeout,
+		MaxOffset: maxOffset,
+		Failures:  3,
+	}
+}
+
+// check probes the server once and returns the divergence, if any
+func (t *SelfTest) check() (*prober.Result, error) {
+	r := t.probe()
+	if !r.Reachable {
+		return r, fmt.Errorf("probe failed: %s", r.Error)
+	}
+	stratum, offset := t.expect()
+	if int(r.Stratum) != stratum {
+		return r, fmt.Errorf("stratum %d, expected %d", r.Stratum, stratum)
+	}
+	diff := r.Offset - offset
+	if t.MaxOffset > 0 && (diff > t.MaxOffset || diff < -t.MaxOffset) {
+		return r, fmt.Errorf("offset %v, expected %v within %v", r.Offset, offset, t.MaxOffset)
+	}
+	return r, nil
+}
+
+// run probes the server once and updates the report, logging alarm transitions
+func (t *SelfTest) run() {
+	r, err := t.check()
+	t.Lock()
+	defer t.Unlock()
+	t.report.Probes++
+	t.report.LastProbe = r.Time
+	t.report.Offset = r.Offset
+	t.report.Delay = r.Delay
+	t.report.Stratum = r.Stratum
+	if err == nil {
+		if t.report.Alarm {
+			log.Warningf("[selftest] %s recovered", t.Address)
+		}
+		t.report.Consecutive = 0
+		t.report.Alarm = false
+		t.report.Error = ""
+		return
+	}
+	log.Debugf("[selftest] %s: %v", t.Address, err)
+	t.report.Failures++
+	t.report.Consecutive++
+	t.report.Error = err.Error()
+	if !t.report.Alarm && t.report.Consecutive >= t.Failures {
+		t.report.Alarm = true
+		log.Errorf("[selftest] %s diverged for %d consecutive probes: %v", t.Address, t.report.Consecutive, err)
+	}
+}
+
+// Run probes the server every Interval until ctx is done
+func (t *SelfTest) Run(ctx context.Context) {
+	if t.probe == nil {
+		p := prober.New(prober.Config{Timeout: t.Timeout, Iface: t.Iface})
+		t.probe = func() *prober.Result { return p.Probe(t.Address) }
+	}
+	log.Infof("[selftest] probing %s every %v", t.Address, t.Interval)
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.run()
+		}
+	}
+}
+
+// Report returns current self-test results
+func (t *SelfTest) Report() *SelfTestReport {
+	t.Lock()
+	defer t.Unlock()
+	r := t.report
+	return &r
+}
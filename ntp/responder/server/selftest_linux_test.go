@@ -0,0 +1,70 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/facebook/time/clock"
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/responder/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTestLoopback(t *testing.T) {
+	conn, err := listen(net.ParseIP("127.0.0.1"), 0, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, ntp.EnableKernelTimestampsSocket(conn))
+	require.NoError(t, ntp.EnablePacketInfo(conn))
+
+	s := &Server{Stratum: 1, RefID: "TEST", Workers: 1, Stats: &stats.JSONStats{}}
+	s.tasks = make(chan task, 1)
+	go func() {
+		defer close(s.tasks)
+		for {
+			request, ext, received, addr, dst, err := ntp.ReadPacketWithExtensions(conn)
+			if err != nil {
+				return
+			}
+			s.tasks <- task{conn: conn, addr: addr, dst: dst, received: received, request: request, ext: ext, stats: s.Stats}
+		}
+	}()
+	go func() {
+		static := &ntp.Packet{}
+		s.fillStaticHeaders(static)
+		response := newResponseTemplate(static)
+		for task := range s.tasks {
+			task.serve(response, clock.System{}, 0, &s.Smear)
+		}
+	}()
+
+	st := NewSelfTest(conn.LocalAddr().String(), time.Millisecond, time.Second, 10*time.Millisecond)
+	s.SelfTest = st
+	st.expect = func() (int, time.Duration) { return s.CurrentStratum(), s.ExtraOffset }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go st.Run(ctx)
+	require.Eventually(t, func() bool { return st.Report().Probes > 0 }, time.Second, 5*time.Millisecond)
+	r := st.Report()
+	require.Empty(t, r.Error)
+	require.False(t, r.Alarm)
+	require.Equal(t, uint8(1), r.Stratum)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/facebook/time/ntp/prober"
+	"github.com/stretchr/testify/require"
+)
+
+func selfTest(results ...*prober.Result) *SelfTest {
+	t := NewSelfTest("127.0.0.1:123", time.Second, time.Second, time.Millisecond)
+	t.expect = func() (int, time.Duration) { return 1, time.Second }
+	t.probe = func() *prober.Result {
+		r := results[0]
+		if len(results) > 1 {
+			results = results[1:]
+		}
+		return r
+	}
+	return t
+}
+
+func TestSelfTestCheck(t *testing.T) {
+	ok := &prober.Result{Reachable: true, Stratum: 1, Offset: time.Second + 100*time.Microsecond}
+	_, err := selfTest(ok).check()
+	require.NoError(t, err)
+
+	_, err = selfTest(&prober.Result{Reachable: true, Stratum: 2, Offset: time.Second}).check()
+	require.EqualError(t, err, "stratum 2, expected 1")
+
+	_, err = selfTest(&prober.Result{Reachable: true, Stratum: 1}).check()
+	require.EqualError(t, err, "offset 0s, expected 1s within 1ms")
+
+	_, err = selfTest(&prober.Result{Error: "timeout"}).check()
+	require.EqualError(t, err, "probe failed: timeout")
+}
+
+func TestSelfTestAlarm(t *testing.T) {
+	bad := &prober.Result{Reachable: true, Stratum: 2, Offset: time.Second}
+	good := &prober.Result{Reachable: true, Stratum: 1, Offset: time.Second}
+	st := selfTest(bad, bad, bad, bad, good)
+	for i := 0; i < 2; i++ {
+		st.run()
+		require.False(t, st.Report().Alarm)
+	}
+	st.run()
+	r := st.Report()
+	require.True(t, r.Alarm)
+	require.Equal(t, 3, r.Consecutive)
+	require.Equal(t, "stratum 2, expected 1", r.Error)
+
+	st.run()
+	require.True(t, st.Report().Alarm)
+
+	st.run()
+	r = st.Report()
+	require.False(t, r.Alarm)
+	require.Equal(t, int64(5), r.Probes)
+	require.Equal(t, int64(4), r.Failures)
+	require.Equal(t, 0, r.Consecutive)
+	require.Empty(t, r.Error)
+}
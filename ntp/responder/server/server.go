@@ -23,61 +23,255 @@ package server
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/facebook/time/clock"
 	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/privsep"
 	log "github.com/sirupsen/logrus"
 )
 
+var (
+	errNoACL         = errors.New("acl is not configured")
+	errInvalidFormat = errors.New("invalid packet format")
+)
+
 // task is a data structure with everything needed to work independently on NTP packet.
 type task struct {
-	conn     net.PacketConn
-	addr     net.Addr
+	conn *net.UDPConn
+	addr *net.UDPAddr
+	// pc and from are set instead of conn and addr for requests from experimental transports
+	pc   net.PacketConn
+	from net.Addr
+	// delay before sending the response in impairment test mode
+	delay time.Duration
+	// dst is the address request arrived on. Response is sent from it
+	dst net.IP
+	// oob are extra control messages of the response, such as flow label
+	oob      []byte
 	received time.Time
-	request  *ntp.Packet
-	stats    Stats
+	// read is when the kernel timestamped request was read in userspace
+	read    time.Time
+	request *ntp.Packet
+	// ext are raw extension fields following the request header
+	ext     []byte
+	stats   Stats
+	audit   *Audit
+	nts     *NTS
+	padding *PaddingConfig
+	// trace is set for sampled requests
+	trace  *Trace
+	tracer *Tracer
+	// region counts responses to the client region if region stats are enabled
+	region *regionCounters
+	// canary is the offset served to canary clients
+	canary time.Duration
 }
 
 // Server is a type for UDP server which handles connections.
 type Server struct {
+	// keep these first, so they are aligned to 64-bit for sync/atomic on 32-bit platforms
+	// headersVersion is bumped every time static headers need to be refilled by workers
+	headersVersion  int64
+	stratumOverride int64
+	drained         int64
+	// ExtraOffset is read by workers atomically, as it can be changed by config reload
+	ExtraOffset time.Duration
+
 	ListenConfig ListenConfig
+	Broadcast    BroadcastConfig
+	Smear        SmearConfig
 	Workers      int
 	Announce     Announce
 	Stats        Stats
 	Checker      Checker
+	ACL          *ACL
+	RateLimiter  *RateLimiter
+	Audit        *Audit
+	ReadLatency  *ntp.ReadLatency
+	Replication  *Replication
+	NTS          *NTS
+	Transport    TransportConfig
+	Impair       ImpairConfig
+	Affinity     AffinityConfig
+	Padding      PaddingConfig
+	SelfTest     *SelfTest
+	Tracer       *Tracer
+	Regions      *RegionStats
+	Canary       *Canary
+	Systemd      *Systemd
+	Privileges   *privsep.Config
+	Clock        clock.Clock
 	tasks        chan task
-	ExtraOffset  time.Duration
 	RefID        string
 	Stratum      int
+
+	// headersLock protects values used by fillStaticHeaders on reload
+	// clock is Clock resolved on Start, never nil once started
+	clock clock.Clock
+
+	headersLock sync.RWMutex
+	// reloadLock serializes config reloads
+	reloadLock sync.Mutex
+	config     *FileConfig
+}
+
+// Drain withdraws announcement of the IPs. Server keeps answering requests
+func (s *Server) Drain() {
+	atomic.StoreInt64(&s.drained, 1)
+	log.Warning("[server] draining")
+	if err := s.Announce.Withdraw(); err != nil {
+		log.Errorf("[server] failed to withdraw announce: %v", err)
+	}
+	s.Stats.ResetAnnounce()
+}
+
+// Undrain allows announcement of the IPs again
+func (s *Server) Undrain() {
+	log.Warning("[server] undraining")
+	atomic.StoreInt64(&s.drained, 0)
+}
+
+// Drained returns true if server is drained
+func (s *Server) Drained() bool {
+	return atomic.LoadInt64(&s.drained) == 1
+}
+
+// SetStratum overrides configured stratum in runtime. 0 resets the override
+func (s *Server) SetStratum(stratum int) {
+	atomic.StoreInt64(&s.stratumOverride, int64(stratum))
+	atomic.AddInt64(&s.headersVersion, 1)
+}
+
+// CurrentStratum returns stratum server is responding with
+func (s *Server) CurrentStratum() int {
+	s.headersLock.RLock()
+	defer s.headersLock.RUnlock()
+	return s.currentStratum()
+}
+
+// currentStratum must be called with headersLock held
+func (s *Server) currentStratum() int {
+	if o := atomic.LoadInt64(&s.stratumOverride); o != 0 {
+		return int(o)
+	}
+	return s.Stratum
+}
+
+// extraOffset returns extra offset of served time
+func (s *Server) extraOffset() time.Duration {
+	return time.Duration(atomic.LoadInt64((*int64)(&s.ExtraOffset)))
+}
+
+// ReloadACL re-reads ACL from disk
+func (s *Server) ReloadACL() error {
+	if s.ACL == nil || s.ACL.Path == "" {
+		return errNoACL
+	}
+	return s.ACL.Load()
 }
 
 // Start UDP server.
 func (s *Server) Start(ctx context.Context, cancelFunc context.CancelFunc) {
+	s.clock = clock.Default(s.Clock)
 	log.Infof("Creating %d goroutine workers", s.Workers)
 	s.tasks = make(chan task, s.Workers)
 	// Pre-create workers
 	for i := 0; i < s.Workers; i++ {
-		go s.startWorker()
+		go func(cpu int) {
+			if cpu >= 0 {
+				if err := pinThread(cpu); err != nil {
+					log.Errorf("[server] failed to pin worker to cpu %d: %v", cpu, err)
+				}
+			}
+			s.startWorker()
+		}(s.Affinity.workerCPU(i))
 	}
 
-	log.Infof("Starting %d listener(s)", len(s.ListenConfig.IPs))
+	if s.Canary != nil {
+		go s.Canary.Run(ctx)
+	}
 
-	for _, ip := range s.ListenConfig.IPs {
-		log.Infof("Starting listener on %s:%d", ip.String(), s.ListenConfig.Port)
+	// listening sockets are open and set up once opened is done
+	var opened sync.WaitGroup
+	if s.Systemd.Activated() {
+		log.Infof("Starting %d listener(s) on sockets passed by systemd", len(s.Systemd.Listeners))
+		for i, conn := range s.Systemd.Listeners {
+			log.Infof("Starting listener on %s", conn.LocalAddr())
+			opened.Add(1)
+			go s.runListener(conn, conn.LocalAddr().(*net.UDPAddr).IP, s.Affinity.listenerCPU(i), &opened)
+		}
+	} else {
+		log.Infof("Starting %d listener(s)", len(s.ListenConfig.IPs))
+		for i, ip := range s.ListenConfig.IPs {
+			log.Infof("Starting listener on %s:%d", ip.String(), s.ListenConfig.Port)
+			opened.Add(1)
+			go s.runListener(nil, ip, s.Affinity.listenerCPU(i), &opened)
+		}
+	}
 
-		go func(ip net.IP) {
-			s.Stats.IncListeners()
-			// Need to be sure IP is on interface:
-			if err := s.addIPToInterface(ip); err != nil {
-				log.Errorf("[server]: %v", err)
+	if s.Transport.Network != "" {
+		conn, err := s.listenTransport()
+		if err != nil {
+			log.Fatalf("listening error: %s", err)
+		}
+		go s.startTransportListener(conn)
+	}
+
+	if s.Broadcast.Interval > 0 {
+		go s.startBroadcaster(ctx)
+	}
+
+	if s.Audit != nil {
+		s.Audit.static = s.fillStaticHeaders
+		s.Audit.AddSource("ratelimit", s.RateLimiter.Len)
+		go s.Audit.Run(ctx)
+	}
+
+	if s.SelfTest != nil {
+		s.SelfTest.expect = func() (int, time.Duration) {
+			return s.CurrentStratum(), s.extraOffset() + s.Impair.Offset
+		}
+		go s.SelfTest.Run(ctx)
+	}
+
+	if s.NTS != nil {
+		go func() {
+			if err := s.NTS.Run(ctx); err != nil {
+				log.Errorf("[server] NTS-KE stopped: %v", err)
+				cancelFunc()
+			}
+		}()
+	}
+
+	if s.Replication != nil {
+		go func() {
+			if err := s.Replication.Run(ctx, s.RateLimiter); err != nil && ctx.Err() == nil {
+				log.Errorf("[server] replication stopped: %v", err)
 			}
+		}()
+	}
 
-			s.startListener(ip, s.ListenConfig.Port)
-			s.Stats.DecListeners()
-		}(ip)
+	// nothing privileged is opened past this point
+	opened.Wait()
+	if s.Privileges.Enabled() {
+		// IPs added to the interface are deleted on Stop
+		if s.managesIPs() {
+			s.Privileges.Keep(privsep.CapNetAdmin)
+		}
+		if err := s.Privileges.Drop(); err != nil {
+			log.Fatalf("[server] %v", err)
+		}
+		log.Infof("[server] dropped privileges to %s, keeping %v", s.Privileges.User, s.Privileges.Capabilities)
 	}
+	s.Systemd.notify("READY=1")
+	go s.Systemd.RunWatchdog(ctx, s.Checker.Check)
 
 	// Run checker periodically
 	go func() {
@@ -98,7 +292,7 @@ func (s *Server) Start(ctx context.Context, cancelFunc context.CancelFunc) {
 		case <-ctx.Done():
 			break
 		case <-time.After(30 * time.Second):
-			if s.ListenConfig.ShouldAnnounce {
+			if s.ListenConfig.ShouldAnnounce && !s.Drained() {
 				// First run will be 30 seconds delayed
 				log.Debug("Requesting VIPs announce")
 				err := s.Announce.Advertise(s.ListenConfig.IPs)
@@ -115,40 +309,248 @@ func (s *Server) Start(ctx context.Context, cancelFunc context.CancelFunc) {
 	}
 }
 
-// Stop will stop announcement, delete IPs from interfaces
+// Stop will stop announcement, delete IPs from interfaces unless sockets were passed by systemd
 func (s *Server) Stop() {
+	s.Systemd.notify("STOPPING=1")
 	if err := s.Announce.Withdraw(); err != nil {
 		log.Errorf("[server] failed to withdraw announce: %v", err)
 	}
-	s.DeleteAllIPs()
+	// addresses of activated sockets are managed by the unit
+	if !s.Systemd.Activated() {
+		s.DeleteAllIPs()
+	}
 }
 
-func (s *Server) startListener(ip net.IP, port int) {
-	s.Checker.IncListeners()
-	defer s.Checker.DecListeners()
+// managesIPs returns whether listening IPs are added to the interface by the server
+func (s *Server) managesIPs() bool {
+	if s.Systemd.Activated() {
+		return false
+	}
+	for _, ip := range s.ListenConfig.IPs {
+		if !ip.IsMulticast() {
+			return true
+		}
+	}
+	return false
+}
 
-	// listen to incoming udp ntp.
-	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port})
-	if err != nil {
-		log.Fatalf("listening error: %s", err)
+// runListener opens the socket unless it is passed via systemd socket activation, sets it up and serves requests.
+// opened is done once the socket is set up, so privileges can be dropped
+func (s *Server) runListener(conn *net.UDPConn, ip net.IP, cpu int, opened *sync.WaitGroup) {
+	if cpu >= 0 {
+		if err := pinThread(cpu); err != nil {
+			log.Errorf("[server] failed to pin listener on %s to cpu %d: %v", ip, cpu, err)
+		}
+	}
+	if conn == nil {
+		// Need to be sure IP is on interface. Multicast groups (manycast) are joined instead
+		if !ip.IsMulticast() {
+			if err := s.addIPToInterface(ip); err != nil {
+				log.Errorf("[server]: %v", err)
+			}
+		}
+
+		// listen to incoming udp ntp.
+		var err error
+		conn, err = listen(ip, s.ListenConfig.Port, s.ListenConfig.Iface)
+		if err != nil {
+			log.Fatalf("listening error: %s", err)
+		}
+	}
+	oob := s.setupListener(conn, ip, cpu)
+	opened.Done()
+	s.startListener(conn, oob)
+}
+
+// setupListener sets socket options of the listener. Some of them, like forced buffer sizes, need privileges.
+// It returns control messages to send responses with
+func (s *Server) setupListener(conn *net.UDPConn, ip net.IP, cpu int) []byte {
+	if s.Affinity.IncomingCPU && cpu >= 0 {
+		if err := setIncomingCPU(conn, cpu); err != nil {
+			log.Errorf("[server] failed to set incoming cpu %d on %s: %v", cpu, ip, err)
+		}
 	}
-	defer conn.Close()
 
 	// Allow reading of kernel timestamps via socket
 	if err := ntp.EnableKernelTimestampsSocket(conn); err != nil {
 		log.Fatalf("enabling timestamp error: %s", err)
 	}
 
-	for {
+	// Allow reading of destination address to reply from it
+	if err := ntp.EnablePacketInfo(conn); err != nil {
+		log.Fatalf("enabling packet info error: %s", err)
+	}
+
+	opts := []ntp.SocketOption{}
+	if s.ListenConfig.HopLimit > 0 {
+		opts = append(opts, ntp.WithHopLimit(s.ListenConfig.HopLimit))
+	}
+	var oob []byte
+	if s.ListenConfig.FlowLabel != 0 && ip.To4() == nil {
+		opts = append(opts, ntp.WithFlowLabel(s.ListenConfig.FlowLabel))
+		oob = ntp.FlowLabelControlMessage(s.ListenConfig.FlowLabel)
+	}
+	if err := ntp.TuneSocket(conn, opts...); err != nil {
+		log.Fatalf("tuning socket error: %s", err)
+	}
+	return oob
+}
+
+// startListener serves requests coming to the open socket. oob are control messages of responses to IPv6 clients
+func (s *Server) startListener(conn *net.UDPConn, oob []byte) {
+	s.Stats.IncListeners()
+	defer s.Stats.DecListeners()
+	s.Checker.IncListeners()
+	defer s.Checker.DecListeners()
+	defer conn.Close()
+
+	s.serveRequests(func(t *task) error {
 		// read kernel timestamp from incoming packet
-		request, nowKernelTimestamp, returnaddr, err := ntp.ReadPacketWithKernelTimestamp(conn)
+		request, ext, nowKernelTimestamp, returnaddr, dst, err := ntp.ReadPacketWithExtensions(conn)
+		if err != nil {
+			return err
+		}
+		t.conn, t.addr, t.dst = conn, returnaddr, dst
+		t.received, t.read, t.request, t.ext = nowKernelTimestamp, time.Now(), request, ext
+		// IPv4 clients of dual stack listeners get no flow label
+		if returnaddr.IP.To4() == nil {
+			t.oob = oob
+		}
+		return nil
+	})
+}
+
+// listenTransport opens listener of the experimental transport
+func (s *Server) listenTransport() (net.PacketConn, error) {
+	transport, err := ntp.NewTransport(s.Transport.Network)
+	if err != nil {
+		return nil, fmt.Errorf("transport error: %w", err)
+	}
+	return transport.Listen(s.Transport.Address)
+}
+
+// startTransportListener serves requests coming via experimental transport.
+// Kernel timestamps are not available there, so receive time is taken in userspace
+func (s *Server) startTransportListener(conn net.PacketConn) {
+	defer conn.Close()
+	log.Infof("Starting experimental %s listener on %s", s.Transport.Network, s.Transport.Address)
+	s.Stats.IncListeners()
+	defer s.Stats.DecListeners()
+
+	buf := make([]byte, ntp.MaxPacketSizeBytes)
+	s.serveRequests(func(t *task) error {
+		n, from, err := conn.ReadFrom(buf)
 		if err != nil {
-			log.Errorf("read packet with timestamp error: %s", err)
+			return err
+		}
+		t.pc, t.from = conn, from
+		t.received = s.clock.Now()
+		if n < ntp.PacketSizeBytes {
+			return errInvalidFormat
+		}
+		if t.request, err = ntp.BytesToPacket(buf[:n]); err != nil {
+			return errInvalidFormat
+		}
+		if n > ntp.PacketSizeBytes {
+			t.ext = append([]byte{}, buf[ntp.PacketSizeBytes:n]...)
+		}
+		return nil
+	})
+}
+
+// serveRequests reads requests with read until the connection is closed and queues them to workers.
+// read sets the request and transport specific fields of the task
+func (s *Server) serveRequests(read func(t *task) error) {
+	for {
+		t := task{}
+		if err := read(&t); err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if errors.Is(err, errInvalidFormat) {
+				s.Stats.IncRequests()
+				s.Stats.IncInvalidFormat()
+				continue
+			}
+			log.Errorf("read packet error: %s", err)
 			s.Stats.IncReadError()
 			continue
 		}
 		s.Stats.IncRequests()
-		s.tasks <- task{conn: conn, addr: returnaddr, received: nowKernelTimestamp, request: request, stats: s.Stats}
+		clientIP := t.clientIP()
+		region := s.Regions.counters(clientIP)
+		region.incRequests()
+		// unix socket clients have no IP and are local by definition
+		if clientIP != nil {
+			if !s.ACL.Allowed(clientIP) {
+				s.Stats.IncACLDenied()
+				region.incACLDenied()
+				continue
+			}
+			if !s.RateLimiter.Allow(clientIP, t.received) {
+				s.Stats.IncRateLimited()
+				region.incRateLimited()
+				continue
+			}
+		}
+		// latency is only meaningful for kernel timestamps of accepted requests
+		if !t.read.IsZero() {
+			s.ReadLatency.Observe(t.received, t.read)
+		}
+		t.trace = s.Tracer.start(clientIP, t.received)
+		t.trace.mark(StageRecv)
+		t.stats, t.audit, t.nts, t.padding, t.tracer, t.region = s.Stats, s.Audit, s.NTS, &s.Padding, s.Tracer, region
+		t.canary = s.Canary.offset(clientIP)
+		s.tasks <- t
+	}
+}
+
+// listen opens unicast socket or joins multicast group for manycast clients
+func listen(ip net.IP, port int, iface string) (*net.UDPConn, error) {
+	addr := &net.UDPAddr{IP: ip, Port: port}
+	if !ip.IsMulticast() {
+		return net.ListenUDP("udp", addr)
+	}
+	i, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenMulticastUDP("udp", i, addr)
+}
+
+// startBroadcaster periodically sends broadcast (mode 5) packets
+func (s *Server) startBroadcaster(ctx context.Context) {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		log.Errorf("[broadcast] failed to open socket: %v", err)
+		return
+	}
+	defer conn.Close()
+	if err := ntp.EnableBroadcast(conn); err != nil {
+		log.Errorf("[broadcast] failed to enable broadcast: %v", err)
+		return
+	}
+
+	addr := &net.UDPAddr{IP: s.Broadcast.IP, Port: s.Broadcast.Port}
+	poll := int8(math.Round(math.Log2(s.Broadcast.Interval.Seconds())))
+	packet := &ntp.Packet{}
+	log.Infof("Starting broadcast to %s every %s", addr, s.Broadcast.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.Broadcast.Interval):
+		}
+		s.fillStaticHeaders(packet)
+		packet.SetBroadcast(4, poll, s.clock.Now().Add(s.extraOffset()))
+		b, err := packet.Bytes()
+		if err != nil {
+			log.Errorf("[broadcast] failed to convert packet to bytes: %v", err)
+			continue
+		}
+		if _, err := conn.WriteTo(b, addr); err != nil {
+			log.Errorf("[broadcast] failed to send: %v", err)
+		}
 	}
 }
 
@@ -157,52 +559,128 @@ func (s *Server) startWorker() {
 	defer s.Checker.DecWorkers()
 	defer s.Stats.DecWorkers()
 
-	// Pre-allocating response buffer
-	response := &ntp.Packet{}
-	s.fillStaticHeaders(response)
+	// Pre-computing static headers of responses
+	static := &ntp.Packet{}
+	version := atomic.LoadInt64(&s.headersVersion)
+	s.fillStaticHeaders(static)
+	response := newResponseTemplate(static)
 	s.Stats.IncWorkers()
 	for {
 		task := <-s.tasks
-		task.serve(response, s.ExtraOffset)
+		task.trace.mark(StageQueue)
+		if v := atomic.LoadInt64(&s.headersVersion); v != version {
+			version = v
+			s.fillStaticHeaders(static)
+			response.set(static)
+		}
+		if s.Impair.Enabled() {
+			task.delay = s.Impair.delay()
+		}
+		task.serve(response, s.clock, s.extraOffset()+s.Impair.Offset+task.canary, &s.Smear)
+	}
+}
+
+// clientIP returns IP of the client, nil for unix socket clients
+func (t *task) clientIP() net.IP {
+	if t.addr != nil {
+		return t.addr.IP
 	}
+	return ntp.AddrIP(t.from)
 }
 
 // serve checks the request format
 // gets time from local and respond.
-func (t *task) serve(response *ntp.Packet, extraoffset time.Duration) {
+func (t *task) serve(tmpl *responseTemplate, clk clock.Clock, extraoffset time.Duration, smear *SmearConfig) {
 	log.Debugf("Received request: %+v", t.request)
 	if t.request.ValidSettingsFormat() {
-		generateResponse(time.Now().Add(extraoffset), t.received.Add(extraoffset), t.request, response)
-		responseBytes, err := response.Bytes()
-		if err != nil {
-			log.Errorf("Failed to convert ntp.%v to bytes %v: %v", response, responseBytes, err)
-			return
+		t.trace.mark(StageDecode)
+		now := clk.Now()
+		responseBytes := tmpl.build(now.Add(extraoffset), t.received.Add(extraoffset), t.request)
+		t.trace.mark(StageTimestamp)
+		// response is only decoded for audit and NTS, the common path works with bytes
+		if t.audit.sample() {
+			if response, err := ntp.BytesToPacket(responseBytes); err == nil {
+				t.audit.verify(response, now.Add(extraoffset), t.received.Add(extraoffset), t.request)
+			}
+		}
+		// extension fields are only defined for NTPv4
+		fields := []ntp.ExtensionField{}
+		if info := smear.Info(now); info != nil && t.request.Version() == 4 {
+			fields = append(fields, info.ExtensionField())
+		}
+		if t.nts != nil && len(t.ext) > 0 && t.request.Version() == 4 {
+			response, err := ntp.BytesToPacket(responseBytes)
+			if err != nil {
+				log.Errorf("Failed to convert bytes %v to ntp.Packet: %v", responseBytes, err)
+				return
+			}
+			var ok bool
+			if responseBytes, ok = t.nts.respond(t.request, t.ext, response, fields, t.stats); !ok {
+				t.stats.IncInvalidFormat()
+				return
+			}
+		} else {
+			responseBytes = t.padding.apply(responseBytes, fields, ntp.PacketSizeBytes+len(t.ext), t.request.Version())
 		}
+		t.trace.mark(StageEncode)
 
-		log.Debugf("Writing from: %v", t.conn.LocalAddr())
-		log.Debugf("Writing response: %+v", response)
-		_, err = t.conn.WriteTo(responseBytes, t.addr)
-		if err != nil {
-			log.Debugf("Failed to respond to the request: %v", err)
+		log.Debugf("Writing from: %v", t.dst)
+		log.Debugf("Writing response: %x", responseBytes)
+		if t.delay > 0 {
+			// response bytes are not reused, worker can move on to the next request
+			time.AfterFunc(t.delay, func() {
+				if err := t.write(responseBytes); err != nil {
+					log.Debugf("Failed to respond to the request: %v", err)
+				}
+				t.trace.mark(StageSend)
+				t.tracer.finish(t.trace)
+			})
+		} else {
+			if err := t.write(responseBytes); err != nil {
+				log.Debugf("Failed to respond to the request: %v", err)
+			}
+			t.trace.mark(StageSend)
+			t.tracer.finish(t.trace)
 		}
 		t.stats.IncResponses()
+		t.region.incResponses()
 		return
 	}
 	log.Debugf("Invalid query, discarding: %v", t.request)
 	t.stats.IncInvalidFormat()
 }
 
+// write sends response back to the client via the transport request came from
+func (t *task) write(b []byte) error {
+	if t.pc != nil {
+		_, err := t.pc.WriteTo(b, t.from)
+		return err
+	}
+	if len(t.oob) > 0 {
+		_, err := ntp.WriteWithControl(t.conn, b, t.addr, t.dst, t.oob)
+		return err
+	}
+	_, err := ntp.WriteFrom(t.conn, b, t.addr, t.dst)
+	return err
+}
+
 // fillStaticHeaders pre-sets all the headers per worker which will never change
 // numbers are taken from tcpdump.
 func (s *Server) fillStaticHeaders(response *ntp.Packet) {
-	response.Stratum = uint8(s.Stratum)
+	s.headersLock.RLock()
+	defer s.headersLock.RUnlock()
+	response.Stratum = uint8(s.currentStratum())
 	response.Precision = -32
 	// Root delay. We pretend to be stratum 1
 	response.RootDelay = 0
-	// Root dispersion, big-endian 0.000152
-	response.RootDispersion = 10
+	// Root dispersion
+	response.RootDispersion = ntp.DurationToShort(152 * time.Microsecond)
 	// Reference ID ATOM. Only for stratum 1
-	response.ReferenceID = binary.BigEndian.Uint32([]byte(fmt.Sprintf("%-4s", s.RefID)))
+	refID := s.RefID
+	if s.Impair.Enabled() {
+		refID = ImpairRefID
+	}
+	response.ReferenceID = binary.BigEndian.Uint32([]byte(fmt.Sprintf("%-4s", refID)))
 }
 
 // generateResponse generates response NTP packet
@@ -219,7 +697,7 @@ func generateResponse(now time.Time, received time.Time, request, response *ntp.
 	// Because we don't have this info (no access to chronyd/ntpd) we need to
 	// come up with something. Just returning "now" will not fly and chronyd/ntpd
 	// will exclude "inconsistent host". So once per 1000s sounds "consistent" enough
-	lastSync := time.Unix(now.Unix()/1000*1000, 0)
+	lastSync := time.Unix(now.Unix()/refTimeInterval*refTimeInterval, 0)
 	lastSyncSec, lastSyncFrac := ntp.Time(lastSync)
 	response.RefTimeSec = lastSyncSec
 	response.RefTimeFrac = lastSyncFrac
@@ -0,0 +1,145 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/facebook/time/clock"
+	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/responder/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeFromDst(t *testing.T) {
+	conn, err := listen(net.IPv4zero, 0, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	cconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer cconn.Close()
+
+	// request arrived on 127.0.0.2 which is not the default source for the client
+	dst := net.ParseIP("127.0.0.2")
+	task := &task{
+		conn:     conn,
+		addr:     cconn.LocalAddr().(*net.UDPAddr),
+		dst:      dst,
+		received: time.Now(),
+		request:  &ntp.Packet{Settings: 0x1B},
+		stats:    &stats.JSONStats{},
+	}
+	task.serve(newResponseTemplate(&ntp.Packet{}), clock.System{}, 0, nil)
+
+	require.NoError(t, cconn.SetReadDeadline(time.Now().Add(time.Second)))
+	response, from, err := ntp.ReadNTPPacket(cconn)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0x1C), response.Settings)
+	require.Equal(t, dst.String(), from.(*net.UDPAddr).IP.String())
+}
+
+func TestServeFlowLabel(t *testing.T) {
+	conn, err := listen(net.IPv6loopback, 0, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, ntp.TuneSocket(conn, ntp.WithFlowLabel(0x4242)))
+	cconn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback, Port: 0})
+	require.NoError(t, err)
+	defer cconn.Close()
+	require.NoError(t, ntp.TuneSocket(cconn, ntp.WithRecvFlowLabel()))
+
+	task := &task{
+		conn:     conn,
+		addr:     cconn.LocalAddr().(*net.UDPAddr),
+		dst:      net.IPv6loopback,
+		oob:      ntp.FlowLabelControlMessage(0x4242),
+		received: time.Now(),
+		request:  &ntp.Packet{Settings: 0x1B},
+		stats:    &stats.JSONStats{},
+	}
+	task.serve(newResponseTemplate(&ntp.Packet{}), clock.System{}, 0, nil)
+
+	require.NoError(t, cconn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, ntp.PacketSizeBytes)
+	oob := make([]byte, ntp.ControlHeaderSizeBytes)
+	_, oobn, _, _, err := cconn.ReadMsgUDP(buf, oob)
+	require.NoError(t, err)
+	label, ok := ntp.ParseFlowLabel(oob[:oobn])
+	require.True(t, ok)
+	require.Equal(t, uint32(0x4242), label)
+}
+
+func TestServeSmearInfo(t *testing.T) {
+	conn, err := listen(net.ParseIP("127.0.0.1"), 0, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	cconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	require.NoError(t, err)
+	defer cconn.Close()
+
+	sim := clock.NewSimulated(time.Unix(1640995200, 0))
+	smear := &SmearConfig{Start: sim.Now().Add(-time.Hour), Duration: 2 * time.Hour, Leap: time.Second}
+	buf := make([]byte, 1024)
+	for _, version := range []uint8{3, 4} {
+		task := &task{
+			conn:     conn,
+			addr:     cconn.LocalAddr().(*net.UDPAddr),
+			received: sim.Now(),
+			request:  &ntp.Packet{Settings: version<<3 | 3},
+			stats:    &stats.JSONStats{},
+		}
+		task.serve(newResponseTemplate(&ntp.Packet{}), sim, 0, smear)
+
+		require.NoError(t, cconn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := cconn.ReadFromUDP(buf)
+		require.NoError(t, err)
+		info, err := ntp.SmearInfoFromBytes(buf[:n])
+		require.NoError(t, err)
+		if version == 3 {
+			require.Equal(t, ntp.PacketSizeBytes, n)
+			require.Nil(t, info)
+			continue
+		}
+		require.True(t, info.Active)
+		require.Equal(t, -500*time.Millisecond, info.Offset)
+	}
+}
+
+func TestServeAudit(t *testing.T) {
+	conn, err := listen(net.ParseIP("127.0.0.1"), 0, "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	audit := NewAudit(1, time.Minute)
+	task := &task{
+		conn:     conn,
+		addr:     conn.LocalAddr().(*net.UDPAddr),
+		received: time.Now(),
+		request:  &ntp.Packet{Settings: 0x1B},
+		stats:    &stats.JSONStats{},
+		audit:    audit,
+	}
+	// response template is reused by workers
+	response := newResponseTemplate(&ntp.Packet{})
+	task.serve(response, clock.System{}, time.Second, nil)
+	task.serve(response, clock.System{}, time.Second, nil)
+	r := audit.Report()
+	require.Equal(t, int64(2), r.Sampled)
+	require.Equal(t, int64(0), r.Mismatches)
+}
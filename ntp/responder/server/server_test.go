@@ -17,16 +17,29 @@ limitations under the License.
 package server
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
+	"net"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/facebook/time/clock"
 	ntp "github.com/facebook/time/ntp/protocol"
+	"github.com/facebook/time/ntp/responder/checker"
+	"github.com/facebook/time/ntp/responder/stats"
 	"github.com/stretchr/testify/require"
 )
 
 var timestamp = time.Unix(1585231321, 148166539)
 
+// both reporters shipped with responder satisfy Stats
+var (
+	_ Stats = &stats.JSONStats{}
+	_ Stats = &stats.MetricsStats{}
+)
+
 func TestFillStaticHeadersStratum(t *testing.T) {
 	stratum := 1
 	s := &Server{Stratum: stratum}
@@ -59,6 +72,25 @@ func TestFillStaticHeadersRootDispersion(t *testing.T) {
 	require.Equal(t, uint32(10), response.RootDispersion, "Root dispersion should be 0.000152")
 }
 
+func TestSetStratum(t *testing.T) {
+	s := &Server{Stratum: 1}
+	require.Equal(t, 1, s.CurrentStratum())
+
+	s.SetStratum(5)
+	require.Equal(t, 5, s.CurrentStratum())
+	response := &ntp.Packet{}
+	s.fillStaticHeaders(response)
+	require.Equal(t, uint8(5), response.Stratum)
+
+	s.SetStratum(0)
+	require.Equal(t, 1, s.CurrentStratum())
+}
+
+func TestReloadACLNotConfigured(t *testing.T) {
+	s := &Server{}
+	require.ErrorIs(t, s.ReloadACL(), errNoACL)
+}
+
 func TestGenerateResponsePoll(t *testing.T) {
 	request := &ntp.Packet{Poll: 8}
 	response := &ntp.Packet{}
@@ -107,3 +139,116 @@ func Benchmark_fillStaticHeaders(b *testing.B) {
 		s.fillStaticHeaders(response)
 	}
 }
+
+func TestBroadcaster(t *testing.T) {
+	conn, err := ntp.ListenBroadcast(net.ParseIP("127.0.0.1"), 0, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s := &Server{
+		Stratum:   1,
+		RefID:     "TEST",
+		clock:     clock.System{},
+		Broadcast: BroadcastConfig{IP: net.ParseIP("127.0.0.1"), Port: conn.LocalAddr().(*net.UDPAddr).Port, Interval: 10 * time.Millisecond},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.startBroadcaster(ctx)
+
+	p, _, _, err := ntp.ReadBroadcastPacket(conn)
+	require.NoError(t, err)
+	require.Equal(t, uint8(1), p.Stratum)
+	require.Equal(t, int8(-7), p.Poll)
+}
+
+func TestListenUnicast(t *testing.T) {
+	conn, err := listen(net.ParseIP("127.0.0.1"), 0, "lo")
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestTransportListener(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "ntp.sock")
+	s := &Server{
+		Stats:     &stats.JSONStats{},
+		Checker:   &checker.SimpleChecker{},
+		Transport: TransportConfig{Network: ntp.TransportUnix, Address: sock},
+		Regions:   NewRegionStats(fakeEnricher{}, 0),
+		clock:     clock.System{},
+		tasks:     make(chan task, 1),
+	}
+	ln, err := s.listenTransport()
+	require.NoError(t, err)
+	go s.startTransportListener(ln)
+	go s.startWorker()
+
+	tr, err := ntp.NewTransport(ntp.TransportUnix)
+	require.NoError(t, err)
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = tr.Dial(sock, time.Second)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	defer conn.Close()
+
+	request, err := (&ntp.Packet{Settings: 0x1B}).Bytes()
+	require.NoError(t, err)
+	_, err = conn.Write(request)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, ntp.MaxPacketSizeBytes)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	response, err := ntp.BytesToPacket(buf[:n])
+	require.NoError(t, err)
+	require.Equal(t, uint8(0x1C), response.Settings)
+	// unix socket clients have no region
+	require.Eventually(t, func() bool {
+		return s.Regions.Report()[RegionUnknown] == RegionCount{Requests: 1, Responses: 1}
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServeRequestsReadLatency(t *testing.T) {
+	acl := &ACL{}
+	acl.Set([]*net.IPNet{{IP: net.ParseIP("192.0.2.1"), Mask: net.CIDRMask(32, 32)}})
+	s := &Server{
+		Stats:       &stats.JSONStats{},
+		ACL:         acl,
+		ReadLatency: ntp.NewReadLatency(),
+		tasks:       make(chan task, 1),
+	}
+	received := time.Now()
+	reads := []func(t *task) error{
+		// read errors have no kernel timestamp
+		func(t *task) error { return errors.New("boom") },
+		// denied by acl
+		func(t *task) error {
+			t.addr, t.received, t.read = &net.UDPAddr{IP: net.ParseIP("192.0.2.2")}, received, received.Add(time.Millisecond)
+			return nil
+		},
+		func(t *task) error {
+			t.addr, t.received, t.read = &net.UDPAddr{IP: net.ParseIP("192.0.2.1")}, received, received.Add(time.Millisecond)
+			return nil
+		},
+		func(t *task) error { return net.ErrClosed },
+	}
+	s.serveRequests(func(t *task) error {
+		read := reads[0]
+		reads = reads[1:]
+		return read(t)
+	})
+	require.Len(t, s.tasks, 1)
+	l := s.ReadLatency.Stats()
+	require.Equal(t, int64(1), l.Count)
+	require.Equal(t, time.Millisecond, l.Max)
+}
+
+func TestManagesIPs(t *testing.T) {
+	s := &Server{ListenConfig: ListenConfig{IPs: MultiIPs{net.ParseIP("239.1.1.1")}}}
+	require.False(t, s.managesIPs())
+	s.ListenConfig.IPs = append(s.ListenConfig.IPs, net.ParseIP("192.0.2.1"))
+	require.True(t, s.managesIPs())
+	s.Systemd = &Systemd{Listeners: []*net.UDPConn{{}}}
+	require.False(t, s.managesIPs())
+}
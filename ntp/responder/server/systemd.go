@@ -0,0 +1,178 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation
+const listenFDsStart = 3
+
+// Systemd integrates the server with systemd: listening sockets are taken from socket activation,
+// so the process needs no privileges to bind port 123, and readiness and watchdog keep-alives are reported via sd_notify.
+// Nil Systemd does nothing
+type Systemd struct {
+	// Listeners are UDP sockets passed via socket activation. Server listens on ListenConfig if empty
+	Listeners []*net.UDPConn
+	// Watchdog is the interval systemd expects keep-alives within. Disabled if 0
+	Watchdog time.Duration
+
+	notifySocket string
+}
+
+// NewSystemd returns Systemd configured by the environment systemd started the process with
+func NewSystemd() (*Systemd, error) {
+	s, err := newSystemd(os.Getenv, os.Getpid(), listenFDsStart)
+	// sockets must not be passed on to children
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	return s, err
+}
+
+func newSystemd(getenv func(string) string, pid int, fdStart int) (*Systemd, error) {
+	s := &Systemd{notifySocket: getenv("NOTIFY_SOCKET")}
+
+	if usec := getenv("WATCHDOG_USEC"); usec != "" && forPid(getenv("WATCHDOG_PID"), pid) {
+		n, err := strconv.ParseInt(usec, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+		}
+		s.Watchdog = time.Duration(n) * time.Microsecond
+	}
+
+	// like sd_listen_fds, sockets are only taken if LISTEN_PID is set to our pid,
+	// so a process spawned by us with inherited environment never grabs random descriptors
+	fds := getenv("LISTEN_FDS")
+	listenPid := getenv("LISTEN_PID")
+	if fds == "" || listenPid == "" || !forPid(listenPid, pid) {
+		return s, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	for fd := fdStart; fd < fdStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("LISTEN_FD_%d", fd))
+		// conn gets a duplicate of the descriptor
+		pc, err := net.FilePacketConn(f)
+		f.Close()
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("activated socket %d: %w", fd, err)
+		}
+		conn, ok := pc.(*net.UDPConn)
+		if !ok {
+			pc.Close()
+			s.Close()
+			return nil, fmt.Errorf("activated socket %d is %s, not UDP", fd, pc.LocalAddr().Network())
+		}
+		s.Listeners = append(s.Listeners, conn)
+	}
+	return s, nil
+}
+
+// forPid returns whether environment variable with target pid is meant for the process.
+// Empty pid matches any process, which is only acceptable for WATCHDOG_PID
+func forPid(target string, pid int) bool {
+	if target == "" {
+		return true
+	}
+	p, err := strconv.Atoi(target)
+	return err == nil && p == pid
+}
+
+// Activated returns whether listening sockets were passed via socket activation
+func (s *Systemd) Activated() bool {
+	return s != nil && len(s.Listeners) > 0
+}
+
+// IPs returns addresses of activated sockets
+func (s *Systemd) IPs() MultiIPs {
+	if s == nil {
+		return nil
+	}
+	ips := MultiIPs{}
+	for _, conn := range s.Listeners {
+		ips = append(ips, conn.LocalAddr().(*net.UDPAddr).IP)
+	}
+	return ips
+}
+
+// Close closes activated sockets
+func (s *Systemd) Close() {
+	if s == nil {
+		return
+	}
+	for _, conn := range s.Listeners {
+		conn.Close()
+	}
+}
+
+// Notify sends state, such as READY=1, to systemd. Does nothing if the process isn't run as a notify service
+func (s *Systemd) Notify(state string) error {
+	if s == nil || s.notifySocket == "" {
+		return nil
+	}
+	// names starting with @ are abstract sockets
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: s.notifySocket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("connecting to systemd: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("notifying systemd: %w", err)
+	}
+	return nil
+}
+
+// notify logs failed notifications, which must not affect serving
+func (s *Systemd) notify(state string) {
+	if err := s.Notify(state); err != nil {
+		log.Errorf("[server] %v", err)
+	}
+}
+
+// RunWatchdog sends keep-alives twice per watchdog interval until ctx is done.
+// Keep-alives are skipped while check fails, so systemd restarts the hung server
+func (s *Systemd) RunWatchdog(ctx context.Context, check func() error) {
+	if s == nil || s.Watchdog <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.Watchdog / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := check(); err != nil {
+			log.Errorf("[server] skipping systemd watchdog keep-alive: %v", err)
+			continue
+		}
+		s.notify("WATCHDOG=1")
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string {
+		return vars[k]
+	}
+}
+
+// dupFD returns a copy of the socket descriptor, as systemd would pass it
+func dupFD(t *testing.T, conn interface{ File() (*os.File, error) }) int {
+	f, err := conn.File()
+	require.NoError(t, err)
+	defer f.Close()
+	fd, err := syscall.Dup(int(f.Fd()))
+	require.NoError(t, err)
+	return fd
+}
+
+func TestNewSystemdWatchdog(t *testing.T) {
+	s, err := newSystemd(env(nil), 42, listenFDsStart)
+	require.NoError(t, err)
+	require.False(t, s.Activated())
+	require.Equal(t, time.Duration(0), s.Watchdog)
+
+	s, err = newSystemd(env(map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": "42"}), 42, listenFDsStart)
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, s.Watchdog)
+
+	// watchdog of another process
+	s, err = newSystemd(env(map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": "1"}), 42, listenFDsStart)
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), s.Watchdog)
+
+	_, err = newSystemd(env(map[string]string{"WATCHDOG_USEC": "oleg"}), 42, listenFDsStart)
+	require.EqualError(t, err, `invalid WATCHDOG_USEC "oleg"`)
+}
+
+func TestNewSystemdListeners(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer conn.Close()
+	fd := dupFD(t, conn)
+
+	// sockets of another process are ignored
+	s, err := newSystemd(env(map[string]string{"LISTEN_FDS": "1", "LISTEN_PID": "1"}), 42, fd)
+	require.NoError(t, err)
+	require.False(t, s.Activated())
+
+	// as well as sockets without LISTEN_PID
+	s, err = newSystemd(env(map[string]string{"LISTEN_FDS": "1"}), 42, fd)
+	require.NoError(t, err)
+	require.False(t, s.Activated())
+
+	s, err = newSystemd(env(map[string]string{"LISTEN_FDS": "1", "LISTEN_PID": "42"}), 42, fd)
+	require.NoError(t, err)
+	defer s.Close()
+	require.True(t, s.Activated())
+	require.Equal(t, conn.LocalAddr(), s.Listeners[0].LocalAddr())
+	require.Equal(t, MultiIPs{net.ParseIP("127.0.0.1").To4()}, s.IPs())
+
+	_, err = newSystemd(env(map[string]string{"LISTEN_FDS": "-1", "LISTEN_PID": "42"}), 42, fd)
+	require.EqualError(t, err, `invalid LISTEN_FDS "-1"`)
+}
+
+func TestNewSystemdListenersNotUDP(t *testing.T) {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer ln.Close()
+	fd := dupFD(t, ln)
+
+	_, err = newSystemd(env(map[string]string{"LISTEN_FDS": "1", "LISTEN_PID": "42"}), 42, fd)
+	require.Error(t, err)
+}
+
+func listenNotify(t *testing.T) (*net.UnixConn, string) {
+	dir, err := ioutil.TempDir("", "systemd")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, path
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, 100)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestSystemdNotify(t *testing.T) {
+	var nilSystemd *Systemd
+	require.NoError(t, nilSystemd.Notify("READY=1"))
+	require.False(t, nilSystemd.Activated())
+	require.NoError(t, (&Systemd{}).Notify("READY=1"))
+
+	conn, path := listenNotify(t)
+	s, err := newSystemd(env(map[string]string{"NOTIFY_SOCKET": path}), 42, listenFDsStart)
+	require.NoError(t, err)
+	require.NoError(t, s.Notify("READY=1"))
+	require.Equal(t, "READY=1", readNotify(t, conn))
+
+	s.notifySocket = filepath.Join(filepath.Dir(path), "missing")
+	require.Error(t, s.Notify("READY=1"))
+}
+
+func TestSystemdWatchdog(t *testing.T) {
+	conn, path := listenNotify(t)
+	s := &Systemd{Watchdog: 20 * time.Millisecond, notifySocket: path}
+	healthy := make(chan error, 1)
+	healthy <- errors.New("no listeners")
+	check := func() error {
+		select {
+		case err := <-healthy:
+			return err
+		default:
+			return nil
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.RunWatchdog(ctx, check)
+
+	// first check fails, keep-alives follow once healthy
+	require.Equal(t, "WATCHDOG=1", readNotify(t, conn))
+	require.Empty(t, healthy)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/binary"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// refTimeInterval is how often reference timestamp of responses changes.
+// See generateResponse for the reasoning
+const refTimeInterval = 1000
+
+// responseTemplate is a response with precomputed static header bytes
+// (stratum, precision, root delay and dispersion, reference ID and timestamp).
+// They are refreshed only when headers or reference timestamp change,
+// so per request only version, poll and timestamps are written.
+// Template is owned by a single worker and is not safe for concurrent use
+type responseTemplate struct {
+	buf [ntp.PacketSizeBytes]byte
+	// refSec is the unix time of the reference timestamp in buf. -1 if not filled yet
+	refSec int64
+}
+
+// newResponseTemplate returns template with static headers of the packet
+func newResponseTemplate(static *ntp.Packet) *responseTemplate {
+	t := &responseTemplate{refSec: -1}
+	t.set(static)
+	return t
+}
+
+// set refreshes static headers from the packet
+func (t *responseTemplate) set(static *ntp.Packet) {
+	t.buf[1] = static.Stratum
+	t.buf[3] = uint8(static.Precision)
+	binary.BigEndian.PutUint32(t.buf[4:], static.RootDelay)
+	binary.BigEndian.PutUint32(t.buf[8:], static.RootDispersion)
+	binary.BigEndian.PutUint32(t.buf[12:], static.ReferenceID)
+}
+
+func putTime(b []byte, t time.Time) {
+	sec, frac := ntp.Time(t)
+	binary.BigEndian.PutUint32(b, sec)
+	binary.BigEndian.PutUint32(b[4:], frac)
+}
+
+// build returns response bytes to the request, the same generateResponse produces
+func (t *responseTemplate) build(now, received time.Time, request *ntp.Packet) []byte {
+	if refSec := now.Unix() / refTimeInterval * refTimeInterval; refSec != t.refSec {
+		t.refSec = refSec
+		putTime(t.buf[16:], time.Unix(refSec, 0))
+	}
+	b := make([]byte, ntp.PacketSizeBytes)
+	copy(b, t.buf[:])
+	b[0] = request.Settings&0x38 + 4
+	b[2] = uint8(request.Poll)
+	binary.BigEndian.PutUint32(b[24:], request.TxTimeSec)
+	binary.BigEndian.PutUint32(b[28:], request.TxTimeFrac)
+	putTime(b[32:], received)
+	putTime(b[40:], now)
+	return b
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+func expectedResponse(t *testing.T, s *Server, now, received time.Time, request *ntp.Packet) []byte {
+	response := &ntp.Packet{}
+	s.fillStaticHeaders(response)
+	generateResponse(now, received, request, response)
+	b, err := response.Bytes()
+	require.NoError(t, err)
+	return b
+}
+
+func TestResponseTemplateBuild(t *testing.T) {
+	s := &Server{Stratum: 1, RefID: "TEST"}
+	static := &ntp.Packet{}
+	s.fillStaticHeaders(static)
+	tmpl := newResponseTemplate(static)
+
+	request := &ntp.Packet{Settings: 0x1B, Poll: 6, TxTimeSec: 3794210679, TxTimeFrac: 2718216404}
+	now := time.Unix(1585147599, 123456789)
+	received := now.Add(-time.Millisecond)
+	require.Equal(t, expectedResponse(t, s, now, received, request), tmpl.build(now, received, request))
+
+	// reference timestamp moves on the next interval
+	now = time.Unix(1585148000, 42)
+	require.Equal(t, expectedResponse(t, s, now, received, request), tmpl.build(now, received, request))
+
+	// version of the request is kept
+	request.Settings = 0x23
+	require.Equal(t, expectedResponse(t, s, now, received, request), tmpl.build(now, received, request))
+}
+
+func TestResponseTemplateSet(t *testing.T) {
+	s := &Server{Stratum: 1, RefID: "TEST"}
+	static := &ntp.Packet{}
+	s.fillStaticHeaders(static)
+	tmpl := newResponseTemplate(static)
+
+	request := &ntp.Packet{Settings: 0x1B}
+	b := tmpl.build(timestamp, timestamp, request)
+
+	s.Stratum = 3
+	s.RefID = "OLEG"
+	s.fillStaticHeaders(static)
+	tmpl.set(static)
+	require.Equal(t, expectedResponse(t, s, timestamp, timestamp, request), tmpl.build(timestamp, timestamp, request))
+	// previously built responses are not affected
+	require.Equal(t, uint8(1), b[1])
+}
+
+func Benchmark_responseTemplateBuild(b *testing.B) {
+	tmpl := newResponseTemplate(&ntp.Packet{})
+	request := &ntp.Packet{}
+	for i := 0; i < b.N; i++ {
+		tmpl.build(timestamp, timestamp, request)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TraceStage is a stage of request handling
+type TraceStage int
+
+// Stages in the order request goes through them
+const (
+	// StageRecv is from kernel receive timestamp to the userspace read
+	StageRecv TraceStage = iota
+	// StageQueue is waiting for a free worker
+	StageQueue
+	// StageDecode is request validation
+	StageDecode
+	// StageTimestamp is response generation
+	StageTimestamp
+	// StageEncode is serialization, NTS and padding
+	StageEncode
+	// StageSend is the write, including impairment delay if enabled
+	StageSend
+	numStages
+)
+
+var traceStageNames = [numStages]string{"recv", "queue", "decode", "timestamp", "encode", "send"}
+
+func (s TraceStage) String() string {
+	if s < 0 || s >= numStages {
+		return "unknown"
+	}
+	return traceStageNames[s]
+}
+
+// Trace is timing of a single request handling
+type Trace struct {
+	Client   net.IP                   `json:"client"`
+	Received time.Time                `json:"received"`
+	Stages   map[string]time.Duration `json:"stages"`
+	Total    time.Duration            `json:"total"`
+
+	last   time.Time
+	stages [numStages]time.Duration
+}
+
+// mark records the time since the previous mark as the duration of the stage.
+// Nil Trace means request is not sampled
+func (t *Trace) mark(stage TraceStage) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.stages[stage] = now.Sub(t.last)
+	t.last = now
+}
+
+// Tracer records timing of request handling stages for sampled requests,
+// so tail latency can be attributed to a stage. Latest traces are kept in a ring.
+// Nil Tracer disables tracing
+type Tracer struct {
+	// SampleRate is how often requests are traced: every SampleRate-th one. 0 disables tracing
+	SampleRate int64
+	// Slow traces taking longer than this are logged. 0 disables logging
+	Slow time.Duration
+
+	// keep these aligned to 64-bit for sync/atomic
+	requests int64
+	traced   int64
+
+	sync.Mutex
+	ring []*Trace
+	next int
+	max  [numStages]time.Duration
+}
+
+// TraceReport is a snapshot of the latest traces
+type TraceReport struct {
+	Requests int64                    `json:"requests"`
+	Traced   int64                    `json:"traced"`
+	Max      map[string]time.Duration `json:"max"`
+	Traces   []*Trace                 `json:"traces"`
+}
+
+// NewTracer returns Tracer tracing every sampleRate-th request and keeping size latest traces
+func NewTracer(sampleRate int64, size int) *Tracer {
+	return &Tracer{
+		SampleRate: sampleRate,
+		ring:       make([]*Trace, 0, size),
+	}
+}
+
+// start returns a new Trace if the request should be traced, nil otherwise
+func (t *Tracer) start(client net.IP, received time.Time) *Trace {
+	if t == nil || t.SampleRate <= 0 {
+		return nil
+	}
+	if atomic.AddInt64(&t.requests, 1)%t.SampleRate != 0 {
+		return nil
+	}
+	return &Trace{Client: client, Received: received, last: received}
+}
+
+// finish stores the trace in the ring
+func (t *Tracer) finish(tr *Trace) {
+	if tr == nil {
+		return
+	}
+	atomic.AddInt64(&t.traced, 1)
+	tr.Stages = make(map[string]time.Duration, numStages)
+	for i, d := range tr.stages {
+		tr.Stages[TraceStage(i).String()] = d
+		tr.Total += d
+	}
+	if t.Slow > 0 && tr.Total > t.Slow {
+		log.Warningf("[trace] slow response to %s took %v: %v", tr.Client, tr.Total, tr.Stages)
+	}
+	t.Lock()
+	defer t.Unlock()
+	for i, d := range tr.stages {
+		if d > t.max[i] {
+			t.max[i] = d
+		}
+	}
+	if cap(t.ring) == 0 {
+		return
+	}
+	if len(t.ring) < cap(t.ring) {
+		t.ring = append(t.ring, tr)
+		return
+	}
+	t.ring[t.next] = tr
+	t.next = (t.next + 1) % len(t.ring)
+}
+
+// Report returns latest traces, oldest first, and max duration of each stage seen so far
+func (t *Tracer) Report() *TraceReport {
+	r := &TraceReport{
+		Requests: atomic.LoadInt64(&t.requests),
+		Traced:   atomic.LoadInt64(&t.traced),
+		Max:      make(map[string]time.Duration, numStages),
+	}
+	t.Lock()
+	defer t.Unlock()
+	for i, d := range t.max {
+		r.Max[TraceStage(i).String()] = d
+	}
+	r.Traces = append(append([]*Trace{}, t.ring[t.next:]...), t.ring[:t.next]...)
+	return r
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracerSample(t *testing.T) {
+	var tr *Tracer
+	require.Nil(t, tr.start(net.IPv4(10, 0, 0, 1), time.Now()))
+
+	tr = NewTracer(3, 10)
+	sampled := 0
+	for i := 0; i < 9; i++ {
+		if tr.start(net.IPv4(10, 0, 0, 1), time.Now()) != nil {
+			sampled++
+		}
+	}
+	require.Equal(t, 3, sampled)
+	require.Equal(t, int64(9), tr.Report().Requests)
+
+	tr.SampleRate = 0
+	require.Nil(t, tr.start(net.IPv4(10, 0, 0, 1), time.Now()))
+}
+
+func TestTraceMarkNil(t *testing.T) {
+	var trace *Trace
+	require.NotPanics(t, func() { trace.mark(StageRecv) })
+	var tr *Tracer
+	require.NotPanics(t, func() { tr.finish(trace) })
+}
+
+func TestTracerFinish(t *testing.T) {
+	tr := NewTracer(1, 2)
+	received := time.Now().Add(-time.Millisecond)
+	for i := 0; i < 3; i++ {
+		trace := tr.start(net.IPv4(10, 0, 0, byte(i)), received)
+		for stage := StageRecv; stage < numStages; stage++ {
+			trace.mark(stage)
+		}
+		tr.finish(trace)
+	}
+	r := tr.Report()
+	require.Equal(t, int64(3), r.Traced)
+	// only 2 latest are kept, oldest first
+	require.Len(t, r.Traces, 2)
+	require.Equal(t, net.IPv4(10, 0, 0, 1), r.Traces[0].Client)
+	require.Equal(t, net.IPv4(10, 0, 0, 2), r.Traces[1].Client)
+
+	trace := r.Traces[1]
+	require.Len(t, trace.Stages, int(numStages))
+	require.GreaterOrEqual(t, trace.Stages["recv"], time.Millisecond)
+	var total time.Duration
+	for _, d := range trace.Stages {
+		total += d
+	}
+	require.Equal(t, total, trace.Total)
+	require.GreaterOrEqual(t, r.Max["recv"], trace.Stages["recv"])
+}
+
+func TestTraceStageString(t *testing.T) {
+	require.Equal(t, "recv", StageRecv.String())
+	require.Equal(t, "send", StageSend.String())
+	require.Equal(t, "unknown", numStages.String())
+}
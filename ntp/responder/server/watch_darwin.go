@@ -0,0 +1,53 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchInterval is how often config file is checked for changes
+const watchInterval = 5 * time.Second
+
+// WatchConfig calls onChange every time config file is modified.
+// Blocks until ctx is done
+func WatchConfig(ctx context.Context, path string, onChange func()) error {
+	var lastMod time.Time
+	if st, err := os.Stat(path); err == nil {
+		lastMod = st.ModTime()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchInterval):
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !st.ModTime().Equal(lastMod) {
+			lastMod = st.ModTime()
+			log.Infof("[server] %s changed", path)
+			onChange()
+		}
+	}
+}
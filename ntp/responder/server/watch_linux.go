@@ -0,0 +1,100 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// WatchConfig calls onChange every time config file is modified.
+// It watches the directory so atomic renames (used by most config management tools) are caught too.
+// Blocks until ctx is done
+func WatchConfig(ctx context.Context, path string, onChange func()) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("failed to init inotify: %w", err)
+	}
+	defer unix.Close(fd)
+
+	dir, name := filepath.Split(filepath.Clean(path))
+	if dir == "" {
+		dir = "."
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO|unix.IN_CREATE); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	buf := make([]byte, 4096)
+	pfd := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+		// wake up periodically to check ctx
+		n, err := unix.Poll(pfd, 1000)
+		if err != nil && err != unix.EINTR {
+			return fmt.Errorf("failed to poll inotify: %w", err)
+		}
+		if n <= 0 {
+			continue
+		}
+		n, err = unix.Read(fd, buf)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			return fmt.Errorf("failed to read inotify: %w", err)
+		}
+		if changed(buf[:n], name) {
+			log.Infof("[server] %s changed", path)
+			onChange()
+		}
+	}
+}
+
+// changed checks if any inotify event in the buffer relates to the file name
+func changed(buf []byte, name string) bool {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		start := offset + unix.SizeofInotifyEvent
+		end := start + int(event.Len)
+		if end > len(buf) {
+			return false
+		}
+		// name is NUL-padded
+		eventName := string(buf[start:end])
+		for i := 0; i < len(eventName); i++ {
+			if eventName[i] == 0 {
+				eventName = eventName[:i]
+				break
+			}
+		}
+		if eventName == name {
+			return true
+		}
+		offset = end
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ntpresponder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("a"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 10)
+	go func() {
+		_ = WatchConfig(ctx, path, func() { changes <- struct{}{} })
+	}()
+	// let the watcher start
+	time.Sleep(100 * time.Millisecond)
+
+	// unrelated file is ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other"), []byte("b"), 0644))
+	// atomic rename is caught
+	tmp := filepath.Join(dir, "config.yaml.tmp")
+	require.NoError(t, ioutil.WriteFile(tmp, []byte("c"), 0644))
+	require.NoError(t, os.Rename(tmp, path))
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no change detected")
+	}
+}
@@ -41,6 +41,9 @@ type JSONStats struct {
 	listeners     int64
 	workers       int64
 	readError     int64
+	aclDenied     int64
+	rateLimited   int64
+	ntsNAK        int64
 	announce      int64
 }
 
@@ -48,17 +51,25 @@ type JSONStats struct {
 func (j *JSONStats) toMap() (export map[string]int64) {
 	export = make(map[string]int64)
 
-	export["invalidformat"] = j.invalidFormat
-	export["requests"] = j.requests
-	export["responses"] = j.responses
-	export["listeners"] = j.listeners
-	export["workers"] = j.workers
-	export["readError"] = j.readError
-	export["announce"] = j.announce
+	export["invalidformat"] = atomic.LoadInt64(&j.invalidFormat)
+	export["requests"] = atomic.LoadInt64(&j.requests)
+	export["responses"] = atomic.LoadInt64(&j.responses)
+	export["listeners"] = atomic.LoadInt64(&j.listeners)
+	export["workers"] = atomic.LoadInt64(&j.workers)
+	export["readError"] = atomic.LoadInt64(&j.readError)
+	export["aclDenied"] = atomic.LoadInt64(&j.aclDenied)
+	export["rateLimited"] = atomic.LoadInt64(&j.rateLimited)
+	export["ntsNAK"] = atomic.LoadInt64(&j.ntsNAK)
+	export["announce"] = atomic.LoadInt64(&j.announce)
 
 	return export
 }
 
+// Values returns current values of all counters
+func (j *JSONStats) Values() map[string]int64 {
+	return j.toMap()
+}
+
 // handleRequest is a handler used for all http monitoring requests
 func (j *JSONStats) handleRequest(w http.ResponseWriter, r *http.Request) {
 	js, err := json.Marshal(j.toMap())
@@ -75,6 +86,7 @@ func (j *JSONStats) handleRequest(w http.ResponseWriter, r *http.Request) {
 // Start with launch 303 thrift and report ODS metrics periodically
 func (j *JSONStats) Start(port int) {
 	http.HandleFunc("/", j.handleRequest)
+	http.HandleFunc("/metrics", j.handlePrometheus)
 	addr := fmt.Sprintf(":%d", port)
 	log.Debugf("Starting http json server on %s", addr)
 	err := http.ListenAndServe(addr, nil)
@@ -113,6 +125,21 @@ func (j *JSONStats) IncReadError() {
 	atomic.AddInt64(&j.readError, 1)
 }
 
+// IncACLDenied atomically add 1 to the counter
+func (j *JSONStats) IncACLDenied() {
+	atomic.AddInt64(&j.aclDenied, 1)
+}
+
+// IncRateLimited atomically add 1 to the counter
+func (j *JSONStats) IncRateLimited() {
+	atomic.AddInt64(&j.rateLimited, 1)
+}
+
+// IncNTSNAK atomically add 1 to the counter
+func (j *JSONStats) IncNTSNAK() {
+	atomic.AddInt64(&j.ntsNAK, 1)
+}
+
 // DecListeners atomically removes 1 from the counter
 func (j *JSONStats) DecListeners() {
 	atomic.AddInt64(&j.listeners, -1)
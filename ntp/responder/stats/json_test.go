@@ -70,6 +70,27 @@ func TestJSONStatsReadError(t *testing.T) {
 	require.Equal(t, int64(1), stats.readError)
 }
 
+func TestJSONStatsACLDenied(t *testing.T) {
+	stats := JSONStats{}
+
+	stats.IncACLDenied()
+	require.Equal(t, int64(1), stats.aclDenied)
+}
+
+func TestJSONStatsRateLimited(t *testing.T) {
+	stats := JSONStats{}
+
+	stats.IncRateLimited()
+	require.Equal(t, int64(1), stats.rateLimited)
+}
+
+func TestJSONStatsNTSNAK(t *testing.T) {
+	stats := JSONStats{}
+
+	stats.IncNTSNAK()
+	require.Equal(t, int64(1), stats.ntsNAK)
+}
+
 func TestJSONStatsAnnounce(t *testing.T) {
 	stats := JSONStats{}
 
@@ -89,6 +110,9 @@ func TestJSONStatsToMap(t *testing.T) {
 		workers:       5,
 		readError:     6,
 		announce:      7,
+		aclDenied:     8,
+		rateLimited:   9,
+		ntsNAK:        10,
 	}
 	result := j.toMap()
 
@@ -100,6 +124,9 @@ func TestJSONStatsToMap(t *testing.T) {
 	expectedMap["workers"] = 5
 	expectedMap["readError"] = 6
 	expectedMap["announce"] = 7
+	expectedMap["aclDenied"] = 8
+	expectedMap["rateLimited"] = 9
+	expectedMap["ntsNAK"] = 10
 
 	require.Equal(t, expectedMap, result)
 }
@@ -0,0 +1,147 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"fmt"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/facebook/time/metrics"
+)
+
+// MetricsStats implements Stat interface on top of metrics.Registry,
+// so responder reports to whatever metrics stack the registry is backed by
+type MetricsStats struct {
+	registry      metrics.Registry
+	invalidFormat metrics.Counter
+	requests      metrics.Counter
+	responses     metrics.Counter
+	readError     metrics.Counter
+	aclDenied     metrics.Counter
+	rateLimited   metrics.Counter
+	ntsNAK        metrics.Counter
+	listeners     metrics.Gauge
+	workers       metrics.Gauge
+	announce      metrics.Gauge
+}
+
+// NewMetricsStats returns MetricsStats registering metrics with the same names
+// JSONStats exports to Prometheus. Nil registry discards everything
+func NewMetricsStats(r metrics.Registry) *MetricsStats {
+	r = metrics.Default(r)
+	counter := func(key, help string) metrics.Counter {
+		return r.Counter(PrometheusPrefix+snakeCase(key)+"_total", help, nil)
+	}
+	gauge := func(key, help string) metrics.Gauge {
+		return r.Gauge(PrometheusPrefix+snakeCase(key), help, nil)
+	}
+	return &MetricsStats{
+		registry:      r,
+		invalidFormat: counter("invalidFormat", "Requests dropped as invalid NTP packets"),
+		requests:      counter("requests", "Requests received"),
+		responses:     counter("responses", "Responses sent"),
+		readError:     counter("readError", "Failed reads from listeners"),
+		aclDenied:     counter("aclDenied", "Requests denied by ACL"),
+		rateLimited:   counter("rateLimited", "Requests dropped by rate limiter"),
+		ntsNAK:        counter("ntsNAK", "NTS NAK responses sent"),
+		listeners:     gauge("listeners", "Running listeners"),
+		workers:       gauge("workers", "Running workers"),
+		announce:      gauge("announce", "Whether the server is announced"),
+	}
+}
+
+// Start serves the registry on /metrics if it is an http.Handler, does nothing otherwise
+func (m *MetricsStats) Start(port int) {
+	h, ok := m.registry.(http.Handler)
+	if !ok {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", h)
+	addr := fmt.Sprintf(":%d", port)
+	log.Debugf("Starting http metrics server on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Errorf("Failed to start listener: %v", err)
+	}
+}
+
+// IncInvalidFormat adds 1 to the counter
+func (m *MetricsStats) IncInvalidFormat() {
+	m.invalidFormat.Inc()
+}
+
+// IncRequests adds 1 to the counter
+func (m *MetricsStats) IncRequests() {
+	m.requests.Inc()
+}
+
+// IncResponses adds 1 to the counter
+func (m *MetricsStats) IncResponses() {
+	m.responses.Inc()
+}
+
+// IncListeners adds 1 to the gauge
+func (m *MetricsStats) IncListeners() {
+	m.listeners.Add(1)
+}
+
+// IncWorkers adds 1 to the gauge
+func (m *MetricsStats) IncWorkers() {
+	m.workers.Add(1)
+}
+
+// IncReadError adds 1 to the counter
+func (m *MetricsStats) IncReadError() {
+	m.readError.Inc()
+}
+
+// IncACLDenied adds 1 to the counter
+func (m *MetricsStats) IncACLDenied() {
+	m.aclDenied.Inc()
+}
+
+// IncRateLimited adds 1 to the counter
+func (m *MetricsStats) IncRateLimited() {
+	m.rateLimited.Inc()
+}
+
+// IncNTSNAK adds 1 to the counter
+func (m *MetricsStats) IncNTSNAK() {
+	m.ntsNAK.Inc()
+}
+
+// DecListeners removes 1 from the gauge
+func (m *MetricsStats) DecListeners() {
+	m.listeners.Add(-1)
+}
+
+// DecWorkers removes 1 from the gauge
+func (m *MetricsStats) DecWorkers() {
+	m.workers.Add(-1)
+}
+
+// SetAnnounce sets the gauge to 1
+func (m *MetricsStats) SetAnnounce() {
+	m.announce.Set(1)
+}
+
+// ResetAnnounce sets the gauge to 0
+func (m *MetricsStats) ResetAnnounce() {
+	m.announce.Set(0)
+}
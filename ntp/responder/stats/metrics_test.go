@@ -0,0 +1,65 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/facebook/time/metrics"
+)
+
+func TestMetricsStats(t *testing.T) {
+	r := metrics.NewPrometheus()
+	stats := NewMetricsStats(r)
+	stats.IncRequests()
+	stats.IncRequests()
+	stats.IncResponses()
+	stats.IncReadError()
+	stats.IncListeners()
+	stats.IncListeners()
+	stats.DecListeners()
+	stats.IncWorkers()
+	stats.DecWorkers()
+	stats.SetAnnounce()
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WritePrometheus(&buf))
+	out := buf.String()
+	require.Contains(t, out, "# TYPE ntpresponder_requests_total counter\nntpresponder_requests_total 2\n")
+	require.Contains(t, out, "ntpresponder_responses_total 1\n")
+	require.Contains(t, out, "ntpresponder_read_error_total 1\n")
+	require.Contains(t, out, "ntpresponder_nts_nak_total 0\n")
+	require.Contains(t, out, "# TYPE ntpresponder_listeners gauge\nntpresponder_listeners 1\n")
+	require.Contains(t, out, "ntpresponder_workers 0\n")
+	require.Contains(t, out, "ntpresponder_announce 1\n")
+
+	stats.ResetAnnounce()
+	buf.Reset()
+	require.NoError(t, r.WritePrometheus(&buf))
+	require.Contains(t, buf.String(), "ntpresponder_announce 0\n")
+}
+
+func TestMetricsStatsNoop(t *testing.T) {
+	stats := NewMetricsStats(nil)
+	stats.IncRequests()
+	stats.SetAnnounce()
+	// noop registry is not served
+	stats.Start(0)
+}
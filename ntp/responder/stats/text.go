@@ -0,0 +1,168 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PrometheusPrefix is prepended to all exported metric names
+const PrometheusPrefix = "ntpresponder_"
+
+// Unix socket commands
+const (
+	// CommandStats replies with "name value" lines
+	CommandStats = "stats"
+	// CommandMetrics replies with Prometheus text exposition
+	CommandMetrics = "metrics"
+)
+
+// gauges are values which go up and down, everything else is a counter
+var gauges = map[string]bool{
+	"listeners": true,
+	"workers":   true,
+	"announce":  true,
+}
+
+// unixTimeout limits how long a client can take to send a command
+var unixTimeout = 5 * time.Second
+
+// snakeCase converts counter keys such as readError or ntsNAK to read_error and nts_nak
+func snakeCase(s string) string {
+	var b strings.Builder
+	prevLower := false
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			if prevLower {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+			prevLower = false
+		} else {
+			prevLower = unicode.IsLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteText writes all counters as "name value" lines sorted by name
+func (j *JSONStats) WriteText(w io.Writer) error {
+	m := j.toMap()
+	for _, k := range sortedKeys(m) {
+		if _, err := fmt.Fprintf(w, "%s %d\n", k, m[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePrometheus writes all counters in Prometheus text exposition format
+func (j *JSONStats) WritePrometheus(w io.Writer) error {
+	m := j.toMap()
+	for _, k := range sortedKeys(m) {
+		name := PrometheusPrefix + snakeCase(k)
+		kind := "gauge"
+		if !gauges[k] {
+			kind = "counter"
+			name += "_total"
+		}
+		if _, err := fmt.Fprintf(w, "# TYPE %s %s\n%s %d\n", name, kind, name, m[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handlePrometheus is a handler for Prometheus scrapes
+func (j *JSONStats) handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := j.WritePrometheus(w); err != nil {
+		log.Errorf("Failed to reply: %v", err)
+	}
+}
+
+// StartUnix serves stats over unix socket at path, so sidecars can scrape without TCP.
+// Client sends a single command line, "stats" or "metrics", and the connection is closed after the reply
+func (j *JSONStats) StartUnix(path string) error {
+	// remove stale socket left after unclean shutdown
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Starting unix socket stats server on %s", path)
+	return j.ServeUnix(ln)
+}
+
+// ServeUnix accepts connections on ln and replies to stats commands
+func (j *JSONStats) ServeUnix(ln net.Listener) error {
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go j.handleUnix(conn)
+	}
+}
+
+func (j *JSONStats) handleUnix(conn net.Conn) {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(unixTimeout)); err != nil {
+		log.Errorf("Failed to set deadline: %v", err)
+		return
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		log.Debugf("Failed to read stats command: %v", err)
+		return
+	}
+	switch cmd := strings.TrimSpace(line); cmd {
+	case CommandStats:
+		err = j.WriteText(conn)
+	case CommandMetrics:
+		err = j.WritePrometheus(conn)
+	default:
+		_, err = fmt.Fprintf(conn, "error unknown command %q\n", cmd)
+	}
+	if err != nil {
+		log.Errorf("Failed to reply: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnakeCase(t *testing.T) {
+	require.Equal(t, "read_error", snakeCase("readError"))
+	require.Equal(t, "nts_nak", snakeCase("ntsNAK"))
+	require.Equal(t, "requests", snakeCase("requests"))
+}
+
+func TestWriteText(t *testing.T) {
+	stats := JSONStats{}
+	stats.IncRequests()
+	stats.IncRequests()
+	stats.IncWorkers()
+
+	var buf bytes.Buffer
+	require.NoError(t, stats.WriteText(&buf))
+	require.Contains(t, buf.String(), "requests 2\n")
+	require.Contains(t, buf.String(), "workers 1\n")
+	require.Contains(t, buf.String(), "aclDenied 0\n")
+}
+
+func TestWritePrometheus(t *testing.T) {
+	stats := JSONStats{}
+	stats.IncReadError()
+	stats.IncListeners()
+
+	var buf bytes.Buffer
+	require.NoError(t, stats.WritePrometheus(&buf))
+	require.Contains(t, buf.String(), "# TYPE ntpresponder_read_error_total counter\nntpresponder_read_error_total 1\n")
+	require.Contains(t, buf.String(), "# TYPE ntpresponder_listeners gauge\nntpresponder_listeners 1\n")
+
+	w := httptest.NewRecorder()
+	stats.handlePrometheus(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, buf.String(), w.Body.String())
+	require.Equal(t, "text/plain; version=0.0.4", w.Header().Get("Content-Type"))
+}
+
+func unixQuery(t *testing.T, path, cmd string) string {
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(cmd))
+	require.NoError(t, err)
+	reply, err := ioutil.ReadAll(conn)
+	require.NoError(t, err)
+	return string(reply)
+}
+
+func TestStartUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.sock")
+	stats := &JSONStats{}
+	stats.IncResponses()
+	ln, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	go func() {
+		_ = stats.ServeUnix(ln)
+	}()
+	defer ln.Close()
+
+	require.Contains(t, unixQuery(t, path, "stats\n"), "responses 1\n")
+	require.Contains(t, unixQuery(t, path, "metrics\n"), "ntpresponder_responses_total 1\n")
+	require.Equal(t, "error unknown command \"foo\"\n", unixQuery(t, path, "foo\n"))
+}
+
+func TestStartUnixStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.sock")
+	require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+	stats := &JSONStats{}
+	go func() {
+		_ = stats.StartUnix(path)
+	}()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package roughtime
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Tag identifies a value in the message. It's 4 ASCII bytes read as little-endian uint32
+type Tag uint32
+
+// Roughtime tags
+var (
+	TagSIG  = newTag("SIG\x00")
+	TagNONC = newTag("NONC")
+	TagDELE = newTag("DELE")
+	TagPATH = newTag("PATH")
+	TagRADI = newTag("RADI")
+	TagPUBK = newTag("PUBK")
+	TagMIDP = newTag("MIDP")
+	TagSREP = newTag("SREP")
+	TagMINT = newTag("MINT")
+	TagROOT = newTag("ROOT")
+	TagCERT = newTag("CERT")
+	TagMAXT = newTag("MAXT")
+	TagINDX = newTag("INDX")
+	TagPAD  = newTag("PAD\xff")
+)
+
+var (
+	errMessageTooShort = errors.New("message is too short")
+	errMessageOffsets  = errors.New("message offsets are invalid")
+	errMessageTags     = errors.New("message tags are not sorted")
+	errMissingTag      = errors.New("missing tag")
+	errValueLength     = errors.New("invalid value length")
+)
+
+func newTag(s string) Tag {
+	return Tag(binary.LittleEndian.Uint32([]byte(s)))
+}
+
+func (t Tag) String() string {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(t))
+	return fmt.Sprintf("%q", b)
+}
+
+// Message is a map of tags to values
+type Message map[Tag][]byte
+
+// Encode returns wire format of the message: number of tags, offsets of all values but the first, sorted tags and values.
+// Values must be padded to multiple of 4 bytes
+func (m Message) Encode() ([]byte, error) {
+	tags := make([]Tag, 0, len(m))
+	for t, v := range m {
+		if len(v)%4 != 0 {
+			return nil, fmt.Errorf("%w: %s is %d bytes", errValueLength, t, len(v))
+		}
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	header := 4 * 2 * len(tags)
+	if len(tags) == 0 {
+		header = 4
+	}
+	b := make([]byte, header)
+	binary.LittleEndian.PutUint32(b, uint32(len(tags)))
+	offset := 0
+	for i, t := range tags {
+		if i > 0 {
+			binary.LittleEndian.PutUint32(b[4*i:], uint32(offset))
+		}
+		binary.LittleEndian.PutUint32(b[4*len(tags)+4*i:], uint32(t))
+		offset += len(m[t])
+	}
+	for _, t := range tags {
+		b = append(b, m[t]...)
+	}
+	return b, nil
+}
+
+// Decode parses wire format of the message
+func Decode(b []byte) (Message, error) {
+	if len(b) < 4 {
+		return nil, errMessageTooShort
+	}
+	// counts and offsets are compared before conversion to int, which is 32-bit on some platforms
+	c := binary.LittleEndian.Uint32(b)
+	if c == 0 {
+		return Message{}, nil
+	}
+	if c > uint32(len(b)/8) {
+		return nil, errMessageTooShort
+	}
+	n := int(c)
+	header := 4 * 2 * n
+	values := b[header:]
+	m := make(Message, n)
+	var prev Tag
+	for i := 0; i < n; i++ {
+		t := Tag(binary.LittleEndian.Uint32(b[4*n+4*i:]))
+		if i > 0 && t <= prev {
+			return nil, errMessageTags
+		}
+		prev = t
+		start := uint32(0)
+		if i > 0 {
+			start = binary.LittleEndian.Uint32(b[4*i:])
+		}
+		end := uint32(len(values))
+		if i < n-1 {
+			end = binary.LittleEndian.Uint32(b[4*(i+1):])
+		}
+		if start%4 != 0 || end%4 != 0 || start > end || end > uint32(len(values)) {
+			return nil, errMessageOffsets
+		}
+		m[t] = values[int(start):int(end)]
+	}
+	return m, nil
+}
+
+// get returns value of the tag with the exact length, any length if size is negative
+func (m Message) get(t Tag, size int) ([]byte, error) {
+	v, ok := m[t]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errMissingTag, t)
+	}
+	if size >= 0 && len(v) != size {
+		return nil, fmt.Errorf("%w: %s is %d bytes, expected %d", errValueLength, t, len(v), size)
+	}
+	return v, nil
+}
+
+// message returns value of the tag decoded as nested message
+func (m Message) message(t Tag) (Message, []byte, error) {
+	v, err := m.get(t, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+	nested, err := Decode(v)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", t, err)
+	}
+	return nested, v, nil
+}
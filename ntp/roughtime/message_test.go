@@ -0,0 +1,96 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package roughtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagString(t *testing.T) {
+	require.Equal(t, `"NONC"`, TagNONC.String())
+	require.Equal(t, uint32(0x434e4f4e), uint32(TagNONC))
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := Message{
+		TagRADI: []byte{1, 2, 3, 4},
+		TagMIDP: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		TagPATH: nil,
+	}
+	b, err := m.Encode()
+	require.NoError(t, err)
+	// 3 tags, 2 offsets, 3 tags, then values
+	require.Len(t, b, 4+2*4+3*4+12)
+	got, err := Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, m[TagRADI], got[TagRADI])
+	require.Equal(t, m[TagMIDP], got[TagMIDP])
+	require.Empty(t, got[TagPATH])
+
+	b, err = Message{}.Encode()
+	require.NoError(t, err)
+	got, err = Decode(b)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestMessageEncodeUnaligned(t *testing.T) {
+	_, err := Message{TagRADI: []byte{1, 2, 3}}.Encode()
+	require.ErrorIs(t, err, errValueLength)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte{1, 0})
+	require.ErrorIs(t, err, errMessageTooShort)
+	_, err = Decode([]byte{0xff, 0, 0, 0})
+	require.ErrorIs(t, err, errMessageTooShort)
+	// count with the high bit set
+	_, err = Decode([]byte{0, 0, 0, 0x80, 0, 0, 0, 0})
+	require.ErrorIs(t, err, errMessageTooShort)
+
+	b, err := Message{TagRADI: make([]byte, 4), TagMIDP: make([]byte, 8)}.Encode()
+	require.NoError(t, err)
+	// offset beyond the values
+	bad := append([]byte{}, b...)
+	bad[4] = 0xf0
+	_, err = Decode(bad)
+	require.ErrorIs(t, err, errMessageOffsets)
+	// offset with the high bit set
+	bad = append([]byte{}, b...)
+	bad[7] = 0x80
+	_, err = Decode(bad)
+	require.ErrorIs(t, err, errMessageOffsets)
+	// tags out of order
+	bad = append([]byte{}, b...)
+	copy(bad[8:], b[12:16])
+	copy(bad[12:], b[8:12])
+	_, err = Decode(bad)
+	require.ErrorIs(t, err, errMessageTags)
+}
+
+func TestMessageGet(t *testing.T) {
+	m := Message{TagRADI: make([]byte, 4)}
+	_, err := m.get(TagMIDP, 8)
+	require.ErrorIs(t, err, errMissingTag)
+	_, err = m.get(TagRADI, 8)
+	require.ErrorIs(t, err, errValueLength)
+	v, err := m.get(TagRADI, -1)
+	require.NoError(t, err)
+	require.Len(t, v, 4)
+}
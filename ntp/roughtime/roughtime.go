@@ -0,0 +1,281 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package roughtime implements the client side of the Roughtime protocol.
+
+Client sends a random nonce, the server replies with a signed midpoint time and radius.
+The nonce is included in a Merkle tree of a batch of requests, whose root is signed
+with a key delegated by the long-term server key, so every reply is a proof of the time
+the nonce was seen. It allows to check integrity of classic NTP answers.
+See https://roughtime.googlesource.com/roughtime/+/HEAD/PROTOCOL.md
+*/
+package roughtime
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// NonceSize is the size of request nonce
+	NonceSize = 64
+	// RequestSize is the min size of request, padded against amplification
+	RequestSize = 1024
+	// MaxResponseSize is a buffer to read the response
+	MaxResponseSize = 4096
+	hashSize        = 64
+)
+
+// signature contexts
+var (
+	delegationContext = []byte("RoughTime v1 delegation signature--\x00")
+	responseContext   = []byte("RoughTime v1 response signature\x00")
+)
+
+var (
+	errBadPublicKey      = errors.New("invalid public key")
+	errDelegationSig     = errors.New("delegation signature is invalid")
+	errResponseSig       = errors.New("response signature is invalid")
+	errMerkleProof       = errors.New("nonce is not in the signed Merkle tree")
+	errOutsideDelegation = errors.New("midpoint is outside of the delegation validity")
+)
+
+// Server is a Roughtime server
+type Server struct {
+	// Address is host:port
+	Address string
+	// PublicKey is the long-term Ed25519 key of the server
+	PublicKey ed25519.PublicKey
+}
+
+// ParsePublicKey parses base64 encoded Ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadPublicKey, err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: %d bytes", errBadPublicKey, len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// Result is a verified reply of the server
+type Result struct {
+	// Midpoint is the server time when request was processed
+	Midpoint time.Time
+	// Radius is uncertainty of the midpoint, true time is within Midpoint ± Radius
+	Radius time.Duration
+	// Delay is the round trip time of the query
+	Delay time.Duration
+	// Offset of the server midpoint to the middle of the local round trip
+	Offset time.Duration
+}
+
+// Consistent returns true if the offset measured by other protocol, such as NTP, with the round trip delay
+// agrees with the Roughtime result within its radius and uncertainty of both round trips
+func (r *Result) Consistent(offset, delay time.Duration) bool {
+	diff := offset - r.Offset
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= r.Radius+r.Delay/2+delay/2
+}
+
+// NewRequest returns request with the nonce, padded to RequestSize
+func NewRequest(nonce []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, fmt.Errorf("%w: nonce is %d bytes", errValueLength, len(nonce))
+	}
+	m := Message{TagNONC: nonce, TagPAD: nil}
+	b, err := m.Encode()
+	if err != nil {
+		return nil, err
+	}
+	m[TagPAD] = make([]byte, RequestSize-len(b))
+	return m.Encode()
+}
+
+// leafHash is the hash of the nonce in the Merkle tree
+func leafHash(nonce []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{0})
+	h.Write(nonce)
+	return h.Sum(nil)[:hashSize]
+}
+
+// nodeHash is the hash of the Merkle tree node
+func nodeHash(left, right []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)[:hashSize]
+}
+
+// verifyPath checks the nonce is at index in the Merkle tree with the root
+func verifyPath(nonce, root, path []byte, index uint32) error {
+	if len(path)%hashSize != 0 {
+		return fmt.Errorf("%w: PATH is %d bytes", errValueLength, len(path))
+	}
+	hash := leafHash(nonce)
+	for len(path) > 0 {
+		if index&1 == 0 {
+			hash = nodeHash(hash, path[:hashSize])
+		} else {
+			hash = nodeHash(path[:hashSize], hash)
+		}
+		index >>= 1
+		path = path[hashSize:]
+	}
+	if !bytes.Equal(hash, root) {
+		return errMerkleProof
+	}
+	return nil
+}
+
+func microseconds(b []byte) time.Time {
+	us := int64(binary.LittleEndian.Uint64(b))
+	return time.Unix(us/1e6, us%1e6*1e3)
+}
+
+// Verify checks the response to the nonce is signed by the server key and returns midpoint and radius
+func Verify(response, nonce []byte, publicKey ed25519.PublicKey) (midpoint time.Time, radius time.Duration, err error) {
+	m, err := Decode(response)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	cert, _, err := m.message(TagCERT)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	dele, deleBytes, err := cert.message(TagDELE)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	deleSig, err := cert.get(TagSIG, ed25519.SignatureSize)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if !ed25519.Verify(publicKey, append(append([]byte{}, delegationContext...), deleBytes...), deleSig) {
+		return time.Time{}, 0, errDelegationSig
+	}
+	delegated, err := dele.get(TagPUBK, ed25519.PublicKeySize)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	srep, srepBytes, err := m.message(TagSREP)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	sig, err := m.get(TagSIG, ed25519.SignatureSize)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(delegated), append(append([]byte{}, responseContext...), srepBytes...), sig) {
+		return time.Time{}, 0, errResponseSig
+	}
+
+	root, err := srep.get(TagROOT, hashSize)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	path, err := m.get(TagPATH, -1)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	index, err := m.get(TagINDX, 4)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if err := verifyPath(nonce, root, path, binary.LittleEndian.Uint32(index)); err != nil {
+		return time.Time{}, 0, err
+	}
+
+	midp, err := srep.get(TagMIDP, 8)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	radi, err := srep.get(TagRADI, 4)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	mint, err := dele.get(TagMINT, 8)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	maxt, err := dele.get(TagMAXT, 8)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	midpoint = microseconds(midp)
+	if midpoint.Before(microseconds(mint)) || midpoint.After(microseconds(maxt)) {
+		return time.Time{}, 0, errOutsideDelegation
+	}
+	radius = time.Duration(binary.LittleEndian.Uint32(radi)) * time.Microsecond
+	return midpoint, radius, nil
+}
+
+// Query sends request with random nonce to the server and verifies the response
+func Query(s *Server, timeout time.Duration) (*Result, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	request, err := NewRequest(nonce)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("udp", s.Address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	buf := make([]byte, MaxResponseSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	received := time.Now()
+	midpoint, radius, err := Verify(buf[:n], nonce, s.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	delay := received.Sub(sent)
+	return &Result{
+		Midpoint: midpoint,
+		Radius:   radius,
+		Delay:    delay,
+		Offset:   midpoint.Sub(sent.Add(delay / 2)),
+	}, nil
+}
@@ -0,0 +1,234 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package roughtime
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testServer signs batches of nonces like a Roughtime server
+type testServer struct {
+	rootKey     ed25519.PrivateKey
+	onlineKey   ed25519.PrivateKey
+	cert        []byte
+	offset      time.Duration
+	corruptPath bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	_, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	onlinePub, onlineKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	now := time.Now()
+	dele, err := Message{
+		TagPUBK: onlinePub,
+		TagMINT: le64(now.Add(-time.Hour)),
+		TagMAXT: le64(now.Add(time.Hour)),
+	}.Encode()
+	require.NoError(t, err)
+	cert, err := Message{
+		TagDELE: dele,
+		TagSIG:  ed25519.Sign(rootKey, append(append([]byte{}, delegationContext...), dele...)),
+	}.Encode()
+	require.NoError(t, err)
+	return &testServer{rootKey: rootKey, onlineKey: onlineKey, cert: cert}
+}
+
+func (s *testServer) publicKey() ed25519.PublicKey {
+	return s.rootKey.Public().(ed25519.PublicKey)
+}
+
+func le64(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(t.UnixNano()/1e3))
+	return b
+}
+
+// respond returns responses to the batch of nonces, batch size must be power of 2
+func (s *testServer) respond(t *testing.T, nonces [][]byte) [][]byte {
+	levels := [][][]byte{{}}
+	for _, n := range nonces {
+		levels[0] = append(levels[0], leafHash(n))
+	}
+	for len(levels[len(levels)-1]) > 1 {
+		prev := levels[len(levels)-1]
+		var next [][]byte
+		for i := 0; i < len(prev); i += 2 {
+			next = append(next, nodeHash(prev[i], prev[i+1]))
+		}
+		levels = append(levels, next)
+	}
+	root := levels[len(levels)-1][0]
+	radi := make([]byte, 4)
+	binary.LittleEndian.PutUint32(radi, 1000000)
+	srep, err := Message{TagROOT: root, TagMIDP: le64(time.Now().Add(s.offset)), TagRADI: radi}.Encode()
+	require.NoError(t, err)
+	sig := ed25519.Sign(s.onlineKey, append(append([]byte{}, responseContext...), srep...))
+
+	responses := make([][]byte, len(nonces))
+	for i := range nonces {
+		var path []byte
+		index := i
+		for _, level := range levels[:len(levels)-1] {
+			path = append(path, level[index^1]...)
+			index >>= 1
+		}
+		if s.corruptPath && len(path) > 0 {
+			path[0] ^= 0xff
+		}
+		indx := make([]byte, 4)
+		binary.LittleEndian.PutUint32(indx, uint32(i))
+		responses[i], err = Message{TagSIG: sig, TagSREP: srep, TagCERT: s.cert, TagPATH: path, TagINDX: indx}.Encode()
+		require.NoError(t, err)
+	}
+	return responses
+}
+
+func (s *testServer) start(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		buf := make([]byte, MaxResponseSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m, err := Decode(buf[:n])
+			if err != nil || n < RequestSize {
+				continue
+			}
+			nonce := append([]byte{}, m[TagNONC]...)
+			_, _ = conn.WriteTo(s.respond(t, [][]byte{nonce})[0], addr)
+		}
+	}()
+	return conn
+}
+
+func nonces(t *testing.T, count int) [][]byte {
+	var result [][]byte
+	for i := 0; i < count; i++ {
+		n := make([]byte, NonceSize)
+		_, err := rand.Read(n)
+		require.NoError(t, err)
+		result = append(result, n)
+	}
+	return result
+}
+
+func TestNewRequest(t *testing.T) {
+	nonce := nonces(t, 1)[0]
+	b, err := NewRequest(nonce)
+	require.NoError(t, err)
+	require.Len(t, b, RequestSize)
+	m, err := Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, nonce, m[TagNONC])
+
+	_, err = NewRequest(nonce[:10])
+	require.ErrorIs(t, err, errValueLength)
+}
+
+func TestParsePublicKey(t *testing.T) {
+	s := newTestServer(t)
+	key, err := ParsePublicKey(base64.StdEncoding.EncodeToString(s.publicKey()))
+	require.NoError(t, err)
+	require.Equal(t, s.publicKey(), key)
+
+	_, err = ParsePublicKey("AAAA")
+	require.ErrorIs(t, err, errBadPublicKey)
+	_, err = ParsePublicKey("not base64!")
+	require.ErrorIs(t, err, errBadPublicKey)
+}
+
+func TestVerifyBatch(t *testing.T) {
+	s := newTestServer(t)
+	batch := nonces(t, 8)
+	for i, response := range s.respond(t, batch) {
+		midpoint, radius, err := Verify(response, batch[i], s.publicKey())
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), midpoint, time.Second)
+		require.Equal(t, time.Second, radius)
+
+		// response proves only the nonce it was generated for
+		_, _, err = Verify(response, batch[(i+1)%len(batch)], s.publicKey())
+		require.ErrorIs(t, err, errMerkleProof)
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	s := newTestServer(t)
+	batch := nonces(t, 2)
+	responses := s.respond(t, batch)
+
+	// signed by another server
+	other := newTestServer(t)
+	_, _, err := Verify(responses[0], batch[0], other.publicKey())
+	require.ErrorIs(t, err, errDelegationSig)
+
+	// response signed by key which is not delegated
+	other.cert = s.cert
+	_, _, err = Verify(other.respond(t, batch)[0], batch[0], s.publicKey())
+	require.ErrorIs(t, err, errResponseSig)
+
+	s.corruptPath = true
+	_, _, err = Verify(s.respond(t, batch)[0], batch[0], s.publicKey())
+	require.ErrorIs(t, err, errMerkleProof)
+
+	// midpoint outside of delegation
+	s.corruptPath = false
+	s.offset = 2 * time.Hour
+	_, _, err = Verify(s.respond(t, batch)[0], batch[0], s.publicKey())
+	require.ErrorIs(t, err, errOutsideDelegation)
+
+	_, _, err = Verify([]byte{1, 2, 3, 4}, batch[0], s.publicKey())
+	require.Error(t, err)
+}
+
+func TestQuery(t *testing.T) {
+	s := newTestServer(t)
+	s.offset = -300 * time.Millisecond
+	conn := s.start(t)
+	defer conn.Close()
+
+	r, err := Query(&Server{Address: conn.LocalAddr().String(), PublicKey: s.publicKey()}, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, time.Second, r.Radius)
+	require.InDelta(t, -300*time.Millisecond, r.Offset, float64(50*time.Millisecond))
+	require.Less(t, r.Delay, 50*time.Millisecond)
+
+	_, err = Query(&Server{Address: conn.LocalAddr().String(), PublicKey: newTestServer(t).publicKey()}, time.Second)
+	require.ErrorIs(t, err, errDelegationSig)
+}
+
+func TestResultConsistent(t *testing.T) {
+	r := &Result{Offset: 10 * time.Millisecond, Radius: 100 * time.Millisecond, Delay: 20 * time.Millisecond}
+	require.True(t, r.Consistent(0, 0))
+	require.True(t, r.Consistent(120*time.Millisecond, 0))
+	require.False(t, r.Consistent(121*time.Millisecond, 0))
+	require.True(t, r.Consistent(-110*time.Millisecond, 20*time.Millisecond))
+	require.False(t, r.Consistent(-111*time.Millisecond, 20*time.Millisecond))
+}
@@ -0,0 +1,211 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package spoof detects NTP responses which are likely generated by a middlebox,
+such as NAT device answering NTP on behalf of the real server.
+
+Same query is sent from two source ports at once. Replies of a real server
+to both are consistent, while interception often applies to some flows only
+or produces replies with a different reference, stratum or TTL.
+TTL is also compared to common initial values to estimate how far the responder is.
+*/
+package spoof
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// common initial TTL values, replies carry one of them minus the number of hops
+var initialTTLs = []int{64, 128, 255}
+
+// Config of the check
+type Config struct {
+	// Timeout for replies
+	Timeout time.Duration
+	// Ports are local source ports of the two queries. 0 means ephemeral port
+	Ports [2]int
+	// MinHops is the min number of hops expected to the server. Closer replies are flagged
+	MinHops int
+	// MaxOffsetDiff is the max difference of offsets measured via both queries
+	MaxOffsetDiff time.Duration
+}
+
+// DefaultConfig expects the server to be at least one router away
+var DefaultConfig = Config{
+	Timeout:       time.Second,
+	MinHops:       1,
+	MaxOffsetDiff: 10 * time.Millisecond,
+}
+
+// Reply is a reply to a single query
+type Reply struct {
+	LocalPort int           `json:"local_port"`
+	From      string        `json:"from,omitempty"`
+	TTL       int           `json:"ttl"`
+	Hops      int           `json:"hops"`
+	Stratum   uint8         `json:"stratum"`
+	RefID     uint32        `json:"ref_id"`
+	Offset    time.Duration `json:"offset"`
+	Error     string        `json:"error,omitempty"`
+	packet    *ntp.Packet
+}
+
+// Result of the check
+type Result struct {
+	Server     string   `json:"server"`
+	Replies    []*Reply `json:"replies"`
+	Findings   []string `json:"findings"`
+	Suspicious bool     `json:"suspicious"`
+}
+
+// hops estimates the number of hops from TTL of the reply
+func hops(ttl int) int {
+	for _, initial := range initialTTLs {
+		if ttl <= initial {
+			return initial - ttl
+		}
+	}
+	return 0
+}
+
+// Check sends duplicate queries to server (host:port) and inspects replies
+func Check(server string, c *Config) (*Result, error) {
+	addr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, err
+	}
+	conns := make([]*net.UDPConn, len(c.Ports))
+	for i, port := range c.Ports {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+		if err != nil {
+			return nil, fmt.Errorf("listening on port %d: %w", port, err)
+		}
+		defer conn.Close()
+		if err := ntp.TuneSocket(conn, ntp.WithRecvTTL()); err != nil {
+			return nil, err
+		}
+		conns[i] = conn
+	}
+
+	r := &Result{Server: server, Replies: make([]*Reply, len(conns))}
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+		go func(i int, conn *net.UDPConn) {
+			defer wg.Done()
+			r.Replies[i] = query(conn, addr, c.Timeout)
+		}(i, conn)
+	}
+	wg.Wait()
+	r.Findings = inspect(addr, r.Replies, c)
+	r.Suspicious = len(r.Findings) > 0
+	return r, nil
+}
+
+func query(conn *net.UDPConn, addr *net.UDPAddr, timeout time.Duration) *Reply {
+	reply := &Reply{LocalPort: conn.LocalAddr().(*net.UDPAddr).Port}
+	fail := func(err error) *Reply {
+		reply.Error = err.Error()
+		return reply
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return fail(err)
+	}
+	request := &ntp.Packet{Settings: 0x1B}
+	if err := request.SetRandomTransmitTime(); err != nil {
+		return fail(err)
+	}
+	b, err := request.Bytes()
+	if err != nil {
+		return fail(err)
+	}
+	t1 := time.Now()
+	if _, err := conn.WriteToUDP(b, addr); err != nil {
+		return fail(err)
+	}
+	buf := make([]byte, ntp.PacketSizeBytes)
+	oob := make([]byte, ntp.PacketInfoControlSizeBytes)
+	for {
+		n, oobn, _, from, err := conn.ReadMsgUDP(buf, oob)
+		t4 := time.Now()
+		if err != nil {
+			return fail(err)
+		}
+		packet, err := ntp.BytesToPacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		// replies to other queries are not ours, wait for the right one
+		if ntp.MatchOrigin(request, packet) != nil {
+			continue
+		}
+		reply.From = from.String()
+		reply.TTL, _ = ntp.ParseTTL(oob[:oobn])
+		reply.Hops = hops(reply.TTL)
+		reply.Stratum = packet.Stratum
+		reply.RefID = packet.ReferenceID
+		t2 := ntp.Unix(packet.RxTimeSec, packet.RxTimeFrac)
+		t3 := ntp.Unix(packet.TxTimeSec, packet.TxTimeFrac)
+		reply.Offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+		reply.packet = packet
+		return reply
+	}
+}
+
+func inspect(addr *net.UDPAddr, replies []*Reply, c *Config) []string {
+	findings := []string{}
+	ok := []*Reply{}
+	for _, r := range replies {
+		if r.Error != "" {
+			findings = append(findings, fmt.Sprintf("no reply to query from port %d: %s", r.LocalPort, r.Error))
+			continue
+		}
+		ok = append(ok, r)
+		if from, err := net.ResolveUDPAddr("udp", r.From); err == nil && !from.IP.Equal(addr.IP) {
+			findings = append(findings, fmt.Sprintf("reply to query from port %d came from %s", r.LocalPort, r.From))
+		}
+		if r.TTL > 0 && r.Hops < c.MinHops {
+			findings = append(findings, fmt.Sprintf("reply to query from port %d has TTL %d, server is %d hop(s) away, expected at least %d", r.LocalPort, r.TTL, r.Hops, c.MinHops))
+		}
+	}
+	if len(ok) < 2 {
+		return findings
+	}
+	a, b := ok[0], ok[1]
+	if a.TTL != b.TTL {
+		findings = append(findings, fmt.Sprintf("replies have different TTL: %d and %d", a.TTL, b.TTL))
+	}
+	if a.Stratum != b.Stratum {
+		findings = append(findings, fmt.Sprintf("replies have different stratum: %d and %d", a.Stratum, b.Stratum))
+	}
+	if a.RefID != b.RefID {
+		findings = append(findings, fmt.Sprintf("replies have different reference ID: %#08x and %#08x", a.RefID, b.RefID))
+	}
+	if a.packet.RefTimeSec != b.packet.RefTimeSec || a.packet.RefTimeFrac != b.packet.RefTimeFrac {
+		findings = append(findings, "replies have different reference time")
+	}
+	diff := a.Offset - b.Offset
+	if c.MaxOffsetDiff > 0 && (diff > c.MaxOffsetDiff || diff < -c.MaxOffsetDiff) {
+		findings = append(findings, fmt.Sprintf("replies offsets differ by %v", diff))
+	}
+	return findings
+}
@@ -0,0 +1,148 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spoof
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ntp "github.com/facebook/time/ntp/protocol"
+)
+
+// fakeServer replies to every request. answer can alter the reply depending on the client address
+func fakeServer(t *testing.T, answer func(from *net.UDPAddr, reply *ntp.Packet) bool) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	go func() {
+		buf := make([]byte, ntp.PacketSizeBytes)
+		for {
+			_, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			request, err := ntp.BytesToPacket(buf)
+			if err != nil {
+				continue
+			}
+			sec, frac := ntp.Time(time.Now())
+			reply := &ntp.Packet{
+				Settings:     0x24,
+				Stratum:      1,
+				ReferenceID:  0x47505300,
+				RefTimeSec:   sec - 1,
+				OrigTimeSec:  request.TxTimeSec,
+				OrigTimeFrac: request.TxTimeFrac,
+				RxTimeSec:    sec,
+				RxTimeFrac:   frac,
+				TxTimeSec:    sec,
+				TxTimeFrac:   frac,
+			}
+			if answer != nil && !answer(from, reply) {
+				continue
+			}
+			b, _ := reply.Bytes()
+			_, _ = conn.WriteToUDP(b, from)
+		}
+	}()
+	return conn
+}
+
+func TestHops(t *testing.T) {
+	require.Equal(t, 0, hops(64))
+	require.Equal(t, 4, hops(60))
+	require.Equal(t, 8, hops(120))
+	require.Equal(t, 10, hops(245))
+}
+
+func TestCheckConsistent(t *testing.T) {
+	conn := fakeServer(t, nil)
+	defer conn.Close()
+
+	c := DefaultConfig
+	// loopback replies are 0 hops away
+	c.MinHops = 0
+	r, err := Check(conn.LocalAddr().String(), &c)
+	require.NoError(t, err)
+	require.Empty(t, r.Findings)
+	require.False(t, r.Suspicious)
+	require.Len(t, r.Replies, 2)
+	for _, reply := range r.Replies {
+		require.Empty(t, reply.Error)
+		require.Equal(t, 64, reply.TTL)
+		require.Equal(t, uint8(1), reply.Stratum)
+	}
+	require.NotEqual(t, r.Replies[0].LocalPort, r.Replies[1].LocalPort)
+}
+
+func TestCheckTooClose(t *testing.T) {
+	conn := fakeServer(t, nil)
+	defer conn.Close()
+
+	r, err := Check(conn.LocalAddr().String(), &DefaultConfig)
+	require.NoError(t, err)
+	require.True(t, r.Suspicious)
+	require.Len(t, r.Findings, 2)
+	require.Contains(t, r.Findings[0], "has TTL 64, server is 0 hop(s) away, expected at least 1")
+}
+
+func TestCheckInconsistent(t *testing.T) {
+	var first *net.UDPAddr
+	conn := fakeServer(t, func(from *net.UDPAddr, reply *ntp.Packet) bool {
+		// interception of some flows only
+		if first == nil {
+			first = from
+		}
+		if from.Port != first.Port {
+			reply.Stratum = 3
+			reply.ReferenceID = 0xc0000201
+		}
+		return true
+	})
+	defer conn.Close()
+
+	c := DefaultConfig
+	c.MinHops = 0
+	r, err := Check(conn.LocalAddr().String(), &c)
+	require.NoError(t, err)
+	require.True(t, r.Suspicious)
+	require.Len(t, r.Findings, 2)
+	require.Contains(t, r.Findings[0], "replies have different stratum")
+	require.Contains(t, r.Findings[1], "replies have different reference ID")
+}
+
+func TestCheckNoReply(t *testing.T) {
+	var first *net.UDPAddr
+	conn := fakeServer(t, func(from *net.UDPAddr, reply *ntp.Packet) bool {
+		if first == nil {
+			first = from
+		}
+		return from.Port == first.Port
+	})
+	defer conn.Close()
+
+	c := DefaultConfig
+	c.MinHops = 0
+	c.Timeout = 100 * time.Millisecond
+	r, err := Check(conn.LocalAddr().String(), &c)
+	require.NoError(t, err)
+	require.True(t, r.Suspicious)
+	require.Len(t, r.Findings, 1)
+	require.Contains(t, r.Findings[0], "no reply to query from port")
+}
@@ -0,0 +1,277 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Severity is a level of an alert
+type Severity int
+
+// Alert severities in ascending order
+const (
+	SeverityOK Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityToString = map[Severity]string{
+	SeverityOK:       "OK",
+	SeverityWarning:  "WARNING",
+	SeverityCritical: "CRITICAL",
+}
+
+func (s Severity) String() string {
+	str, found := severityToString[s]
+	if !found {
+		return "UNSUPPORTED VALUE"
+	}
+	return str
+}
+
+// Alerts evaluated by Alerter
+const (
+	AlertTemperature  = "temperature"
+	AlertFineCtrl     = "fine_ctrl"
+	AlertCoarseCtrl   = "coarse_ctrl"
+	AlertLockFlapping = "lock_flapping"
+)
+
+// Threshold raises a level once the value reaches it. The level is cleared only after
+// the value goes below the threshold by Hysteresis, so values hovering around
+// the threshold don't produce a storm of alerts. Zero Warning or Critical disables the level
+type Threshold struct {
+	Warning    float64
+	Critical   float64
+	Hysteresis float64
+}
+
+func (t Threshold) raised(value, threshold float64, active bool) bool {
+	if threshold == 0 {
+		return false
+	}
+	if active {
+		return value > threshold-t.Hysteresis
+	}
+	return value >= threshold
+}
+
+// Severity returns severity of the value given the current severity
+func (t Threshold) Severity(value float64, current Severity) Severity {
+	if t.raised(value, t.Critical, current >= SeverityCritical) {
+		return SeverityCritical
+	}
+	if t.raised(value, t.Warning, current >= SeverityWarning) {
+		return SeverityWarning
+	}
+	return SeverityOK
+}
+
+// CtrlLimits is the range of an oscillator control value. Threshold applies to the
+// distance from the middle of the range in percent of the half range,
+// 100 meaning the control value is at the limit and can't steer the oscillator further.
+// Limits depend on the oscillator model, zero range disables the check
+type CtrlLimits struct {
+	Min       int
+	Max       int
+	Threshold Threshold
+}
+
+// usage returns how close the value is to the limits in percent
+func (l CtrlLimits) usage(value int) float64 {
+	half := float64(l.Max-l.Min) / 2
+	return math.Abs(float64(value)-float64(l.Min)-half) / half * 100
+}
+
+// AlertConfig is a set of thresholds applied to polled Status
+type AlertConfig struct {
+	// Temperature of the oscillator in degrees Celsius
+	Temperature Threshold
+	FineCtrl    CtrlLimits
+	CoarseCtrl  CtrlLimits
+	// LockFlapping applies to the number of lock losses within FlapWindow
+	LockFlapping Threshold
+	FlapWindow   time.Duration
+}
+
+// DefaultAlertConfig has temperature and lock flapping thresholds. Control limits are model specific and not checked
+var DefaultAlertConfig = AlertConfig{
+	Temperature:  Threshold{Warning: 70, Critical: 80, Hysteresis: 2},
+	LockFlapping: Threshold{Warning: 3, Critical: 6, Hysteresis: 2},
+	FlapWindow:   time.Hour,
+}
+
+// AlertEvent is a change of alert severity of a single device
+type AlertEvent struct {
+	Address string
+	Alert   string
+	From    Severity
+	To      Severity
+	// Value which caused the change
+	Value float64
+	Time  time.Time
+}
+
+func (e AlertEvent) String() string {
+	return fmt.Sprintf("%s: %s %s -> %s (%v)", e.Address, e.Alert, e.From, e.To, e.Value)
+}
+
+type alertState struct {
+	severity   map[string]Severity
+	status     *Status
+	lastSeen   time.Time
+	lockLosses []time.Time
+}
+
+// Alerter evaluates polled statuses against thresholds and reports
+// alert severity changes instead of raw values
+type Alerter struct {
+	Config AlertConfig
+
+	sync.Mutex
+	devices map[string]*alertState
+}
+
+// NewAlerter returns an Alerter using thresholds from the config
+func NewAlerter(c AlertConfig) *Alerter {
+	return &Alerter{Config: c, devices: map[string]*alertState{}}
+}
+
+// Evaluate applies thresholds to the status of the device read at the time and returns severity changes
+func (a *Alerter) Evaluate(address string, status *Status, at time.Time) []AlertEvent {
+	a.Lock()
+	defer a.Unlock()
+	d, found := a.devices[address]
+	if !found {
+		d = &alertState{severity: map[string]Severity{}}
+		a.devices[address] = d
+	}
+	events := []AlertEvent{}
+	check := func(alert string, t Threshold, value float64) {
+		from := d.severity[alert]
+		to := t.Severity(value, from)
+		if to == from {
+			return
+		}
+		d.severity[alert] = to
+		events = append(events, AlertEvent{Address: address, Alert: alert, From: from, To: to, Value: value, Time: at})
+	}
+
+	check(AlertTemperature, a.Config.Temperature, status.Oscillator.Temperature)
+	if a.Config.FineCtrl.Max > a.Config.FineCtrl.Min {
+		check(AlertFineCtrl, a.Config.FineCtrl.Threshold, a.Config.FineCtrl.usage(status.Oscillator.FineCtrl))
+	}
+	if a.Config.CoarseCtrl.Max > a.Config.CoarseCtrl.Min {
+		check(AlertCoarseCtrl, a.Config.CoarseCtrl.Threshold, a.Config.CoarseCtrl.usage(status.Oscillator.CoarseCtrl))
+	}
+
+	if d.status != nil && d.status.Oscillator.Lock && !status.Oscillator.Lock {
+		d.lockLosses = append(d.lockLosses, at)
+	}
+	// drop lock losses out of the window
+	i := 0
+	for i < len(d.lockLosses) && at.Sub(d.lockLosses[i]) > a.Config.FlapWindow {
+		i++
+	}
+	d.lockLosses = d.lockLosses[i:]
+	check(AlertLockFlapping, a.Config.LockFlapping, float64(len(d.lockLosses)))
+
+	d.status = status
+	d.lastSeen = at
+	return events
+}
+
+// EvaluatePoller evaluates statuses of devices polled since the previous call.
+// Unreachable devices keep their alerts
+func (a *Alerter) EvaluatePoller(p *Poller) []AlertEvent {
+	states := p.Status()
+	addresses := make([]string, 0, len(states))
+	for address := range states {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	events := []AlertEvent{}
+	for _, address := range addresses {
+		s := states[address]
+		if !s.Reachable() {
+			continue
+		}
+		a.Lock()
+		d, found := a.devices[address]
+		seen := found && !s.LastSeen.After(d.lastSeen)
+		a.Unlock()
+		if seen {
+			continue
+		}
+		events = append(events, a.Evaluate(address, s.Status, s.LastSeen)...)
+	}
+	return events
+}
+
+// Run polls devices every Interval of the poller and sends alert severity changes to events until ctx is done
+func (a *Alerter) Run(ctx context.Context, p *Poller, events chan<- AlertEvent) error {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		p.Poll()
+		for _, e := range a.EvaluatePoller(p) {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Active returns alerts above OK severity of every device
+func (a *Alerter) Active() map[string]map[string]Severity {
+	a.Lock()
+	defer a.Unlock()
+	res := map[string]map[string]Severity{}
+	for address, d := range a.devices {
+		for alert, s := range d.severity {
+			if s == SeverityOK {
+				continue
+			}
+			if res[address] == nil {
+				res[address] = map[string]Severity{}
+			}
+			res[address][alert] = s
+		}
+	}
+	return res
+}
+
+// Remove forgets alerts of the device
+func (a *Alerter) Remove(address string) {
+	a.Lock()
+	defer a.Unlock()
+	delete(a.devices, address)
+}
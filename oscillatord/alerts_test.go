@@ -0,0 +1,124 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeverityString(t *testing.T) {
+	require.Equal(t, "OK", SeverityOK.String())
+	require.Equal(t, "CRITICAL", SeverityCritical.String())
+	require.Equal(t, "UNSUPPORTED VALUE", Severity(42).String())
+}
+
+func TestThresholdSeverity(t *testing.T) {
+	th := Threshold{Warning: 70, Critical: 80, Hysteresis: 2}
+	require.Equal(t, SeverityOK, th.Severity(69.9, SeverityOK))
+	require.Equal(t, SeverityWarning, th.Severity(70, SeverityOK))
+	require.Equal(t, SeverityCritical, th.Severity(85, SeverityOK))
+	// hysteresis keeps the level
+	require.Equal(t, SeverityCritical, th.Severity(78.5, SeverityCritical))
+	require.Equal(t, SeverityWarning, th.Severity(78, SeverityCritical))
+	require.Equal(t, SeverityWarning, th.Severity(68.5, SeverityWarning))
+	require.Equal(t, SeverityOK, th.Severity(68, SeverityWarning))
+	// disabled levels
+	require.Equal(t, SeverityOK, Threshold{}.Severity(100, SeverityOK))
+	require.Equal(t, SeverityWarning, Threshold{Warning: 1}.Severity(100, SeverityOK))
+}
+
+func TestCtrlLimitsUsage(t *testing.T) {
+	l := CtrlLimits{Min: 0, Max: 4000}
+	require.InDelta(t, 0, l.usage(2000), 0.001)
+	require.InDelta(t, 50, l.usage(1000), 0.001)
+	require.InDelta(t, 100, l.usage(4000), 0.001)
+}
+
+func TestAlerterEvaluate(t *testing.T) {
+	c := DefaultAlertConfig
+	c.FineCtrl = CtrlLimits{Min: 0, Max: 4000, Threshold: Threshold{Warning: 80, Critical: 95, Hysteresis: 5}}
+	a := NewAlerter(c)
+	now := time.Unix(1640995200, 0)
+	status := func(temp float64, fine int, lock bool) *Status {
+		return &Status{Oscillator: Oscillator{Temperature: temp, FineCtrl: fine, Lock: lock}}
+	}
+
+	require.Empty(t, a.Evaluate("a:1", status(50, 2000, true), now))
+
+	events := a.Evaluate("a:1", status(71, 3800, true), now.Add(time.Second))
+	require.Len(t, events, 2)
+	require.Equal(t, "a:1: temperature OK -> WARNING (71)", events[0].String())
+	require.Equal(t, AlertFineCtrl, events[1].Alert)
+	require.Equal(t, SeverityWarning, events[1].To)
+	require.Equal(t, map[string]map[string]Severity{"a:1": {AlertTemperature: SeverityWarning, AlertFineCtrl: SeverityWarning}}, a.Active())
+
+	// within hysteresis nothing changes
+	require.Empty(t, a.Evaluate("a:1", status(69, 3700, true), now.Add(2*time.Second)))
+
+	events = a.Evaluate("a:1", status(60, 2000, true), now.Add(3*time.Second))
+	require.Len(t, events, 2)
+	require.Equal(t, SeverityOK, events[0].To)
+	require.Empty(t, a.Active())
+
+	a.Remove("a:1")
+	require.Empty(t, a.Evaluate("a:1", status(50, 2000, true), now))
+}
+
+func TestAlerterLockFlapping(t *testing.T) {
+	c := DefaultAlertConfig
+	c.FlapWindow = time.Minute
+	a := NewAlerter(c)
+	now := time.Unix(1640995200, 0)
+	events := []AlertEvent{}
+	for i := 0; i < 6; i++ {
+		lock := i%2 == 0
+		events = append(events, a.Evaluate("a:1", &Status{Oscillator: Oscillator{Lock: lock}}, now.Add(time.Duration(i)*time.Second))...)
+	}
+	require.Len(t, events, 1)
+	require.Equal(t, AlertLockFlapping, events[0].Alert)
+	require.Equal(t, SeverityWarning, events[0].To)
+	require.Equal(t, float64(3), events[0].Value)
+
+	// losses leave the window
+	events = a.Evaluate("a:1", &Status{Oscillator: Oscillator{Lock: true}}, now.Add(2*time.Minute))
+	require.Len(t, events, 1)
+	require.Equal(t, SeverityOK, events[0].To)
+}
+
+func TestAlerterEvaluatePoller(t *testing.T) {
+	hot := &Status{Oscillator: Oscillator{Temperature: 90, Lock: true}}
+	f := &fakeDevices{status: map[string]*Status{"a:1": hot}}
+	p := NewPoller([]string{"a:1", "b:1"}, time.Second, time.Second)
+	p.fetch = f.fetch
+	a := NewAlerter(DefaultAlertConfig)
+
+	p.Poll()
+	events := a.EvaluatePoller(p)
+	require.Len(t, events, 1)
+	require.Equal(t, "a:1: temperature OK -> CRITICAL (90)", events[0].String())
+	// same poll is not evaluated twice
+	require.Empty(t, a.EvaluatePoller(p))
+
+	// unreachable device keeps its alerts
+	f.set("a:1", nil)
+	p.Poll()
+	require.Empty(t, a.EvaluatePoller(p))
+	require.Equal(t, SeverityCritical, a.Active()["a:1"][AlertTemperature])
+}
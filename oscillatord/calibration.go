@@ -0,0 +1,197 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Request is a monitoring request oscillatord acts upon before replying with its state
+type Request int
+
+// from oscillatord src/monitoring.h
+const (
+	RequestNone Request = iota
+	RequestCalibration
+	RequestGNSSStart
+	RequestGNSSStop
+	RequestGNSSSoft
+	RequestGNSSHard
+	RequestGNSSCold
+	RequestFakeHoldoverStart
+	RequestFakeHoldoverStop
+)
+
+var requestToString = map[Request]string{
+	RequestNone:              "NONE",
+	RequestCalibration:       "CALIBRATION",
+	RequestGNSSStart:         "GNSS_START",
+	RequestGNSSStop:          "GNSS_STOP",
+	RequestGNSSSoft:          "GNSS_SOFT",
+	RequestGNSSHard:          "GNSS_HARD",
+	RequestGNSSCold:          "GNSS_COLD",
+	RequestFakeHoldoverStart: "FAKE_HOLDOVER_START",
+	RequestFakeHoldoverStop:  "FAKE_HOLDOVER_STOP",
+}
+
+func (r Request) String() string {
+	s, found := requestToString[r]
+	if !found {
+		return "UNSUPPORTED VALUE"
+	}
+	return s
+}
+
+// DiscipliningCalibration is the disciplining status while calibration is in progress
+const DiscipliningCalibration = "CALIBRATION"
+
+// Disciplining describes state of the disciplining algorithm
+type Disciplining struct {
+	Status               string  `json:"status"`
+	ConvergenceCount     int     `json:"current_phase_convergence_count"`
+	ConvergenceThreshold int     `json:"valid_phase_convergence_threshold"`
+	ConvergenceProgress  float64 `json:"convergence_progress"`
+	ReadyForHoldover     bool    `json:"ready_for_holdover"`
+}
+
+// CalibrationParameters are results of the last calibration stored by oscillatord
+type CalibrationParameters struct {
+	CtrlNodesLength   int       `json:"ctrl_nodes_length"`
+	CtrlLoadNodes     []float64 `json:"ctrl_load_nodes"`
+	CtrlDriftCoeffs   []float64 `json:"ctrl_drift_coeffs"`
+	CoarseEquilibrium int       `json:"coarse_equilibrium"`
+	Valid             bool      `json:"calibration_valid"`
+	// Date is unix time of the calibration
+	Date int64 `json:"calibration_date"`
+}
+
+// Time returns time of the calibration
+func (p *CalibrationParameters) Time() time.Time {
+	return time.Unix(p.Date, 0)
+}
+
+// Calibration is calibration related state reported by oscillatord
+type Calibration struct {
+	Disciplining Disciplining          `json:"disciplining"`
+	Parameters   CalibrationParameters `json:"calibration_parameters"`
+}
+
+// InProgress returns true while oscillatord is calibrating
+func (c *Calibration) InProgress() bool {
+	return c.Disciplining.Status == DiscipliningCalibration
+}
+
+// Response is the reply of oscillatord to a Request
+type Response struct {
+	Status      *Status
+	Schema      Schema
+	Calibration Calibration
+}
+
+type calibrationPayload struct {
+	Disciplining           Disciplining `json:"disciplining"`
+	DiscipliningParameters struct {
+		CalibrationParameters CalibrationParameters `json:"calibration_parameters"`
+	} `json:"disciplining_parameters"`
+}
+
+// SendRequest sends the request to oscillatord via monitoring port connection and reads the reply
+func SendRequest(conn io.ReadWriter, r Request) (*Response, error) {
+	req, err := json.Marshal(struct {
+		Request Request `json:"request"`
+	}{Request: r})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return nil, fmt.Errorf("writing to oscillatord conn: %w", err)
+	}
+	// calibration parameters don't fit into a single small read, decode the whole JSON object
+	var data json.RawMessage
+	if err := json.NewDecoder(conn).Decode(&data); err != nil {
+		return nil, fmt.Errorf("reading from oscillatord conn: %w", err)
+	}
+	status, schema, err := DecodeStatus(data)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling JSON: %w", err)
+	}
+	var p calibrationPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("unmarshalling JSON: %w", err)
+	}
+	return &Response{
+		Status: status,
+		Schema: schema,
+		Calibration: Calibration{
+			Disciplining: p.Disciplining,
+			Parameters:   p.DiscipliningParameters.CalibrationParameters,
+		},
+	}, nil
+}
+
+// StartCalibration requests oscillatord to calibrate the oscillator.
+// Calibration takes hours, use WaitCalibration to wait for its results
+func StartCalibration(conn io.ReadWriter) (*Calibration, error) {
+	resp, err := SendRequest(conn, RequestCalibration)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Calibration, nil
+}
+
+// ReadCalibration reads calibration state and results of the last calibration
+func ReadCalibration(conn io.ReadWriter) (*Calibration, error) {
+	resp, err := SendRequest(conn, RequestNone)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Calibration, nil
+}
+
+// WaitCalibration polls oscillatord every interval until calibration newer than since is finished.
+// Each poll talks to oscillatord over a new connection returned by dial
+func WaitCalibration(ctx context.Context, dial func() (io.ReadWriteCloser, error), since time.Time, interval time.Duration) (*Calibration, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c, err := readCalibration(dial)
+		if err != nil {
+			return nil, err
+		}
+		if !c.InProgress() && c.Parameters.Date > 0 && !c.Parameters.Time().Before(since) {
+			return c, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func readCalibration(dial func() (io.ReadWriteCloser, error)) (*Calibration, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to oscillatord: %w", err)
+	}
+	defer conn.Close()
+	return ReadCalibration(conn)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func calibrationData(status string, date int64) string {
+	return fmt.Sprintf(`{ "oscillator": { "model": "mRO50", "fine_ctrl": 10, "coarse_ctrl": 20, "lock": true, "temperature": 45.5 }, "gnss": { "fix": 5, "fix_ok": true, "antenna_power": 1, "antenna_status": 2, "ls_change": 0, "leap_seconds": 18 }, "disciplining": { "status": "%s", "current_phase_convergence_count": 3, "valid_phase_convergence_threshold": 10, "convergence_progress": 30.0, "ready_for_holdover": false }, "disciplining_parameters": { "calibration_parameters": { "ctrl_nodes_length": 3, "ctrl_load_nodes": [0.25, 0.5, 0.75], "ctrl_drift_coeffs": [1.5, 0.5, -0.5], "coarse_equilibrium": 4000, "calibration_valid": true, "calibration_date": %d } } }`, status, date)
+}
+
+// serveRequest reads a single request and replies with data
+func serveRequest(t *testing.T, server net.Conn, data string) Request {
+	var req struct {
+		Request Request `json:"request"`
+	}
+	require.NoError(t, json.NewDecoder(server).Decode(&req))
+	_, err := server.Write([]byte(data))
+	require.NoError(t, err)
+	return req.Request
+}
+
+func TestRequestString(t *testing.T) {
+	require.Equal(t, "CALIBRATION", RequestCalibration.String())
+	require.Equal(t, "UNSUPPORTED VALUE", Request(100).String())
+}
+
+func TestStartCalibration(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	requests := make(chan Request, 1)
+	go func() {
+		requests <- serveRequest(t, server, calibrationData(DiscipliningCalibration, 0))
+	}()
+	c, err := StartCalibration(client)
+	require.NoError(t, err)
+	require.Equal(t, RequestCalibration, <-requests)
+	require.True(t, c.InProgress())
+	require.Equal(t, 10, c.Disciplining.ConvergenceThreshold)
+}
+
+func TestSendRequest(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go serveRequest(t, server, calibrationData("LOCK_HIGH_RESOLUTION", 1650000000))
+
+	resp, err := SendRequest(client, RequestNone)
+	require.NoError(t, err)
+	require.Equal(t, SchemaV3, resp.Schema)
+	require.Equal(t, "mRO50", resp.Status.Oscillator.Model)
+	require.True(t, resp.Status.GNSS.FixOK)
+	require.False(t, resp.Calibration.InProgress())
+	require.Equal(t, CalibrationParameters{
+		CtrlNodesLength:   3,
+		CtrlLoadNodes:     []float64{0.25, 0.5, 0.75},
+		CtrlDriftCoeffs:   []float64{1.5, 0.5, -0.5},
+		CoarseEquilibrium: 4000,
+		Valid:             true,
+		Date:              1650000000,
+	}, resp.Calibration.Parameters)
+	require.Equal(t, time.Unix(1650000000, 0), resp.Calibration.Parameters.Time())
+}
+
+func TestSendRequestGarbage(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go serveRequest(t, server, "garbage")
+	_, err := SendRequest(client, RequestNone)
+	require.Error(t, err)
+}
+
+func TestWaitCalibration(t *testing.T) {
+	since := time.Unix(1650000000, 0)
+	replies := []string{
+		// old calibration results
+		calibrationData("LOCK_HIGH_RESOLUTION", 1640000000),
+		calibrationData(DiscipliningCalibration, 1640000000),
+		calibrationData("LOCK_LOW_RESOLUTION", 1650000100),
+	}
+	polls := 0
+	dial := func() (io.ReadWriteCloser, error) {
+		client, server := net.Pipe()
+		data := replies[polls]
+		polls++
+		go func() {
+			defer server.Close()
+			serveRequest(t, server, data)
+		}()
+		return client, nil
+	}
+	c, err := WaitCalibration(context.Background(), dial, since, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, 3, polls)
+	require.Equal(t, int64(1650000100), c.Parameters.Date)
+}
+
+func TestWaitCalibrationCancel(t *testing.T) {
+	dial := func() (io.ReadWriteCloser, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			serveRequest(t, server, calibrationData(DiscipliningCalibration, 0))
+		}()
+		return client, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := WaitCalibration(ctx, dial, time.Now(), time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
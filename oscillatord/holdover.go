@@ -0,0 +1,211 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// HoldoverConfig describes the oscillator used to bound time error during holdover.
+// Frequency values are in ppb (ns of error per second)
+type HoldoverConfig struct {
+	// InitialError is the time error when GNSS is lost
+	InitialError time.Duration
+	// FrequencyError is the residual frequency error right after discipline is lost
+	FrequencyError float64
+	// Aging is the frequency drift in ppb per day
+	Aging float64
+	// TempCoefficient is the frequency change in ppb per degree Celsius
+	TempCoefficient float64
+	// FineCtrlStep is the frequency change in ppb per unit of fine_ctrl
+	FineCtrlStep float64
+	// MaxOffsets limits the number of NTP offsets used for the estimate
+	MaxOffsets int
+}
+
+// DefaultHoldoverConfig is a conservative config for a rubidium oscillator
+var DefaultHoldoverConfig = HoldoverConfig{
+	InitialError:    100 * time.Nanosecond,
+	FrequencyError:  0.05,
+	Aging:           0.05,
+	TempCoefficient: 0.1,
+	FineCtrlStep:    0.001,
+	MaxOffsets:      64,
+}
+
+// offsetSample is a single NTP offset measurement
+type offsetSample struct {
+	at     time.Time
+	offset float64
+	// err is the measurement error, half of the round trip delay
+	err float64
+}
+
+// HoldoverEstimator fuses oscillatord data and NTP offsets to estimate expected time error during holdover.
+// Without NTP offsets the error is bounded by the oscillator model.
+// With NTP offsets the error is extrapolated from the measurements
+type HoldoverEstimator struct {
+	Config HoldoverConfig
+
+	sync.Mutex
+	holdover     bool
+	start        time.Time
+	startTemp    float64
+	maxTempDelta float64
+	startCtrl    int
+	maxCtrlDelta int
+	offsets      []offsetSample
+}
+
+// NewHoldoverEstimator returns a new HoldoverEstimator
+func NewHoldoverEstimator(config HoldoverConfig) *HoldoverEstimator {
+	return &HoldoverEstimator{Config: config}
+}
+
+// AddStatus feeds the status reported by oscillatord at the time t.
+// Holdover starts when GNSS fix is lost and ends when it's back
+func (h *HoldoverEstimator) AddStatus(t time.Time, status *Status) {
+	h.Lock()
+	defer h.Unlock()
+	if status.GNSS.FixOK {
+		h.holdover = false
+		h.offsets = nil
+		return
+	}
+	if !h.holdover {
+		h.holdover = true
+		h.start = t
+		h.startTemp = status.Oscillator.Temperature
+		h.maxTempDelta = 0
+		h.startCtrl = status.Oscillator.FineCtrl
+		h.maxCtrlDelta = 0
+		h.offsets = nil
+		return
+	}
+	h.maxTempDelta = math.Max(h.maxTempDelta, math.Abs(status.Oscillator.Temperature-h.startTemp))
+	ctrlDelta := status.Oscillator.FineCtrl - h.startCtrl
+	if ctrlDelta < 0 {
+		ctrlDelta = -ctrlDelta
+	}
+	if ctrlDelta > h.maxCtrlDelta {
+		h.maxCtrlDelta = ctrlDelta
+	}
+}
+
+// AddOffset feeds NTP offset measured at the time t with the round trip delay.
+// Offsets are only used during holdover
+func (h *HoldoverEstimator) AddOffset(t time.Time, offset, delay time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	if !h.holdover {
+		return
+	}
+	h.offsets = append(h.offsets, offsetSample{at: t, offset: float64(offset), err: float64(delay) / 2})
+	if h.Config.MaxOffsets > 0 && len(h.offsets) > h.Config.MaxOffsets {
+		h.offsets = h.offsets[len(h.offsets)-h.Config.MaxOffsets:]
+	}
+}
+
+// InHoldover returns true if GNSS fix is lost
+func (h *HoldoverEstimator) InHoldover() bool {
+	h.Lock()
+	defer h.Unlock()
+	return h.holdover
+}
+
+// Since returns holdover duration at the time now
+func (h *HoldoverEstimator) Since(now time.Time) time.Duration {
+	h.Lock()
+	defer h.Unlock()
+	if !h.holdover {
+		return 0
+	}
+	return now.Sub(h.start)
+}
+
+// Uncertainty returns expected time error at the time now
+func (h *HoldoverEstimator) Uncertainty(now time.Time) time.Duration {
+	h.Lock()
+	defer h.Unlock()
+	if !h.holdover {
+		return h.Config.InitialError
+	}
+	switch len(h.offsets) {
+	case 0:
+		return time.Duration(h.modelError(now.Sub(h.start).Seconds()))
+	case 1:
+		// single measurement plus what the model allows to accumulate since
+		o := h.offsets[0]
+		growth := h.modelError(now.Sub(h.start).Seconds()) - h.modelError(o.at.Sub(h.start).Seconds())
+		return time.Duration(math.Abs(o.offset) + o.err + growth)
+	}
+	return time.Duration(h.extrapolate(now))
+}
+
+// frequencyError returns the worst frequency error in ppb not counting aging
+func (h *HoldoverEstimator) frequencyError() float64 {
+	return h.Config.FrequencyError +
+		h.Config.TempCoefficient*h.maxTempDelta +
+		h.Config.FineCtrlStep*float64(h.maxCtrlDelta)
+}
+
+// modelError returns the worst time error in ns after elapsed seconds of holdover
+func (h *HoldoverEstimator) modelError(elapsed float64) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	aging := h.Config.Aging / (24 * 3600)
+	return float64(h.Config.InitialError) + h.frequencyError()*elapsed + 0.5*aging*elapsed*elapsed
+}
+
+// extrapolate fits a line through NTP offsets and returns the expected error at the time now
+func (h *HoldoverEstimator) extrapolate(now time.Time) float64 {
+	// least squares fit of offset = a + b*x, where x is seconds since holdover start
+	var sumX, sumY, sumXY, sumXX, maxErr float64
+	n := float64(len(h.offsets))
+	for _, o := range h.offsets {
+		x := o.at.Sub(h.start).Seconds()
+		sumX += x
+		sumY += o.offset
+		sumXY += x * o.offset
+		sumXX += x * x
+		maxErr = math.Max(maxErr, o.err)
+	}
+	x := now.Sub(h.start).Seconds()
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		// all offsets measured at the same time
+		return math.Abs(sumY/n) + maxErr + h.modelError(x) - h.modelError(sumX/n)
+	}
+	b := (n*sumXY - sumX*sumY) / denom
+	a := (sumY - b*sumX) / n
+
+	// residual spread widens the estimate
+	var residual float64
+	for _, o := range h.offsets {
+		residual = math.Max(residual, math.Abs(o.offset-(a+b*o.at.Sub(h.start).Seconds())))
+	}
+	// frequency can still drift due to aging after the last measurement
+	last := h.offsets[len(h.offsets)-1].at.Sub(h.start).Seconds()
+	drift := 0.0
+	if x > last {
+		drift = 0.5 * h.Config.Aging / (24 * 3600) * (x - last) * (x - last)
+	}
+	return math.Abs(a+b*x) + maxErr + residual + drift
+}
@@ -0,0 +1,98 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func holdoverStatus(fixOK bool, temp float64, fineCtrl int) *Status {
+	return &Status{
+		Oscillator: Oscillator{Temperature: temp, FineCtrl: fineCtrl},
+		GNSS:       GNSS{FixOK: fixOK},
+	}
+}
+
+func TestHoldoverEstimatorLocked(t *testing.T) {
+	h := NewHoldoverEstimator(DefaultHoldoverConfig)
+	now := time.Now()
+	h.AddStatus(now, holdoverStatus(true, 40, 0))
+	h.AddOffset(now, time.Millisecond, time.Millisecond)
+	require.False(t, h.InHoldover())
+	require.Equal(t, time.Duration(0), h.Since(now))
+	require.Equal(t, DefaultHoldoverConfig.InitialError, h.Uncertainty(now.Add(time.Hour)))
+}
+
+func TestHoldoverEstimatorModel(t *testing.T) {
+	h := NewHoldoverEstimator(HoldoverConfig{
+		InitialError:    100 * time.Nanosecond,
+		FrequencyError:  1,
+		TempCoefficient: 0.5,
+		FineCtrlStep:    0.1,
+	})
+	start := time.Now()
+	h.AddStatus(start, holdoverStatus(false, 40, 100))
+	require.True(t, h.InHoldover())
+	require.Equal(t, 100*time.Nanosecond, h.Uncertainty(start))
+	// 1ppb for 1000s
+	require.Equal(t, 1100*time.Nanosecond, h.Uncertainty(start.Add(1000*time.Second)))
+
+	// temperature changed by 2C and fine_ctrl by 10: 1 + 0.5*2 + 0.1*10 = 3ppb
+	h.AddStatus(start.Add(time.Second), holdoverStatus(false, 38, 110))
+	h.AddStatus(start.Add(2*time.Second), holdoverStatus(false, 39, 105))
+	require.Equal(t, 3100*time.Nanosecond, h.Uncertainty(start.Add(1000*time.Second)))
+	require.Equal(t, 1000*time.Second, h.Since(start.Add(1000*time.Second)))
+
+	// GNSS is back
+	h.AddStatus(start.Add(3*time.Second), holdoverStatus(true, 39, 105))
+	require.False(t, h.InHoldover())
+	require.Equal(t, 100*time.Nanosecond, h.Uncertainty(start.Add(1000*time.Second)))
+}
+
+func TestHoldoverEstimatorAging(t *testing.T) {
+	h := NewHoldoverEstimator(HoldoverConfig{Aging: 1})
+	start := time.Now()
+	h.AddStatus(start, holdoverStatus(false, 40, 0))
+	// 0.5 * 1ppb/day * (1 day)^2 = 43200ns
+	require.Equal(t, 43200*time.Nanosecond, h.Uncertainty(start.Add(24*time.Hour)))
+}
+
+func TestHoldoverEstimatorSingleOffset(t *testing.T) {
+	h := NewHoldoverEstimator(HoldoverConfig{FrequencyError: 1})
+	start := time.Now()
+	h.AddStatus(start, holdoverStatus(false, 40, 0))
+	h.AddOffset(start.Add(100*time.Second), -2*time.Microsecond, 200*time.Nanosecond)
+	// 2us offset + 100ns measurement error + 1ppb for 100s since measurement
+	require.Equal(t, 2200*time.Nanosecond, h.Uncertainty(start.Add(200*time.Second)))
+}
+
+func TestHoldoverEstimatorOffsets(t *testing.T) {
+	h := NewHoldoverEstimator(HoldoverConfig{FrequencyError: 100, MaxOffsets: 3})
+	start := time.Now()
+	h.AddStatus(start, holdoverStatus(false, 40, 0))
+	// outlier which is going to be evicted
+	h.AddOffset(start, time.Second, 0)
+	// clock runs 10ppb fast
+	for i := 1; i <= 3; i++ {
+		h.AddOffset(start.Add(time.Duration(i)*100*time.Second), time.Duration(i)*time.Microsecond, 0)
+	}
+	// extrapolated, much better than the model
+	require.Equal(t, 10*time.Microsecond, h.Uncertainty(start.Add(1000*time.Second)))
+}
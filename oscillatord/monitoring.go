@@ -23,7 +23,6 @@ All references throughout the code relate to the https://github.com/Orolia2s/osc
 package oscillatord
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 )
@@ -190,9 +189,9 @@ func ReadStatus(conn io.ReadWriter) (*Status, error) {
 	if n == 0 {
 		return nil, fmt.Errorf("read 0 bytes from oscillatord")
 	}
-	var status Status
-	if err := json.Unmarshal(buf[:n], &status); err != nil {
+	status, _, err := DecodeStatus(buf[:n])
+	if err != nil {
 		return nil, fmt.Errorf("unmarshalling JSON: %w", err)
 	}
-	return &status, nil
+	return status, nil
 }
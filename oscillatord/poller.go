@@ -0,0 +1,286 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/facebook/time/metrics"
+)
+
+// EventType is a type of device state change
+type EventType int
+
+// Device state changes reported by Poller
+const (
+	EventUnreachable EventType = iota
+	EventReachable
+	EventLockLost
+	EventLockGained
+	EventFixLost
+	EventFixGained
+)
+
+var eventTypeToString = map[EventType]string{
+	EventUnreachable: "UNREACHABLE",
+	EventReachable:   "REACHABLE",
+	EventLockLost:    "LOCK LOST",
+	EventLockGained:  "LOCK GAINED",
+	EventFixLost:     "FIX LOST",
+	EventFixGained:   "FIX GAINED",
+}
+
+func (e EventType) String() string {
+	s, found := eventTypeToString[e]
+	if !found {
+		return "UNSUPPORTED VALUE"
+	}
+	return s
+}
+
+// Event is a state change of a single device
+type Event struct {
+	Address string
+	Type    EventType
+	Time    time.Time
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s: %s", e.Address, e.Type)
+}
+
+// DeviceState is the latest known state of a single oscillatord endpoint
+type DeviceState struct {
+	Address string
+	// Status is the last successfully read status. Nil if never read
+	Status *Status
+	// LastSeen is the time of the last successful poll
+	LastSeen time.Time
+	// LastPoll is the time of the last poll attempt
+	LastPoll time.Time
+	// Err is the error of the last poll attempt
+	Err error
+	// Failures is the number of consecutive failed polls
+	Failures int
+}
+
+// Reachable returns whether the last poll succeeded
+func (d DeviceState) Reachable() bool {
+	return d.Status != nil && d.Err == nil
+}
+
+// FetchStatus connects to oscillatord monitoring address and reads reported Status
+func FetchStatus(address string, timeout time.Duration) (*Status, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to oscillatord: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("setting connection deadline: %w", err)
+	}
+	return ReadStatus(conn)
+}
+
+// Poller polls multiple oscillatord endpoints concurrently and tracks their state
+type Poller struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	// Metrics receives poll results and device state. Nothing is recorded if nil
+	Metrics metrics.Registry
+
+	sync.Mutex
+	devices map[string]*DeviceState
+	fetch   func(address string, timeout time.Duration) (*Status, error)
+}
+
+// NewPoller returns a Poller for oscillatord monitoring addresses (host:port)
+func NewPoller(addresses []string, interval, timeout time.Duration) *Poller {
+	p := &Poller{
+		Interval: interval,
+		Timeout:  timeout,
+		devices:  map[string]*DeviceState{},
+		fetch:    FetchStatus,
+	}
+	for _, a := range addresses {
+		p.devices[a] = &DeviceState{Address: a}
+	}
+	return p
+}
+
+// Add starts polling the address
+func (p *Poller) Add(address string) {
+	p.Lock()
+	defer p.Unlock()
+	if _, found := p.devices[address]; !found {
+		p.devices[address] = &DeviceState{Address: address}
+	}
+}
+
+// Remove stops polling the address
+func (p *Poller) Remove(address string) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.devices, address)
+}
+
+// Status returns a copy of the latest state of every device
+func (p *Poller) Status() map[string]DeviceState {
+	p.Lock()
+	defer p.Unlock()
+	res := make(map[string]DeviceState, len(p.devices))
+	for a, d := range p.devices {
+		res[a] = *d
+	}
+	return res
+}
+
+// Poll polls all devices concurrently once and returns state changes
+func (p *Poller) Poll() []Event {
+	p.Lock()
+	addresses := make([]string, 0, len(p.devices))
+	for a := range p.devices {
+		addresses = append(addresses, a)
+	}
+	p.Unlock()
+	sort.Strings(addresses)
+
+	type result struct {
+		status *Status
+		err    error
+		at     time.Time
+		took   time.Duration
+	}
+	results := make([]result, len(addresses))
+	var wg sync.WaitGroup
+	for i, a := range addresses {
+		wg.Add(1)
+		go func(i int, a string) {
+			defer wg.Done()
+			start := time.Now()
+			s, err := p.fetch(a, p.Timeout)
+			at := time.Now()
+			results[i] = result{status: s, err: err, at: at, took: at.Sub(start)}
+		}(i, a)
+	}
+	wg.Wait()
+
+	p.Lock()
+	defer p.Unlock()
+	events := []Event{}
+	for i, a := range addresses {
+		d, found := p.devices[a]
+		if !found {
+			// removed while polling
+			continue
+		}
+		events = append(events, d.update(results[i].status, results[i].err, results[i].at)...)
+		p.record(d, results[i].took)
+	}
+	return events
+}
+
+// record reports result of the poll which took the duration to the metrics registry
+func (p *Poller) record(d *DeviceState, took time.Duration) {
+	r := metrics.Default(p.Metrics)
+	labels := metrics.Labels{"address": d.Address}
+	r.Counter("oscillatord_polls_total", "Polls of oscillatord", labels).Inc()
+	r.Histogram("oscillatord_poll_duration_seconds", "Duration of oscillatord polls", metrics.DefaultBuckets, labels).Observe(took.Seconds())
+	reachable := r.Gauge("oscillatord_reachable", "Whether the last poll of oscillatord succeeded", labels)
+	if !d.Reachable() {
+		r.Counter("oscillatord_poll_failures_total", "Failed polls of oscillatord", labels).Inc()
+		reachable.Set(0)
+		return
+	}
+	reachable.Set(1)
+	r.Gauge("oscillatord_oscillator_lock", "Whether the oscillator is locked", labels).Set(boolToFloat(d.Status.Oscillator.Lock))
+	r.Gauge("oscillatord_gnss_fix_ok", "Whether GNSS has a valid fix", labels).Set(boolToFloat(d.Status.GNSS.FixOK))
+	r.Gauge("oscillatord_temperature_celsius", "Temperature of the oscillator", labels).Set(d.Status.Oscillator.Temperature)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// update applies poll result to the device state and returns state changes.
+// Changes are only reported against a previously known state
+func (d *DeviceState) update(status *Status, err error, at time.Time) []Event {
+	events := []Event{}
+	event := func(t EventType) {
+		events = append(events, Event{Address: d.Address, Type: t, Time: at})
+	}
+	d.LastPoll = at
+	known := !d.LastSeen.IsZero() || d.Failures > 0
+	if err != nil {
+		if known && d.Err == nil {
+			event(EventUnreachable)
+		}
+		d.Err = err
+		d.Failures++
+		return events
+	}
+	if known && d.Err != nil {
+		event(EventReachable)
+	}
+	if prev := d.Status; prev != nil {
+		if prev.Oscillator.Lock && !status.Oscillator.Lock {
+			event(EventLockLost)
+		}
+		if !prev.Oscillator.Lock && status.Oscillator.Lock {
+			event(EventLockGained)
+		}
+		if prev.GNSS.FixOK && !status.GNSS.FixOK {
+			event(EventFixLost)
+		}
+		if !prev.GNSS.FixOK && status.GNSS.FixOK {
+			event(EventFixGained)
+		}
+	}
+	d.Status = status
+	d.Err = nil
+	d.Failures = 0
+	d.LastSeen = at
+	return events
+}
+
+// Run polls devices every Interval and sends state changes to events until ctx is done
+func (p *Poller) Run(ctx context.Context, events chan<- Event) error {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		for _, e := range p.Poll() {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/facebook/time/metrics"
+)
+
+type fakeDevices struct {
+	sync.Mutex
+	status map[string]*Status
+}
+
+func (f *fakeDevices) fetch(address string, timeout time.Duration) (*Status, error) {
+	f.Lock()
+	defer f.Unlock()
+	s, found := f.status[address]
+	if !found {
+		return nil, errors.New("connection refused")
+	}
+	c := *s
+	return &c, nil
+}
+
+func (f *fakeDevices) set(address string, s *Status) {
+	f.Lock()
+	defer f.Unlock()
+	if s == nil {
+		delete(f.status, address)
+		return
+	}
+	f.status[address] = s
+}
+
+func eventTypes(events []Event) []string {
+	res := []string{}
+	for _, e := range events {
+		res = append(res, e.String())
+	}
+	return res
+}
+
+func TestPollerPoll(t *testing.T) {
+	locked := &Status{Oscillator: Oscillator{Lock: true}, GNSS: GNSS{FixOK: true}}
+	f := &fakeDevices{status: map[string]*Status{"a:1": locked, "b:1": locked}}
+	p := NewPoller([]string{"a:1", "b:1", "c:1"}, time.Second, time.Second)
+	p.fetch = f.fetch
+
+	// initial state is not reported
+	require.Empty(t, p.Poll())
+	s := p.Status()
+	require.Len(t, s, 3)
+	require.True(t, s["a:1"].Reachable())
+	require.False(t, s["c:1"].Reachable())
+	require.Equal(t, 1, s["c:1"].Failures)
+
+	f.set("a:1", &Status{Oscillator: Oscillator{Lock: false}, GNSS: GNSS{FixOK: false}})
+	f.set("b:1", nil)
+	f.set("c:1", locked)
+	require.Equal(t, []string{
+		"a:1: LOCK LOST",
+		"a:1: FIX LOST",
+		"b:1: UNREACHABLE",
+		"c:1: REACHABLE",
+	}, eventTypes(p.Poll()))
+
+	f.set("a:1", locked)
+	require.Equal(t, []string{"a:1: LOCK GAINED", "a:1: FIX GAINED"}, eventTypes(p.Poll()))
+	s = p.Status()
+	require.Equal(t, 2, s["b:1"].Failures)
+	// last known status is kept
+	require.NotNil(t, s["b:1"].Status)
+	require.Error(t, s["b:1"].Err)
+
+	p.Remove("b:1")
+	p.Add("d:1")
+	require.Empty(t, p.Poll())
+	s = p.Status()
+	require.Len(t, s, 3)
+	require.NotContains(t, s, "b:1")
+}
+
+func TestPollerRun(t *testing.T) {
+	f := &fakeDevices{status: map[string]*Status{"a:1": {Oscillator: Oscillator{Lock: true}}}}
+	p := NewPoller([]string{"a:1"}, time.Millisecond, time.Second)
+	p.fetch = f.fetch
+	require.Empty(t, p.Poll())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event)
+	errCh := make(chan error)
+	go func() {
+		errCh <- p.Run(ctx, events)
+	}()
+	f.set("a:1", &Status{Oscillator: Oscillator{Lock: false}})
+	e := <-events
+	require.Equal(t, EventLockLost, e.Type)
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+}
+
+func TestPollerMetrics(t *testing.T) {
+	f := &fakeDevices{status: map[string]*Status{"a:1": {Oscillator: Oscillator{Lock: true, Temperature: 45.5}}}}
+	p := NewPoller([]string{"a:1", "b:1"}, time.Second, time.Second)
+	p.fetch = f.fetch
+	r := metrics.NewPrometheus()
+	p.Metrics = r
+	p.Poll()
+	p.Poll()
+
+	var b bytes.Buffer
+	require.NoError(t, r.WritePrometheus(&b))
+	out := b.String()
+	require.Contains(t, out, `oscillatord_polls_total{address="a:1"} 2`)
+	require.Contains(t, out, `oscillatord_polls_total{address="b:1"} 2`)
+	require.Contains(t, out, `oscillatord_poll_failures_total{address="b:1"} 2`)
+	require.NotContains(t, out, `oscillatord_poll_failures_total{address="a:1"}`)
+	require.Contains(t, out, `oscillatord_poll_duration_seconds_count{address="a:1"} 2`)
+	require.Contains(t, out, `oscillatord_reachable{address="a:1"} 1`)
+	require.Contains(t, out, `oscillatord_reachable{address="b:1"} 0`)
+	require.Contains(t, out, `oscillatord_oscillator_lock{address="a:1"} 1`)
+	require.Contains(t, out, `oscillatord_gnss_fix_ok{address="a:1"} 0`)
+	require.Contains(t, out, `oscillatord_temperature_celsius{address="a:1"} 45.5`)
+}
+
+func TestFetchStatus(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 1)
+		_, _ = conn.Read(buf)
+		fmt.Fprint(conn, `{"oscillator": {"model": "sa5x", "lock": true}, "gnss": {"fixOk": true}}`)
+	}()
+
+	s, err := FetchStatus(ln.Addr().String(), time.Second)
+	require.NoError(t, err)
+	require.True(t, s.Oscillator.Lock)
+	require.True(t, s.GNSS.FixOK)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Schema is a version of the oscillatord monitoring JSON format.
+// Field names changed between oscillatord releases, all of them are decoded into the same Status
+type Schema int
+
+// Known schemas
+const (
+	SchemaUnknown Schema = iota
+	// SchemaV1 is used by early releases with camelCase names such as fineCtrl and antennaPower
+	SchemaV1
+	// SchemaV2 mixes snake_case names with fixOk and lsChange
+	SchemaV2
+	// SchemaV3 uses snake_case names only, such as fix_ok and ls_change
+	SchemaV3
+)
+
+var schemaToString = map[Schema]string{
+	SchemaUnknown: "unknown",
+	SchemaV1:      "v1",
+	SchemaV2:      "v2",
+	SchemaV3:      "v3",
+}
+
+func (s Schema) String() string {
+	str, found := schemaToString[s]
+	if !found {
+		return "UNSUPPORTED VALUE"
+	}
+	return str
+}
+
+// schemaProbes are names of fields which only exist in a particular schema
+var schemaProbes = []struct {
+	schema  Schema
+	section string
+	field   string
+}{
+	{SchemaV1, "oscillator", "fineCtrl"},
+	{SchemaV1, "oscillator", "coarseCtrl"},
+	{SchemaV1, "gnss", "antennaPower"},
+	{SchemaV1, "gnss", "leapSeconds"},
+	{SchemaV3, "gnss", "fix_ok"},
+	{SchemaV3, "gnss", "ls_change"},
+	{SchemaV2, "oscillator", "fine_ctrl"},
+	{SchemaV2, "gnss", "fixOk"},
+	{SchemaV2, "gnss", "antenna_power"},
+}
+
+// DetectSchema probes field names of the payload to find out its schema
+func DetectSchema(data []byte) (Schema, error) {
+	sections := map[string]map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return SchemaUnknown, err
+	}
+	for _, p := range schemaProbes {
+		if _, ok := sections[p.section][p.field]; ok {
+			return p.schema, nil
+		}
+	}
+	return SchemaUnknown, nil
+}
+
+type statusV1 struct {
+	Oscillator struct {
+		Model       string  `json:"model"`
+		FineCtrl    int     `json:"fineCtrl"`
+		CoarseCtrl  int     `json:"coarseCtrl"`
+		Lock        bool    `json:"lock"`
+		Temperature float64 `json:"temperature"`
+	} `json:"oscillator"`
+	GNSS struct {
+		Fix           GNSSFix          `json:"fix"`
+		FixOK         bool             `json:"fixOk"`
+		AntennaPower  AntennaPower     `json:"antennaPower"`
+		AntennaStatus AntennaStatus    `json:"antennaStatus"`
+		LSChange      LeapSecondChange `json:"lsChange"`
+		LeapSeconds   int              `json:"leapSeconds"`
+	} `json:"gnss"`
+}
+
+type statusV3 struct {
+	Oscillator Oscillator `json:"oscillator"`
+	GNSS       struct {
+		Fix           GNSSFix          `json:"fix"`
+		FixOK         bool             `json:"fix_ok"`
+		AntennaPower  AntennaPower     `json:"antenna_power"`
+		AntennaStatus AntennaStatus    `json:"antenna_status"`
+		LSChange      LeapSecondChange `json:"ls_change"`
+		LeapSeconds   int              `json:"leap_seconds"`
+	} `json:"gnss"`
+}
+
+// DecodeStatus detects the schema of the payload and decodes it into Status.
+// Payloads without any of the probed fields are decoded as SchemaV2
+func DecodeStatus(data []byte) (*Status, Schema, error) {
+	schema, err := DetectSchema(data)
+	if err != nil {
+		return nil, SchemaUnknown, err
+	}
+	switch schema {
+	case SchemaV1:
+		var s statusV1
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, schema, err
+		}
+		return &Status{
+			Oscillator: Oscillator(s.Oscillator),
+			GNSS:       GNSS(s.GNSS),
+		}, schema, nil
+	case SchemaV3:
+		var s statusV3
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, schema, err
+		}
+		return &Status{Oscillator: s.Oscillator, GNSS: GNSS(s.GNSS)}, schema, nil
+	case SchemaV2, SchemaUnknown:
+		var s Status
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, schema, err
+		}
+		return &s, schema, nil
+	}
+	return nil, schema, fmt.Errorf("unsupported schema %s", schema)
+}
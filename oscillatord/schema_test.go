@@ -0,0 +1,79 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var wantStatus = &Status{
+	Oscillator: Oscillator{
+		Model:       "sa5x",
+		FineCtrl:    12,
+		CoarseCtrl:  3,
+		Lock:        true,
+		Temperature: 45.5,
+	},
+	GNSS: GNSS{
+		Fix:           Fix3D,
+		FixOK:         true,
+		AntennaPower:  AntPowerOn,
+		AntennaStatus: AntStatusOK,
+		LSChange:      LeapAddSecond,
+		LeapSeconds:   18,
+	},
+}
+
+func TestDecodeStatus(t *testing.T) {
+	payloads := map[Schema]string{
+		SchemaV1: `{ "oscillator": { "model": "sa5x", "fineCtrl": 12, "coarseCtrl": 3, "lock": true, "temperature": 45.5 }, "gnss": { "fix": 5, "fixOk": true, "antennaPower": 1, "antennaStatus": 2, "lsChange": 1, "leapSeconds": 18 } }`,
+		SchemaV2: `{ "oscillator": { "model": "sa5x", "fine_ctrl": 12, "coarse_ctrl": 3, "lock": true, "temperature": 45.5 }, "gnss": { "fix": 5, "fixOk": true, "antenna_power": 1, "antenna_status": 2, "lsChange": 1, "leap_seconds": 18 } }`,
+		SchemaV3: `{ "oscillator": { "model": "sa5x", "fine_ctrl": 12, "coarse_ctrl": 3, "lock": true, "temperature": 45.5 }, "gnss": { "fix": 5, "fix_ok": true, "antenna_power": 1, "antenna_status": 2, "ls_change": 1, "leap_seconds": 18 }, "clock": { "class": "Lock" } }`,
+	}
+	for schema, payload := range payloads {
+		t.Run(schema.String(), func(t *testing.T) {
+			detected, err := DetectSchema([]byte(payload))
+			require.NoError(t, err)
+			require.Equal(t, schema, detected)
+
+			status, detected, err := DecodeStatus([]byte(payload))
+			require.NoError(t, err)
+			require.Equal(t, schema, detected)
+			require.Equal(t, wantStatus, status)
+		})
+	}
+}
+
+func TestDecodeStatusUnknown(t *testing.T) {
+	status, schema, err := DecodeStatus([]byte(`{ "oscillator": { "model": "sa5x", "lock": true } }`))
+	require.NoError(t, err)
+	require.Equal(t, SchemaUnknown, schema)
+	require.Equal(t, "sa5x", status.Oscillator.Model)
+	require.True(t, status.Oscillator.Lock)
+
+	_, _, err = DecodeStatus([]byte(`{ fdkfjd }`))
+	require.Error(t, err)
+	_, _, err = DecodeStatus([]byte(`{ "gnss": { "fix_ok": "yes" } }`))
+	require.Error(t, err)
+}
+
+func TestSchemaString(t *testing.T) {
+	require.Equal(t, "v1", SchemaV1.String())
+	require.Equal(t, "UNSUPPORTED VALUE", Schema(42).String())
+}
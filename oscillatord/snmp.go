@@ -0,0 +1,95 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"math"
+	"sort"
+
+	"github.com/facebook/time/snmp"
+)
+
+// DefaultSNMPPrefix is the OID of the oscillatord subtree in the experimental arc
+var DefaultSNMPPrefix = snmp.MustParseOID("1.3.6.1.3.2958")
+
+// Objects under the SNMP prefix:
+//
+//	prefix.1.0 - number of devices
+//	prefix.2.1.<column>.<index> - device table, index is 1-based position of the device sorted by address
+const (
+	snmpDeviceCount = 1
+	snmpDeviceTable = 2
+	snmpDeviceEntry = 1
+)
+
+// Columns of the device table
+const (
+	SNMPColAddress       = 1  // OCTET STRING
+	SNMPColReachable     = 2  // TruthValue
+	SNMPColFailures      = 3  // Gauge32, consecutive failed polls
+	SNMPColModel         = 4  // OCTET STRING
+	SNMPColLock          = 5  // TruthValue
+	SNMPColTemperature   = 6  // INTEGER, millidegrees Celsius
+	SNMPColFineCtrl      = 7  // INTEGER
+	SNMPColCoarseCtrl    = 8  // INTEGER
+	SNMPColGNSSFix       = 9  // INTEGER, GNSSFix
+	SNMPColGNSSFixOK     = 10 // TruthValue
+	SNMPColAntennaStatus = 11 // INTEGER, AntennaStatus
+	SNMPColAntennaPower  = 12 // INTEGER, AntennaPower
+	SNMPColLeapSeconds   = 13 // INTEGER
+	SNMPColLSChange      = 14 // INTEGER, LeapSecondChange
+)
+
+// SNMPVariables returns SNMP variables describing the devices under the prefix.
+// Status columns are omitted for devices which were never read.
+func SNMPVariables(prefix snmp.OID, devices map[string]DeviceState) []snmp.Variable {
+	addresses := make([]string, 0, len(devices))
+	for a := range devices {
+		addresses = append(addresses, a)
+	}
+	sort.Strings(addresses)
+
+	vars := []snmp.Variable{
+		{OID: prefix.Append(snmpDeviceCount, 0), Value: snmp.Gauge32(len(addresses))},
+	}
+	for i, a := range addresses {
+		d := devices[a]
+		index := uint32(i + 1)
+		col := func(c uint32, value interface{}) {
+			vars = append(vars, snmp.Variable{OID: prefix.Append(snmpDeviceTable, snmpDeviceEntry, c, index), Value: value})
+		}
+		col(SNMPColAddress, d.Address)
+		col(SNMPColReachable, d.Reachable())
+		col(SNMPColFailures, snmp.Gauge32(d.Failures))
+		if d.Status == nil {
+			continue
+		}
+		s := d.Status
+		col(SNMPColModel, s.Oscillator.Model)
+		col(SNMPColLock, s.Oscillator.Lock)
+		col(SNMPColTemperature, int64(math.Round(s.Oscillator.Temperature*1000)))
+		col(SNMPColFineCtrl, s.Oscillator.FineCtrl)
+		col(SNMPColCoarseCtrl, s.Oscillator.CoarseCtrl)
+		col(SNMPColGNSSFix, int(s.GNSS.Fix))
+		col(SNMPColGNSSFixOK, s.GNSS.FixOK)
+		col(SNMPColAntennaStatus, int(s.GNSS.AntennaStatus))
+		col(SNMPColAntennaPower, int(s.GNSS.AntennaPower))
+		col(SNMPColLeapSeconds, s.GNSS.LeapSeconds)
+		col(SNMPColLSChange, int(s.GNSS.LSChange))
+	}
+	return vars
+}
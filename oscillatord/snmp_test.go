@@ -0,0 +1,68 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oscillatord
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/facebook/time/snmp"
+)
+
+func TestSNMPVariables(t *testing.T) {
+	prefix := snmp.MustParseOID("1.3.6.1.3.1")
+	devices := map[string]DeviceState{
+		"[::1]:2958": {
+			Address: "[::1]:2958",
+			Status: &Status{
+				Oscillator: Oscillator{Model: "sa5x", FineCtrl: 100, CoarseCtrl: 2, Lock: true, Temperature: 45.1234},
+				GNSS:       GNSS{Fix: Fix3D, FixOK: true, AntennaPower: AntPowerOn, AntennaStatus: AntStatusOK, LSChange: LeapAddSecond, LeapSeconds: 18},
+			},
+		},
+		"10.0.0.1:2958": {
+			Address:  "10.0.0.1:2958",
+			Err:      errors.New("connection refused"),
+			Failures: 3,
+		},
+	}
+	vars := SNMPVariables(prefix, devices)
+	entry := func(col, index uint32) snmp.OID {
+		return prefix.Append(2, 1, col, index)
+	}
+	require.Equal(t, []snmp.Variable{
+		{OID: prefix.Append(1, 0), Value: snmp.Gauge32(2)},
+		{OID: entry(SNMPColAddress, 1), Value: "10.0.0.1:2958"},
+		{OID: entry(SNMPColReachable, 1), Value: false},
+		{OID: entry(SNMPColFailures, 1), Value: snmp.Gauge32(3)},
+		{OID: entry(SNMPColAddress, 2), Value: "[::1]:2958"},
+		{OID: entry(SNMPColReachable, 2), Value: true},
+		{OID: entry(SNMPColFailures, 2), Value: snmp.Gauge32(0)},
+		{OID: entry(SNMPColModel, 2), Value: "sa5x"},
+		{OID: entry(SNMPColLock, 2), Value: true},
+		{OID: entry(SNMPColTemperature, 2), Value: int64(45123)},
+		{OID: entry(SNMPColFineCtrl, 2), Value: 100},
+		{OID: entry(SNMPColCoarseCtrl, 2), Value: 2},
+		{OID: entry(SNMPColGNSSFix, 2), Value: int(Fix3D)},
+		{OID: entry(SNMPColGNSSFixOK, 2), Value: true},
+		{OID: entry(SNMPColAntennaStatus, 2), Value: int(AntStatusOK)},
+		{OID: entry(SNMPColAntennaPower, 2), Value: int(AntPowerOn)},
+		{OID: entry(SNMPColLeapSeconds, 2), Value: 18},
+		{OID: entry(SNMPColLSChange, 2), Value: int(LeapAddSecond)},
+	}, vars)
+}
@@ -34,6 +34,9 @@ const (
 // ioctlPTPSysOffsetExtended is an IOCTL to get extended offset
 var ioctlPTPSysOffsetExtended = ioctl.IOWR(ptpClkMagic, 9, unsafe.Sizeof(PTPSysOffsetExtended{}))
 
+// ioctlPTPSysOffsetPrecise is an IOCTL to get cross timestamp
+var ioctlPTPSysOffsetPrecise = ioctl.IOWR(ptpClkMagic, 8, unsafe.Sizeof(PTPSysOffsetPrecise{}))
+
 // Ifreq is the request we send with SIOCETHTOOL IOCTL
 // as per Linux kernel's include/uapi/linux/if.h
 type Ifreq struct {
@@ -67,6 +70,14 @@ type PTPSysOffsetExtended struct {
 	TS [ptpMaxSamples][3]PTPClockTime
 }
 
+// PTPSysOffsetPrecise as defined in linux/ptp_clock.h
+type PTPSysOffsetPrecise struct {
+	Device      PTPClockTime
+	SysRealtime PTPClockTime
+	SysMonoRaw  PTPClockTime
+	Reserved    [4]uint32 /* Reserved for future use. */
+}
+
 // IfaceInfo uses SIOCETHTOOL ioctl to get information for the give nic, i.e. eth0.
 func IfaceInfo(iface string) (*EthtoolTSinfo, error) {
 	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
@@ -76,6 +76,17 @@ func sysoffEstimateExtended(extended *PTPSysOffsetExtended) SysoffResult {
 	}
 }
 
+// cross timestamp is taken by hardware at the same moment, so there is no delay
+func sysoffPrecise(precise *PTPSysOffsetPrecise) SysoffResult {
+	sysTime := precise.SysRealtime.Time()
+	phcTime := precise.Device.Time()
+	return SysoffResult{
+		SysTime: sysTime,
+		PHCTime: phcTime,
+		Offset:  sysTime.Sub(phcTime),
+	}
+}
+
 // TimeAndOffset returns time we got from network card + offset
 func TimeAndOffset(iface string, method TimeMethod) (SysoffResult, error) {
 	info, err := IfaceInfo(iface)
@@ -113,7 +124,12 @@ func TimeAndOffsetFromDevice(device string, method TimeMethod) (SysoffResult, er
 			return SysoffResult{}, err
 		}
 		return sysoffEstimateExtended(extended), nil
-
+	case MethodIoctlSysOffsetPrecise:
+		precise, err := ReadPTPSysOffsetPrecise(device)
+		if err != nil {
+			return SysoffResult{}, err
+		}
+		return sysoffPrecise(precise), nil
 	}
 	return SysoffResult{}, fmt.Errorf("unknown method to get PHC time %q", method)
 }
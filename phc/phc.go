@@ -49,10 +49,11 @@ type TimeMethod string
 const (
 	MethodSyscallClockGettime    TimeMethod = "syscall_clock_gettime"
 	MethodIoctlSysOffsetExtended TimeMethod = "ioctl_PTP_SYS_OFFSET_EXTENDED"
+	MethodIoctlSysOffsetPrecise  TimeMethod = "ioctl_PTP_SYS_OFFSET_PRECISE"
 )
 
 // SupportedMethods is a list of supported TimeMethods
-var SupportedMethods = []TimeMethod{MethodSyscallClockGettime, MethodIoctlSysOffsetExtended}
+var SupportedMethods = []TimeMethod{MethodSyscallClockGettime, MethodIoctlSysOffsetExtended, MethodIoctlSysOffsetPrecise}
 
 // Time returns time we got from network card
 func Time(iface string, method TimeMethod) (time.Time, error) {
@@ -74,7 +75,12 @@ func Time(iface string, method TimeMethod) (time.Time, error) {
 		}
 		latest := extended.TS[extended.NSamples-1]
 		return latest[1].Time(), nil
-
+	case MethodIoctlSysOffsetPrecise:
+		precise, err := ReadPTPSysOffsetPrecise(device)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return precise.Device.Time(), nil
 	}
 	return time.Time{}, fmt.Errorf("unknown method to get PHC time %q", method)
 }
@@ -113,3 +119,22 @@ func ReadPTPSysOffsetExtended(device string, nsamples int) (*PTPSysOffsetExtende
 	}
 	return res, nil
 }
+
+// ReadPTPSysOffsetPrecise gets cross timestamp of PHC and SYS time. Only supported by some hardware
+func ReadPTPSysOffsetPrecise(device string) (*PTPSysOffsetPrecise, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	res := &PTPSysOffsetPrecise{}
+	_, _, errno := unix.Syscall(
+		unix.SYS_IOCTL, uintptr(f.Fd()),
+		uintptr(ioctlPTPSysOffsetPrecise),
+		uintptr(unsafe.Pointer(res)),
+	)
+	if errno != 0 {
+		return nil, fmt.Errorf("failed PTP_SYS_OFFSET_PRECISE %s (%d)", unix.ErrnoName(errno), errno)
+	}
+	return res, nil
+}
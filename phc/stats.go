@@ -0,0 +1,153 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// outlierMADs is how many median absolute deviations from the median a sample may be before it's an outlier
+const outlierMADs = 3
+
+var errNoSysoffSamples = errors.New("no samples")
+
+// OffsetStats is a summary of multiple PHC and SYS clock offset measurements
+type OffsetStats struct {
+	// Samples is the number of measurements taken
+	Samples int
+	// Used is the number of measurements left after outliers were filtered out
+	Used int
+	// Offset is the median offset of used measurements
+	Offset time.Duration
+	// MeanOffset is the mean offset of used measurements
+	MeanOffset time.Duration
+	MinOffset  time.Duration
+	MaxOffset  time.Duration
+	// Delay is the median delay of used measurements
+	Delay    time.Duration
+	MinDelay time.Duration
+	// Dispersion is the standard deviation of used offsets
+	Dispersion time.Duration
+}
+
+func median(values []time.Duration) time.Duration {
+	sorted := append([]time.Duration{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// outliers marks values further than outlierMADs median absolute deviations from the median
+func outliers(values []time.Duration, marked []bool) {
+	m := median(values)
+	deviations := make([]time.Duration, len(values))
+	for i, v := range values {
+		deviations[i] = abs(v - m)
+	}
+	limit := outlierMADs * median(deviations)
+	for i, v := range values {
+		if abs(v-m) > limit {
+			marked[i] = true
+		}
+	}
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// offsetStats filters out measurements with outlying delay or offset and summarizes the rest
+func offsetStats(results []SysoffResult) (*OffsetStats, error) {
+	if len(results) == 0 {
+		return nil, errNoSysoffSamples
+	}
+	offsets := make([]time.Duration, len(results))
+	delays := make([]time.Duration, len(results))
+	for i, r := range results {
+		offsets[i] = r.Offset
+		delays[i] = r.Delay
+	}
+	marked := make([]bool, len(results))
+	outliers(delays, marked)
+	outliers(offsets, marked)
+
+	used := []SysoffResult{}
+	for i, r := range results {
+		if !marked[i] {
+			used = append(used, r)
+		}
+	}
+	// nothing agrees, use everything
+	if len(used) == 0 {
+		used = results
+	}
+
+	s := &OffsetStats{Samples: len(results), Used: len(used)}
+	offsets = offsets[:0]
+	delays = delays[:0]
+	var sum float64
+	for i, r := range used {
+		offsets = append(offsets, r.Offset)
+		delays = append(delays, r.Delay)
+		sum += float64(r.Offset)
+		if i == 0 || r.Offset < s.MinOffset {
+			s.MinOffset = r.Offset
+		}
+		if i == 0 || r.Offset > s.MaxOffset {
+			s.MaxOffset = r.Offset
+		}
+		if i == 0 || r.Delay < s.MinDelay {
+			s.MinDelay = r.Delay
+		}
+	}
+	mean := sum / float64(len(used))
+	var variance float64
+	for _, o := range offsets {
+		variance += (float64(o) - mean) * (float64(o) - mean)
+	}
+	s.MeanOffset = time.Duration(mean)
+	s.Dispersion = time.Duration(math.Sqrt(variance / float64(len(used))))
+	s.Offset = median(offsets)
+	s.Delay = median(delays)
+	return s, nil
+}
+
+// OffsetStatsFromDevice takes n measurements of PHC and SYS clock offset interval apart and summarizes them
+func OffsetStatsFromDevice(device string, method TimeMethod, n int, interval time.Duration) (*OffsetStats, error) {
+	results := make([]SysoffResult, 0, n)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		r, err := TimeAndOffsetFromDevice(device, method)
+		if err != nil {
+			return nil, fmt.Errorf("measurement %d: %w", i, err)
+		}
+		results = append(results, r)
+	}
+	return offsetStats(results)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetStats(t *testing.T) {
+	results := []SysoffResult{
+		{Offset: 100, Delay: 500},
+		{Offset: 110, Delay: 510},
+		{Offset: 90, Delay: 490},
+		{Offset: 105, Delay: 505},
+		{Offset: 95, Delay: 495},
+		// delay outlier, e.g. preempted
+		{Offset: 100, Delay: 50000},
+		// offset outlier
+		{Offset: 10000, Delay: 500},
+	}
+	s, err := offsetStats(results)
+	require.NoError(t, err)
+	require.Equal(t, &OffsetStats{
+		Samples:    7,
+		Used:       5,
+		Offset:     100,
+		MeanOffset: 100,
+		MinOffset:  90,
+		MaxOffset:  110,
+		Delay:      500,
+		MinDelay:   490,
+		Dispersion: 7,
+	}, s)
+
+	_, err = offsetStats(nil)
+	require.ErrorIs(t, err, errNoSysoffSamples)
+
+	// single sample
+	s, err = offsetStats([]SysoffResult{{Offset: -time.Microsecond, Delay: time.Microsecond}})
+	require.NoError(t, err)
+	require.Equal(t, 1, s.Used)
+	require.Equal(t, -time.Microsecond, s.Offset)
+	require.Equal(t, time.Duration(0), s.Dispersion)
+}
+
+func TestSysoffPrecise(t *testing.T) {
+	r := sysoffPrecise(&PTPSysOffsetPrecise{
+		Device:      PTPClockTime{Sec: 1640000037, NSec: 100},
+		SysRealtime: PTPClockTime{Sec: 1640000000, NSec: 300},
+	})
+	require.Equal(t, -37*time.Second+200, r.Offset)
+	require.Equal(t, time.Duration(0), r.Delay)
+}
@@ -0,0 +1,215 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package pps implements RFC 2783 PPS API on top of Linux PPS kernel interface.
+It allows reading assert/clear event timestamps with sequence numbers from /dev/ppsX devices.
+*/
+package pps
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"github.com/vtolstov/go-ioctl"
+	"golang.org/x/sys/unix"
+)
+
+// Mode is a set of PPS capabilities or mode flags as defined in linux/pps.h
+type Mode int32
+
+// PPS mode flags
+const (
+	CaptureAssert Mode = 0x01
+	CaptureClear  Mode = 0x02
+	CaptureBoth   Mode = 0x03
+	OffsetAssert  Mode = 0x10
+	OffsetClear   Mode = 0x20
+	EchoAssert    Mode = 0x40
+	EchoClear     Mode = 0x80
+	CanWait       Mode = 0x100
+	CanPoll       Mode = 0x200
+	TSFmtTSpec    Mode = 0x1000
+)
+
+// Missing from sys/unix package, defined in Linux include/uapi/linux/pps.h
+const (
+	ppsMagic       = 'p'
+	ppsAPIVersion  = 1
+	ppsTimeInvalid = 1 << 0
+)
+
+// ioctls are defined with pointer arguments, so the size is the size of a pointer
+var (
+	ioctlPPSGetParams = ioctl.IOR(ppsMagic, 0xa1, unsafe.Sizeof(uintptr(0)))
+	ioctlPPSSetParams = ioctl.IOW(ppsMagic, 0xa2, unsafe.Sizeof(uintptr(0)))
+	ioctlPPSGetCap    = ioctl.IOR(ppsMagic, 0xa3, unsafe.Sizeof(uintptr(0)))
+	ioctlPPSFetch     = ioctl.IOWR(ppsMagic, 0xa4, unsafe.Sizeof(uintptr(0)))
+)
+
+// KTime as defined in linux/pps.h
+type KTime struct {
+	Sec   int64
+	NSec  int32
+	Flags uint32
+}
+
+// Time returns KTime as time.Time
+func (t KTime) Time() time.Time {
+	return time.Unix(t.Sec, int64(t.NSec))
+}
+
+// KInfo as defined in linux/pps.h
+type KInfo struct {
+	AssertSequence uint32
+	ClearSequence  uint32
+	AssertTu       KTime
+	ClearTu        KTime
+	CurrentMode    int32
+}
+
+// FData as defined in linux/pps.h
+type FData struct {
+	Info    KInfo
+	Timeout KTime
+}
+
+// KParams as defined in linux/pps.h
+type KParams struct {
+	APIVersion  int32
+	Mode        int32
+	AssertOffTu KTime
+	ClearOffTu  KTime
+}
+
+// Event is a PPS event capture
+type Event struct {
+	AssertSequence uint32
+	ClearSequence  uint32
+	Assert         time.Time
+	Clear          time.Time
+	Mode           Mode
+}
+
+// Device is an open PPS device such as /dev/pps0
+type Device struct {
+	f *os.File
+}
+
+// Open opens PPS device
+func Open(path string) (*Device, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Device{f: f}, nil
+}
+
+// Close closes PPS device
+func (d *Device) Close() error {
+	return d.f.Close()
+}
+
+func (d *Device) ioctl(name string, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, d.f.Fd(), req, uintptr(arg))
+	if errno != 0 {
+		return fmt.Errorf("failed %s %s (%d)", name, unix.ErrnoName(errno), errno)
+	}
+	return nil
+}
+
+// Capabilities returns modes supported by the device
+func (d *Device) Capabilities() (Mode, error) {
+	var caps int32
+	if err := d.ioctl("PPS_GETCAP", ioctlPPSGetCap, unsafe.Pointer(&caps)); err != nil {
+		return 0, err
+	}
+	return Mode(caps), nil
+}
+
+// Params returns current parameters of the device
+func (d *Device) Params() (*KParams, error) {
+	params := &KParams{}
+	if err := d.ioctl("PPS_GETPARAMS", ioctlPPSGetParams, unsafe.Pointer(params)); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// SetParams sets parameters of the device. Requires write access
+func (d *Device) SetParams(params *KParams) error {
+	params.APIVersion = ppsAPIVersion
+	return d.ioctl("PPS_SETPARAMS", ioctlPPSSetParams, unsafe.Pointer(params))
+}
+
+// SetMode enables capture of the events in mode keeping the rest of parameters
+func (d *Device) SetMode(mode Mode) error {
+	params, err := d.Params()
+	if err != nil {
+		return err
+	}
+	params.Mode = int32(mode | TSFmtTSpec)
+	return d.SetParams(params)
+}
+
+// Fetch returns the latest event. It waits up to timeout for the next event.
+// Negative timeout waits forever, zero timeout returns immediately
+func (d *Device) Fetch(timeout time.Duration) (*Event, error) {
+	data := &FData{}
+	if timeout < 0 {
+		data.Timeout.Flags = ppsTimeInvalid
+	} else {
+		data.Timeout.Sec = int64(timeout / time.Second)
+		data.Timeout.NSec = int32(timeout % time.Second)
+	}
+	if err := d.ioctl("PPS_FETCH", ioctlPPSFetch, unsafe.Pointer(data)); err != nil {
+		return nil, err
+	}
+	return eventFromKInfo(&data.Info), nil
+}
+
+func eventFromKInfo(info *KInfo) *Event {
+	return &Event{
+		AssertSequence: info.AssertSequence,
+		ClearSequence:  info.ClearSequence,
+		Assert:         info.AssertTu.Time(),
+		Clear:          info.ClearTu.Time(),
+		Mode:           Mode(info.CurrentMode),
+	}
+}
+
+// Next waits up to timeout for an assert event newer than seq
+func (d *Device) Next(seq uint32, timeout time.Duration) (*Event, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		left := time.Until(deadline)
+		if left < 0 {
+			left = 0
+		}
+		e, err := d.Fetch(left)
+		if err != nil {
+			return nil, err
+		}
+		if e.AssertSequence != seq {
+			return e, nil
+		}
+		if left == 0 {
+			return nil, fmt.Errorf("no PPS event within %v", timeout)
+		}
+	}
+}
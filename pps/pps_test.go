@@ -0,0 +1,78 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pps
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructSizes(t *testing.T) {
+	require.Equal(t, uintptr(16), unsafe.Sizeof(KTime{}))
+	require.Equal(t, uintptr(48), unsafe.Sizeof(KInfo{}))
+	require.Equal(t, uintptr(64), unsafe.Sizeof(FData{}))
+	require.Equal(t, uintptr(40), unsafe.Sizeof(KParams{}))
+}
+
+func TestIoctls(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("64 bit only")
+	}
+	require.Equal(t, uintptr(0x800870a1), ioctlPPSGetParams)
+	require.Equal(t, uintptr(0x400870a2), ioctlPPSSetParams)
+	require.Equal(t, uintptr(0x800870a3), ioctlPPSGetCap)
+	require.Equal(t, uintptr(0xc00870a4), ioctlPPSFetch)
+}
+
+func TestEventFromKInfo(t *testing.T) {
+	info := &KInfo{
+		AssertSequence: 42,
+		ClearSequence:  41,
+		AssertTu:       KTime{Sec: 1640000000, NSec: 12},
+		ClearTu:        KTime{Sec: 1639999999, NSec: 500000000},
+		CurrentMode:    int32(CaptureAssert | TSFmtTSpec),
+	}
+	require.Equal(t, &Event{
+		AssertSequence: 42,
+		ClearSequence:  41,
+		Assert:         time.Unix(1640000000, 12),
+		Clear:          time.Unix(1639999999, 500000000),
+		Mode:           CaptureAssert | TSFmtTSpec,
+	}, eventFromKInfo(info))
+}
+
+func TestNotPPSDevice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pps0")
+	require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+	d, err := Open(path)
+	require.NoError(t, err)
+	defer d.Close()
+
+	_, err = d.Fetch(0)
+	require.Error(t, err)
+	_, err = d.Capabilities()
+	require.Error(t, err)
+	require.Error(t, d.SetMode(CaptureAssert))
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
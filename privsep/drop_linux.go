@@ -0,0 +1,143 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package privsep
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var errCgo = errors.New("capabilities can only be changed on all threads of binaries built with CGO_ENABLED=0")
+
+// allThreads runs syscall on every thread of the process, as capabilities and most prctl settings are per thread
+func allThreads(trap, a1, a2, a3 uintptr) error {
+	_, _, errno := syscall.AllThreadsSyscall(trap, a1, a2, a3)
+	if errno == syscall.ENOTSUP {
+		return errCgo
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func prctl(option int, arg uintptr) error {
+	return allThreads(unix.SYS_PRCTL, uintptr(option), arg, 0)
+}
+
+// mask returns capabilities as a bit mask
+func mask(caps []Capability) uint64 {
+	var m uint64
+	for _, c := range caps {
+		m |= 1 << uint(c)
+	}
+	return m
+}
+
+// lastCap returns the highest capability supported by the kernel
+func lastCap() int {
+	b, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return unix.CAP_LAST_CAP
+	}
+	c, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return unix.CAP_LAST_CAP
+	}
+	return c
+}
+
+// capset sets effective and permitted capabilities of all threads, clearing inheritable ones
+func capset(m uint64) error {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := [2]unix.CapUserData{
+		{Effective: uint32(m), Permitted: uint32(m)},
+		{Effective: uint32(m >> 32), Permitted: uint32(m >> 32)},
+	}
+	err := allThreads(unix.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+	runtime.KeepAlive(&hdr)
+	runtime.KeepAlive(&data)
+	return err
+}
+
+// setIDs switches user and group of all threads. Capabilities are cleared unless PR_SET_KEEPCAPS is set
+func setIDs(uid, gid int) error {
+	// go runtime applies these to all threads
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setting groups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setting gid %d: %w", gid, err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setting uid %d: %w", uid, err)
+	}
+	return nil
+}
+
+// verify checks root privileges are gone for good
+func verify(uid int) error {
+	if uid != 0 && syscall.Setuid(0) == nil {
+		return errors.New("root privileges can still be regained")
+	}
+	return nil
+}
+
+func drop(uid, gid int, keep []Capability) error {
+	m := mask(keep)
+	// kept capabilities survive setuid in the permitted set
+	if err := prctl(unix.PR_SET_KEEPCAPS, 1); err != nil {
+		// cgo binaries can still lose all capabilities by switching the user
+		if errors.Is(err, errCgo) && len(keep) == 0 {
+			if err := setIDs(uid, gid); err != nil {
+				return err
+			}
+			return verify(uid)
+		}
+		return fmt.Errorf("keeping capabilities: %w", err)
+	}
+	// dropped capabilities can't be regained, even by executing other binaries
+	for c := 0; c <= lastCap(); c++ {
+		if m&(1<<uint(c)) != 0 {
+			continue
+		}
+		if err := prctl(unix.PR_CAPBSET_DROP, uintptr(c)); err != nil {
+			return fmt.Errorf("dropping %s from bounding set: %w", Capability(c), err)
+		}
+	}
+	if err := setIDs(uid, gid); err != nil {
+		return err
+	}
+	if err := capset(m); err != nil {
+		return fmt.Errorf("setting capabilities: %w", err)
+	}
+	if err := prctl(unix.PR_SET_KEEPCAPS, 0); err != nil {
+		return fmt.Errorf("resetting keep capabilities: %w", err)
+	}
+	if err := prctl(unix.PR_SET_NO_NEW_PRIVS, 1); err != nil {
+		return fmt.Errorf("setting no new privileges: %w", err)
+	}
+	return verify(uid)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package privsep
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// capEff returns effective capabilities of the process as in /proc/self/status
+func capEff(t *testing.T) string {
+	b, err := ioutil.ReadFile("/proc/self/status")
+	require.NoError(t, err)
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "CapEff:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "CapEff:"))
+		}
+	}
+	t.Fatal("no CapEff in /proc/self/status")
+	return ""
+}
+
+// runChild runs the test in a child process, as dropped privileges can't be regained
+func runChild(t *testing.T, name string) {
+	if os.Getuid() != 0 {
+		t.Skip("dropping privileges needs root")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^"+name+"$", "-test.v")
+	cmd.Env = append(os.Environ(), "PRIVSEP_TEST_CHILD=1")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	if strings.Contains(string(out), "--- SKIP: "+name) {
+		t.Skip(string(out))
+	}
+	require.Contains(t, string(out), "--- PASS: "+name)
+}
+
+func TestDrop(t *testing.T) {
+	runChild(t, "TestDropChild")
+}
+
+func TestDropAll(t *testing.T) {
+	runChild(t, "TestDropAllChild")
+}
+
+func TestDropChild(t *testing.T) {
+	if os.Getenv("PRIVSEP_TEST_CHILD") == "" {
+		t.Skip("run by TestDrop")
+	}
+	// privileged socket opened before the drop
+	var early net.PacketConn
+	c := &Config{User: "nobody", Capabilities: []Capability{CapNetBindService}}
+	err := c.Setup(func() error {
+		var err error
+		early, err = net.ListenPacket("udp", "127.0.0.1:123")
+		return err
+	})
+	if err != nil && strings.Contains(err.Error(), errCgo.Error()) {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	defer early.Close()
+
+	require.NotEqual(t, 0, os.Getuid())
+	require.Equal(t, "0000000000000400", capEff(t))
+	// kept capability still works
+	conn, err := net.ListenPacket("udp", "127.0.0.1:124")
+	require.NoError(t, err)
+	conn.Close()
+	// dropped ones don't
+	_, err = ioutil.ReadFile("/proc/1/environ")
+	require.Error(t, err)
+}
+
+func TestDropAllChild(t *testing.T) {
+	if os.Getenv("PRIVSEP_TEST_CHILD") == "" {
+		t.Skip("run by TestDropAll")
+	}
+	c := &Config{User: "nobody"}
+	require.NoError(t, c.Drop())
+	require.NotEqual(t, 0, os.Getuid())
+	require.Equal(t, "0000000000000000", capEff(t))
+	_, err := net.ListenPacket("udp", "127.0.0.1:123")
+	require.Error(t, err)
+}
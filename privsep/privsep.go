@@ -0,0 +1,183 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package privsep implements privilege separation for time daemons: privileged resources such as
+port 123 sockets or PHC devices are opened first, then the process switches to an unprivileged user
+keeping only the capabilities it still needs, such as CAP_SYS_TIME to steer the clock.
+*/
+package privsep
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errNoUser = errors.New("user to drop privileges to is not set")
+
+// Capability is a linux capability number
+type Capability int
+
+// Capabilities time daemons may need after the drop
+const (
+	CapNetBindService Capability = 10
+	CapNetAdmin       Capability = 12
+	CapNetRaw         Capability = 13
+	CapIPCLock        Capability = 14
+	CapSysNice        Capability = 23
+	CapSysResource    Capability = 24
+	CapSysTime        Capability = 25
+)
+
+var capabilityToString = map[Capability]string{
+	CapNetBindService: "CAP_NET_BIND_SERVICE",
+	CapNetAdmin:       "CAP_NET_ADMIN",
+	CapNetRaw:         "CAP_NET_RAW",
+	CapIPCLock:        "CAP_IPC_LOCK",
+	CapSysNice:        "CAP_SYS_NICE",
+	CapSysResource:    "CAP_SYS_RESOURCE",
+	CapSysTime:        "CAP_SYS_TIME",
+}
+
+func (c Capability) String() string {
+	s, found := capabilityToString[c]
+	if !found {
+		return fmt.Sprintf("CAP_%d", int(c))
+	}
+	return s
+}
+
+// ParseCapabilities parses comma separated capability names, like net_admin,CAP_SYS_TIME
+func ParseCapabilities(s string) ([]Capability, error) {
+	res := []Capability{}
+	if s == "" {
+		return res, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !strings.HasPrefix(name, "CAP_") {
+			name = "CAP_" + name
+		}
+		found := false
+		for c, cname := range capabilityToString {
+			if cname == name {
+				res = append(res, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unsupported capability %q", name)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res, nil
+}
+
+// Config describes the unprivileged identity of the daemon
+type Config struct {
+	// User name or uid to run as. Privileges are not dropped if empty
+	User string
+	// Group name or gid to run as. Primary group of the User if empty
+	Group string
+	// Capabilities to keep, everything else is dropped including the bounding set
+	Capabilities []Capability
+}
+
+// Enabled returns whether privileges are to be dropped
+func (c *Config) Enabled() bool {
+	return c != nil && c.User != ""
+}
+
+// Keep adds capabilities to keep after the drop, unless they are kept already
+func (c *Config) Keep(caps ...Capability) {
+	for _, cp := range caps {
+		found := false
+		for _, kept := range c.Capabilities {
+			if kept == cp {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.Capabilities = append(c.Capabilities, cp)
+		}
+	}
+	sort.Slice(c.Capabilities, func(i, j int) bool { return c.Capabilities[i] < c.Capabilities[j] })
+}
+
+// lookup returns uid and gid of the configured user and group
+func (c *Config) lookup() (int, int, error) {
+	if c.User == "" {
+		return 0, 0, errNoUser
+	}
+	u, err := user.Lookup(c.User)
+	if err != nil {
+		if u, err = user.LookupId(c.User); err != nil {
+			return 0, 0, fmt.Errorf("looking up user %q: %w", c.User, err)
+		}
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("user %q has non numeric uid %q", c.User, u.Uid)
+	}
+	gidStr := u.Gid
+	if c.Group != "" {
+		g, err := user.LookupGroup(c.Group)
+		if err != nil {
+			if g, err = user.LookupGroupId(c.Group); err != nil {
+				return 0, 0, fmt.Errorf("looking up group %q: %w", c.Group, err)
+			}
+		}
+		gidStr = g.Gid
+	}
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("group of %q has non numeric gid %q", c.User, gidStr)
+	}
+	return uid, gid, nil
+}
+
+// Drop switches the process to the configured user and group keeping only the configured capabilities.
+// It can't be undone. Does nothing if not Enabled
+func (c *Config) Drop() error {
+	if !c.Enabled() {
+		return nil
+	}
+	uid, gid, err := c.lookup()
+	if err != nil {
+		return err
+	}
+	if err := drop(uid, gid, c.Capabilities); err != nil {
+		return fmt.Errorf("dropping privileges to %s: %w", c.User, err)
+	}
+	return nil
+}
+
+// Setup opens privileged resources with open functions, then drops privileges.
+// Nothing is dropped if any of them fails
+func (c *Config) Setup(open ...func() error) error {
+	for _, o := range open {
+		if err := o(); err != nil {
+			return err
+		}
+	}
+	return c.Drop()
+}
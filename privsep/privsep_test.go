@@ -0,0 +1,98 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package privsep
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	caps, err := ParseCapabilities("sys_time, CAP_NET_BIND_SERVICE,net_admin")
+	require.NoError(t, err)
+	require.Equal(t, []Capability{CapNetBindService, CapNetAdmin, CapSysTime}, caps)
+	require.Equal(t, "CAP_SYS_TIME", caps[2].String())
+	require.Equal(t, "CAP_0", Capability(0).String())
+
+	caps, err = ParseCapabilities("")
+	require.NoError(t, err)
+	require.Empty(t, caps)
+
+	_, err = ParseCapabilities("sys_admin")
+	require.EqualError(t, err, `unsupported capability "CAP_SYS_ADMIN"`)
+}
+
+func TestKeep(t *testing.T) {
+	c := &Config{Capabilities: []Capability{CapSysTime}}
+	c.Keep(CapNetAdmin, CapSysTime)
+	require.Equal(t, []Capability{CapNetAdmin, CapSysTime}, c.Capabilities)
+
+	c = &Config{}
+	c.Keep(CapNetAdmin)
+	require.Equal(t, []Capability{CapNetAdmin}, c.Capabilities)
+}
+
+func TestLookup(t *testing.T) {
+	c := &Config{User: "root"}
+	uid, gid, err := c.lookup()
+	require.NoError(t, err)
+	require.Equal(t, 0, uid)
+	require.Equal(t, 0, gid)
+
+	c = &Config{User: "0", Group: "0"}
+	uid, gid, err = c.lookup()
+	require.NoError(t, err)
+	require.Equal(t, 0, uid)
+	require.Equal(t, 0, gid)
+
+	c = &Config{User: "oleg-does-not-exist"}
+	_, _, err = c.lookup()
+	require.Error(t, err)
+
+	c = &Config{User: "root", Group: "oleg-does-not-exist"}
+	_, _, err = c.lookup()
+	require.Error(t, err)
+
+	_, _, err = (&Config{}).lookup()
+	require.ErrorIs(t, err, errNoUser)
+}
+
+func TestDropDisabled(t *testing.T) {
+	var c *Config
+	require.False(t, c.Enabled())
+	require.NoError(t, c.Drop())
+	require.NoError(t, (&Config{Capabilities: []Capability{CapSysTime}}).Drop())
+}
+
+func TestSetupFailure(t *testing.T) {
+	opened := []string{}
+	c := &Config{User: "oleg-does-not-exist"}
+	err := c.Setup(
+		func() error {
+			opened = append(opened, "socket")
+			return errors.New("address in use")
+		},
+		func() error {
+			opened = append(opened, "phc")
+			return nil
+		},
+	)
+	require.EqualError(t, err, "address in use")
+	require.Equal(t, []string{"socket"}, opened)
+}
@@ -0,0 +1,36 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"time"
+)
+
+// Clock is a source of PTP (TAI) time distributed by the master
+type Clock interface {
+	Now() (time.Time, error)
+}
+
+// SystemClock is the system clock shifted by UTC offset to PTP timescale
+type SystemClock struct {
+	UTCOffset time.Duration
+}
+
+// Now returns current TAI time of the system clock
+func (c *SystemClock) Now() (time.Time, error) {
+	return time.Now().Add(c.UTCOffset), nil
+}
@@ -0,0 +1,342 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	ptp "github.com/facebook/time/ptp/protocol"
+)
+
+// Default PTP multicast groups (primary domain)
+var (
+	MulticastIPv4 = net.ParseIP("224.0.1.129")
+	MulticastIPv6 = net.ParseIP("ff0e::181")
+)
+
+// Config is the configuration of the master
+type Config struct {
+	// IP to bind on
+	IP net.IP
+	// Interface to join multicast group on
+	Interface string
+	// Destinations of Sync and Announce messages. Multicast group matching IP family if empty
+	Destinations []net.IP
+	DomainNumber uint8
+	// ClockIdentity of the master, usually derived from MAC of the Interface
+	ClockIdentity    ptp.ClockIdentity
+	SyncInterval     time.Duration
+	AnnounceInterval time.Duration
+	// UTCOffset is advertised in Announce messages
+	UTCOffset    time.Duration
+	ClockQuality ptp.ClockQuality
+	Priority1    uint8
+	Priority2    uint8
+	TimeSource   ptp.TimeSource
+}
+
+// DefaultConfig returns config of a lab master syncing every second
+func DefaultConfig() *Config {
+	return &Config{
+		IP:               net.IPv6zero,
+		SyncInterval:     time.Second,
+		AnnounceInterval: time.Second,
+		UTCOffset:        37 * time.Second,
+		ClockQuality: ptp.ClockQuality{
+			ClockClass:              6,
+			ClockAccuracy:           0x21, // Time Accurate within 100ns
+			OffsetScaledLogVariance: 23008,
+		},
+		Priority1:  128,
+		Priority2:  128,
+		TimeSource: ptp.TimeSourceGNSS,
+	}
+}
+
+// destinations returns configured destinations or the multicast group
+func (c *Config) destinations() []net.IP {
+	if len(c.Destinations) > 0 {
+		return c.Destinations
+	}
+	if c.IP.To4() != nil {
+		return []net.IP{MulticastIPv4}
+	}
+	return []net.IP{MulticastIPv6}
+}
+
+// multicast returns true if messages go to the multicast group
+func (c *Config) multicast() bool {
+	for _, ip := range c.destinations() {
+		if !ip.IsMulticast() {
+			return false
+		}
+	}
+	return true
+}
+
+// PacketConn is what we expect from event and general connections
+type PacketConn interface {
+	ReadFrom(b []byte) (int, net.Addr, error)
+	WriteTo(b []byte, addr net.Addr) (int, error)
+	Close() error
+}
+
+// Master is a minimal two-step PTP ordinary clock in master state.
+// It periodically sends Announce, Sync and Follow Up messages and
+// answers Delay Requests. All timestamps are taken from Clock in software,
+// so precision is limited to what the OS scheduler allows.
+type Master struct {
+	Config *Config
+	Clock  Clock
+
+	eventConn   PacketConn
+	generalConn PacketConn
+
+	mux         sync.Mutex
+	syncSeq     uint16
+	announceSeq uint16
+}
+
+// New returns a Master distributing time of the clock
+func New(c *Config, clock Clock) *Master {
+	return &Master{Config: c, Clock: clock}
+}
+
+func (m *Master) listenUDP(port int) (PacketConn, error) {
+	if m.Config.multicast() && m.Config.Interface != "" {
+		iface, err := net.InterfaceByName(m.Config.Interface)
+		if err != nil {
+			return nil, err
+		}
+		return net.ListenMulticastUDP("udp", iface, &net.UDPAddr{IP: m.Config.destinations()[0], Port: port})
+	}
+	return net.ListenUDP("udp", &net.UDPAddr{IP: m.Config.IP, Port: port})
+}
+
+// Listen opens event and general connections
+func (m *Master) Listen() error {
+	var err error
+	if m.eventConn, err = m.listenUDP(ptp.PortEvent); err != nil {
+		return fmt.Errorf("listening on event port: %w", err)
+	}
+	if m.generalConn, err = m.listenUDP(ptp.PortGeneral); err != nil {
+		m.eventConn.Close()
+		return fmt.Errorf("listening on general port: %w", err)
+	}
+	return nil
+}
+
+// Run sends messages and serves Delay Requests until context is cancelled
+func (m *Master) Run(ctx context.Context) error {
+	if m.eventConn == nil {
+		if err := m.Listen(); err != nil {
+			return err
+		}
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		<-ctx.Done()
+		m.eventConn.Close()
+		m.generalConn.Close()
+		return nil
+	})
+	eg.Go(func() error {
+		return m.every(ctx, m.Config.AnnounceInterval, m.sendAnnounce)
+	})
+	eg.Go(func() error {
+		return m.every(ctx, m.Config.SyncInterval, m.sendSync)
+	})
+	eg.Go(func() error {
+		err := m.serveEvent()
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	})
+	return eg.Wait()
+}
+
+// every runs f on each tick, logging failures
+func (m *Master) every(ctx context.Context, interval time.Duration, f func() error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := f(); err != nil {
+			log.Errorf("master: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveEvent reads event messages and answers Delay Requests
+func (m *Master) serveEvent() error {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := m.eventConn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("reading event message: %w", err)
+		}
+		rx, err := m.Clock.Now()
+		if err != nil {
+			log.Errorf("master: reading clock: %v", err)
+			continue
+		}
+		if err := m.handleEvent(buf[:n], addr, rx); err != nil {
+			log.Debugf("master: %v", err)
+		}
+	}
+}
+
+func (m *Master) header(msgType ptp.MessageType, length int, flags uint16, control uint8, interval time.Duration) ptp.Header {
+	li := ptp.LogInterval(0x7f)
+	if interval > 0 {
+		li, _ = ptp.NewLogInterval(interval)
+	}
+	if !m.Config.multicast() {
+		flags |= ptp.FlagUnicast
+	}
+	return ptp.Header{
+		SdoIDAndMsgType: ptp.NewSdoIDAndMsgType(msgType, 0),
+		Version:         ptp.Version,
+		MessageLength:   uint16(length),
+		DomainNumber:    m.Config.DomainNumber,
+		FlagField:       flags,
+		SourcePortIdentity: ptp.PortIdentity{
+			PortNumber:    1,
+			ClockIdentity: m.Config.ClockIdentity,
+		},
+		ControlField:       control,
+		LogMessageInterval: li,
+	}
+}
+
+func (m *Master) send(conn PacketConn, p ptp.Packet, ip net.IP, port int) error {
+	b, err := ptp.Bytes(p)
+	if err != nil {
+		return err
+	}
+	_, err = conn.WriteTo(b, &net.UDPAddr{IP: ip, Port: port})
+	return err
+}
+
+// sendAnnounce sends Announce to all destinations
+func (m *Master) sendAnnounce() error {
+	m.mux.Lock()
+	seq := m.announceSeq
+	m.announceSeq++
+	m.mux.Unlock()
+
+	a := &ptp.Announce{
+		Header: m.header(ptp.MessageAnnounce, binary.Size(ptp.Announce{}), ptp.FlagPTPTimescale|ptp.FlagCurrentUtcOffsetValid, 5, m.Config.AnnounceInterval),
+		AnnounceBody: ptp.AnnounceBody{
+			CurrentUTCOffset:        int16(m.Config.UTCOffset.Seconds()),
+			GrandmasterPriority1:    m.Config.Priority1,
+			GrandmasterClockQuality: m.Config.ClockQuality,
+			GrandmasterPriority2:    m.Config.Priority2,
+			GrandmasterIdentity:     m.Config.ClockIdentity,
+			StepsRemoved:            0,
+			TimeSource:              m.Config.TimeSource,
+		},
+	}
+	a.SequenceID = seq
+	for _, ip := range m.Config.destinations() {
+		if err := m.send(m.generalConn, a, ip, ptp.PortGeneral); err != nil {
+			return fmt.Errorf("sending announce to %s: %w", ip, err)
+		}
+	}
+	return nil
+}
+
+// sendSync sends Sync followed by Follow Up with its origin time to all destinations
+func (m *Master) sendSync() error {
+	m.mux.Lock()
+	seq := m.syncSeq
+	m.syncSeq++
+	m.mux.Unlock()
+
+	s := &ptp.SyncDelayReq{
+		Header: m.header(ptp.MessageSync, binary.Size(ptp.SyncDelayReq{}), ptp.FlagTwoStep, 0, m.Config.SyncInterval),
+	}
+	s.SequenceID = seq
+	f := &ptp.FollowUp{
+		Header: m.header(ptp.MessageFollowUp, binary.Size(ptp.FollowUp{}), 0, 2, m.Config.SyncInterval),
+	}
+	f.SequenceID = seq
+	for _, ip := range m.Config.destinations() {
+		tx, err := m.Clock.Now()
+		if err != nil {
+			return fmt.Errorf("reading clock: %w", err)
+		}
+		if err := m.send(m.eventConn, s, ip, ptp.PortEvent); err != nil {
+			return fmt.Errorf("sending sync to %s: %w", ip, err)
+		}
+		f.PreciseOriginTimestamp = ptp.NewTimestamp(tx)
+		if err := m.send(m.generalConn, f, ip, ptp.PortGeneral); err != nil {
+			return fmt.Errorf("sending follow up to %s: %w", ip, err)
+		}
+	}
+	return nil
+}
+
+// handleEvent answers Delay Request received at rx with Delay Response
+func (m *Master) handleEvent(b []byte, addr net.Addr, rx time.Time) error {
+	msgType, err := ptp.ProbeMsgType(b)
+	if err != nil {
+		return err
+	}
+	if msgType != ptp.MessageDelayReq {
+		return fmt.Errorf("unexpected %s event message from %s", msgType, addr)
+	}
+	req := &ptp.SyncDelayReq{}
+	if err := ptp.FromBytes(b, req); err != nil {
+		return fmt.Errorf("parsing delay request from %s: %w", addr, err)
+	}
+	if req.DomainNumber != m.Config.DomainNumber {
+		return fmt.Errorf("delay request from %s is for domain %d", addr, req.DomainNumber)
+	}
+	uaddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return fmt.Errorf("unexpected address type %T", addr)
+	}
+	resp := &ptp.DelayResp{
+		Header: m.header(ptp.MessageDelayResp, binary.Size(ptp.DelayResp{}), req.FlagField&ptp.FlagUnicast, 3, m.Config.SyncInterval),
+		DelayRespBody: ptp.DelayRespBody{
+			ReceiveTimestamp:       ptp.NewTimestamp(rx),
+			RequestingPortIdentity: req.SourcePortIdentity,
+		},
+	}
+	resp.SequenceID = req.SequenceID
+	resp.CorrectionField = req.CorrectionField
+	// multicast requests are answered to the group, unicast ones to the requester
+	dst := uaddr.IP
+	if req.FlagField&ptp.FlagUnicast == 0 && m.Config.multicast() {
+		dst = m.Config.destinations()[0]
+	}
+	return m.send(m.generalConn, resp, dst, ptp.PortGeneral)
+}
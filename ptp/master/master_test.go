@@ -0,0 +1,181 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ptp "github.com/facebook/time/ptp/protocol"
+)
+
+type written struct {
+	b    []byte
+	addr *net.UDPAddr
+}
+
+type fakeConn struct {
+	written []written
+}
+
+func (c *fakeConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	return 0, nil, net.ErrClosed
+}
+
+func (c *fakeConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.written = append(c.written, written{b: append([]byte{}, b...), addr: addr.(*net.UDPAddr)})
+	return len(b), nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() (time.Time, error) {
+	return c.t, nil
+}
+
+var identity = ptp.ClockIdentity(0xc42a1fffe6d7ca6)
+
+func newTestMaster(dst ...net.IP) (*Master, *fakeConn, *fakeConn) {
+	c := DefaultConfig()
+	c.IP = net.ParseIP("192.168.0.1")
+	c.Destinations = dst
+	c.ClockIdentity = identity
+	m := New(c, &fakeClock{t: time.Unix(1640995237, 42)})
+	event, general := &fakeConn{}, &fakeConn{}
+	m.eventConn = event
+	m.generalConn = general
+	return m, event, general
+}
+
+func TestDestinations(t *testing.T) {
+	c := DefaultConfig()
+	require.Equal(t, []net.IP{MulticastIPv6}, c.destinations())
+	require.True(t, c.multicast())
+
+	c.IP = net.ParseIP("192.168.0.1")
+	require.Equal(t, []net.IP{MulticastIPv4}, c.destinations())
+
+	c.Destinations = []net.IP{net.ParseIP("192.168.0.2")}
+	require.Equal(t, c.Destinations, c.destinations())
+	require.False(t, c.multicast())
+}
+
+func TestSendSync(t *testing.T) {
+	m, event, general := newTestMaster()
+	require.NoError(t, m.sendSync())
+	require.NoError(t, m.sendSync())
+
+	require.Len(t, event.written, 2)
+	require.Len(t, general.written, 2)
+	require.Equal(t, &net.UDPAddr{IP: MulticastIPv4, Port: ptp.PortEvent}, event.written[0].addr)
+	require.Equal(t, &net.UDPAddr{IP: MulticastIPv4, Port: ptp.PortGeneral}, general.written[0].addr)
+
+	p, err := ptp.DecodePacket(event.written[1].b)
+	require.NoError(t, err)
+	sync := p.(*ptp.SyncDelayReq)
+	require.Equal(t, ptp.MessageSync, sync.MessageType())
+	require.Equal(t, uint16(1), sync.SequenceID)
+	require.Equal(t, ptp.FlagTwoStep, sync.FlagField)
+	require.Equal(t, identity, sync.SourcePortIdentity.ClockIdentity)
+
+	p, err = ptp.DecodePacket(general.written[1].b)
+	require.NoError(t, err)
+	followup := p.(*ptp.FollowUp)
+	require.Equal(t, uint16(1), followup.SequenceID)
+	require.Equal(t, time.Unix(1640995237, 42), followup.PreciseOriginTimestamp.Time())
+}
+
+func TestSendAnnounceUnicast(t *testing.T) {
+	dst := []net.IP{net.ParseIP("192.168.0.2"), net.ParseIP("192.168.0.3")}
+	m, _, general := newTestMaster(dst...)
+	require.NoError(t, m.sendAnnounce())
+
+	require.Len(t, general.written, 2)
+	require.Equal(t, dst[1], general.written[1].addr.IP)
+	p, err := ptp.DecodePacket(general.written[0].b)
+	require.NoError(t, err)
+	announce := p.(*ptp.Announce)
+	require.Equal(t, ptp.FlagUnicast|ptp.FlagPTPTimescale|ptp.FlagCurrentUtcOffsetValid, announce.FlagField)
+	require.Equal(t, int16(37), announce.CurrentUTCOffset)
+	require.Equal(t, uint8(6), announce.GrandmasterClockQuality.ClockClass)
+	require.Equal(t, identity, announce.GrandmasterIdentity)
+	require.Equal(t, ptp.TimeSourceGNSS, announce.TimeSource)
+}
+
+func delayReq(t *testing.T, flags uint16, domain uint8) []byte {
+	req := &ptp.SyncDelayReq{
+		Header: ptp.Header{
+			SdoIDAndMsgType: ptp.NewSdoIDAndMsgType(ptp.MessageDelayReq, 0),
+			Version:         ptp.Version,
+			DomainNumber:    domain,
+			FlagField:       flags,
+			SequenceID:      7,
+			CorrectionField: ptp.NewCorrection(100),
+			SourcePortIdentity: ptp.PortIdentity{
+				PortNumber:    1,
+				ClockIdentity: 42,
+			},
+		},
+	}
+	b, err := ptp.Bytes(req)
+	require.NoError(t, err)
+	return b
+}
+
+func TestHandleDelayReq(t *testing.T) {
+	m, _, general := newTestMaster()
+	rx := time.Unix(1640995238, 100)
+	client := &net.UDPAddr{IP: net.ParseIP("192.168.0.2"), Port: ptp.PortEvent}
+
+	// multicast request is answered to the group
+	require.NoError(t, m.handleEvent(delayReq(t, 0, 0), client, rx))
+	// unicast request is answered to the client
+	require.NoError(t, m.handleEvent(delayReq(t, ptp.FlagUnicast, 0), client, rx))
+	require.Len(t, general.written, 2)
+	require.Equal(t, &net.UDPAddr{IP: MulticastIPv4, Port: ptp.PortGeneral}, general.written[0].addr)
+	require.Equal(t, &net.UDPAddr{IP: client.IP, Port: ptp.PortGeneral}, general.written[1].addr)
+
+	p, err := ptp.DecodePacket(general.written[1].b)
+	require.NoError(t, err)
+	resp := p.(*ptp.DelayResp)
+	require.Equal(t, uint16(7), resp.SequenceID)
+	require.Equal(t, ptp.FlagUnicast, resp.FlagField)
+	require.Equal(t, ptp.NewCorrection(100), resp.CorrectionField)
+	require.Equal(t, rx, resp.ReceiveTimestamp.Time())
+	require.Equal(t, ptp.PortIdentity{PortNumber: 1, ClockIdentity: 42}, resp.RequestingPortIdentity)
+}
+
+func TestHandleEventErrors(t *testing.T) {
+	m, event, general := newTestMaster()
+	client := &net.UDPAddr{IP: net.ParseIP("192.168.0.2"), Port: ptp.PortEvent}
+
+	require.Error(t, m.handleEvent(nil, client, time.Now()))
+	require.Error(t, m.handleEvent(delayReq(t, 0, 1), client, time.Now()))
+
+	require.NoError(t, m.sendSync())
+	require.Error(t, m.handleEvent(event.written[0].b, client, time.Now()))
+	require.Len(t, general.written, 1)
+}
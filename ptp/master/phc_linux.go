@@ -0,0 +1,34 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"time"
+
+	"github.com/facebook/time/phc"
+)
+
+// PHCClock is the PTP hardware clock of the network card, which is expected to run in TAI
+type PHCClock struct {
+	Iface  string
+	Method phc.TimeMethod
+}
+
+// Now returns current time of the PHC
+func (c *PHCClock) Now() (time.Time, error) {
+	return phc.Time(c.Iface, c.Method)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rtc
+
+import (
+	"os"
+	"time"
+)
+
+// DefaultDevice is the system RTC
+const DefaultDevice = "/dev/rtc"
+
+// RTC is a hardware real time clock device. RTC is expected to keep UTC
+type RTC struct {
+	f *os.File
+}
+
+// Alarm is a wake alarm of RTC
+type Alarm struct {
+	Enabled bool
+	Pending bool
+	Time    time.Time
+}
+
+// Open opens RTC device
+func Open(device string) (*RTC, error) {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &RTC{f: f}, nil
+}
+
+// Close closes RTC device
+func (r *RTC) Close() error {
+	return r.f.Close()
+}
+
+// Offset returns RTC time minus system time.
+// Result is only accurate to a second as RTC doesn't expose fractions
+func (r *RTC) Offset() (time.Duration, error) {
+	t, err := r.Time()
+	if err != nil {
+		return 0, err
+	}
+	return t.Sub(time.Now().Truncate(time.Second)), nil
+}
+
+// Sync sets RTC from the system clock if their offset exceeds maxOffset.
+// It returns the offset before correction and whether RTC was set
+func (r *RTC) Sync(maxOffset time.Duration) (time.Duration, bool, error) {
+	offset, err := r.Offset()
+	if err != nil {
+		return 0, false, err
+	}
+	if abs(offset) <= maxOffset {
+		return offset, false, nil
+	}
+	// RTC starts a new second on write, so wait for the system clock second boundary
+	now := time.Now()
+	time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+	return offset, true, r.SetTime(time.Now())
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
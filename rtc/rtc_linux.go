@@ -0,0 +1,87 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rtc
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Time reads RTC time
+func (r *RTC) Time() (time.Time, error) {
+	t, err := unix.IoctlGetRTCTime(int(r.f.Fd()))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("RTC_RD_TIME: %w", err)
+	}
+	return rtcToTime(t), nil
+}
+
+// SetTime sets RTC time. RTC has a second resolution, fractions are truncated
+func (r *RTC) SetTime(t time.Time) error {
+	if err := unix.IoctlSetRTCTime(int(r.f.Fd()), timeToRTC(t)); err != nil {
+		return fmt.Errorf("RTC_SET_TIME: %w", err)
+	}
+	return nil
+}
+
+// WakeAlarm reads RTC wake alarm
+func (r *RTC) WakeAlarm() (*Alarm, error) {
+	a, err := unix.IoctlGetRTCWkAlrm(int(r.f.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("RTC_WKALM_RD: %w", err)
+	}
+	return &Alarm{
+		Enabled: a.Enabled != 0,
+		Pending: a.Pending != 0,
+		Time:    rtcToTime(&a.Time),
+	}, nil
+}
+
+// SetWakeAlarm sets RTC wake alarm to t, disabled alarm is cleared
+func (r *RTC) SetWakeAlarm(t time.Time, enabled bool) error {
+	a := &unix.RTCWkAlrm{Time: *timeToRTC(t)}
+	if enabled {
+		a.Enabled = 1
+	}
+	if err := unix.IoctlSetRTCWkAlrm(int(r.f.Fd()), a); err != nil {
+		return fmt.Errorf("RTC_WKALM_SET: %w", err)
+	}
+	return nil
+}
+
+// rtcToTime converts struct rtc_time (tm-like, UTC) to time
+func rtcToTime(t *unix.RTCTime) time.Time {
+	return time.Date(int(t.Year)+1900, time.Month(t.Mon+1), int(t.Mday), int(t.Hour), int(t.Min), int(t.Sec), 0, time.UTC)
+}
+
+// timeToRTC converts time to struct rtc_time in UTC
+func timeToRTC(t time.Time) *unix.RTCTime {
+	t = t.UTC()
+	return &unix.RTCTime{
+		Sec:   int32(t.Second()),
+		Min:   int32(t.Minute()),
+		Hour:  int32(t.Hour()),
+		Mday:  int32(t.Day()),
+		Mon:   int32(t.Month() - 1),
+		Year:  int32(t.Year() - 1900),
+		Wday:  int32(t.Weekday()),
+		Yday:  int32(t.YearDay() - 1),
+		Isdst: 0,
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestTimeToRTC(t *testing.T) {
+	ts := time.Date(2021, time.December, 31, 23, 59, 58, 999999999, time.UTC)
+	expected := &unix.RTCTime{
+		Sec:  58,
+		Min:  59,
+		Hour: 23,
+		Mday: 31,
+		Mon:  11,
+		Year: 121,
+		Wday: 5,
+		Yday: 364,
+	}
+	require.Equal(t, expected, timeToRTC(ts))
+	// non-UTC time is converted
+	require.Equal(t, expected, timeToRTC(ts.In(time.FixedZone("PST", -8*3600))))
+}
+
+func TestRTCToTime(t *testing.T) {
+	rt := &unix.RTCTime{Sec: 1, Min: 2, Hour: 3, Mday: 1, Mon: 0, Year: 122}
+	require.Equal(t, time.Date(2022, time.January, 1, 3, 2, 1, 0, time.UTC), rtcToTime(rt))
+
+	ts := time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, ts, rtcToTime(timeToRTC(ts)))
+}
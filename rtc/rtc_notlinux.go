@@ -0,0 +1,47 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rtc
+
+import (
+	"errors"
+	"time"
+)
+
+var errUnsupported = errors.New("RTC ioctls are only supported on linux")
+
+// Time reads RTC time
+func (r *RTC) Time() (time.Time, error) {
+	return time.Time{}, errUnsupported
+}
+
+// SetTime sets RTC time
+func (r *RTC) SetTime(t time.Time) error {
+	return errUnsupported
+}
+
+// WakeAlarm reads RTC wake alarm
+func (r *RTC) WakeAlarm() (*Alarm, error) {
+	return nil, errUnsupported
+}
+
+// SetWakeAlarm sets RTC wake alarm
+func (r *RTC) SetWakeAlarm(t time.Time, enabled bool) error {
+	return errUnsupported
+}
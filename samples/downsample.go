@@ -0,0 +1,119 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package samples
+
+import (
+	"math"
+)
+
+// MinMax splits samples into buckets of equal time span and keeps min and max offsets of every bucket
+// in time order. Result has at most 2*buckets samples. Samples are expected to be in time order
+func MinMax(samples []Sample, buckets int) []Sample {
+	if buckets < 1 || len(samples) <= 2*buckets {
+		return append([]Sample{}, samples...)
+	}
+	first := samples[0].Time
+	span := samples[len(samples)-1].Time.Sub(first)
+	res := make([]Sample, 0, 2*buckets)
+	bucket := -1
+	var lo, hi Sample
+	flush := func() {
+		if bucket < 0 {
+			return
+		}
+		if lo.Time.After(hi.Time) {
+			lo, hi = hi, lo
+		}
+		res = append(res, lo)
+		if lo != hi {
+			res = append(res, hi)
+		}
+	}
+	for _, s := range samples {
+		b := 0
+		if span > 0 {
+			b = int(float64(s.Time.Sub(first)) / float64(span) * float64(buckets))
+		}
+		if b >= buckets {
+			b = buckets - 1
+		}
+		if b != bucket {
+			flush()
+			bucket = b
+			lo, hi = s, s
+			continue
+		}
+		if s.Offset < lo.Offset {
+			lo = s
+		}
+		if s.Offset > hi.Offset {
+			hi = s
+		}
+	}
+	flush()
+	return res
+}
+
+// LTTB downsamples to threshold samples using Largest-Triangle-Three-Buckets algorithm
+// which preserves visual shape of the series. First and last samples are always kept.
+// Samples are expected to be in time order
+func LTTB(samples []Sample, threshold int) []Sample {
+	if threshold < 3 || len(samples) <= threshold {
+		return append([]Sample{}, samples...)
+	}
+	x := func(s Sample) float64 {
+		return float64(s.Time.Sub(samples[0].Time))
+	}
+
+	res := make([]Sample, 0, threshold)
+	res = append(res, samples[0])
+	// first and last samples are not part of any bucket
+	every := float64(len(samples)-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// average of the next bucket is the third point of the triangle
+		nextStart := int(math.Floor(float64(i+1)*every)) + 1
+		nextEnd := int(math.Floor(float64(i+2)*every)) + 1
+		if nextEnd > len(samples) {
+			nextEnd = len(samples)
+		}
+		var avgX, avgY float64
+		for _, s := range samples[nextStart:nextEnd] {
+			avgX += x(s)
+			avgY += s.Offset
+		}
+		n := float64(nextEnd - nextStart)
+		avgX /= n
+		avgY /= n
+
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := nextStart
+		ax, ay := x(samples[a]), samples[a].Offset
+		maxArea := -1.0
+		next := start
+		for j := start; j < end; j++ {
+			area := math.Abs((ax-avgX)*(samples[j].Offset-ay) - (ax-x(samples[j]))*(avgY-ay))
+			if area > maxArea {
+				maxArea = area
+				next = j
+			}
+		}
+		res = append(res, samples[next])
+		a = next
+	}
+	return append(res, samples[len(samples)-1])
+}
@@ -0,0 +1,66 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package samples
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinMax(t *testing.T) {
+	s := []Sample{}
+	for i := 0; i < 100; i++ {
+		s = append(s, Sample{at(i), math.Sin(float64(i))})
+	}
+	// short series is returned as is
+	require.Equal(t, s, MinMax(s, 50))
+
+	res := MinMax(s, 10)
+	require.LessOrEqual(t, len(res), 20)
+	for i := 1; i < len(res); i++ {
+		require.True(t, res[i-1].Time.Before(res[i].Time))
+	}
+	// global extremes are preserved
+	min, max := Percentiles(s, 0, 100), Percentiles(res, 0, 100)
+	require.Equal(t, min, max)
+
+	flat := []Sample{{at(0), 1}, {at(1), 1}, {at(2), 1}, {at(3), 1}, {at(4), 1}}
+	// flat bucket is represented by a single sample
+	require.Equal(t, []Sample{{at(0), 1}, {at(2), 1}}, MinMax(flat, 2))
+}
+
+func TestLTTB(t *testing.T) {
+	s := []Sample{}
+	for i := 0; i < 100; i++ {
+		s = append(s, Sample{at(i), 0})
+	}
+	// spike is preserved
+	s[42].Offset = 100
+	require.Equal(t, s, LTTB(s, 100))
+	require.Equal(t, s, LTTB(s, 2))
+
+	res := LTTB(s, 10)
+	require.Len(t, res, 10)
+	require.Equal(t, s[0], res[0])
+	require.Equal(t, s[99], res[9])
+	require.Contains(t, res, s[42])
+	for i := 1; i < len(res); i++ {
+		require.True(t, res[i-1].Time.Before(res[i].Time))
+	}
+}
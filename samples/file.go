@@ -0,0 +1,110 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package samples
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sampleSize is the size of a sample on disk: unix nanoseconds and offset
+const sampleSize = 16
+
+var errTruncated = errors.New("truncated sample")
+
+// WriteTo writes samples to w in binary format, oldest first
+func (r *Ring) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	b := make([]byte, sampleSize)
+	for _, s := range r.Samples() {
+		binary.BigEndian.PutUint64(b[:8], uint64(s.Time.UnixNano()))
+		binary.BigEndian.PutUint64(b[8:], math.Float64bits(s.Offset))
+		written, err := bw.Write(b)
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ReadFrom appends samples read from rd in binary format produced by WriteTo
+func (r *Ring) ReadFrom(rd io.Reader) (int64, error) {
+	br := bufio.NewReader(rd)
+	var n int64
+	b := make([]byte, sampleSize)
+	for {
+		read, err := io.ReadFull(br, b)
+		n += int64(read)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return n, errTruncated
+		}
+		if err != nil {
+			return n, err
+		}
+		r.Add(
+			time.Unix(0, int64(binary.BigEndian.Uint64(b[:8]))),
+			math.Float64frombits(binary.BigEndian.Uint64(b[8:])),
+		)
+	}
+}
+
+// Save atomically writes samples to the file
+func (r *Ring) Save(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Load returns a Ring of the given capacity filled with samples from the file.
+// Missing file results in an empty Ring
+func Load(path string, capacity int) (*Ring, error) {
+	r := NewRing(capacity)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := r.ReadFrom(f); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
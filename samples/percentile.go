@@ -0,0 +1,57 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package samples
+
+import (
+	"math"
+	"sort"
+)
+
+// Percentile returns nearest rank percentile of offsets. p is in range [0, 100]
+func Percentile(samples []Sample, p float64) float64 {
+	return Percentiles(samples, p)[0]
+}
+
+// Percentiles returns nearest rank percentiles of offsets sorting them only once
+func Percentiles(samples []Sample, ps ...float64) []float64 {
+	sorted := Offsets(samples)
+	sort.Float64s(sorted)
+	res := make([]float64, len(ps))
+	if len(sorted) == 0 {
+		return res
+	}
+	for i, p := range ps {
+		rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		if rank >= len(sorted) {
+			rank = len(sorted) - 1
+		}
+		res[i] = sorted[rank]
+	}
+	return res
+}
+
+// AbsPercentiles returns nearest rank percentiles of absolute offsets
+func AbsPercentiles(samples []Sample, ps ...float64) []float64 {
+	abs := make([]Sample, len(samples))
+	for i, s := range samples {
+		abs[i] = Sample{Time: s.Time, Offset: math.Abs(s.Offset)}
+	}
+	return Percentiles(abs, ps...)
+}
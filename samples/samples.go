@@ -0,0 +1,125 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package samples implements a bounded time-series buffer of (time, offset) pairs
+with downsampling and percentile queries.
+*/
+package samples
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sample is a single measurement
+type Sample struct {
+	Time   time.Time
+	Offset float64
+}
+
+// Ring is a fixed capacity buffer of samples. Oldest samples are overwritten once it's full.
+// It is safe for concurrent use
+type Ring struct {
+	sync.Mutex
+	buf   []Sample
+	start int
+	size  int
+}
+
+// NewRing returns a Ring which keeps up to capacity samples
+func NewRing(capacity int) *Ring {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Ring{buf: make([]Sample, capacity)}
+}
+
+// Cap returns the capacity of the ring
+func (r *Ring) Cap() int {
+	return len(r.buf)
+}
+
+// Len returns the number of samples stored
+func (r *Ring) Len() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.size
+}
+
+// Add appends the sample overwriting the oldest one if the ring is full
+func (r *Ring) Add(t time.Time, offset float64) {
+	r.Lock()
+	defer r.Unlock()
+	r.add(Sample{Time: t, Offset: offset})
+}
+
+func (r *Ring) add(s Sample) {
+	i := (r.start + r.size) % len(r.buf)
+	r.buf[i] = s
+	if r.size < len(r.buf) {
+		r.size++
+	} else {
+		r.start = (r.start + 1) % len(r.buf)
+	}
+}
+
+// Reset removes all samples
+func (r *Ring) Reset() {
+	r.Lock()
+	defer r.Unlock()
+	r.start = 0
+	r.size = 0
+}
+
+// Samples returns a copy of all samples, oldest first
+func (r *Ring) Samples() []Sample {
+	r.Lock()
+	defer r.Unlock()
+	res := make([]Sample, r.size)
+	for i := 0; i < r.size; i++ {
+		res[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return res
+}
+
+// Last returns the most recent sample
+func (r *Ring) Last() (Sample, bool) {
+	r.Lock()
+	defer r.Unlock()
+	if r.size == 0 {
+		return Sample{}, false
+	}
+	return r.buf[(r.start+r.size-1)%len(r.buf)], true
+}
+
+// Since returns a copy of samples taken at or after t, oldest first.
+// Samples are expected to be added in time order
+func (r *Ring) Since(t time.Time) []Sample {
+	all := r.Samples()
+	i := sort.Search(len(all), func(i int) bool { return !all[i].Time.Before(t) })
+	return all[i:]
+}
+
+// Offsets returns offsets of the samples
+func Offsets(samples []Sample) []float64 {
+	res := make([]float64, len(samples))
+	for i, s := range samples {
+		res[i] = s.Offset
+	}
+	return res
+}
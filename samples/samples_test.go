@@ -0,0 +1,101 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package samples
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Unix(1640000000, 0)
+
+func at(s int) time.Time {
+	return epoch.Add(time.Duration(s) * time.Second)
+}
+
+func TestRing(t *testing.T) {
+	r := NewRing(3)
+	require.Equal(t, 3, r.Cap())
+	_, ok := r.Last()
+	require.False(t, ok)
+	require.Empty(t, r.Samples())
+
+	r.Add(at(0), 1)
+	r.Add(at(1), 2)
+	require.Equal(t, 2, r.Len())
+	require.Equal(t, []Sample{{at(0), 1}, {at(1), 2}}, r.Samples())
+
+	r.Add(at(2), 3)
+	r.Add(at(3), 4)
+	require.Equal(t, 3, r.Len())
+	require.Equal(t, []Sample{{at(1), 2}, {at(2), 3}, {at(3), 4}}, r.Samples())
+	last, ok := r.Last()
+	require.True(t, ok)
+	require.Equal(t, Sample{at(3), 4}, last)
+
+	require.Equal(t, []Sample{{at(2), 3}, {at(3), 4}}, r.Since(at(2)))
+	require.Empty(t, r.Since(at(4)))
+	require.Equal(t, []float64{2, 3, 4}, Offsets(r.Samples()))
+
+	r.Reset()
+	require.Equal(t, 0, r.Len())
+}
+
+func TestPercentiles(t *testing.T) {
+	s := []Sample{}
+	require.Equal(t, []float64{0}, Percentiles(s, 50))
+	for i := 1; i <= 100; i++ {
+		s = append(s, Sample{at(i), float64(101 - i)})
+	}
+	require.Equal(t, []float64{1, 50, 90, 99, 100}, Percentiles(s, 0, 50, 90, 99, 100))
+	require.Equal(t, 50.0, Percentile(s, 50))
+
+	abs := []Sample{{at(0), -10}, {at(1), 1}, {at(2), 2}}
+	require.Equal(t, []float64{2, 10}, AbsPercentiles(abs, 50, 100))
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples")
+	r, err := Load(path, 3)
+	require.NoError(t, err)
+	require.Equal(t, 0, r.Len())
+
+	r.Add(at(0), 1.5)
+	r.Add(at(1), -2.5)
+	require.NoError(t, r.Save(path))
+
+	loaded, err := Load(path, 3)
+	require.NoError(t, err)
+	require.Equal(t, len(r.Samples()), len(loaded.Samples()))
+	for i, s := range r.Samples() {
+		require.True(t, s.Time.Equal(loaded.Samples()[i].Time))
+		require.Equal(t, s.Offset, loaded.Samples()[i].Offset)
+	}
+
+	// smaller capacity keeps the most recent samples
+	loaded, err = Load(path, 1)
+	require.NoError(t, err)
+	require.Equal(t, []float64{-2.5}, Offsets(loaded.Samples()))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte{1, 2, 3}, 0644))
+	_, err = Load(path, 3)
+	require.ErrorIs(t, err, errTruncated)
+}
@@ -0,0 +1,301 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package snmp implements a minimal read-only SNMPv2c agent which is enough to expose
+health of time appliances to existing network monitoring:
+* GetRequest, GetNextRequest and GetBulkRequest PDUs
+* community based access, requests with other versions or communities are dropped
+* variables are provided by a callback on each request, so values are always fresh
+*/
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// snmp versions on the wire
+const (
+	versionV2c = 1
+)
+
+// error statuses of the response PDU
+const (
+	errStatusTooBig = 1
+)
+
+// DefaultMaxRepetitions caps max-repetitions of GetBulk requests
+const DefaultMaxRepetitions = 32
+
+// maxMessageSize is the largest response we send, which fits into a single unfragmented UDP packet
+const maxMessageSize = 1472
+
+// maxRequestVarbinds caps variable bindings of requests, larger requests are dropped
+const maxRequestVarbinds = 128
+
+// Variable is a single managed object
+type Variable struct {
+	OID OID
+	// Value is one of int, int64, bool (encoded as TruthValue, 1 is true and 2 is false), string, []byte, OID, Counter32, Gauge32, TimeTicks, Counter64
+	Value interface{}
+}
+
+// Agent answers SNMP requests for variables
+type Agent struct {
+	Community string
+	// Variables returns all variables served by the agent, in any order
+	Variables func() []Variable
+	// MaxRepetitions caps max-repetitions of GetBulk, DefaultMaxRepetitions if 0
+	MaxRepetitions int
+}
+
+// request is a decoded SNMP message
+type request struct {
+	community string
+	pduType   byte
+	id        int64
+	// nonRepeaters and maxRepetitions are only set for GetBulk
+	nonRepeaters   int64
+	maxRepetitions int64
+	oids           []OID
+}
+
+// Serve answers requests received on conn until it fails
+func (a *Agent) Serve(conn net.PacketConn) error {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		resp, err := a.Handle(buf[:n])
+		if err != nil {
+			log.Debugf("snmp: dropping request from %s: %v", addr, err)
+			continue
+		}
+		if _, err := conn.WriteTo(resp, addr); err != nil {
+			log.Errorf("snmp: responding to %s: %v", addr, err)
+		}
+	}
+}
+
+// Handle returns response to the request message
+func (a *Agent) Handle(b []byte) ([]byte, error) {
+	req, err := decodeRequest(b)
+	if err != nil {
+		return nil, err
+	}
+	if req.community != a.Community {
+		return nil, errors.New("wrong community")
+	}
+	vars := a.Variables()
+	sort.Slice(vars, func(i, j int) bool { return vars[i].OID.Compare(vars[j].OID) < 0 })
+
+	res := &varbinds{req: req}
+	fits := true
+	switch req.pduType {
+	case tagGetRequest:
+		for _, oid := range req.oids {
+			if fits, err = res.add(get(vars, oid)); err != nil || !fits {
+				break
+			}
+		}
+	case tagGetNextRequest:
+		for _, oid := range req.oids {
+			if fits, err = res.add(next(vars, oid)); err != nil || !fits {
+				break
+			}
+		}
+	case tagGetBulkRequest:
+		// GetBulk responses are truncated to fit, others fail with tooBig
+		fits, err = a.bulk(vars, req, res)
+		fits = fits || res.n > 0
+	default:
+		return nil, fmt.Errorf("unsupported PDU type %#x", req.pduType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !fits {
+		return encodeResponse(req, errStatusTooBig, nil), nil
+	}
+	return encodeResponse(req, 0, res.b), nil
+}
+
+// bulk adds variables for GetBulk request as per RFC 3416 section 4.2.3 until the response is full.
+// It returns false if some variables didn't fit
+func (a *Agent) bulk(vars []Variable, req *request, res *varbinds) (bool, error) {
+	maxRep := int64(a.MaxRepetitions)
+	if maxRep <= 0 {
+		maxRep = DefaultMaxRepetitions
+	}
+	if req.maxRepetitions < maxRep {
+		maxRep = req.maxRepetitions
+	}
+	nonRep := req.nonRepeaters
+	if nonRep < 0 {
+		nonRep = 0
+	}
+	if nonRep > int64(len(req.oids)) {
+		nonRep = int64(len(req.oids))
+	}
+
+	for _, oid := range req.oids[:nonRep] {
+		if fits, err := res.add(next(vars, oid)); err != nil || !fits {
+			return fits, err
+		}
+	}
+	repeaters := append([]OID{}, req.oids[nonRep:]...)
+	for r := int64(0); r < maxRep && len(repeaters) > 0; r++ {
+		done := true
+		for i, oid := range repeaters {
+			v := next(vars, oid)
+			if fits, err := res.add(v); err != nil || !fits {
+				return fits, err
+			}
+			repeaters[i] = v.OID
+			if v.Value != exception(tagEndOfMibView) {
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+	}
+	return true, nil
+}
+
+// get returns variable with the oid
+func get(vars []Variable, oid OID) Variable {
+	i := sort.Search(len(vars), func(i int) bool { return vars[i].OID.Compare(oid) >= 0 })
+	if i < len(vars) && vars[i].OID.Compare(oid) == 0 {
+		return vars[i]
+	}
+	return Variable{OID: oid, Value: exception(tagNoSuchObject)}
+}
+
+// next returns the first variable after the oid
+func next(vars []Variable, oid OID) Variable {
+	i := sort.Search(len(vars), func(i int) bool { return vars[i].OID.Compare(oid) > 0 })
+	if i < len(vars) {
+		return vars[i]
+	}
+	return Variable{OID: oid, Value: exception(tagEndOfMibView)}
+}
+
+func decodeRequest(b []byte) (*request, error) {
+	msg, _, err := readExpected(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	version, msg, err := readInt(msg)
+	if err != nil {
+		return nil, fmt.Errorf("reading version: %w", err)
+	}
+	if version != versionV2c {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+	community, msg, err := readExpected(msg, tagOctetString)
+	if err != nil {
+		return nil, fmt.Errorf("reading community: %w", err)
+	}
+	pdu, _, err := readTLV(msg)
+	if err != nil {
+		return nil, fmt.Errorf("reading PDU: %w", err)
+	}
+	req := &request{community: string(community), pduType: pdu.tag}
+	body := pdu.content
+	if req.id, body, err = readInt(body); err != nil {
+		return nil, fmt.Errorf("reading request id: %w", err)
+	}
+	// error status and index are non-repeaters and max-repetitions for GetBulk
+	if req.nonRepeaters, body, err = readInt(body); err != nil {
+		return nil, fmt.Errorf("reading error status: %w", err)
+	}
+	if req.maxRepetitions, body, err = readInt(body); err != nil {
+		return nil, fmt.Errorf("reading error index: %w", err)
+	}
+	varbinds, _, err := readExpected(body, tagSequence)
+	if err != nil {
+		return nil, fmt.Errorf("reading variable bindings: %w", err)
+	}
+	for len(varbinds) > 0 {
+		var vb []byte
+		if vb, varbinds, err = readExpected(varbinds, tagSequence); err != nil {
+			return nil, fmt.Errorf("reading variable binding: %w", err)
+		}
+		content, _, err := readExpected(vb, tagOID)
+		if err != nil {
+			return nil, fmt.Errorf("reading variable name: %w", err)
+		}
+		oid, err := decodeOID(content)
+		if err != nil {
+			return nil, fmt.Errorf("reading variable name: %w", err)
+		}
+		if len(req.oids) == maxRequestVarbinds {
+			return nil, fmt.Errorf("more than %d variable bindings", maxRequestVarbinds)
+		}
+		req.oids = append(req.oids, oid)
+	}
+	return req, nil
+}
+
+// varbinds accumulates encoded variable bindings of the response as long as it fits into maxMessageSize
+type varbinds struct {
+	req *request
+	b   []byte
+	n   int
+}
+
+// add encodes the variable. It returns false and leaves the bindings intact if the response would become too big
+func (v *varbinds) add(x Variable) (bool, error) {
+	vb := appendTLV(nil, tagOID, encodeOID(x.OID))
+	vb, err := appendValue(vb, x.Value)
+	if err != nil {
+		return false, fmt.Errorf("encoding %s: %w", x.OID, err)
+	}
+	size := len(v.b) + tlvSize(len(vb))
+	if responseSize(v.req, size) > maxMessageSize {
+		return false, nil
+	}
+	v.b = appendTLV(v.b, tagSequence, vb)
+	v.n++
+	return true, nil
+}
+
+// responseSize returns size of the response message with encoded variable bindings of the size
+func responseSize(req *request, varbindsSize int) int {
+	pdu := tlvSize(len(encodeInt(req.id))) + tlvSize(len(encodeInt(errStatusTooBig))) + tlvSize(len(encodeInt(0))) + tlvSize(varbindsSize)
+	msg := tlvSize(len(encodeInt(versionV2c))) + tlvSize(len(req.community)) + tlvSize(pdu)
+	return tlvSize(msg)
+}
+
+func encodeResponse(req *request, errStatus int64, varbinds []byte) []byte {
+	pdu := appendTLV(nil, tagInteger, encodeInt(req.id))
+	pdu = appendTLV(pdu, tagInteger, encodeInt(errStatus))
+	pdu = appendTLV(pdu, tagInteger, encodeInt(0))
+	pdu = appendTLV(pdu, tagSequence, varbinds)
+
+	msg := appendTLV(nil, tagInteger, encodeInt(versionV2c))
+	msg = appendTLV(msg, tagOctetString, []byte(req.community))
+	msg = appendTLV(msg, tagResponse, pdu)
+	return appendTLV(nil, tagSequence, msg)
+}
@@ -0,0 +1,248 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testPrefix = MustParseOID("1.3.6.1.3.4242")
+
+func testAgent() *Agent {
+	return &Agent{
+		Community: "public",
+		Variables: func() []Variable {
+			return []Variable{
+				{OID: testPrefix.Append(2, 0), Value: "second"},
+				{OID: testPrefix.Append(1, 0), Value: 42},
+				{OID: testPrefix.Append(3, 0), Value: Gauge32(7)},
+			}
+		},
+	}
+}
+
+func encodeRequest(version int64, community string, pduType byte, a, b int64, oids ...OID) []byte {
+	var varbinds []byte
+	for _, oid := range oids {
+		vb := appendTLV(nil, tagOID, encodeOID(oid))
+		vb = appendTLV(vb, tagNull, nil)
+		varbinds = appendTLV(varbinds, tagSequence, vb)
+	}
+	pdu := appendTLV(nil, tagInteger, encodeInt(1234))
+	pdu = appendTLV(pdu, tagInteger, encodeInt(a))
+	pdu = appendTLV(pdu, tagInteger, encodeInt(b))
+	pdu = appendTLV(pdu, tagSequence, varbinds)
+	msg := appendTLV(nil, tagInteger, encodeInt(version))
+	msg = appendTLV(msg, tagOctetString, []byte(community))
+	msg = appendTLV(msg, pduType, pdu)
+	return appendTLV(nil, tagSequence, msg)
+}
+
+// decodeResponse returns error status and variables of response, values are decoded into int64, string or exception
+func decodeResponse(t *testing.T, b []byte) (int64, []Variable) {
+	msg, rest, err := readExpected(b, tagSequence)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	version, msg, err := readInt(msg)
+	require.NoError(t, err)
+	require.Equal(t, int64(versionV2c), version)
+	_, msg, err = readExpected(msg, tagOctetString)
+	require.NoError(t, err)
+	pdu, _, err := readExpected(msg, tagResponse)
+	require.NoError(t, err)
+	id, pdu, err := readInt(pdu)
+	require.NoError(t, err)
+	require.Equal(t, int64(1234), id)
+	errStatus, pdu, err := readInt(pdu)
+	require.NoError(t, err)
+	_, pdu, err = readInt(pdu)
+	require.NoError(t, err)
+	varbinds, _, err := readExpected(pdu, tagSequence)
+	require.NoError(t, err)
+
+	var vars []Variable
+	for len(varbinds) > 0 {
+		var vb []byte
+		vb, varbinds, err = readExpected(varbinds, tagSequence)
+		require.NoError(t, err)
+		content, vb, err := readExpected(vb, tagOID)
+		require.NoError(t, err)
+		oid, err := decodeOID(content)
+		require.NoError(t, err)
+		value, _, err := readTLV(vb)
+		require.NoError(t, err)
+		v := Variable{OID: oid}
+		switch value.tag {
+		case tagInteger, tagGauge32, tagCounter32, tagCounter64, tagTimeTicks:
+			v.Value, err = decodeInt(append([]byte{0}, value.content...))
+			require.NoError(t, err)
+		case tagOctetString:
+			v.Value = string(value.content)
+		default:
+			v.Value = exception(value.tag)
+		}
+		vars = append(vars, v)
+	}
+	return errStatus, vars
+}
+
+func TestAgentGet(t *testing.T) {
+	a := testAgent()
+	resp, err := a.Handle(encodeRequest(versionV2c, "public", tagGetRequest, 0, 0, testPrefix.Append(1, 0), testPrefix.Append(9, 0)))
+	require.NoError(t, err)
+	status, vars := decodeResponse(t, resp)
+	require.Equal(t, int64(0), status)
+	require.Equal(t, []Variable{
+		{OID: testPrefix.Append(1, 0), Value: int64(42)},
+		{OID: testPrefix.Append(9, 0), Value: exception(tagNoSuchObject)},
+	}, vars)
+}
+
+func TestAgentGetNext(t *testing.T) {
+	a := testAgent()
+	resp, err := a.Handle(encodeRequest(versionV2c, "public", tagGetNextRequest, 0, 0, testPrefix, testPrefix.Append(1, 0), testPrefix.Append(3, 0)))
+	require.NoError(t, err)
+	_, vars := decodeResponse(t, resp)
+	require.Equal(t, []Variable{
+		{OID: testPrefix.Append(1, 0), Value: int64(42)},
+		{OID: testPrefix.Append(2, 0), Value: "second"},
+		{OID: testPrefix.Append(3, 0), Value: exception(tagEndOfMibView)},
+	}, vars)
+}
+
+func TestAgentGetBulk(t *testing.T) {
+	a := testAgent()
+	// one non repeater and walk of the whole tree
+	resp, err := a.Handle(encodeRequest(versionV2c, "public", tagGetBulkRequest, 1, 10, testPrefix.Append(2, 0), testPrefix))
+	require.NoError(t, err)
+	_, vars := decodeResponse(t, resp)
+	require.Equal(t, []Variable{
+		{OID: testPrefix.Append(3, 0), Value: int64(7)},
+		{OID: testPrefix.Append(1, 0), Value: int64(42)},
+		{OID: testPrefix.Append(2, 0), Value: "second"},
+		{OID: testPrefix.Append(3, 0), Value: int64(7)},
+		{OID: testPrefix.Append(3, 0), Value: exception(tagEndOfMibView)},
+	}, vars)
+
+	// max repetitions is capped by the agent
+	a.MaxRepetitions = 2
+	resp, err = a.Handle(encodeRequest(versionV2c, "public", tagGetBulkRequest, 0, 10, testPrefix))
+	require.NoError(t, err)
+	_, vars = decodeResponse(t, resp)
+	require.Len(t, vars, 2)
+}
+
+func TestAgentGetBulkTruncated(t *testing.T) {
+	a := &Agent{
+		Community: "public",
+		Variables: func() []Variable {
+			vars := []Variable{}
+			for i := uint32(0); i < 30; i++ {
+				vars = append(vars, Variable{OID: testPrefix.Append(i), Value: fmt.Sprintf("%0100d", i)})
+			}
+			return vars
+		},
+	}
+	resp, err := a.Handle(encodeRequest(versionV2c, "public", tagGetBulkRequest, 0, 30, testPrefix))
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(resp), maxMessageSize)
+	status, vars := decodeResponse(t, resp)
+	require.Equal(t, int64(0), status)
+	require.Greater(t, len(vars), 1)
+	require.Less(t, len(vars), 30)
+
+	// the same doesn't fit into Get response
+	oids := []OID{}
+	for i := uint32(0); i < 30; i++ {
+		oids = append(oids, testPrefix.Append(i))
+	}
+	resp, err = a.Handle(encodeRequest(versionV2c, "public", tagGetRequest, 0, 0, oids...))
+	require.NoError(t, err)
+	status, vars = decodeResponse(t, resp)
+	require.Equal(t, int64(errStatusTooBig), status)
+	require.Empty(t, vars)
+}
+
+func TestAgentGetBulkLarge(t *testing.T) {
+	a := &Agent{
+		Community: "public",
+		Variables: func() []Variable {
+			vars := []Variable{}
+			for i := uint32(0); i < 1000; i++ {
+				vars = append(vars, Variable{OID: testPrefix.Append(i), Value: fmt.Sprintf("%020d", i)})
+			}
+			return vars
+		},
+		MaxRepetitions: 1000,
+	}
+	oids := []OID{}
+	for i := 0; i < maxRequestVarbinds; i++ {
+		oids = append(oids, testPrefix)
+	}
+	req := encodeRequest(versionV2c, "public", tagGetBulkRequest, 0, 1000, oids...)
+	resp, err := a.Handle(req)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(resp), maxMessageSize)
+	status, vars := decodeResponse(t, resp)
+	require.Equal(t, int64(0), status)
+	require.NotEmpty(t, vars)
+	// the response is filled up to the limit
+	require.Greater(t, len(resp), maxMessageSize-64)
+
+	// requests with too many variable bindings are dropped
+	_, err = a.Handle(encodeRequest(versionV2c, "public", tagGetBulkRequest, 0, 1000, append(oids, testPrefix)...))
+	require.Error(t, err)
+}
+
+func TestAgentDrops(t *testing.T) {
+	a := testAgent()
+	_, err := a.Handle(encodeRequest(versionV2c, "private", tagGetRequest, 0, 0, testPrefix))
+	require.Error(t, err)
+	// SNMPv1
+	_, err = a.Handle(encodeRequest(0, "public", tagGetRequest, 0, 0, testPrefix))
+	require.Error(t, err)
+	// SetRequest
+	_, err = a.Handle(encodeRequest(versionV2c, "public", 0xa3, 0, 0, testPrefix))
+	require.Error(t, err)
+	_, err = a.Handle([]byte{tagSequence, 0x10, 0x02})
+	require.Error(t, err)
+}
+
+func TestAgentServe(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	a := testAgent()
+	go func() {
+		_ = a.Serve(conn)
+	}()
+	defer conn.Close()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write(encodeRequest(versionV2c, "public", tagGetRequest, 0, 0, testPrefix.Append(2, 0)))
+	require.NoError(t, err)
+	buf := make([]byte, 1500)
+	n, err := client.Read(buf)
+	require.NoError(t, err)
+	_, vars := decodeResponse(t, buf[:n])
+	require.Equal(t, []Variable{{OID: testPrefix.Append(2, 0), Value: "second"}}, vars)
+}
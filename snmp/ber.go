@@ -0,0 +1,312 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// BER tags used by SNMPv2c
+const (
+	tagInteger        = 0x02
+	tagOctetString    = 0x04
+	tagNull           = 0x05
+	tagOID            = 0x06
+	tagSequence       = 0x30
+	tagCounter32      = 0x41
+	tagGauge32        = 0x42
+	tagTimeTicks      = 0x43
+	tagCounter64      = 0x46
+	tagNoSuchObject   = 0x80
+	tagNoSuchInstance = 0x81
+	tagEndOfMibView   = 0x82
+	tagGetRequest     = 0xa0
+	tagGetNextRequest = 0xa1
+	tagResponse       = 0xa2
+	tagGetBulkRequest = 0xa5
+)
+
+var errTruncated = errors.New("truncated BER encoding")
+
+// Counter32 is a wrapping 32 bit counter value
+type Counter32 uint32
+
+// Gauge32 is a non negative 32 bit value which may go up and down
+type Gauge32 uint32
+
+// TimeTicks is time in hundredths of a second
+type TimeTicks uint32
+
+// Counter64 is a wrapping 64 bit counter value
+type Counter64 uint64
+
+// OID is an object identifier such as 1.3.6.1.2.1.1.1.0
+type OID []uint32
+
+// ParseOID parses dotted OID, leading dot is optional
+func ParseOID(s string) (OID, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("OID %q is too short", s)
+	}
+	oid := make(OID, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q: %w", s, err)
+		}
+		oid = append(oid, uint32(id))
+	}
+	return oid, nil
+}
+
+// MustParseOID parses OID, panicking on error. Used for constants
+func MustParseOID(s string) OID {
+	oid, err := ParseOID(s)
+	if err != nil {
+		panic(err)
+	}
+	return oid
+}
+
+func (o OID) String() string {
+	parts := make([]string, len(o))
+	for i, id := range o {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// Append returns a new OID with ids appended
+func (o OID) Append(ids ...uint32) OID {
+	res := make(OID, 0, len(o)+len(ids))
+	res = append(res, o...)
+	return append(res, ids...)
+}
+
+// Compare returns -1, 0 or 1 if o is before, equal or after other in lexicographic order
+func (o OID) Compare(other OID) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		if o[i] != other[i] {
+			if o[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(o) < len(other):
+		return -1
+	case len(o) > len(other):
+		return 1
+	}
+	return 0
+}
+
+// appendTLV appends tag, length and content
+func appendTLV(b []byte, tag byte, content []byte) []byte {
+	b = append(b, tag)
+	n := len(content)
+	if n < 0x80 {
+		b = append(b, byte(n))
+	} else {
+		size := (bits.Len(uint(n)) + 7) / 8
+		b = append(b, 0x80|byte(size))
+		for i := size - 1; i >= 0; i-- {
+			b = append(b, byte(n>>(8*i)))
+		}
+	}
+	return append(b, content...)
+}
+
+// tlvSize returns size of TLV with content of n bytes
+func tlvSize(n int) int {
+	if n < 0x80 {
+		return 2 + n
+	}
+	return 2 + (bits.Len(uint(n))+7)/8 + n
+}
+
+func encodeInt(v int64) []byte {
+	b := []byte{byte(v)}
+	for v > 127 || v < -128 {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	return b
+}
+
+func encodeUint(v uint64) []byte {
+	b := []byte{byte(v)}
+	for v > 127 {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	return b
+}
+
+func encodeOID(o OID) []byte {
+	if len(o) < 2 {
+		return []byte{0}
+	}
+	b := encodeBase128(nil, o[0]*40+o[1])
+	for _, id := range o[2:] {
+		b = encodeBase128(b, id)
+	}
+	return b
+}
+
+func encodeBase128(b []byte, v uint32) []byte {
+	var tmp [5]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		tmp[i] = byte(v&0x7f) | 0x80
+	}
+	return append(b, tmp[i:]...)
+}
+
+// appendValue appends BER encoding of the variable value
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return appendTLV(b, tagNull, nil), nil
+	case int:
+		return appendTLV(b, tagInteger, encodeInt(int64(v))), nil
+	case int64:
+		return appendTLV(b, tagInteger, encodeInt(v)), nil
+	case bool:
+		// TruthValue of SNMPv2-TC
+		if v {
+			return appendTLV(b, tagInteger, encodeInt(1)), nil
+		}
+		return appendTLV(b, tagInteger, encodeInt(2)), nil
+	case string:
+		return appendTLV(b, tagOctetString, []byte(v)), nil
+	case []byte:
+		return appendTLV(b, tagOctetString, v), nil
+	case OID:
+		return appendTLV(b, tagOID, encodeOID(v)), nil
+	case Counter32:
+		return appendTLV(b, tagCounter32, encodeUint(uint64(v))), nil
+	case Gauge32:
+		return appendTLV(b, tagGauge32, encodeUint(uint64(v))), nil
+	case TimeTicks:
+		return appendTLV(b, tagTimeTicks, encodeUint(uint64(v))), nil
+	case Counter64:
+		return appendTLV(b, tagCounter64, encodeUint(uint64(v))), nil
+	case exception:
+		return appendTLV(b, byte(v), nil), nil
+	}
+	return nil, fmt.Errorf("unsupported value type %T", v)
+}
+
+// exception is a varbind value for missing variables
+type exception byte
+
+// tlv is a decoded BER element
+type tlv struct {
+	tag     byte
+	content []byte
+}
+
+// readTLV decodes the element at the start of b and returns it with the rest of b
+func readTLV(b []byte) (tlv, []byte, error) {
+	if len(b) < 2 {
+		return tlv{}, nil, errTruncated
+	}
+	tag, n := b[0], int(b[1])
+	b = b[2:]
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(b) < size {
+			return tlv{}, nil, errTruncated
+		}
+		n = 0
+		for _, c := range b[:size] {
+			n = n<<8 | int(c)
+		}
+		b = b[size:]
+	}
+	if n < 0 || len(b) < n {
+		return tlv{}, nil, errTruncated
+	}
+	return tlv{tag: tag, content: b[:n]}, b[n:], nil
+}
+
+// readExpected decodes the element with the tag
+func readExpected(b []byte, tag byte) ([]byte, []byte, error) {
+	t, rest, err := readTLV(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.tag != tag {
+		return nil, nil, fmt.Errorf("expected tag %#x, got %#x", tag, t.tag)
+	}
+	return t.content, rest, nil
+}
+
+func decodeInt(b []byte) (int64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, fmt.Errorf("invalid integer length %d", len(b))
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v, nil
+}
+
+func readInt(b []byte) (int64, []byte, error) {
+	content, rest, err := readExpected(b, tagInteger)
+	if err != nil {
+		return 0, nil, err
+	}
+	v, err := decodeInt(content)
+	return v, rest, err
+}
+
+func decodeOID(b []byte) (OID, error) {
+	if len(b) == 0 {
+		return nil, errTruncated
+	}
+	var ids []uint32
+	var v uint32
+	for i, c := range b {
+		if v > 1<<25 {
+			return nil, errors.New("OID component overflows")
+		}
+		v = v<<7 | uint32(c&0x7f)
+		if c&0x80 == 0 {
+			ids = append(ids, v)
+			v = 0
+		} else if i == len(b)-1 {
+			return nil, errTruncated
+		}
+	}
+	first := ids[0]
+	oid := OID{first / 40, first % 40}
+	if first >= 80 {
+		oid = OID{2, first - 80}
+	}
+	return append(oid, ids[1:]...), nil
+}
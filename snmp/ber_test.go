@@ -0,0 +1,116 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snmp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOID(t *testing.T) {
+	oid, err := ParseOID(".1.3.6.1.3.4242")
+	require.NoError(t, err)
+	require.Equal(t, OID{1, 3, 6, 1, 3, 4242}, oid)
+	require.Equal(t, "1.3.6.1.3.4242", oid.String())
+
+	_, err = ParseOID("1")
+	require.Error(t, err)
+	_, err = ParseOID("1.3.x")
+	require.Error(t, err)
+}
+
+func TestOIDCompare(t *testing.T) {
+	a := MustParseOID("1.3.6.1.3.1")
+	require.Equal(t, 0, a.Compare(a))
+	require.Equal(t, -1, a.Compare(a.Append(1)))
+	require.Equal(t, 1, a.Append(1).Compare(a))
+	require.Equal(t, -1, a.Append(2).Compare(a.Append(10)))
+	require.Equal(t, OID{1, 3, 6, 1, 3, 1}, a)
+}
+
+func TestOIDRoundTrip(t *testing.T) {
+	for _, s := range []string{"1.3.6.1.3.4242.1.1.2.1", "0.0", "2.999.3", "1.3.6.1.4.1.4294967295"} {
+		oid := MustParseOID(s)
+		got, err := decodeOID(encodeOID(oid))
+		require.NoError(t, err)
+		require.Equal(t, oid, got)
+	}
+	// well known encoding of sysDescr.0
+	require.Equal(t, []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}, encodeOID(MustParseOID("1.3.6.1.2.1.1.1.0")))
+
+	_, err := decodeOID([]byte{0x2b, 0x86})
+	require.Error(t, err)
+}
+
+func TestIntRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, -1, 127, 128, -128, -129, 255, 256, 1 << 31, -(1 << 40)} {
+		got, err := decodeInt(encodeInt(v))
+		require.NoError(t, err)
+		require.Equal(t, v, got)
+	}
+	require.Equal(t, []byte{0x00, 0x80}, encodeInt(128))
+	require.Equal(t, []byte{0x00, 0xff, 0xff, 0xff, 0xff}, encodeUint(0xffffffff))
+}
+
+func TestTLVLongLength(t *testing.T) {
+	content := make([]byte, 300)
+	b := appendTLV(nil, tagOctetString, content)
+	require.Equal(t, []byte{tagOctetString, 0x82, 0x01, 0x2c}, b[:4])
+
+	v, rest, err := readTLV(append(b, 0x05))
+	require.NoError(t, err)
+	require.Equal(t, byte(tagOctetString), v.tag)
+	require.Len(t, v.content, 300)
+	require.Equal(t, []byte{0x05}, rest)
+
+	_, _, err = readTLV(b[:100])
+	require.ErrorIs(t, err, errTruncated)
+}
+
+func TestAppendValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  []byte
+	}{
+		{nil, []byte{tagNull, 0}},
+		{42, []byte{tagInteger, 1, 42}},
+		{int64(-2), []byte{tagInteger, 1, 0xfe}},
+		{true, []byte{tagInteger, 1, 1}},
+		{false, []byte{tagInteger, 1, 2}},
+		{"ok", []byte{tagOctetString, 2, 'o', 'k'}},
+		{Gauge32(200), []byte{tagGauge32, 2, 0, 200}},
+		{Counter32(1), []byte{tagCounter32, 1, 1}},
+		{TimeTicks(1), []byte{tagTimeTicks, 1, 1}},
+		{Counter64(1), []byte{tagCounter64, 1, 1}},
+		{exception(tagEndOfMibView), []byte{tagEndOfMibView, 0}},
+	}
+	for _, tt := range tests {
+		got, err := appendValue(nil, tt.value)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got, "%v", tt.value)
+	}
+	_, err := appendValue(nil, 4.2)
+	require.Error(t, err)
+}
+
+
+func TestTLVSize(t *testing.T) {
+	for _, n := range []int{0, 1, 0x7f, 0x80, 0xff, 0x100, 0x10000} {
+		require.Len(t, appendTLV(nil, tagOctetString, make([]byte, n)), tlvSize(n))
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package timecard
+
+import (
+	"fmt"
+
+	"github.com/facebook/time/oscillatord"
+)
+
+// gpsTAIOffset is TAI-GPS in seconds. GNSS leap seconds are GPS-UTC while utc_tai_offset is TAI-UTC
+const gpsTAIOffset = 19
+
+// Health is a combined health report of the Time Card and its oscillator
+type Health struct {
+	Card        *Card               `json:"card"`
+	Oscillatord *oscillatord.Status `json:"oscillatord"`
+	Healthy     bool                `json:"healthy"`
+	Problems    []string            `json:"problems"`
+}
+
+// NewHealth evaluates health of the Time Card for stratum 1 service.
+// status is optional and may be nil if oscillatord is not running
+func NewHealth(card *Card, status *oscillatord.Status) *Health {
+	h := &Health{Card: card, Oscillatord: status, Problems: []string{}}
+	problem := func(format string, a ...interface{}) {
+		h.Problems = append(h.Problems, fmt.Sprintf(format, a...))
+	}
+	if !card.GNSSSync {
+		problem("GNSS is not in sync: %s", card.GNSSSyncStatus)
+	}
+	if card.PTPDevice == "" {
+		problem("no PTP device")
+	}
+	if card.PPSDevice == "" {
+		problem("no PPS device")
+	}
+	if status != nil {
+		if !status.Oscillator.Lock {
+			problem("oscillator %s is not locked", status.Oscillator.Model)
+		}
+		if !status.GNSS.FixOK {
+			problem("oscillatord GNSS fix is %s", status.GNSS.Fix)
+		}
+		if status.GNSS.AntennaStatus != oscillatord.AntStatusOK {
+			problem("antenna status is %s", status.GNSS.AntennaStatus)
+		}
+		if card.UTCTAIOffset != 0 && status.GNSS.LeapSeconds != 0 && card.UTCTAIOffset != status.GNSS.LeapSeconds+gpsTAIOffset {
+			problem("UTC-TAI offset %d doesn't match GNSS leap seconds %d", card.UTCTAIOffset, status.GNSS.LeapSeconds)
+		}
+	}
+	h.Healthy = len(h.Problems) == 0
+	return h
+}
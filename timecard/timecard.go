@@ -0,0 +1,173 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package timecard reads Open Compute Time Card attributes exposed by ptp_ocp driver via sysfs
+and combines them with oscillatord data into a single device health report.
+*/
+package timecard
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SysfsPath is where ptp_ocp driver exposes Time Cards
+const SysfsPath = "/sys/class/timecard"
+
+// gnss_sync reports SYNC or "LOST @ <time>"
+const gnssSynced = "SYNC"
+
+// Card is a set of Time Card attributes
+type Card struct {
+	Name                  string   `json:"name"`
+	SerialNumber          string   `json:"serialnum"`
+	ClockSource           string   `json:"clock_source"`
+	AvailableClockSources []string `json:"available_clock_sources"`
+	GNSSSync              bool     `json:"gnss_sync"`
+	// GNSSSyncStatus is the raw gnss_sync value, containing time of the loss of sync
+	GNSSSyncStatus string `json:"gnss_sync_status"`
+	UTCTAIOffset   int    `json:"utc_tai_offset"`
+	// ClockStatusDrift and ClockStatusOffset are reported by the card firmware servo
+	ClockStatusDrift  int64 `json:"clock_status_drift"`
+	ClockStatusOffset int64 `json:"clock_status_offset"`
+	// PTPDevice, PPSDevice and GNSSTTY are device paths such as /dev/ptp1. Empty if not present
+	PTPDevice string `json:"ptp_device"`
+	PPSDevice string `json:"pps_device"`
+	GNSSTTY   string `json:"gnss_tty"`
+}
+
+// List returns names of Time Cards found in the sysfs directory such as SysfsPath
+func List(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readAttr reads sysfs attribute. Missing attribute results in empty value
+func readAttr(dir, name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// readInt reads integer sysfs attribute. Missing attribute results in 0
+func readInt(dir, name string) (int64, error) {
+	v, err := readAttr(dir, name)
+	if err != nil || v == "" {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return i, nil
+}
+
+// readLink returns /dev path of the device sysfs link points to. Missing link results in empty value
+func readLink(dir, name string) (string, error) {
+	target, err := os.Readlink(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join("/dev", filepath.Base(target)), nil
+}
+
+// ReadCard reads attributes of the Time Card from its sysfs directory such as /sys/class/timecard/ocp0
+func ReadCard(dir string) (*Card, error) {
+	c := &Card{Name: filepath.Base(dir)}
+	var err error
+	if _, err = os.Stat(dir); err != nil {
+		return nil, err
+	}
+	if c.SerialNumber, err = readAttr(dir, "serialnum"); err != nil {
+		return nil, err
+	}
+	if c.ClockSource, err = readAttr(dir, "clock_source"); err != nil {
+		return nil, err
+	}
+	sources, err := readAttr(dir, "available_clock_sources")
+	if err != nil {
+		return nil, err
+	}
+	c.AvailableClockSources = strings.Fields(sources)
+	if c.GNSSSyncStatus, err = readAttr(dir, "gnss_sync"); err != nil {
+		return nil, err
+	}
+	c.GNSSSync = c.GNSSSyncStatus == gnssSynced
+	offset, err := readInt(dir, "utc_tai_offset")
+	if err != nil {
+		return nil, err
+	}
+	c.UTCTAIOffset = int(offset)
+	if c.ClockStatusDrift, err = readInt(dir, "clock_status_drift"); err != nil {
+		return nil, err
+	}
+	if c.ClockStatusOffset, err = readInt(dir, "clock_status_offset"); err != nil {
+		return nil, err
+	}
+	if c.PTPDevice, err = readLink(dir, "ptp"); err != nil {
+		return nil, err
+	}
+	if c.PPSDevice, err = readLink(dir, "pps"); err != nil {
+		return nil, err
+	}
+	if c.GNSSTTY, err = readLink(dir, "ttyGNSS"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReadCards reads all Time Cards found in the sysfs directory such as SysfsPath
+func ReadCards(dir string) ([]*Card, error) {
+	names, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	cards := []*Card{}
+	for _, name := range names {
+		c, err := ReadCard(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		cards = append(cards, c)
+	}
+	return cards, nil
+}
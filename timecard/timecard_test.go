@@ -0,0 +1,113 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package timecard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/facebook/time/oscillatord"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCard(t *testing.T, dir string, attrs map[string]string, links map[string]string) {
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for k, v := range attrs {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, k), []byte(v+"\n"), 0644))
+	}
+	for k, v := range links {
+		require.NoError(t, os.Symlink(v, filepath.Join(dir, k)))
+	}
+}
+
+func testCard() *Card {
+	return &Card{
+		Name:                  "ocp0",
+		SerialNumber:          "fb:01:02:03:04:05",
+		ClockSource:           "PPS",
+		AvailableClockSources: []string{"NONE", "PPS", "TOD", "IRIG", "DCF"},
+		GNSSSync:              true,
+		GNSSSyncStatus:        "SYNC",
+		UTCTAIOffset:          37,
+		ClockStatusDrift:      -12,
+		ClockStatusOffset:     3,
+		PTPDevice:             "/dev/ptp2",
+		PPSDevice:             "/dev/pps1",
+		GNSSTTY:               "/dev/ttyS5",
+	}
+}
+
+func TestReadCards(t *testing.T) {
+	root := t.TempDir()
+	writeCard(t, filepath.Join(root, "ocp0"), map[string]string{
+		"serialnum":               "fb:01:02:03:04:05",
+		"clock_source":            "PPS",
+		"available_clock_sources": "NONE PPS TOD IRIG DCF",
+		"gnss_sync":               "SYNC",
+		"utc_tai_offset":          "37",
+		"clock_status_drift":      "-12",
+		"clock_status_offset":     "3",
+	}, map[string]string{
+		"ptp":     "../../ptp/ptp2",
+		"pps":     "../../pps/pps1",
+		"ttyGNSS": "../../tty/ttyS5",
+	})
+	writeCard(t, filepath.Join(root, "ocp1"), map[string]string{
+		"gnss_sync": "LOST @ 2021-12-20T11:33:20",
+	}, nil)
+
+	cards, err := ReadCards(root)
+	require.NoError(t, err)
+	require.Len(t, cards, 2)
+	require.Equal(t, testCard(), cards[0])
+	require.Equal(t, &Card{Name: "ocp1", GNSSSyncStatus: "LOST @ 2021-12-20T11:33:20", AvailableClockSources: []string{}}, cards[1])
+
+	names, err := List(filepath.Join(root, "missing"))
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	_, err = ReadCard(filepath.Join(root, "missing"))
+	require.Error(t, err)
+
+	writeCard(t, filepath.Join(root, "ocp2"), map[string]string{"utc_tai_offset": "x"}, nil)
+	_, err = ReadCards(root)
+	require.Error(t, err)
+}
+
+func TestNewHealth(t *testing.T) {
+	status := &oscillatord.Status{
+		Oscillator: oscillatord.Oscillator{Model: "sa5x", Lock: true},
+		GNSS:       oscillatord.GNSS{FixOK: true, Fix: oscillatord.Fix3D, AntennaStatus: oscillatord.AntStatusOK, LeapSeconds: 18},
+	}
+	h := NewHealth(testCard(), status)
+	require.True(t, h.Healthy, h.Problems)
+
+	h = NewHealth(testCard(), nil)
+	require.True(t, h.Healthy, h.Problems)
+
+	card := testCard()
+	card.GNSSSync = false
+	card.PPSDevice = ""
+	status.Oscillator.Lock = false
+	status.GNSS.AntennaStatus = oscillatord.AntStatusOpen
+	status.GNSS.LeapSeconds = 17
+	h = NewHealth(card, status)
+	require.False(t, h.Healthy)
+	require.Len(t, h.Problems, 5)
+}
@@ -303,13 +303,20 @@ func ReadPacketWithRXTimestamp(connFd int) ([]byte, unix.Sockaddr, time.Time, er
 // ReadPacketWithRXTimestampBuf writes byte packet into provide buffer buf, and returns number of bytes copied to the buffer, client ip and HW RX timestamp.
 // oob buffer can be reaused after ReadPacketWithRXTimestampBuf call.
 func ReadPacketWithRXTimestampBuf(connFd int, buf, oob []byte) (int, unix.Sockaddr, time.Time, error) {
+	bbuf, _, saddr, timestamp, err := ReadPacketWithRXTimestampOOB(connFd, buf, oob)
+	return bbuf, saddr, timestamp, err
+}
+
+// ReadPacketWithRXTimestampOOB is ReadPacketWithRXTimestampBuf which also returns number of bytes written to oob,
+// so other control messages, such as TTL, can be parsed from it.
+func ReadPacketWithRXTimestampOOB(connFd int, buf, oob []byte) (int, int, unix.Sockaddr, time.Time, error) {
 	bbuf, boob, _, saddr, err := unix.Recvmsg(connFd, buf, oob, 0)
 	if err != nil {
-		return 0, nil, time.Time{}, fmt.Errorf("failed to read timestamp: %v", err)
+		return 0, 0, nil, time.Time{}, fmt.Errorf("failed to read timestamp: %v", err)
 	}
 
 	timestamp, err := socketControlMessageTimestamp(oob[:boob])
-	return bbuf, saddr, timestamp, err
+	return bbuf, boob, saddr, timestamp, err
 }
 
 // IPToSockaddr converts IP + port into a socket address
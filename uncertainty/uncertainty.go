@@ -0,0 +1,207 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package uncertainty combines errors of different time sources into a single window
+the true time is expected to be within, so applications can wait out or compare against it
+instead of trusting the system clock blindly.
+
+Errors of the sources are added up, which gives the worst case bound rather than a statistical one.
+*/
+package uncertainty
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMaxDrift is the max frequency error of the system clock assumed for aging measurements.
+// Same as PHI in RFC5905, 15 ppm
+const DefaultMaxDrift = 15e-6
+
+// Source is anything which can tell its time error at the time now.
+// oscillatord.HoldoverEstimator is a Source
+type Source interface {
+	Uncertainty(now time.Time) time.Duration
+}
+
+// SourceFunc is an adapter to use ordinary functions as Source
+type SourceFunc func(now time.Time) time.Duration
+
+// Uncertainty calls f(now)
+func (f SourceFunc) Uncertainty(now time.Time) time.Duration {
+	return f(now)
+}
+
+// aged returns err grown by maxDrift since measured
+func aged(err time.Duration, measured, now time.Time, maxDrift float64) time.Duration {
+	age := now.Sub(measured)
+	if age < 0 || measured.IsZero() {
+		age = 0
+	}
+	return err + time.Duration(maxDrift*float64(age))
+}
+
+// NTP is the error of time received from NTP server, which is the root distance at the time of measurement.
+// Root delay and dispersion are taken from the server response, Delay and Dispersion are of our own measurement
+type NTP struct {
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	Delay          time.Duration
+	Dispersion     time.Duration
+	// Measured is the time of measurement, the error grows by MaxDrift since then
+	Measured time.Time
+	// MaxDrift defaults to DefaultMaxDrift
+	MaxDrift float64
+}
+
+// Uncertainty returns root distance aged to the time now
+func (n *NTP) Uncertainty(now time.Time) time.Duration {
+	maxDrift := n.MaxDrift
+	if maxDrift == 0 {
+		maxDrift = DefaultMaxDrift
+	}
+	distance := (n.RootDelay+n.Delay)/2 + n.RootDispersion + n.Dispersion
+	return aged(distance, n.Measured, now, maxDrift)
+}
+
+// PHC is the error of PHC to system clock offset measurement.
+// Delay and Dispersion are the same as in phc.OffsetStats
+type PHC struct {
+	Delay      time.Duration
+	Dispersion time.Duration
+	// Measured is the time of measurement, the error grows by MaxDrift since then
+	Measured time.Time
+	// MaxDrift defaults to DefaultMaxDrift
+	MaxDrift float64
+}
+
+// Uncertainty returns half of the measurement delay plus dispersion aged to the time now
+func (p *PHC) Uncertainty(now time.Time) time.Duration {
+	maxDrift := p.MaxDrift
+	if maxDrift == 0 {
+		maxDrift = DefaultMaxDrift
+	}
+	return aged(p.Delay/2+p.Dispersion, p.Measured, now, maxDrift)
+}
+
+// Window is the interval the true time is expected to be within
+type Window struct {
+	// Now is the system clock reading the window is centered around
+	Now      time.Time
+	Earliest time.Time
+	Latest   time.Time
+	// Uncertainty is the half width of the window
+	Uncertainty time.Duration
+	// Components is the error contributed by each source
+	Components map[string]time.Duration
+}
+
+// Before returns true if t is definitely before the true time
+func (w *Window) Before(t time.Time) bool {
+	return t.Before(w.Earliest)
+}
+
+// After returns true if t is definitely after the true time
+func (w *Window) After(t time.Time) bool {
+	return t.After(w.Latest)
+}
+
+// Contains returns true if t may be the true time
+func (w *Window) Contains(t time.Time) bool {
+	return !w.Before(t) && !w.After(t)
+}
+
+// Clock composes errors of multiple sources into Window
+type Clock struct {
+	sync.Mutex
+	sources map[string]Source
+	// Now is used to read the system clock, defaults to time.Now
+	Now func() time.Time
+}
+
+// NewClock returns a new Clock without sources
+func NewClock() *Clock {
+	return &Clock{
+		sources: map[string]Source{},
+		Now:     time.Now,
+	}
+}
+
+// Set adds the source or replaces the one with the same name
+func (c *Clock) Set(name string, s Source) {
+	c.Lock()
+	defer c.Unlock()
+	c.sources[name] = s
+}
+
+// Remove removes the source
+func (c *Clock) Remove(name string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.sources, name)
+}
+
+// Sources returns sorted names of the sources
+func (c *Clock) Sources() []string {
+	c.Lock()
+	defer c.Unlock()
+	names := make([]string, 0, len(c.sources))
+	for name := range c.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Window returns the window around the current system time
+func (c *Clock) Window() *Window {
+	return c.WindowAt(c.Now())
+}
+
+// WindowAt returns the window around the time now
+func (c *Clock) WindowAt(now time.Time) *Window {
+	c.Lock()
+	defer c.Unlock()
+	w := &Window{
+		Now:        now,
+		Components: make(map[string]time.Duration, len(c.sources)),
+	}
+	for name, s := range c.sources {
+		err := s.Uncertainty(now)
+		if err < 0 {
+			err = -err
+		}
+		w.Components[name] = err
+		w.Uncertainty += err
+	}
+	w.Earliest = now.Add(-w.Uncertainty)
+	w.Latest = now.Add(w.Uncertainty)
+	return w
+}
+
+// WaitUntil sleeps until t is definitely in the past, the way commit wait works in TrueTime.
+// It returns the window observed after waiting
+func (c *Clock) WaitUntil(t time.Time) *Window {
+	for {
+		w := c.Window()
+		if w.Before(t) {
+			return w
+		}
+		time.Sleep(t.Sub(w.Earliest) + time.Microsecond)
+	}
+}
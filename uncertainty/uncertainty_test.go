@@ -0,0 +1,89 @@
+/*
+Copyright (c) Facebook, Inc. and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uncertainty
+
+import (
+	"testing"
+	"time"
+
+	"github.com/facebook/time/oscillatord"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+
+func TestNTPUncertainty(t *testing.T) {
+	n := &NTP{
+		RootDelay:      2 * time.Millisecond,
+		RootDispersion: 500 * time.Microsecond,
+		Delay:          200 * time.Microsecond,
+		Dispersion:     10 * time.Microsecond,
+		Measured:       start,
+	}
+	require.Equal(t, 1610*time.Microsecond, n.Uncertainty(start))
+	// 15 ppm over 100 seconds
+	require.Equal(t, 3110*time.Microsecond, n.Uncertainty(start.Add(100*time.Second)))
+	// clock went backwards, no aging
+	require.Equal(t, 1610*time.Microsecond, n.Uncertainty(start.Add(-time.Second)))
+}
+
+func TestPHCUncertainty(t *testing.T) {
+	p := &PHC{Delay: 400 * time.Nanosecond, Dispersion: 30 * time.Nanosecond, Measured: start, MaxDrift: 1e-6}
+	require.Equal(t, 230*time.Nanosecond, p.Uncertainty(start))
+	require.Equal(t, 1230*time.Nanosecond, p.Uncertainty(start.Add(time.Second)))
+}
+
+func TestClockWindow(t *testing.T) {
+	holdover := oscillatord.NewHoldoverEstimator(oscillatord.DefaultHoldoverConfig)
+	c := NewClock()
+	c.Set("ntp", &NTP{RootDelay: time.Millisecond, Measured: start})
+	c.Set("holdover", holdover)
+	c.Set("phc", SourceFunc(func(now time.Time) time.Duration { return -50 * time.Nanosecond }))
+	require.Equal(t, []string{"holdover", "ntp", "phc"}, c.Sources())
+
+	w := c.WindowAt(start)
+	bound := 500*time.Microsecond + 150*time.Nanosecond
+	want := &Window{
+		Now:         start,
+		Earliest:    start.Add(-bound),
+		Latest:      start.Add(bound),
+		Uncertainty: bound,
+		Components: map[string]time.Duration{
+			"ntp":      500 * time.Microsecond,
+			"holdover": 100 * time.Nanosecond,
+			"phc":      50 * time.Nanosecond,
+		},
+	}
+	require.Equal(t, want, w)
+
+	require.True(t, w.Before(start.Add(-time.Millisecond)))
+	require.True(t, w.After(start.Add(time.Millisecond)))
+	require.True(t, w.Contains(start.Add(100*time.Microsecond)))
+	require.False(t, w.Contains(start.Add(-time.Millisecond)))
+
+	c.Remove("ntp")
+	require.Equal(t, 150*time.Nanosecond, c.WindowAt(start).Uncertainty)
+}
+
+func TestClockWaitUntil(t *testing.T) {
+	c := NewClock()
+	c.Set("ntp", SourceFunc(func(now time.Time) time.Duration { return 5 * time.Millisecond }))
+	deadline := time.Now()
+	w := c.WaitUntil(deadline)
+	require.True(t, w.Before(deadline))
+	require.GreaterOrEqual(t, time.Since(deadline), 5*time.Millisecond)
+}